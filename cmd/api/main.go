@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"flag"
 	"log"
 	"os"
@@ -10,8 +11,15 @@ import (
 	"time"
 
 	"github.com/kafitramarna/TransisiDB/internal/api"
+	"github.com/kafitramarna/TransisiDB/internal/backfill"
 	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/database"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
 	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/onboarding"
+	"github.com/kafitramarna/TransisiDB/internal/reconcile"
+	"github.com/kafitramarna/TransisiDB/internal/repair"
+	"github.com/kafitramarna/TransisiDB/internal/scheduler"
 )
 
 var (
@@ -39,6 +47,7 @@ func main() {
 		logger.Info("API will start but config operations will be limited")
 	} else {
 		logger.Info("Redis connection established")
+		redisStore.SetAuditKey([]byte(cfg.Audit.HMACKey))
 
 		// Save current config to Redis if needed
 		ctx := context.Background()
@@ -56,6 +65,42 @@ func main() {
 
 	// Create API server (without backfill worker for now)
 	server := api.NewServer(&cfg.API, redisStore, nil)
+	server.SetFileTables(cfg.Tables)
+	server.SetProxyAdmin(cfg.ProxyAdminURL(), cfg.Monitoring.MetricsPath)
+	if redisStore != nil {
+		// Backfill jobs are run by transisidb-backfill --worker
+		server.SetBackfillQueue(backfill.NewJobQueue(redisStore.Client()))
+		server.SetBackfillRuns(backfill.NewRedisRuns(redisStore.Client()))
+	}
+
+	// Reconcile jobs, onboarding, repairs and table row counts fail until the database is reachable
+	var db *sql.DB
+	dbPool, err := database.NewPool(&cfg.Database)
+	if err != nil {
+		logger.Warn("Database connection failed, reconcile jobs, onboarding and repairs will fail", "error", err)
+	} else {
+		db = dbPool.GetDB()
+		server.SetDatabase(db)
+	}
+
+	var pipeline *onboarding.Pipeline
+	var repairer *repair.Repairer
+	if redisStore != nil {
+		// Table locks are shared with the backfill workers
+		locks := lock.NewRedis(redisStore.Client())
+		pipeline = onboarding.New(db, cfg, redisStore, onboarding.NewRedisRecords(redisStore.Client()))
+		pipeline.SetLocker(locks)
+		server.SetOnboarding(pipeline)
+		repairer = repair.New(db, cfg, redisStore, repair.NewRedisRecords(redisStore.Client()))
+		repairer.SetLocker(locks)
+		server.SetRepairer(repairer)
+		server.SetLocker(locks)
+		server.SetReconcileResults(reconcile.NewRedisResults(redisStore.Client()))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopScheduler := startScheduler(ctx, cfg, redisStore, db, server)
 
 	// Start server in goroutine
 	go func() {
@@ -74,13 +119,26 @@ func main() {
 	log.Println("\nShutdown signal received, gracefully stopping...")
 
 	// Shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer shutdownCancel()
 
-	if err := server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Error("Error during shutdown", "error", err)
 	}
 
+	// Let running jobs finish recording before Redis closes
+	cancel()
+	stopScheduler()
+	if pipeline != nil {
+		pipeline.Wait()
+	}
+	if repairer != nil {
+		repairer.Wait()
+	}
+	if dbPool != nil {
+		dbPool.Close()
+	}
+
 	if redisStore != nil {
 		if err := redisStore.Close(); err != nil {
 			logger.Error("Error closing Redis", "error", err)
@@ -89,3 +147,36 @@ func main() {
 
 	logger.Info("Server stopped cleanly")
 }
+
+// startScheduler runs the scheduled jobs when the scheduler is enabled. Job
+// runs are claimed in Redis, so several API servers can run the scheduler.
+// The returned function waits for running jobs after ctx is cancelled.
+func startScheduler(ctx context.Context, cfg *config.Config, store *config.RedisStore, db *sql.DB, server *api.Server) func() {
+	if !cfg.Scheduler.Enabled {
+		return func() {}
+	}
+	if store == nil {
+		logger.Warn("Scheduler needs Redis to record job runs; scheduled jobs are disabled")
+		return func() {}
+	}
+
+	sched := scheduler.New(scheduler.NewRedisStore(store.Client()), cfg.Scheduler)
+	// Reconcile jobs save their results for GET /api/v1/reconciliation/report
+	results := reconcile.NewRedisResults(store.Client())
+	sched.Register(config.JobTypeReconcile, scheduler.Reconcile(db, cfg, results))
+	sched.Register(config.JobTypeWarehouseReconcile, scheduler.WarehouseReconcile(db, cfg, results))
+	sched.Register(config.JobTypeCertExpiry, scheduler.CertExpiry)
+	sched.Register(config.JobTypeConfigBackup, scheduler.ConfigBackup(store))
+	if err := sched.Load(ctx); err != nil {
+		log.Fatalf("Failed to load scheduled jobs: %v", err)
+	}
+	server.SetScheduler(sched)
+
+	done := make(chan struct{})
+	go func() {
+		sched.Run(ctx)
+		close(done)
+	}()
+	logger.Info("Scheduler started", "jobs", len(cfg.Scheduler.Jobs))
+	return func() { <-done }
+}
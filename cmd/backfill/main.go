@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -13,18 +17,23 @@ import (
 	"github.com/kafitramarna/TransisiDB/internal/backfill"
 	"github.com/kafitramarna/TransisiDB/internal/config"
 	"github.com/kafitramarna/TransisiDB/internal/database"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
 )
 
 var (
 	configPath = flag.String("config", "config.yaml", "Path to configuration file")
-	tableName  = flag.String("table", "", "Table name to backfill (required)")
-	dryRun     = flag.Bool("dry-run", false, "Dry run mode (count rows only)")
+	tableName  = flag.String("table", "", "Table name to backfill (required unless --worker)")
+	dryRun     = flag.Bool("dry-run", false, "Report the backfill's expected impact instead of running it")
+	calibrate  = flag.Int("calibrate-rows", 0, "With --dry-run, backfill this many rows to measure batch time and replica lag")
+	reportPath = flag.String("report", "", "With --dry-run, also write the impact report as JSON to this file")
+	workerMode = flag.Bool("worker", false, "Run backfill jobs queued through the API until stopped")
+	noLock     = flag.Bool("no-lock", false, "Run without the table lock; nothing stops a concurrent backfill or onboarding of the table")
 )
 
 func main() {
 	flag.Parse()
 
-	if *tableName == "" {
+	if *tableName == "" && !*workerMode {
 		log.Fatal("Error: --table flag is required")
 	}
 
@@ -39,6 +48,11 @@ func main() {
 		log.Fatal("Backfill is disabled in configuration")
 	}
 
+	if *workerMode {
+		runWorker(cfg)
+		return
+	}
+
 	// Check if table is configured
 	tableConfig, exists := cfg.Tables[*tableName]
 	if !exists {
@@ -67,6 +81,11 @@ func main() {
 	// Create backfill worker
 	worker := backfill.NewWorker(dbPool.GetDB(), cfg)
 
+	if *dryRun {
+		runDryRun(cfg, worker, tableConfig)
+		return
+	}
+
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -89,11 +108,9 @@ func main() {
 	// Start backfill
 	startTime := time.Now()
 
-	if *dryRun {
-		log.Println("DRY RUN MODE: Counting rows only...")
-	}
-
-	err = worker.Start(ctx, *tableName, tableConfig)
+	err = startLocked(ctx, cfg, *tableName, func(ctx context.Context, lease *lock.Lease) error {
+		return worker.Start(ctx, *tableName, tableConfig, lease)
+	})
 
 	duration := time.Since(startTime)
 
@@ -117,6 +134,131 @@ func main() {
 	}
 }
 
+// runDryRun prints the expected impact of backfilling the table. Nothing is
+// written unless --calibrate-rows asks for a calibration run.
+func runDryRun(cfg *config.Config, worker *backfill.Worker, tableConfig config.TableConfig) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var replicas map[string]*sql.DB
+	if *calibrate > 0 {
+		log.Printf("DRY RUN MODE: backfilling %d rows to calibrate the estimates", *calibrate)
+		replicas = make(map[string]*sql.DB)
+		for _, replica := range cfg.Database.Replicas {
+			pool, err := database.NewPool(&cfg.ForReplica(replica).Database)
+			if err != nil {
+				log.Printf("Replica %s unavailable, its lag is not measured: %v", replica.Name, err)
+				continue
+			}
+			defer pool.Close()
+			replicas[replica.Name] = pool.GetDB()
+		}
+	} else {
+		log.Println("DRY RUN MODE: nothing is written")
+	}
+
+	var report *backfill.ImpactReport
+	estimate := func(ctx context.Context, _ *lock.Lease) (err error) {
+		report, err = worker.Estimate(ctx, *tableName, tableConfig, *calibrate, replicas)
+		return err
+	}
+	var err error
+	if *calibrate > 0 {
+		// The calibration writes rows like a backfill
+		err = startLocked(ctx, cfg, *tableName, estimate)
+	} else {
+		err = estimate(ctx, nil)
+	}
+	if err != nil {
+		log.Fatalf("Impact estimate failed: %v", err)
+	}
+
+	fmt.Print("\n" + report.String())
+	if *reportPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+		if err := os.WriteFile(*reportPath, append(data, '\n'), 0o644); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		log.Printf("Report written to %s", *reportPath)
+	}
+}
+
+// runWorker runs jobs from the backfill queue until a shutdown signal
+func runWorker(cfg *config.Config) {
+	store, err := config.NewRedisStore(&cfg.Redis)
+	if err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer store.Close()
+
+	dbPool, err := database.NewPool(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer dbPool.Close()
+
+	// One backfill worker per job run at once
+	workers := []*backfill.Worker{backfill.NewWorker(dbPool.GetDB(), cfg)}
+	runner := backfill.NewRunner(backfill.NewJobQueue(store.Client()), workers[0], cfg)
+	for len(workers) < cfg.Backfill.WorkerConcurrency() {
+		worker := backfill.NewWorker(dbPool.GetDB(), cfg)
+		runner.AddMigrator(worker)
+		workers = append(workers, worker)
+	}
+	runner.SetLocker(lock.NewRedis(store.Client()))
+	runner.SetRuns(backfill.NewRedisRuns(store.Client()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		// Cancelling leaves the running job unacknowledged so another
+		// worker resumes it
+		log.Println("\nReceived shutdown signal, stopping backfill worker...")
+		cancel()
+	}()
+
+	for _, worker := range workers {
+		go reportProgress(ctx, worker, 10*time.Second)
+	}
+
+	log.Printf("Backfill worker running up to %d jobs at once from %s", len(workers), backfill.JobStream)
+	runner.Run(ctx)
+	log.Println("Backfill worker stopped")
+}
+
+// startLocked runs a direct backfill holding the table's lock, so it does
+// not race a queued backfill or an onboarding of the same table. It fails
+// when Redis is unavailable; --no-lock runs fn without the lock and a nil
+// lease.
+func startLocked(ctx context.Context, cfg *config.Config, table string, fn func(ctx context.Context, lease *lock.Lease) error) error {
+	if *noLock {
+		log.Printf("Running without the table lock (--no-lock)")
+		return fn(ctx, nil)
+	}
+	store, err := config.NewRedisStore(&cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("redis is needed for the table lock, run with --no-lock to backfill without it: %w", err)
+	}
+	defer store.Close()
+
+	err = lock.Hold(ctx, lock.NewRedis(store.Client()), lock.Table(table), lock.Owner(), lock.DefaultTTL,
+		func(ctx context.Context, lease lock.Lease) error {
+			log.Printf("Holding lock %s (token %d)", lease.Name, lease.Token)
+			return fn(ctx, &lease)
+		})
+	if errors.Is(err, lock.ErrHeld) {
+		log.Fatalf("Table '%s' is being migrated elsewhere: %v", table, err)
+	}
+	return err
+}
+
 // reportProgress periodically prints progress updates
 func reportProgress(ctx context.Context, worker *backfill.Worker, interval time.Duration) {
 	ticker := time.NewTicker(interval)
@@ -0,0 +1,79 @@
+// Command e2e runs the end-to-end smoke test: it starts MySQL and Redis in
+// Docker, runs the proxy and the management API in-process and checks
+// dual-write, transactions, rounding and the API through them.
+//
+// It exits 0 when every scenario passed, 1 when one failed and 2 when the
+// deployment could not be started.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/e2e"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+func main() {
+	configPath := flag.String("config", "config.yaml", "Path to the configuration file the proxy and API start from")
+	schemaPath := flag.String("schema", "scripts/init.sql", "SQL script creating the test tables")
+	mysqlImage := flag.String("mysql-image", e2e.DefaultMySQLImage, "MySQL image")
+	redisImage := flag.String("redis-image", e2e.DefaultRedisImage, "Redis image")
+	logLevel := flag.String("log-level", "ERROR", "Log level of the proxy and API")
+	asJSON := flag.Bool("json", false, "Print the results as JSON")
+	flag.Parse()
+
+	logger.Init(*logLevel)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := e2e.Run(ctx, e2e.Options{
+		ConfigPath: *configPath,
+		SchemaPath: *schemaPath,
+		MySQLImage: *mysqlImage,
+		RedisImage: *redisImage,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+		stop()
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printReport(report)
+	}
+	if report.Failed() > 0 {
+		stop()
+		os.Exit(1)
+	}
+}
+
+func printReport(r *e2e.Report) {
+	width := 0
+	for _, result := range r.Results {
+		width = max(width, len(result.Name))
+	}
+	for _, result := range r.Results {
+		status := "PASS"
+		if !result.Passed() {
+			status = "FAIL"
+		}
+		fmt.Printf("%-4s  %-*s  %s", status, width, result.Name, result.Duration.Round(time.Millisecond))
+		if !result.Passed() {
+			fmt.Printf("  %s", result.Error)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("\n%d passed, %d failed\n", len(r.Results)-r.Failed(), r.Failed())
+}
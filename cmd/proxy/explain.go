@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/dualwrite"
+)
+
+// runExplain prints how the proxy would rewrite a statement and returns the
+// exit code: 0 when it is rewritten or left unchanged, 1 when the rewrite
+// fails and 2 when the configuration cannot be loaded
+func runExplain(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	path := fs.String("config", *configPath, "Path to configuration file")
+	asJSON := fs.Bool("json", false, "Print the trace as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: proxy explain [flags] \"<SQL>\"")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	query := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if query == "" {
+		fs.Usage()
+		return 2
+	}
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 2
+	}
+
+	e := dualwrite.Explain(cfg, query)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(e)
+	} else {
+		fmt.Print(e)
+	}
+	if e.Error != "" {
+		return 1
+	}
+	return 0
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"flag"
 	"fmt"
 	"log"
@@ -8,9 +10,14 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/kafitramarna/TransisiDB/internal/canary"
 	"github.com/kafitramarna/TransisiDB/internal/config"
 	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/outbox"
 	"github.com/kafitramarna/TransisiDB/internal/proxy"
+	"github.com/kafitramarna/TransisiDB/internal/schema"
+
+	_ "github.com/go-sql-driver/mysql"
 )
 
 var (
@@ -22,6 +29,16 @@ var (
 func main() {
 	flag.Parse()
 
+	if flag.Arg(0) == "selftest" {
+		os.Exit(runSelfTest(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "tls" {
+		os.Exit(runTLS(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "explain" {
+		os.Exit(runExplain(flag.Args()[1:]))
+	}
+
 	printBanner()
 
 	// Load configuration
@@ -39,6 +56,69 @@ func main() {
 	// Initialize and start proxy server
 	server := proxy.NewServer(cfg)
 
+	// Pick up query rules and table toggles managed through the API
+	redisStore, err := config.NewRedisStore(&cfg.Redis)
+	if err != nil {
+		logger.Warn("Redis connection failed, query rules from config file only", "error", err)
+	} else {
+		defer redisStore.Close()
+		redisStore.SetAuditKey([]byte(cfg.Audit.HMACKey))
+		if err := server.WatchQueryRules(context.Background(), redisStore); err != nil {
+			logger.Warn("Failed to watch query rules", "error", err)
+		}
+		if err := server.WatchTableToggles(context.Background(), redisStore); err != nil {
+			logger.Warn("Failed to watch table toggles", "error", err)
+		}
+	}
+
+	// Fill the shadow columns of async tables from the outbox
+	if asyncTables(cfg) {
+		if redisStore == nil {
+			logger.Warn("Outbox needs Redis, dual-writing async tables synchronously")
+		} else if err := startOutbox(context.Background(), cfg, server, redisStore); err != nil {
+			logger.Warn("Outbox disabled, dual-writing async tables synchronously", "error", err)
+		}
+	}
+
+	// Cache live table metadata from information_schema
+	if cfg.Schema.Enabled {
+		if err := startSchemaCache(context.Background(), cfg, server); err != nil {
+			logger.Warn("Schema cache disabled", "error", err)
+		}
+	}
+
+	// Read the shadow values the conversion guard compares UPDATEs with on
+	// connections of the proxy's own; none are opened while the guard is off
+	if db, err := proxy.OpenBackend(cfg); err != nil {
+		logger.Warn("Conversion guard shadow lookups disabled", "error", err)
+	} else {
+		db.SetMaxOpenConns(cfg.Proxy.PoolSize)
+		server.SetShadowLookup(db)
+	}
+
+	// Replay rewritten statements on the canary backend
+	if cfg.Canary.Enabled {
+		if err := startCanary(context.Background(), cfg, server); err != nil {
+			logger.Warn("Canary comparison disabled", "error", err)
+		}
+	}
+
+	// Resolve backends configured with service discovery
+	server.WatchDiscovery(context.Background())
+
+	// Follow primary failovers to the configured standbys
+	server.WatchPrimary(context.Background())
+
+	// Probe the backend through the proxy to catch slow responses
+	server.StartProbe(context.Background())
+
+	// Serve metrics for Prometheus and the management API
+	go func() {
+		if err := server.StartAdmin(); err != nil {
+			logger.Error("Proxy admin endpoint error", "error", err)
+		}
+	}()
+
 	// Handle shutdown signals
 	go func() {
 		sigChan := make(chan os.Signal, 1)
@@ -55,6 +135,83 @@ func main() {
 	}
 }
 
+// startSchemaCache loads the configured database's metadata and keeps it
+// refreshed for the lifetime of the process
+func startSchemaCache(ctx context.Context, cfg *config.Config, server *proxy.Server) error {
+	db, err := proxy.OpenBackend(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open metadata connection: %w", err)
+	}
+	db.SetMaxOpenConns(2)
+
+	cache := schema.NewCache(schema.NewSQLLoader(db), cfg.Schema.RefreshInterval, cfg.Database.LowerCaseTableNames != 0)
+	if err := cache.Refresh(ctx, cfg.Database.Database); err != nil {
+		// Databases are loaded again on first lookup
+		logger.Warn("Initial schema load failed", "database", cfg.Database.Database, "error", err)
+	}
+	for _, problem := range schema.CheckTargetColumns(ctx, cache, cfg.Database.Database, cfg.Tables) {
+		logger.Error("Shadow column does not match table config", "error", problem)
+	}
+	go cache.Run(ctx)
+
+	server.SetSchemaCache(cache)
+	logger.Info("Schema cache enabled", "database", cfg.Database.Database, "refresh_interval", cfg.Schema.RefreshInterval)
+	return nil
+}
+
+// startCanary replays rewritten statements on the canary backend for the
+// lifetime of the process
+func startCanary(ctx context.Context, cfg *config.Config, server *proxy.Server) error {
+	db, err := sql.Open("mysql", cfg.GetCanaryDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open canary connection: %w", err)
+	}
+	workers := cfg.Canary.Workers
+	if workers <= 0 {
+		workers = config.DefaultCanaryWorkers
+	}
+	db.SetMaxOpenConns(workers)
+
+	replayer := canary.NewReplayer(db, cfg.Canary)
+	go replayer.Run(ctx)
+
+	server.SetCanary(replayer)
+	logger.Info("Canary comparison enabled", "host", cfg.Canary.Host, "sample_rate", cfg.Canary.SampleRate)
+	return nil
+}
+
+// asyncTables reports whether any enabled table uses write_mode async
+func asyncTables(cfg *config.Config) bool {
+	for _, tableConfig := range cfg.Tables {
+		if tableConfig.Enabled && tableConfig.IsAsync() {
+			return true
+		}
+	}
+	return false
+}
+
+// startOutbox queues the shadow column updates of async tables and applies
+// them for the lifetime of the process
+func startOutbox(ctx context.Context, cfg *config.Config, server *proxy.Server, store *config.RedisStore) error {
+	db, err := proxy.OpenBackend(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox connection: %w", err)
+	}
+	workers := cfg.Outbox.Workers
+	if workers <= 0 {
+		workers = config.DefaultOutboxWorkers
+	}
+	db.SetMaxOpenConns(workers)
+
+	queue := outbox.NewRedisQueue(store.Client(), cfg.Outbox)
+	worker := outbox.NewWorker(queue, outbox.NewSQLApplier(db, cfg), cfg.Outbox)
+	go worker.Run(ctx)
+
+	server.SetOutbox(queue)
+	logger.Info("Outbox enabled", "workers", workers)
+	return nil
+}
+
 func printBanner() {
 	banner := `
 ╔════════════════════════════════════════════════════════════════╗
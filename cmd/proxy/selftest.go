@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/dualwrite"
+)
+
+// runSelfTest checks the conversion invariants of every configured currency
+// column and returns the exit code: 0 when all hold, 1 when some do not and 2
+// when the configuration cannot be loaded
+func runSelfTest(args []string) int {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	path := fs.String("config", *configPath, "Path to configuration file")
+	samples := fs.Int("samples", 10000, "Number of amounts to convert per column")
+	seed := fs.Int64("seed", 1, "Seed of the random amounts")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 2
+	}
+
+	columns := 0
+	for _, tableConfig := range cfg.Tables {
+		columns += len(tableConfig.Columns)
+	}
+	fmt.Printf("Checking %d columns with %d amounts each (ratio 1:%d, seed %d)\n",
+		columns, *samples, cfg.Conversion.Ratio, *seed)
+
+	failures := dualwrite.SelfTest(cfg, dualwrite.SampleAmounts(*seed, *samples))
+	for _, failure := range failures {
+		fmt.Println("FAIL", failure)
+	}
+	if len(failures) > 0 {
+		fmt.Printf("%d failures\n", len(failures))
+		return 1
+	}
+	fmt.Println("All conversion invariants hold")
+	return 0
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/certgen"
+)
+
+// runTLS runs a tls subcommand and returns the exit code
+func runTLS(args []string) int {
+	if len(args) == 0 || args[0] != "generate" {
+		fmt.Fprintln(os.Stderr, "Usage: transisidb tls generate [-out dir] [-hosts names] [-clients names] [-days n] [-force]")
+		return 2
+	}
+	return runTLSGenerate(args[1:])
+}
+
+// runTLSGenerate writes a development CA with server and client certificates
+// and a configuration snippet using them. It returns 0 on success, 1 when the
+// files cannot be written and 2 on invalid flags.
+func runTLSGenerate(args []string) int {
+	fs := flag.NewFlagSet("tls generate", flag.ExitOnError)
+	out := fs.String("out", "certs", "Directory to write the certificates to")
+	hosts := fs.String("hosts", strings.Join(certgen.DefaultHosts, ","), "Comma-separated DNS names and IP addresses of the server certificate")
+	clients := fs.String("clients", certgen.DefaultClient, "Comma-separated common names of the client certificates")
+	days := fs.Int("days", int(certgen.DefaultValidity/(24*time.Hour)), "Days the certificates are valid")
+	force := fs.Bool("force", false, "Replace existing files")
+	fs.Parse(args)
+
+	if *days <= 0 {
+		fmt.Fprintln(os.Stderr, "-days must be positive")
+		return 2
+	}
+	bundle, err := certgen.Generate(certgen.Options{
+		Hosts:    splitList(*hosts),
+		Clients:  splitList(*clients),
+		Validity: time.Duration(*days) * 24 * time.Hour,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate certificates: %v\n", err)
+		return 2
+	}
+	files, err := bundle.Write(*out, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write certificates: %v (use -force to replace them)\n", err)
+		return 1
+	}
+
+	fmt.Println("Development certificates (do not use in production):")
+	fmt.Printf("  CA:     %s\n", files.CA)
+	fmt.Printf("  Server: %s (%s)\n", files.ServerCert, strings.Join(splitList(*hosts), ", "))
+	for _, client := range files.Clients {
+		fmt.Printf("  Client: %s (%s)\n", client.Cert, client.Name)
+	}
+	fmt.Printf("  Valid until %s\n", bundle.CA.Certificate.NotAfter.UTC().Format(time.RFC3339))
+	fmt.Printf("\nSample configuration: %s\n", files.Config)
+	fmt.Printf("Start Redis with:\n  %s\n", certgen.RedisServerCommand(files))
+	return 0
+}
+
+// splitList splits a comma-separated flag, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
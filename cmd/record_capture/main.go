@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// record_capture relays one client connection to a MySQL server and writes
+// every packet in the fixture format read by pkg/protocol's capture tests:
+// one line per packet, S or C followed by the packet's hex, header included.
+//
+// The relay does not understand TLS or compression, so connect with them off:
+//
+//	go run cmd/record_capture/main.go -backend 127.0.0.1:3306 -out handshake.capture
+//	mysql -h 127.0.0.1 -P 3310 --ssl-mode=DISABLED -u root -p
+func main() {
+	listen := flag.String("listen", "127.0.0.1:3310", "Address the client connects to")
+	backend := flag.String("backend", "127.0.0.1:3306", "MySQL server to relay to")
+	out := flag.String("out", "session.capture", "Fixture file to write")
+	flag.Parse()
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("Listen failed: %v", err)
+	}
+	fmt.Printf("Waiting for a client on %s\n", *listen)
+
+	client, err := ln.Accept()
+	ln.Close()
+	if err != nil {
+		log.Fatalf("Accept failed: %v", err)
+	}
+	defer client.Close()
+
+	server, err := net.Dial("tcp", *backend)
+	if err != nil {
+		log.Fatalf("Dial %s failed: %v", *backend, err)
+	}
+	defer server.Close()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Create %s failed: %v", *out, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "# Recorded from %s\n", *backend)
+
+	var mu sync.Mutex
+	record := func(direction string, packet []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s %s\n", direction, hex.EncodeToString(packet))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		relay(server, client, "S", record)
+		client.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		relay(client, server, "C", record)
+		server.Close()
+	}()
+	wg.Wait()
+
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Write %s failed: %v", *out, err)
+	}
+	fmt.Printf("Capture written to %s\n", *out)
+}
+
+// relay copies packets from src to dst until either side closes, recording
+// each one as it passes
+func relay(src, dst net.Conn, direction string, record func(string, []byte)) {
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(src, header); err != nil {
+			return
+		}
+		length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+
+		packet := make([]byte, 4+length)
+		copy(packet, header)
+		if _, err := io.ReadFull(src, packet[4:]); err != nil {
+			return
+		}
+
+		record(direction, packet)
+		if _, err := dst.Write(packet); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/dbmode"
+	"github.com/kafitramarna/TransisiDB/internal/proxy"
+)
+
+// tableCheck is the outcome of dbmode check for one table
+type tableCheck struct {
+	Table        string              `json:"table"`
+	Installation dbmode.Installation `json:"installation"`
+	Mismatches   []dbmode.Mismatch   `json:"mismatches"`
+	Drift        []dbmode.Drift      `json:"drift"`
+	Error        string              `json:"error,omitempty"`
+}
+
+// ok reports whether the table's triggers or generated columns are installed,
+// round like the proxy and left no drift
+func (t tableCheck) ok() bool {
+	if t.Error != "" || !t.Installation.OK() || len(t.Mismatches) > 0 {
+		return false
+	}
+	for _, drift := range t.Drift {
+		if drift.Missing > 0 || drift.Mismatched > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// runDBMode runs a dbmode subcommand and returns the exit code: plan and
+// remove print DDL, apply executes it and check exits with 1 when a table is
+// not installed as planned, rounds differently from the proxy or drifted
+func runDBMode(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	command := args[0]
+	flags := flag.NewFlagSet("dbmode "+command, flag.ExitOnError)
+	configPath := flags.String("config", "config.yaml", "Path to configuration file")
+	tableName := flags.String("table", "", "Only this table instead of every table in database write mode")
+	asJSON := flags.Bool("json", false, "Print the result of check as JSON")
+	flags.Parse(args[1:])
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 2
+	}
+	tables, err := databaseModeTables(cfg, *tableName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	switch command {
+	case "plan", "remove":
+		for _, table := range tables {
+			statements, err := statementsFor(command, cfg, table)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return 2
+			}
+			fmt.Printf("-- %s (%s)\n", table, cfg.Tables[table].Method())
+			for _, statement := range statements {
+				fmt.Printf("%s;\n", statement)
+			}
+		}
+		return 0
+	case "apply", "check":
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	db, err := proxy.OpenBackend(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to MySQL: %v\n", err)
+		return 2
+	}
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if command == "apply" {
+		for _, table := range tables {
+			statements, err := statementsFor("plan", cfg, table)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return 2
+			}
+			for _, statement := range statements {
+				if _, err := db.ExecContext(ctx, statement); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to apply %s: %v\n", table, err)
+					return 1
+				}
+			}
+			fmt.Printf("%s: installed %s\n", table, cfg.Tables[table].Method())
+		}
+		return 0
+	}
+
+	checks := make([]tableCheck, len(tables))
+	failed := false
+	for i, table := range tables {
+		checks[i] = checkTable(ctx, db, cfg, table)
+		failed = failed || !checks[i].ok()
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(checks)
+	} else {
+		for _, check := range checks {
+			printTableCheck(check)
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// databaseModeTables returns the named table, or every table in database
+// write mode, sorted
+func databaseModeTables(cfg *config.Config, name string) ([]string, error) {
+	if name != "" {
+		tableConfig, ok := cfg.Tables[name]
+		if !ok {
+			return nil, fmt.Errorf("table %s is not configured", name)
+		}
+		if !tableConfig.InDatabase() {
+			return nil, fmt.Errorf("table %s is not in database write mode", name)
+		}
+		return []string{name}, nil
+	}
+
+	var tables []string
+	for table, tableConfig := range cfg.Tables {
+		if tableConfig.InDatabase() {
+			tables = append(tables, table)
+		}
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no table is in database write mode")
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+// statementsFor returns the install (plan) or remove statements of a table
+func statementsFor(command string, cfg *config.Config, table string) ([]string, error) {
+	if command == "remove" {
+		return dbmode.RemovePlan(table, cfg.Tables[table])
+	}
+	return dbmode.Plan(table, cfg.Tables[table], cfg.Conversion)
+}
+
+// checkTable inspects what is installed for a table, evaluates its
+// expressions and counts its drift
+func checkTable(ctx context.Context, db *sql.DB, cfg *config.Config, table string) tableCheck {
+	tableConfig := cfg.Tables[table]
+	check := tableCheck{Table: table, Mismatches: []dbmode.Mismatch{}, Drift: []dbmode.Drift{}}
+	var err error
+	if check.Installation, err = dbmode.Inspect(ctx, db, cfg.Database.Database, table, tableConfig, cfg.Conversion); err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	if check.Mismatches, err = dbmode.CheckExpressions(ctx, db, table, tableConfig, cfg.Conversion); err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	if check.Drift, err = dbmode.CountDrift(ctx, db, table, tableConfig, cfg.Conversion); err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
+
+func printTableCheck(check tableCheck) {
+	status := "OK"
+	if !check.ok() {
+		status = "FAILED"
+	}
+	fmt.Printf("%s (%s): %s\n", check.Table, check.Installation.Method, status)
+	if check.Error != "" {
+		fmt.Printf("  error: %s\n", check.Error)
+		return
+	}
+	for _, name := range check.Installation.Missing {
+		fmt.Printf("  not installed: %s, run transisidb dbmode apply\n", name)
+	}
+	for _, name := range check.Installation.Outdated {
+		fmt.Printf("  outdated: %s differs from the configuration, run transisidb dbmode apply\n", name)
+	}
+	for _, m := range check.Mismatches {
+		fmt.Printf("  rounding: %s converts %d to %s in MySQL, %s in the proxy\n", m.Column, m.Amount, m.MySQL, m.Proxy)
+	}
+	for _, drift := range check.Drift {
+		if drift.Missing > 0 || drift.Mismatched > 0 {
+			fmt.Printf("  drift: %s has %d rows without a shadow value and %d off from the amount\n",
+				drift.Column, drift.Missing, drift.Mismatched)
+		}
+	}
+}
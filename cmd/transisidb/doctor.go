@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kafitramarna/TransisiDB/internal/doctor"
+)
+
+// runDoctor runs doctor and returns the exit code: 0 when every check passed
+// or only warned, 1 when a check failed
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := flags.String("config", "config.yaml", "Path to configuration file")
+	asJSON := flags.Bool("json", false, "Print the checks as JSON")
+	flags.Parse(args)
+
+	report := doctor.Run(context.Background(), *configPath)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printReport(report)
+	}
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
+
+func printReport(r *doctor.Report) {
+	width := 0
+	for _, check := range r.Checks {
+		width = max(width, len(check.Name))
+	}
+	for _, check := range r.Checks {
+		fmt.Printf("%-4s  %-*s  %s\n", check.Status, width, check.Name, check.Detail)
+	}
+	fmt.Printf("\n%d passed, %d warnings, %d failed, %d skipped\n",
+		r.Count(doctor.Pass), r.Count(doctor.Warn), r.Count(doctor.Fail), r.Count(doctor.Skip))
+}
@@ -0,0 +1,120 @@
+// Command transisidb runs administrative tasks against a deployment.
+//
+//	transisidb audit verify [--config config.yaml] [--json]
+//	transisidb doctor [--config config.yaml] [--json]
+//	transisidb dbmode plan|remove|apply|check [--config config.yaml] [--table name] [--json]
+//
+// audit verify checks the audit log kept in Redis for modified, missing or
+// forged entries. It exits with 1 when the log is not intact.
+//
+// doctor checks that the proxy can start: the configuration, the connections
+// to MySQL, Redis and the replicas, the shadow columns, the TLS material and
+// the binlog settings. It exits with 1 when a check fails.
+//
+// dbmode manages the triggers or generated columns of tables in database
+// write mode: plan and remove print their DDL, apply installs them and check
+// compares what is installed, how MySQL rounds and the drift of the shadow
+// columns. check exits with 1 when a table fails.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+const usage = `Usage: transisidb <command>
+
+Commands:
+  audit verify   Check the audit log for tampering or truncation
+  doctor         Check the configuration, connections and schema before starting
+  dbmode plan    Print the triggers or generated columns of database write mode
+  dbmode remove  Print the DDL removing them
+  dbmode apply   Install them
+  dbmode check   Check they are installed, round like the proxy and left no drift
+`
+
+func main() {
+	switch {
+	case len(os.Args) >= 3 && os.Args[1] == "audit" && os.Args[2] == "verify":
+		os.Exit(auditVerify(os.Args[3:]))
+	case len(os.Args) >= 2 && os.Args[1] == "doctor":
+		os.Exit(runDoctor(os.Args[2:]))
+	case len(os.Args) >= 2 && os.Args[1] == "dbmode":
+		os.Exit(runDBMode(os.Args[2:]))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// auditVerify runs audit verify and returns the exit code
+func auditVerify(args []string) int {
+	flags := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	configPath := flags.String("config", "config.yaml", "Path to configuration file")
+	asJSON := flags.Bool("json", false, "Print the result as JSON")
+	flags.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 2
+	}
+	store, err := config.NewRedisStore(&cfg.Redis)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to Redis: %v\n", err)
+		return 2
+	}
+	defer store.Close()
+	store.SetAuditKey([]byte(cfg.Audit.HMACKey))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := store.VerifyAudit(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to verify audit log: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	} else {
+		printVerification(result)
+	}
+	if !result.OK() {
+		return 1
+	}
+	return 0
+}
+
+func printVerification(v config.AuditVerification) {
+	fmt.Printf("Entries checked: %d", v.Entries)
+	if v.LastSeq > 0 {
+		fmt.Printf(" (%d to %d)", v.FirstSeq, v.LastSeq)
+	}
+	fmt.Println()
+	if v.Unchained > 0 {
+		fmt.Printf("Unchained:       %d older entries predate chaining and were not checked\n", v.Unchained)
+	}
+	if v.Signed {
+		fmt.Println("Signatures:      checked")
+	} else {
+		fmt.Println("Signatures:      not checked, audit.hmac_key is not set")
+	}
+
+	if v.OK() {
+		fmt.Println("Result:          intact")
+		return
+	}
+	fmt.Println("Result:          NOT intact")
+	for _, problem := range v.Problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+}
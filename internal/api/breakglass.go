@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+// Get the break glass switch
+func (s *Server) handleGetBreakGlass(c *gin.Context) {
+	if !s.requireConfigStore(c) {
+		return
+	}
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	state, err := s.configStore.LoadBreakGlass(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load break glass: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, breakGlassBody(state))
+}
+
+// Put every proxy into raw pass-through for the given minutes. Proxies apply
+// it on the published reload, or within proxy.table_toggle_interval when they
+// miss it.
+func (s *Server) handleBreakGlassOn(c *gin.Context) {
+	if !s.requireAdmin(c) || !s.requireConfigStore(c) {
+		return
+	}
+
+	var req struct {
+		Minutes int    `json:"minutes"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Request body must be {\"reason\": \"...\", \"minutes\": <n>}",
+		})
+		return
+	}
+	state, err := config.NewBreakGlass(actor(c), req.Reason, time.Duration(req.Minutes)*time.Minute)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	if err := s.configStore.SetBreakGlass(ctx, state); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to turn break glass on: " + err.Error(),
+		})
+		return
+	}
+
+	logger.Error("BREAK GLASS turned on, proxies forward every statement without parsing or rewriting",
+		"actor", state.Actor, "reason", state.Reason, "expires", state.Expires.Format(time.RFC3339))
+	s.audit(ctx, config.AuditEntry{Time: time.Now(), Actor: state.Actor, Action: "break_glass_on", Target: "proxy", Detail: state.Detail()})
+	s.publishReload(ctx)
+
+	c.JSON(http.StatusOK, breakGlassBody(state))
+}
+
+// Turn break glass off before it expires
+func (s *Server) handleBreakGlassOff(c *gin.Context) {
+	if !s.requireAdmin(c) || !s.requireConfigStore(c) {
+		return
+	}
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	cleared, err := s.configStore.ClearBreakGlass(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to turn break glass off: " + err.Error(),
+		})
+		return
+	}
+	if cleared != nil {
+		logger.Warn("BREAK GLASS turned off", "actor", actor(c))
+		s.audit(ctx, config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: "break_glass_off", Target: "proxy"})
+		s.publishReload(ctx)
+	}
+
+	c.JSON(http.StatusOK, breakGlassBody(nil))
+}
+
+// requireAdmin answers 403 unless the request authenticated with api.admin_key
+func (s *Server) requireAdmin(c *gin.Context) bool {
+	if c.GetString(roleContextKey) != roleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This endpoint needs the admin key (api.admin_key)",
+		})
+		return false
+	}
+	return true
+}
+
+// breakGlassBody reports the switch, which is off when state is nil
+func breakGlassBody(state *config.BreakGlass) gin.H {
+	if !state.Active(time.Now()) {
+		return gin.H{"active": false}
+	}
+	return gin.H{
+		"active":            true,
+		"actor":             state.Actor,
+		"reason":            state.Reason,
+		"started":           state.Started,
+		"expires":           state.Expires,
+		"remaining_seconds": int64(time.Until(state.Expires).Seconds()),
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_BreakGlassNeedsAdminKey(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key", AdminKey: "admin-key"}, nil, nil)
+	do := func(method, key, body string) int {
+		req := httptest.NewRequest(method, "/api/v1/break-glass", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	body := `{"reason": "parser incident", "minutes": 30}`
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "test-key", body))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "test-key", ""))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "other-key", body))
+
+	// The admin key passes the role check; the switch needs Redis
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodPost, "admin-key", body))
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "test-key", ""))
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "admin-key", ""))
+}
+
+func TestServer_BreakGlass(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+	store, err := config.NewRedisStore(&config.RedisConfig{Host: "localhost", Port: 6379, Database: 15, PoolSize: 2})
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer store.Close()
+
+	server := NewServer(&config.APIConfig{APIKey: "test-key", AdminKey: "admin-key"}, store, nil)
+	do := func(method, key, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, "/api/v1/break-glass", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+
+	code, _ := do(http.MethodPost, "admin-key", `{"minutes": 30}`)
+	assert.Equal(t, http.StatusBadRequest, code, "a reason is required")
+	code, _ = do(http.MethodPost, "admin-key", `{"reason": "incident", "minutes": 600}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, resp := do(http.MethodPost, "admin-key", `{"reason": "parser incident", "minutes": 30}`)
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, true, resp["active"])
+
+	code, resp = do(http.MethodGet, "test-key", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "parser incident", resp["reason"])
+	assert.InDelta(t, 1800, resp["remaining_seconds"], 5)
+
+	code, resp = do(http.MethodDelete, "admin-key", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, resp["active"])
+	_, resp = do(http.MethodGet, "test-key", "")
+	assert.Equal(t, false, resp["active"])
+}
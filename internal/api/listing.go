@@ -0,0 +1,146 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
+	"github.com/kafitramarna/TransisiDB/internal/scheduler"
+)
+
+// Page sizes of list endpoints
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// listQuery holds the pagination, filter and sort parameters of a list
+// endpoint: ?limit=, ?offset=, ?name= and ?sort=<field> or ?sort=-<field>
+type listQuery struct {
+	Limit  int
+	Offset int
+	// Name keeps the items whose name contains it, ignoring case
+	Name string
+	Sort string
+	Desc bool
+}
+
+// sortField compares two items by one field
+type sortField[T any] struct {
+	name    string
+	compare func(a, b T) int
+}
+
+// byField returns a sort field of an endpoint
+func byField[T any](name string, compare func(a, b T) int) sortField[T] {
+	return sortField[T]{name: name, compare: compare}
+}
+
+// Sort fields of the list endpoints, the default first
+var (
+	tableSortFields = []sortField[config.TableSource]{
+		byField("name", func(a, b config.TableSource) int { return cmp.Compare(a.Name, b.Name) }),
+		byField("source", func(a, b config.TableSource) int { return cmp.Compare(a.Source, b.Source) }),
+	}
+	sessionSortFields = []sortField[sessionInfo]{
+		byField("conn_id", func(a, b sessionInfo) int { return cmp.Compare(a.ConnID, b.ConnID) }),
+		byField("user", func(a, b sessionInfo) int { return cmp.Compare(a.User, b.User) }),
+		byField("connected_at", func(a, b sessionInfo) int { return a.ConnectedAt.Compare(b.ConnectedAt) }),
+		byField("queries", func(a, b sessionInfo) int { return cmp.Compare(a.Queries, b.Queries) }),
+	}
+	jobSortFields = []sortField[scheduler.JobStatus]{
+		byField("name", func(a, b scheduler.JobStatus) int { return cmp.Compare(a.Name, b.Name) }),
+		byField("next_run", func(a, b scheduler.JobStatus) int { return a.NextRun.Compare(b.NextRun) }),
+		byField("type", func(a, b scheduler.JobStatus) int { return cmp.Compare(a.Type, b.Type) }),
+	}
+	lockSortFields = []sortField[lock.Lease]{
+		byField("name", func(a, b lock.Lease) int { return cmp.Compare(a.Name, b.Name) }),
+		byField("acquired_at", func(a, b lock.Lease) int { return a.AcquiredAt.Compare(b.AcquiredAt) }),
+		byField("expires_at", func(a, b lock.Lease) int { return a.ExpiresAt.Compare(b.ExpiresAt) }),
+	}
+)
+
+// parseListQuery reads the list parameters of a request. The first of fields
+// is the default sort order.
+func parseListQuery[T any](c *gin.Context, fields []sortField[T]) (listQuery, error) {
+	q := listQuery{Limit: defaultPageLimit, Name: c.Query("name"), Sort: fields[0].name}
+
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			return q, fmt.Errorf("limit must be an integer between 1 and %d", maxPageLimit)
+		}
+		q.Limit = n
+	}
+	if value := c.Query("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("offset must be a non-negative integer")
+		}
+		q.Offset = n
+	}
+	if value := c.Query("sort"); value != "" {
+		q.Sort, q.Desc = strings.CutPrefix(value, "-")
+		known := make([]string, len(fields))
+		for i, field := range fields {
+			known[i] = field.name
+		}
+		if !slices.Contains(known, q.Sort) {
+			return q, fmt.Errorf("sort must be one of %s, optionally prefixed with -", strings.Join(known, ", "))
+		}
+	}
+	return q, nil
+}
+
+// paginate filters items by name, sorts them and returns the requested page
+// with the number of items that matched the filter
+func paginate[T any](items []T, q listQuery, name func(T) string, fields []sortField[T]) ([]T, int) {
+	if q.Name != "" {
+		filter := strings.ToLower(q.Name)
+		items = slices.DeleteFunc(items, func(item T) bool {
+			return !strings.Contains(strings.ToLower(name(item)), filter)
+		})
+	}
+
+	compare := fields[0].compare
+	for _, field := range fields {
+		if field.name == q.Sort {
+			compare = field.compare
+		}
+	}
+	slices.SortStableFunc(items, func(a, b T) int {
+		order := compare(a, b)
+		if order == 0 {
+			order = cmp.Compare(name(a), name(b))
+		}
+		if q.Desc {
+			return -order
+		}
+		return order
+	})
+
+	total := len(items)
+	start := min(q.Offset, total)
+	end := min(start+q.Limit, total)
+	return items[start:end], total
+}
+
+// listResponse is the envelope of a page: the items under key, their count,
+// the number of items matching the filter and the page bounds
+func listResponse[T any](key string, page []T, total int, q listQuery) gin.H {
+	if page == nil {
+		page = []T{}
+	}
+	return gin.H{
+		key:      page,
+		"count":  len(page),
+		"total":  total,
+		"limit":  q.Limit,
+		"offset": q.Offset,
+	}
+}
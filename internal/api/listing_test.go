@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/tables?"+query, nil)
+	return c
+}
+
+func TestParseListQuery(t *testing.T) {
+	q, err := parseListQuery(listContext(""), tableSortFields)
+	require.NoError(t, err)
+	assert.Equal(t, listQuery{Limit: defaultPageLimit, Sort: "name"}, q)
+
+	q, err = parseListQuery(listContext("limit=20&offset=40&name=ord&sort=-source"), tableSortFields)
+	require.NoError(t, err)
+	assert.Equal(t, listQuery{Limit: 20, Offset: 40, Name: "ord", Sort: "source", Desc: true}, q)
+
+	for _, query := range []string{"limit=0", "limit=5000", "limit=x", "offset=-1", "sort=rows"} {
+		_, err := parseListQuery(listContext(query), tableSortFields)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	tables := []config.TableSource{
+		{Name: "orders", Source: config.TableSourceYAML},
+		{Name: "order_items", Source: config.TableSourceRedis},
+		{Name: "invoices", Source: config.TableSourceRedis},
+		{Name: "Orders_Archive", Source: config.TableSourceOverridden},
+	}
+	name := func(t config.TableSource) string { return t.Name }
+	names := func(page []config.TableSource) []string {
+		var out []string
+		for _, t := range page {
+			out = append(out, t.Name)
+		}
+		return out
+	}
+
+	page, total := paginate(append([]config.TableSource(nil), tables...), listQuery{Limit: 2, Name: "ORDER", Sort: "name"}, name, tableSortFields)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"Orders_Archive", "order_items"}, names(page))
+
+	page, total = paginate(append([]config.TableSource(nil), tables...), listQuery{Limit: 2, Offset: 2, Name: "order", Sort: "name"}, name, tableSortFields)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"orders"}, names(page))
+
+	// Ties are broken by name
+	page, _ = paginate(append([]config.TableSource(nil), tables...), listQuery{Limit: 10, Sort: "source", Desc: true}, name, tableSortFields)
+	assert.Equal(t, []string{"orders", "order_items", "invoices", "Orders_Archive"}, names(page))
+
+	page, total = paginate(append([]config.TableSource(nil), tables...), listQuery{Limit: 10, Offset: 10, Sort: "name"}, name, tableSortFields)
+	assert.Equal(t, 4, total)
+	assert.Empty(t, page)
+}
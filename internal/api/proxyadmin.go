@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// proxyAdmin reads state owned by the proxy process through its admin endpoint
+type proxyAdmin struct {
+	baseURL     string
+	metricsPath string
+	client      *http.Client
+}
+
+// newProxyAdmin creates a client for the proxy admin endpoint at baseURL
+func newProxyAdmin(baseURL, metricsPath string) *proxyAdmin {
+	if metricsPath == "" {
+		metricsPath = "/metrics"
+	}
+	return &proxyAdmin{
+		baseURL:     baseURL,
+		metricsPath: metricsPath,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// get performs a GET against the proxy admin endpoint
+func (p *proxyAdmin) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy admin request: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("proxy admin endpoint unreachable: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("proxy admin endpoint returned %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// getJSON decodes a JSON document served by the proxy admin endpoint
+func (p *proxyAdmin) getJSON(ctx context.Context, path string, v interface{}) error {
+	resp, err := p.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode proxy response: %w", err)
+	}
+	return nil
+}
+
+// Gather scrapes the proxy's Prometheus metrics, so it can be used as a
+// prometheus.Gatherer
+func (p *proxyAdmin) Gather() ([]*dto.MetricFamily, error) {
+	resp, err := p.get(context.Background(), p.metricsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	byName, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proxy metrics: %w", err)
+	}
+
+	families := make([]*dto.MetricFamily, 0, len(byName))
+	for _, mf := range byName {
+		families = append(families, mf)
+	}
+	return families, nil
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyAdmin_GatherFeedsLatencySummary(t *testing.T) {
+	// Stand-in for the proxy process and its registry
+	reg := prometheus.NewRegistry()
+	backend := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "transisidb_query_backend_duration_seconds",
+	}, []string{"statement"})
+	reg.MustRegister(backend)
+	backend.WithLabelValues("SELECT").Observe((3 * time.Millisecond).Seconds())
+
+	ts := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	defer ts.Close()
+
+	summary, err := metrics.LatencySummary(newProxyAdmin(ts.URL, "/"))
+	require.NoError(t, err)
+	require.Contains(t, summary, "SELECT")
+	assert.Equal(t, uint64(1), summary["SELECT"].Phases["backend"].Count)
+	assert.InDelta(t, 3.0, summary["SELECT"].Phases["backend"].AvgMs, 0.001)
+}
+
+func TestProxyAdmin_Unreachable(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	ts.Close()
+
+	_, err := newProxyAdmin(ts.URL, "").Gather()
+	assert.Error(t, err)
+}
+
+func TestProxyAdmin_GetJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/parser/failures", r.URL.Path)
+		w.Write([]byte(`[{"fingerprint":"SELEC ?","reason":"syntax_error","timestamp":"2025-01-15T10:30:00Z"}]`))
+	}))
+	defer ts.Close()
+
+	var failures []parser.FailureSample
+	require.NoError(t, newProxyAdmin(ts.URL, "").getJSON(context.Background(), "/parser/failures", &failures))
+	require.Len(t, failures, 1)
+	assert.Equal(t, "SELEC ?", failures[0].Fingerprint)
+}
+
+func TestProxyAdmin_GetJSONCancelled(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	// A client that disconnects cancels the call to the proxy
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	var stats map[string]any
+	err := newProxyAdmin(ts.URL, "").getJSON(ctx, "/stats", &stats)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+}
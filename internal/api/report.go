@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/reconcile"
+)
+
+// Report datasets and formats
+const (
+	reportMismatches = "mismatches"
+	reportAudit      = "audit"
+	formatCSV        = "csv"
+	formatParquet    = "parquet"
+)
+
+// auditExportLimit covers the whole audit log, which keeps 1000 entries
+const auditExportLimit = 1000
+
+// Export the latest reconciliation results or the audit log as a file
+func (s *Server) handleReconciliationReport(c *gin.Context) {
+	dataset := c.DefaultQuery("dataset", reportMismatches)
+	if dataset != reportMismatches && dataset != reportAudit {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("dataset must be %s or %s, got %q", reportMismatches, reportAudit, dataset),
+		})
+		return
+	}
+	switch format := c.DefaultQuery("format", formatCSV); format {
+	case formatCSV:
+	case formatParquet:
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error": "Parquet export is not supported, use format=csv",
+		})
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("format must be %s or %s, got %q", formatCSV, formatParquet, format),
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	var header []string
+	var records [][]string
+	switch dataset {
+	case reportMismatches:
+		if s.reconcileResults == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Reconciliation results need Redis and are not available",
+			})
+			return
+		}
+		results, err := s.reconcileResults.Latest(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("Failed to load reconciliation results: %v", err),
+			})
+			return
+		}
+		header, records = mismatchRecords(results)
+
+	case reportAudit:
+		if !s.requireConfigStore(c) {
+			return
+		}
+		entries, err := s.configStore.LoadAudit(ctx, auditExportLimit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("Failed to load audit log: %v", err),
+			})
+			return
+		}
+		header, records = auditRecords(entries)
+	}
+
+	filename := fmt.Sprintf("transisidb-%s-%s.csv", dataset, time.Now().UTC().Format("20060102T150405Z"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(header)
+	for _, record := range records {
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		logger.Warn("Failed to write report", "dataset", dataset, "error", err)
+	}
+}
+
+// mismatchRecords flattens results to one row per column and divergence kind
+func mismatchRecords(results []reconcile.Result) ([]string, [][]string) {
+	header := []string{"checked", "job", "table", "column", "kind", "rows", "sample_ids"}
+	var records [][]string
+	for _, result := range results {
+		for _, kind := range reconcile.Kinds() {
+			count, ok := result.Counts[kind]
+			if !ok {
+				continue
+			}
+			ids := make([]string, len(result.SampleIDs[kind]))
+			for i, id := range result.SampleIDs[kind] {
+				ids[i] = strconv.FormatInt(id, 10)
+			}
+			records = append(records, []string{
+				result.Checked.UTC().Format(time.RFC3339),
+				result.Job,
+				result.Table,
+				result.Column,
+				kind,
+				strconv.FormatInt(count, 10),
+				strings.Join(ids, " "),
+			})
+		}
+	}
+	return header, records
+}
+
+// auditRecords lists audit entries, newest first
+func auditRecords(entries []config.AuditEntry) ([]string, [][]string) {
+	header := []string{"time", "actor", "action", "target", "detail"}
+	records := make([][]string, len(entries))
+	for i, entry := range entries {
+		records[i] = []string{entry.Time.UTC().Format(time.RFC3339), entry.Actor, entry.Action, entry.Target, entry.Detail}
+	}
+	return header, records
+}
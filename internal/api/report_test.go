@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/reconcile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ReconciliationReport(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/reconciliation/report"+query, nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, get("").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get("?dataset=audit").Code)
+	assert.Equal(t, http.StatusBadRequest, get("?dataset=sessions").Code)
+	assert.Equal(t, http.StatusBadRequest, get("?format=xlsx").Code)
+	assert.Equal(t, http.StatusNotImplemented, get("?format=parquet").Code)
+
+	results := reconcile.NewMemoryResults()
+	checked := time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	require.NoError(t, results.Save(ctx, reconcile.Result{
+		Checked: checked, Job: "nightly", Table: "orders", Column: "total_amount",
+		Counts: map[string]int64{reconcile.KindNotBackfilled: 0, reconcile.KindConversion: 2},
+	}))
+	require.NoError(t, results.Save(ctx, reconcile.Result{
+		Checked: checked, Job: "warehouse", Table: "orders", Column: "total_amount",
+		Counts:    map[string]int64{reconcile.KindConversion: 0, reconcile.KindDownstream: 1, reconcile.KindNotBackfilled: 0, reconcile.KindMissing: 0},
+		SampleIDs: map[string][]int64{reconcile.KindDownstream: {7}},
+	}))
+	server.SetReconcileResults(results)
+
+	rec := get("?format=csv")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "transisidb-mismatches-")
+	assert.Equal(t, "checked,job,table,column,kind,rows,sample_ids\n"+
+		"2026-10-17T02:00:00Z,nightly,orders,total_amount,conversion,2,\n"+
+		"2026-10-17T02:00:00Z,nightly,orders,total_amount,not_backfilled,0,\n"+
+		"2026-10-17T02:00:00Z,warehouse,orders,total_amount,conversion,0,\n"+
+		"2026-10-17T02:00:00Z,warehouse,orders,total_amount,downstream,1,7\n"+
+		"2026-10-17T02:00:00Z,warehouse,orders,total_amount,not_backfilled,0,\n"+
+		"2026-10-17T02:00:00Z,warehouse,orders,total_amount,missing,0,\n",
+		rec.Body.String())
+}
+
+func TestAuditRecords(t *testing.T) {
+	header, records := auditRecords([]config.AuditEntry{{
+		Time: time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC), Actor: "api:10.0.0.5", Action: "plan_repair", Target: "orders", Detail: "token abc, 3 rows",
+	}})
+	assert.Equal(t, []string{"time", "actor", "action", "target", "detail"}, header)
+	assert.Equal(t, [][]string{{"2026-10-17T09:30:00Z", "api:10.0.0.5", "plan_repair", "orders", "token abc, 3 rows"}}, records)
+}
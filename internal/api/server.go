@@ -2,16 +2,46 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kafitramarna/TransisiDB/internal/backfill"
+	"github.com/kafitramarna/TransisiDB/internal/canary"
 	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/dualwrite"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
 	"github.com/kafitramarna/TransisiDB/internal/logger"
 	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/observability"
+	"github.com/kafitramarna/TransisiDB/internal/onboarding"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/internal/queue"
+	"github.com/kafitramarna/TransisiDB/internal/reconcile"
+	"github.com/kafitramarna/TransisiDB/internal/repair"
+	"github.com/kafitramarna/TransisiDB/internal/scheduler"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gopkg.in/yaml.v3"
+)
+
+// Handler timeouts
+const (
+	// requestTimeout bounds the Redis and database calls of a request, below
+	// the server's write timeout
+	requestTimeout = 10 * time.Second
+	// followUpTimeout bounds the audit entry and reload notification written
+	// after a change
+	followUpTimeout = 5 * time.Second
 )
 
 // Server represents the management API server
@@ -19,8 +49,38 @@ type Server struct {
 	router         *gin.Engine
 	config         *config.APIConfig
 	configStore    *config.RedisStore
+	fileTables     config.TablesConfig
 	backfillWorker *backfill.Worker
-	httpServer     *http.Server
+	backfillJobs   queue.Queue
+	scheduler      *scheduler.Scheduler
+	onboarding     *onboarding.Pipeline
+	repairs        *repair.Repairer
+	// reconcileResults backs the mismatch report, nil without Redis
+	reconcileResults reconcile.Results
+	// backfillRuns are the last backfill runs by table, nil without Redis
+	backfillRuns backfill.Runs
+	// db counts the rows of the table statistics, nil while the database is
+	// unreachable
+	db         *sql.DB
+	locks      lock.Locker
+	proxyAdmin *proxyAdmin
+	httpServer *http.Server
+	// requests is the base context of requests, cancelled by Shutdown once
+	// its grace period has passed
+	requests       context.Context
+	cancelRequests context.CancelFunc
+}
+
+// sessionInfo mirrors the session snapshot served by the proxy admin endpoint
+type sessionInfo struct {
+	ConnID      uint32    `json:"conn_id"`
+	User        string    `json:"user"`
+	Database    string    `json:"database"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Listener    string    `json:"listener,omitempty"`
+	Role        string    `json:"role,omitempty"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Queries     uint64    `json:"queries"`
 }
 
 // NewServer creates a new API server
@@ -36,12 +96,68 @@ func NewServer(cfg *config.APIConfig, configStore *config.RedisStore, worker *ba
 		configStore:    configStore,
 		backfillWorker: worker,
 	}
+	server.requests, server.cancelRequests = context.WithCancel(context.Background())
 
 	server.setupRoutes()
 
 	return server
 }
 
+// SetFileTables lists the tables of the config file alongside those stored in
+// Redis, so they are served before they are synced or after Redis was flushed
+func (s *Server) SetFileTables(tables config.TablesConfig) {
+	s.fileTables = tables
+}
+
+// SetProxyAdmin points the API at the proxy's admin endpoint, which serves
+// the metrics recorded in the proxy process
+func (s *Server) SetProxyAdmin(baseURL, metricsPath string) {
+	s.proxyAdmin = newProxyAdmin(baseURL, metricsPath)
+}
+
+// SetBackfillQueue dispatches backfill jobs started through the API to the
+// backfill workers consuming q
+func (s *Server) SetBackfillQueue(q queue.Queue) {
+	s.backfillJobs = q
+}
+
+// SetScheduler exposes the scheduled jobs of sched through the API
+func (s *Server) SetScheduler(sched *scheduler.Scheduler) {
+	s.scheduler = sched
+}
+
+// SetOnboarding enables table onboarding through pipeline
+func (s *Server) SetOnboarding(pipeline *onboarding.Pipeline) {
+	s.onboarding = pipeline
+}
+
+// SetRepairer enables planning and applying mismatch repairs
+func (s *Server) SetRepairer(repairer *repair.Repairer) {
+	s.repairs = repairer
+}
+
+// SetReconcileResults exports the latest reconcile job results through the
+// reconciliation report
+func (s *Server) SetReconcileResults(results reconcile.Results) {
+	s.reconcileResults = results
+}
+
+// SetBackfillRuns reports the last backfill run of each table in the table
+// statistics
+func (s *Server) SetBackfillRuns(runs backfill.Runs) {
+	s.backfillRuns = runs
+}
+
+// SetDatabase counts the rows of the tables in their statistics with db
+func (s *Server) SetDatabase(db *sql.DB) {
+	s.db = db
+}
+
+// SetLocker exposes the locks held by backfills and onboardings through the API
+func (s *Server) SetLocker(locker lock.Locker) {
+	s.locks = locker
+}
+
 // setupRoutes configures all API routes
 func (s *Server) setupRoutes() {
 	// Prometheus metrics endpoint (public - no auth for scraping)
@@ -60,6 +176,12 @@ func (s *Server) setupRoutes() {
 		v1.GET("/config", s.handleGetConfig)
 		v1.PUT("/config", s.handleUpdateConfig)
 		v1.POST("/config/reload", s.handleReloadConfig)
+		v1.GET("/config/export", s.handleExportConfig)
+		v1.POST("/config/import", s.handleImportConfig)
+		v1.GET("/config/staged", s.handleGetStagedConfig)
+		v1.PUT("/config/staged", s.handleStageConfig)
+		v1.POST("/config/staged/promote", s.handlePromoteStagedConfig)
+		v1.DELETE("/config/staged", s.handleAbortStagedConfig)
 
 		// Backfill endpoints
 		v1.POST("/backfill/start", s.handleBackfillStart)
@@ -67,12 +189,72 @@ func (s *Server) setupRoutes() {
 		v1.POST("/backfill/resume", s.handleBackfillResume)
 		v1.POST("/backfill/stop", s.handleBackfillStop)
 		v1.GET("/backfill/status", s.handleBackfillStatus)
+		v1.GET("/backfill/queue", s.handleBackfillQueue)
 
 		// Table configuration endpoints
 		v1.GET("/tables", s.handleListTables)
 		v1.GET("/tables/:name", s.handleGetTable)
+		v1.GET("/tables/:name/stats", s.handleTableStats)
 		v1.PUT("/tables/:name", s.handleUpdateTable)
 		v1.DELETE("/tables/:name", s.handleDeleteTable)
+		v1.POST("/tables/:name/restore", s.handleRestoreTable)
+		v1.PATCH("/tables/:name/enable", s.handleToggleTable(true))
+		v1.PATCH("/tables/:name/disable", s.handleToggleTable(false))
+		v1.PATCH("/tables/:name/rollout", s.handleTableRollout)
+		v1.PATCH("/tables/:name/mode", s.handleTableMode)
+		v1.POST("/tables/:name/onboard", s.handleOnboardTable)
+		v1.GET("/tables/:name/onboard", s.handleOnboardingStatus)
+		v1.POST("/tables/:name/repair", s.handlePlanRepair)
+		v1.POST("/tables/:name/repair/apply", s.handleApplyRepair)
+		v1.GET("/tables/:name/repair/:token", s.handleRepairStatus)
+
+		// Scheduled jobs
+		v1.GET("/jobs", s.handleListJobs)
+		v1.PUT("/jobs/:name", s.handleSaveJob)
+		v1.DELETE("/jobs/:name", s.handleDeleteJob)
+
+		// Locks held by exclusive operations
+		v1.GET("/locks", s.handleListLocks)
+
+		// Audit log of runtime changes
+		v1.GET("/audit", s.handleGetAudit)
+
+		// Break glass pass-through, turned on and off by admins
+		v1.GET("/break-glass", s.handleGetBreakGlass)
+		v1.POST("/break-glass", s.handleBreakGlassOn)
+		v1.DELETE("/break-glass", s.handleBreakGlassOff)
+
+		// Reconciliation and audit exports
+		v1.GET("/reconciliation/report", s.handleReconciliationReport)
+
+		// Query rules endpoints
+		v1.GET("/rules", s.handleGetRules)
+		v1.PUT("/rules", s.handleUpdateRules)
+
+		// Parser diagnostics
+		v1.GET("/parser/failures", s.handleParserFailures)
+		v1.GET("/ddl/proposals", s.handleShadowProposals)
+		v1.POST("/explain", s.handleExplain)
+
+		// Canary comparison
+		v1.GET("/canary/mismatches", s.handleCanaryMismatches)
+
+		// Client session endpoints
+		v1.GET("/sessions", s.handleListSessions)
+		v1.GET("/stats", s.handleProxyStats)
+
+		// Monitoring setup
+		v1.GET("/observability/bundle", s.handleObservabilityBundle)
+	}
+
+	// API v2 routes (protected)
+	v2 := s.router.Group("/api/v2")
+	v2.Use(s.authMiddleware())
+	v2.Use(s.metricsMiddleware())
+	v2.Use(s.loggingMiddleware())
+	{
+		// Metrics endpoints
+		v2.GET("/metrics/summary", s.handleMetricsSummary)
 	}
 }
 
@@ -94,7 +276,12 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 			apiKey = apiKey[7:]
 		}
 
-		if apiKey != s.config.APIKey {
+		role := roleOperator
+		switch {
+		case apiKey == s.config.APIKey:
+		case s.config.AdminKey != "" && apiKey == s.config.AdminKey:
+			role = roleAdmin
+		default:
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid API key",
 			})
@@ -102,10 +289,39 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		c.Set(keyIDContextKey, keyID(apiKey))
+		c.Set(roleContextKey, role)
 		c.Next()
 	}
 }
 
+// keyIDContextKey holds the ID of the API key a request authenticated with,
+// roleContextKey the role of that key
+const (
+	keyIDContextKey = "api_key_id"
+	roleContextKey  = "api_role"
+)
+
+// Roles of the API keys: api.api_key is an operator, api.admin_key an admin
+const (
+	roleOperator = "operator"
+	roleAdmin    = "admin"
+)
+
+// keyID identifies an API key in the audit log without revealing it
+func keyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:4])
+}
+
+// actor identifies the caller in audit entries by address and API key ID
+func actor(c *gin.Context) string {
+	if id := c.GetString(keyIDContextKey); id != "" {
+		return "api:" + c.ClientIP() + "/key:" + id
+	}
+	return "api:" + c.ClientIP()
+}
+
 // metricsMiddleware tracks API request metrics
 func (s *Server) metricsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -171,7 +387,7 @@ func (s *Server) handleHealth(c *gin.Context) {
 
 	// Check Redis health if available
 	if s.configStore != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
 		defer cancel()
 
 		if err := s.configStore.Health(ctx); err != nil {
@@ -185,9 +401,34 @@ func (s *Server) handleHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, health)
 }
 
+// Get per-statement latency breakdown (proxy overhead vs backend time)
+func (s *Server) handleMetricsSummary(c *gin.Context) {
+	if s.proxyAdmin == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Proxy admin endpoint is not configured",
+		})
+		return
+	}
+
+	// Latency histograms are recorded by the proxy process
+	summary, err := metrics.LatencySummary(s.proxyAdmin)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": fmt.Sprintf("Failed to summarize metrics: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"statements": summary,
+		"timestamp":  time.Now().Unix(),
+	})
+}
+
 // Get configuration
 func (s *Server) handleGetConfig(c *gin.Context) {
-	ctx := context.Background()
+	ctx, cancel := requestContext(c)
+	defer cancel()
 
 	cfg, err := s.configStore.LoadConfig(ctx)
 	if err != nil {
@@ -219,7 +460,8 @@ func (s *Server) handleUpdateConfig(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx, cancel := requestContext(c)
+	defer cancel()
 
 	// Save to Redis
 	if err := s.configStore.SaveConfig(ctx, &newConfig); err != nil {
@@ -237,7 +479,8 @@ func (s *Server) handleUpdateConfig(c *gin.Context) {
 
 // Reload configuration
 func (s *Server) handleReloadConfig(c *gin.Context) {
-	ctx := context.Background()
+	ctx, cancel := requestContext(c)
+	defer cancel()
 
 	if err := s.configStore.PublishReload(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -252,11 +495,291 @@ func (s *Server) handleReloadConfig(c *gin.Context) {
 	})
 }
 
-// Start backfill (placeholder - requires table name in request)
+// Export the running configuration with the tables and query rules stored in
+// Redis as a YAML (default) or JSON bundle
+func (s *Server) handleExportConfig(c *gin.Context) {
+	if !s.requireConfigStore(c) {
+		return
+	}
+	redact, err := queryBool(c, "redact")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	format := c.DefaultQuery("format", "yaml")
+	if format != "yaml" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("format must be yaml or json, got %q", format),
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	cfg, err := s.configStore.ExportConfig(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to export config: %v", err),
+		})
+		return
+	}
+	bundle := config.NewBundle(cfg, redact)
+
+	filename := fmt.Sprintf("transisidb-config-%s.%s", bundle.ExportedAt.Format("20060102T150405Z"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "json" {
+		c.JSON(http.StatusOK, bundle)
+		return
+	}
+	// Encoded like config.yaml so durations read back with ParseBundle
+	data, err := yaml.Marshal(bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to encode config: %v", err),
+		})
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml", data)
+}
+
+// Import a configuration bundle. Connection settings are kept unless
+// include_connections is set; dry_run reports the changes without saving.
+func (s *Server) handleImportConfig(c *gin.Context) {
+	if !s.requireConfigStore(c) {
+		return
+	}
+	dryRun, err := queryBool(c, "dry_run")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	includeConnections, err := queryBool(c, "include_connections")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to read request body: %v", err),
+		})
+		return
+	}
+	bundle, err := config.ParseBundle(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	current, err := s.configStore.ExportConfig(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load current config: %v", err),
+		})
+		return
+	}
+	next, err := bundle.ConfigFor(current, includeConnections)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	plan := config.PlanImport(current, next)
+
+	if dryRun || plan.Empty() {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run": dryRun,
+			"changes": plan,
+		})
+		return
+	}
+
+	if err := s.configStore.ImportConfig(ctx, next); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to import config: %v", err),
+		})
+		return
+	}
+
+	detail := fmt.Sprintf("%d tables added, %d updated, %d removed; sections changed: %v",
+		len(plan.TablesAdded), len(plan.TablesUpdated), len(plan.TablesRemoved), plan.SectionsChanged)
+	entry := config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: "import_config", Target: "config", Detail: detail}
+	s.audit(ctx, entry)
+	s.publishReload(ctx)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Configuration imported",
+		"dry_run": false,
+		"changes": plan,
+	})
+}
+
+// errorBody is the JSON body of a failed request. Classified errors add their
+// errs code, so clients can handle them without matching messages.
+func errorBody(err error, message string) gin.H {
+	body := gin.H{"error": message}
+	if code := errs.CodeOf(err); code != "" {
+		body["code"] = code
+	}
+	return body
+}
+
+// requestContext returns the context for a handler's Redis and database calls.
+// It is cancelled when the client disconnects, when the server shuts down
+// or after requestTimeout.
+func requestContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Request.Context(), requestTimeout)
+}
+
+// audit appends an entry for a change that has been made. The entry is
+// written even if the client has disconnected.
+func (s *Server) audit(ctx context.Context, entry config.AuditEntry) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), followUpTimeout)
+	defer cancel()
+	if err := s.configStore.AppendAudit(ctx, entry); err != nil {
+		logger.Warn("Failed to record audit entry", "action", entry.Action, "target", entry.Target, "error", err)
+	}
+}
+
+// publishReload tells the proxies about a change that has been made, even if
+// the client has disconnected; proxies that miss it pick the change up on
+// their next poll
+func (s *Server) publishReload(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), followUpTimeout)
+	defer cancel()
+	if err := s.configStore.PublishReload(ctx); err != nil {
+		logger.Warn("Failed to publish reload", "error", err)
+	}
+}
+
+// requireConfigStore answers 503 when the API server has no Redis connection
+func (s *Server) requireConfigStore(c *gin.Context) bool {
+	if s.configStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Config store is not available",
+		})
+		return false
+	}
+	return true
+}
+
+// queryBool parses an optional boolean query parameter
+func queryBool(c *gin.Context, name string) (bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false, got %q", name, value)
+	}
+	return b, nil
+}
+
+// Queue a backfill job for a table
 func (s *Server) handleBackfillStart(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{
-		"error":   "Backfill start requires integration with worker manager",
-		"message": "Use standalone CLI tool for now: transisidb-backfill",
+	if s.backfillJobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Backfill job queue is not configured",
+		})
+		return
+	}
+
+	var req struct {
+		Table    string `json:"table"`
+		Priority int    `json:"priority"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Table == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Request body must be {\"table\": \"<name>\", \"priority\": <n>}",
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	if s.configStore != nil {
+		tableConfig, err := s.configStore.LoadTableConfig(ctx, req.Table)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("Table not found: %v", err),
+			})
+			return
+		}
+		if !tableConfig.Enabled {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Table '%s' is not enabled for conversion", req.Table),
+			})
+			return
+		}
+	}
+
+	// Smaller tables run first among jobs of the same priority
+	requestedBy := actor(c)
+	job := backfill.Job{Table: req.Table, RequestedBy: requestedBy, Priority: req.Priority}
+	if s.backfillWorker != nil {
+		rows, err := s.backfillWorker.TableRows(ctx, req.Table)
+		if err != nil {
+			logger.Warn("Queuing backfill job without a size estimate", "table", req.Table, "error", err)
+		}
+		job.Rows = rows
+	}
+	id, err := backfill.Enqueue(ctx, s.backfillJobs, job)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to queue backfill job: %v", err),
+		})
+		return
+	}
+
+	if s.configStore != nil {
+		entry := config.AuditEntry{Time: time.Now(), Actor: requestedBy, Action: "start_backfill", Target: req.Table, Detail: id}
+		s.audit(ctx, entry)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  fmt.Sprintf("Backfill job for table '%s' queued", req.Table),
+		"job_id":   id,
+		"table":    req.Table,
+		"priority": job.Priority,
+		"rows":     job.Rows,
+	})
+}
+
+// List the queued backfill jobs: running jobs, then the others in the order
+// they will run
+func (s *Server) handleBackfillQueue(c *gin.Context) {
+	if s.backfillJobs == nil || s.locks == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Backfill job queue is not configured",
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	jobs, err := backfill.ListJobs(ctx, s.backfillJobs, s.locks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to list backfill jobs: %v", err),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  jobs,
+		"count": len(jobs),
 	})
 }
 
@@ -335,28 +858,38 @@ func (s *Server) handleBackfillStatus(c *gin.Context) {
 
 // List all tables
 func (s *Server) handleListTables(c *gin.Context) {
-	ctx := context.Background()
-
-	tables, err := s.configStore.ListTables(ctx)
+	q, err := parseListQuery(c, tableSortFields)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to list tables: %v", err),
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"tables": tables,
-		"count":  len(tables),
-	})
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	var stored map[string]config.TableConfig
+	if s.configStore != nil {
+		if stored, err = s.configStore.LoadTables(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("Failed to list tables: %v", err),
+			})
+			return
+		}
+	}
+
+	tables, total := paginate(config.MergeTables(s.fileTables, stored), q,
+		func(t config.TableSource) string { return t.Name }, tableSortFields)
+	c.JSON(http.StatusOK, listResponse("tables", tables, total, q))
 }
 
 // Get table configuration
 func (s *Server) handleGetTable(c *gin.Context) {
-	tableName := c.Param("name")
-	ctx := context.Background()
+	ctx, cancel := requestContext(c)
+	defer cancel()
 
-	tableConfig, err := s.configStore.LoadTableConfig(ctx, tableName)
+	tableConfig, err := s.lookupTable(ctx, c.Param("name"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": fmt.Sprintf("Table not found: %v", err),
@@ -367,6 +900,25 @@ func (s *Server) handleGetTable(c *gin.Context) {
 	c.JSON(http.StatusOK, tableConfig)
 }
 
+// lookupTable returns the config of a table stored in Redis or, until it is
+// stored there, in the config file
+func (s *Server) lookupTable(ctx context.Context, tableName string) (config.TableConfig, error) {
+	tableConfig, ok := s.fileTables[tableName]
+	if s.configStore != nil {
+		stored, err := s.configStore.LoadTableConfig(ctx, tableName)
+		switch {
+		case err == nil:
+			return *stored, nil
+		case !ok:
+			return config.TableConfig{}, err
+		}
+	}
+	if !ok {
+		return config.TableConfig{}, fmt.Errorf("%s", tableName)
+	}
+	return tableConfig, nil
+}
+
 // Update table configuration
 func (s *Server) handleUpdateTable(c *gin.Context) {
 	tableName := c.Param("name")
@@ -379,7 +931,8 @@ func (s *Server) handleUpdateTable(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx, cancel := requestContext(c)
+	defer cancel()
 
 	if err := s.configStore.SaveTableConfig(ctx, tableName, tableConfig); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -395,21 +948,789 @@ func (s *Server) handleUpdateTable(c *gin.Context) {
 
 // Delete table configuration
 func (s *Server) handleDeleteTable(c *gin.Context) {
+	if !s.requireConfigStore(c) {
+		return
+	}
+
 	tableName := c.Param("name")
-	ctx := context.Background()
+	ctx, cancel := requestContext(c)
+	defer cancel()
 
-	if err := s.configStore.DeleteTableConfig(ctx, tableName); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to delete table config: %v", err),
-		})
+	deleted, err := s.configStore.SoftDeleteTableConfig(ctx, tableName, actor(c), s.config.TableRetention())
+	switch {
+	case errors.Is(err, config.ErrTableNotFound):
+		c.JSON(errs.HTTPStatus(err), errorBody(err, fmt.Sprintf("Table not found: %v", err)))
+		return
+	case errors.Is(err, config.ErrTableEnabled):
+		c.JSON(errs.HTTPStatus(err), errorBody(err,
+			fmt.Sprintf("Table '%s' is enabled, disable it with PATCH /api/v1/tables/%s/disable first", tableName, tableName)))
+		return
+	case err != nil:
+		c.JSON(errs.HTTPStatus(err), errorBody(err, fmt.Sprintf("Failed to delete table config: %v", err)))
+		return
+	}
+
+	entry := config.AuditEntry{Time: time.Now(), Actor: deleted.DeletedBy, Action: "delete_table", Target: tableName,
+		Detail: "restorable until " + deleted.ExpiresAt.UTC().Format(time.RFC3339)}
+	s.audit(ctx, entry)
+	s.publishReload(ctx)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          fmt.Sprintf("Table '%s' configuration deleted", tableName),
+		"table":            tableName,
+		"restorable_until": deleted.ExpiresAt,
+	})
+}
+
+// Restore a deleted table config; the table comes back disabled
+func (s *Server) handleRestoreTable(c *gin.Context) {
+	if !s.requireConfigStore(c) {
 		return
 	}
 
+	tableName := c.Param("name")
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	tableConfig, err := s.configStore.RestoreTableConfig(ctx, tableName)
+	switch {
+	case errors.Is(err, config.ErrTableNotFound):
+		c.JSON(errs.HTTPStatus(err), errorBody(err, fmt.Sprintf("Table not found: %v", err)))
+		return
+	case errors.Is(err, config.ErrTableExists):
+		c.JSON(errs.HTTPStatus(err), errorBody(err,
+			fmt.Sprintf("Table '%s' has a configuration again and cannot be restored", tableName)))
+		return
+	case err != nil:
+		c.JSON(errs.HTTPStatus(err), errorBody(err, fmt.Sprintf("Failed to restore table config: %v", err)))
+		return
+	}
+
+	entry := config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: "restore_table", Target: tableName}
+	s.audit(ctx, entry)
+	s.publishReload(ctx)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Table '%s' configuration deleted", tableName),
+		"message": fmt.Sprintf("Table '%s' configuration restored", tableName),
+		"table":   tableName,
+		"config":  tableConfig,
 	})
 }
 
+// Enable or disable dual-write for a table, leaving the rest of its config
+// untouched. Proxies apply the change on the published reload, or within
+// proxy.table_toggle_interval when they miss it.
+func (s *Server) handleToggleTable(enabled bool) gin.HandlerFunc {
+	action, state := "disable_table", "disabled"
+	if enabled {
+		action, state = "enable_table", "enabled"
+	}
+
+	return func(c *gin.Context) {
+		tableName := c.Param("name")
+		ctx, cancel := requestContext(c)
+		defer cancel()
+
+		if _, err := s.configStore.LoadTableConfig(ctx, tableName); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("Table not found: %v", err),
+			})
+			return
+		}
+
+		if err := s.configStore.SetTableEnabled(ctx, tableName, enabled); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("Failed to update table: %v", err),
+			})
+			return
+		}
+
+		entry := config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: action, Target: tableName}
+		s.audit(ctx, entry)
+		s.publishReload(ctx)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("Table '%s' dual-write %s", tableName, state),
+			"enabled": enabled,
+		})
+	}
+}
+
+// Change the share of a table's statements that are dual-written
+func (s *Server) handleTableRollout(c *gin.Context) {
+	tableName := c.Param("name")
+
+	var req struct {
+		Percent *float64 `json:"percent"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Percent == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Request body must be {\"percent\": <0-100>}",
+		})
+		return
+	}
+	if *req.Percent < 0 || *req.Percent > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("percent must be between 0 and 100, got %v", *req.Percent),
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	if _, err := s.configStore.LoadTableConfig(ctx, tableName); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Table not found: %v", err),
+		})
+		return
+	}
+
+	if err := s.configStore.SetTableRollout(ctx, tableName, *req.Percent); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to update table: %v", err),
+		})
+		return
+	}
+
+	detail := strconv.FormatFloat(*req.Percent, 'f', -1, 64) + "%"
+	entry := config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: "set_rollout", Target: tableName, Detail: detail}
+	s.audit(ctx, entry)
+	s.publishReload(ctx)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Table '%s' dual-write rolled out to %s", tableName, detail),
+		"percent": *req.Percent,
+	})
+}
+
+// Switch a table between enforce and observe mode
+func (s *Server) handleTableMode(c *gin.Context) {
+	tableName := c.Param("name")
+
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Mode == "" || !config.ValidTableMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Request body must be {\"mode\": \"enforce\" | \"observe\"}",
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	if _, err := s.configStore.LoadTableConfig(ctx, tableName); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Table not found: %v", err),
+		})
+		return
+	}
+
+	if err := s.configStore.SetTableMode(ctx, tableName, req.Mode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to update table: %v", err),
+		})
+		return
+	}
+
+	entry := config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: "set_mode", Target: tableName, Detail: req.Mode}
+	s.audit(ctx, entry)
+	s.publishReload(ctx)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Table '%s' switched to %s mode", tableName, req.Mode),
+		"mode":    req.Mode,
+	})
+}
+
+// Start onboarding a configured table; GET on the same path reports progress
+func (s *Server) handleOnboardTable(c *gin.Context) {
+	if !s.requireOnboarding(c) {
+		return
+	}
+
+	var opts onboarding.Options
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid request body: %v", err),
+			})
+			return
+		}
+	}
+
+	tableName := c.Param("name")
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	record, err := s.onboarding.Start(ctx, tableName, opts, actor(c))
+	switch {
+	case errs.Is(err, errs.ConfigError):
+		c.JSON(errs.HTTPStatus(err), errorBody(err, err.Error()))
+		return
+	case err != nil:
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Table not found: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    fmt.Sprintf("Onboarding of table '%s' started", tableName),
+		"onboarding": record,
+	})
+}
+
+// Latest onboarding run of a table, step by step
+func (s *Server) handleOnboardingStatus(c *gin.Context) {
+	if !s.requireOnboarding(c) {
+		return
+	}
+
+	tableName := c.Param("name")
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	record, err := s.onboarding.Status(ctx, tableName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load onboarding status: %v", err),
+		})
+		return
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Table '%s' has not been onboarded", tableName),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// Plan a repair of the table's mismatched rows. Nothing is changed; the plan
+// reports the mismatches and the token that applies it.
+func (s *Server) handlePlanRepair(c *gin.Context) {
+	if !s.requireRepairs(c) {
+		return
+	}
+
+	var opts repair.Options
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid request body: %v", err),
+			})
+			return
+		}
+	}
+
+	tableName := c.Param("name")
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	record, err := s.repairs.Plan(ctx, tableName, opts, actor(c))
+	switch {
+	case errs.CodeOf(err) != "":
+		c.JSON(errs.HTTPStatus(err), errorBody(err, err.Error()))
+		return
+	case err != nil:
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Table not found: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Repair of table '%s' planned, apply it with its token before %s", tableName, record.Expires.Format(time.RFC3339)),
+		"repair":  record,
+	})
+}
+
+// Apply a planned repair, or resume one that was interrupted
+func (s *Server) handleApplyRepair(c *gin.Context) {
+	if !s.requireRepairs(c) {
+		return
+	}
+
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	tableName := c.Param("name")
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	record, err := s.repairs.Apply(ctx, tableName, req.Token, actor(c))
+	switch {
+	case errs.CodeOf(err) != "":
+		c.JSON(errs.HTTPStatus(err), errorBody(err, err.Error()))
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to apply repair: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": fmt.Sprintf("Repair of table '%s' started", tableName),
+		"repair":  record,
+	})
+}
+
+// A repair plan and the progress of applying it
+func (s *Server) handleRepairStatus(c *gin.Context) {
+	if !s.requireRepairs(c) {
+		return
+	}
+
+	tableName := c.Param("name")
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	record, err := s.repairs.Status(ctx, tableName, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load repair: %v", err),
+		})
+		return
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("No repair of table '%s' with this token", tableName),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// requireRepairs answers 503 when the API server cannot repair tables
+func (s *Server) requireRepairs(c *gin.Context) bool {
+	if s.repairs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Repairs need Redis and are not available",
+		})
+		return false
+	}
+	return true
+}
+
+// List the locks currently held, with their owners and fencing tokens
+func (s *Server) handleListLocks(c *gin.Context) {
+	if s.locks == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Locks need Redis and are not available",
+		})
+		return
+	}
+	q, err := parseListQuery(c, lockSortFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	leases, err := s.locks.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to list locks: %v", err),
+		})
+		return
+	}
+
+	leases, total := paginate(leases, q, func(l lock.Lease) string { return l.Name }, lockSortFields)
+	c.JSON(http.StatusOK, listResponse("locks", leases, total, q))
+}
+
+// requireOnboarding answers 503 when the API server cannot onboard tables
+func (s *Server) requireOnboarding(c *gin.Context) bool {
+	if s.onboarding == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Onboarding needs Redis and is not available",
+		})
+		return false
+	}
+	return true
+}
+
+// Get the audit log, newest first
+func (s *Server) handleGetAudit(c *gin.Context) {
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be a positive integer",
+			})
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	entries, err := s.configStore.LoadAudit(ctx, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load audit log: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// Get query rules
+func (s *Server) handleGetRules(c *gin.Context) {
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	rules, err := s.configStore.LoadQueryRules(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load query rules: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// Replace query rules
+func (s *Server) handleUpdateRules(c *gin.Context) {
+	var queryRules []config.QueryRule
+	if err := c.ShouldBindJSON(&queryRules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid query rules: %v", err),
+		})
+		return
+	}
+
+	if err := config.ValidateQueryRules(queryRules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Query rules validation failed: %v", err),
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	if err := s.configStore.SaveQueryRules(ctx, queryRules); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to save query rules: %v", err),
+		})
+		return
+	}
+
+	s.publishReload(ctx)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("%d query rules saved", len(queryRules)),
+	})
+}
+
+// List the most recent statements the SQL parser could not parse
+func (s *Server) handleParserFailures(c *gin.Context) {
+	if s.proxyAdmin == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Proxy admin endpoint is not configured",
+		})
+		return
+	}
+
+	// Failures are recorded by the parser running in the proxy process
+	var failures []parser.FailureSample
+	if err := s.proxyAdmin.getJSON(c.Request.Context(), "/parser/failures", &failures); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": fmt.Sprintf("Failed to load parser failures: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"failures": failures,
+		"count":    len(failures),
+	})
+}
+
+// List shadow column configs proposed for currency-looking columns added by DDL
+func (s *Server) handleShadowProposals(c *gin.Context) {
+	if s.proxyAdmin == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Proxy admin endpoint is not configured",
+		})
+		return
+	}
+
+	// Proposals are recorded by the proxy when it sees the DDL
+	var proposals []parser.ShadowProposal
+	if err := s.proxyAdmin.getJSON(c.Request.Context(), "/ddl/proposals", &proposals); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": fmt.Sprintf("Failed to load shadow column proposals: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"proposals": proposals,
+		"count":     len(proposals),
+	})
+}
+
+// Trace how the proxy would rewrite a statement, without executing it
+func (s *Server) handleExplain(c *gin.Context) {
+	if s.proxyAdmin == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Proxy admin endpoint is not configured",
+		})
+		return
+	}
+
+	var req struct {
+		Query string `json:"query"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Request body must be {\"query\": \"<SQL>\"}",
+		})
+		return
+	}
+
+	// The proxy explains with its live table toggles
+	var explanation dualwrite.Explanation
+	if err := s.proxyAdmin.getJSON(c.Request.Context(), "/explain?query="+url.QueryEscape(req.Query), &explanation); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": fmt.Sprintf("Failed to explain statement: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, explanation)
+}
+
+// List recent statements whose canary result differed from the primary's
+func (s *Server) handleCanaryMismatches(c *gin.Context) {
+	if s.proxyAdmin == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Proxy admin endpoint is not configured",
+		})
+		return
+	}
+
+	// Mismatches are kept by the proxy that replayed the statements
+	var mismatches []canary.Mismatch
+	if err := s.proxyAdmin.getJSON(c.Request.Context(), "/canary/mismatches", &mismatches); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": fmt.Sprintf("Failed to load canary mismatches: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mismatches": mismatches,
+		"count":      len(mismatches),
+	})
+}
+
+// Get the Grafana dashboard and Prometheus alerting rules. With file set to
+// dashboard or alerts, only that file is returned, ready to import.
+func (s *Server) handleObservabilityBundle(c *gin.Context) {
+	bundle := observability.Get()
+
+	switch file := c.Query("file"); file {
+	case "":
+		c.JSON(http.StatusOK, bundle)
+	case "dashboard":
+		c.Header("Content-Disposition", `attachment; filename="transisidb-dashboard.json"`)
+		c.Data(http.StatusOK, "application/json", bundle.Dashboard)
+	case "alerts":
+		c.Header("Content-Disposition", `attachment; filename="transisidb-alerts.yml"`)
+		c.Data(http.StatusOK, "application/yaml", []byte(bundle.AlertRules))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("file must be dashboard or alerts, got %q", file),
+		})
+	}
+}
+
+// List scheduled jobs with their next run and run history
+func (s *Server) handleListJobs(c *gin.Context) {
+	if !s.requireScheduler(c) {
+		return
+	}
+	q, err := parseListQuery(c, jobSortFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	jobs, err := s.scheduler.Jobs(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load jobs: %v", err),
+		})
+		return
+	}
+
+	jobs, total := paginate(jobs, q, func(j scheduler.JobStatus) string { return j.Name }, jobSortFields)
+	c.JSON(http.StatusOK, listResponse("jobs", jobs, total, q))
+}
+
+// Add or replace a job; jobs from the config file cannot be changed
+func (s *Server) handleSaveJob(c *gin.Context) {
+	if !s.requireScheduler(c) {
+		return
+	}
+
+	var job config.JobConfig
+	if err := c.ShouldBindJSON(&job); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+	job.Name = c.Param("name")
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	if err := s.scheduler.SaveJob(ctx, job); err != nil {
+		// Unclassified errors are invalid job definitions
+		status := http.StatusBadRequest
+		if errs.CodeOf(err) != "" {
+			status = errs.HTTPStatus(err)
+		}
+		c.JSON(status, errorBody(err, fmt.Sprintf("Failed to save job: %v", err)))
+		return
+	}
+	s.auditJob(c, "save_job", job.Name, job.Schedule)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Job '%s' saved", job.Name),
+		"job":     job,
+	})
+}
+
+// Delete a job added through the API
+func (s *Server) handleDeleteJob(c *gin.Context) {
+	if !s.requireScheduler(c) {
+		return
+	}
+
+	name := c.Param("name")
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	if err := s.scheduler.DeleteJob(ctx, name); err != nil {
+		c.JSON(errs.HTTPStatus(err), errorBody(err, fmt.Sprintf("Failed to delete job: %v", err)))
+		return
+	}
+	s.auditJob(c, "delete_job", name, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Job '%s' deleted", name),
+	})
+}
+
+// requireScheduler answers 503 when the API server runs no scheduler
+func (s *Server) requireScheduler(c *gin.Context) bool {
+	if s.scheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Scheduler is not enabled",
+		})
+		return false
+	}
+	return true
+}
+
+// auditJob records a job change in the audit log
+func (s *Server) auditJob(c *gin.Context, action, name, detail string) {
+	if s.configStore == nil {
+		return
+	}
+	entry := config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: action, Target: name, Detail: detail}
+	s.audit(c.Request.Context(), entry)
+}
+
+// List active client sessions with their MySQL user
+func (s *Server) handleListSessions(c *gin.Context) {
+	if s.proxyAdmin == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Proxy admin endpoint is not configured",
+		})
+		return
+	}
+
+	q, err := parseListQuery(c, sessionSortFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var sessions []sessionInfo
+	if err := s.proxyAdmin.getJSON(c.Request.Context(), "/sessions", &sessions); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": fmt.Sprintf("Failed to load sessions: %v", err),
+		})
+		return
+	}
+	if user := c.Query("user"); user != "" {
+		filtered := sessions[:0]
+		for _, session := range sessions {
+			if session.User == user {
+				filtered = append(filtered, session)
+			}
+		}
+		sessions = filtered
+	}
+
+	// The name filter matches the MySQL user
+	sessions, total := paginate(sessions, q, func(session sessionInfo) string { return session.User }, sessionSortFields)
+	c.JSON(http.StatusOK, listResponse("sessions", sessions, total, q))
+}
+
+// Get combined proxy statistics: pools and circuit breakers, replica health,
+// sessions, schema cache, backend probe and conversion counters
+func (s *Server) handleProxyStats(c *gin.Context) {
+	if s.proxyAdmin == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Proxy admin endpoint is not configured",
+		})
+		return
+	}
+
+	// Relayed as served, the proxy owns the document's shape
+	var stats map[string]json.RawMessage
+	if err := s.proxyAdmin.getJSON(c.Request.Context(), "/stats", &stats); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": fmt.Sprintf("Failed to load proxy stats: %v", err),
+		})
+		return
+	}
+	stats["timestamp"] = json.RawMessage(strconv.FormatInt(time.Now().Unix(), 10))
+
+	c.JSON(http.StatusOK, stats)
+}
+
 // Start starts the API server
 func (s *Server) Start() error {
 	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
@@ -420,14 +1741,17 @@ func (s *Server) Start() error {
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
+		BaseContext:  func(net.Listener) context.Context { return s.requests },
 	}
 
 	logger.Info("API server listening", "address", addr)
 	return s.httpServer.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the server
+// Shutdown gracefully shuts down the server. Requests still running when ctx
+// is done are cancelled.
 func (s *Server) Shutdown(ctx context.Context) error {
+	defer s.cancelRequests()
 	if s.httpServer != nil {
 		return s.httpServer.Shutdown(ctx)
 	}
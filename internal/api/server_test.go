@@ -0,0 +1,480 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kafitramarna/TransisiDB/internal/backfill"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
+	"github.com/kafitramarna/TransisiDB/internal/onboarding"
+	"github.com/kafitramarna/TransisiDB/internal/queue"
+	"github.com/kafitramarna/TransisiDB/internal/repair"
+	"github.com/kafitramarna/TransisiDB/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ListSessionsFromProxy(t *testing.T) {
+	proxyTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/sessions", r.URL.Path)
+		w.Write([]byte(`[
+			{"conn_id":1,"user":"app_user","database":"ecommerce_db","queries":3},
+			{"conn_id":2,"user":"report_user","database":"","queries":1}
+		]`))
+	}))
+	defer proxyTS.Close()
+
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	server.SetProxyAdmin(proxyTS.URL, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions?user=app_user", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Sessions []sessionInfo `json:"sessions"`
+		Count    int           `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.Count)
+	assert.Equal(t, "ecommerce_db", body.Sessions[0].Database)
+}
+
+func TestServer_ListSessionsWithoutProxy(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sessions", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestServer_ProxyStats(t *testing.T) {
+	proxyTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/stats", r.URL.Path)
+		w.Write([]byte(`{
+			"pools": [{"role":"primary","backend":"db-1:3306","healthy":true,"stats":{"current_active":2}}],
+			"sessions": {"active": 2, "by_user": {"app_user": 2}},
+			"conversion": {"rewrite_failures": 1}
+		}`))
+	}))
+	defer proxyTS.Close()
+
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	server.SetProxyAdmin(proxyTS.URL, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Pools []struct {
+			Backend string `json:"backend"`
+			Healthy bool   `json:"healthy"`
+		} `json:"pools"`
+		Sessions struct {
+			Active int `json:"active"`
+		} `json:"sessions"`
+		Conversion map[string]float64 `json:"conversion"`
+		Timestamp  int64              `json:"timestamp"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Pools, 1)
+	assert.True(t, body.Pools[0].Healthy)
+	assert.Equal(t, 2, body.Sessions.Active)
+	assert.Equal(t, 1.0, body.Conversion["rewrite_failures"])
+	assert.NotZero(t, body.Timestamp)
+
+	proxyTS.Close()
+	rec = httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+}
+
+func TestServer_BackfillStartQueuesJob(t *testing.T) {
+	jobs := queue.NewMemory(queue.Options{Block: time.Millisecond})
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	server.SetBackfillQueue(jobs)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/backfill/start", strings.NewReader(`{"table":"orders"}`))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	messages, err := jobs.Consume(context.Background(), "test", 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	var job backfill.Job
+	require.NoError(t, json.Unmarshal(messages[0].Payload, &job))
+	assert.Equal(t, "orders", job.Table)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/backfill/start", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec = httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServer_BackfillQueue(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/backfill/queue", "").Code)
+
+	server.SetBackfillQueue(queue.NewMemory(queue.Options{}))
+	server.SetLocker(lock.NewMemory())
+	for _, body := range []string{`{"table":"orders"}`, `{"table":"payments","priority":5}`, `{"table":"invoices"}`} {
+		require.Equal(t, http.StatusAccepted, do(http.MethodPost, "/api/v1/backfill/start", body).Code)
+	}
+
+	rec := do(http.MethodGet, "/api/v1/backfill/queue", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Jobs  []backfill.QueuedJob `json:"jobs"`
+		Count int                  `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, 3, body.Count)
+	for i, table := range []string{"payments", "orders", "invoices"} {
+		assert.Equal(t, table, body.Jobs[i].Table)
+		assert.Equal(t, backfill.JobWaiting, body.Jobs[i].State)
+		assert.Equal(t, i+1, body.Jobs[i].Position)
+	}
+	assert.Equal(t, 5, body.Jobs[0].Priority)
+}
+
+func TestServer_Jobs(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/jobs", "").Code)
+
+	sched := scheduler.New(scheduler.NewMemoryStore(), config.SchedulerConfig{Jobs: []config.JobConfig{
+		{Name: "nightly-reconcile", Type: config.JobTypeReconcile, Schedule: "@daily 02:00"},
+	}})
+	sched.Register(config.JobTypeReconcile, scheduler.Reconcile(nil, &config.Config{}, nil))
+	sched.Register(config.JobTypeCertExpiry, scheduler.CertExpiry)
+	require.NoError(t, sched.Load(context.Background()))
+	server.SetScheduler(sched)
+
+	rec := do(http.MethodPut, "/api/v1/jobs/certs", `{"type":"cert_expiry","schedule":"@hourly","certificates":["/etc/transisidb/ca.pem"]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodPut, "/api/v1/jobs/nightly-reconcile", `{"type":"reconcile","schedule":"@hourly"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"config.conflict"`)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/v1/jobs/certs", `{"type":"cert_expiry","schedule":"0 * * * *"}`).Code)
+
+	rec = do(http.MethodGet, "/api/v1/jobs", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Jobs  []scheduler.JobStatus `json:"jobs"`
+		Count int                   `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, 2, body.Count)
+	assert.Equal(t, "certs", body.Jobs[0].Name)
+	assert.Equal(t, scheduler.SourceAPI, body.Jobs[0].Source)
+	assert.Equal(t, scheduler.SourceConfig, body.Jobs[1].Source)
+	assert.False(t, body.Jobs[1].NextRun.IsZero())
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/jobs/certs", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/v1/jobs/certs", "").Code)
+}
+
+func TestServer_ListTablesFromConfigFile(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	server.SetFileTables(config.TablesConfig{
+		"orders":   {Enabled: true},
+		"invoices": {},
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/v1/tables")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Tables []config.TableSource `json:"tables"`
+		Count  int                  `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 2, body.Count)
+	assert.Equal(t, []config.TableSource{
+		{Name: "invoices", Source: config.TableSourceYAML},
+		{Name: "orders", Source: config.TableSourceYAML},
+	}, body.Tables)
+
+	rec = get("/api/v1/tables?limit=1&sort=-name")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.Count)
+	assert.Equal(t, "orders", body.Tables[0].Name)
+	assert.Contains(t, rec.Body.String(), `"total":2`)
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/tables?sort=size").Code)
+
+	rec = get("/api/v1/tables/orders")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tableConfig config.TableConfig
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tableConfig))
+	assert.True(t, tableConfig.Enabled)
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/tables/payments").Code)
+}
+
+func TestServer_AuditActorIncludesKeyID(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	var got string
+	server.router.GET("/api/v1/whoami", server.authMiddleware(), func(c *gin.Context) {
+		got = actor(c)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/whoami", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.RemoteAddr = "10.0.0.5:4242"
+	server.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "api:10.0.0.5/key:"+keyID("test-key"), got)
+	assert.Len(t, keyID("test-key"), 8)
+	assert.NotEqual(t, keyID("test-key"), keyID("other-key"))
+}
+
+func TestServer_DeleteTableRequiresStore(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodDelete, "/api/v1/tables/orders", nil),
+		httptest.NewRequest(http.MethodPost, "/api/v1/tables/orders/restore", nil),
+	} {
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code, req.URL.Path)
+	}
+}
+
+func TestServer_ConfigExportRequiresStore(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+
+	for _, path := range []string{"/api/v1/config/export", "/api/v1/config/import?dry_run=true"} {
+		method := http.MethodGet
+		if strings.Contains(path, "import") {
+			method = http.MethodPost
+		}
+		req := httptest.NewRequest(method, path, strings.NewReader("version: 1\n"))
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code, path)
+	}
+}
+
+// onboardingTables is a table store with a single configured table
+type onboardingTables struct{}
+
+func (onboardingTables) LoadTableConfig(ctx context.Context, tableName string) (*config.TableConfig, error) {
+	if tableName != "orders" {
+		return nil, errors.New("table not found")
+	}
+	return &config.TableConfig{Columns: map[string]config.ColumnConfig{"total_amount": {TargetColumn: "total_amount_idn"}}}, nil
+}
+func (onboardingTables) SetTableEnabled(ctx context.Context, tableName string, enabled bool) error {
+	return nil
+}
+func (onboardingTables) SetTableRollout(ctx context.Context, tableName string, percent float64) error {
+	return nil
+}
+func (onboardingTables) SetTableMode(ctx context.Context, tableName, mode string) error { return nil }
+func (onboardingTables) PublishReload(ctx context.Context) error                        { return nil }
+func (onboardingTables) AppendAudit(ctx context.Context, entry config.AuditEntry) error { return nil }
+
+func TestServer_OnboardTable(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodPost, "/api/v1/tables/orders/onboard", "").Code)
+
+	// Without a database onboarding stops at schema validation
+	pipeline := onboarding.New(nil, &config.Config{}, onboardingTables{}, onboarding.NewMemoryRecords())
+	server.SetOnboarding(pipeline)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/tables/invoices/onboard", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/tables/orders/onboard", `{"observe_percent":150}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/tables/orders/onboard", "").Code)
+
+	rec := do(http.MethodPost, "/api/v1/tables/orders/onboard", `{"apply_ddl":true}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	pipeline.Wait()
+
+	rec = do(http.MethodGet, "/api/v1/tables/orders/onboard", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var record onboarding.Record
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &record))
+	assert.Equal(t, onboarding.StatusFailed, record.Status)
+	assert.True(t, record.Options.ApplyDDL)
+	assert.Equal(t, onboarding.StepValidate, record.Steps[0].Name)
+	assert.Equal(t, "database is not available", record.Steps[0].Error)
+	assert.Equal(t, onboarding.StatusSkipped, record.Steps[5].Status)
+}
+
+func TestServer_Repair(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodPost, "/api/v1/tables/orders/repair", "").Code)
+
+	// Without a database repairs are validated but cannot run
+	records := repair.NewMemoryRecords()
+	server.SetRepairer(repair.New(nil, &config.Config{}, onboardingTables{}, records))
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/tables/invoices/repair", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/tables/orders/repair", `{"authority":"usd"}`).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodPost, "/api/v1/tables/orders/repair", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/tables/orders/repair/apply", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/tables/orders/repair/apply", `{"token":"unknown"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/tables/orders/repair/unknown", "").Code)
+
+	ctx := context.Background()
+	require.NoError(t, records.Save(ctx, &repair.Record{Token: "abc", Table: "orders", Status: repair.StatusSucceeded}))
+	rec := do(http.MethodPost, "/api/v1/tables/orders/repair/apply", `{"token":"abc"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "already been applied")
+
+	rec = do(http.MethodGet, "/api/v1/tables/orders/repair/abc", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var record repair.Record
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &record))
+	assert.Equal(t, repair.StatusSucceeded, record.Status)
+}
+
+func TestServer_ListLocks(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/locks", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, get().Code)
+
+	locks := lock.NewMemory()
+	_, err := locks.Acquire(context.Background(), lock.Table("orders"), "backfill-1", time.Minute)
+	require.NoError(t, err)
+	server.SetLocker(locks)
+
+	rec := get()
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Locks []lock.Lease `json:"locks"`
+		Count int          `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, 1, body.Count)
+	assert.Equal(t, "table:orders", body.Locks[0].Name)
+	assert.Equal(t, "backfill-1", body.Locks[0].Owner)
+	assert.NotZero(t, body.Locks[0].Token)
+}
+
+func TestServer_ObservabilityBundle(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/v1/observability/bundle")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Dashboard  map[string]interface{} `json:"dashboard"`
+		AlertRules string                 `json:"alert_rules"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "TransisiDB Overview", body.Dashboard["title"])
+	assert.Contains(t, body.AlertRules, "alert: CircuitBreakerOpen")
+
+	rec = get("/api/v1/observability/bundle?file=alerts")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, body.AlertRules, rec.Body.String())
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "transisidb-alerts.yml")
+
+	rec = get("/api/v1/observability/bundle?file=dashboard")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"uid": "transisidb-overview"`)
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/observability/bundle?file=grafana").Code)
+}
+
+func TestServer_ExplainFromProxy(t *testing.T) {
+	proxyTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/explain", r.URL.Path)
+		require.Equal(t, "UPDATE orders SET total_amount = 5000", r.URL.Query().Get("query"))
+		w.Write([]byte(`{"query":"UPDATE orders SET total_amount = 5000","statement":"UPDATE","table":"orders",
+			"outcome":"rewritten","rewritten":"UPDATE orders SET total_amount = 5000, total_amount_idn = 5.0000"}`))
+	}))
+	defer proxyTS.Close()
+
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	server.SetProxyAdmin(proxyTS.URL, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/explain", strings.NewReader(`{"query":"UPDATE orders SET total_amount = 5000"}`))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Outcome   string `json:"outcome"`
+		Rewritten string `json:"rewritten"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "rewritten", body.Outcome)
+	assert.Contains(t, body.Rewritten, "total_amount_idn")
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/explain", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec = httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+)
+
+// Get the staged config version and the number of the active one
+func (s *Server) handleGetStagedConfig(c *gin.Context) {
+	if !s.requireConfigStore(c) {
+		return
+	}
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	staged, err := s.configStore.LoadStagedConfig(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load staged config: %v", err),
+		})
+		return
+	}
+	active, err := s.configStore.LoadActiveVersion(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load active config version: %v", err),
+		})
+		return
+	}
+
+	activeVersion := int64(0)
+	if active != nil {
+		activeVersion = active.Version
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"active_version": activeVersion,
+		"staged":         staged,
+	})
+}
+
+// Stage a config version for the new sessions from the canary addresses and
+// a percentage of the others. Sections left out of the request are taken from
+// the stored config.
+func (s *Server) handleStageConfig(c *gin.Context) {
+	if !s.requireConfigStore(c) {
+		return
+	}
+
+	var req struct {
+		Tables     *config.TablesConfig     `json:"tables"`
+		Conversion *config.ConversionConfig `json:"conversion"`
+		QueryRules []config.QueryRule       `json:"query_rules"`
+		CanaryIPs  []string                 `json:"canary_ips"`
+		Percent    float64                  `json:"percent"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid staged config: %v", err),
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	base, err := s.configStore.LoadConfig(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load the stored config to stage against: %v", err),
+		})
+		return
+	}
+	staged := &config.StagedConfig{
+		ConfigVersion: config.ConfigVersion{
+			Tables:     base.Tables,
+			Conversion: base.Conversion,
+			QueryRules: req.QueryRules,
+			CreatedBy:  actor(c),
+			Created:    time.Now().UTC(),
+		},
+		CanaryIPs: req.CanaryIPs,
+		Percent:   req.Percent,
+	}
+	if req.Tables != nil {
+		staged.Tables = *req.Tables
+	}
+	if req.Conversion != nil {
+		staged.Conversion = *req.Conversion
+	}
+	if err := staged.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Staged config validation failed: %v", err),
+		})
+		return
+	}
+	if err := staged.Apply(base).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Staged config validation failed: %v", err),
+		})
+		return
+	}
+
+	if err := s.configStore.StageConfig(ctx, staged); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to stage config: %v", err),
+		})
+		return
+	}
+
+	detail := fmt.Sprintf("version %d, %g%% of new sessions", staged.Version, staged.Percent)
+	if len(staged.CanaryIPs) > 0 {
+		detail += ", canary " + strings.Join(staged.CanaryIPs, " ")
+	}
+	s.audit(ctx, config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: "stage_config", Target: "config", Detail: detail})
+	s.publishReload(ctx)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Config version %d staged", staged.Version),
+		"staged":  staged,
+	})
+}
+
+// Promote the staged config version. With {"version": n} the request fails
+// when another version has been staged since.
+func (s *Server) handlePromoteStagedConfig(c *gin.Context) {
+	if !s.requireConfigStore(c) {
+		return
+	}
+
+	var req struct {
+		Version int64 `json:"version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Request body must be empty or {\"version\": <n>}",
+		})
+		return
+	}
+
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	promoted, err := s.configStore.PromoteStagedConfig(ctx, req.Version)
+	if err != nil {
+		c.JSON(errs.HTTPStatus(err), errorBody(err, fmt.Sprintf("Failed to promote staged config: %v", err)))
+		return
+	}
+
+	s.audit(ctx, config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: "promote_config", Target: "config",
+		Detail: fmt.Sprintf("version %d", promoted.Version)})
+	s.publishReload(ctx)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        fmt.Sprintf("Config version %d promoted", promoted.Version),
+		"active_version": promoted.Version,
+	})
+}
+
+// Abort the staged config version. Sessions already on it keep it until they
+// disconnect.
+func (s *Server) handleAbortStagedConfig(c *gin.Context) {
+	if !s.requireConfigStore(c) {
+		return
+	}
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	aborted, err := s.configStore.AbortStagedConfig(ctx)
+	if err != nil {
+		c.JSON(errs.HTTPStatus(err), errorBody(err, fmt.Sprintf("Failed to abort staged config: %v", err)))
+		return
+	}
+
+	s.audit(ctx, config.AuditEntry{Time: time.Now(), Actor: actor(c), Action: "abort_config", Target: "config",
+		Detail: fmt.Sprintf("version %d", aborted.Version)})
+	s.publishReload(ctx)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Staged config version %d aborted", aborted.Version),
+	})
+}
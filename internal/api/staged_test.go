@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_StagedConfigNeedsStore(t *testing.T) {
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/config/staged"},
+		{http.MethodPut, "/api/v1/config/staged"},
+		{http.MethodPost, "/api/v1/config/staged/promote"},
+		{http.MethodDelete, "/api/v1/config/staged"},
+	} {
+		req := httptest.NewRequest(route.method, route.path, strings.NewReader(`{"percent": 10}`))
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "%s %s", route.method, route.path)
+	}
+}
+
+func TestServer_StagedConfig(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+	store, err := config.NewRedisStore(&config.RedisConfig{Host: "localhost", Port: 6379, Database: 15, PoolSize: 2})
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer store.Close()
+
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, store, nil)
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+
+	base := &config.Config{
+		Database:   config.DatabaseConfig{Host: "localhost", Port: 3306},
+		Proxy:      config.ProxyConfig{Port: 3308},
+		Redis:      config.RedisConfig{Host: "localhost", Port: 6379},
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, RoundingStrategy: "BANKERS_ROUND"},
+		Tables:     config.TablesConfig{},
+	}
+	require.NoError(t, base.Validate())
+	require.NoError(t, store.SaveConfig(t.Context(), base))
+	do(http.MethodDelete, "/api/v1/config/staged", "")
+
+	code, _ := do(http.MethodPut, "/api/v1/config/staged", `{"percent": 0}`)
+	assert.Equal(t, http.StatusBadRequest, code, "a staged version must target some sessions")
+	code, _ = do(http.MethodPut, "/api/v1/config/staged", `{"canary_ips": ["not-an-ip"]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, resp := do(http.MethodPut, "/api/v1/config/staged", `{"canary_ips": ["10.0.0.5"], "percent": 5}`)
+	require.Equal(t, http.StatusOK, code, resp)
+	staged := resp["staged"].(map[string]interface{})
+	version := staged["version"].(float64)
+
+	code, resp = do(http.MethodGet, "/api/v1/config/staged", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, version, resp["staged"].(map[string]interface{})["version"])
+
+	code, _ = do(http.MethodPost, "/api/v1/config/staged/promote", `{"version": 999999999}`)
+	assert.Equal(t, http.StatusConflict, code)
+	code, resp = do(http.MethodPost, "/api/v1/config/staged/promote", "")
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, version, resp["active_version"])
+
+	code, _ = do(http.MethodDelete, "/api/v1/config/staged", "")
+	assert.Equal(t, http.StatusNotFound, code, "nothing is staged after promoting")
+}
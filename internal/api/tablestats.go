@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kafitramarna/TransisiDB/internal/backfill"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/reconcile"
+)
+
+// dualWriteRate mirrors the dual-write rates served by the proxy admin
+// endpoint
+type dualWriteRate struct {
+	Table     string  `json:"table"`
+	LastHour  int64   `json:"last_hour"`
+	PerMinute float64 `json:"per_minute"`
+}
+
+// tableStats is a table's conversion coverage and activity. Parts whose
+// source is not configured or failed are nil and explained in Unavailable.
+type tableStats struct {
+	Table   string `json:"table"`
+	Enabled bool   `json:"enabled"`
+	*backfill.Coverage
+	// CoveragePercent is the share of rows converted
+	CoveragePercent  *float64           `json:"coverage_percent,omitempty"`
+	DualWrites       *dualWriteRate     `json:"dual_writes"`
+	LastBackfill     *backfill.Run      `json:"last_backfill"`
+	LastVerification []reconcile.Result `json:"last_verification"`
+	Unavailable      map[string]string  `json:"unavailable,omitempty"`
+	Timestamp        int64              `json:"timestamp"`
+}
+
+// Live counts of a table's rows and shadow values, its dual-write rate over
+// the last hour, its last backfill run and its latest verification results
+func (s *Server) handleTableStats(c *gin.Context) {
+	tableName := c.Param("name")
+	ctx, cancel := requestContext(c)
+	defer cancel()
+
+	tableConfig, err := s.lookupTable(ctx, tableName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Table not found: %v", err),
+		})
+		return
+	}
+
+	stats := tableStats{
+		Table:            tableName,
+		Enabled:          tableConfig.Enabled,
+		LastVerification: []reconcile.Result{},
+		Unavailable:      make(map[string]string),
+		Timestamp:        time.Now().Unix(),
+	}
+	s.tableCoverage(ctx, &stats, tableConfig)
+	s.tableDualWrites(ctx, &stats)
+	s.tableBackfill(ctx, &stats)
+	s.tableVerification(ctx, &stats)
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// tableCoverage counts the rows and shadow values of the table
+func (s *Server) tableCoverage(ctx context.Context, stats *tableStats, tableConfig config.TableConfig) {
+	if s.db == nil {
+		stats.Unavailable["rows"] = "the database is not connected"
+		return
+	}
+	coverage, err := backfill.CountCoverage(ctx, s.db, stats.Table, tableConfig)
+	if err != nil {
+		stats.Unavailable["rows"] = fmt.Sprintf("failed to count rows: %v", err)
+		return
+	}
+	stats.Coverage = &coverage
+	percent := 100.0
+	if coverage.Rows > 0 {
+		percent = math.Round(float64(coverage.Converted)/float64(coverage.Rows)*10000) / 100
+	}
+	stats.CoveragePercent = &percent
+}
+
+// tableDualWrites loads the table's dual-write rate from the proxy
+func (s *Server) tableDualWrites(ctx context.Context, stats *tableStats) {
+	if s.proxyAdmin == nil {
+		stats.Unavailable["dual_writes"] = "the proxy admin endpoint is not configured"
+		return
+	}
+	var rates []dualWriteRate
+	if err := s.proxyAdmin.getJSON(ctx, "/dual-writes", &rates); err != nil {
+		stats.Unavailable["dual_writes"] = fmt.Sprintf("failed to load dual-write rates: %v", err)
+		return
+	}
+	stats.DualWrites = &dualWriteRate{Table: stats.Table}
+	for _, rate := range rates {
+		if rate.Table == stats.Table {
+			stats.DualWrites = &rate
+			break
+		}
+	}
+}
+
+// tableBackfill loads the table's last backfill run
+func (s *Server) tableBackfill(ctx context.Context, stats *tableStats) {
+	if s.backfillRuns == nil {
+		stats.Unavailable["last_backfill"] = "backfill runs need Redis"
+		return
+	}
+	run, err := s.backfillRuns.Last(ctx, stats.Table)
+	if err != nil {
+		stats.Unavailable["last_backfill"] = err.Error()
+		return
+	}
+	stats.LastBackfill = run
+}
+
+// tableVerification loads the latest reconcile results of the table's columns
+func (s *Server) tableVerification(ctx context.Context, stats *tableStats) {
+	if s.reconcileResults == nil {
+		stats.Unavailable["last_verification"] = "reconciliation results need Redis"
+		return
+	}
+	results, err := s.reconcileResults.Latest(ctx)
+	if err != nil {
+		stats.Unavailable["last_verification"] = err.Error()
+		return
+	}
+	for _, result := range results {
+		if result.Table == stats.Table {
+			stats.LastVerification = append(stats.LastVerification, result)
+		}
+	}
+}
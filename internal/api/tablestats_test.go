@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/backfill"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/reconcile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_TableStats(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/dual-writes", r.URL.Path)
+		w.Write([]byte(`[{"table":"invoices","last_hour":3,"per_minute":0.05},{"table":"orders","last_hour":120,"per_minute":2}]`))
+	}))
+	defer proxy.Close()
+
+	server := NewServer(&config.APIConfig{APIKey: "test-key"}, nil, nil)
+	server.SetFileTables(config.TablesConfig{"orders": {Enabled: true}, "payments": {Enabled: false}})
+	get := func(table string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tables/"+table+"/stats", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusNotFound, get("customers").Code)
+
+	// Without any source every part is unavailable
+	rec := get("orders")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats tableStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.True(t, stats.Enabled)
+	assert.Nil(t, stats.Coverage)
+	assert.Nil(t, stats.DualWrites)
+	assert.Nil(t, stats.LastBackfill)
+	assert.Empty(t, stats.LastVerification)
+	assert.Len(t, stats.Unavailable, 4)
+
+	ctx := context.Background()
+	finished := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+	runs := backfill.NewMemoryRuns()
+	require.NoError(t, runs.Save(ctx, backfill.Run{Table: "orders", JobID: "1-0", Attempt: 1,
+		Started: finished.Add(-time.Hour), Finished: finished, Outcome: backfill.RunCompleted}))
+	results := reconcile.NewMemoryResults()
+	require.NoError(t, results.Save(ctx, reconcile.Result{Checked: finished, Job: "nightly", Table: "orders", Column: "total_amount",
+		Counts: map[string]int64{reconcile.KindConversion: 0}}))
+	require.NoError(t, results.Save(ctx, reconcile.Result{Checked: finished, Job: "nightly", Table: "invoices", Column: "grand_total",
+		Counts: map[string]int64{reconcile.KindConversion: 1}}))
+	server.SetProxyAdmin(proxy.URL, "")
+	server.SetBackfillRuns(runs)
+	server.SetReconcileResults(results)
+
+	rec = get("orders")
+	require.Equal(t, http.StatusOK, rec.Code)
+	stats = tableStats{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	require.NotNil(t, stats.DualWrites)
+	assert.Equal(t, int64(120), stats.DualWrites.LastHour)
+	assert.Equal(t, 2.0, stats.DualWrites.PerMinute)
+	require.NotNil(t, stats.LastBackfill)
+	assert.Equal(t, backfill.RunCompleted, stats.LastBackfill.Outcome)
+	require.Len(t, stats.LastVerification, 1)
+	assert.Equal(t, "total_amount", stats.LastVerification[0].Column)
+	assert.Equal(t, map[string]string{"rows": "the database is not connected"}, stats.Unavailable)
+
+	// Tables the proxy did not dual-write in the hour have a zero rate
+	rec = get("payments")
+	require.Equal(t, http.StatusOK, rec.Code)
+	stats = tableStats{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.False(t, stats.Enabled)
+	require.NotNil(t, stats.DualWrites)
+	assert.Zero(t, stats.DualWrites.LastHour)
+	assert.Nil(t, stats.LastBackfill)
+}
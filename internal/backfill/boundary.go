@@ -0,0 +1,92 @@
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+)
+
+// queryer runs the reads of a backfill, on the pool or in a snapshot
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// boundary is where a backfill job ends. Rows written after it are left to
+// dual-write. A nil boundary reads the live table until no rows are left.
+type boundary struct {
+	// snapshot reads the batches from a REPEATABLE READ transaction, whose
+	// snapshot is taken by the first read
+	snapshot *sql.Tx
+	// cutoff only selects rows whose column is before it
+	cutoff time.Time
+	column string
+}
+
+// openBoundary sets the boundary of a job starting now, as configured by
+// backfill.consistency. The caller closes it when the job ends.
+func (w *Worker) openBoundary(ctx context.Context) (*boundary, error) {
+	switch w.config.Consistency {
+	case config.BackfillConsistencySnapshot:
+		tx, err := w.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err != nil {
+			return nil, errs.Wrap(errs.BackendQuery, err, "failed to start snapshot")
+		}
+		return &boundary{snapshot: tx}, nil
+
+	case config.BackfillConsistencyCutoff:
+		column := w.config.CutoffColumn
+		if column == "" {
+			column = config.DefaultBackfillCutoffColumn
+		}
+		// The server's clock, which also sets the column, marks the start
+		var cutoff time.Time
+		if err := w.db.QueryRowContext(ctx, `SELECT NOW()`).Scan(&cutoff); err != nil {
+			return nil, errs.Wrap(errs.BackendQuery, err, "failed to read job start")
+		}
+		return &boundary{cutoff: cutoff, column: column}, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// reader returns where the job reads its batches
+func (b *boundary) reader(db *sql.DB) queryer {
+	if b != nil && b.snapshot != nil {
+		return b.snapshot
+	}
+	return db
+}
+
+// predicate returns the condition and arguments a row must meet to be
+// inside the boundary, empty for none
+func (b *boundary) predicate() (string, []interface{}) {
+	if b == nil || b.cutoff.IsZero() {
+		return "", nil
+	}
+	return fmt.Sprintf(" AND %s < ?", b.column), []interface{}{b.cutoff}
+}
+
+// String describes the boundary for logs
+func (b *boundary) String() string {
+	switch {
+	case b == nil:
+		return config.BackfillConsistencyNone
+	case b.snapshot != nil:
+		return config.BackfillConsistencySnapshot
+	default:
+		return fmt.Sprintf("%s %s < %s", config.BackfillConsistencyCutoff, b.column, b.cutoff.Format(time.DateTime))
+	}
+}
+
+// close ends the snapshot
+func (b *boundary) close() {
+	if b != nil && b.snapshot != nil {
+		b.snapshot.Rollback()
+	}
+}
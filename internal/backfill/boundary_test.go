@@ -0,0 +1,43 @@
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundary_None(t *testing.T) {
+	for _, consistency := range []string{"", config.BackfillConsistencyNone} {
+		w := NewWorker(nil, &config.Config{Backfill: config.BackfillConfig{Consistency: consistency}})
+		b, err := w.openBoundary(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, b)
+
+		predicate, args := b.predicate()
+		assert.Empty(t, predicate)
+		assert.Empty(t, args)
+		assert.Equal(t, "none", b.String())
+
+		db := &sql.DB{}
+		assert.Same(t, db, b.reader(db))
+		b.close()
+	}
+}
+
+func TestBoundary_Cutoff(t *testing.T) {
+	cutoff := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	b := &boundary{cutoff: cutoff, column: "modified_at"}
+
+	predicate, args := b.predicate()
+	assert.Equal(t, " AND modified_at < ?", predicate)
+	assert.Equal(t, []interface{}{cutoff}, args)
+	assert.Equal(t, "cutoff modified_at < 2026-03-01 09:30:00", b.String())
+
+	db := &sql.DB{}
+	assert.Same(t, db, b.reader(db))
+}
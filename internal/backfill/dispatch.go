@@ -0,0 +1,467 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+// Backfill job queue
+const (
+	JobStream = "transisidb:backfill:jobs"
+	JobGroup  = "transisidb-backfill"
+	// jobPollInterval is how often an idle runner looks for a job it can start
+	jobPollInterval = time.Second
+	// jobRetryDelay is how long a failed job waits before it runs again
+	jobRetryDelay = time.Minute
+	// maxJobAttempts is how often a failing job runs before it is dropped
+	maxJobAttempts = 3
+	// dispatchLock lets one runner at a time pick a job, so the concurrency
+	// caps hold across workers
+	dispatchLock = "backfill:dispatch"
+	// runningLockPrefix names the lock held on a running job, followed by
+	// the job's backend and ID
+	runningLockPrefix = "backfill:job:"
+)
+
+// Job states in the queue
+const (
+	JobWaiting = "waiting"
+	// JobScheduled is a failed job waiting for its retry
+	JobScheduled = "scheduled"
+	JobRunning   = "running"
+)
+
+// Job asks a backfill worker to migrate a table. Jobs run by priority,
+// highest first, then smallest table first, then oldest first.
+type Job struct {
+	Table       string    `json:"table"`
+	RequestedBy string    `json:"requested_by,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+	Priority    int       `json:"priority,omitempty"`
+	// Rows estimates the table's rows when the job was queued, 0 when unknown
+	Rows int64 `json:"rows,omitempty"`
+	// Attempts counts the failed runs of the job
+	Attempts int `json:"attempts,omitempty"`
+	// NotBefore holds a failed job back until its retry
+	NotBefore time.Time `json:"not_before,omitzero"`
+}
+
+// before reports whether job j runs before job o. Tables of unknown size go
+// after the others of the same priority.
+func (j Job) before(o Job) bool {
+	if j.Priority != o.Priority {
+		return j.Priority > o.Priority
+	}
+	if (j.Rows == 0) != (o.Rows == 0) {
+		return j.Rows != 0
+	}
+	if j.Rows != o.Rows {
+		return j.Rows < o.Rows
+	}
+	return j.RequestedAt.Before(o.RequestedAt)
+}
+
+// QueuedJob is a job in the queue with its state
+type QueuedJob struct {
+	ID string `json:"id"`
+	Job
+	State string `json:"state"`
+	// Position is the job's place in line from 1, 0 while it runs
+	Position int `json:"position,omitempty"`
+	// Backend and Owner say where a running job runs
+	Backend string `json:"backend,omitempty"`
+	Owner   string `json:"owner,omitempty"`
+}
+
+// NewJobQueue returns the Redis queue backfill jobs are dispatched through
+func NewJobQueue(client redis.UniversalClient) queue.Queue {
+	return queue.NewRedis(client, queue.Options{Stream: JobStream, Group: JobGroup})
+}
+
+// Enqueue dispatches a job to the backfill workers and returns its ID
+func Enqueue(ctx context.Context, q queue.Queue, job Job) (string, error) {
+	if job.RequestedAt.IsZero() {
+		job.RequestedAt = time.Now()
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal backfill job: %w", err)
+	}
+	id, err := q.Publish(ctx, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue backfill job: %w", err)
+	}
+	return id, nil
+}
+
+// ListJobs returns the queued jobs: running jobs first, then the others in
+// the order they will run. Jobs are running while a runner holds their lock
+// in locks.
+func ListJobs(ctx context.Context, q queue.Queue, locks lock.Locker) ([]QueuedJob, error) {
+	jobs, _, err := listJobs(ctx, q, locks, time.Now())
+	return jobs, err
+}
+
+// listJobs lists the queued jobs as of now and the IDs of malformed ones
+func listJobs(ctx context.Context, q queue.Queue, locks lock.Locker, now time.Time) ([]QueuedJob, []string, error) {
+	messages, err := q.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	leases, err := locks.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	running := make(map[string]QueuedJob)
+	for _, lease := range leases {
+		if backend, id, ok := parseRunningLock(lease.Name); ok {
+			running[id] = QueuedJob{Backend: backend, Owner: lease.Owner}
+		}
+	}
+
+	jobs := make([]QueuedJob, 0, len(messages))
+	var malformed []string
+	for _, msg := range messages {
+		job := running[msg.ID]
+		if err := json.Unmarshal(msg.Payload, &job.Job); err != nil {
+			malformed = append(malformed, msg.ID)
+			continue
+		}
+		job.ID = msg.ID
+		switch {
+		case job.Owner != "":
+			job.State = JobRunning
+		case job.NotBefore.After(now):
+			job.State = JobScheduled
+		default:
+			job.State = JobWaiting
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if ri, rj := jobs[i].State == JobRunning, jobs[j].State == JobRunning; ri != rj {
+			return ri
+		}
+		return jobs[i].before(jobs[j].Job)
+	})
+	position := 0
+	for i := range jobs {
+		if jobs[i].State != JobRunning {
+			position++
+			jobs[i].Position = position
+		}
+	}
+	return jobs, malformed, nil
+}
+
+// runningLock names the lock held on a running job
+func runningLock(backend, id string) string {
+	return runningLockPrefix + backend + ":" + id
+}
+
+// parseRunningLock returns the backend and job ID of a running job's lock.
+// Job IDs have no colon; backends may.
+func parseRunningLock(name string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(name, runningLockPrefix)
+	if !ok {
+		return "", "", false
+	}
+	i := strings.LastIndex(rest, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
+}
+
+// TableMigrator migrates one table while lease holds its lock; *Worker
+// implements it
+type TableMigrator interface {
+	Start(ctx context.Context, tableName string, tableConfig config.TableConfig, lease *lock.Lease) error
+}
+
+// Runner runs queued backfill jobs, one per migrator at a time. A job is
+// claimed by holding its lock while it runs; a job of a runner that exits or
+// crashes is released when its lock expires and is resumed by another
+// runner, which only migrates the rows still missing shadow values.
+type Runner struct {
+	queue    queue.Queue
+	tables   config.TablesConfig
+	consumer string
+	backend  string
+	// maxJobs and maxJobsPerBackend cap the jobs running across all
+	// runners, 0 for no cap
+	maxJobs           int
+	maxJobsPerBackend int
+	// locks claims jobs and keeps two hosts from migrating a table at once
+	locks lock.Locker
+	// runs records the last run of each table, nil to not record them
+	runs Runs
+
+	mu sync.Mutex
+	// idle holds the migrators not running a job
+	idle []TableMigrator
+	// finished wakes Run when a job ends
+	finished chan struct{}
+	wg       sync.WaitGroup
+
+	poll       time.Duration
+	retryDelay time.Duration
+}
+
+// NewRunner returns a runner migrating the configured tables. Without
+// SetLocker, jobs are only claimed within this process.
+func NewRunner(q queue.Queue, migrator TableMigrator, cfg *config.Config) *Runner {
+	return &Runner{
+		queue:             q,
+		tables:            cfg.Tables,
+		consumer:          lock.Owner(),
+		backend:           cfg.Database.Backend(),
+		maxJobs:           cfg.Backfill.MaxJobs,
+		maxJobsPerBackend: cfg.Backfill.MaxJobsPerBackend,
+		locks:             lock.NewMemory(),
+		idle:              []TableMigrator{migrator},
+		finished:          make(chan struct{}, 1),
+		poll:              jobPollInterval,
+		retryDelay:        jobRetryDelay,
+	}
+}
+
+// AddMigrator lets the runner run one more job at a time. Call it before Run.
+func (r *Runner) AddMigrator(migrator TableMigrator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.idle = append(r.idle, migrator)
+}
+
+// SetLocker shares job claims, concurrency caps and table locks with the
+// runners and onboardings of other hosts. A job for a table locked elsewhere,
+// by another backfill or an onboarding, is dropped: the running operation
+// already migrates that table.
+func (r *Runner) SetLocker(locker lock.Locker) {
+	r.locks = locker
+}
+
+// SetRuns records the last run of each table in runs, for the table
+// statistics of the API
+func (r *Runner) SetRuns(runs Runs) {
+	r.runs = runs
+}
+
+// Run starts jobs until ctx is cancelled, then waits for the running jobs to
+// stop
+func (r *Runner) Run(ctx context.Context) {
+	defer r.wg.Wait()
+	for ctx.Err() == nil {
+		started, err := r.dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Warn("Failed to dispatch backfill jobs, retrying", "error", err)
+		}
+		if started {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-r.finished:
+		case <-time.After(r.poll):
+		}
+	}
+}
+
+// dispatch starts the first waiting job in line when a migrator is idle and
+// the concurrency caps allow it, and reports whether it started one
+func (r *Runner) dispatch(ctx context.Context) (bool, error) {
+	migrator := r.takeMigrator()
+	if migrator == nil {
+		return false, nil
+	}
+	started := false
+	defer func() {
+		if !started {
+			r.putMigrator(migrator)
+		}
+	}()
+
+	runCtx := ctx
+	err := lock.Hold(ctx, r.locks, dispatchLock, r.consumer, lock.DefaultTTL, func(ctx context.Context, _ lock.Lease) error {
+		jobs, malformed, err := listJobs(ctx, r.queue, r.locks, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, id := range malformed {
+			logger.Error("Dropping malformed backfill job", "id", id)
+			r.ack(id)
+		}
+
+		running, onBackend := 0, 0
+		for _, job := range jobs {
+			if job.State == JobRunning {
+				running++
+				if job.Backend == r.backend {
+					onBackend++
+				}
+			}
+		}
+		for _, job := range jobs {
+			if job.State != JobWaiting {
+				continue
+			}
+			tableConfig, ok := r.tables[job.Table]
+			if !ok || !tableConfig.Enabled {
+				logger.Error("Dropping backfill job for a table not enabled in the config", "id", job.ID, "table", job.Table)
+				r.ack(job.ID)
+				continue
+			}
+			if (r.maxJobs > 0 && running >= r.maxJobs) || (r.maxJobsPerBackend > 0 && onBackend >= r.maxJobsPerBackend) {
+				// Later jobs wait behind the first in line
+				return nil
+			}
+			lease, err := r.locks.Acquire(ctx, runningLock(r.backend, job.ID), r.consumer, lock.DefaultTTL)
+			if err != nil {
+				return fmt.Errorf("failed to claim backfill job %s: %w", job.ID, err)
+			}
+			started = true
+			r.wg.Add(1)
+			go r.runJob(runCtx, job, tableConfig, lease, migrator)
+			return nil
+		}
+		return nil
+	})
+	if errors.Is(err, lock.ErrHeld) {
+		// Another runner is dispatching
+		return false, nil
+	}
+	return started, err
+}
+
+// runJob runs a claimed job and acknowledges it, or queues its retry, before
+// releasing its lock
+func (r *Runner) runJob(ctx context.Context, job QueuedJob, tableConfig config.TableConfig, lease lock.Lease, migrator TableMigrator) {
+	defer r.wg.Done()
+	defer func() {
+		r.putMigrator(migrator)
+		select {
+		case r.finished <- struct{}{}:
+		default:
+		}
+	}()
+
+	lock.Keep(ctx, r.locks, lease, lock.DefaultTTL, func(jobCtx context.Context, _ lock.Lease) error {
+		logger.Info("Running backfill job", "id", job.ID, "table", job.Table, "attempt", job.Attempts+1,
+			"priority", job.Priority, "requested_by", job.RequestedBy)
+		started := time.Now()
+		err := r.migrate(jobCtx, migrator, job.Table, tableConfig)
+		switch {
+		case err == nil:
+			r.record(job, started, RunCompleted, nil)
+			r.ack(job.ID)
+		case errors.Is(err, lock.ErrHeld):
+			logger.Warn("Dropping backfill job for a table locked by another operation", "id", job.ID, "error", err)
+			r.ack(job.ID)
+		case ctx.Err() != nil:
+			logger.Info("Backfill job interrupted, another worker will resume it", "id", job.ID, "table", job.Table)
+			r.record(job, started, RunInterrupted, nil)
+		default:
+			r.record(job, started, RunFailed, err)
+			r.retry(job, err)
+		}
+		return nil
+	})
+}
+
+// record saves the run of a job that migrated its table or tried to
+func (r *Runner) record(job QueuedJob, started time.Time, outcome string, cause error) {
+	if r.runs == nil {
+		return
+	}
+	run := Run{
+		Table:    job.Table,
+		JobID:    job.ID,
+		Attempt:  job.Attempts + 1,
+		Started:  started,
+		Finished: time.Now(),
+		Outcome:  outcome,
+		Owner:    r.consumer,
+	}
+	if cause != nil {
+		run.Error = cause.Error()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.runs.Save(ctx, run); err != nil {
+		logger.Warn("Failed to record backfill run", "id", job.ID, "table", job.Table, "error", err)
+	}
+}
+
+// retry queues a failed job to run again after the retry delay, or drops it
+// after maxJobAttempts runs
+func (r *Runner) retry(job QueuedJob, cause error) {
+	next := job.Job
+	next.Attempts++
+	if next.Attempts >= maxJobAttempts {
+		logger.Error("Dropping backfill job after repeated failures", "id", job.ID, "table", job.Table,
+			"attempts", next.Attempts, "error", cause)
+		r.ack(job.ID)
+		return
+	}
+	logger.Error("Backfill job failed, retrying later", "id", job.ID, "table", job.Table, "attempt", next.Attempts, "error", cause)
+
+	next.NotBefore = time.Now().Add(r.retryDelay)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := Enqueue(ctx, r.queue, next); err != nil {
+		// The job stays queued and is retried right away
+		logger.Warn("Failed to queue backfill job retry", "id", job.ID, "error", err)
+		return
+	}
+	r.ack(job.ID)
+}
+
+// migrate migrates a table holding its lock
+func (r *Runner) migrate(ctx context.Context, migrator TableMigrator, table string, tableConfig config.TableConfig) error {
+	return lock.Hold(ctx, r.locks, lock.Table(table), r.consumer, lock.DefaultTTL,
+		func(ctx context.Context, lease lock.Lease) error {
+			logger.Info("Holding table lock", "lock", lease.Name, "token", lease.Token)
+			return migrator.Start(ctx, table, tableConfig, &lease)
+		})
+}
+
+// takeMigrator returns an idle migrator, nil when all are running jobs
+func (r *Runner) takeMigrator() TableMigrator {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.idle) == 0 {
+		return nil
+	}
+	migrator := r.idle[len(r.idle)-1]
+	r.idle = r.idle[:len(r.idle)-1]
+	return migrator
+}
+
+// putMigrator makes a migrator idle again
+func (r *Runner) putMigrator(migrator TableMigrator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.idle = append(r.idle, migrator)
+}
+
+// ack acknowledges a finished or dropped job
+func (r *Runner) ack(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.queue.Ack(ctx, id); err != nil {
+		logger.Warn("Failed to acknowledge backfill job", "id", id, "error", err)
+	}
+}
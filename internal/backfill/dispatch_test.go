@@ -0,0 +1,325 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
+	"github.com/kafitramarna/TransisiDB/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMigrator records migrated tables and fails the tables in fail
+type fakeMigrator struct {
+	mu     sync.Mutex
+	tables []string
+	leases []*lock.Lease
+	fail   map[string]bool
+}
+
+func (m *fakeMigrator) Start(ctx context.Context, tableName string, tableConfig config.TableConfig, lease *lock.Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tables = append(m.tables, tableName)
+	m.leases = append(m.leases, lease)
+	if m.fail[tableName] {
+		return errors.New("lock wait timeout")
+	}
+	return nil
+}
+
+func (m *fakeMigrator) migrated() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.tables...)
+}
+
+// newTestRunner returns a runner that polls and retries quickly
+func newTestRunner(q queue.Queue, migrator TableMigrator, cfg *config.Config) *Runner {
+	runner := NewRunner(q, migrator, cfg)
+	runner.poll = 5 * time.Millisecond
+	runner.retryDelay = 10 * time.Millisecond
+	return runner
+}
+
+// runUntilDrained runs the runners until the queue is empty
+func runUntilDrained(t *testing.T, q queue.Queue, runners ...*Runner) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, runner := range runners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner.Run(ctx)
+		}()
+	}
+
+	assert.Eventually(t, func() bool {
+		n, err := q.Len(context.Background())
+		return err == nil && n == 0
+	}, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	wg.Wait()
+}
+
+func TestRunner_RunsQueuedJobs(t *testing.T) {
+	q := queue.NewMemory(queue.Options{Block: 5 * time.Millisecond, ClaimIdle: 20 * time.Millisecond})
+	cfg := &config.Config{Tables: config.TablesConfig{
+		"orders":   {Enabled: true},
+		"payments": {Enabled: true},
+		"invoices": {Enabled: false},
+	}}
+	migrator := &fakeMigrator{fail: map[string]bool{"payments": true}}
+	runner := newTestRunner(q, migrator, cfg)
+
+	for _, table := range []string{"orders", "invoices", "payments"} {
+		_, err := Enqueue(context.Background(), q, Job{Table: table, RequestedBy: "ops"})
+		require.NoError(t, err)
+	}
+
+	// The failing job is retried until it is dropped; disabled tables never run
+	runUntilDrained(t, q, runner)
+
+	assert.Equal(t, []string{"orders", "payments", "payments", "payments"}, migrator.migrated())
+	// Each run writes fenced by the lease of the table lock it holds
+	for i, lease := range migrator.leases {
+		require.NotNil(t, lease)
+		assert.Equal(t, lock.Table(migrator.tables[i]), lease.Name)
+		assert.Positive(t, lease.Token)
+	}
+}
+
+func TestRunner_RecordsRuns(t *testing.T) {
+	q := queue.NewMemory(queue.Options{Block: 5 * time.Millisecond, ClaimIdle: 20 * time.Millisecond})
+	cfg := &config.Config{Tables: config.TablesConfig{
+		"orders":   {Enabled: true},
+		"payments": {Enabled: true},
+	}}
+	runner := newTestRunner(q, &fakeMigrator{fail: map[string]bool{"payments": true}}, cfg)
+	runs := NewMemoryRuns()
+	runner.SetRuns(runs)
+
+	for _, table := range []string{"orders", "payments"} {
+		_, err := Enqueue(context.Background(), q, Job{Table: table})
+		require.NoError(t, err)
+	}
+	runUntilDrained(t, q, runner)
+
+	orders, err := runs.Last(context.Background(), "orders")
+	require.NoError(t, err)
+	require.NotNil(t, orders)
+	assert.Equal(t, RunCompleted, orders.Outcome)
+	assert.Equal(t, 1, orders.Attempt)
+	assert.False(t, orders.Finished.Before(orders.Started))
+
+	// The last of the failed attempts
+	payments, err := runs.Last(context.Background(), "payments")
+	require.NoError(t, err)
+	require.NotNil(t, payments)
+	assert.Equal(t, RunFailed, payments.Outcome)
+	assert.Equal(t, maxJobAttempts, payments.Attempt)
+	assert.Contains(t, payments.Error, "lock wait timeout")
+
+	none, err := runs.Last(context.Background(), "invoices")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+}
+
+func TestRunner_SkipsLockedTables(t *testing.T) {
+	q := queue.NewMemory(queue.Options{Block: 5 * time.Millisecond, ClaimIdle: 20 * time.Millisecond})
+	cfg := &config.Config{Tables: config.TablesConfig{
+		"orders":   {Enabled: true},
+		"payments": {Enabled: true},
+	}}
+	migrator := &fakeMigrator{}
+	runner := newTestRunner(q, migrator, cfg)
+	locks := lock.NewMemory()
+	runner.SetLocker(locks)
+
+	// Another host is migrating orders
+	_, err := locks.Acquire(context.Background(), lock.Table("orders"), "other-host", time.Minute)
+	require.NoError(t, err)
+
+	for _, table := range []string{"orders", "payments"} {
+		_, err := Enqueue(context.Background(), q, Job{Table: table})
+		require.NoError(t, err)
+	}
+
+	runUntilDrained(t, q, runner)
+
+	assert.Equal(t, []string{"payments"}, migrator.migrated())
+	leases, err := locks.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, leases, 1, "the payments lock is released after the job")
+	assert.Equal(t, "other-host", leases[0].Owner)
+}
+
+func TestRunner_RunsJobsInOrder(t *testing.T) {
+	q := queue.NewMemory(queue.Options{})
+	cfg := &config.Config{Tables: config.TablesConfig{
+		"orders":    {Enabled: true},
+		"payments":  {Enabled: true},
+		"invoices":  {Enabled: true},
+		"refunds":   {Enabled: true},
+		"customers": {Enabled: true},
+	}}
+	migrator := &fakeMigrator{}
+	runner := newTestRunner(q, migrator, cfg)
+
+	start := time.Now()
+	for i, job := range []Job{
+		{Table: "orders", Rows: 5_000_000},
+		{Table: "customers"},
+		{Table: "payments", Rows: 20_000},
+		{Table: "refunds", Rows: 800, Priority: -1},
+		{Table: "invoices", Rows: 20_000, Priority: 0},
+	} {
+		job.RequestedAt = start.Add(time.Duration(i) * time.Second)
+		_, err := Enqueue(context.Background(), q, job)
+		require.NoError(t, err)
+	}
+
+	jobs, err := ListJobs(context.Background(), q, runner.locks)
+	require.NoError(t, err)
+	order := make([]string, 0, len(jobs))
+	for i, job := range jobs {
+		assert.Equal(t, JobWaiting, job.State)
+		assert.Equal(t, i+1, job.Position)
+		order = append(order, job.Table)
+	}
+	want := []string{"payments", "invoices", "orders", "customers", "refunds"}
+	assert.Equal(t, want, order)
+
+	runUntilDrained(t, q, runner)
+	assert.Equal(t, want, migrator.migrated())
+}
+
+// blockingMigrator runs each table until released and records how many
+// tables ran at once
+type blockingMigrator struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	done    int
+	release chan struct{}
+}
+
+func (m *blockingMigrator) Start(ctx context.Context, tableName string, tableConfig config.TableConfig, lease *lock.Lease) error {
+	m.mu.Lock()
+	m.running++
+	m.peak = max(m.peak, m.running)
+	m.mu.Unlock()
+
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running--
+	m.done++
+	return ctx.Err()
+}
+
+func (m *blockingMigrator) stats() (running, peak, done int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running, m.peak, m.done
+}
+
+func TestRunner_ConcurrencyCaps(t *testing.T) {
+	q := queue.NewMemory(queue.Options{})
+	tables := config.TablesConfig{}
+	for _, table := range []string{"t1", "t2", "t3", "t4", "t5"} {
+		tables[table] = config.TableConfig{Enabled: true}
+		_, err := Enqueue(context.Background(), q, Job{Table: table})
+		require.NoError(t, err)
+	}
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Host: "db-1", Port: 3306},
+		Tables:   tables,
+		Backfill: config.BackfillConfig{MaxJobsPerBackend: 2},
+	}
+
+	// Two workers of three migrators each share the locks
+	locks := lock.NewMemory()
+	migrator := &blockingMigrator{release: make(chan struct{})}
+	var runners []*Runner
+	for i := 0; i < 2; i++ {
+		runner := newTestRunner(q, migrator, cfg)
+		runner.consumer = fmt.Sprintf("worker-%d", i)
+		runner.AddMigrator(migrator)
+		runner.AddMigrator(migrator)
+		runner.SetLocker(locks)
+		runners = append(runners, runner)
+	}
+
+	go func() {
+		// Let the runners fill every slot before each job ends
+		for i := 0; i < 5; i++ {
+			assert.Eventually(t, func() bool {
+				running, _, done := migrator.stats()
+				return running == min(2, 5-done)
+			}, time.Second, time.Millisecond)
+
+			jobs, err := ListJobs(context.Background(), q, locks)
+			assert.NoError(t, err)
+			running := 0
+			for _, job := range jobs {
+				if job.State == JobRunning {
+					running++
+					assert.Equal(t, "db-1:3306", job.Backend)
+				}
+			}
+			assert.LessOrEqual(t, running, 2)
+			migrator.release <- struct{}{}
+		}
+	}()
+	runUntilDrained(t, q, runners...)
+
+	_, peak, done := migrator.stats()
+	assert.Equal(t, 2, peak)
+	assert.Equal(t, 5, done)
+}
+
+func TestListJobs_States(t *testing.T) {
+	q := queue.NewMemory(queue.Options{})
+	locks := lock.NewMemory()
+	ctx := context.Background()
+
+	running, err := Enqueue(ctx, q, Job{Table: "orders"})
+	require.NoError(t, err)
+	_, err = Enqueue(ctx, q, Job{Table: "payments", Attempts: 1, NotBefore: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	_, err = Enqueue(ctx, q, Job{Table: "invoices"})
+	require.NoError(t, err)
+	_, err = q.Publish(ctx, []byte("not json"))
+	require.NoError(t, err)
+	_, err = locks.Acquire(ctx, runningLock("unix:/var/run/mysqld/mysqld.sock", running), "worker-1", time.Minute)
+	require.NoError(t, err)
+
+	jobs, err := ListJobs(ctx, q, locks)
+	require.NoError(t, err)
+	require.Len(t, jobs, 3)
+
+	assert.Equal(t, "orders", jobs[0].Table)
+	assert.Equal(t, JobRunning, jobs[0].State)
+	assert.Zero(t, jobs[0].Position)
+	assert.Equal(t, "unix:/var/run/mysqld/mysqld.sock", jobs[0].Backend)
+	assert.Equal(t, "worker-1", jobs[0].Owner)
+
+	assert.Equal(t, "payments", jobs[1].Table)
+	assert.Equal(t, JobScheduled, jobs[1].State)
+	assert.Equal(t, 1, jobs[1].Position)
+
+	assert.Equal(t, "invoices", jobs[2].Table)
+	assert.Equal(t, JobWaiting, jobs[2].State)
+	assert.Equal(t, 2, jobs[2].Position)
+}
@@ -0,0 +1,55 @@
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
+)
+
+// FenceTable is the table of the backfill's database that keeps the newest
+// fencing token of each table lock. A backfill raises the fence of its lock
+// when it starts, and its batches only update rows while the fence still
+// holds its token, so MySQL itself refuses the writes of a worker whose lease
+// expired and was taken over.
+const FenceTable = "transisidb_fences"
+
+// raiseFence records the lease's token as the newest of its lock. It fails
+// with lock.ErrNotHeld when a newer holder already raised the fence.
+func raiseFence(ctx context.Context, db *sql.DB, lease *lock.Lease) error {
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (lock_name VARCHAR(255) NOT NULL PRIMARY KEY, token BIGINT NOT NULL)", FenceTable)
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return errs.Wrap(errs.BackendQuery, err, "failed to create %s", FenceTable)
+	}
+	raise := fmt.Sprintf("INSERT INTO %s (lock_name, token) VALUES (?, ?) ON DUPLICATE KEY UPDATE token = GREATEST(token, VALUES(token))", FenceTable)
+	if _, err := db.ExecContext(ctx, raise, lease.Name, lease.Token); err != nil {
+		return errs.Wrap(errs.BackendQuery, err, "failed to raise the fence of %s", lease.Name)
+	}
+	return checkFence(ctx, db, lease)
+}
+
+// checkFence returns lock.ErrNotHeld when the fence of the lease's lock holds
+// a newer token than the lease
+func checkFence(ctx context.Context, db *sql.DB, lease *lock.Lease) error {
+	var token int64
+	query := fmt.Sprintf("SELECT token FROM %s WHERE lock_name = ?", FenceTable)
+	if err := db.QueryRowContext(ctx, query, lease.Name).Scan(&token); err != nil {
+		return errs.Wrap(errs.BackendQuery, err, "failed to read the fence of %s", lease.Name)
+	}
+	if token != lease.Token {
+		return fmt.Errorf("%w: %s was taken over with token %d, this backfill holds %d", lock.ErrNotHeld, lease.Name, token, lease.Token)
+	}
+	return nil
+}
+
+// fencePredicate returns the condition that keeps a batch's updates to the
+// holder of lease and its arguments, nothing without a lease
+func fencePredicate(lease *lock.Lease) (string, []interface{}) {
+	if lease == nil {
+		return "", nil
+	}
+	return fmt.Sprintf(" AND EXISTS (SELECT 1 FROM %s WHERE lock_name = ? AND token = ?)", FenceTable),
+		[]interface{}{lease.Name, lease.Token}
+}
@@ -0,0 +1,18 @@
+package backfill
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/lock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFencePredicate(t *testing.T) {
+	predicate, args := fencePredicate(nil)
+	assert.Empty(t, predicate)
+	assert.Empty(t, args)
+
+	predicate, args = fencePredicate(&lock.Lease{Name: lock.Table("orders"), Token: 7})
+	assert.Equal(t, " AND EXISTS (SELECT 1 FROM transisidb_fences WHERE lock_name = ? AND token = ?)", predicate)
+	assert.Equal(t, []interface{}{"table:orders", int64(7)}, args)
+}
@@ -0,0 +1,372 @@
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+)
+
+// Binlog size estimates per converted row. The worker updates one row per
+// statement, so each row is its own transaction: GTID, BEGIN, table map,
+// row event and XID events in row format, GTID, BEGIN, the statement and
+// XID in statement format.
+const (
+	rowEventOverhead       = 250
+	statementEventOverhead = 200
+	// minimalRowImageBytes covers the primary key before image and the
+	// shadow DECIMAL after image of binlog_row_image=MINIMAL
+	minimalRowImageBytes = 24
+)
+
+// lagSampleInterval is how often replica lag is read during calibration
+const lagSampleInterval = 250 * time.Millisecond
+
+// ImpactReport estimates what backfilling a table costs, so a production
+// backfill can be reviewed before it runs
+type ImpactReport struct {
+	Table       string    `json:"table"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	PendingRows   int64         `json:"pending_rows"`
+	BatchSize     int           `json:"batch_size"`
+	Batches       int64         `json:"batches"`
+	SleepInterval time.Duration `json:"sleep_interval"`
+
+	// Calibration is the measured sample run, nil when none ran
+	Calibration *Calibration `json:"calibration,omitempty"`
+	// BatchLatency is the measured time to convert one batch, 0 without
+	// calibration
+	BatchLatency      time.Duration `json:"batch_latency"`
+	RowsPerSecond     float64       `json:"rows_per_second"`
+	EstimatedDuration time.Duration `json:"estimated_duration"`
+
+	BinlogFormat         string `json:"binlog_format"`
+	BinlogRowImage       string `json:"binlog_row_image"`
+	AvgRowBytes          int64  `json:"avg_row_bytes"`
+	EstimatedBinlogBytes int64  `json:"estimated_binlog_bytes"`
+
+	Replicas []ReplicaLagEstimate `json:"replicas,omitempty"`
+}
+
+// Calibration is a short backfill run whose timings the estimates are based on
+type Calibration struct {
+	Rows    int           `json:"rows"`
+	Batches int           `json:"batches"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// ReplicaLagEstimate is the lag a replica showed during calibration and the
+// peak projected for the whole backfill
+type ReplicaLagEstimate struct {
+	Name     string  `json:"name"`
+	Baseline float64 `json:"baseline_seconds"`
+	Peak     float64 `json:"peak_seconds"`
+	// ProjectedPeak extrapolates the lag gained during calibration linearly
+	// over the estimated duration; a replica that kept up keeps its peak
+	ProjectedPeak float64 `json:"projected_peak_seconds"`
+	Error         string  `json:"error,omitempty"`
+
+	// end is the lag read once calibration finished
+	end float64
+}
+
+// Estimate reports the cost of backfilling a table. With calibrateRows above
+// zero that many rows are backfilled first to measure batch latency and the
+// lag of replicas; otherwise nothing is written and the duration assumes
+// batches take no time beyond the sleep interval.
+func (w *Worker) Estimate(ctx context.Context, tableName string, tableConfig config.TableConfig,
+	calibrateRows int, replicas map[string]*sql.DB) (*ImpactReport, error) {
+
+	report := &ImpactReport{
+		Table:         tableName,
+		GeneratedAt:   time.Now().UTC(),
+		BatchSize:     w.config.BatchSize,
+		SleepInterval: time.Duration(w.config.SleepIntervalMs) * time.Millisecond,
+	}
+
+	// Each column's rows are updated separately
+	var pending int64
+	for _, column := range currencyColumns(tableConfig) {
+		n, err := w.countPendingRows(ctx, tableName, tableConfig.Columns[column], nil)
+		if err != nil {
+			return nil, errs.Wrap(errs.BackendQuery, err, "failed to count rows of %s", column)
+		}
+		pending += n
+	}
+	report.PendingRows = pending
+
+	if err := w.readBinlogSettings(ctx, report); err != nil {
+		return nil, err
+	}
+	if err := w.db.QueryRowContext(ctx,
+		`SELECT COALESCE(AVG_ROW_LENGTH, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`,
+		tableName).Scan(&report.AvgRowBytes); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.Wrap(errs.BackendQuery, err, "failed to read average row length")
+	}
+
+	if calibrateRows > 0 && pending > 0 {
+		if !w.running.CompareAndSwap(false, true) {
+			return nil, fmt.Errorf("worker already running")
+		}
+		defer w.running.Store(false)
+		if err := w.calibrate(ctx, tableName, tableConfig, calibrateRows, replicas, report); err != nil {
+			return nil, err
+		}
+	}
+
+	report.estimate()
+	return report, nil
+}
+
+// readBinlogSettings reads how the server logs the backfill's updates
+func (w *Worker) readBinlogSettings(ctx context.Context, report *ImpactReport) error {
+	var format, rowImage sql.NullString
+	if err := w.db.QueryRowContext(ctx, `SELECT @@GLOBAL.binlog_format, @@GLOBAL.binlog_row_image`).Scan(&format, &rowImage); err != nil {
+		return errs.Wrap(errs.BackendQuery, err, "failed to read binlog settings")
+	}
+	report.BinlogFormat = strings.ToUpper(format.String)
+	report.BinlogRowImage = strings.ToUpper(rowImage.String)
+	return nil
+}
+
+// calibrate backfills up to rows rows, timing the batches and sampling the
+// lag of each replica while they run
+func (w *Worker) calibrate(ctx context.Context, tableName string, tableConfig config.TableConfig,
+	rows int, replicas map[string]*sql.DB, report *ImpactReport) error {
+
+	estimates := make([]*ReplicaLagEstimate, 0, len(replicas))
+	for name, db := range replicas {
+		estimate := &ReplicaLagEstimate{Name: name}
+		if lag, err := replicaLag(ctx, db); err != nil {
+			estimate.Error = err.Error()
+		} else {
+			estimate.Baseline, estimate.Peak = lag, lag
+		}
+		estimates = append(estimates, estimate)
+	}
+
+	sampleCtx, stopSampling := context.WithCancel(ctx)
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(lagSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sampleCtx.Done():
+				return
+			case <-ticker.C:
+				sampleLag(sampleCtx, replicas, estimates)
+			}
+		}
+	}()
+
+	start := time.Now()
+	var busy time.Duration
+	calibration := &Calibration{}
+	columns := currencyColumns(tableConfig)
+	var lastID int64
+	for calibration.Rows < rows && len(columns) > 0 {
+		batchStart := time.Now()
+		column := columns[0]
+		processed, nextID, err := w.processBatch(ctx, tableName, column, tableConfig.Columns[column], nil, lastID, min(w.config.BatchSize, rows-calibration.Rows))
+		if err != nil {
+			stopSampling()
+			<-sampled
+			return fmt.Errorf("calibration failed: %w", err)
+		}
+		if nextID == lastID {
+			// Go on with the next column
+			columns, lastID = columns[1:], 0
+			continue
+		}
+		busy += time.Since(batchStart)
+		lastID = nextID
+		calibration.Rows += processed
+		calibration.Batches++
+
+		// Throttle like the backfill, so replicas see the same write rate
+		select {
+		case <-ctx.Done():
+		case <-time.After(report.SleepInterval):
+		}
+	}
+	calibration.Elapsed = time.Since(start)
+
+	stopSampling()
+	<-sampled
+	for _, estimate := range estimates {
+		if estimate.Error == "" {
+			estimate.end = estimate.Peak
+			if lag, err := replicaLag(ctx, replicas[estimate.Name]); err == nil {
+				estimate.end = lag
+				estimate.Peak = math.Max(estimate.Peak, lag)
+			}
+		}
+		report.Replicas = append(report.Replicas, *estimate)
+	}
+	sort.Slice(report.Replicas, func(i, j int) bool { return report.Replicas[i].Name < report.Replicas[j].Name })
+
+	report.Calibration = calibration
+	if calibration.Batches > 0 {
+		report.BatchLatency = busy / time.Duration(calibration.Batches)
+	}
+	return nil
+}
+
+// sampleLag records the current lag of each replica that has not failed
+func sampleLag(ctx context.Context, replicas map[string]*sql.DB, estimates []*ReplicaLagEstimate) {
+	for _, estimate := range estimates {
+		if estimate.Error != "" {
+			continue
+		}
+		if lag, err := replicaLag(ctx, replicas[estimate.Name]); err == nil && lag > estimate.Peak {
+			estimate.Peak = lag
+		}
+	}
+}
+
+// estimate derives the batches, duration, binlog volume and projected
+// replica lag from the measured inputs
+func (r *ImpactReport) estimate() {
+	if r.BatchSize > 0 {
+		r.Batches = (r.PendingRows + int64(r.BatchSize) - 1) / int64(r.BatchSize)
+	}
+
+	perBatch := r.BatchLatency + r.SleepInterval
+	if r.BatchSize > 0 && perBatch > 0 {
+		r.RowsPerSecond = float64(r.BatchSize) / perBatch.Seconds()
+		r.EstimatedDuration = time.Duration(r.Batches) * perBatch
+	}
+
+	r.EstimatedBinlogBytes = r.PendingRows * r.binlogBytesPerRow()
+
+	for i := range r.Replicas {
+		replica := &r.Replicas[i]
+		if replica.Error != "" {
+			continue
+		}
+		replica.ProjectedPeak = replica.Peak
+		if r.Calibration == nil || r.Calibration.Elapsed <= 0 {
+			continue
+		}
+		// Lag still gained by the end of calibration is a replica applying
+		// slower than the backfill writes
+		if gained := replica.end - replica.Baseline; gained > 0 {
+			rate := gained / r.Calibration.Elapsed.Seconds()
+			replica.ProjectedPeak = math.Max(replica.Peak, replica.Baseline+rate*r.EstimatedDuration.Seconds())
+		}
+	}
+}
+
+// binlogBytesPerRow estimates the binlog written for one converted row
+func (r *ImpactReport) binlogBytesPerRow() int64 {
+	switch r.BinlogFormat {
+	case "STATEMENT", "MIXED":
+		// UPDATE <table> SET <shadow> = <value> WHERE id = <id>
+		return statementEventOverhead + int64(len(r.Table)) + 64
+	}
+	switch r.BinlogRowImage {
+	case "MINIMAL":
+		return rowEventOverhead + minimalRowImageBytes
+	default:
+		// Before and after images of the whole row
+		return rowEventOverhead + 2*r.AvgRowBytes
+	}
+}
+
+// replicaLag returns the replica's Seconds_Behind_Source
+func replicaLag(ctx context.Context, db *sql.DB) (float64, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		// Servers before 8.0.22 only know the old statement
+		if rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS"); err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("server is not a replica")
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, fmt.Errorf("replication is not running")
+		}
+		return strconv.ParseFloat(string(values[i]), 64)
+	}
+	return 0, fmt.Errorf("replica status has no Seconds_Behind_Source")
+}
+
+// String formats the report for a change ticket
+func (r *ImpactReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Backfill impact report: %s\n", r.Table)
+	fmt.Fprintf(&b, "Generated:          %s\n", r.GeneratedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Pending rows:       %d\n", r.PendingRows)
+	fmt.Fprintf(&b, "Batches:            %d of %d rows, %s sleep between batches\n", r.Batches, r.BatchSize, r.SleepInterval)
+	if r.Calibration != nil {
+		fmt.Fprintf(&b, "Calibration:        %d rows in %d batches over %s, %s per batch\n",
+			r.Calibration.Rows, r.Calibration.Batches, r.Calibration.Elapsed.Round(time.Millisecond), r.BatchLatency.Round(time.Millisecond))
+	} else {
+		b.WriteString("Calibration:        none, batch time assumed to be 0 (duration is a lower bound)\n")
+	}
+	fmt.Fprintf(&b, "Throughput:         %.0f rows/second\n", r.RowsPerSecond)
+	fmt.Fprintf(&b, "Estimated duration: %s\n", r.EstimatedDuration.Round(time.Second))
+	fmt.Fprintf(&b, "Binlog:             %s", r.BinlogFormat)
+	if r.BinlogFormat == "ROW" {
+		fmt.Fprintf(&b, ", binlog_row_image=%s, average row %d bytes", r.BinlogRowImage, r.AvgRowBytes)
+	}
+	fmt.Fprintf(&b, "\nEstimated binlog:   %s\n", formatBytes(r.EstimatedBinlogBytes))
+	for _, replica := range r.Replicas {
+		if replica.Error != "" {
+			fmt.Fprintf(&b, "Replica %s: lag unavailable: %s\n", replica.Name, replica.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "Replica %s: lag %.0fs before, %.0fs peak during calibration, %.0fs projected peak\n",
+			replica.Name, replica.Baseline, replica.Peak, replica.ProjectedPeak)
+	}
+	return b.String()
+}
+
+// formatBytes formats n with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,75 @@
+package backfill
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpactReport_Estimate(t *testing.T) {
+	report := &ImpactReport{
+		Table:          "orders",
+		PendingRows:    2_500_000,
+		BatchSize:      1000,
+		SleepInterval:  100 * time.Millisecond,
+		BatchLatency:   400 * time.Millisecond,
+		Calibration:    &Calibration{Rows: 10_000, Batches: 10, Elapsed: 5 * time.Second},
+		BinlogFormat:   "ROW",
+		BinlogRowImage: "FULL",
+		AvgRowBytes:    180,
+		Replicas: []ReplicaLagEstimate{
+			{Name: "kept-up", Baseline: 0, Peak: 1, end: 0},
+			{Name: "falling-behind", Baseline: 1, Peak: 3, end: 3},
+			{Name: "down", Error: "replication is not running"},
+		},
+	}
+	report.estimate()
+
+	assert.Equal(t, int64(2500), report.Batches)
+	assert.InDelta(t, 2000, report.RowsPerSecond, 0.001)
+	assert.Equal(t, 1250*time.Second, report.EstimatedDuration)
+	assert.Equal(t, int64(2_500_000*(rowEventOverhead+2*180)), report.EstimatedBinlogBytes)
+
+	// 2s of lag gained over 5s of calibration, extrapolated over 1250s
+	assert.Equal(t, 1.0, report.Replicas[0].ProjectedPeak)
+	assert.InDelta(t, 501, report.Replicas[1].ProjectedPeak, 0.001)
+	assert.Zero(t, report.Replicas[2].ProjectedPeak)
+}
+
+func TestImpactReport_EstimateWithoutCalibration(t *testing.T) {
+	report := &ImpactReport{PendingRows: 1001, BatchSize: 100, SleepInterval: 50 * time.Millisecond, BinlogFormat: "ROW", BinlogRowImage: "MINIMAL"}
+	report.estimate()
+
+	// Batches are assumed to take no time, so only the sleeps count
+	assert.Equal(t, int64(11), report.Batches)
+	assert.Equal(t, 550*time.Millisecond, report.EstimatedDuration)
+	assert.Equal(t, int64(1001*(rowEventOverhead+minimalRowImageBytes)), report.EstimatedBinlogBytes)
+	assert.Contains(t, report.String(), "duration is a lower bound")
+}
+
+func TestImpactReport_BinlogBytesPerRow(t *testing.T) {
+	tests := []struct {
+		format, rowImage string
+		want             int64
+	}{
+		{"ROW", "FULL", rowEventOverhead + 400},
+		{"ROW", "NOBLOB", rowEventOverhead + 400},
+		{"ROW", "MINIMAL", rowEventOverhead + minimalRowImageBytes},
+		{"STATEMENT", "FULL", statementEventOverhead + int64(len("orders")) + 64},
+		{"MIXED", "FULL", statementEventOverhead + int64(len("orders")) + 64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.rowImage, func(t *testing.T) {
+			report := &ImpactReport{Table: "orders", BinlogFormat: tt.format, BinlogRowImage: tt.rowImage, AvgRowBytes: 200}
+			assert.Equal(t, tt.want, report.binlogBytesPerRow())
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "1.5 GiB", formatBytes(3<<29))
+}
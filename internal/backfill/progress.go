@@ -2,6 +2,8 @@ package backfill
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,6 +20,15 @@ type Progress struct {
 	startTime     time.Time
 	endTime       *time.Time
 	status        Status
+	// columns tracks each currency column, since columns can be onboarded
+	// at different times
+	columns map[string]*columnProgress
+}
+
+// columnProgress counts the rows of one column
+type columnProgress struct {
+	total     int64
+	completed atomic.Int64
 }
 
 // Status represents backfill status
@@ -38,23 +49,44 @@ func NewProgress() *Progress {
 	}
 }
 
-// Start marks the backfill as started
+// Start marks the backfill of a table as started, clearing the counts of
+// the previous one
 func (p *Progress) Start(tableName string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	p.tableName = tableName
 	p.startTime = time.Now()
+	p.endTime = nil
 	p.status = StatusRunning
+	p.columns = make(map[string]*columnProgress)
+	atomic.StoreInt64(&p.totalRows, 0)
+	atomic.StoreInt64(&p.completedRows, 0)
+	atomic.StoreInt64(&p.errors, 0)
 }
 
-// SetTotal sets the total number of rows
-func (p *Progress) SetTotal(total int64) {
-	atomic.StoreInt64(&p.totalRows, total)
+// SetColumnTotal sets the number of rows of a column to migrate. The table's
+// total adds up its columns.
+func (p *Progress) SetColumnTotal(column string, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if previous, ok := p.columns[column]; ok {
+		atomic.AddInt64(&p.totalRows, -previous.total)
+	}
+	p.columns[column] = &columnProgress{total: total}
+	atomic.AddInt64(&p.totalRows, total)
 }
 
-// IncrementCompleted increments completed rows count
-func (p *Progress) IncrementCompleted(count int64) {
+// IncrementCompleted increments the completed rows count of a column
+func (p *Progress) IncrementCompleted(column string, count int64) {
+	p.mu.RLock()
+	c := p.columns[column]
+	p.mu.RUnlock()
+
+	if c != nil {
+		c.completed.Add(count)
+	}
 	atomic.AddInt64(&p.completedRows, count)
 }
 
@@ -125,6 +157,18 @@ func (p *Progress) GetSnapshot() *Snapshot {
 		}
 	}
 
+	columns := make([]ColumnSnapshot, 0, len(p.columns))
+	for name, c := range p.columns {
+		column := ColumnSnapshot{Column: name, TotalRows: c.total, CompletedRows: c.completed.Load()}
+		// A column with nothing to migrate was already backfilled
+		column.ProgressPercentage = 100
+		if column.TotalRows > 0 {
+			column.ProgressPercentage = float64(column.CompletedRows) / float64(column.TotalRows) * 100
+		}
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Column < columns[j].Column })
+
 	return &Snapshot{
 		TableName:           p.tableName,
 		Status:              p.status,
@@ -136,6 +180,7 @@ func (p *Progress) GetSnapshot() *Snapshot {
 		StartTime:           p.startTime,
 		EndTime:             p.endTime,
 		EstimatedCompletion: eta,
+		Columns:             columns,
 	}
 }
 
@@ -151,6 +196,17 @@ type Snapshot struct {
 	StartTime           time.Time  `json:"start_time"`
 	EndTime             *time.Time `json:"end_time,omitempty"`
 	EstimatedCompletion *time.Time `json:"estimated_completion,omitempty"`
+	// Columns reports each currency column by name; the table's rows add
+	// up its columns
+	Columns []ColumnSnapshot `json:"columns"`
+}
+
+// ColumnSnapshot is the progress of one currency column
+type ColumnSnapshot struct {
+	Column             string  `json:"column"`
+	TotalRows          int64   `json:"total_rows"`
+	CompletedRows      int64   `json:"completed_rows"`
+	ProgressPercentage float64 `json:"progress_percentage"`
 }
 
 // String returns a human-readable representation
@@ -166,7 +222,15 @@ func (s *Snapshot) String() string {
 		eta = s.EstimatedCompletion.Format("15:04:05")
 	}
 
-	return fmt.Sprintf("Table: %s | Status: %s | Progress: %d/%d (%.1f%%) | Speed: %.0f rows/sec | ETA: %s | Errors: %d",
+	line := fmt.Sprintf("Table: %s | Status: %s | Progress: %d/%d (%.1f%%) | Speed: %.0f rows/sec | ETA: %s | Errors: %d",
 		s.TableName, s.Status, s.CompletedRows, s.TotalRows, s.ProgressPercentage,
 		s.RowsPerSecond, eta, s.Errors)
+	if len(s.Columns) > 1 {
+		columns := make([]string, 0, len(s.Columns))
+		for _, c := range s.Columns {
+			columns = append(columns, fmt.Sprintf("%s %.1f%%", c.Column, c.ProgressPercentage))
+		}
+		line += " | Columns: " + strings.Join(columns, ", ")
+	}
+	return line
 }
@@ -0,0 +1,37 @@
+package backfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress_Columns(t *testing.T) {
+	p := NewProgress()
+	p.Start("orders")
+	p.SetColumnTotal("total_amount", 200)
+	p.SetColumnTotal("shipping_fee", 100)
+	p.SetColumnTotal("discount", 0)
+	p.IncrementCompleted("total_amount", 190)
+	p.IncrementCompleted("shipping_fee", 60)
+
+	snapshot := p.GetSnapshot()
+	assert.Equal(t, int64(300), snapshot.TotalRows)
+	assert.Equal(t, int64(250), snapshot.CompletedRows)
+	require.Len(t, snapshot.Columns, 3)
+	assert.Equal(t, ColumnSnapshot{Column: "discount", ProgressPercentage: 100}, snapshot.Columns[0])
+	assert.Equal(t, ColumnSnapshot{Column: "shipping_fee", TotalRows: 100, CompletedRows: 60, ProgressPercentage: 60}, snapshot.Columns[1])
+	assert.Equal(t, "total_amount", snapshot.Columns[2].Column)
+	assert.InDelta(t, 95, snapshot.Columns[2].ProgressPercentage, 0.001)
+	assert.Contains(t, snapshot.String(), "Columns: discount 100.0%, shipping_fee 60.0%, total_amount 95.0%")
+
+	// The next table starts from zero
+	p.Start("payments")
+	p.SetColumnTotal("amount", 10)
+	snapshot = p.GetSnapshot()
+	assert.Equal(t, int64(10), snapshot.TotalRows)
+	assert.Zero(t, snapshot.CompletedRows)
+	require.Len(t, snapshot.Columns, 1)
+	assert.NotContains(t, snapshot.String(), "Columns:")
+}
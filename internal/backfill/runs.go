@@ -0,0 +1,109 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Run outcomes
+const (
+	RunCompleted = "completed"
+	RunFailed    = "failed"
+	// RunInterrupted is a job stopped with its worker; another worker resumes it
+	RunInterrupted = "interrupted"
+)
+
+// Run is one execution of a queued backfill job
+type Run struct {
+	Table    string    `json:"table"`
+	JobID    string    `json:"job_id"`
+	Attempt  int       `json:"attempt"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+	Owner    string    `json:"owner"`
+}
+
+// Runs keeps the last run of each table
+type Runs interface {
+	Save(ctx context.Context, run Run) error
+	// Last returns the table's last run, nil when it never ran
+	Last(ctx context.Context, table string) (*Run, error)
+}
+
+// RunsKey is the Redis hash of the last runs, one field per table
+const RunsKey = "transisidb:backfill:runs"
+
+// RedisRuns stores runs in a Redis hash shared by every worker
+type RedisRuns struct {
+	client redis.UniversalClient
+}
+
+// NewRedisRuns returns runs stored with client
+func NewRedisRuns(client redis.UniversalClient) *RedisRuns {
+	return &RedisRuns{client: client}
+}
+
+// Save replaces the last run of the table
+func (s *RedisRuns) Save(ctx context.Context, run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal backfill run: %w", err)
+	}
+	if err := s.client.HSet(ctx, RunsKey, run.Table, data).Err(); err != nil {
+		return fmt.Errorf("failed to save backfill run: %w", err)
+	}
+	return nil
+}
+
+// Last returns the table's last run
+func (s *RedisRuns) Last(ctx context.Context, table string) (*Run, error) {
+	data, err := s.client.HGet(ctx, RunsKey, table).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backfill run: %w", err)
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal backfill run of %s: %w", table, err)
+	}
+	return &run, nil
+}
+
+// MemoryRuns keeps runs in process, for tests and single-node setups
+type MemoryRuns struct {
+	mu   sync.Mutex
+	runs map[string]Run
+}
+
+// NewMemoryRuns returns empty in-memory runs
+func NewMemoryRuns() *MemoryRuns {
+	return &MemoryRuns{runs: make(map[string]Run)}
+}
+
+// Save replaces the last run of the table
+func (s *MemoryRuns) Save(ctx context.Context, run Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[run.Table] = run
+	return nil
+}
+
+// Last returns the table's last run
+func (s *MemoryRuns) Last(ctx context.Context, table string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[table]
+	if !ok {
+		return nil, nil
+	}
+	return &run, nil
+}
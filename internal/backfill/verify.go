@@ -0,0 +1,85 @@
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/converter"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// CountDrift counts the rows of a currency column without a shadow value and
+// those whose shadow value is off from the converted source value by at least
+// one unit of the column's precision, falling back to conversion.precision.
+// Rows with a NULL source are not counted.
+func CountDrift(ctx context.Context, db *sql.DB, table, col string, colConfig config.ColumnConfig, conv config.ConversionConfig) (missing, mismatched int64, err error) {
+	source, target := parser.QuoteIdentifier(col), parser.QuoteIdentifier(colConfig.TargetColumn)
+	query := fmt.Sprintf(
+		"SELECT COALESCE(SUM(%s IS NULL), 0), COALESCE(SUM(%s IS NOT NULL AND ABS(%s - %s / ?) >= ?), 0) FROM %s WHERE %s IS NOT NULL",
+		target, target, target, source, parser.QuoteTable(table), source)
+
+	tolerance := converter.ShadowTolerance(conv, colConfig)
+	if err := db.QueryRowContext(ctx, query, conv.Ratio, tolerance).Scan(&missing, &mismatched); err != nil {
+		return 0, 0, err
+	}
+	return missing, mismatched, nil
+}
+
+// Coverage counts the rows of a table and those whose shadow columns are
+// filled
+type Coverage struct {
+	Rows int64 `json:"rows_total"`
+	// Converted are the rows with a shadow value for every currency column
+	// whose source is not NULL
+	Converted int64 `json:"rows_converted"`
+	// Columns counts the rows with a shadow value by currency column
+	Columns map[string]int64 `json:"columns"`
+}
+
+// CountCoverage counts the table's rows and those with shadow values in one
+// scan of the table
+func CountCoverage(ctx context.Context, db *sql.DB, table string, tableConfig config.TableConfig) (Coverage, error) {
+	query, columns := coverageQuery(table, tableConfig)
+	counts := make([]int64, len(columns)+2)
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if err := db.QueryRowContext(ctx, query).Scan(dest...); err != nil {
+		return Coverage{}, err
+	}
+
+	coverage := Coverage{Rows: counts[0], Converted: counts[1], Columns: make(map[string]int64, len(columns))}
+	for i, col := range columns {
+		coverage.Columns[col] = counts[i+2]
+	}
+	return coverage, nil
+}
+
+// coverageQuery returns the query CountCoverage runs and the currency columns
+// in the order of its last results
+func coverageQuery(table string, tableConfig config.TableConfig) (string, []string) {
+	columns := make([]string, 0, len(tableConfig.Columns))
+	for col := range tableConfig.Columns {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	converted := make([]string, len(columns))
+	filled := make([]string, len(columns))
+	for i, col := range columns {
+		source, target := parser.QuoteIdentifier(col), parser.QuoteIdentifier(tableConfig.Columns[col].TargetColumn)
+		converted[i] = fmt.Sprintf("(%s IS NULL OR %s IS NOT NULL)", source, target)
+		filled[i] = fmt.Sprintf(", COALESCE(SUM(%s IS NOT NULL), 0)", target)
+	}
+	all := "1"
+	if len(converted) > 0 {
+		all = strings.Join(converted, " AND ")
+	}
+	return fmt.Sprintf("SELECT COUNT(*), COALESCE(SUM(%s), 0)%s FROM %s",
+		all, strings.Join(filled, ""), parser.QuoteTable(table)), columns
+}
@@ -0,0 +1,25 @@
+package backfill
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoverageQuery(t *testing.T) {
+	query, columns := coverageQuery("shop.orders", config.TableConfig{Columns: map[string]config.ColumnConfig{
+		"total_amount": {TargetColumn: "total_amount_idn"},
+		"shipping_fee": {TargetColumn: "shipping_fee_idn"},
+	}})
+
+	assert.Equal(t, []string{"shipping_fee", "total_amount"}, columns)
+	assert.Equal(t, "SELECT COUNT(*), COALESCE(SUM("+
+		"(`shipping_fee` IS NULL OR `shipping_fee_idn` IS NOT NULL) AND (`total_amount` IS NULL OR `total_amount_idn` IS NOT NULL)), 0), "+
+		"COALESCE(SUM(`shipping_fee_idn` IS NOT NULL), 0), COALESCE(SUM(`total_amount_idn` IS NOT NULL), 0) "+
+		"FROM `shop`.`orders`", query)
+
+	query, columns = coverageQuery("orders", config.TableConfig{})
+	assert.Empty(t, columns)
+	assert.Equal(t, "SELECT COUNT(*), COALESCE(SUM(1), 0) FROM `orders`", query)
+}
@@ -4,21 +4,26 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"sync/atomic"
 	"time"
 
 	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/converter"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
 	"github.com/kafitramarna/TransisiDB/internal/logger"
 	"github.com/kafitramarna/TransisiDB/internal/metrics"
-	"github.com/kafitramarna/TransisiDB/internal/rounding"
 )
 
 // Worker handles background data migration
 type Worker struct {
-	db             *sql.DB
-	config         *config.BackfillConfig
-	conversionCfg  *config.ConversionConfig
-	roundingEngine *rounding.Engine
+	db            *sql.DB
+	config        *config.BackfillConfig
+	conversionCfg *config.ConversionConfig
+	// fence is the lease of the table lock a running backfill holds, nil
+	// when it runs unlocked
+	fence *lock.Lease
 
 	// State
 	running  atomic.Bool
@@ -37,19 +42,18 @@ func NewWorker(db *sql.DB, cfg *config.Config) *Worker {
 		db:            db,
 		config:        &cfg.Backfill,
 		conversionCfg: &cfg.Conversion,
-		roundingEngine: rounding.NewEngine(
-			rounding.Strategy(cfg.Conversion.RoundingStrategy),
-			cfg.Conversion.Precision,
-		),
-		progress: NewProgress(),
-		pauseCh:  make(chan struct{}),
-		resumeCh: make(chan struct{}),
-		stopCh:   make(chan struct{}),
+		progress:      NewProgress(),
+		pauseCh:       make(chan struct{}),
+		resumeCh:      make(chan struct{}),
+		stopCh:        make(chan struct{}),
 	}
 }
 
-// Start begins the backfill process for a table
-func (w *Worker) Start(ctx context.Context, tableName string, tableConfig config.TableConfig) error {
+// Start begins the backfill process for a table. Its currency columns are
+// backfilled one after the other, in name order. With the lease of the
+// table's lock, rows are only written while no newer holder took the lock
+// over, see FenceTable; nil runs unfenced.
+func (w *Worker) Start(ctx context.Context, tableName string, tableConfig config.TableConfig, lease *lock.Lease) error {
 	if !w.running.CompareAndSwap(false, true) {
 		return fmt.Errorf("worker already running")
 	}
@@ -58,57 +62,106 @@ func (w *Worker) Start(ctx context.Context, tableName string, tableConfig config
 	logger.Info("Starting backfill job", "table", tableName)
 	w.progress.Start(tableName)
 
-	// Count total rows to migrate
-	totalRows, err := w.countPendingRows(ctx, tableName, tableConfig)
+	columns := currencyColumns(tableConfig)
+	if len(columns) == 0 {
+		return errs.New(errs.ConfigInvalid, "no currency columns configured")
+	}
+
+	if lease != nil {
+		if err := raiseFence(ctx, w.db, lease); err != nil {
+			return err
+		}
+		w.fence = lease
+		defer func() { w.fence = nil }()
+	}
+
+	b, err := w.openBoundary(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to count rows: %w", err)
+		return err
+	}
+	defer b.close()
+
+	// Count the rows to migrate of each column. In a snapshot the first count
+	// takes the snapshot.
+	pending := make(map[string]int64, len(columns))
+	var totalRows int64
+	for _, column := range columns {
+		n, err := w.countPendingRows(ctx, tableName, tableConfig.Columns[column], b)
+		if err != nil {
+			return fmt.Errorf("failed to count rows of %s: %w", column, err)
+		}
+		w.progress.SetColumnTotal(column, n)
+		pending[column] = n
+		totalRows += n
 	}
-	w.progress.SetTotal(totalRows)
 
 	if totalRows == 0 {
-		logger.Info("No rows to backfill", "table", tableName)
+		logger.Info("No rows to backfill", "table", tableName, "boundary", b.String())
 		w.progress.Complete()
 		return nil
 	}
 
-	logger.Info("Backfill started", "table", tableName, "total_rows", totalRows)
+	logger.Info("Backfill started", "table", tableName, "total_rows", totalRows, "boundary", b.String())
+
+	for _, column := range columns {
+		if pending[column] == 0 {
+			continue
+		}
+		stopped, err := w.backfillColumn(ctx, tableName, column, tableConfig.Columns[column], b)
+		if err != nil || stopped {
+			return err
+		}
+	}
+
+	w.progress.Complete()
+	metrics.SetBackfillProgress(tableName, 100.0)
+	logger.Info("Backfill completed successfully", "table", tableName)
+	w.logRemaining(ctx, tableName, tableConfig, b)
+	return nil
+}
+
+// backfillColumn converts the rows of one column in batches and reports
+// whether the worker was stopped. Rows are walked in id order so rows the
+// conversion guard rejects, which keep a NULL shadow value, are not selected
+// again.
+func (w *Worker) backfillColumn(ctx context.Context, tableName, column string, colConfig config.ColumnConfig, b *boundary) (bool, error) {
+	logger.Info("Backfilling column", "table", tableName, "column", column)
 
-	// Process in batches
+	var lastID int64
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return false, ctx.Err()
 		case <-w.stopCh:
-			return nil
+			return true, nil
 		case <-w.pauseCh:
 			// Wait for resume
 			<-w.resumeCh
 		default:
 			// Process next batch
-			processed, err := w.processBatch(ctx, tableName, tableConfig)
+			processed, nextID, err := w.processBatch(ctx, tableName, column, colConfig, b, lastID, w.config.BatchSize)
 			if err != nil {
 				w.progress.IncrementErrors()
 				metrics.RecordBackfillError(tableName)
 				metrics.RecordError("backfill")
-				logger.Error("Batch processing failed", "table", tableName, "error", err)
+				logger.Error("Batch processing failed", "table", tableName, "column", column, "error", err, "code", errs.CodeOf(err))
 
-				// Retry logic
-				if w.shouldRetry() {
+				// Backend failures are retried; conversion and config errors
+				// would fail the same way again
+				if errs.ActionOf(err) == errs.Retry && w.shouldRetry() {
 					time.Sleep(time.Duration(w.config.RetryBackoffMs) * time.Millisecond)
 					continue
 				}
-				return fmt.Errorf("batch processing failed: %w", err)
+				return false, fmt.Errorf("batch processing failed: %w", err)
 			}
 
-			if processed == 0 {
+			if nextID == lastID {
 				// No more rows to process
-				w.progress.Complete()
-				metrics.SetBackfillProgress(tableName, 100.0)
-				logger.Info("Backfill completed successfully", "table", tableName)
-				return nil
+				return false, nil
 			}
 
-			w.progress.IncrementCompleted(int64(processed))
+			lastID = nextID
+			w.progress.IncrementCompleted(column, int64(processed))
 
 			// Update metrics
 			for i := 0; i < processed; i++ {
@@ -123,94 +176,166 @@ func (w *Worker) Start(ctx context.Context, tableName string, tableConfig config
 	}
 }
 
-// processBatch processes a batch of rows
-func (w *Worker) processBatch(ctx context.Context, tableName string, tableConfig config.TableConfig) (int, error) {
-	// Build query to select batch of rows without converted values
-	columns := make([]string, 0, len(tableConfig.Columns))
-	for colName := range tableConfig.Columns {
-		columns = append(columns, colName)
+// Sample backfills at most rows rows of each currency column of a table and
+// returns how many values it converted. Onboarding uses it to try the
+// conversion on part of a table before the full backfill.
+func (w *Worker) Sample(ctx context.Context, tableName string, tableConfig config.TableConfig, rows int) (int, error) {
+	if !w.running.CompareAndSwap(false, true) {
+		return 0, fmt.Errorf("worker already running")
 	}
+	defer w.running.Store(false)
 
-	// For simplicity, we'll process the first currency column
-	// In production, you'd handle all columns
-	var firstColumn string
-	var firstConfig config.ColumnConfig
-	for col, cfg := range tableConfig.Columns {
-		firstColumn = col
-		firstConfig = cfg
-		break
+	total := 0
+	for _, column := range currencyColumns(tableConfig) {
+		sampled := 0
+		var lastID int64
+		for sampled < rows {
+			if err := ctx.Err(); err != nil {
+				return total, err
+			}
+			processed, nextID, err := w.processBatch(ctx, tableName, column, tableConfig.Columns[column], nil, lastID, min(w.config.BatchSize, rows-sampled))
+			if err != nil {
+				metrics.RecordBackfillError(tableName)
+				return total, fmt.Errorf("batch processing failed: %w", err)
+			}
+			if nextID == lastID {
+				break
+			}
+			lastID = nextID
+			sampled += processed
+			total += processed
+			for i := 0; i < processed; i++ {
+				metrics.RecordBackfillRow(tableName)
+			}
+		}
 	}
+	return total, nil
+}
 
-	if firstColumn == "" {
-		return 0, fmt.Errorf("no currency columns configured")
+// currencyColumns returns the currency columns of a table in name order
+func currencyColumns(tableConfig config.TableConfig) []string {
+	columns := make([]string, 0, len(tableConfig.Columns))
+	for column := range tableConfig.Columns {
+		columns = append(columns, column)
 	}
+	sort.Strings(columns)
+	return columns
+}
 
+// processBatch processes a batch of at most limit rows of a column with an
+// id above afterID inside the boundary b. It returns how many rows it
+// converted and the last id it read, which is afterID when no rows are left.
+// Rows with a NULL source, rows the conversion guard rejects and amounts
+// outside the column's bounds are read but not converted.
+func (w *Worker) processBatch(ctx context.Context, tableName, column string, colConfig config.ColumnConfig, b *boundary, afterID int64, limit int) (int, int64, error) {
 	// Query for rows where shadow column is NULL
+	predicate, args := b.predicate()
 	query := fmt.Sprintf(
-		`SELECT id, %s FROM %s WHERE %s IS NULL LIMIT %d`,
-		firstColumn,
+		`SELECT id, %s FROM %s WHERE %s IS NULL AND id > ?%s ORDER BY id LIMIT %d`,
+		column,
 		tableName,
-		firstConfig.TargetColumn,
-		w.config.BatchSize,
+		colConfig.TargetColumn,
+		predicate,
+		limit,
 	)
 
-	rows, err := w.db.QueryContext(ctx, query)
+	rows, err := b.reader(w.db).QueryContext(ctx, query, append([]interface{}{afterID}, args...)...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to query batch: %w", err)
+		return 0, afterID, errs.Wrap(errs.BackendQuery, err, "failed to query batch")
 	}
 	defer rows.Close()
 
+	guard := converter.NewGuard(*w.conversionCfg, converter.SourceBackfill)
 	processed := 0
+	lastID := afterID
 	for rows.Next() {
 		var id int64
-		var value int64
+		// DECIMAL sources may hold fractional amounts
+		var value sql.NullString
 
 		if err := rows.Scan(&id, &value); err != nil {
-			return processed, fmt.Errorf("failed to scan row: %w", err)
+			return processed, lastID, errs.Wrap(errs.BackendQuery, err, "failed to scan row")
+		}
+		lastID = id
+
+		// A NULL source has no shadow value to fill
+		if !value.Valid {
+			continue
+		}
+		amount, err := converter.ParseStoredAmount(value.String)
+		if err != nil {
+			logger.Warn("Skipping row with an unreadable amount", "table", tableName, "column", column, "id", id, "error", err)
+			continue
+		}
+
+		// Leave amounts that look already converted or are out of range for
+		// an operator
+		if err := guard.Check(tableName, column, colConfig, amount, nil); err != nil {
+			continue
+		}
+		if err := converter.CheckBounds(converter.SourceBackfill, tableName, column, colConfig, amount); err != nil {
+			continue
 		}
 
-		// Convert value
-		convertedValue := w.roundingEngine.ConvertIDRtoIDN(value, w.conversionCfg.Ratio)
+		// Convert value with the column's rounding, as dual-write does
+		shadow, err := converter.ShadowValue(*w.conversionCfg, colConfig, amount)
+		if err != nil {
+			// MySQL outside strict mode would clamp the value to the column's maximum
+			converter.OutOfRange(converter.SourceBackfill, tableName, column, converter.BoundTargetType, err)
+			continue
+		}
+		metrics.RecordConvertedAmount(tableName, column, amount/float64(w.conversionCfg.Ratio))
 
-		// Update row
+		// Update row, unless it changed since it was read or the table lock
+		// was taken over. A snapshot can be behind the table, and dual-write
+		// may have set the shadow value already.
+		fence, fenceArgs := fencePredicate(w.fence)
 		updateQuery := fmt.Sprintf(
-			`UPDATE %s SET %s = ? WHERE id = ?`,
+			`UPDATE %s SET %s = ? WHERE id = ? AND %s IS NULL AND %s = ?%s`,
 			tableName,
-			firstConfig.TargetColumn,
+			colConfig.TargetColumn,
+			colConfig.TargetColumn,
+			column,
+			fence,
 		)
 
-		_, err := w.db.ExecContext(ctx, updateQuery, convertedValue, id)
+		result, err := w.db.ExecContext(ctx, updateQuery, append([]interface{}{shadow, id, value.String}, fenceArgs...)...)
 		if err != nil {
-			return processed, fmt.Errorf("failed to update row %d: %w", id, err)
+			return processed, lastID, errs.Wrap(errs.BackendQuery, err, "failed to update row %d", id)
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			if w.fence != nil {
+				if err := checkFence(ctx, w.db, w.fence); err != nil {
+					return processed, lastID, err
+				}
+			}
+			logger.Debug("Row changed since it was read, skipping", "table", tableName, "id", id)
+			continue
 		}
 
 		processed++
 	}
 
 	if err := rows.Err(); err != nil {
-		return processed, fmt.Errorf("row iteration error: %w", err)
+		return processed, lastID, errs.Wrap(errs.BackendQuery, err, "row iteration error")
 	}
 
-	return processed, nil
+	return processed, lastID, nil
 }
 
-// countPendingRows counts how many rows still need migration
-func (w *Worker) countPendingRows(ctx context.Context, tableName string, tableConfig config.TableConfig) (int64, error) {
-	// Get first currency column
-	var firstConfig config.ColumnConfig
-	for _, cfg := range tableConfig.Columns {
-		firstConfig = cfg
-		break
-	}
-
+// countPendingRows counts how many rows of a column inside the boundary b
+// still need migration
+func (w *Worker) countPendingRows(ctx context.Context, tableName string, colConfig config.ColumnConfig, b *boundary) (int64, error) {
+	predicate, args := b.predicate()
 	query := fmt.Sprintf(
-		`SELECT COUNT(*) FROM %s WHERE %s IS NULL`,
+		`SELECT COUNT(*) FROM %s WHERE %s IS NULL%s`,
 		tableName,
-		firstConfig.TargetColumn,
+		colConfig.TargetColumn,
+		predicate,
 	)
 
 	var count int64
-	err := w.db.QueryRowContext(ctx, query).Scan(&count)
+	err := b.reader(w.db).QueryRowContext(ctx, query, args...).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -218,6 +343,39 @@ func (w *Worker) countPendingRows(ctx context.Context, tableName string, tableCo
 	return count, nil
 }
 
+// logRemaining logs how many rows of the live table still have no shadow
+// value once a job with a boundary ends: rows written after the boundary that
+// dual-write did not convert, and rows the conversion guard rejected
+func (w *Worker) logRemaining(ctx context.Context, tableName string, tableConfig config.TableConfig, b *boundary) {
+	if b == nil {
+		return
+	}
+	for _, column := range currencyColumns(tableConfig) {
+		remaining, err := w.countPendingRows(ctx, tableName, tableConfig.Columns[column], nil)
+		if err != nil {
+			logger.Warn("Failed to count rows left after the boundary", "table", tableName, "column", column, "error", err)
+			continue
+		}
+		if remaining > 0 {
+			logger.Warn("Rows without a shadow value remain after the backfill boundary",
+				"table", tableName, "column", column, "boundary", b.String(), "rows", remaining)
+		}
+	}
+}
+
+// TableRows returns the server's estimate of a table's rows, which queued
+// jobs are ordered by
+func (w *Worker) TableRows(ctx context.Context, tableName string) (int64, error) {
+	var rows int64
+	err := w.db.QueryRowContext(ctx,
+		`SELECT COALESCE(TABLE_ROWS, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`,
+		tableName).Scan(&rows)
+	if err != nil {
+		return 0, errs.Wrap(errs.BackendQuery, err, "failed to estimate rows of %s", tableName)
+	}
+	return rows, nil
+}
+
 // shouldRetry determines if we should retry after an error
 func (w *Worker) shouldRetry() bool {
 	return w.progress.errors < int64(w.config.RetryAttempts)
@@ -0,0 +1,222 @@
+// Package canary replays rewritten statements against a second MySQL instance
+// and reports where its results differ from the primary's, so parser and
+// converter changes can be validated against production traffic.
+package canary
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// Comparison outcomes, also the values of the metric's outcome label
+const (
+	OutcomeMatch         = "match"
+	OutcomeRowsMismatch  = "rows_mismatch"
+	OutcomeErrorMismatch = "error_mismatch"
+	OutcomeUnavailable   = "unavailable"
+	OutcomeDropped       = "dropped"
+)
+
+// maxMismatches is how many mismatches Mismatches returns
+const maxMismatches = 100
+
+// Executor runs a statement on the canary; *sql.DB implements it
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Statement is a rewritten statement and the primary's result
+type Statement struct {
+	Table string
+	Query string
+	// AffectedRows is the primary's affected row count when ErrorCode is 0
+	AffectedRows uint64
+	// ErrorCode is the primary's MySQL error code, 0 when it succeeded
+	ErrorCode uint16
+}
+
+// Mismatch is a statement whose canary result differed from the primary's
+type Mismatch struct {
+	Time        time.Time `json:"time"`
+	Table       string    `json:"table"`
+	Fingerprint string    `json:"fingerprint"`
+	Outcome     string    `json:"outcome"`
+	Primary     string    `json:"primary"`
+	Canary      string    `json:"canary"`
+}
+
+// Replayer executes statements on the canary in the background
+type Replayer struct {
+	exec       Executor
+	queue      chan Statement
+	sampleRate float64
+	workers    int
+	timeout    time.Duration
+
+	mu         sync.Mutex
+	mismatches []Mismatch // ring buffer, next is the oldest entry once full
+	next       int
+}
+
+// NewReplayer returns a replayer for the canary config with defaults applied
+func NewReplayer(exec Executor, cfg config.CanaryConfig) *Replayer {
+	r := &Replayer{
+		exec:       exec,
+		sampleRate: cfg.SampleRate,
+		workers:    cfg.Workers,
+		timeout:    cfg.Timeout,
+	}
+	if r.sampleRate == 0 {
+		r.sampleRate = 1
+	}
+	if r.workers <= 0 {
+		r.workers = config.DefaultCanaryWorkers
+	}
+	if r.timeout <= 0 {
+		r.timeout = config.DefaultCanaryTimeout
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = config.DefaultCanaryQueueSize
+	}
+	r.queue = make(chan Statement, queueSize)
+	return r
+}
+
+// Submit queues a statement for replay without blocking. Statements outside
+// the sample, or arriving while the queue is full, are not replayed.
+func (r *Replayer) Submit(stmt Statement) {
+	if r.sampleRate < 1 && rand.Float64() >= r.sampleRate {
+		return
+	}
+	select {
+	case r.queue <- stmt:
+	default:
+		metrics.RecordCanaryComparison(stmt.Table, OutcomeDropped)
+	}
+}
+
+// Run replays queued statements until ctx is cancelled
+func (r *Replayer) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case stmt := <-r.queue:
+					r.replay(ctx, stmt)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// replay executes a statement on the canary and compares the results
+func (r *Replayer) replay(ctx context.Context, stmt Statement) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.exec.ExecContext(ctx, stmt.Query)
+	outcome, canary := compare(stmt, result, err)
+	metrics.RecordCanaryComparison(stmt.Table, outcome)
+
+	switch outcome {
+	case OutcomeMatch:
+	case OutcomeUnavailable:
+		logger.Debug("Canary replay failed", "table", stmt.Table, "error", err)
+	default:
+		mismatch := Mismatch{
+			Time:        time.Now(),
+			Table:       stmt.Table,
+			Fingerprint: parser.Fingerprint(stmt.Query),
+			Outcome:     outcome,
+			Primary:     describePrimary(stmt),
+			Canary:      canary,
+		}
+		logger.Warn("Canary result differs from primary", "table", mismatch.Table, "outcome", outcome,
+			"primary", mismatch.Primary, "canary", mismatch.Canary, "fingerprint", mismatch.Fingerprint)
+		r.record(mismatch)
+	}
+}
+
+// compare classifies the canary's result of a statement and describes it
+func compare(stmt Statement, result sql.Result, err error) (string, string) {
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if !errors.As(err, &mysqlErr) {
+			// Timeouts and connection failures say nothing about the statement
+			return OutcomeUnavailable, err.Error()
+		}
+		canary := fmt.Sprintf("error %d", mysqlErr.Number)
+		if stmt.ErrorCode == mysqlErr.Number {
+			return OutcomeMatch, canary
+		}
+		return OutcomeErrorMismatch, canary
+	}
+	if stmt.ErrorCode != 0 {
+		return OutcomeErrorMismatch, "ok"
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return OutcomeUnavailable, err.Error()
+	}
+	canary := strconv.FormatInt(affected, 10) + " rows"
+	if uint64(affected) != stmt.AffectedRows {
+		return OutcomeRowsMismatch, canary
+	}
+	return OutcomeMatch, canary
+}
+
+// describePrimary describes the primary's result like compare describes the
+// canary's
+func describePrimary(stmt Statement) string {
+	if stmt.ErrorCode != 0 {
+		return fmt.Sprintf("error %d", stmt.ErrorCode)
+	}
+	return strconv.FormatUint(stmt.AffectedRows, 10) + " rows"
+}
+
+// record keeps a mismatch, replacing the oldest once the buffer is full
+func (r *Replayer) record(m Mismatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.mismatches) < maxMismatches {
+		r.mismatches = append(r.mismatches, m)
+		return
+	}
+	r.mismatches[r.next] = m
+	r.next = (r.next + 1) % maxMismatches
+}
+
+// Mismatches returns the most recent mismatches, newest first
+func (r *Replayer) Mismatches() []Mismatch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(r.mismatches)
+	recent := make([]Mismatch, 0, n)
+	for i := 1; i <= n; i++ {
+		idx := (r.next - i + n) % n
+		recent = append(recent, r.mismatches[idx])
+	}
+	return recent
+}
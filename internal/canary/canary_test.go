@@ -0,0 +1,127 @@
+package canary
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExecutor answers every statement with the same result
+type fakeExecutor struct {
+	affected int64
+	err      error
+	queries  chan string
+}
+
+func (f *fakeExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if f.queries != nil {
+		f.queries <- query
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return driverResult(f.affected), nil
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, nil }
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name    string
+		stmt    Statement
+		result  sql.Result
+		err     error
+		outcome string
+	}{
+		{"same rows", Statement{AffectedRows: 2}, driverResult(2), nil, OutcomeMatch},
+		{"different rows", Statement{AffectedRows: 2}, driverResult(1), nil, OutcomeRowsMismatch},
+		{"same error", Statement{ErrorCode: 1062}, nil, &mysql.MySQLError{Number: 1062}, OutcomeMatch},
+		{"different error", Statement{ErrorCode: 1062}, nil, &mysql.MySQLError{Number: 1054}, OutcomeErrorMismatch},
+		{"canary error only", Statement{AffectedRows: 1}, nil, &mysql.MySQLError{Number: 1054}, OutcomeErrorMismatch},
+		{"primary error only", Statement{ErrorCode: 1054}, driverResult(1), nil, OutcomeErrorMismatch},
+		{"wrapped error", Statement{ErrorCode: 1062}, nil, fmt.Errorf("exec: %w", &mysql.MySQLError{Number: 1062}), OutcomeMatch},
+		{"unreachable", Statement{AffectedRows: 1}, nil, context.DeadlineExceeded, OutcomeUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome, _ := compare(tt.stmt, tt.result, tt.err)
+			assert.Equal(t, tt.outcome, outcome)
+		})
+	}
+}
+
+func TestReplayer_RecordsMismatches(t *testing.T) {
+	exec := &fakeExecutor{affected: 1}
+	r := NewReplayer(exec, config.CanaryConfig{})
+
+	r.replay(context.Background(), Statement{Table: "orders", Query: "UPDATE orders SET total = 5 WHERE id = 1", AffectedRows: 1})
+	r.replay(context.Background(), Statement{Table: "orders", Query: "UPDATE orders SET total = 5 WHERE id > 1", AffectedRows: 3})
+	exec.err = errors.New("connection refused")
+	r.replay(context.Background(), Statement{Table: "orders", Query: "DELETE FROM orders", AffectedRows: 3})
+
+	mismatches := r.Mismatches()
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, "orders", mismatches[0].Table)
+	assert.Equal(t, OutcomeRowsMismatch, mismatches[0].Outcome)
+	assert.Equal(t, "3 rows", mismatches[0].Primary)
+	assert.Equal(t, "1 rows", mismatches[0].Canary)
+	assert.Equal(t, "UPDATE orders SET total = ? WHERE id > ?", mismatches[0].Fingerprint)
+}
+
+func TestReplayer_MismatchesNewestFirst(t *testing.T) {
+	r := NewReplayer(&fakeExecutor{}, config.CanaryConfig{})
+	for i := 0; i < maxMismatches+5; i++ {
+		r.record(Mismatch{Table: fmt.Sprintf("t%d", i)})
+	}
+
+	mismatches := r.Mismatches()
+	require.Len(t, mismatches, maxMismatches)
+	assert.Equal(t, fmt.Sprintf("t%d", maxMismatches+4), mismatches[0].Table)
+	assert.Equal(t, "t5", mismatches[len(mismatches)-1].Table)
+}
+
+func TestReplayer_SubmitDropsWhenFull(t *testing.T) {
+	r := NewReplayer(&fakeExecutor{}, config.CanaryConfig{QueueSize: 1})
+	r.Submit(Statement{Table: "orders"})
+	r.Submit(Statement{Table: "orders"})
+	assert.Len(t, r.queue, 1)
+}
+
+func TestReplayer_Run(t *testing.T) {
+	exec := &fakeExecutor{queries: make(chan string, 1)}
+	r := NewReplayer(exec, config.CanaryConfig{Workers: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	r.Submit(Statement{Table: "orders", Query: "DELETE FROM orders WHERE id = 1"})
+	select {
+	case query := <-exec.queries:
+		assert.Equal(t, "DELETE FROM orders WHERE id = 1", query)
+	case <-time.After(time.Second):
+		t.Fatal("statement was not replayed")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -0,0 +1,193 @@
+// Package certgen creates a private certificate authority with server and
+// client certificates for development and tests. Keys are written
+// unencrypted; do not use them in production.
+package certgen
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"time"
+)
+
+// DefaultValidity is how long generated certificates are valid
+const DefaultValidity = 365 * 24 * time.Hour
+
+// DefaultClient is the client certificate generated when none is named
+const DefaultClient = "transisidb"
+
+// organization is the subject organization of every generated certificate
+const organization = "TransisiDB Development"
+
+// DefaultHosts are the names of the server certificate when none are given
+var DefaultHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// clientName restricts client names to ones usable in file names
+var clientName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Options select what Generate creates
+type Options struct {
+	// Hosts are the DNS names and IP addresses of the server certificate
+	Hosts []string
+	// Clients are the common names of the client certificates
+	Clients  []string
+	Validity time.Duration
+}
+
+// Cert is a certificate with its private key
+type Cert struct {
+	Name        string
+	Certificate *x509.Certificate
+	Key         *ecdsa.PrivateKey
+	CertPEM     []byte
+	KeyPEM      []byte
+}
+
+// TLSCertificate returns the certificate for a tls.Config
+func (c *Cert) TLSCertificate() (tls.Certificate, error) {
+	return tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+}
+
+// Bundle is a CA with the server and client certificates it signed
+type Bundle struct {
+	CA      *Cert
+	Server  *Cert
+	Clients []*Cert
+}
+
+// Generate creates a CA, a server certificate for opts.Hosts and one client
+// certificate per name in opts.Clients
+func Generate(opts Options) (*Bundle, error) {
+	if len(opts.Hosts) == 0 {
+		opts.Hosts = DefaultHosts
+	}
+	if len(opts.Clients) == 0 {
+		opts.Clients = []string{DefaultClient}
+	}
+	if opts.Validity <= 0 {
+		opts.Validity = DefaultValidity
+	}
+	seen := make(map[string]bool)
+	for _, name := range opts.Clients {
+		if !clientName.MatchString(name) {
+			return nil, fmt.Errorf("invalid client name %q: use letters, digits, '.', '_' and '-'", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate client name %q", name)
+		}
+		seen[name] = true
+	}
+
+	notBefore := time.Now().Add(-time.Hour) // tolerate clock skew
+	notAfter := notBefore.Add(opts.Validity)
+
+	ca, err := newCert(&x509.Certificate{
+		Subject:               pkix.Name{Organization: []string{organization}, CommonName: "TransisiDB Development CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA: %w", err)
+	}
+	ca.Name = "ca"
+
+	server := &x509.Certificate{
+		Subject:     pkix.Name{Organization: []string{organization}, CommonName: opts.Hosts[0]},
+		NotBefore:   notBefore,
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range opts.Hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			server.IPAddresses = append(server.IPAddresses, ip)
+		} else {
+			server.DNSNames = append(server.DNSNames, host)
+		}
+	}
+	bundle := &Bundle{CA: ca}
+	if bundle.Server, err = newCert(server, ca); err != nil {
+		return nil, fmt.Errorf("failed to create server certificate: %w", err)
+	}
+	bundle.Server.Name = "server"
+
+	for _, name := range opts.Clients {
+		client, err := newCert(&x509.Certificate{
+			Subject:     pkix.Name{Organization: []string{organization}, CommonName: name},
+			NotBefore:   notBefore,
+			NotAfter:    notAfter,
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ca)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client certificate %s: %w", name, err)
+		}
+		client.Name = name
+		bundle.Clients = append(bundle.Clients, client)
+	}
+	return bundle, nil
+}
+
+// Client returns the client certificate with the given name
+func (b *Bundle) Client(name string) (*Cert, bool) {
+	for _, client := range b.Clients {
+		if client.Name == name {
+			return client, true
+		}
+	}
+	return nil, false
+}
+
+// CertPool returns a pool holding the CA certificate
+func (b *Bundle) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(b.CA.Certificate)
+	return pool
+}
+
+// newCert signs template with parent, or self-signs it when parent is nil
+func newCert(template *x509.Certificate, parent *Cert) (*Cert, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.Certificate, parent.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Cert{
+		Certificate: cert,
+		Key:         key,
+		CertPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:      pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
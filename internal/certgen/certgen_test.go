@@ -0,0 +1,160 @@
+package certgen
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerate_Names(t *testing.T) {
+	bundle, err := Generate(Options{Hosts: []string{"redis.internal", "10.0.0.5"}, Clients: []string{"proxy", "api"}})
+	require.NoError(t, err)
+
+	assert.True(t, bundle.CA.Certificate.IsCA)
+	assert.Equal(t, "redis.internal", bundle.Server.Certificate.Subject.CommonName)
+	assert.Equal(t, []string{"redis.internal"}, bundle.Server.Certificate.DNSNames)
+	require.Len(t, bundle.Server.Certificate.IPAddresses, 1)
+	assert.Equal(t, "10.0.0.5", bundle.Server.Certificate.IPAddresses[0].String())
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, bundle.Server.Certificate.ExtKeyUsage)
+
+	require.Len(t, bundle.Clients, 2)
+	client, ok := bundle.Client("api")
+	require.True(t, ok)
+	assert.Equal(t, "api", client.Certificate.Subject.CommonName)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, client.Certificate.ExtKeyUsage)
+
+	// Each certificate verifies against the CA for its own use only
+	roots := bundle.CertPool()
+	_, err = bundle.Server.Certificate.Verify(x509.VerifyOptions{DNSName: "redis.internal", Roots: roots})
+	assert.NoError(t, err)
+	_, err = bundle.Server.Certificate.Verify(x509.VerifyOptions{DNSName: "other.internal", Roots: roots})
+	assert.Error(t, err)
+	_, err = client.Certificate.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	assert.NoError(t, err)
+	_, err = client.Certificate.Verify(x509.VerifyOptions{Roots: roots})
+	assert.Error(t, err, "client certificate must not serve")
+}
+
+func TestGenerate_Defaults(t *testing.T) {
+	bundle, err := Generate(Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"localhost"}, bundle.Server.Certificate.DNSNames)
+	assert.Len(t, bundle.Server.Certificate.IPAddresses, 2)
+	_, ok := bundle.Client(DefaultClient)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(DefaultValidity), bundle.Server.Certificate.NotAfter, 2*time.Hour)
+}
+
+func TestGenerate_InvalidClient(t *testing.T) {
+	_, err := Generate(Options{Clients: []string{"../proxy"}})
+	assert.ErrorContains(t, err, "invalid client name")
+
+	_, err = Generate(Options{Clients: []string{"proxy", "proxy"}})
+	assert.ErrorContains(t, err, "duplicate client name")
+}
+
+func TestGenerate_MutualTLS(t *testing.T) {
+	bundle, err := Generate(Options{})
+	require.NoError(t, err)
+	serverCert, err := bundle.Server.TLSCertificate()
+	require.NoError(t, err)
+	clientCert, err := bundle.Clients[0].TLSCertificate()
+	require.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    bundle.CertPool(),
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	peers := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if tlsConn.Handshake() == nil {
+			peers <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+			io.WriteString(conn, "ok")
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+		RootCAs:      bundle.CertPool(),
+		Certificates: []tls.Certificate{clientCert},
+		ServerName:   "localhost",
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	reply, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(reply))
+	assert.Equal(t, DefaultClient, <-peers)
+
+	// Addresses in the SANs verify too
+	_, err = bundle.Server.Certificate.Verify(x509.VerifyOptions{DNSName: "127.0.0.1", Roots: bundle.CertPool()})
+	assert.NoError(t, err)
+}
+
+func TestBundle_Write(t *testing.T) {
+	bundle, err := Generate(Options{Clients: []string{"proxy"}})
+	require.NoError(t, err)
+	dir := filepath.Join(t.TempDir(), "certs")
+
+	files, err := bundle.Write(dir, false)
+	require.NoError(t, err)
+	require.Len(t, files.Clients, 1)
+	assert.Equal(t, filepath.Join(dir, "client-proxy.pem"), files.Clients[0].Cert)
+
+	info, err := os.Stat(files.CAKey)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	_, err = tls.LoadX509KeyPair(files.Clients[0].Cert, files.Clients[0].Key)
+	assert.NoError(t, err)
+
+	var sample struct {
+		Redis struct {
+			TLS struct {
+				Enabled    bool   `yaml:"enabled"`
+				CAFile     string `yaml:"ca_file"`
+				CertFile   string `yaml:"cert_file"`
+				ServerName string `yaml:"server_name"`
+			} `yaml:"tls"`
+		} `yaml:"redis"`
+		Scheduler struct {
+			Jobs []struct {
+				Type         string   `yaml:"type"`
+				Certificates []string `yaml:"certificates"`
+			} `yaml:"jobs"`
+		} `yaml:"scheduler"`
+	}
+	data, err := os.ReadFile(files.Config)
+	require.NoError(t, err)
+	require.NoError(t, yaml.Unmarshal(data, &sample))
+	assert.True(t, sample.Redis.TLS.Enabled)
+	assert.Equal(t, files.CA, sample.Redis.TLS.CAFile)
+	assert.Equal(t, files.Clients[0].Cert, sample.Redis.TLS.CertFile)
+	assert.Equal(t, "localhost", sample.Redis.TLS.ServerName)
+	require.Len(t, sample.Scheduler.Jobs, 1)
+	assert.Equal(t, "cert_expiry", sample.Scheduler.Jobs[0].Type)
+	assert.Len(t, sample.Scheduler.Jobs[0].Certificates, 3)
+
+	// A second run keeps the existing CA unless asked to replace it
+	_, err = bundle.Write(dir, false)
+	assert.ErrorContains(t, err, "already exists")
+	_, err = bundle.Write(dir, true)
+	assert.NoError(t, err)
+}
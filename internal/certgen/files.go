@@ -0,0 +1,176 @@
+package certgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SampleConfigFile is the name of the configuration snippet written with
+// the certificates
+const SampleConfigFile = "transisidb-tls.yaml"
+
+// Files are the paths a bundle was written to
+type Files struct {
+	CA         string
+	CAKey      string
+	ServerCert string
+	ServerKey  string
+	Clients    []ClientFiles
+	Config     string
+}
+
+// ClientFiles are the paths of one client certificate
+type ClientFiles struct {
+	Name string
+	Cert string
+	Key  string
+}
+
+// pemFile is a file Write creates
+type pemFile struct {
+	path string
+	data []byte
+	perm os.FileMode
+}
+
+// Write stores the bundle in dir as PEM files with a sample configuration
+// using them. Existing files are kept unless overwrite is set. Keys are only
+// readable by the owner.
+func (b *Bundle) Write(dir string, overwrite bool) (Files, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Files{}, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Files{}, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	files := Files{
+		CA:         filepath.Join(dir, "ca.pem"),
+		CAKey:      filepath.Join(dir, "ca-key.pem"),
+		ServerCert: filepath.Join(dir, "server.pem"),
+		ServerKey:  filepath.Join(dir, "server-key.pem"),
+		Config:     filepath.Join(dir, SampleConfigFile),
+	}
+	writes := []pemFile{
+		{files.CA, b.CA.CertPEM, 0o644},
+		{files.CAKey, b.CA.KeyPEM, 0o600},
+		{files.ServerCert, b.Server.CertPEM, 0o644},
+		{files.ServerKey, b.Server.KeyPEM, 0o600},
+	}
+	for _, client := range b.Clients {
+		paths := ClientFiles{
+			Name: client.Name,
+			Cert: filepath.Join(dir, "client-"+client.Name+".pem"),
+			Key:  filepath.Join(dir, "client-"+client.Name+"-key.pem"),
+		}
+		files.Clients = append(files.Clients, paths)
+		writes = append(writes,
+			pemFile{paths.Cert, client.CertPEM, 0o644},
+			pemFile{paths.Key, client.KeyPEM, 0o600})
+	}
+
+	sample, err := b.SampleConfig(files)
+	if err != nil {
+		return Files{}, err
+	}
+	writes = append(writes, pemFile{files.Config, sample, 0o644})
+
+	if !overwrite {
+		for _, w := range writes {
+			if _, err := os.Stat(w.path); err == nil {
+				return Files{}, fmt.Errorf("%s already exists", w.path)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return Files{}, err
+			}
+		}
+	}
+	for _, w := range writes {
+		if err := os.WriteFile(w.path, w.data, w.perm); err != nil {
+			return Files{}, fmt.Errorf("failed to write %s: %w", w.path, err)
+		}
+		// WriteFile keeps the mode of an existing file
+		if err := os.Chmod(w.path, w.perm); err != nil {
+			return Files{}, err
+		}
+	}
+	return files, nil
+}
+
+// sampleConfig is the part of the configuration that uses the certificates
+type sampleConfig struct {
+	Redis struct {
+		TLS struct {
+			Enabled    bool   `yaml:"enabled"`
+			CAFile     string `yaml:"ca_file"`
+			CertFile   string `yaml:"cert_file"`
+			KeyFile    string `yaml:"key_file"`
+			ServerName string `yaml:"server_name"`
+		} `yaml:"tls"`
+	} `yaml:"redis"`
+	Scheduler struct {
+		Enabled bool        `yaml:"enabled"`
+		Jobs    []sampleJob `yaml:"jobs"`
+	} `yaml:"scheduler"`
+}
+
+type sampleJob struct {
+	Name         string   `yaml:"name"`
+	Type         string   `yaml:"type"`
+	Schedule     string   `yaml:"schedule"`
+	Certificates []string `yaml:"certificates"`
+	WarnBefore   string   `yaml:"warn_before"`
+}
+
+// SampleConfig returns a configuration snippet that connects to Redis over
+// mutual TLS with the first client certificate and watches the expiry of
+// every certificate in files
+func (b *Bundle) SampleConfig(files Files) ([]byte, error) {
+	var sample sampleConfig
+	sample.Redis.TLS.Enabled = true
+	sample.Redis.TLS.CAFile = files.CA
+	if len(files.Clients) > 0 {
+		sample.Redis.TLS.CertFile = files.Clients[0].Cert
+		sample.Redis.TLS.KeyFile = files.Clients[0].Key
+	}
+	sample.Redis.TLS.ServerName = b.Server.Certificate.Subject.CommonName
+
+	certificates := []string{files.CA, files.ServerCert}
+	for _, client := range files.Clients {
+		certificates = append(certificates, client.Cert)
+	}
+	sample.Scheduler.Enabled = true
+	sample.Scheduler.Jobs = []sampleJob{{
+		Name:         "dev-cert-expiry",
+		Type:         "cert_expiry",
+		Schedule:     "@daily 03:00",
+		Certificates: certificates,
+		WarnBefore:   "720h",
+	}}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Development certificates, generated %s. Merge into config.yaml.\n",
+		b.CA.Certificate.NotBefore.UTC().Format("2006-01-02"))
+	fmt.Fprintf(&buf, "# Start Redis with the server certificate:\n#   %s\n", RedisServerCommand(files))
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(sample); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RedisServerCommand returns a redis-server command line that serves TLS
+// with the server certificate and requires client certificates from the CA
+func RedisServerCommand(files Files) string {
+	return fmt.Sprintf("redis-server --port 0 --tls-port 6379 --tls-cert-file %s --tls-key-file %s --tls-ca-cert-file %s --tls-auth-clients yes",
+		files.ServerCert, files.ServerKey, files.CA)
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditConfig configures the signing of the audit log
+type AuditConfig struct {
+	// HMACKey signs every audit entry. Without it entries are only chained
+	// by hash, which detects changes but not entries forged by someone with
+	// write access to Redis. It is read from the config file only.
+	HMACKey string `yaml:"hmac_key" json:"-"`
+}
+
+// minAuditKeyLength is the shortest HMAC key accepted
+const minAuditKeyLength = 16
+
+func (a AuditConfig) validate() error {
+	if a.HMACKey != "" && len(a.HMACKey) < minAuditKeyLength {
+		return fmt.Errorf("audit hmac_key must be at least %d characters", minAuditKeyLength)
+	}
+	return nil
+}
+
+// AuditHead is the newest entry of the audit log, kept apart from the log so
+// that removing its newest entries is detected
+type AuditHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// chain links the entry to the previous one and signs it with key, if set
+func (e *AuditEntry) chain(prev AuditHead, key []byte) {
+	e.Time = e.Time.UTC()
+	e.Seq = prev.Seq + 1
+	e.PrevHash = prev.Hash
+	e.Hash = e.digest()
+	e.MAC = ""
+	if len(key) > 0 {
+		e.MAC = sign(key, e.Hash)
+	}
+}
+
+// digest hashes the entry's content, sequence number and previous hash
+func (e AuditEntry) digest() string {
+	e.Hash, e.MAC = "", ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sign(key []byte, hash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AuditVerification is the result of checking the audit log
+type AuditVerification struct {
+	// Entries is the number of entries checked, Unchained the older ones
+	// written before entries were chained, which cannot be checked
+	Entries   int   `json:"entries"`
+	Unchained int   `json:"unchained"`
+	FirstSeq  int64 `json:"first_seq"`
+	LastSeq   int64 `json:"last_seq"`
+	// Signed is set when the signatures were checked with a key
+	Signed   bool     `json:"signed"`
+	Problems []string `json:"problems"`
+}
+
+// OK reports whether the log is intact
+func (v AuditVerification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// VerifyAudit checks the audit log, newest entry first as LoadAudit returns
+// it, against its head. Every chained entry must hash to its recorded hash,
+// follow the previous one and, when key is set, carry a valid signature. The
+// newest entry must be the head, and the oldest may only be past the first
+// when the log is full and older entries were rotated out.
+func VerifyAudit(entries []AuditEntry, head *AuditHead, key []byte) AuditVerification {
+	v := AuditVerification{Signed: len(key) > 0, Problems: []string{}}
+	problem := func(format string, args ...interface{}) {
+		v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+	}
+
+	var prev *AuditEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		v.Entries++
+		if entry.Seq == 0 {
+			if prev != nil {
+				problem("an unchained entry at %s follows entry %d", entry.Time.Format(time.RFC3339), prev.Seq)
+			}
+			v.Unchained++
+			continue
+		}
+
+		if entry.digest() != entry.Hash {
+			problem("entry %d was modified: its content does not match its hash", entry.Seq)
+		}
+		switch {
+		case !v.Signed:
+		case entry.MAC == "":
+			problem("entry %d is not signed", entry.Seq)
+		case !hmac.Equal([]byte(entry.MAC), []byte(sign(key, entry.Hash))):
+			problem("entry %d has an invalid signature", entry.Seq)
+		}
+
+		if prev == nil {
+			v.FirstSeq = entry.Seq
+			if entry.Seq > 1 && len(entries) < auditLogSize {
+				problem("entries before %d are missing", entry.Seq)
+			}
+		} else {
+			switch {
+			case entry.Seq == prev.Seq+2:
+				problem("entry %d is missing", prev.Seq+1)
+			case entry.Seq != prev.Seq+1:
+				problem("entries %d to %d are missing", prev.Seq+1, entry.Seq-1)
+			case entry.PrevHash != prev.Hash:
+				problem("entry %d does not follow entry %d", entry.Seq, prev.Seq)
+			}
+		}
+		v.LastSeq = entry.Seq
+		prev = &entries[i]
+	}
+
+	switch {
+	case head == nil && prev != nil:
+		problem("the log head is missing")
+	case head != nil && prev == nil:
+		problem("the log is empty but its head is entry %d", head.Seq)
+	case head != nil && (head.Seq != prev.Seq || head.Hash != prev.Hash):
+		if head.Seq > prev.Seq {
+			problem("the log was truncated: the newest entry is %d but the head is %d", prev.Seq, head.Seq)
+		} else {
+			problem("the newest entry %d does not match the head %d", prev.Seq, head.Seq)
+		}
+	}
+	return v
+}
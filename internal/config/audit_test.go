@@ -0,0 +1,102 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chainEntries builds a signed log of n entries, newest first like LoadAudit
+func chainEntries(n int, key []byte) ([]AuditEntry, *AuditHead) {
+	var head AuditHead
+	entries := make([]AuditEntry, n)
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		entry := AuditEntry{Time: start.Add(time.Duration(i) * time.Minute), Actor: "api:10.0.0.5", Action: "disable_table", Target: "orders"}
+		entry.chain(head, key)
+		head = AuditHead{Seq: entry.Seq, Hash: entry.Hash}
+		entries[n-1-i] = entry
+	}
+	return entries, &head
+}
+
+func TestVerifyAudit_Intact(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	entries, head := chainEntries(3, key)
+
+	v := VerifyAudit(entries, head, key)
+	assert.True(t, v.OK(), v.Problems)
+	assert.Equal(t, 3, v.Entries)
+	assert.Equal(t, int64(1), v.FirstSeq)
+	assert.Equal(t, int64(3), v.LastSeq)
+	assert.True(t, v.Signed)
+
+	// Without the key only the chain is checked
+	assert.True(t, VerifyAudit(entries, head, nil).OK())
+
+	// An empty log has no head
+	assert.True(t, VerifyAudit(nil, nil, key).OK())
+}
+
+func TestVerifyAudit_DetectsTampering(t *testing.T) {
+	key := []byte("0123456789abcdef")
+
+	entries, head := chainEntries(3, key)
+	entries[1].Target = "invoices"
+	assert.Equal(t, []string{"entry 2 was modified: its content does not match its hash"}, VerifyAudit(entries, head, key).Problems)
+
+	// Rehashing a modified entry breaks the signature and the chain
+	entries[1].Hash = entries[1].digest()
+	assert.Equal(t, []string{"entry 2 has an invalid signature", "entry 3 does not follow entry 2"}, VerifyAudit(entries, head, key).Problems)
+
+	// An entry forged without the key is unsigned
+	entries, head = chainEntries(3, key)
+	forged := AuditEntry{Time: time.Now(), Actor: "api:10.0.0.9", Action: "enable_table", Target: "orders"}
+	forged.chain(*head, nil)
+	entries = append([]AuditEntry{forged}, entries...)
+	assert.Equal(t, []string{"entry 4 is not signed", "the newest entry 4 does not match the head 3"},
+		VerifyAudit(entries, head, key).Problems)
+
+	// Removing entries from the middle
+	entries, head = chainEntries(5, key)
+	assert.Equal(t, []string{"entry 4 is missing"}, VerifyAudit(append(entries[:1:1], entries[2:]...), head, key).Problems)
+	assert.Equal(t, []string{"entries 2 to 3 are missing"}, VerifyAudit(append(entries[:2:2], entries[4:]...), head, key).Problems)
+}
+
+func TestVerifyAudit_DetectsTruncation(t *testing.T) {
+	key := []byte("0123456789abcdef")
+
+	entries, head := chainEntries(3, key)
+	assert.Equal(t, []string{"the log was truncated: the newest entry is 2 but the head is 3"},
+		VerifyAudit(entries[1:], head, key).Problems)
+
+	assert.Equal(t, []string{"entries before 2 are missing"}, VerifyAudit(entries[:2], head, key).Problems)
+	assert.Equal(t, []string{"the log head is missing"}, VerifyAudit(entries, nil, key).Problems)
+	assert.Equal(t, []string{"the log is empty but its head is entry 3"}, VerifyAudit(nil, head, key).Problems)
+}
+
+func TestVerifyAudit_UnchainedEntries(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	entries, head := chainEntries(2, key)
+	legacy := AuditEntry{Time: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), Action: "enable_table", Target: "orders"}
+
+	v := VerifyAudit(append(entries, legacy), head, key)
+	assert.True(t, v.OK(), v.Problems)
+	assert.Equal(t, 1, v.Unchained)
+
+	v = VerifyAudit(append([]AuditEntry{legacy}, entries...), head, key)
+	assert.Contains(t, v.Problems, "an unchained entry at 2026-10-01T00:00:00Z follows entry 2")
+}
+
+func TestValidate_AuditKey(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	cfg.Audit.HMACKey = "short"
+	assert.ErrorContains(t, cfg.Validate(), "audit hmac_key must be at least 16 characters")
+	cfg.Audit.HMACKey = "0123456789abcdef"
+	assert.NoError(t, cfg.Validate())
+}
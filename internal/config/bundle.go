@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BundleVersion is the format version of exported configuration bundles
+const BundleVersion = 1
+
+// redactedValue replaces secrets in redacted bundles
+const redactedValue = "REDACTED"
+
+// Bundle is the configuration of an environment as exported by the API: the
+// stored config with the tables and query rules currently in Redis
+type Bundle struct {
+	Version    int       `yaml:"version" json:"version"`
+	ExportedAt time.Time `yaml:"exported_at" json:"exported_at"`
+	// Redacted bundles have their passwords and API keys replaced
+	Redacted bool   `yaml:"redacted" json:"redacted"`
+	Config   Config `yaml:"config" json:"config"`
+}
+
+// NewBundle returns a bundle of cfg, optionally without secrets
+func NewBundle(cfg *Config, redact bool) *Bundle {
+	b := &Bundle{Version: BundleVersion, ExportedAt: time.Now().UTC(), Redacted: redact, Config: *cfg}
+	if redact {
+		for _, secret := range []*string{&b.Config.Database.Password, &b.Config.Redis.Password,
+			&b.Config.API.APIKey, &b.Config.API.AdminKey, &b.Config.Canary.Password, &b.Config.Audit.HMACKey} {
+			if *secret != "" {
+				*secret = redactedValue
+			}
+		}
+		b.Config.Database.EgressProxy = b.Config.Database.EgressProxy.redacted()
+	}
+	return b
+}
+
+// ParseBundle reads a bundle exported as JSON or YAML
+func ParseBundle(data []byte) (*Bundle, error) {
+	var b Bundle
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &b); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON bundle: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML bundle: %w", err)
+	}
+
+	if b.Version == 0 {
+		return nil, fmt.Errorf("not a configuration bundle: version is missing")
+	}
+	if b.Version > BundleVersion {
+		return nil, fmt.Errorf("bundle version %d is newer than the supported version %d", b.Version, BundleVersion)
+	}
+	return &b, nil
+}
+
+// ConfigFor returns the configuration an import of the bundle stores. The
+// connection sections (database, redis, api and canary) are kept from current
+// unless includeConnections is set, which redacted bundles do not allow. The
+// audit key is always kept.
+func (b *Bundle) ConfigFor(current *Config, includeConnections bool) (*Config, error) {
+	cfg := b.Config
+	if includeConnections {
+		if b.Redacted {
+			return nil, fmt.Errorf("a redacted bundle cannot replace connection settings")
+		}
+	} else {
+		cfg.Database, cfg.Redis, cfg.API, cfg.Canary = current.Database, current.Redis, current.API, current.Canary
+	}
+	// Switching keys would break the verification of the audit log
+	cfg.Audit = current.Audit
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &cfg, nil
+}
+
+// ImportPlan lists what an import changes
+type ImportPlan struct {
+	TablesAdded   []string `json:"tables_added"`
+	TablesUpdated []string `json:"tables_updated"`
+	TablesRemoved []string `json:"tables_removed"`
+	// QueryRulesChanged is set when the rule set is replaced by a different one
+	QueryRulesChanged bool `json:"query_rules_changed"`
+	// SectionsChanged are the other top-level sections that differ
+	SectionsChanged []string `json:"sections_changed"`
+}
+
+// Empty reports whether the import changes nothing
+func (p ImportPlan) Empty() bool {
+	return len(p.TablesAdded)+len(p.TablesUpdated)+len(p.TablesRemoved)+len(p.SectionsChanged) == 0 &&
+		!p.QueryRulesChanged
+}
+
+// PlanImport compares the current configuration with the one to import
+func PlanImport(current, next *Config) ImportPlan {
+	plan := ImportPlan{TablesAdded: []string{}, TablesUpdated: []string{}, TablesRemoved: []string{}, SectionsChanged: []string{}}
+
+	for name, tableConfig := range next.Tables {
+		existing, ok := current.Tables[name]
+		switch {
+		case !ok:
+			plan.TablesAdded = append(plan.TablesAdded, name)
+		case !sameYAML(existing, tableConfig):
+			plan.TablesUpdated = append(plan.TablesUpdated, name)
+		}
+	}
+	for name := range current.Tables {
+		if _, ok := next.Tables[name]; !ok {
+			plan.TablesRemoved = append(plan.TablesRemoved, name)
+		}
+	}
+	sort.Strings(plan.TablesAdded)
+	sort.Strings(plan.TablesUpdated)
+	sort.Strings(plan.TablesRemoved)
+
+	plan.QueryRulesChanged = !sameYAML(current.QueryRules, next.QueryRules)
+
+	currentValue, nextValue := reflect.ValueOf(*current), reflect.ValueOf(*next)
+	for i := 0; i < currentValue.NumField(); i++ {
+		field := currentValue.Type().Field(i)
+		if field.Name == "Tables" || field.Name == "QueryRules" {
+			continue
+		}
+		if !sameYAML(currentValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			plan.SectionsChanged = append(plan.SectionsChanged, strings.Split(field.Tag.Get("yaml"), ",")[0])
+		}
+	}
+	return plan
+}
+
+// sameYAML compares values by their YAML encoding, which treats nil and empty
+// slices and maps alike
+func sameYAML(a, b interface{}) bool {
+	encodedA, errA := yaml.Marshal(a)
+	encodedB, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
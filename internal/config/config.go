@@ -2,7 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -20,6 +25,250 @@ type Config struct {
 	Monitoring MonitoringConfig `yaml:"monitoring"`
 	Logging    LoggingConfig    `yaml:"logging"`
 	Tables     TablesConfig     `yaml:"tables"`
+	QueryRules []QueryRule      `yaml:"query_rules"`
+	Schema     SchemaConfig     `yaml:"schema"`
+	Canary     CanaryConfig     `yaml:"canary"`
+	Outbox     OutboxConfig     `yaml:"outbox"`
+	Scheduler  SchedulerConfig  `yaml:"scheduler"`
+	Audit      AuditConfig      `yaml:"audit"`
+}
+
+// SchedulerConfig defines recurring maintenance jobs run by the API server.
+// Jobs can also be added through the API; those are stored in Redis.
+type SchedulerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// History is how many runs are kept per job (default 20)
+	History int         `yaml:"history"`
+	Jobs    []JobConfig `yaml:"jobs"`
+}
+
+// JobConfig is a scheduled job. Schedule is "@every <duration>", "@hourly",
+// "@daily" or "@daily HH:MM", in UTC.
+type JobConfig struct {
+	Name     string `yaml:"name" json:"name"`
+	Type     string `yaml:"type" json:"type"`
+	Schedule string `yaml:"schedule" json:"schedule"`
+	// Tables limits a reconcile job; empty checks all enabled tables
+	Tables []string `yaml:"tables" json:"tables,omitempty"`
+	// Certificates are the PEM files a cert_expiry job checks
+	Certificates []string `yaml:"certificates" json:"certificates,omitempty"`
+	// WarnBefore fails a cert_expiry job when a certificate expires within it
+	// (default 336h)
+	WarnBefore time.Duration `yaml:"warn_before" json:"warn_before,omitempty"`
+	// Path is the directory a config_backup job writes to
+	Path string `yaml:"path" json:"path,omitempty"`
+	// Keep is how many backups a config_backup job keeps (default 7)
+	Keep int `yaml:"keep" json:"keep,omitempty"`
+	// Extract is the external data a warehouse_reconcile job compares with
+	Extract *ExtractConfig `yaml:"extract" json:"extract,omitempty"`
+}
+
+// ExtractConfig is an extract of one currency column from an external system,
+// such as a data warehouse, read by a warehouse_reconcile job
+type ExtractConfig struct {
+	// Table and Column name the configured currency column compared
+	Table  string `yaml:"table" json:"table"`
+	Column string `yaml:"column" json:"column"`
+	// Format is csv, for a file with a header row, or sql, for a query run
+	// over the MySQL protocol
+	Format string `yaml:"format" json:"format"`
+	// Path is the CSV file
+	Path string `yaml:"path" json:"path,omitempty"`
+	// IDField and AmountField are the CSV header names of the row id and the
+	// amount (default id and amount)
+	IDField     string `yaml:"id_field" json:"id_field,omitempty"`
+	AmountField string `yaml:"amount_field" json:"amount_field,omitempty"`
+	// DSN and Query select the row id and the amount, in that order
+	DSN   string `yaml:"dsn" json:"dsn,omitempty"`
+	Query string `yaml:"query" json:"query,omitempty"`
+	// Unit is idn (default) when the extract holds converted amounts, or idr
+	// when it holds source amounts
+	Unit string `yaml:"unit" json:"unit,omitempty"`
+}
+
+// Extract formats and units
+const (
+	ExtractFormatCSV = "csv"
+	ExtractFormatSQL = "sql"
+	ExtractUnitIDN   = "idn"
+	ExtractUnitIDR   = "idr"
+)
+
+// validate checks that the extract names a column and can be read
+func (e *ExtractConfig) validate() error {
+	if e == nil {
+		return fmt.Errorf("warehouse_reconcile jobs need an extract")
+	}
+	if e.Table == "" || e.Column == "" {
+		return fmt.Errorf("extract table and column are required")
+	}
+	switch e.Format {
+	case ExtractFormatCSV:
+		if e.Path == "" {
+			return fmt.Errorf("csv extracts need a path")
+		}
+	case ExtractFormatSQL:
+		if e.DSN == "" || e.Query == "" {
+			return fmt.Errorf("sql extracts need a dsn and a query")
+		}
+	default:
+		return fmt.Errorf("invalid extract format: %s", e.Format)
+	}
+	switch e.Unit {
+	case "", ExtractUnitIDN, ExtractUnitIDR:
+	default:
+		return fmt.Errorf("invalid extract unit: %s", e.Unit)
+	}
+	return nil
+}
+
+// Job types
+const (
+	JobTypeReconcile    = "reconcile"
+	JobTypeCertExpiry   = "cert_expiry"
+	JobTypeConfigBackup = "config_backup"
+
+	// JobTypeWarehouseReconcile compares converted values with an extract
+	// from a downstream system
+	JobTypeWarehouseReconcile = "warehouse_reconcile"
+)
+
+// Scheduler defaults
+const (
+	DefaultJobHistory     = 20
+	DefaultCertWarnBefore = 14 * 24 * time.Hour
+	DefaultBackupKeep     = 7
+)
+
+// Validate checks the job's name, type and type-specific settings; the
+// schedule is parsed by the scheduler
+func (j JobConfig) Validate() error {
+	if j.Name == "" {
+		return fmt.Errorf("job name is required")
+	}
+	if j.Schedule == "" {
+		return fmt.Errorf("job %s: schedule is required", j.Name)
+	}
+	if j.WarnBefore < 0 || j.Keep < 0 {
+		return fmt.Errorf("job %s: warn_before and keep must not be negative", j.Name)
+	}
+	switch j.Type {
+	case JobTypeReconcile:
+	case JobTypeCertExpiry:
+		if len(j.Certificates) == 0 {
+			return fmt.Errorf("job %s: cert_expiry jobs need certificates", j.Name)
+		}
+	case JobTypeConfigBackup:
+		if j.Path == "" {
+			return fmt.Errorf("job %s: config_backup jobs need a path", j.Name)
+		}
+	case JobTypeWarehouseReconcile:
+		if err := j.Extract.validate(); err != nil {
+			return fmt.Errorf("job %s: %w", j.Name, err)
+		}
+	default:
+		return fmt.Errorf("job %s: invalid type: %s", j.Name, j.Type)
+	}
+	return nil
+}
+
+// validate checks the configured jobs and that their names are unique
+func (s SchedulerConfig) validate() error {
+	if s.History < 0 {
+		return fmt.Errorf("scheduler history must not be negative")
+	}
+	seen := make(map[string]bool, len(s.Jobs))
+	for _, job := range s.Jobs {
+		if err := job.Validate(); err != nil {
+			return fmt.Errorf("scheduler: %w", err)
+		}
+		if seen[job.Name] {
+			return fmt.Errorf("scheduler: duplicate job name: %s", job.Name)
+		}
+		seen[job.Name] = true
+	}
+	return nil
+}
+
+// OutboxConfig controls the Redis stream that carries shadow column updates
+// of tables with write_mode async to the outbox workers
+type OutboxConfig struct {
+	Stream    string `yaml:"stream"`     // default transisidb:outbox
+	Group     string `yaml:"group"`      // consumer group, default transisidb-outbox
+	Workers   int    `yaml:"workers"`    // default 2
+	BatchSize int    `yaml:"batch_size"` // tasks read at once, default 100
+	// MaxLen caps the stream length; the oldest tasks are trimmed beyond it
+	// (default 1000000)
+	MaxLen int64 `yaml:"max_len"`
+	// ClaimIdle is how long a task stays with a worker that did not
+	// acknowledge it before another worker retries it (default 1m)
+	ClaimIdle time.Duration `yaml:"claim_idle"`
+}
+
+// Outbox defaults
+const (
+	DefaultOutboxStream    = "transisidb:outbox"
+	DefaultOutboxGroup     = "transisidb-outbox"
+	DefaultOutboxWorkers   = 2
+	DefaultOutboxBatchSize = 100
+	DefaultOutboxMaxLen    = 1000000
+	DefaultOutboxClaimIdle = time.Minute
+)
+
+// validate checks the outbox limits
+func (o OutboxConfig) validate() error {
+	if o.Workers < 0 || o.BatchSize < 0 || o.MaxLen < 0 || o.ClaimIdle < 0 {
+		return fmt.Errorf("outbox workers, batch_size, max_len and claim_idle must not be negative")
+	}
+	return nil
+}
+
+// CanaryConfig replays rewritten statements against a second MySQL instance
+// and reports where its results differ from the primary's. Replays are
+// asynchronous and best-effort; they never delay or fail client statements.
+type CanaryConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"` // default database.database
+	// SampleRate is the share of rewritten statements replayed, 0-1 (default 1)
+	SampleRate float64       `yaml:"sample_rate"`
+	Workers    int           `yaml:"workers"`    // default 2
+	QueueSize  int           `yaml:"queue_size"` // default 1000
+	Timeout    time.Duration `yaml:"timeout"`    // default 5s
+}
+
+// Canary defaults
+const (
+	DefaultCanaryWorkers   = 2
+	DefaultCanaryQueueSize = 1000
+	DefaultCanaryTimeout   = 5 * time.Second
+)
+
+// validate checks the canary address and limits when enabled
+func (c CanaryConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Host == "" || c.Port == 0 {
+		return fmt.Errorf("canary host and port are required")
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("canary sample_rate must be between 0 and 1, got %v", c.SampleRate)
+	}
+	if c.Workers < 0 || c.QueueSize < 0 || c.Timeout < 0 {
+		return fmt.Errorf("canary workers, queue_size and timeout must not be negative")
+	}
+	return nil
+}
+
+// SchemaConfig controls the cache of table metadata read from information_schema
+type SchemaConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// RefreshInterval is how often cached databases are reloaded (default 5m)
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
 }
 
 type DatabaseConfig struct {
@@ -32,6 +281,245 @@ type DatabaseConfig struct {
 	MaxConnections    int           `yaml:"max_connections"`
 	IdleConnections   int           `yaml:"idle_connections"`
 	ConnectionTimeout time.Duration `yaml:"connection_timeout"`
+	// LowerCaseTableNames mirrors the server's lower_case_table_names:
+	// 0 compares table names case-sensitively, 1 and 2 case-insensitively
+	LowerCaseTableNames int `yaml:"lower_case_table_names"`
+	// Socket is the MySQL Unix socket path; when set it is used instead of host/port
+	Socket string `yaml:"socket"`
+	// TCP tunes the proxy's connections to the backend
+	TCP TCPConfig `yaml:"tcp"`
+	// EgressProxy routes backend connections through a SOCKS5 or HTTP CONNECT proxy
+	EgressProxy EgressProxyConfig `yaml:"egress_proxy"`
+	// Replicas are role-tagged backends that proxy.routes can send sessions to
+	Replicas []ReplicaConfig `yaml:"replicas"`
+	// Failover lists warm standbys the proxy follows when the primary fails
+	Failover FailoverConfig `yaml:"failover"`
+	// Discovery resolves the primary's address instead of host/port
+	Discovery DiscoveryConfig `yaml:"discovery"`
+}
+
+// Discovery types
+const (
+	DiscoveryDNSSRV = "dns_srv"
+	DiscoveryConsul = "consul"
+)
+
+// DefaultDiscoveryRefreshInterval is used when discovery.refresh_interval is not set
+const DefaultDiscoveryRefreshInterval = 30 * time.Second
+
+// DiscoveryConfig resolves backend endpoints from a service registry and
+// refreshes them periodically
+type DiscoveryConfig struct {
+	// Type is dns_srv or consul; empty disables discovery
+	Type string `yaml:"type"`
+	// Name is the SRV record (e.g. _mysql._tcp.db.internal) or Consul service
+	Name string `yaml:"name"`
+	// ConsulAddress is the Consul HTTP API (default http://127.0.0.1:8500)
+	ConsulAddress string `yaml:"consul_address"`
+	// Tag keeps only Consul instances with this tag
+	Tag             string        `yaml:"tag"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// Enabled reports whether discovery is configured
+func (d DiscoveryConfig) Enabled() bool {
+	return d.Type != ""
+}
+
+// validate checks the discovery type and name
+func (d DiscoveryConfig) validate() error {
+	switch d.Type {
+	case "":
+		return nil
+	case DiscoveryDNSSRV, DiscoveryConsul:
+	default:
+		return fmt.Errorf("invalid discovery type: %s", d.Type)
+	}
+	if d.Name == "" {
+		return fmt.Errorf("discovery name is required")
+	}
+	if d.RefreshInterval < 0 {
+		return fmt.Errorf("discovery refresh_interval must not be negative")
+	}
+	return nil
+}
+
+// Failover defaults
+const (
+	DefaultFailoverProbeInterval    = 5 * time.Second
+	DefaultFailoverProbeTimeout     = 2 * time.Second
+	DefaultFailoverFailureThreshold = 3
+)
+
+// FailoverConfig makes the proxy follow a primary failover. The current
+// primary is probed every probe interval; after failure_threshold failed
+// probes new sessions go to the first standby that accepts writes
+// (read_only=0). There is no automatic failback.
+type FailoverConfig struct {
+	Standbys         []StandbyConfig `yaml:"standbys"`
+	ProbeInterval    time.Duration   `yaml:"probe_interval"`
+	ProbeTimeout     time.Duration   `yaml:"probe_timeout"`
+	FailureThreshold int             `yaml:"failure_threshold"`
+}
+
+// StandbyConfig is a primary candidate. It shares credentials and the
+// default database with the primary.
+type StandbyConfig struct {
+	Name   string `yaml:"name"`
+	Host   string `yaml:"host"`
+	Port   int    `yaml:"port"`
+	Socket string `yaml:"socket"`
+}
+
+// Enabled reports whether any standbys are configured
+func (f FailoverConfig) Enabled() bool {
+	return len(f.Standbys) > 0
+}
+
+// validate checks standby names and addresses and the probe settings
+func (f FailoverConfig) validate() error {
+	names := map[string]bool{"primary": true}
+	for i, sb := range f.Standbys {
+		if sb.Name == "" {
+			return fmt.Errorf("failover standby %d: name is required", i)
+		}
+		if names[sb.Name] {
+			return fmt.Errorf("failover standby %s: duplicate or reserved name", sb.Name)
+		}
+		names[sb.Name] = true
+		if sb.Socket == "" && (sb.Host == "" || sb.Port == 0) {
+			return fmt.Errorf("failover standby %s: host and port or socket is required", sb.Name)
+		}
+	}
+	if f.ProbeInterval < 0 || f.ProbeTimeout < 0 || f.FailureThreshold < 0 {
+		return fmt.Errorf("failover probe settings must not be negative")
+	}
+	return nil
+}
+
+// ForStandby returns a copy of c whose database address points at the standby
+func (c *Config) ForStandby(sb StandbyConfig) *Config {
+	cfg := *c
+	cfg.Database.Host = sb.Host
+	cfg.Database.Port = sb.Port
+	cfg.Database.Socket = sb.Socket
+	cfg.Database.Failover = FailoverConfig{}
+	cfg.Database.Discovery = DiscoveryConfig{}
+	return &cfg
+}
+
+// ReplicaConfig is a replica backend. It shares credentials and the default
+// database with the primary.
+type ReplicaConfig struct {
+	Name   string `yaml:"name"`
+	Host   string `yaml:"host"`
+	Port   int    `yaml:"port"`
+	Socket string `yaml:"socket"`
+	// Role groups replicas for routing, e.g. "oltp" or "analytics"
+	Role string `yaml:"role"`
+	// Discovery resolves the replicas of this entry instead of host/port;
+	// every resolved endpoint joins the role
+	Discovery DiscoveryConfig `yaml:"discovery"`
+}
+
+// validateReplicas checks replica names, roles and addresses
+func (d DatabaseConfig) validateReplicas() error {
+	names := make(map[string]bool)
+	for i, r := range d.Replicas {
+		if r.Name == "" {
+			return fmt.Errorf("replica %d: name is required", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("replica %s: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+
+		if r.Role == "" {
+			return fmt.Errorf("replica %s: role is required", r.Name)
+		}
+		if err := r.Discovery.validate(); err != nil {
+			return fmt.Errorf("replica %s: %w", r.Name, err)
+		}
+		if !r.Discovery.Enabled() && r.Socket == "" && (r.Host == "" || r.Port == 0) {
+			return fmt.Errorf("replica %s: host and port, socket or discovery is required", r.Name)
+		}
+	}
+	return nil
+}
+
+// ReplicaRoles returns the set of roles with at least one replica
+func (d DatabaseConfig) ReplicaRoles() map[string]bool {
+	roles := make(map[string]bool)
+	for _, r := range d.Replicas {
+		roles[r.Role] = true
+	}
+	return roles
+}
+
+// Backend names the primary for logs and backfill concurrency caps: its
+// socket, discovered service or address
+func (d DatabaseConfig) Backend() string {
+	switch {
+	case d.Socket != "":
+		return "unix:" + d.Socket
+	case d.Discovery.Enabled():
+		return d.Discovery.Type + ":" + d.Discovery.Name
+	default:
+		return fmt.Sprintf("%s:%d", d.Host, d.Port)
+	}
+}
+
+// ForReplica returns a copy of c whose database address points at the replica
+func (c *Config) ForReplica(r ReplicaConfig) *Config {
+	cfg := *c
+	cfg.Database.Host = r.Host
+	cfg.Database.Port = r.Port
+	cfg.Database.Socket = r.Socket
+	cfg.Database.Replicas = nil
+	cfg.Database.Discovery = DiscoveryConfig{}
+	return &cfg
+}
+
+// TCPConfig holds socket tuning options for client or backend connections
+type TCPConfig struct {
+	// KeepAlivePeriod is the TCP keep-alive interval (0 uses 30s, negative disables)
+	KeepAlivePeriod time.Duration `yaml:"keepalive_period"`
+	// NoDelay sets TCP_NODELAY (defaults to true)
+	NoDelay *bool `yaml:"no_delay"`
+	// ReadBufferSize and WriteBufferSize set SO_RCVBUF/SO_SNDBUF (0 keeps the OS default)
+	ReadBufferSize  int `yaml:"read_buffer_size"`
+	WriteBufferSize int `yaml:"write_buffer_size"`
+	// BindInterface binds sockets to a network interface (SO_BINDTODEVICE, Linux only)
+	BindInterface string `yaml:"bind_interface"`
+}
+
+// Validate checks the TCP options
+func (t TCPConfig) Validate() error {
+	if t.ReadBufferSize < 0 || t.WriteBufferSize < 0 {
+		return fmt.Errorf("tcp buffer sizes must not be negative")
+	}
+	return nil
+}
+
+// AcceptConfig limits how fast client connections are taken on. Connections
+// beyond a limit are reset at once, so clients retry elsewhere instead of
+// queueing.
+type AcceptConfig struct {
+	// Rate is the number of connections each listener accepts per second, 0
+	// for no limit. Burst is how many it accepts at once (default the rate).
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+	// MaxPending is the number of accepted connections still in the
+	// handshake beyond which new ones are reset, 0 for no limit
+	MaxPending int `yaml:"max_pending"`
+}
+
+// Validate checks the accept limits
+func (a AcceptConfig) Validate() error {
+	if a.Rate < 0 || a.Burst < 0 || a.MaxPending < 0 {
+		return fmt.Errorf("accept rate, burst and max_pending must not be negative")
+	}
+	return nil
 }
 
 type ProxyConfig struct {
@@ -41,26 +529,530 @@ type ProxyConfig struct {
 	MaxConnectionsPerHost int           `yaml:"max_connections_per_host"`
 	ReadTimeout           time.Duration `yaml:"read_timeout"`
 	WriteTimeout          time.Duration `yaml:"write_timeout"`
+	// Socket is an optional Unix socket path clients can connect to, served
+	// alongside host/port (port may be 0 to listen on the socket only)
+	Socket string `yaml:"socket"`
+	// TCP tunes the listener and accepted client connections
+	TCP TCPConfig `yaml:"tcp"`
+	// Accept guards the client listeners against connection storms
+	Accept AcceptConfig `yaml:"accept"`
+	// Listeners are additional named endpoints with their own behaviour
+	Listeners []ListenerConfig `yaml:"listeners"`
+	// Routes send matching sessions to a replica role instead of the primary
+	Routes []RouteConfig `yaml:"routes"`
+	// Limits bound the results and execution time of matching statements
+	Limits []LimitConfig `yaml:"limits"`
+	// PoolHealthCheck controls how pooled backend connections are checked
+	// before they are handed to a session
+	PoolHealthCheck PoolHealthCheckConfig `yaml:"pool_health_check"`
+	// MaxConnIdleTime and MaxConnLifetime bound how long pooled backend
+	// connections are kept; keep them below the server's wait_timeout.
+	// CleanupInterval is how often idle connections are checked. All three
+	// apply to running pools on config reload.
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time"` // default 5m
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime"`  // default 30m
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`   // default 30s
+	// AdminUsers are the client users allowed to change proxy state through
+	// the virtual transisidb schema, e.g. to disable dual-write for a table
+	AdminUsers []string `yaml:"admin_users"`
+	// TableToggleInterval bounds how long a table enabled/disabled through
+	// the API takes to reach the proxy when a reload notification is missed
+	TableToggleInterval time.Duration `yaml:"table_toggle_interval"` // default 5s
+	// Probe runs SELECT 1 through the proxy to catch a slow backend
+	Probe ProbeConfig `yaml:"probe"`
+	// MaxParseSize is the statement length in bytes above which the SQL
+	// parser is skipped. Such writes to configured tables are forwarded
+	// without dual-write under the table's failure policy. 0 parses every
+	// statement.
+	MaxParseSize int `yaml:"max_parse_size"`
+	// IdleTimeout closes client sessions that send no command for this
+	// long, like MySQL's wait_timeout. 0 keeps idle sessions open.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// QuarantineDir is where the client packet being handled is written
+	// when a session panics. Empty only logs the panic.
+	QuarantineDir string `yaml:"quarantine_dir"`
+}
+
+// DefaultTableToggleInterval is how often table toggles are polled from Redis
+const DefaultTableToggleInterval = 5 * time.Second
+
+// IsAdminUser reports whether a client user may change proxy state
+func (p ProxyConfig) IsAdminUser(user string) bool {
+	for _, admin := range p.AdminUsers {
+		if admin == user {
+			return true
+		}
+	}
+	return false
 }
 
+// Pool connection lifetime defaults
+const (
+	DefaultMaxConnIdleTime = 5 * time.Minute
+	DefaultMaxConnLifetime = 30 * time.Minute
+	DefaultCleanupInterval = 30 * time.Second
+)
+
+// Pool health check modes
+const (
+	// HealthCheckPing sends COM_PING and expects an OK packet
+	HealthCheckPing = "ping"
+	// HealthCheckRead reads from the socket with a short deadline; a timeout
+	// means the connection is alive
+	HealthCheckRead = "read"
+	// HealthCheckNone hands out pooled connections unchecked
+	HealthCheckNone = "none"
+)
+
+// Pool health check defaults
+const (
+	DefaultPoolHealthCheckIdleThreshold = time.Second
+	DefaultPoolHealthCheckTimeout       = time.Second
+)
+
+// Backend probe defaults
+const (
+	DefaultProbeTimeout       = 2 * time.Second
+	DefaultProbeSlowThreshold = 500 * time.Millisecond
+)
+
+// ProbeConfig makes the proxy run SELECT 1 through its own listener with the
+// database credentials every Interval. The backend is reported degraded when
+// the query takes longer than SlowThreshold and unavailable when it fails.
+type ProbeConfig struct {
+	Interval      time.Duration `yaml:"interval"`       // 0 disables the probe
+	Timeout       time.Duration `yaml:"timeout"`        // default 2s
+	SlowThreshold time.Duration `yaml:"slow_threshold"` // default 500ms
+}
+
+// Enabled reports whether the backend probe runs
+func (p ProbeConfig) Enabled() bool {
+	return p.Interval > 0
+}
+
+// validate checks the probe durations
+func (p ProbeConfig) validate() error {
+	if p.Interval < 0 || p.Timeout < 0 || p.SlowThreshold < 0 {
+		return fmt.Errorf("probe durations must not be negative")
+	}
+	timeout, slow := p.Timeout, p.SlowThreshold
+	if timeout == 0 {
+		timeout = DefaultProbeTimeout
+	}
+	if slow == 0 {
+		slow = DefaultProbeSlowThreshold
+	}
+	if slow >= timeout {
+		return fmt.Errorf("probe slow_threshold %v must be below timeout %v", slow, timeout)
+	}
+	return nil
+}
+
+// PoolHealthCheckConfig controls the liveness check of pooled connections.
+// Connections idle for less than IdleThreshold are handed out unchecked;
+// connections failing the check are evicted.
+type PoolHealthCheckConfig struct {
+	Mode          string        `yaml:"mode"`           // ping (default), read or none
+	IdleThreshold time.Duration `yaml:"idle_threshold"` // default 1s
+	Timeout       time.Duration `yaml:"timeout"`        // default 1s
+}
+
+// validate checks the health check mode and durations
+func (h PoolHealthCheckConfig) validate() error {
+	switch h.Mode {
+	case "", HealthCheckPing, HealthCheckRead, HealthCheckNone:
+	default:
+		return fmt.Errorf("invalid pool health check mode: %s", h.Mode)
+	}
+	if h.IdleThreshold < 0 || h.Timeout < 0 {
+		return fmt.Errorf("pool health check durations must not be negative")
+	}
+	return nil
+}
+
+// LimitConfig bounds the statements of a user, of a table, or of a user on a
+// table; a limit without user and table applies to every statement. When
+// several limits match, the strictest value of each field applies. Zero
+// leaves a field unlimited.
+type LimitConfig struct {
+	User string `yaml:"user"`
+	// Table matches statements whose first table in FROM is this table
+	Table string `yaml:"table"`
+	// MaxRows and MaxResultBytes terminate a resultset with an error once it
+	// returns more rows or row bytes
+	MaxRows        int64 `yaml:"max_rows"`
+	MaxResultBytes int64 `yaml:"max_result_bytes"`
+	// MaxExecutionTime is passed to the server as a MAX_EXECUTION_TIME hint
+	// on SELECT statements
+	MaxExecutionTime time.Duration `yaml:"max_execution_time"`
+}
+
+// IsZero reports whether the limit leaves everything unlimited
+func (l LimitConfig) IsZero() bool {
+	return l.MaxRows == 0 && l.MaxResultBytes == 0 && l.MaxExecutionTime == 0
+}
+
+// LimitFor combines the limits matching a user and table. table may be
+// empty when the statement's table is unknown, in which case only limits
+// without a table match.
+func (p ProxyConfig) LimitFor(user, table string) LimitConfig {
+	var combined LimitConfig
+	for _, l := range p.Limits {
+		if l.User != "" && l.User != user {
+			continue
+		}
+		if l.Table != "" && (table == "" || !strings.EqualFold(l.Table, table)) {
+			continue
+		}
+		combined.MaxRows = stricter(combined.MaxRows, l.MaxRows)
+		combined.MaxResultBytes = stricter(combined.MaxResultBytes, l.MaxResultBytes)
+		combined.MaxExecutionTime = time.Duration(stricter(int64(combined.MaxExecutionTime), int64(l.MaxExecutionTime)))
+	}
+	return combined
+}
+
+// stricter returns the smaller of two limits where zero means unlimited
+func stricter(a, b int64) int64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// validateLimits checks that every limit bounds something
+func (p ProxyConfig) validateLimits() error {
+	for i, l := range p.Limits {
+		if l.MaxRows < 0 || l.MaxResultBytes < 0 || l.MaxExecutionTime < 0 {
+			return fmt.Errorf("limit %d: limits must not be negative", i)
+		}
+		if l.IsZero() {
+			return fmt.Errorf("limit %d: max_rows, max_result_bytes or max_execution_time is required", i)
+		}
+		if l.MaxExecutionTime > 0 && l.MaxExecutionTime < time.Millisecond {
+			return fmt.Errorf("limit %d: max_execution_time must be at least 1ms", i)
+		}
+	}
+	return nil
+}
+
+// RouteConfig sends sessions to the replicas of a role. A route matches when
+// every condition it sets matches; the first matching route wins. Routing is
+// decided when the client connects, before authentication, so it cannot depend
+// on the user or on individual statements.
+type RouteConfig struct {
+	Role string `yaml:"role"`
+	// Listener matches sessions accepted on the named listener ("default" for
+	// the main host/port/socket)
+	Listener string `yaml:"listener"`
+	// SourceCIDRs matches TCP clients whose address is in one of the networks
+	SourceCIDRs []string `yaml:"source_cidrs"`
+}
+
+// validateRoutes checks that routes reference known roles and listeners
+func (p ProxyConfig) validateRoutes(roles map[string]bool) error {
+	listeners := map[string]bool{"default": true}
+	for _, l := range p.Listeners {
+		listeners[l.Name] = true
+	}
+
+	for i, r := range p.Routes {
+		if !roles[r.Role] {
+			return fmt.Errorf("route %d: no replicas with role %q", i, r.Role)
+		}
+		if r.Listener == "" && len(r.SourceCIDRs) == 0 {
+			return fmt.Errorf("route %d: listener or source_cidrs is required", i)
+		}
+		if r.Listener != "" && !listeners[r.Listener] {
+			return fmt.Errorf("route %d: unknown listener %s", i, r.Listener)
+		}
+		for _, cidr := range r.SourceCIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("route %d: invalid source CIDR %s: %w", i, cidr, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ListenerConfig is an additional client endpoint served by the proxy.
+// Sessions accepted on it use its settings instead of the defaults.
+type ListenerConfig struct {
+	Name   string `yaml:"name"`
+	Host   string `yaml:"host"`
+	Port   int    `yaml:"port"`
+	Socket string `yaml:"socket"`
+	// Simulation makes the endpoint read-only: only statements that cannot
+	// modify data are forwarded, everything else is rejected
+	Simulation bool `yaml:"simulation"`
+	// FailurePolicy overrides conversion.failure_policy for its sessions
+	FailurePolicy string `yaml:"failure_policy"`
+}
+
+// validateListeners checks that listener names and addresses are unique.
+// "default" is reserved for the main proxy host/port/socket.
+func (p ProxyConfig) validateListeners() error {
+	names := make(map[string]bool)
+	addrs := make(map[string]bool)
+	if p.Port != 0 {
+		addrs[fmt.Sprintf("%s:%d", p.Host, p.Port)] = true
+	}
+	if p.Socket != "" {
+		addrs[p.Socket] = true
+	}
+
+	for i, l := range p.Listeners {
+		if l.Name == "" {
+			return fmt.Errorf("listener %d: name is required", i)
+		}
+		if names[l.Name] || l.Name == "default" {
+			return fmt.Errorf("listener %s: duplicate name", l.Name)
+		}
+		names[l.Name] = true
+
+		if l.Port == 0 && l.Socket == "" {
+			return fmt.Errorf("listener %s: port or socket is required", l.Name)
+		}
+		if l.Port != 0 {
+			addr := fmt.Sprintf("%s:%d", l.Host, l.Port)
+			if addrs[addr] {
+				return fmt.Errorf("listener %s: address %s is already in use", l.Name, addr)
+			}
+			addrs[addr] = true
+		}
+		if l.Socket != "" {
+			if addrs[l.Socket] {
+				return fmt.Errorf("listener %s: socket %s is already in use", l.Name, l.Socket)
+			}
+			addrs[l.Socket] = true
+		}
+		if !validFailurePolicy(l.FailurePolicy) {
+			return fmt.Errorf("listener %s: invalid failure policy: %s", l.Name, l.FailurePolicy)
+		}
+	}
+	return nil
+}
+
+// Redis topologies
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
 type RedisConfig struct {
+	// Mode is standalone (default, host and port), sentinel or cluster
+	Mode     string `yaml:"mode"`
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
+	Username string `yaml:"username"` // ACL user; empty uses the default user
 	Password string `yaml:"password"`
 	Database int    `yaml:"database"`
 	PoolSize int    `yaml:"pool_size"`
+	// MasterName and Sentinels (host:port) locate the master in sentinel mode
+	MasterName       string   `yaml:"master_name"`
+	Sentinels        []string `yaml:"sentinels"`
+	SentinelUsername string   `yaml:"sentinel_username"`
+	SentinelPassword string   `yaml:"sentinel_password"`
+	// Addrs are the cluster nodes (host:port) to discover the cluster from
+	Addrs []string       `yaml:"addrs"`
+	TLS   RedisTLSConfig `yaml:"tls"`
+}
+
+// RedisTLSConfig enables TLS to Redis nodes, and to sentinels in sentinel
+// mode. CertFile and KeyFile are a client certificate for mutual TLS.
+type RedisTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"` // empty uses the system roots
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// validate checks the topology settings of the configured mode
+func (r RedisConfig) validate() error {
+	switch r.Mode {
+	case "", RedisModeStandalone:
+	case RedisModeSentinel:
+		if r.MasterName == "" || len(r.Sentinels) == 0 {
+			return fmt.Errorf("sentinel mode needs master_name and sentinels")
+		}
+	case RedisModeCluster:
+		if len(r.Addrs) == 0 {
+			return fmt.Errorf("cluster mode needs addrs")
+		}
+		if r.Database != 0 {
+			return fmt.Errorf("cluster mode only has database 0")
+		}
+	default:
+		return fmt.Errorf("invalid mode: %s", r.Mode)
+	}
+	if (r.TLS.CertFile == "") != (r.TLS.KeyFile == "") {
+		return fmt.Errorf("tls cert_file and key_file must be set together")
+	}
+	return nil
 }
 
 type APIConfig struct {
 	Host   string `yaml:"host"`
 	Port   int    `yaml:"port"`
 	APIKey string `yaml:"api_key"`
+	// AdminKey authenticates requests with the admin role, which may also
+	// turn break glass on and off. Without it break glass can only be used
+	// through the proxy's virtual schema.
+	AdminKey string `yaml:"admin_key"`
+	// ProxyAdminURL is the base URL of the proxy's admin endpoint, used for
+	// state that lives in the proxy process (e.g. "http://proxy-host:9090").
+	// Defaults to localhost on monitoring.prometheus_port.
+	ProxyAdminURL string `yaml:"proxy_admin_url"`
+	// DeletedTableRetention is how long a deleted table config is kept and
+	// can be restored (default 7 days)
+	DeletedTableRetention time.Duration `yaml:"deleted_table_retention"`
+}
+
+// DefaultDeletedTableRetention is how long deleted table configs are kept
+const DefaultDeletedTableRetention = 7 * 24 * time.Hour
+
+// TableRetention returns how long deleted table configs are kept
+func (a APIConfig) TableRetention() time.Duration {
+	if a.DeletedTableRetention <= 0 {
+		return DefaultDeletedTableRetention
+	}
+	return a.DeletedTableRetention
 }
 
 type ConversionConfig struct {
 	Ratio            int    `yaml:"ratio"`
 	Precision        int    `yaml:"precision"`
 	RoundingStrategy string `yaml:"rounding_strategy"`
+	// FailurePolicy is the default for tables without their own failure_policy
+	FailurePolicy string `yaml:"failure_policy"`
+	// DDLAction controls what the proxy does when DDL adds a column that looks
+	// like a currency amount: warn (default), propose or ignore
+	DDLAction string `yaml:"ddl_action"`
+	// Guard catches amounts that look already converted
+	Guard GuardConfig `yaml:"guard"`
+	// AmountLocales lists the digit separators accepted in quoted amounts
+	// besides plain numerals: id (1.500.000,50) and en (1,500,000.50), or
+	// plain alone for none. Defaults to id and en.
+	AmountLocales []string `yaml:"amount_locales"`
+	// PlaceholderPolicy is what happens to statements with a bind placeholder
+	// where an amount belongs: reject (default) refuses them, flag forwards
+	// them without dual-write and alerts
+	PlaceholderPolicy string `yaml:"placeholder_policy"`
+}
+
+// Placeholder policies
+const (
+	PlaceholderReject = "reject"
+	PlaceholderFlag   = "flag"
+)
+
+// Amount locales
+const (
+	AmountLocalePlain = "plain"
+	AmountLocaleID    = "id"
+	AmountLocaleEN    = "en"
+)
+
+// GuardConfig catches IDN amounts written to IDR columns, which conversion
+// would divide a second time
+type GuardConfig struct {
+	// Policy is off (default), flag to log and count suspect amounts, or
+	// reject to refuse the write
+	Policy string `yaml:"policy"`
+	// MinAmount is the smallest non-zero source amount expected; amounts
+	// closer to zero look already converted. Defaults to the ratio.
+	MinAmount float64 `yaml:"min_amount"`
+}
+
+// Guard policies
+const (
+	GuardOff    = "off"
+	GuardFlag   = "flag"
+	GuardReject = "reject"
+)
+
+// validate checks the policy and floor
+func (g GuardConfig) validate() error {
+	switch g.Policy {
+	case "", GuardOff, GuardFlag, GuardReject:
+	default:
+		return fmt.Errorf("invalid guard policy: %s", g.Policy)
+	}
+	if g.MinAmount < 0 {
+		return fmt.Errorf("guard min_amount must not be negative")
+	}
+	return nil
+}
+
+// DDL actions for currency-looking columns added by DDL
+const (
+	// DDLActionWarn logs a warning
+	DDLActionWarn = "warn"
+	// DDLActionPropose logs a warning and records a shadow column proposal
+	DDLActionPropose = "propose"
+	// DDLActionIgnore does nothing
+	DDLActionIgnore = "ignore"
+)
+
+// Failure policies applied when a statement on a configured table cannot be
+// parsed, converted or rewritten
+const (
+	// FailOpen forwards the original statement (the dual-write is lost)
+	FailOpen = "fail_open"
+	// FailClosed rejects the statement with an error to the client
+	FailClosed = "fail_closed"
+	// FailOpenWithAlert forwards the original statement and raises an alert
+	FailOpenWithAlert = "fail_open_with_alert"
+)
+
+// validFailurePolicy reports whether p is a known policy (empty means inherit)
+func validFailurePolicy(p string) bool {
+	switch p {
+	case "", FailOpen, FailClosed, FailOpenWithAlert:
+		return true
+	}
+	return false
+}
+
+// ForListener returns the configuration used by sessions accepted on the
+// listener. The copy shares tables and rules with c.
+func (c *Config) ForListener(l ListenerConfig) *Config {
+	cfg := *c
+	if l.FailurePolicy != "" {
+		cfg.Conversion.FailurePolicy = l.FailurePolicy
+	}
+	return &cfg
+}
+
+// FailurePolicyFor returns the effective failure policy for a table: the
+// table's own policy, else the conversion default, else fallback. Callers pass
+// their historical behaviour as fallback so configs without a failure_policy
+// keep working as before (the proxy forwards, the orchestrator fails).
+func (c *Config) FailurePolicyFor(table, fallback string) string {
+	if tableConfig, exists := c.Tables[table]; exists && tableConfig.FailurePolicy != "" {
+		return tableConfig.FailurePolicy
+	}
+	if c.Conversion.FailurePolicy != "" {
+		return c.Conversion.FailurePolicy
+	}
+	return fallback
+}
+
+// StrictestFailurePolicy returns the table with the strictest effective failure
+// policy among the given tables (fail_closed > fail_open_with_alert > fail_open)
+func (c *Config) StrictestFailurePolicy(tables []string, fallback string) (string, string) {
+	rank := map[string]int{FailOpen: 0, FailOpenWithAlert: 1, FailClosed: 2}
+
+	var strictestTable, strictestPolicy string
+	for _, table := range tables {
+		policy := c.FailurePolicyFor(table, fallback)
+		if strictestTable == "" || rank[policy] > rank[strictestPolicy] {
+			strictestTable, strictestPolicy = table, policy
+		}
+	}
+	return strictestTable, strictestPolicy
 }
 
 type BackfillConfig struct {
@@ -70,6 +1062,53 @@ type BackfillConfig struct {
 	MaxCPUPercent   int  `yaml:"max_cpu_percent"`
 	RetryAttempts   int  `yaml:"retry_attempts"`
 	RetryBackoffMs  int  `yaml:"retry_backoff_ms"`
+	// Consistency sets where a backfill job ends: none (default) converts
+	// rows until the live table has none left, snapshot reads the batches
+	// from one REPEATABLE READ snapshot taken at job start, and cutoff
+	// converts rows whose cutoff_column is before the job start. Rows
+	// written after the boundary are left to dual-write.
+	Consistency string `yaml:"consistency"`
+	// CutoffColumn is the last-modified timestamp of each row that
+	// consistency cutoff compares (default updated_at)
+	CutoffColumn string `yaml:"cutoff_column"`
+	// Concurrency is how many jobs one backfill worker runs at once
+	// (default 1)
+	Concurrency int `yaml:"concurrency"`
+	// MaxJobs caps the jobs running at once across all workers, 0 for no cap
+	MaxJobs int `yaml:"max_jobs"`
+	// MaxJobsPerBackend caps the jobs running at once against one primary
+	// across all workers, 0 for no cap
+	MaxJobsPerBackend int `yaml:"max_jobs_per_backend"`
+}
+
+// Backfill consistency modes
+const (
+	BackfillConsistencyNone     = "none"
+	BackfillConsistencySnapshot = "snapshot"
+	BackfillConsistencyCutoff   = "cutoff"
+
+	DefaultBackfillCutoffColumn = "updated_at"
+)
+
+// WorkerConcurrency returns how many jobs one backfill worker runs at once
+func (b BackfillConfig) WorkerConcurrency() int {
+	if b.Concurrency <= 0 {
+		return 1
+	}
+	return b.Concurrency
+}
+
+// validate checks the backfill consistency mode and concurrency caps
+func (b BackfillConfig) validate() error {
+	if b.Concurrency < 0 || b.MaxJobs < 0 || b.MaxJobsPerBackend < 0 {
+		return fmt.Errorf("backfill: concurrency, max_jobs and max_jobs_per_backend must not be negative")
+	}
+	switch b.Consistency {
+	case "", BackfillConsistencyNone, BackfillConsistencySnapshot, BackfillConsistencyCutoff:
+		return nil
+	default:
+		return fmt.Errorf("backfill: invalid consistency: %s", b.Consistency)
+	}
 }
 
 type SimulationConfig struct {
@@ -83,6 +1122,15 @@ type MonitoringConfig struct {
 	MetricsPath       string `yaml:"metrics_path"`
 }
 
+// ProxyAdminURL returns the configured proxy admin URL, falling back to the
+// proxy's Prometheus port on localhost
+func (c *Config) ProxyAdminURL() string {
+	if c.API.ProxyAdminURL != "" {
+		return strings.TrimRight(c.API.ProxyAdminURL, "/")
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d", c.Monitoring.PrometheusPort)
+}
+
 type LoggingConfig struct {
 	Level  string `yaml:"level"`
 	Format string `yaml:"format"`
@@ -92,8 +1140,206 @@ type LoggingConfig struct {
 type TablesConfig map[string]TableConfig
 
 type TableConfig struct {
-	Enabled bool                     `yaml:"enabled"`
-	Columns map[string]ColumnConfig  `yaml:"columns"`
+	Enabled bool                    `yaml:"enabled"`
+	Columns map[string]ColumnConfig `yaml:"columns"`
+	// FailurePolicy overrides conversion.failure_policy for this table
+	FailurePolicy string `yaml:"failure_policy"`
+	// Rollout limits dual-write to a share of the table's statements; nil
+	// rewrites all of them
+	Rollout *RolloutConfig `yaml:"rollout"`
+	// WriteMode is sync (default) to rewrite statements with their shadow
+	// values, async to forward them untouched and fill the shadow columns
+	// from the outbox, or database to forward them untouched while triggers
+	// or generated columns in MySQL fill the shadow columns
+	WriteMode string `yaml:"write_mode"`
+	// DatabaseMethod is how MySQL fills the shadow columns in database write
+	// mode: trigger (default) or generated
+	DatabaseMethod string `yaml:"database_method"`
+	// PrimaryKey identifies the rows written in async mode (default id)
+	PrimaryKey string `yaml:"primary_key"`
+	// Mode is enforce (default) to dual-write, or observe to compute the
+	// rewrite of each statement, log and count it, and forward the statement
+	// untouched
+	Mode string `yaml:"mode"`
+	// ReadCurrency is the currency SELECT statements compare and read
+	// currency columns in: idr (default) forwards them as written, idn
+	// converts compared amounts to IDR and reads the shadow columns for
+	// clients that already think in IDN
+	ReadCurrency string `yaml:"read_currency"`
+}
+
+// Table modes
+const (
+	TableModeEnforce = "enforce"
+	TableModeObserve = "observe"
+)
+
+// ValidTableMode reports whether mode is a table mode; empty means enforce
+func ValidTableMode(mode string) bool {
+	switch mode {
+	case "", TableModeEnforce, TableModeObserve:
+		return true
+	}
+	return false
+}
+
+// Table write modes
+const (
+	WriteModeSync     = "sync"
+	WriteModeAsync    = "async"
+	WriteModeDatabase = "database"
+)
+
+// Currencies of the amounts in reads
+const (
+	ReadCurrencyIDR = "idr"
+	ReadCurrencyIDN = "idn"
+)
+
+// Database write mode methods
+const (
+	DatabaseMethodTrigger   = "trigger"
+	DatabaseMethodGenerated = "generated"
+)
+
+// DefaultPrimaryKey is the key column of tables without primary_key
+const DefaultPrimaryKey = "id"
+
+// IsAsync reports whether the table's shadow columns are filled from the outbox
+func (t TableConfig) IsAsync() bool {
+	return t.WriteMode == WriteModeAsync
+}
+
+// InDatabase reports whether MySQL fills the table's shadow columns
+func (t TableConfig) InDatabase() bool {
+	return t.WriteMode == WriteModeDatabase
+}
+
+// Method returns how MySQL fills the shadow columns in database write mode
+func (t TableConfig) Method() string {
+	if t.DatabaseMethod != "" {
+		return t.DatabaseMethod
+	}
+	return DatabaseMethodTrigger
+}
+
+// ReadsIDN reports whether SELECT statements on the table compare currency
+// columns with IDN amounts
+func (t TableConfig) ReadsIDN() bool {
+	return t.ReadCurrency == ReadCurrencyIDN
+}
+
+// KeyColumn returns the column identifying the table's rows
+func (t TableConfig) KeyColumn() string {
+	if t.PrimaryKey != "" {
+		return t.PrimaryKey
+	}
+	return DefaultPrimaryKey
+}
+
+// RolloutConfig dual-writes a percentage of a table's statements while the
+// table is being enabled. Statements outside the rollout are forwarded
+// untouched and their shadow columns are filled by backfill later.
+type RolloutConfig struct {
+	Percent float64 `yaml:"percent"` // 0-100
+	// HashBy picks the statements: connection (default) keeps a session in or
+	// out of the rollout, primary_key keeps a row in or out of it
+	HashBy     string `yaml:"hash_by"`
+	PrimaryKey string `yaml:"primary_key"` // column hashed by primary_key, default id
+}
+
+// Rollout hashing modes
+const (
+	RolloutHashConnection = "connection"
+	RolloutHashPrimaryKey = "primary_key"
+)
+
+// DefaultRolloutPrimaryKey is the key column hashed without primary_key
+const DefaultRolloutPrimaryKey = "id"
+
+// validate checks the percentage and hashing mode
+func (r *RolloutConfig) validate() error {
+	if r.Percent < 0 || r.Percent > 100 {
+		return fmt.Errorf("rollout percent must be between 0 and 100, got %v", r.Percent)
+	}
+	switch r.HashBy {
+	case "", RolloutHashConnection, RolloutHashPrimaryKey:
+	default:
+		return fmt.Errorf("invalid rollout hash_by: %s", r.HashBy)
+	}
+	return nil
+}
+
+// NormalizeIdentifier strips identifier quoting (backticks or double quotes)
+func NormalizeIdentifier(name string) string {
+	name = strings.TrimSpace(name)
+	name = strings.Trim(name, "`")
+	name = strings.Trim(name, "\"")
+	return name
+}
+
+// Lookup resolves a table config by name, ignoring identifier quoting and,
+// when caseInsensitive is set, letter case. It returns the canonical key.
+func (t TablesConfig) Lookup(name string, caseInsensitive bool) (string, TableConfig, bool) {
+	name = NormalizeIdentifier(name)
+	if tableConfig, exists := t[name]; exists {
+		return name, tableConfig, true
+	}
+	if !caseInsensitive {
+		return "", TableConfig{}, false
+	}
+	if key, ok := foldMatch(t, name); ok {
+		return key, t[key], true
+	}
+	return "", TableConfig{}, false
+}
+
+// LookupColumn resolves a column config by name. Column names are always
+// case-insensitive in MySQL. It returns the canonical key.
+func (t TableConfig) LookupColumn(name string) (string, ColumnConfig, bool) {
+	name = NormalizeIdentifier(name)
+	if colConfig, exists := t.Columns[name]; exists {
+		return name, colConfig, true
+	}
+	if key, ok := foldMatch(t.Columns, name); ok {
+		return key, t.Columns[key], true
+	}
+	return "", ColumnConfig{}, false
+}
+
+// foldMatch returns the case-insensitive match for name among the map keys.
+// Keys are compared in sorted order so the result is deterministic even if
+// validation was bypassed and several keys differ only in case.
+func foldMatch[V any](m map[string]V, name string) (string, bool) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		if strings.EqualFold(key, name) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return "", false
+	}
+	sort.Strings(keys)
+	return keys[0], true
+}
+
+// caseCollision returns two keys that differ only in letter case, if any
+func caseCollision[V any](m map[string]V) (string, string, bool) {
+	seen := make(map[string]string, len(m))
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		folded := strings.ToLower(NormalizeIdentifier(key))
+		if other, exists := seen[folded]; exists {
+			return other, key, true
+		}
+		seen[folded] = key
+	}
+	return "", "", false
 }
 
 type ColumnConfig struct {
@@ -103,6 +1349,151 @@ type ColumnConfig struct {
 	TargetType       string `yaml:"target_type"`
 	RoundingStrategy string `yaml:"rounding_strategy"`
 	Precision        int    `yaml:"precision"`
+	// NullPolicy controls the shadow value written when the source value is NULL.
+	// Zero is an ordinary amount and always converts to 0.
+	NullPolicy string `yaml:"null_policy"`
+	// GuardFloor is the conversion guard's floor for this column: non-zero
+	// amounts closer to zero look already converted. It overrides
+	// conversion.guard.min_amount and must not be below BoundsMin.
+	GuardFloor float64 `yaml:"guard_floor"`
+	// BoundsMin and BoundsMax bound the source amounts converted; amounts
+	// outside them are refused instead of converted. Unset bounds are open.
+	BoundsMin *float64 `yaml:"bounds_min"`
+	BoundsMax *float64 `yaml:"bounds_max"`
+}
+
+// Null policies for currency columns
+const (
+	// NullPolicyPropagate writes NULL to the shadow column (default)
+	NullPolicyPropagate = "propagate"
+	// NullPolicyZero writes 0 to the shadow column
+	NullPolicyZero = "zero"
+	// NullPolicySkip leaves the shadow column out of the statement
+	NullPolicySkip = "skip"
+)
+
+// EffectiveRoundingStrategy returns the column's rounding strategy, falling
+// back to the given default (conversion.rounding_strategy)
+func (c ColumnConfig) EffectiveRoundingStrategy(defaultStrategy string) string {
+	if c.RoundingStrategy == "" {
+		return defaultStrategy
+	}
+	return c.RoundingStrategy
+}
+
+// EffectiveNullPolicy returns the column's null policy, defaulting to propagate
+func (c ColumnConfig) EffectiveNullPolicy() string {
+	if c.NullPolicy == "" {
+		return NullPolicyPropagate
+	}
+	return c.NullPolicy
+}
+
+// QueryRule is a ProxySQL-style rule matched against incoming statements.
+// Rules are evaluated in ascending ID order; every matching rule applies its
+// action until a rule with Apply set (or a block) stops evaluation.
+type QueryRule struct {
+	ID             int    `yaml:"id"`
+	Active         bool   `yaml:"active"`
+	MatchPattern   string `yaml:"match_pattern"`   // regex against the query text
+	MatchDigest    string `yaml:"match_digest"`    // regex against the query fingerprint
+	User           string `yaml:"user"`            // exact client username, empty matches any
+	Schema         string `yaml:"schema"`          // exact current database, empty matches any
+	Action         string `yaml:"action"`          // rewrite, block, comment
+	ReplacePattern string `yaml:"replace_pattern"` // rewrite template, may reference match_pattern groups ($1)
+	Comment        string `yaml:"comment"`
+	ErrorMessage   string `yaml:"error_message"` // message returned for blocked queries
+	Apply          bool   `yaml:"apply"`         // stop evaluating further rules after this one
+}
+
+// Query rule actions
+const (
+	RuleActionRewrite = "rewrite"
+	RuleActionBlock   = "block"
+	RuleActionComment = "comment"
+
+	// RuleActionRoute and RuleActionCache are rejected: statements cannot move
+	// to another backend within a session (see RouteConfig) and there is no
+	// resultset cache
+	RuleActionRoute = "route"
+	RuleActionCache = "cache"
+)
+
+// ValidateQueryRules checks rule actions, required fields and regular expressions
+func ValidateQueryRules(rules []QueryRule) error {
+	seen := make(map[int]bool, len(rules))
+	for _, rule := range rules {
+		if seen[rule.ID] {
+			return fmt.Errorf("duplicate query rule id: %d", rule.ID)
+		}
+		seen[rule.ID] = true
+
+		if rule.MatchPattern != "" {
+			if _, err := regexp.Compile(rule.MatchPattern); err != nil {
+				return fmt.Errorf("query rule %d: invalid match_pattern: %w", rule.ID, err)
+			}
+		}
+		if rule.MatchDigest != "" {
+			if _, err := regexp.Compile(rule.MatchDigest); err != nil {
+				return fmt.Errorf("query rule %d: invalid match_digest: %w", rule.ID, err)
+			}
+		}
+
+		switch rule.Action {
+		case RuleActionRewrite:
+			if rule.MatchPattern == "" {
+				return fmt.Errorf("query rule %d: rewrite requires match_pattern", rule.ID)
+			}
+		case RuleActionRoute, RuleActionCache:
+			return fmt.Errorf("query rule %d: action %s is not supported", rule.ID, rule.Action)
+		case RuleActionComment:
+			if rule.Comment == "" {
+				return fmt.Errorf("query rule %d: comment requires comment text", rule.ID)
+			}
+			// The text is put inside /* */; a */ would end the comment and
+			// prepend the rest to every matching statement as SQL
+			if strings.Contains(rule.Comment, "*/") {
+				return fmt.Errorf("query rule %d: comment text must not contain */", rule.ID)
+			}
+		case RuleActionBlock:
+		default:
+			return fmt.Errorf("query rule %d: invalid action: %s", rule.ID, rule.Action)
+		}
+	}
+	return nil
+}
+
+// integerTypePattern matches MySQL integer column types, e.g. INT(11) UNSIGNED
+var integerTypePattern = regexp.MustCompile(`(?i)^\s*(?:TINYINT|SMALLINT|MEDIUMINT|INT|INTEGER|BIGINT)\s*(?:\(\s*\d+\s*\))?\s*(?:UNSIGNED)?\s*(?:ZEROFILL)?\s*$`)
+
+// IsIntegerType reports whether a column type is an integer type, which
+// cannot hold the decimals of a converted amount
+func IsIntegerType(columnType string) bool {
+	return integerTypePattern.MatchString(columnType)
+}
+
+// decimalTypePattern matches DECIMAL/NUMERIC column types with optional precision and scale
+var decimalTypePattern = regexp.MustCompile(`(?i)^\s*(?:DECIMAL|NUMERIC|DEC|FIXED)\s*(?:\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\))?\s*(?:UNSIGNED)?\s*$`)
+
+// ParseDecimalType extracts precision and scale from a DECIMAL(p,s) type.
+// MySQL defaults apply when omitted: DECIMAL = DECIMAL(10,0), DECIMAL(p) = DECIMAL(p,0).
+func ParseDecimalType(columnType string) (precision int, scale int, ok bool) {
+	m := decimalTypePattern.FindStringSubmatch(columnType)
+	if m == nil {
+		return 0, 0, false
+	}
+
+	precision = 10
+	if m[1] != "" {
+		precision, _ = strconv.Atoi(m[1])
+	}
+	if m[2] != "" {
+		scale, _ = strconv.Atoi(m[2])
+	}
+	if scale > precision {
+		return 0, 0, false
+	}
+	return precision, scale, true
 }
 
 // Load loads configuration from a YAML file
@@ -127,14 +1518,19 @@ func Load(filepath string) (*Config, error) {
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
-	if c.Database.Host == "" {
-		return fmt.Errorf("database host is required")
+	if c.Database.Socket == "" && !c.Database.Discovery.Enabled() {
+		if c.Database.Host == "" {
+			return fmt.Errorf("database host is required")
+		}
+		if c.Database.Port == 0 {
+			return fmt.Errorf("database port is required")
+		}
 	}
-	if c.Database.Port == 0 {
-		return fmt.Errorf("database port is required")
+	if c.Proxy.Port == 0 && c.Proxy.Socket == "" {
+		return fmt.Errorf("proxy port or socket is required")
 	}
-	if c.Proxy.Port == 0 {
-		return fmt.Errorf("proxy port is required")
+	if err := c.Redis.validate(); err != nil {
+		return fmt.Errorf("redis: %w", err)
 	}
 	if c.Conversion.Ratio <= 0 {
 		return fmt.Errorf("conversion ratio must be positive")
@@ -142,7 +1538,80 @@ func (c *Config) Validate() error {
 	if c.Conversion.Precision < 0 || c.Conversion.Precision > 10 {
 		return fmt.Errorf("conversion precision must be between 0 and 10")
 	}
-	
+	if err := c.Conversion.Guard.validate(); err != nil {
+		return fmt.Errorf("conversion: %w", err)
+	}
+	for _, locale := range c.Conversion.AmountLocales {
+		switch locale {
+		case AmountLocalePlain, AmountLocaleID, AmountLocaleEN:
+		default:
+			return fmt.Errorf("invalid conversion amount locale: %s", locale)
+		}
+	}
+	switch c.Conversion.PlaceholderPolicy {
+	case "", PlaceholderReject, PlaceholderFlag:
+	default:
+		return fmt.Errorf("invalid conversion placeholder policy: %s", c.Conversion.PlaceholderPolicy)
+	}
+
+	if err := c.Proxy.TCP.Validate(); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if err := c.Proxy.Accept.Validate(); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if err := c.Proxy.validateListeners(); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if err := c.Database.TCP.Validate(); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	if err := c.Database.EgressProxy.validate(); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	if err := c.Database.validateReplicas(); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	if err := c.Database.Failover.validate(); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	if err := c.Database.Discovery.validate(); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	if c.Database.Discovery.Enabled() && c.Database.Failover.Enabled() {
+		return fmt.Errorf("database: discovery and failover standbys cannot be combined")
+	}
+	if err := c.Proxy.validateRoutes(c.Database.ReplicaRoles()); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if err := c.Proxy.validateLimits(); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if err := c.Proxy.PoolHealthCheck.validate(); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if err := c.Proxy.Probe.validate(); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	if c.Proxy.MaxConnIdleTime < 0 || c.Proxy.MaxConnLifetime < 0 || c.Proxy.CleanupInterval < 0 {
+		return fmt.Errorf("proxy: pool connection lifetimes must not be negative")
+	}
+	if c.API.DeletedTableRetention < 0 {
+		return fmt.Errorf("api: deleted_table_retention must not be negative")
+	}
+	if c.API.AdminKey != "" && c.API.AdminKey == c.API.APIKey {
+		return fmt.Errorf("api: admin_key must differ from api_key")
+	}
+	if c.Proxy.TableToggleInterval < 0 {
+		return fmt.Errorf("proxy: table_toggle_interval must not be negative")
+	}
+	if c.Proxy.MaxParseSize < 0 {
+		return fmt.Errorf("proxy: max_parse_size must not be negative")
+	}
+	if c.Proxy.IdleTimeout < 0 {
+		return fmt.Errorf("proxy: idle_timeout must not be negative")
+	}
+
 	// Validate rounding strategy
 	validStrategies := map[string]bool{
 		"BANKERS_ROUND":    true,
@@ -151,14 +1620,121 @@ func (c *Config) Validate() error {
 	if !validStrategies[c.Conversion.RoundingStrategy] {
 		return fmt.Errorf("invalid rounding strategy: %s", c.Conversion.RoundingStrategy)
 	}
-	
+
+	if !validFailurePolicy(c.Conversion.FailurePolicy) {
+		return fmt.Errorf("invalid failure policy: %s", c.Conversion.FailurePolicy)
+	}
+	switch c.Conversion.DDLAction {
+	case "", DDLActionWarn, DDLActionPropose, DDLActionIgnore:
+	default:
+		return fmt.Errorf("invalid ddl action: %s", c.Conversion.DDLAction)
+	}
+	// Case-insensitive lookups must resolve to exactly one config
+	if c.Database.LowerCaseTableNames != 0 {
+		if a, b, found := caseCollision(c.Tables); found {
+			return fmt.Errorf("tables %s and %s differ only in case, which is ambiguous with lower_case_table_names=%d",
+				a, b, c.Database.LowerCaseTableNames)
+		}
+	}
+	for name, tableConfig := range c.Tables {
+		if a, b, found := caseCollision(tableConfig.Columns); found {
+			return fmt.Errorf("table %s: columns %s and %s differ only in case", name, a, b)
+		}
+		if !validFailurePolicy(tableConfig.FailurePolicy) {
+			return fmt.Errorf("table %s: invalid failure policy: %s", name, tableConfig.FailurePolicy)
+		}
+		if tableConfig.Rollout != nil {
+			if err := tableConfig.Rollout.validate(); err != nil {
+				return fmt.Errorf("table %s: %w", name, err)
+			}
+		}
+		switch tableConfig.WriteMode {
+		case "", WriteModeSync, WriteModeAsync, WriteModeDatabase:
+		default:
+			return fmt.Errorf("table %s: invalid write mode: %s", name, tableConfig.WriteMode)
+		}
+		switch tableConfig.DatabaseMethod {
+		case "", DatabaseMethodTrigger, DatabaseMethodGenerated:
+		default:
+			return fmt.Errorf("table %s: invalid database method: %s", name, tableConfig.DatabaseMethod)
+		}
+		if !ValidTableMode(tableConfig.Mode) {
+			return fmt.Errorf("table %s: invalid mode: %s", name, tableConfig.Mode)
+		}
+		switch tableConfig.ReadCurrency {
+		case "", ReadCurrencyIDR, ReadCurrencyIDN:
+		default:
+			return fmt.Errorf("table %s: invalid read currency: %s", name, tableConfig.ReadCurrency)
+		}
+		for colName, colConfig := range tableConfig.Columns {
+			if IsIntegerType(colConfig.TargetType) {
+				return fmt.Errorf("table %s column %s: target type %s is an integer type and would truncate converted decimals",
+					name, colName, colConfig.TargetType)
+			}
+			switch colConfig.NullPolicy {
+			case "", NullPolicyPropagate, NullPolicyZero, NullPolicySkip:
+			default:
+				return fmt.Errorf("table %s column %s: invalid null policy: %s", name, colName, colConfig.NullPolicy)
+			}
+			if colConfig.NullPolicy == NullPolicySkip && tableConfig.InDatabase() && tableConfig.Method() == DatabaseMethodGenerated {
+				return fmt.Errorf("table %s column %s: null policy skip needs database_method trigger, a generated column cannot keep its value", name, colName)
+			}
+			if colConfig.GuardFloor < 0 {
+				return fmt.Errorf("table %s column %s: guard_floor must not be negative", name, colName)
+			}
+			if colConfig.BoundsMin != nil && colConfig.BoundsMax != nil && *colConfig.BoundsMin > *colConfig.BoundsMax {
+				return fmt.Errorf("table %s column %s: bounds_min %v is greater than bounds_max %v", name, colName, *colConfig.BoundsMin, *colConfig.BoundsMax)
+			}
+			if colConfig.GuardFloor > 0 && colConfig.BoundsMin != nil && colConfig.GuardFloor < *colConfig.BoundsMin {
+				return fmt.Errorf("table %s column %s: guard_floor %v is below bounds_min %v", name, colName, colConfig.GuardFloor, *colConfig.BoundsMin)
+			}
+		}
+	}
+
+	if err := ValidateQueryRules(c.QueryRules); err != nil {
+		return err
+	}
+	if err := c.Canary.validate(); err != nil {
+		return err
+	}
+	if err := c.Outbox.validate(); err != nil {
+		return err
+	}
+	if err := c.Scheduler.validate(); err != nil {
+		return err
+	}
+	if err := c.Backfill.validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// GetCanaryDSN returns the connection string of the canary instance, which
+// defaults to the primary's database
+func (c *Config) GetCanaryDSN() string {
+	database := c.Canary.Database
+	if database == "" {
+		database = c.Database.Database
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.Canary.User, c.Canary.Password, c.Canary.Host, c.Canary.Port, database)
+}
+
 // GetDatabaseDSN returns the database connection string
 func (c *Config) GetDatabaseDSN() string {
 	switch c.Database.Type {
 	case "mysql":
+		if c.Database.Socket != "" {
+			return fmt.Sprintf("%s:%s@unix(%s)/%s?parseTime=true&loc=Asia%%2FJakarta",
+				c.Database.User,
+				c.Database.Password,
+				c.Database.Socket,
+				c.Database.Database,
+			)
+		}
 		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=Asia%%2FJakarta",
 			c.Database.User,
 			c.Database.Password,
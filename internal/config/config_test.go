@@ -0,0 +1,656 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecimalType(t *testing.T) {
+	tests := []struct {
+		input         string
+		wantPrecision int
+		wantScale     int
+		wantOK        bool
+	}{
+		{"DECIMAL(19,4)", 19, 4, true},
+		{"decimal(12, 2) unsigned", 12, 2, true},
+		{"NUMERIC(8)", 8, 0, true},
+		{"DECIMAL", 10, 0, true},
+		{"DECIMAL(2,4)", 0, 0, false},
+		{"BIGINT", 0, 0, false},
+		{"DOUBLE", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			precision, scale, ok := ParseDecimalType(tt.input)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantPrecision, precision)
+			assert.Equal(t, tt.wantScale, scale)
+		})
+	}
+}
+
+func TestValidate_IntegerTargetType(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "localhost", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+		Tables: TablesConfig{
+			"orders": {Enabled: true, Columns: map[string]ColumnConfig{
+				"total_amount": {SourceColumn: "total_amount", TargetColumn: "total_amount_idn", TargetType: "DECIMAL(19,4)"},
+			}},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	col := cfg.Tables["orders"].Columns["total_amount"]
+	col.TargetType = "bigint(20) unsigned"
+	cfg.Tables["orders"].Columns["total_amount"] = col
+	assert.ErrorContains(t, cfg.Validate(), "integer type and would truncate")
+
+	assert.True(t, IsIntegerType("INT"))
+	assert.False(t, IsIntegerType("DECIMAL(19,4)"))
+	assert.False(t, IsIntegerType("POINT"))
+}
+
+func TestTablesConfigLookup(t *testing.T) {
+	tables := TablesConfig{
+		"orders": {Enabled: true, Columns: map[string]ColumnConfig{"total_amount": {TargetColumn: "total_amount_idn"}}},
+	}
+
+	key, _, ok := tables.Lookup("`orders`", false)
+	assert.True(t, ok)
+	assert.Equal(t, "orders", key)
+
+	_, _, ok = tables.Lookup("ORDERS", false)
+	assert.False(t, ok)
+
+	key, tableConfig, ok := tables.Lookup("ORDERS", true)
+	assert.True(t, ok)
+	assert.Equal(t, "orders", key)
+
+	colKey, colConfig, ok := tableConfig.LookupColumn("`Total_Amount`")
+	assert.True(t, ok)
+	assert.Equal(t, "total_amount", colKey)
+	assert.Equal(t, "total_amount_idn", colConfig.TargetColumn)
+}
+
+func TestTablesConfigLookup_CaseCollision(t *testing.T) {
+	tables := TablesConfig{"Orders": {Enabled: true}, "orders": {Enabled: false}, "ORDERS": {Enabled: true}}
+
+	// Exact matches win, otherwise the first key in sorted order
+	key, _, ok := tables.Lookup("orders", true)
+	assert.True(t, ok)
+	assert.Equal(t, "orders", key)
+	for i := 0; i < 20; i++ {
+		key, _, _ = tables.Lookup("oRdErS", true)
+		assert.Equal(t, "ORDERS", key)
+	}
+
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "localhost", Port: 3306, LowerCaseTableNames: 1},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+		Tables:     TablesConfig{"Orders": {Enabled: true}, "orders": {Enabled: true}},
+	}
+	assert.ErrorContains(t, cfg.Validate(), "differ only in case")
+
+	// Case-sensitive servers can hold both tables
+	cfg.Database.LowerCaseTableNames = 0
+	assert.NoError(t, cfg.Validate())
+
+	// Columns are always case-insensitive
+	cfg.Tables = TablesConfig{"orders": {Enabled: true, Columns: map[string]ColumnConfig{"total": {}, "TOTAL": {}}}}
+	assert.ErrorContains(t, cfg.Validate(), "columns TOTAL and total differ only in case")
+}
+
+func TestFailurePolicyFor(t *testing.T) {
+	cfg := &Config{
+		Conversion: ConversionConfig{FailurePolicy: FailOpen},
+		Tables: TablesConfig{
+			"orders":   {Enabled: true, FailurePolicy: FailClosed},
+			"payments": {Enabled: true, FailurePolicy: FailOpenWithAlert},
+			"invoices": {Enabled: true},
+		},
+	}
+
+	assert.Equal(t, FailClosed, cfg.FailurePolicyFor("orders", FailOpen))
+	assert.Equal(t, FailOpen, cfg.FailurePolicyFor("invoices", FailClosed))
+	assert.Equal(t, FailOpen, cfg.FailurePolicyFor("unknown", FailClosed))
+
+	table, policy := cfg.StrictestFailurePolicy([]string{"invoices", "payments", "orders"}, FailOpen)
+	assert.Equal(t, "orders", table)
+	assert.Equal(t, FailClosed, policy)
+
+	// Without any configured policy the caller's fallback applies
+	cfg.Conversion.FailurePolicy = ""
+	assert.Equal(t, FailOpen, cfg.FailurePolicyFor("invoices", FailOpen))
+	assert.Equal(t, FailClosed, cfg.FailurePolicyFor("invoices", FailClosed))
+}
+
+func TestValidate_UnixSockets(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Type: "mysql", User: "root", Database: "ecommerce_db", Socket: "/var/run/mysqld/mysqld.sock"},
+		Proxy:      ProxyConfig{Socket: "/var/run/transisidb.sock"},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "root:@unix(/var/run/mysqld/mysqld.sock)/ecommerce_db?parseTime=true&loc=Asia%2FJakarta", cfg.GetDatabaseDSN())
+
+	cfg.Proxy.Socket = ""
+	assert.ErrorContains(t, cfg.Validate(), "proxy port or socket is required")
+
+	cfg.Proxy.Port = 3308
+	cfg.Database.Socket = ""
+	assert.ErrorContains(t, cfg.Validate(), "database host is required")
+}
+
+func TestValidate_Listeners(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "localhost", Port: 3306},
+		Proxy:      ProxyConfig{Host: "0.0.0.0", Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND", FailurePolicy: FailOpen},
+	}
+	cfg.Proxy.Listeners = []ListenerConfig{
+		{Name: "simulation", Host: "0.0.0.0", Port: 3309, Simulation: true, FailurePolicy: FailClosed},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	listenerCfg := cfg.ForListener(cfg.Proxy.Listeners[0])
+	assert.Equal(t, FailClosed, listenerCfg.Conversion.FailurePolicy)
+	assert.Equal(t, FailOpen, cfg.Conversion.FailurePolicy)
+
+	cfg.Proxy.Listeners[0].Port = 3308
+	assert.ErrorContains(t, cfg.Validate(), "address 0.0.0.0:3308 is already in use")
+
+	cfg.Proxy.Listeners[0].Port = 0
+	assert.ErrorContains(t, cfg.Validate(), "port or socket is required")
+
+	cfg.Proxy.Listeners[0].Port = 3309
+	cfg.Proxy.Listeners = append(cfg.Proxy.Listeners, ListenerConfig{Name: "simulation", Port: 3310})
+	assert.ErrorContains(t, cfg.Validate(), "duplicate name")
+
+	cfg.Proxy.Listeners = cfg.Proxy.Listeners[:1]
+	cfg.Proxy.Listeners[0].FailurePolicy = "ignore"
+	assert.ErrorContains(t, cfg.Validate(), "invalid failure policy")
+}
+
+func TestValidate_ReplicaRoutes(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{Host: "primary", Port: 3306, Replicas: []ReplicaConfig{
+			{Name: "bi-1", Host: "replica-1", Port: 3306, Role: "analytics"},
+		}},
+		Proxy: ProxyConfig{Port: 3308, Routes: []RouteConfig{
+			{Role: "analytics", SourceCIDRs: []string{"10.20.0.0/16"}},
+		}},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	replicaCfg := cfg.ForReplica(cfg.Database.Replicas[0])
+	assert.Equal(t, "replica-1", replicaCfg.Database.Host)
+	assert.Equal(t, "primary", cfg.Database.Host)
+
+	cfg.Proxy.Routes[0].SourceCIDRs = []string{"10.20.0.0"}
+	assert.ErrorContains(t, cfg.Validate(), "invalid source CIDR")
+
+	cfg.Proxy.Routes[0].SourceCIDRs = nil
+	cfg.Proxy.Routes[0].Listener = "bi"
+	assert.ErrorContains(t, cfg.Validate(), "unknown listener bi")
+
+	cfg.Proxy.Routes[0].Listener = "default"
+	cfg.Proxy.Routes[0].Role = "oltp"
+	assert.ErrorContains(t, cfg.Validate(), `no replicas with role "oltp"`)
+
+	cfg.Proxy.Routes = nil
+	cfg.Database.Replicas[0].Role = ""
+	assert.ErrorContains(t, cfg.Validate(), "replica bi-1: role is required")
+}
+
+func TestProxyConfig_LimitFor(t *testing.T) {
+	p := ProxyConfig{Limits: []LimitConfig{
+		{MaxRows: 1000000},
+		{User: "report", MaxRows: 50000, MaxExecutionTime: time.Minute},
+		{User: "report", Table: "orders", MaxRows: 100000, MaxResultBytes: 1 << 20},
+		{Table: "Orders", MaxExecutionTime: 10 * time.Second},
+	}}
+
+	assert.Equal(t, LimitConfig{MaxRows: 1000000}, p.LimitFor("app", ""))
+	assert.Equal(t, LimitConfig{MaxRows: 1000000, MaxExecutionTime: 10 * time.Second}, p.LimitFor("app", "orders"))
+	assert.Equal(t, LimitConfig{MaxRows: 50000, MaxResultBytes: 1 << 20, MaxExecutionTime: 10 * time.Second},
+		p.LimitFor("report", "orders"))
+	assert.True(t, ProxyConfig{}.LimitFor("app", "orders").IsZero())
+
+	assert.NoError(t, p.validateLimits())
+	p.Limits = append(p.Limits, LimitConfig{User: "app"})
+	assert.ErrorContains(t, p.validateLimits(), "limit 4: max_rows, max_result_bytes or max_execution_time is required")
+	p.Limits[4].MaxRows = -1
+	assert.ErrorContains(t, p.validateLimits(), "must not be negative")
+}
+
+func TestValidate_Failover(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	cfg.Database.Failover.Standbys = []StandbyConfig{{Name: "db-2", Host: "db-2", Port: 3306}}
+	assert.NoError(t, cfg.Validate())
+
+	standby := cfg.ForStandby(cfg.Database.Failover.Standbys[0])
+	assert.Equal(t, "db-2", standby.Database.Host)
+	assert.False(t, standby.Database.Failover.Enabled())
+	assert.Equal(t, "db-1", cfg.Database.Host)
+
+	cfg.Database.Failover.Standbys = append(cfg.Database.Failover.Standbys, StandbyConfig{Name: "primary", Host: "db-3", Port: 3306})
+	assert.ErrorContains(t, cfg.Validate(), "duplicate or reserved name")
+
+	cfg.Database.Failover.Standbys[1] = StandbyConfig{Name: "db-3"}
+	assert.ErrorContains(t, cfg.Validate(), "host and port or socket is required")
+}
+
+func TestValidate_Discovery(t *testing.T) {
+	cfg := &Config{
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	cfg.Database.Discovery = DiscoveryConfig{Type: DiscoveryDNSSRV, Name: "_mysql._tcp.primary.db"}
+	cfg.Database.Replicas = []ReplicaConfig{{
+		Name:      "analytics",
+		Role:      "analytics",
+		Discovery: DiscoveryConfig{Type: DiscoveryConsul, Name: "mysql-analytics"},
+	}}
+	assert.NoError(t, cfg.Validate())
+
+	replica := cfg.ForReplica(ReplicaConfig{Name: "analytics", Host: "10.0.0.5", Port: 3306})
+	assert.False(t, replica.Database.Discovery.Enabled())
+
+	cfg.Database.Replicas[0].Discovery.Type = "etcd"
+	assert.ErrorContains(t, cfg.Validate(), "etcd")
+
+	cfg.Database.Replicas[0].Discovery = DiscoveryConfig{}
+	assert.ErrorContains(t, cfg.Validate(), "host and port, socket or discovery is required")
+
+	cfg.Database.Replicas = nil
+	cfg.Database.Failover.Standbys = []StandbyConfig{{Name: "db-2", Host: "db-2", Port: 3306}}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_EgressProxy(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	cfg.Database.EgressProxy = EgressProxyConfig{URL: "socks5://egress.corp:1080", Username: "db", Password: "secret"}
+	assert.NoError(t, cfg.Validate())
+	endpoint, err := cfg.Database.EgressProxy.Endpoint()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "db", endpoint.User.Username())
+	}
+
+	// Replicas and standbys share the primary's egress proxy
+	replica := cfg.ForReplica(ReplicaConfig{Name: "analytics", Host: "10.0.0.5", Port: 3306})
+	assert.True(t, replica.Database.EgressProxy.Enabled())
+
+	cfg.Database.EgressProxy = EgressProxyConfig{URL: "https://egress.corp:3128"}
+	assert.ErrorContains(t, cfg.Validate(), "unsupported scheme")
+
+	cfg.Database.EgressProxy = EgressProxyConfig{URL: "http://egress.corp"}
+	assert.ErrorContains(t, cfg.Validate(), "host:port")
+
+	cfg.Database.EgressProxy = EgressProxyConfig{Username: "db"}
+	assert.ErrorContains(t, cfg.Validate(), "username needs a url")
+}
+
+func TestValidate_PoolHealthCheck(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	cfg.Proxy.PoolHealthCheck = PoolHealthCheckConfig{Mode: HealthCheckRead, IdleThreshold: 5 * time.Second}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Proxy.PoolHealthCheck.Mode = "select1"
+	assert.ErrorContains(t, cfg.Validate(), "invalid pool health check mode")
+
+	cfg.Proxy.PoolHealthCheck = PoolHealthCheckConfig{Timeout: -time.Second}
+	assert.ErrorContains(t, cfg.Validate(), "must not be negative")
+}
+
+func TestValidate_Probe(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	cfg.Proxy.Probe = ProbeConfig{Interval: 10 * time.Second}
+	assert.True(t, cfg.Proxy.Probe.Enabled())
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Proxy.Probe.SlowThreshold = 3 * time.Second
+	assert.ErrorContains(t, cfg.Validate(), "must be below timeout")
+
+	cfg.Proxy.Probe.Timeout = 5 * time.Second
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Proxy.Probe = ProbeConfig{Interval: -time.Second}
+	assert.ErrorContains(t, cfg.Validate(), "probe durations must not be negative")
+}
+
+func TestValidate_DeletedTableRetention(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, DefaultDeletedTableRetention, cfg.API.TableRetention())
+
+	cfg.API.DeletedTableRetention = 48 * time.Hour
+	assert.Equal(t, 48*time.Hour, cfg.API.TableRetention())
+
+	cfg.API.DeletedTableRetention = -time.Hour
+	assert.ErrorContains(t, cfg.Validate(), "deleted_table_retention must not be negative")
+}
+
+func TestValidate_AdminKey(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+		API:        APIConfig{APIKey: "sk_operator", AdminKey: "sk_admin"},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.API.AdminKey = cfg.API.APIKey
+	assert.ErrorContains(t, cfg.Validate(), "admin_key must differ from api_key")
+}
+
+func TestValidate_PoolLifetimes(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308, MaxConnIdleTime: 2 * time.Minute, CleanupInterval: 10 * time.Second},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Proxy.MaxConnLifetime = -time.Minute
+	assert.ErrorContains(t, cfg.Validate(), "lifetimes must not be negative")
+}
+
+func TestValidate_TableToggleInterval(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308, TableToggleInterval: 2 * time.Second, AdminUsers: []string{"dba"}},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Proxy.IsAdminUser("dba"))
+	assert.False(t, cfg.Proxy.IsAdminUser("app"))
+
+	cfg.Proxy.TableToggleInterval = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "table_toggle_interval must not be negative")
+
+	cfg.Proxy.TableToggleInterval = 0
+	cfg.Proxy.MaxParseSize = -1
+	assert.ErrorContains(t, cfg.Validate(), "max_parse_size must not be negative")
+
+	cfg.Proxy.MaxParseSize = 0
+	cfg.Proxy.IdleTimeout = -time.Second
+	assert.ErrorContains(t, cfg.Validate(), "idle_timeout must not be negative")
+}
+
+func TestValidate_TableRollout(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+		Tables: TablesConfig{
+			"orders": {Enabled: true, Rollout: &RolloutConfig{Percent: 12.5, HashBy: RolloutHashPrimaryKey}},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Tables["orders"].Rollout.Percent = 101
+	assert.ErrorContains(t, cfg.Validate(), "rollout percent must be between 0 and 100")
+
+	cfg.Tables["orders"].Rollout.Percent = 50
+	cfg.Tables["orders"].Rollout.HashBy = "user"
+	assert.ErrorContains(t, cfg.Validate(), "invalid rollout hash_by")
+}
+
+func TestValidate_ConversionGuard(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:    ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND",
+			Guard: GuardConfig{Policy: GuardReject, MinAmount: 500}},
+		Tables: TablesConfig{
+			"orders": {Enabled: true, Columns: map[string]ColumnConfig{
+				"total_amount": {TargetColumn: "total_amount_idn", GuardFloor: 100},
+			}},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Conversion.Guard.Policy = "block"
+	assert.ErrorContains(t, cfg.Validate(), "invalid guard policy")
+
+	cfg.Conversion.Guard = GuardConfig{Policy: GuardFlag, MinAmount: -1}
+	assert.ErrorContains(t, cfg.Validate(), "guard min_amount must not be negative")
+
+	cfg.Conversion.Guard.MinAmount = 0
+	cfg.Tables["orders"].Columns["total_amount"] = ColumnConfig{TargetColumn: "total_amount_idn", GuardFloor: -5}
+	assert.ErrorContains(t, cfg.Validate(), "column total_amount: guard_floor must not be negative")
+}
+
+func TestValidate_AmountLocales(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND", AmountLocales: []string{AmountLocaleID}},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Conversion.AmountLocales = []string{AmountLocalePlain}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Conversion.AmountLocales = []string{"de"}
+	assert.ErrorContains(t, cfg.Validate(), "invalid conversion amount locale: de")
+}
+
+func TestValidate_PlaceholderPolicy(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND", PlaceholderPolicy: PlaceholderFlag},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Conversion.PlaceholderPolicy = "skip"
+	assert.ErrorContains(t, cfg.Validate(), "invalid conversion placeholder policy: skip")
+}
+
+func TestValidate_ColumnBounds(t *testing.T) {
+	min, max := 0.0, 1e15
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+		Tables: TablesConfig{
+			"orders": {Enabled: true, Columns: map[string]ColumnConfig{
+				"total_amount": {TargetColumn: "total_amount_idn", BoundsMin: &min, BoundsMax: &max},
+			}},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	min = 2e15
+	assert.ErrorContains(t, cfg.Validate(), "column total_amount: bounds_min 2e+15 is greater than bounds_max 1e+15")
+
+	// The guard floor must not be below the bounds
+	min = 5000
+	cfg.Tables["orders"].Columns["total_amount"] = ColumnConfig{TargetColumn: "total_amount_idn", GuardFloor: 1000, BoundsMin: &min, BoundsMax: &max}
+	assert.ErrorContains(t, cfg.Validate(), "column total_amount: guard_floor 1000 is below bounds_min 5000")
+	min = 1000
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_Canary(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306, Database: "shop"},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+		Canary:     CanaryConfig{Enabled: true, Host: "canary-1", Port: 3306, User: "replay", Password: "secret", SampleRate: 0.1},
+	}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "replay:secret@tcp(canary-1:3306)/shop", cfg.GetCanaryDSN())
+
+	cfg.Canary.SampleRate = 1.5
+	assert.ErrorContains(t, cfg.Validate(), "sample_rate must be between 0 and 1")
+
+	cfg.Canary.SampleRate = 0
+	cfg.Canary.Host = ""
+	assert.ErrorContains(t, cfg.Validate(), "canary host and port are required")
+}
+
+func TestValidate_WriteMode(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+		Tables: TablesConfig{
+			"orders":   {Enabled: true, WriteMode: WriteModeAsync, PrimaryKey: "order_id"},
+			"payments": {Enabled: true},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Tables["orders"].IsAsync())
+	assert.Equal(t, "order_id", cfg.Tables["orders"].KeyColumn())
+	assert.False(t, cfg.Tables["payments"].IsAsync())
+	assert.Equal(t, DefaultPrimaryKey, cfg.Tables["payments"].KeyColumn())
+
+	cfg.Tables["payments"] = TableConfig{Enabled: true, WriteMode: "deferred"}
+	assert.ErrorContains(t, cfg.Validate(), "table payments: invalid write mode: deferred")
+
+	cfg.Tables["payments"] = TableConfig{Enabled: true, WriteMode: WriteModeDatabase}
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Tables["payments"].InDatabase())
+	assert.Equal(t, DatabaseMethodTrigger, cfg.Tables["payments"].Method())
+	cfg.Tables["payments"] = TableConfig{Enabled: true, WriteMode: WriteModeDatabase, DatabaseMethod: "view"}
+	assert.ErrorContains(t, cfg.Validate(), "table payments: invalid database method: view")
+	cfg.Tables["payments"] = TableConfig{Enabled: true, WriteMode: WriteModeDatabase, DatabaseMethod: DatabaseMethodGenerated,
+		Columns: map[string]ColumnConfig{"amount": {TargetColumn: "amount_idn", NullPolicy: NullPolicySkip}}}
+	assert.ErrorContains(t, cfg.Validate(), "table payments column amount: null policy skip needs database_method trigger")
+
+	cfg.Tables["payments"] = TableConfig{Enabled: true, Mode: TableModeObserve}
+	assert.NoError(t, cfg.Validate())
+	cfg.Tables["payments"] = TableConfig{Enabled: true, Mode: "dry_run"}
+	assert.ErrorContains(t, cfg.Validate(), "table payments: invalid mode: dry_run")
+
+	cfg.Tables["payments"] = TableConfig{Enabled: true}
+	cfg.Outbox.Workers = -1
+	assert.ErrorContains(t, cfg.Validate(), "outbox workers")
+}
+
+func TestValidate_SchedulerJobs(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+		Scheduler: SchedulerConfig{Enabled: true, Jobs: []JobConfig{
+			{Name: "nightly-reconcile", Type: JobTypeReconcile, Schedule: "@daily 02:00"},
+			{Name: "certs", Type: JobTypeCertExpiry, Schedule: "@hourly", Certificates: []string{"/etc/transisidb/ca.pem"}},
+			{Name: "backup", Type: JobTypeConfigBackup, Schedule: "@daily", Path: "/var/backups/transisidb"},
+		}},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Scheduler.Jobs = append(cfg.Scheduler.Jobs, JobConfig{Name: "certs", Type: JobTypeReconcile, Schedule: "@daily"})
+	assert.ErrorContains(t, cfg.Validate(), "duplicate job name: certs")
+
+	cfg.Scheduler.Jobs[3] = JobConfig{Name: "renew", Type: JobTypeCertExpiry, Schedule: "@daily"}
+	assert.ErrorContains(t, cfg.Validate(), "job renew: cert_expiry jobs need certificates")
+
+	cfg.Scheduler.Jobs[3] = JobConfig{Name: "vacuum", Type: "vacuum", Schedule: "@daily"}
+	assert.ErrorContains(t, cfg.Validate(), "job vacuum: invalid type: vacuum")
+
+	extract := &ExtractConfig{Table: "orders", Column: "total_amount", Format: ExtractFormatCSV, Path: "/exports/orders.csv"}
+	cfg.Scheduler.Jobs[3] = JobConfig{Name: "warehouse", Type: JobTypeWarehouseReconcile, Schedule: "@daily", Extract: extract}
+	assert.NoError(t, cfg.Validate())
+
+	extract.Format, extract.DSN = ExtractFormatSQL, "finance@tcp(clickhouse:9004)/finance"
+	assert.ErrorContains(t, cfg.Validate(), "job warehouse: sql extracts need a dsn and a query")
+	extract.Query = "SELECT id, total_amount FROM orders"
+	assert.NoError(t, cfg.Validate())
+
+	extract.Unit = "usd"
+	assert.ErrorContains(t, cfg.Validate(), "job warehouse: invalid extract unit: usd")
+
+	cfg.Scheduler.Jobs[3].Extract = nil
+	assert.ErrorContains(t, cfg.Validate(), "job warehouse: warehouse_reconcile jobs need an extract")
+}
+
+func TestValidate_BackfillConsistency(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	for _, consistency := range []string{"", BackfillConsistencyNone, BackfillConsistencySnapshot, BackfillConsistencyCutoff} {
+		cfg.Backfill.Consistency = consistency
+		assert.NoError(t, cfg.Validate(), consistency)
+	}
+
+	cfg.Backfill.Consistency = "serializable"
+	assert.ErrorContains(t, cfg.Validate(), "backfill: invalid consistency: serializable")
+}
+
+func TestValidate_BackfillConcurrency(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, 1, cfg.Backfill.WorkerConcurrency())
+	assert.Equal(t, "db-1:3306", cfg.Database.Backend())
+
+	cfg.Backfill = BackfillConfig{Concurrency: 4, MaxJobs: 6, MaxJobsPerBackend: 2}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, 4, cfg.Backfill.WorkerConcurrency())
+
+	cfg.Backfill.MaxJobsPerBackend = -1
+	assert.ErrorContains(t, cfg.Validate(), "max_jobs_per_backend must not be negative")
+}
+
+func TestValidate_ReadCurrency(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+		Tables:     TablesConfig{"orders": {Enabled: true, ReadCurrency: ReadCurrencyIDN}},
+	}
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Tables["orders"].ReadsIDN())
+
+	cfg.Tables["orders"] = TableConfig{Enabled: true, ReadCurrency: "usd"}
+	assert.ErrorContains(t, cfg.Validate(), "table orders: invalid read currency: usd")
+}
+
+func TestValidate_Accept(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308, Accept: AcceptConfig{Rate: 200, Burst: 50, MaxPending: 500}},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Proxy.Accept.MaxPending = -1
+	assert.ErrorContains(t, cfg.Validate(), "proxy: accept rate, burst and max_pending must not be negative")
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// Egress proxy schemes
+const (
+	EgressSOCKS5  = "socks5"
+	EgressSOCKS5H = "socks5h"
+	EgressHTTP    = "http"
+)
+
+// EgressProxyConfig sends the proxy's TCP connections to the primary,
+// replicas and standbys through a SOCKS5 or HTTP CONNECT proxy. Unix socket
+// backends are always reached directly.
+type EgressProxyConfig struct {
+	// URL is socks5://host:port, socks5h://host:port or http://host:port;
+	// empty connects directly
+	URL string `yaml:"url"`
+	// Username and Password authenticate to the egress proxy; they override
+	// credentials in the URL
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Enabled reports whether backend connections go through an egress proxy
+func (e EgressProxyConfig) Enabled() bool {
+	return e.URL != ""
+}
+
+// Endpoint returns the parsed proxy URL with the configured credentials
+func (e EgressProxyConfig) Endpoint() (*url.URL, error) {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	switch u.Scheme {
+	case EgressSOCKS5, EgressSOCKS5H, EgressHTTP:
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q: use socks5, socks5h or http", u.Scheme)
+	}
+	if _, port, err := net.SplitHostPort(u.Host); err != nil || u.Hostname() == "" || port == "" {
+		return nil, fmt.Errorf("url must be <scheme>://host:port")
+	}
+	if e.Username != "" {
+		u.User = url.UserPassword(e.Username, e.Password)
+	}
+	return u, nil
+}
+
+// redacted returns a copy without the password, in the field or the URL
+func (e EgressProxyConfig) redacted() EgressProxyConfig {
+	if e.Password != "" {
+		e.Password = redactedValue
+	}
+	if u, err := url.Parse(e.URL); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedValue)
+			e.URL = u.String()
+		}
+	}
+	return e
+}
+
+// validate checks the proxy URL
+func (e EgressProxyConfig) validate() error {
+	if !e.Enabled() {
+		if e.Username != "" {
+			return fmt.Errorf("egress_proxy: username needs a url")
+		}
+		return nil
+	}
+	if _, err := e.Endpoint(); err != nil {
+		return fmt.Errorf("egress_proxy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewRedisClient creates a client for the configured topology: a single
+// node, the master named by Sentinel, or a Redis Cluster
+func NewRedisClient(cfg *RedisConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := cfg.TLS.load()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Mode {
+	case RedisModeSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Sentinels,
+			SentinelUsername: cfg.SentinelUsername,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.Database,
+			PoolSize:         cfg.PoolSize,
+			TLSConfig:        tlsConfig,
+		}), nil
+	case RedisModeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     cfg.Addrs,
+			Username:  cfg.Username,
+			Password:  cfg.Password,
+			PoolSize:  cfg.PoolSize,
+			TLSConfig: tlsConfig,
+		}), nil
+	default:
+		return redis.NewClient(&redis.Options{
+			Addr:      fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+			Username:  cfg.Username,
+			Password:  cfg.Password,
+			DB:        cfg.Database,
+			PoolSize:  cfg.PoolSize,
+			TLSConfig: tlsConfig,
+		}), nil
+	}
+}
+
+// load builds the TLS settings, nil when TLS is disabled
+func (t RedisTLSConfig) load() (*tls.Config, error) {
+	if !t.Enabled {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// RedisKeyPrefix returns the prefix of a feature's keys. On a Redis Cluster
+// the prefix is a hash tag, so the feature's keys share a slot and its
+// multi-key transactions work.
+func RedisKeyPrefix(client redis.UniversalClient, prefix string) string {
+	if _, ok := client.(*redis.ClusterClient); ok {
+		return "{" + prefix + "}"
+	}
+	return prefix
+}
+
+// scanKeys returns the keys matching pattern, from every master of a cluster
+func scanKeys(ctx context.Context, client redis.UniversalClient, pattern string) ([]string, error) {
+	scan := func(ctx context.Context, client *redis.Client) ([]string, error) {
+		var keys []string
+		iter := client.Scan(ctx, 0, pattern, 0).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		return keys, iter.Err()
+	}
+
+	switch c := client.(type) {
+	case *redis.Client:
+		return scan(ctx, c)
+	case *redis.ClusterClient:
+		var mu sync.Mutex
+		var keys []string
+		err := c.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			masterKeys, err := scan(ctx, master)
+			mu.Lock()
+			keys = append(keys, masterKeys...)
+			mu.Unlock()
+			return err
+		})
+		return keys, err
+	default:
+		return nil, fmt.Errorf("unsupported Redis client %T", client)
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/certgen"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRedisClient_Topologies(t *testing.T) {
+	client, err := NewRedisClient(&RedisConfig{Host: "localhost", Port: 6379, Username: "transisidb"})
+	require.NoError(t, err)
+	defer client.Close()
+	require.IsType(t, &redis.Client{}, client)
+	assert.Equal(t, "localhost:6379", client.(*redis.Client).Options().Addr)
+	assert.Equal(t, "transisidb", client.(*redis.Client).Options().Username)
+	assert.Equal(t, ConfigKeyPrefix, RedisKeyPrefix(client, ConfigKeyPrefix))
+
+	// Sentinel resolves the master on first use, so no connection is made here
+	sentinel, err := NewRedisClient(&RedisConfig{
+		Mode: RedisModeSentinel, MasterName: "mymaster", Sentinels: []string{"sentinel-1:26379"},
+	})
+	require.NoError(t, err)
+	defer sentinel.Close()
+	require.IsType(t, &redis.Client{}, sentinel)
+	assert.Equal(t, ConfigKeyPrefix, RedisKeyPrefix(sentinel, ConfigKeyPrefix))
+
+	cluster, err := NewRedisClient(&RedisConfig{Mode: RedisModeCluster, Addrs: []string{"node-1:6379", "node-2:6379"}})
+	require.NoError(t, err)
+	defer cluster.Close()
+	require.IsType(t, &redis.ClusterClient{}, cluster)
+	assert.Equal(t, "{transisidb:config}", RedisKeyPrefix(cluster, ConfigKeyPrefix))
+}
+
+func TestNewRedisClient_TLS(t *testing.T) {
+	client, err := NewRedisClient(&RedisConfig{
+		Host: "localhost", Port: 6380,
+		TLS: RedisTLSConfig{Enabled: true, ServerName: "redis.internal"},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	tlsConfig := client.(*redis.Client).Options().TLSConfig
+	require.NotNil(t, tlsConfig)
+	assert.Equal(t, "redis.internal", tlsConfig.ServerName)
+
+	_, err = NewRedisClient(&RedisConfig{TLS: RedisTLSConfig{Enabled: true, CAFile: "/nonexistent/ca.pem"}})
+	assert.ErrorContains(t, err, "failed to read Redis CA file")
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+	_, err = NewRedisClient(&RedisConfig{TLS: RedisTLSConfig{Enabled: true, CAFile: caFile}})
+	assert.ErrorContains(t, err, "no certificates found")
+
+	bundle, err := certgen.Generate(certgen.Options{Hosts: []string{"redis.internal"}})
+	require.NoError(t, err)
+	files, err := bundle.Write(t.TempDir(), false)
+	require.NoError(t, err)
+	client, err = NewRedisClient(&RedisConfig{TLS: RedisTLSConfig{
+		Enabled: true, CAFile: files.CA,
+		CertFile: files.Clients[0].Cert, KeyFile: files.Clients[0].Key,
+	}})
+	require.NoError(t, err)
+	defer client.Close()
+	tlsConfig = client.(*redis.Client).Options().TLSConfig
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.NotNil(t, tlsConfig.RootCAs)
+}
+
+func TestValidate_Redis(t *testing.T) {
+	cfg := &Config{
+		Database:   DatabaseConfig{Host: "db-1", Port: 3306},
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"},
+	}
+	cfg.Redis = RedisConfig{Mode: RedisModeSentinel, MasterName: "mymaster", Sentinels: []string{"sentinel-1:26379"}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Redis.Sentinels = nil
+	assert.ErrorContains(t, cfg.Validate(), "sentinel mode needs master_name and sentinels")
+
+	cfg.Redis = RedisConfig{Mode: RedisModeCluster, Addrs: []string{"node-1:6379"}, Database: 2}
+	assert.ErrorContains(t, cfg.Validate(), "cluster mode only has database 0")
+
+	cfg.Redis = RedisConfig{Mode: "ring"}
+	assert.ErrorContains(t, cfg.Validate(), "redis: invalid mode: ring")
+
+	cfg.Redis = RedisConfig{TLS: RedisTLSConfig{Enabled: true, CertFile: "client.pem"}}
+	assert.ErrorContains(t, cfg.Validate(), "cert_file and key_file must be set together")
+}
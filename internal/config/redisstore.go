@@ -3,9 +3,16 @@ package config
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -15,38 +22,53 @@ const (
 	ConfigChannel   = "transisidb:config:reload"
 )
 
+// Config watcher timing
+const (
+	// watchPingInterval is how long the watcher waits for a message before it
+	// pings Redis, so a silently dropped connection is noticed
+	watchPingInterval = 30 * time.Second
+	// watchMinBackoff and watchMaxBackoff bound the wait between attempts to
+	// resubscribe after the connection was lost
+	watchMinBackoff = time.Second
+	watchMaxBackoff = 30 * time.Second
+)
+
 // RedisStore manages configuration in Redis with hot-reload capability
 type RedisStore struct {
-	client   *redis.Client
-	cfg      *RedisConfig
-	pubsub   *redis.PubSub
-	reloadCh chan *Config
-	closeCh  chan struct{}
+	client redis.UniversalClient
+	prefix string // ConfigKeyPrefix, hash-tagged on a cluster
+	cfg    *RedisConfig
+	// auditKey signs audit entries, none when empty
+	auditKey []byte
+
+	// ctx is cancelled by Close and stops the config watchers
+	ctx      context.Context
+	cancel   context.CancelFunc
+	watchers sync.WaitGroup
 }
 
 // NewRedisStore creates a new Redis configuration store
 func NewRedisStore(cfg *RedisConfig) (*RedisStore, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.Database,
-		PoolSize: cfg.PoolSize,
-	})
+	client, err := NewRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
 	store := &RedisStore{
-		client:   client,
-		cfg:      cfg,
-		reloadCh: make(chan *Config, 10),
-		closeCh:  make(chan struct{}),
+		client: client,
+		prefix: RedisKeyPrefix(client, ConfigKeyPrefix),
+		cfg:    cfg,
 	}
+	store.ctx, store.cancel = context.WithCancel(context.Background())
 
 	return store, nil
 }
@@ -60,13 +82,13 @@ func (s *RedisStore) SaveConfig(ctx context.Context, cfg *Config) error {
 	}
 
 	// Save to Redis with version timestamp
-	key := fmt.Sprintf("%s:main", ConfigKeyPrefix)
+	key := fmt.Sprintf("%s:main", s.prefix)
 	if err := s.client.Set(ctx, key, data, 0).Err(); err != nil {
 		return fmt.Errorf("failed to save config to Redis: %w", err)
 	}
 
 	// Save timestamp
-	timestampKey := fmt.Sprintf("%s:timestamp", ConfigKeyPrefix)
+	timestampKey := fmt.Sprintf("%s:timestamp", s.prefix)
 	if err := s.client.Set(ctx, timestampKey, time.Now().Unix(), 0).Err(); err != nil {
 		return fmt.Errorf("failed to save timestamp: %w", err)
 	}
@@ -76,7 +98,7 @@ func (s *RedisStore) SaveConfig(ctx context.Context, cfg *Config) error {
 
 // LoadConfig loads configuration from Redis
 func (s *RedisStore) LoadConfig(ctx context.Context) (*Config, error) {
-	key := fmt.Sprintf("%s:main", ConfigKeyPrefix)
+	key := fmt.Sprintf("%s:main", s.prefix)
 
 	data, err := s.client.Get(ctx, key).Result()
 	if err == redis.Nil {
@@ -98,58 +120,138 @@ func (s *RedisStore) PublishReload(ctx context.Context) error {
 	return s.client.Publish(ctx, ConfigChannel, "reload").Err()
 }
 
-// WatchConfigChanges watches for configuration changes via Redis Pub/Sub
+// WatchConfigChanges watches for configuration changes via Redis Pub/Sub.
+// The watcher resubscribes when the connection drops and reloads the config
+// once it is back, since notifications sent meanwhile are lost. The channel
+// is closed when ctx is cancelled or the store is closed.
 func (s *RedisStore) WatchConfigChanges(ctx context.Context) (<-chan *Config, error) {
-	// Subscribe to config reload channel
-	s.pubsub = s.client.Subscribe(ctx, ConfigChannel)
+	pubsub, err := s.subscribe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reloadCh := make(chan *Config, 10)
+	s.watchers.Add(1)
+	go s.watchLoop(ctx, pubsub, reloadCh)
+
+	return reloadCh, nil
+}
 
-	// Wait for subscription confirmation
-	if _, err := s.pubsub.Receive(ctx); err != nil {
+// subscribe subscribes to the config channel and waits for the confirmation
+func (s *RedisStore) subscribe(ctx context.Context) (*redis.PubSub, error) {
+	pubsub := s.client.Subscribe(ctx, ConfigChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
 		return nil, fmt.Errorf("failed to subscribe to config channel: %w", err)
 	}
+	metrics.SetConfigWatcherUp(true)
+	return pubsub, nil
+}
+
+// watchLoop listens for reload messages until ctx is cancelled or the store
+// is closed, resubscribing whenever the subscription fails. It is the only
+// sender on reloadCh and closes it on return.
+func (s *RedisStore) watchLoop(ctx context.Context, pubsub *redis.PubSub, reloadCh chan<- *Config) {
+	defer s.watchers.Done()
+	defer close(reloadCh)
+	defer metrics.SetConfigWatcherUp(false)
 
-	// Start watching in background
-	go s.watchLoop(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopOnClose := context.AfterFunc(s.ctx, cancel)
+	defer stopOnClose()
 
-	return s.reloadCh, nil
+	for {
+		err := s.receive(ctx, pubsub, reloadCh)
+		metrics.SetConfigWatcherUp(false)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warn("Config watcher lost its Redis subscription, resubscribing", "error", err)
+
+		if pubsub = s.resubscribe(ctx); pubsub == nil {
+			return
+		}
+		logger.Info("Config watcher resubscribed")
+		s.reload(ctx, reloadCh)
+	}
 }
 
-// watchLoop listens for reload messages and loads new config
-func (s *RedisStore) watchLoop(ctx context.Context) {
-	ch := s.pubsub.Channel()
+// receive reloads the config for every notification until the subscription
+// fails; it always closes pubsub
+func (s *RedisStore) receive(ctx context.Context, pubsub *redis.PubSub, reloadCh chan<- *Config) error {
+	// Closing the subscription unblocks a pending read on shutdown
+	stop := context.AfterFunc(ctx, func() { pubsub.Close() })
+	defer func() {
+		if stop() {
+			pubsub.Close()
+		}
+	}()
 
 	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, watchPingInterval)
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				return err
+			}
+			if err := pubsub.Ping(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := msg.(*redis.Message); ok {
+			s.reload(ctx, reloadCh)
+		}
+	}
+}
+
+// resubscribe retries the subscription with exponential backoff until it
+// succeeds, or returns nil once ctx is cancelled
+func (s *RedisStore) resubscribe(ctx context.Context) *redis.PubSub {
+	backoff := watchMinBackoff
+	for {
+		timer := time.NewTimer(backoff)
 		select {
 		case <-ctx.Done():
-			return
-		case <-s.closeCh:
-			return
-		case msg := <-ch:
-			if msg == nil {
-				continue
-			}
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
 
-			// Load new configuration
-			newCfg, err := s.LoadConfig(ctx)
-			if err != nil {
-				// Log error but continue watching
-				fmt.Printf("Error loading config after reload notification: %v\n", err)
-				continue
-			}
+		pubsub, err := s.subscribe(ctx)
+		if err == nil {
+			return pubsub
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		backoff = min(backoff*2, watchMaxBackoff)
+		logger.Warn("Failed to resubscribe to config changes", "error", err, "retry_in", backoff)
+	}
+}
 
-			// Send to reload channel (non-blocking)
-			select {
-			case s.reloadCh <- newCfg:
-			default:
-				// Channel full, skip this update
-			}
+// reload loads the config and hands it to the watcher's consumer
+func (s *RedisStore) reload(ctx context.Context, reloadCh chan<- *Config) {
+	newCfg, err := s.LoadConfig(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Warn("Failed to load config after reload notification", "error", err)
 		}
+		return
+	}
+
+	// Send to reload channel (non-blocking)
+	select {
+	case reloadCh <- newCfg:
+	default:
+		// Channel full, skip this update
 	}
 }
 
 // GetConfigTimestamp returns the last config update timestamp
 func (s *RedisStore) GetConfigTimestamp(ctx context.Context) (int64, error) {
-	key := fmt.Sprintf("%s:timestamp", ConfigKeyPrefix)
+	key := fmt.Sprintf("%s:timestamp", s.prefix)
 
 	result, err := s.client.Get(ctx, key).Int64()
 	if err == redis.Nil {
@@ -168,13 +270,13 @@ func (s *RedisStore) SaveTableConfig(ctx context.Context, tableName string, tabl
 		return fmt.Errorf("failed to marshal table config: %w", err)
 	}
 
-	key := fmt.Sprintf("%s:tables:%s", ConfigKeyPrefix, tableName)
+	key := fmt.Sprintf("%s:tables:%s", s.prefix, tableName)
 	return s.client.Set(ctx, key, data, 0).Err()
 }
 
 // LoadTableConfig loads individual table configuration
 func (s *RedisStore) LoadTableConfig(ctx context.Context, tableName string) (*TableConfig, error) {
-	key := fmt.Sprintf("%s:tables:%s", ConfigKeyPrefix, tableName)
+	key := fmt.Sprintf("%s:tables:%s", s.prefix, tableName)
 
 	data, err := s.client.Get(ctx, key).Result()
 	if err == redis.Nil {
@@ -193,35 +295,324 @@ func (s *RedisStore) LoadTableConfig(ctx context.Context, tableName string) (*Ta
 
 // ListTables returns list of configured tables
 func (s *RedisStore) ListTables(ctx context.Context) ([]string, error) {
-	pattern := fmt.Sprintf("%s:tables:*", ConfigKeyPrefix)
+	pattern := fmt.Sprintf("%s:tables:*", s.prefix)
 
-	var tables []string
-	iter := s.client.Scan(ctx, 0, pattern, 0).Iterator()
+	keys, err := scanKeys(ctx, s.client, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan tables: %w", err)
+	}
 
-	for iter.Next(ctx) {
-		key := iter.Val()
-		// Extract table name from key
-		// Key format: transisidb:config:tables:tablename
-		parts := len(ConfigKeyPrefix) + len(":tables:")
+	// Key format: transisidb:config:tables:tablename
+	tables := make([]string, 0, len(keys))
+	parts := len(s.prefix) + len(":tables:")
+	for _, key := range keys {
 		if len(key) > parts {
-			tableName := key[parts:]
-			tables = append(tables, tableName)
+			tables = append(tables, key[parts:])
 		}
 	}
 
-	if err := iter.Err(); err != nil {
-		return nil, fmt.Errorf("failed to scan tables: %w", err)
+	return tables, nil
+}
+
+// LoadTables returns the configs of all tables stored in Redis
+func (s *RedisStore) LoadTables(ctx context.Context) (map[string]TableConfig, error) {
+	names, err := s.ListTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(names))
+	for i, name := range names {
+		cmds[i] = pipe.Get(ctx, fmt.Sprintf("%s:tables:%s", s.prefix, name))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to load table configs: %w", err)
 	}
 
+	tables := make(map[string]TableConfig, len(names))
+	for i, name := range names {
+		data, err := cmds[i].Result()
+		if err == redis.Nil {
+			// Deleted since the scan
+			continue
+		}
+		var tableConfig TableConfig
+		if err := json.Unmarshal([]byte(data), &tableConfig); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal table config %s: %w", name, err)
+		}
+		tables[name] = tableConfig
+	}
 	return tables, nil
 }
 
 // DeleteTableConfig deletes a table configuration
 func (s *RedisStore) DeleteTableConfig(ctx context.Context, tableName string) error {
-	key := fmt.Sprintf("%s:tables:%s", ConfigKeyPrefix, tableName)
+	key := fmt.Sprintf("%s:tables:%s", s.prefix, tableName)
 	return s.client.Del(ctx, key).Err()
 }
 
+// Table soft-delete errors
+var (
+	// ErrTableNotFound is returned for a table without a stored config, or
+	// without a deleted config to restore
+	ErrTableNotFound = errs.New(errs.ConfigNotFound, "table config not found")
+	// ErrTableEnabled is returned when deleting a table that is still enabled
+	ErrTableEnabled = errs.New(errs.ConfigConflict, "table is enabled, disable it before deleting it")
+	// ErrTableExists is returned when restoring a table that has a config again
+	ErrTableExists = errs.New(errs.ConfigConflict, "table has a config, delete it before restoring")
+)
+
+// DeletedTable is a deleted table config, kept until ExpiresAt
+type DeletedTable struct {
+	Config    TableConfig `json:"config"`
+	DeletedBy string      `json:"deleted_by"`
+	DeletedAt time.Time   `json:"deleted_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// SoftDeleteTableConfig moves the config of a disabled table aside, where
+// RestoreTableConfig finds it until retention has passed. The table's toggle
+// and rollout overrides stay, so a table still defined in the config file
+// remains disabled.
+func (s *RedisStore) SoftDeleteTableConfig(ctx context.Context, tableName, actor string, retention time.Duration) (*DeletedTable, error) {
+	tableKey := fmt.Sprintf("%s:tables:%s", s.prefix, tableName)
+	deletedKey := fmt.Sprintf("%s:deleted_tables:%s", s.prefix, tableName)
+
+	var deleted *DeletedTable
+	remove := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, tableKey).Result()
+		if err == redis.Nil {
+			return fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+		} else if err != nil {
+			return fmt.Errorf("failed to load table config: %w", err)
+		}
+
+		var tableConfig TableConfig
+		if err := json.Unmarshal([]byte(data), &tableConfig); err != nil {
+			return fmt.Errorf("failed to unmarshal table config: %w", err)
+		}
+		if tableConfig.Enabled {
+			return ErrTableEnabled
+		}
+
+		now := time.Now()
+		deleted = &DeletedTable{Config: tableConfig, DeletedBy: actor, DeletedAt: now, ExpiresAt: now.Add(retention)}
+		record, err := json.Marshal(deleted)
+		if err != nil {
+			return fmt.Errorf("failed to marshal deleted table: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, deletedKey, record, retention)
+			pipe.Del(ctx, tableKey)
+			return nil
+		})
+		return err
+	}
+
+	// Retry when the table config changed between the read and the write
+	for i := 0; i < 3; i++ {
+		err := s.client.Watch(ctx, remove, tableKey)
+		if err != redis.TxFailedErr {
+			if err != nil {
+				return nil, err
+			}
+			return deleted, nil
+		}
+	}
+	return nil, fmt.Errorf("table config %s changed concurrently, try again", tableName)
+}
+
+// RestoreTableConfig puts back the config of a table deleted with
+// SoftDeleteTableConfig. The table is restored disabled.
+func (s *RedisStore) RestoreTableConfig(ctx context.Context, tableName string) (*TableConfig, error) {
+	tableKey := fmt.Sprintf("%s:tables:%s", s.prefix, tableName)
+	deletedKey := fmt.Sprintf("%s:deleted_tables:%s", s.prefix, tableName)
+
+	var restored *TableConfig
+	restore := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, deletedKey).Result()
+		if err == redis.Nil {
+			return fmt.Errorf("%w: no deleted config of %s", ErrTableNotFound, tableName)
+		} else if err != nil {
+			return fmt.Errorf("failed to load deleted table: %w", err)
+		}
+		exists, err := tx.Exists(ctx, tableKey).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check table config: %w", err)
+		}
+		if exists > 0 {
+			return ErrTableExists
+		}
+
+		var deleted DeletedTable
+		if err := json.Unmarshal([]byte(data), &deleted); err != nil {
+			return fmt.Errorf("failed to unmarshal deleted table: %w", err)
+		}
+		tableConfig, err := json.Marshal(deleted.Config)
+		if err != nil {
+			return fmt.Errorf("failed to marshal table config: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, tableKey, tableConfig, 0)
+			pipe.Del(ctx, deletedKey)
+			return nil
+		})
+		restored = &deleted.Config
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		err := s.client.Watch(ctx, restore, tableKey, deletedKey)
+		if err != redis.TxFailedErr {
+			if err != nil {
+				return nil, err
+			}
+			return restored, nil
+		}
+	}
+	return nil, fmt.Errorf("table config %s changed concurrently, try again", tableName)
+}
+
+// SaveQueryRules saves the query rules list
+func (s *RedisStore) SaveQueryRules(ctx context.Context, rules []QueryRule) error {
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("failed to marshal query rules: %w", err)
+	}
+
+	key := fmt.Sprintf("%s:rules", s.prefix)
+	return s.client.Set(ctx, key, data, 0).Err()
+}
+
+// LoadQueryRules loads the query rules list
+func (s *RedisStore) LoadQueryRules(ctx context.Context) ([]QueryRule, error) {
+	key := fmt.Sprintf("%s:rules", s.prefix)
+
+	data, err := s.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return []QueryRule{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load query rules: %w", err)
+	}
+
+	var rules []QueryRule
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal query rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// HasQueryRules reports whether a query rules list has been saved to Redis
+func (s *RedisStore) HasQueryRules(ctx context.Context) (bool, error) {
+	key := fmt.Sprintf("%s:rules", s.prefix)
+
+	n, err := s.client.Exists(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check query rules: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ExportConfig returns the running configuration: the config saved with
+// SaveConfig with the tables and query rules currently stored in Redis
+func (s *RedisStore) ExportConfig(ctx context.Context) (*Config, error) {
+	cfg, err := s.LoadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := s.ListTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Tables = make(TablesConfig, len(tables))
+	for _, tableName := range tables {
+		tableConfig, err := s.LoadTableConfig(ctx, tableName)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Tables[tableName] = *tableConfig
+	}
+
+	hasRules, err := s.HasQueryRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if hasRules {
+		if cfg.QueryRules, err = s.LoadQueryRules(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// ImportConfig replaces the stored config, tables and query rules with cfg in
+// one transaction. Stored tables missing from cfg are deleted, and the table
+// toggles are reset to the imported tables' flags.
+func (s *RedisStore) ImportConfig(ctx context.Context, cfg *Config) error {
+	existing, err := s.ListTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	rules := cfg.QueryRules
+	if rules == nil {
+		rules = []QueryRule{}
+	}
+	rulesData, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("failed to marshal query rules: %w", err)
+	}
+	tables := make(map[string][]byte, len(cfg.Tables))
+	for tableName, tableConfig := range cfg.Tables {
+		if tables[tableName], err = json.Marshal(tableConfig); err != nil {
+			return fmt.Errorf("failed to marshal table config %s: %w", tableName, err)
+		}
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, fmt.Sprintf("%s:main", s.prefix), data, 0)
+		pipe.Set(ctx, fmt.Sprintf("%s:timestamp", s.prefix), time.Now().Unix(), 0)
+		pipe.Set(ctx, fmt.Sprintf("%s:rules", s.prefix), rulesData, 0)
+		for _, tableName := range existing {
+			if _, ok := tables[tableName]; !ok {
+				pipe.Del(ctx, fmt.Sprintf("%s:tables:%s", s.prefix, tableName))
+			}
+		}
+		for tableName, tableData := range tables {
+			pipe.Set(ctx, fmt.Sprintf("%s:tables:%s", s.prefix, tableName), tableData, 0)
+		}
+
+		// Proxies take the imported flags from the overrides they poll
+		enabledKey := fmt.Sprintf("%s:table_enabled", s.prefix)
+		rolloutKey := fmt.Sprintf("%s:table_rollout", s.prefix)
+		modeKey := fmt.Sprintf("%s:table_mode", s.prefix)
+		pipe.Del(ctx, enabledKey, rolloutKey, modeKey)
+		for tableName, tableConfig := range cfg.Tables {
+			pipe.HSet(ctx, enabledKey, tableName, strconv.FormatBool(tableConfig.Enabled))
+			if tableConfig.Rollout != nil {
+				pipe.HSet(ctx, rolloutKey, tableName, strconv.FormatFloat(tableConfig.Rollout.Percent, 'f', -1, 64))
+			}
+			if tableConfig.Mode != "" {
+				pipe.HSet(ctx, modeKey, tableName, tableConfig.Mode)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import config: %w", err)
+	}
+	return nil
+}
+
 // SyncTablesFromConfig syncs all table configurations from Config to Redis
 // This is typically called during startup to populate Redis with tables from config.yaml
 func (s *RedisStore) SyncTablesFromConfig(ctx context.Context, cfg *Config) error {
@@ -240,22 +631,522 @@ func (s *RedisStore) SyncTablesFromConfig(ctx context.Context, cfg *Config) erro
 	return nil
 }
 
-// Health checks Redis connection health
-func (s *RedisStore) Health(ctx context.Context) error {
-	return s.client.Ping(ctx).Err()
+// SetTableEnabled enables or disables dual-write for a table without touching
+// the rest of its config. The flag is recorded in the table toggles the proxy
+// polls and, when the table config is stored in Redis, in the config itself;
+// both change in one transaction.
+func (s *RedisStore) SetTableEnabled(ctx context.Context, tableName string, enabled bool) error {
+	return s.updateTable(ctx, tableName, "table_enabled", strconv.FormatBool(enabled), func(tc *TableConfig) {
+		tc.Enabled = enabled
+	})
 }
 
-// Close closes the Redis store and cleanup resources
-func (s *RedisStore) Close() error {
-	close(s.closeCh)
+// SetTableRollout changes the rollout percentage of a table like
+// SetTableEnabled changes its flag
+func (s *RedisStore) SetTableRollout(ctx context.Context, tableName string, percent float64) error {
+	return s.updateTable(ctx, tableName, "table_rollout", strconv.FormatFloat(percent, 'f', -1, 64), func(tc *TableConfig) {
+		if tc.Rollout == nil {
+			tc.Rollout = &RolloutConfig{}
+		}
+		tc.Rollout.Percent = percent
+	})
+}
+
+// SetTableMode switches a table between enforce and observe like
+// SetTableEnabled changes its flag
+func (s *RedisStore) SetTableMode(ctx context.Context, tableName, mode string) error {
+	return s.updateTable(ctx, tableName, "table_mode", mode, func(tc *TableConfig) {
+		tc.Mode = mode
+	})
+}
+
+// updateTable sets a table's field in the overrides hash the proxy polls and
+// applies the same change to the stored table config, if any, atomically
+func (s *RedisStore) updateTable(ctx context.Context, tableName, overrides, value string, apply func(*TableConfig)) error {
+	tableKey := fmt.Sprintf("%s:tables:%s", s.prefix, tableName)
+	overridesKey := fmt.Sprintf("%s:%s", s.prefix, overrides)
 
-	if s.pubsub != nil {
-		if err := s.pubsub.Close(); err != nil {
+	update := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, tableKey).Result()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("failed to load table config: %w", err)
+		}
+
+		var updated []byte
+		if err == nil {
+			var tableConfig TableConfig
+			if err := json.Unmarshal([]byte(data), &tableConfig); err != nil {
+				return fmt.Errorf("failed to unmarshal table config: %w", err)
+			}
+			apply(&tableConfig)
+			if updated, err = json.Marshal(tableConfig); err != nil {
+				return fmt.Errorf("failed to marshal table config: %w", err)
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if updated != nil {
+				pipe.Set(ctx, tableKey, updated, 0)
+			}
+			pipe.HSet(ctx, overridesKey, tableName, value)
+			return nil
+		})
+		return err
+	}
+
+	// Retry when the table config changed between the read and the write
+	for i := 0; i < 3; i++ {
+		err := s.client.Watch(ctx, update, tableKey)
+		if err != redis.TxFailedErr {
 			return err
 		}
 	}
+	return fmt.Errorf("table config %s changed concurrently, try again", tableName)
+}
+
+// LoadTableToggles returns the tables enabled or disabled with SetTableEnabled
+func (s *RedisStore) LoadTableToggles(ctx context.Context) (map[string]bool, error) {
+	key := fmt.Sprintf("%s:table_enabled", s.prefix)
+
+	values, err := s.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load table toggles: %w", err)
+	}
+
+	toggles := make(map[string]bool, len(values))
+	for table, value := range values {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid toggle for table %s: %q", table, value)
+		}
+		toggles[table] = enabled
+	}
+	return toggles, nil
+}
+
+// LoadTableRollouts returns the rollout percentages set with SetTableRollout
+func (s *RedisStore) LoadTableRollouts(ctx context.Context) (map[string]float64, error) {
+	key := fmt.Sprintf("%s:table_rollout", s.prefix)
+
+	values, err := s.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load table rollouts: %w", err)
+	}
+
+	rollouts := make(map[string]float64, len(values))
+	for table, value := range values {
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rollout for table %s: %q", table, value)
+		}
+		rollouts[table] = percent
+	}
+	return rollouts, nil
+}
+
+// LoadTableModes returns the table modes set with SetTableMode
+func (s *RedisStore) LoadTableModes(ctx context.Context) (map[string]string, error) {
+	key := fmt.Sprintf("%s:table_mode", s.prefix)
+
+	modes, err := s.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load table modes: %w", err)
+	}
+	for table, mode := range modes {
+		if !ValidTableMode(mode) {
+			return nil, fmt.Errorf("invalid mode for table %s: %q", table, mode)
+		}
+	}
+	return modes, nil
+}
+
+// BreakGlass is the emergency switch that puts every proxy into raw
+// pass-through: statements are forwarded without parsing or rewriting until
+// the switch is turned off or expires
+type BreakGlass struct {
+	Actor   string    `json:"actor"`
+	Reason  string    `json:"reason"`
+	Started time.Time `json:"started"`
+	Expires time.Time `json:"expires"`
+}
+
+// Break glass durations
+const (
+	DefaultBreakGlassDuration = 15 * time.Minute
+	MaxBreakGlassDuration     = 4 * time.Hour
+)
+
+// NewBreakGlass returns the switch turned on now by actor for duration, or
+// for DefaultBreakGlassDuration when duration is 0
+func NewBreakGlass(actor, reason string, duration time.Duration) (*BreakGlass, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("break glass needs a reason")
+	}
+	if duration == 0 {
+		duration = DefaultBreakGlassDuration
+	}
+	if duration < time.Minute || duration > MaxBreakGlassDuration {
+		return nil, fmt.Errorf("break glass lasts between 1 and %d minutes, got %v",
+			int(MaxBreakGlassDuration/time.Minute), duration)
+	}
+	now := time.Now().UTC()
+	return &BreakGlass{Actor: actor, Reason: reason, Started: now, Expires: now.Add(duration)}, nil
+}
+
+// Active reports whether the switch is on at now
+func (b *BreakGlass) Active(now time.Time) bool {
+	return b != nil && now.Before(b.Expires)
+}
+
+// Detail describes the switch in its audit entry
+func (b *BreakGlass) Detail() string {
+	return fmt.Sprintf("until %s: %s", b.Expires.UTC().Format(time.RFC3339), b.Reason)
+}
+
+// SetBreakGlass turns the break glass switch on for every proxy. Redis
+// removes it when it expires.
+func (s *RedisStore) SetBreakGlass(ctx context.Context, breakGlass *BreakGlass) error {
+	key := fmt.Sprintf("%s:break_glass", s.prefix)
+
+	ttl := time.Until(breakGlass.Expires)
+	if ttl <= 0 {
+		return fmt.Errorf("break glass already expired at %s", breakGlass.Expires.Format(time.RFC3339))
+	}
+	data, err := json.Marshal(breakGlass)
+	if err != nil {
+		return fmt.Errorf("failed to marshal break glass: %w", err)
+	}
+	if err := s.client.Set(ctx, key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save break glass: %w", err)
+	}
+	return nil
+}
+
+// ClearBreakGlass turns the break glass switch off and returns the switch
+// that was on, nil when it was off
+func (s *RedisStore) ClearBreakGlass(ctx context.Context) (*BreakGlass, error) {
+	key := fmt.Sprintf("%s:break_glass", s.prefix)
+
+	data, err := s.client.GetDel(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear break glass: %w", err)
+	}
+	var breakGlass BreakGlass
+	if err := json.Unmarshal(data, &breakGlass); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal break glass: %w", err)
+	}
+	return &breakGlass, nil
+}
 
-	close(s.reloadCh)
+// LoadBreakGlass returns the break glass switch, nil when it is off
+func (s *RedisStore) LoadBreakGlass(ctx context.Context) (*BreakGlass, error) {
+	key := fmt.Sprintf("%s:break_glass", s.prefix)
+
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load break glass: %w", err)
+	}
+	var breakGlass BreakGlass
+	if err := json.Unmarshal(data, &breakGlass); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal break glass: %w", err)
+	}
+	return &breakGlass, nil
+}
+
+// StageConfig stages a config version, replacing the one staged before, and
+// numbers it after every version staged so far
+func (s *RedisStore) StageConfig(ctx context.Context, staged *StagedConfig) error {
+	version, err := s.client.Incr(ctx, fmt.Sprintf("%s:versions:seq", s.prefix)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to number config version: %w", err)
+	}
+	staged.Version = version
+
+	data, err := json.Marshal(staged)
+	if err != nil {
+		return fmt.Errorf("failed to marshal staged config: %w", err)
+	}
+	if err := s.client.Set(ctx, fmt.Sprintf("%s:versions:staged", s.prefix), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save staged config: %w", err)
+	}
+	return nil
+}
+
+// LoadStagedConfig returns the staged config version, nil when none is staged
+func (s *RedisStore) LoadStagedConfig(ctx context.Context) (*StagedConfig, error) {
+	data, err := s.client.Get(ctx, fmt.Sprintf("%s:versions:staged", s.prefix)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load staged config: %w", err)
+	}
+	var staged StagedConfig
+	if err := json.Unmarshal(data, &staged); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal staged config: %w", err)
+	}
+	return &staged, nil
+}
+
+// AbortStagedConfig removes the staged config version and returns it
+func (s *RedisStore) AbortStagedConfig(ctx context.Context) (*StagedConfig, error) {
+	data, err := s.client.GetDel(ctx, fmt.Sprintf("%s:versions:staged", s.prefix)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNoStagedConfig
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to abort staged config: %w", err)
+	}
+	var staged StagedConfig
+	if err := json.Unmarshal(data, &staged); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal staged config: %w", err)
+	}
+	return &staged, nil
+}
+
+// LoadActiveVersion returns the config version promoted last, nil while the
+// proxies run the config file
+func (s *RedisStore) LoadActiveVersion(ctx context.Context) (*ConfigVersion, error) {
+	data, err := s.client.Get(ctx, fmt.Sprintf("%s:versions:active", s.prefix)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load active config version: %w", err)
+	}
+	var active ConfigVersion
+	if err := json.Unmarshal(data, &active); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal active config version: %w", err)
+	}
+	return &active, nil
+}
+
+// PromoteStagedConfig makes the staged config version the active one. When
+// version is set it must be the staged version. The tables, conversion and
+// query rules of the version are also saved as the stored config, its table
+// configs and its query rules.
+func (s *RedisStore) PromoteStagedConfig(ctx context.Context, version int64) (*ConfigVersion, error) {
+	stagedKey := fmt.Sprintf("%s:versions:staged", s.prefix)
+	mainKey := fmt.Sprintf("%s:main", s.prefix)
+	existing, err := s.ListTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var promoted *ConfigVersion
+	promote := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, stagedKey).Bytes()
+		if err == redis.Nil {
+			return ErrNoStagedConfig
+		} else if err != nil {
+			return fmt.Errorf("failed to load staged config: %w", err)
+		}
+		var staged StagedConfig
+		if err := json.Unmarshal(data, &staged); err != nil {
+			return fmt.Errorf("failed to unmarshal staged config: %w", err)
+		}
+		if version != 0 && version != staged.Version {
+			return fmt.Errorf("%w: version %d is staged, not %d", ErrStagedConfigChanged, staged.Version, version)
+		}
+
+		active, err := json.Marshal(staged.ConfigVersion)
+		if err != nil {
+			return fmt.Errorf("failed to marshal config version: %w", err)
+		}
+		var mainData []byte
+		if data, err := tx.Get(ctx, mainKey).Bytes(); err == nil {
+			var cfg Config
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				return fmt.Errorf("failed to unmarshal config: %w", err)
+			}
+			if mainData, err = json.Marshal(staged.Apply(&cfg)); err != nil {
+				return fmt.Errorf("failed to marshal config: %w", err)
+			}
+		} else if err != redis.Nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		var rulesData []byte
+		if staged.QueryRules != nil {
+			if rulesData, err = json.Marshal(staged.QueryRules); err != nil {
+				return fmt.Errorf("failed to marshal query rules: %w", err)
+			}
+		}
+		tables := make(map[string][]byte, len(staged.Tables))
+		for tableName, tableConfig := range staged.Tables {
+			if tables[tableName], err = json.Marshal(tableConfig); err != nil {
+				return fmt.Errorf("failed to marshal table config %s: %w", tableName, err)
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, fmt.Sprintf("%s:versions:active", s.prefix), active, 0)
+			pipe.Del(ctx, stagedKey)
+			if mainData != nil {
+				pipe.Set(ctx, mainKey, mainData, 0)
+				pipe.Set(ctx, fmt.Sprintf("%s:timestamp", s.prefix), time.Now().Unix(), 0)
+			}
+			if rulesData != nil {
+				pipe.Set(ctx, fmt.Sprintf("%s:rules", s.prefix), rulesData, 0)
+			}
+			for _, tableName := range existing {
+				if _, ok := tables[tableName]; !ok {
+					pipe.Del(ctx, fmt.Sprintf("%s:tables:%s", s.prefix, tableName))
+				}
+			}
+			for tableName, tableData := range tables {
+				pipe.Set(ctx, fmt.Sprintf("%s:tables:%s", s.prefix, tableName), tableData, 0)
+			}
+			return nil
+		})
+		promoted = &staged.ConfigVersion
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		err := s.client.Watch(ctx, promote, stagedKey, mainKey)
+		if err != redis.TxFailedErr {
+			if err != nil {
+				return nil, err
+			}
+			return promoted, nil
+		}
+	}
+	return nil, fmt.Errorf("staged config changed concurrently, try again")
+}
+
+// AuditEntry records a change made to the running configuration. Entries are
+// numbered and chained: each holds the hash of the previous one, and its
+// own hash, signed when an audit key is set.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Detail string    `json:"detail,omitempty"`
+
+	Seq      int64  `json:"seq,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+	MAC      string `json:"mac,omitempty"`
+}
+
+// auditLogSize is how many audit entries are kept
+const auditLogSize = 1000
+
+// auditAppendAttempts bounds the retries of an append racing another one
+const auditAppendAttempts = 5
+
+// SetAuditKey signs the audit entries appended from now on with key
+func (s *RedisStore) SetAuditKey(key []byte) {
+	s.auditKey = key
+}
+
+// AppendAudit adds an entry to the audit log, chained to the newest entry,
+// keeping the newest entries
+func (s *RedisStore) AppendAudit(ctx context.Context, entry AuditEntry) error {
+	key := fmt.Sprintf("%s:audit", s.prefix)
+	headKey := fmt.Sprintf("%s:audit_head", s.prefix)
+
+	appendEntry := func(tx *redis.Tx) error {
+		var head AuditHead
+		data, err := tx.Get(ctx, headKey).Bytes()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("failed to load audit head: %w", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &head); err != nil {
+				return fmt.Errorf("failed to unmarshal audit head: %w", err)
+			}
+		}
+
+		entry.chain(head, s.auditKey)
+		data, err = json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit entry: %w", err)
+		}
+		headData, err := json.Marshal(AuditHead{Seq: entry.Seq, Hash: entry.Hash})
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit head: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.LPush(ctx, key, data)
+			pipe.LTrim(ctx, key, 0, auditLogSize-1)
+			pipe.Set(ctx, headKey, headData, 0)
+			return nil
+		})
+		return err
+	}
+
+	// Retry when another entry was appended between the read and the write
+	for i := 0; i < auditAppendAttempts; i++ {
+		err := s.client.Watch(ctx, appendEntry, headKey)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return fmt.Errorf("audit log changed concurrently, entry not recorded")
+}
+
+// VerifyAudit checks the whole audit log against its head with the key set
+// by SetAuditKey; see VerifyAudit
+func (s *RedisStore) VerifyAudit(ctx context.Context) (AuditVerification, error) {
+	entries, err := s.LoadAudit(ctx, auditLogSize)
+	if err != nil {
+		return AuditVerification{}, err
+	}
+
+	var head *AuditHead
+	data, err := s.client.Get(ctx, fmt.Sprintf("%s:audit_head", s.prefix)).Bytes()
+	switch {
+	case err == redis.Nil:
+	case err != nil:
+		return AuditVerification{}, fmt.Errorf("failed to load audit head: %w", err)
+	default:
+		head = &AuditHead{}
+		if err := json.Unmarshal(data, head); err != nil {
+			return AuditVerification{}, fmt.Errorf("failed to unmarshal audit head: %w", err)
+		}
+	}
+	return VerifyAudit(entries, head, s.auditKey), nil
+}
+
+// LoadAudit returns up to limit audit entries, newest first
+func (s *RedisStore) LoadAudit(ctx context.Context, limit int) ([]AuditEntry, error) {
+	key := fmt.Sprintf("%s:audit", s.prefix)
+
+	values, err := s.client.LRange(ctx, key, 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit log: %w", err)
+	}
+
+	entries := make([]AuditEntry, 0, len(values))
+	for _, value := range values {
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Health checks Redis connection health
+func (s *RedisStore) Health(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Close stops the config watchers, waits for them to return and closes the
+// Redis client
+func (s *RedisStore) Close() error {
+	s.cancel()
+	s.watchers.Wait()
 
 	return s.client.Close()
 }
@@ -264,3 +1155,8 @@ func (s *RedisStore) Close() error {
 func (s *RedisStore) Stats() *redis.PoolStats {
 	return s.client.PoolStats()
 }
+
+// Client returns the underlying client for features that keep their own keys
+func (s *RedisStore) Client() redis.UniversalClient {
+	return s.client
+}
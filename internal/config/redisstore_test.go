@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -183,6 +185,10 @@ func TestTableConfigOperations(t *testing.T) {
 	require.NoError(t, err)
 	assert.Contains(t, tables, "products")
 
+	stored, err := store.LoadTables(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, stored["products"].Columns, "price")
+
 	// Delete table config
 	err = store.DeleteTableConfig(ctx, "products")
 	require.NoError(t, err)
@@ -192,6 +198,213 @@ func TestTableConfigOperations(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestSoftDeleteTableConfig(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	store, err := NewRedisStore(getTestRedisConfig())
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	defer store.DeleteTableConfig(ctx, "refunds")
+
+	_, err = store.SoftDeleteTableConfig(ctx, "refunds", "api:test", time.Hour)
+	assert.ErrorIs(t, err, ErrTableNotFound)
+
+	require.NoError(t, store.SaveTableConfig(ctx, "refunds", TableConfig{Enabled: true, FailurePolicy: FailOpen}))
+	_, err = store.SoftDeleteTableConfig(ctx, "refunds", "api:test", time.Hour)
+	assert.ErrorIs(t, err, ErrTableEnabled, "live tables must be disabled first")
+
+	require.NoError(t, store.SetTableEnabled(ctx, "refunds", false))
+	deleted, err := store.SoftDeleteTableConfig(ctx, "refunds", "api:test", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "api:test", deleted.DeletedBy)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deleted.ExpiresAt, time.Minute)
+	_, err = store.LoadTableConfig(ctx, "refunds")
+	assert.Error(t, err)
+
+	restored, err := store.RestoreTableConfig(ctx, "refunds")
+	require.NoError(t, err)
+	assert.False(t, restored.Enabled)
+	assert.Equal(t, FailOpen, restored.FailurePolicy)
+	_, err = store.RestoreTableConfig(ctx, "refunds")
+	assert.ErrorIs(t, err, ErrTableNotFound)
+
+	_, err = store.SoftDeleteTableConfig(ctx, "refunds", "api:test", time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveTableConfig(ctx, "refunds", TableConfig{}))
+	_, err = store.RestoreTableConfig(ctx, "refunds")
+	assert.ErrorIs(t, err, ErrTableExists)
+}
+
+func TestQueryRulesOperations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	cfg := getTestRedisConfig()
+	store, err := NewRedisStore(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	require.NoError(t, store.client.Del(ctx, ConfigKeyPrefix+":rules").Err())
+
+	exists, err := store.HasQueryRules(ctx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	rules := []QueryRule{{ID: 10, Active: true, MatchDigest: "^DELETE", Action: RuleActionBlock}}
+	require.NoError(t, store.SaveQueryRules(ctx, rules))
+
+	exists, err = store.HasQueryRules(ctx)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	loaded, err := store.LoadQueryRules(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, rules, loaded)
+}
+
+func TestTableToggleOperations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	cfg := getTestRedisConfig()
+	store, err := NewRedisStore(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	require.NoError(t, store.client.Del(ctx, ConfigKeyPrefix+":table_enabled", ConfigKeyPrefix+":table_rollout",
+		ConfigKeyPrefix+":table_mode", ConfigKeyPrefix+":audit", ConfigKeyPrefix+":audit_head").Err())
+
+	tableConfig := TableConfig{Enabled: true, Columns: map[string]ColumnConfig{"price": {TargetColumn: "price_idn"}}}
+	require.NoError(t, store.SaveTableConfig(ctx, "products", tableConfig))
+	defer store.DeleteTableConfig(ctx, "products")
+
+	// Disabling keeps the rest of the table config
+	require.NoError(t, store.SetTableEnabled(ctx, "products", false))
+	loaded, err := store.LoadTableConfig(ctx, "products")
+	require.NoError(t, err)
+	assert.False(t, loaded.Enabled)
+	assert.Equal(t, "price_idn", loaded.Columns["price"].TargetColumn)
+
+	// Tables without a stored config are toggled too
+	require.NoError(t, store.SetTableEnabled(ctx, "orders", true))
+
+	toggles, err := store.LoadTableToggles(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"products": false, "orders": true}, toggles)
+
+	require.NoError(t, store.SetTableRollout(ctx, "products", 12.5))
+	loaded, err = store.LoadTableConfig(ctx, "products")
+	require.NoError(t, err)
+	require.NotNil(t, loaded.Rollout)
+	assert.Equal(t, 12.5, loaded.Rollout.Percent)
+	rollouts, err := store.LoadTableRollouts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"products": 12.5}, rollouts)
+
+	require.NoError(t, store.SetTableMode(ctx, "products", TableModeObserve))
+	loaded, err = store.LoadTableConfig(ctx, "products")
+	require.NoError(t, err)
+	assert.Equal(t, TableModeObserve, loaded.Mode)
+	modes, err := store.LoadTableModes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"products": TableModeObserve}, modes)
+
+	require.NoError(t, store.AppendAudit(ctx, AuditEntry{Time: time.Now(), Actor: "api", Action: "disable_table", Target: "products"}))
+	require.NoError(t, store.AppendAudit(ctx, AuditEntry{Time: time.Now(), Actor: "api", Action: "enable_table", Target: "orders"}))
+	entries, err := store.LoadAudit(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "enable_table", entries[0].Action)
+}
+
+func TestAuditChain(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	cfg := getTestRedisConfig()
+	store, err := NewRedisStore(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+	defer store.Close()
+	store.SetAuditKey([]byte("0123456789abcdef"))
+
+	ctx := context.Background()
+	require.NoError(t, store.client.Del(ctx, ConfigKeyPrefix+":audit", ConfigKeyPrefix+":audit_head").Err())
+	for _, action := range []string{"disable_table", "enable_table", "set_rollout"} {
+		require.NoError(t, store.AppendAudit(ctx, AuditEntry{Time: time.Now(), Actor: "test", Action: action, Target: "orders"}))
+	}
+
+	v, err := store.VerifyAudit(ctx)
+	require.NoError(t, err)
+	assert.True(t, v.OK(), v.Problems)
+	assert.Equal(t, int64(3), v.LastSeq)
+
+	// Dropping the newest entry is truncation
+	require.NoError(t, store.client.LPop(ctx, ConfigKeyPrefix+":audit").Err())
+	v, err = store.VerifyAudit(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"the log was truncated: the newest entry is 2 but the head is 3"}, v.Problems)
+}
+
+func TestExportImportConfig(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	store, err := NewRedisStore(getTestRedisConfig())
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	cfg := bundleTestConfig()
+	require.NoError(t, store.SaveConfig(ctx, cfg))
+	require.NoError(t, store.SaveTableConfig(ctx, "legacy_invoices", TableConfig{Enabled: true}))
+	defer store.DeleteTableConfig(ctx, "legacy_invoices")
+	defer store.DeleteTableConfig(ctx, "orders")
+
+	require.NoError(t, store.ImportConfig(ctx, cfg))
+	exported, err := store.ExportConfig(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders"}, keys(exported.Tables))
+	assert.Len(t, exported.QueryRules, 1)
+	assert.True(t, PlanImport(exported, cfg).Empty())
+
+	toggles, err := store.LoadTableToggles(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"orders": true}, toggles)
+}
+
+func keys(tables TablesConfig) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	return names
+}
+
 func TestWatchConfigChanges(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping Redis integration test in short mode")
@@ -237,6 +450,69 @@ func TestWatchConfigChanges(t *testing.T) {
 	}
 }
 
+func TestWatchConfigChanges_CloseStopsWatcher(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	store, err := NewRedisStore(getTestRedisConfig())
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+
+	reloadCh, err := store.WatchConfigChanges(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.ConfigWatcherUp))
+
+	// Notifications arriving during shutdown must not panic
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.PublishReload(context.Background()))
+	}
+	require.NoError(t, store.Close())
+
+	for range reloadCh {
+	}
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.ConfigWatcherUp))
+}
+
+func TestWatchConfigChanges_Resubscribes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	store, err := NewRedisStore(getTestRedisConfig())
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, store.SaveConfig(ctx, &Config{Conversion: ConversionConfig{Ratio: 1000}}))
+
+	reloadCh, err := store.WatchConfigChanges(ctx)
+	require.NoError(t, err)
+
+	// Drop the subscriber connection; the watcher resubscribes and reloads
+	require.NoError(t, store.client.Do(ctx, "CLIENT", "KILL", "TYPE", "pubsub").Err())
+	select {
+	case newConfig := <-reloadCh:
+		assert.Equal(t, 1000, newConfig.Conversion.Ratio)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the reload after resubscribing")
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.ConfigWatcherUp))
+
+	require.NoError(t, store.PublishReload(ctx))
+	select {
+	case <-reloadCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for config reload")
+	}
+}
+
 func TestRedisStoreStats(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping Redis integration test in short mode")
@@ -255,3 +531,120 @@ func TestRedisStoreStats(t *testing.T) {
 	// Stats should show at least some activity
 	assert.GreaterOrEqual(t, stats.TotalConns, uint32(0))
 }
+
+func TestBreakGlassOperations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	cfg := getTestRedisConfig()
+	store, err := NewRedisStore(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_, err = store.ClearBreakGlass(ctx)
+	require.NoError(t, err)
+
+	state, err := store.LoadBreakGlass(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	on, err := NewBreakGlass("test", "incident", 10*time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, store.SetBreakGlass(ctx, on))
+	ttl, err := store.client.TTL(ctx, ConfigKeyPrefix+":break_glass").Result()
+	require.NoError(t, err)
+	assert.InDelta(t, (10 * time.Minute).Seconds(), ttl.Seconds(), 5)
+
+	state, err = store.LoadBreakGlass(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, "incident", state.Reason)
+	assert.True(t, state.Expires.Equal(on.Expires))
+
+	cleared, err := store.ClearBreakGlass(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, cleared)
+	state, err = store.LoadBreakGlass(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestNewBreakGlass(t *testing.T) {
+	state, err := NewBreakGlass("api", "incident", 0)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultBreakGlassDuration, state.Expires.Sub(state.Started))
+	assert.True(t, state.Active(time.Now()))
+	assert.False(t, state.Active(state.Expires))
+
+	_, err = NewBreakGlass("api", "", time.Hour)
+	assert.Error(t, err, "a reason is required")
+	_, err = NewBreakGlass("api", "incident", 30*time.Second)
+	assert.Error(t, err)
+	_, err = NewBreakGlass("api", "incident", MaxBreakGlassDuration+time.Minute)
+	assert.Error(t, err)
+}
+
+func TestStagedConfigOperations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	cfg := getTestRedisConfig()
+	store, err := NewRedisStore(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+		return
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	require.NoError(t, store.client.Del(ctx, ConfigKeyPrefix+":versions:staged", ConfigKeyPrefix+":versions:active").Err())
+	_, err = store.PromoteStagedConfig(ctx, 0)
+	assert.ErrorIs(t, err, ErrNoStagedConfig)
+	_, err = store.AbortStagedConfig(ctx)
+	assert.ErrorIs(t, err, ErrNoStagedConfig)
+
+	base := &Config{Conversion: ConversionConfig{Ratio: 1000, Precision: 4}, Tables: TablesConfig{}}
+	require.NoError(t, store.SaveConfig(ctx, base))
+
+	tables := TablesConfig{"orders": {Enabled: true}}
+	first := &StagedConfig{ConfigVersion: ConfigVersion{Tables: tables, Conversion: base.Conversion}, Percent: 10}
+	require.NoError(t, store.StageConfig(ctx, first))
+	second := &StagedConfig{ConfigVersion: ConfigVersion{Tables: tables, Conversion: base.Conversion}, CanaryIPs: []string{"10.0.0.5"}}
+	require.NoError(t, store.StageConfig(ctx, second))
+	assert.Greater(t, second.Version, first.Version)
+
+	staged, err := store.LoadStagedConfig(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, staged)
+	assert.Equal(t, second.Version, staged.Version)
+	assert.Equal(t, []string{"10.0.0.5"}, staged.CanaryIPs)
+
+	// Promoting the version reviewed before it was restaged fails
+	_, err = store.PromoteStagedConfig(ctx, first.Version)
+	assert.ErrorIs(t, err, ErrStagedConfigChanged)
+
+	promoted, err := store.PromoteStagedConfig(ctx, second.Version)
+	require.NoError(t, err)
+	assert.Equal(t, second.Version, promoted.Version)
+
+	active, err := store.LoadActiveVersion(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, active)
+	assert.Equal(t, second.Version, active.Version)
+	staged, err = store.LoadStagedConfig(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, staged)
+
+	stored, err := store.LoadConfig(ctx)
+	require.NoError(t, err)
+	assert.True(t, stored.Tables["orders"].Enabled)
+	table, err := store.LoadTableConfig(ctx, "orders")
+	require.NoError(t, err)
+	assert.True(t, table.Enabled)
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+)
+
+// ConfigVersion is a version of the settings new sessions are created with.
+// Version 0 is the config file the proxy started with.
+type ConfigVersion struct {
+	Version    int64            `json:"version"`
+	Tables     TablesConfig     `json:"tables"`
+	Conversion ConversionConfig `json:"conversion"`
+	// QueryRules replace the rules saved through the API for the sessions on
+	// the version; nil keeps the saved rules
+	QueryRules []QueryRule `json:"query_rules,omitempty"`
+	CreatedBy  string      `json:"created_by"`
+	Created    time.Time   `json:"created"`
+}
+
+// Apply returns a copy of cfg with the version's tables, conversion and query
+// rules
+func (v *ConfigVersion) Apply(cfg *Config) *Config {
+	out := *cfg
+	out.Tables = v.Tables
+	out.Conversion = v.Conversion
+	if v.QueryRules != nil {
+		out.QueryRules = v.QueryRules
+	}
+	return &out
+}
+
+// StagedConfig is a config version tried on some new sessions before it is
+// promoted: sessions from a canary address and a percentage of the others
+// use it, the rest stay on the active version
+type StagedConfig struct {
+	ConfigVersion
+	// CanaryIPs are client addresses or CIDR ranges whose new sessions use
+	// the staged version
+	CanaryIPs []string `json:"canary_ips,omitempty"`
+	// Percent of the other new sessions that use the staged version
+	Percent float64 `json:"percent"`
+}
+
+// Staged config errors
+var (
+	// ErrNoStagedConfig is returned when promoting or aborting without a
+	// staged version
+	ErrNoStagedConfig = errs.New(errs.ConfigNotFound, "no config version is staged")
+	// ErrStagedConfigChanged is returned when promoting a version that was
+	// replaced by a newer staged version
+	ErrStagedConfigChanged = errs.New(errs.ConfigConflict, "a different config version is staged")
+)
+
+// Validate checks that the staged version targets some sessions
+func (s *StagedConfig) Validate() error {
+	if s.Percent < 0 || s.Percent > 100 {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+	if len(s.CanaryIPs) == 0 && s.Percent == 0 {
+		return fmt.Errorf("canary_ips or percent is required")
+	}
+	if _, err := s.CanaryNets(); err != nil {
+		return err
+	}
+	return ValidateQueryRules(s.QueryRules)
+}
+
+// CanaryNets parses CanaryIPs; a single address is a network of one
+func (s *StagedConfig) CanaryNets() ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(s.CanaryIPs))
+	for _, entry := range s.CanaryIPs {
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid canary range %q", entry)
+			}
+			nets = append(nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid canary address %q", entry)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
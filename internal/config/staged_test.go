@@ -0,0 +1,70 @@
+package config
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagedConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		staged  StagedConfig
+		wantErr bool
+	}{
+		{"percent", StagedConfig{Percent: 10}, false},
+		{"canary", StagedConfig{CanaryIPs: []string{"10.0.0.5", "192.168.1.0/24", "::1"}}, false},
+		{"no target", StagedConfig{}, true},
+		{"percent above 100", StagedConfig{Percent: 101}, true},
+		{"negative percent", StagedConfig{Percent: -1}, true},
+		{"invalid address", StagedConfig{CanaryIPs: []string{"10.0.0"}}, true},
+		{"invalid range", StagedConfig{CanaryIPs: []string{"10.0.0.0/33"}}, true},
+		{"invalid rule", StagedConfig{Percent: 10, ConfigVersion: ConfigVersion{QueryRules: []QueryRule{{ID: 1, MatchPattern: "("}}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.staged.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStagedConfig_CanaryNets(t *testing.T) {
+	staged := StagedConfig{CanaryIPs: []string{"10.0.0.5", "192.168.1.0/24"}}
+	nets, err := staged.CanaryNets()
+	require.NoError(t, err)
+	require.Len(t, nets, 2)
+
+	assert.True(t, nets[0].Contains(net.ParseIP("10.0.0.5")))
+	assert.False(t, nets[0].Contains(net.ParseIP("10.0.0.6")))
+	assert.True(t, nets[1].Contains(net.ParseIP("192.168.1.77")))
+}
+
+func TestConfigVersion_Apply(t *testing.T) {
+	cfg := &Config{
+		Proxy:      ProxyConfig{Port: 3308},
+		Conversion: ConversionConfig{Ratio: 1000, Precision: 4},
+		Tables:     TablesConfig{"orders": {Enabled: true}},
+		QueryRules: []QueryRule{{ID: 1}},
+	}
+	v := &ConfigVersion{
+		Version:    3,
+		Conversion: ConversionConfig{Ratio: 100, Precision: 2},
+		Tables:     TablesConfig{"invoices": {Enabled: true}},
+	}
+
+	applied := v.Apply(cfg)
+	assert.Equal(t, 3308, applied.Proxy.Port)
+	assert.Equal(t, 100, applied.Conversion.Ratio)
+	assert.Contains(t, applied.Tables, "invoices")
+	assert.NotContains(t, applied.Tables, "orders")
+	assert.Len(t, applied.QueryRules, 1, "a version without rules keeps them")
+	assert.Equal(t, 1000, cfg.Conversion.Ratio, "the original config is unchanged")
+}
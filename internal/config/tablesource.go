@@ -0,0 +1,52 @@
+package config
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Table sources reported by MergeTables
+const (
+	// TableSourceYAML is a table defined in the config file, with no
+	// differing config stored in Redis
+	TableSourceYAML = "yaml"
+	// TableSourceRedis is a table only stored in Redis, added through the API
+	TableSourceRedis = "redis"
+	// TableSourceOverridden is a table of the config file whose config in
+	// Redis differs from the file; the Redis config is the one in effect
+	TableSourceOverridden = "overridden"
+)
+
+// TableSource names a table and where its config comes from
+type TableSource struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// MergeTables lists the tables of the config file and those stored in Redis,
+// sorted by name. Tables synced from the file unchanged count as yaml.
+func MergeTables(file TablesConfig, stored map[string]TableConfig) []TableSource {
+	tables := make([]TableSource, 0, len(file)+len(stored))
+	for name, fileConfig := range file {
+		source := TableSourceYAML
+		if storedConfig, ok := stored[name]; ok && !sameTableConfig(fileConfig, storedConfig) {
+			source = TableSourceOverridden
+		}
+		tables = append(tables, TableSource{Name: name, Source: source})
+	}
+	for name := range stored {
+		if _, ok := file[name]; !ok {
+			tables = append(tables, TableSource{Name: name, Source: TableSourceRedis})
+		}
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables
+}
+
+// sameTableConfig compares table configs in the JSON form they are stored in
+func sameTableConfig(a, b TableConfig) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(dataA) == string(dataB)
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeTables(t *testing.T) {
+	orders := TableConfig{Enabled: true, Columns: map[string]ColumnConfig{
+		"total_amount": {SourceColumn: "total_amount", TargetColumn: "total_amount_idn", Precision: 4},
+	}}
+	invoices := TableConfig{Enabled: true}
+	disabledInvoices := invoices
+	disabledInvoices.Enabled = false
+
+	file := TablesConfig{"orders": orders, "invoices": invoices, "refunds": {}}
+	stored := map[string]TableConfig{
+		"orders":   orders,
+		"invoices": disabledInvoices,
+		"payments": {Enabled: true},
+	}
+
+	assert.Equal(t, []TableSource{
+		{Name: "invoices", Source: TableSourceOverridden},
+		{Name: "orders", Source: TableSourceYAML},
+		{Name: "payments", Source: TableSourceRedis},
+		{Name: "refunds", Source: TableSourceYAML},
+	}, MergeTables(file, stored))
+
+	assert.Empty(t, MergeTables(nil, nil))
+}
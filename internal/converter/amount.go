@@ -0,0 +1,239 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// ToIDN divides an IDR amount of a column by ratio and records the result in
+// the column's converted amount distribution. Rounding happens when the
+// shadow value is formatted.
+func ToIDN(table, column string, amount float64, ratio int) float64 {
+	converted := amount / float64(ratio)
+	metrics.RecordConvertedAmount(table, column, converted)
+	return converted
+}
+
+// AmountParser converts currency values extracted from statements into
+// float64 without truncating fractional IDR amounts, accepting the digit
+// separators of its locales in string values
+type AmountParser struct {
+	// id accepts '.' grouping and ',' decimals, en ',' grouping and '.'
+	// decimals
+	id, en bool
+}
+
+// NewAmountParser returns a parser accepting the locales of
+// conversion.amount_locales; none accepts both id and en
+func NewAmountParser(locales []string) AmountParser {
+	if len(locales) == 0 {
+		return AmountParser{id: true, en: true}
+	}
+	var p AmountParser
+	for _, locale := range locales {
+		switch locale {
+		case config.AmountLocaleID:
+			p.id = true
+		case config.AmountLocaleEN:
+			p.en = true
+		}
+	}
+	return p
+}
+
+// ParseAmount parses a value accepting both id and en separators
+func ParseAmount(value interface{}) (float64, error) {
+	return NewAmountParser(nil).Parse(value)
+}
+
+// ParseStoredAmount parses an amount read from a numeric column, which MySQL
+// returns as a plain numeral such as "1500000" or "1500.500". Backfill, repair
+// and reconcile read source values with it, so DECIMAL sources with fractional
+// amounts convert as they do in the proxy.
+func ParseStoredAmount(raw string) (float64, error) {
+	// A '.' is always the decimal point, never grouping
+	return NewAmountParser([]string{config.AmountLocaleEN}).Parse(raw)
+}
+
+// Parse converts a value. String values are parsed as follows:
+//   - "1500000", "200.9", "-1e6" and "1.5e6" are plain numerals, always
+//     accepted
+//   - "1.500.000" and "1,500,000" use a repeated separator for grouping
+//   - "1.500.000,50" and "1,500,000.50" use the last separator as the decimal point
+//   - a single ',' is the Indonesian decimal comma ("200,9", "1500000,00")
+//   - a single separator followed by exactly three digits ("1.500", "1,500")
+//     is grouping in the only locale grouping with it, and rejected when
+//     both locales are accepted, so an amount is never silently off by a
+//     factor of 1000
+//
+// Separators of locales not accepted are rejected. Leading "Rp"/"IDR"
+// prefixes and surrounding whitespace are ignored. Placeholders the parser
+// found in place of a value fail with ErrPlaceholder.
+func (p AmountParser) Parse(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return checkFinite(v)
+	case float32:
+		return checkFinite(float64(v))
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case string:
+		return p.parseString(v)
+	case []byte:
+		return p.parseString(string(v))
+	case parser.Placeholder:
+		return 0, fmt.Errorf("%w: %s", ErrPlaceholder, v)
+	default:
+		return 0, fmt.Errorf("unsupported amount type %T", value)
+	}
+}
+
+func (p AmountParser) parseString(raw string) (float64, error) {
+	s := strings.TrimSpace(raw)
+	for _, prefix := range []string{"Rp.", "Rp", "IDR"} {
+		if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+			s = strings.TrimSpace(s[len(prefix):])
+			break
+		}
+	}
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+
+	if ambiguousSeparator(s) {
+		grouping := strings.Contains(s, ".") && p.id && !p.en || strings.Contains(s, ",") && p.en && !p.id
+		switch {
+		case p.id && p.en:
+			return 0, fmt.Errorf("ambiguous amount %q: separator may be grouping or decimal", raw)
+		case grouping:
+			s = strings.NewReplacer(".", "", ",", "").Replace(s)
+		}
+	}
+
+	// Plain numeric literals (including SQL floats and exponents) take precedence
+	// unless they look like a grouped integer such as "1.500.000"
+	if strings.Count(s, ".") <= 1 && !strings.Contains(s, ",") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q", raw)
+		}
+		return checkFinite(f)
+	}
+
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		sign, s = s[:1], s[1:]
+	}
+
+	normalized, locale, err := normalizeSeparators(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", raw, err)
+	}
+	if locale == config.AmountLocaleID && !p.id || locale == config.AmountLocaleEN && !p.en {
+		return 0, fmt.Errorf("invalid amount %q: %s digit separators are not in conversion.amount_locales", raw, locale)
+	}
+
+	f, err := strconv.ParseFloat(sign+normalized, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", raw)
+	}
+	return checkFinite(f)
+}
+
+// normalizeSeparators rewrites a grouped number into plain "1234.56" form
+// and returns the locale whose separators it uses
+func normalizeSeparators(s string) (string, string, error) {
+	lastDot := strings.LastIndex(s, ".")
+	lastComma := strings.LastIndex(s, ",")
+
+	var group, decimal byte
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// Both present: whichever comes last is the decimal separator
+		if lastDot > lastComma {
+			group, decimal = ',', '.'
+		} else {
+			group, decimal = '.', ','
+		}
+	case strings.Count(s, ",") == 1 && lastDot < 0:
+		decimal = ','
+	case lastComma >= 0:
+		group = ','
+	default:
+		group = '.'
+	}
+
+	locale := config.AmountLocaleEN
+	if group == '.' || decimal == ',' {
+		locale = config.AmountLocaleID
+	}
+
+	intPart, fracPart := s, ""
+	if decimal != 0 {
+		idx := strings.LastIndexByte(s, decimal)
+		intPart, fracPart = s[:idx], s[idx+1:]
+		if fracPart == "" || !allDigits(fracPart) {
+			return "", "", fmt.Errorf("malformed fractional part")
+		}
+	}
+
+	if group != 0 && strings.IndexByte(intPart, group) >= 0 {
+		groups := strings.Split(intPart, string(group))
+		if len(groups[0]) == 0 || len(groups[0]) > 3 {
+			return "", "", fmt.Errorf("malformed digit grouping")
+		}
+		for _, g := range groups[1:] {
+			if len(g) != 3 {
+				return "", "", fmt.Errorf("malformed digit grouping")
+			}
+		}
+		intPart = strings.Join(groups, "")
+	}
+
+	if intPart == "" || !allDigits(intPart) {
+		return "", "", fmt.Errorf("malformed integer part")
+	}
+	if fracPart != "" {
+		return intPart + "." + fracPart, locale, nil
+	}
+	return intPart, locale, nil
+}
+
+// ambiguousSeparator reports whether s has a single '.' or ',' that could
+// equally be a thousands separator ("1.500" = 1500) or a decimal point (1.5)
+func ambiguousSeparator(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	if strings.Count(s, ".")+strings.Count(s, ",") != 1 {
+		return false
+	}
+
+	idx := strings.IndexAny(s, ".,")
+	intPart, fracPart := s[:idx], s[idx+1:]
+	// Grouping needs a 1-3 digit leading group without a leading zero
+	return len(fracPart) == 3 && allDigits(fracPart) &&
+		len(intPart) >= 1 && len(intPart) <= 3 && allDigits(intPart) && intPart[0] != '0'
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func checkFinite(f float64) (float64, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("amount is not a finite number")
+	}
+	return f, nil
+}
@@ -0,0 +1,147 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   interface{}
+		want    float64
+		wantErr bool
+	}{
+		{"int64", int64(500000), 500000, false},
+		{"int", 1500, 1500, false},
+		{"float keeps fraction", 200.9, 200.9, false},
+		{"plain string", "1500000", 1500000, false},
+		{"decimal string", "200.9", 200.9, false},
+		{"negative string", "-1500.5", -1500.5, false},
+		{"exponent", "1e6", 1000000, false},
+		{"fractional exponent", "1.5e6", 1500000, false},
+		{"dot grouping", "1.500.000", 1500000, false},
+		{"comma grouping", "1,500,000", 1500000, false},
+		{"indonesian decimal", "1.500.000,50", 1500000.5, false},
+		{"english decimal", "1,500,000.50", 1500000.5, false},
+		{"decimal comma", "200,9", 200.9, false},
+		{"decimal comma cents", "1500000,00", 1500000, false},
+		{"rupiah prefix", "Rp 1.500.000", 1500000, false},
+		{"idr prefix", "IDR 2,000,000", 2000000, false},
+		{"ambiguous dot", "1.500", 0, true},
+		{"ambiguous comma", "1,500", 0, true},
+		{"ambiguous negative", "-1.500", 0, true},
+		{"ambiguous with prefix", "Rp 2.000", 0, true},
+		{"dot grouping matches", "1.500.000", 1500000, false},
+		{"comma grouping matches", "1,500,000", 1500000, false},
+		{"leading zero is decimal", "0.125", 0.125, false},
+		{"long integer part is decimal", "1500.500", 1500.5, false},
+		{"two decimals is decimal", "1.50", 1.5, false},
+		{"bad grouping", "1.50.000", 0, true},
+		{"text", "abc", 0, true},
+		{"empty", "  ", 0, true},
+		{"nan", "NaN", 0, true},
+		{"unsupported type", true, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAmount(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestParseAmount_Placeholder(t *testing.T) {
+	_, err := ParseAmount(parser.Placeholder(":v1"))
+	assert.ErrorIs(t, err, ErrPlaceholder)
+	assert.ErrorContains(t, err, ":v1")
+}
+
+func TestParseStoredAmount(t *testing.T) {
+	for raw, want := range map[string]float64{"1500000": 1500000, "1500.500": 1500.5, "1.500": 1.5, "-0.25": -0.25} {
+		got, err := ParseStoredAmount(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, got, raw)
+	}
+	_, err := ParseStoredAmount("")
+	assert.Error(t, err)
+}
+
+func TestAmountParser_Locales(t *testing.T) {
+	tests := []struct {
+		locales []string
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		// A single separator before three digits groups in the only locale
+		// grouping with it
+		{[]string{"id"}, "1.500", 1500, false},
+		{[]string{"id"}, "1,500", 1.5, false},
+		{[]string{"en"}, "1.500", 1.5, false},
+		{[]string{"en"}, "1,500", 1500, false},
+		{[]string{"id"}, "Rp 2.000", 2000, false},
+		{[]string{"id"}, "-2.000", -2000, false},
+		// Separators of other locales are rejected
+		{[]string{"id"}, "1.500.000,50", 1500000.5, false},
+		{[]string{"id"}, "1,500,000.50", 0, true},
+		{[]string{"id"}, "1,500,000", 0, true},
+		{[]string{"en"}, "1,500,000.50", 1500000.5, false},
+		{[]string{"en"}, "1.500.000", 0, true},
+		{[]string{"en"}, "200,9", 0, true},
+		// Plain numerals are always accepted
+		{[]string{"plain"}, "200.9", 200.9, false},
+		{[]string{"plain"}, "1.5e6", 1500000, false},
+		{[]string{"plain"}, "1.500", 1.5, false},
+		{[]string{"plain"}, "1,500", 0, true},
+		{[]string{"plain"}, "1500000,00", 0, true},
+		{[]string{"id"}, "200.9", 200.9, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locales[0]+" "+tt.input, func(t *testing.T) {
+			got, err := NewAmountParser(tt.locales).Parse(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestToIDN(t *testing.T) {
+	assert.Equal(t, 1500.5, ToIDN("histogram_orders", "total_amount", 1500500, 1000))
+	assert.Equal(t, -2.5, ToIDN("histogram_orders", "total_amount", -2500, 1000))
+	assert.Equal(t, 0.0005, ToIDN("histogram_orders", "total_amount", 0.5, 1000))
+
+	var m dto.Metric
+	observer := metrics.ConvertedAmounts.WithLabelValues("histogram_orders", "total_amount")
+	require.NoError(t, observer.(prometheus.Histogram).Write(&m))
+	histogram := m.GetHistogram()
+	assert.Equal(t, uint64(3), histogram.GetSampleCount())
+	assert.InDelta(t, 1503.0005, histogram.GetSampleSum(), 1e-9, "negative amounts are recorded by magnitude")
+
+	// One amount per digit: at most 0.001, at most 10 and at most 10000
+	counts := map[float64]uint64{}
+	for _, bucket := range histogram.GetBucket() {
+		counts[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+	}
+	assert.Equal(t, uint64(1), counts[0.001])
+	assert.Equal(t, uint64(2), counts[10])
+	assert.Equal(t, uint64(3), counts[10000])
+}
@@ -0,0 +1,52 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+)
+
+// Bounds an amount can fall outside of
+const (
+	BoundMin = "min"
+	BoundMax = "max"
+	// BoundTargetType is a converted amount too large for its shadow column
+	BoundTargetType = "target_type"
+)
+
+// ErrOutOfRange is returned for amounts outside their column's bounds
+var ErrOutOfRange = errs.New(errs.ConversionOutOfRange, "amount out of range")
+
+// CheckBounds checks a source amount seen at source against the column's min
+// and max. Amounts outside them are logged, counted and returned as an error
+// wrapping ErrOutOfRange.
+func CheckBounds(source, table, column string, colConfig config.ColumnConfig, amount float64) error {
+	if bound, err := OutsideBounds(column, colConfig, amount); err != nil {
+		return OutOfRange(source, table, column, bound, err)
+	}
+	return nil
+}
+
+// OutsideBounds returns the bound an amount falls outside of with an error
+// wrapping ErrOutOfRange, or nil when it is within the column's bounds. It
+// neither logs nor counts.
+func OutsideBounds(column string, colConfig config.ColumnConfig, amount float64) (string, error) {
+	switch {
+	case colConfig.BoundsMin != nil && amount < *colConfig.BoundsMin:
+		return BoundMin, fmt.Errorf("%w: column %s amount %v is below the minimum %v", ErrOutOfRange, column, amount, *colConfig.BoundsMin)
+	case colConfig.BoundsMax != nil && amount > *colConfig.BoundsMax:
+		return BoundMax, fmt.Errorf("%w: column %s amount %v is above the maximum %v", ErrOutOfRange, column, amount, *colConfig.BoundsMax)
+	}
+	return "", nil
+}
+
+// OutOfRange logs and counts an amount refused for falling outside bound and
+// returns err
+func OutOfRange(source, table, column, bound string, err error) error {
+	metrics.RecordOutOfRangeAmount(source, table, column, bound)
+	logger.Warn("Amount out of range", "source", source, "table", table, "column", column, "bound", bound, "error", err)
+	return err
+}
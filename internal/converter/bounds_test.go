@@ -0,0 +1,30 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBounds(t *testing.T) {
+	min, max := 0.0, 1e15
+	column := config.ColumnConfig{TargetColumn: "total_amount_idn", BoundsMin: &min, BoundsMax: &max}
+
+	assert.NoError(t, CheckBounds(SourceProxy, "bounded_orders", "total_amount", column, 0))
+	assert.NoError(t, CheckBounds(SourceProxy, "bounded_orders", "total_amount", column, 1e15))
+	assert.NoError(t, CheckBounds(SourceProxy, "bounded_orders", "total_amount", config.ColumnConfig{}, -1e20))
+
+	err := CheckBounds(SourceProxy, "bounded_orders", "total_amount", column, -1)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	assert.ErrorContains(t, err, "below the minimum 0")
+
+	err = CheckBounds(SourceProxy, "bounded_orders", "total_amount", column, 2e15)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	assert.ErrorContains(t, err, "above the maximum 1e+15")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.OutOfRangeAmounts.WithLabelValues(SourceProxy, "bounded_orders", "total_amount", BoundMin)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.OutOfRangeAmounts.WithLabelValues(SourceProxy, "bounded_orders", "total_amount", BoundMax)))
+}
@@ -0,0 +1,142 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+)
+
+// Places where amounts are guarded
+const (
+	SourceProxy    = "proxy"
+	SourceBackfill = "backfill"
+	SourceOutbox   = "outbox"
+)
+
+// Reasons an amount looks already converted
+const (
+	// ReasonBelowFloor is a non-zero amount closer to zero than the floor
+	ReasonBelowFloor = "below_floor"
+	// ReasonMatchesShadow is an amount equal to the row's current shadow
+	// value, which its conversion would change
+	ReasonMatchesShadow = "matches_shadow"
+)
+
+// ErrSuspectAmount is returned for amounts a rejecting guard refuses
+var ErrSuspectAmount = errs.New(errs.ConversionSuspect, "amount looks already converted")
+
+// ErrPlaceholder is returned for a bind placeholder where an amount belongs
+var ErrPlaceholder = errs.New(errs.ConversionPlaceholder, "placeholder in place of an amount; parameters were not interpolated")
+
+// FailurePolicy returns what happens to a statement whose dual-write failed
+// with cause: amounts the guard rejects are always refused, placeholders
+// follow conversion.placeholder_policy and other failures the table's
+// failure policy, or defaultPolicy
+func FailurePolicy(cfg *config.Config, table, defaultPolicy string, cause error) string {
+	switch {
+	case errors.Is(cause, ErrSuspectAmount):
+		return config.FailClosed
+	case errors.Is(cause, ErrPlaceholder):
+		if cfg.Conversion.PlaceholderPolicy == config.PlaceholderFlag {
+			return config.FailOpenWithAlert
+		}
+		return config.FailClosed
+	}
+	return cfg.FailurePolicyFor(table, defaultPolicy)
+}
+
+// RecordPlaceholder counts a statement with a placeholder amount that was
+// handled with policy
+func RecordPlaceholder(table, policy string) {
+	action := "flagged"
+	if policy == config.FailClosed {
+		action = "rejected"
+	}
+	metrics.RecordPlaceholderStatement(table, action)
+}
+
+// Guard checks source amounts for IDN values written to IDR columns, which
+// conversion would divide a second time
+type Guard struct {
+	source    string
+	policy    string
+	ratio     int
+	precision int
+	minAmount float64
+}
+
+// NewGuard returns the guard of conv for amounts seen at source
+func NewGuard(conv config.ConversionConfig, source string) Guard {
+	return Guard{
+		source:    source,
+		policy:    conv.Guard.Policy,
+		ratio:     conv.Ratio,
+		precision: conv.Precision,
+		minAmount: conv.Guard.MinAmount,
+	}
+}
+
+// Check looks at the amount of a column before it is converted. existing is
+// the row's current shadow value when the caller knows it. Suspect amounts
+// are logged and counted; with the reject policy Check also returns an error
+// wrapping ErrSuspectAmount.
+func (g Guard) Check(table, column string, colConfig config.ColumnConfig, amount float64, existing *float64) error {
+	reason := g.Suspect(colConfig, amount, existing)
+	if reason == "" {
+		return nil
+	}
+
+	action := "flagged"
+	if g.Rejects() {
+		action = "rejected"
+	}
+	metrics.RecordSuspectAmount(g.source, table, column, reason, action)
+	logger.Warn("Amount looks already converted",
+		"source", g.source, "table", table, "column", column, "amount", amount, "reason", reason, "action", action)
+
+	if g.Rejects() {
+		return fmt.Errorf("%w: column %s amount %v (%s)", ErrSuspectAmount, column, amount, reason)
+	}
+	return nil
+}
+
+// Suspect returns why an amount looks already converted, or "" when it does
+// not or the guard is off. It neither logs nor counts.
+func (g Guard) Suspect(colConfig config.ColumnConfig, amount float64, existing *float64) string {
+	if g.policy == "" || g.policy == config.GuardOff || amount == 0 {
+		return ""
+	}
+
+	floor := g.minAmount
+	if colConfig.GuardFloor > 0 {
+		floor = colConfig.GuardFloor
+	}
+	if floor == 0 {
+		floor = float64(g.ratio)
+	}
+
+	precision := g.precision
+	if colConfig.Precision > 0 {
+		precision = colConfig.Precision
+	}
+	tolerance := math.Pow10(-precision)
+
+	switch {
+	case math.Abs(amount) < floor:
+		return ReasonBelowFloor
+	case existing != nil && *existing != 0 && math.Abs(amount-*existing) < tolerance &&
+		math.Abs(amount/float64(g.ratio)-*existing) >= tolerance:
+		return ReasonMatchesShadow
+	}
+	return ""
+}
+
+// Rejects reports whether suspect amounts are refused rather than flagged
+func (g Guard) Rejects() bool {
+	return g.policy == config.GuardReject
+}
@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuard_Check(t *testing.T) {
+	conv := config.ConversionConfig{Ratio: 1000, Precision: 4, Guard: config.GuardConfig{Policy: config.GuardReject}}
+	guard := NewGuard(conv, SourceProxy)
+	column := config.ColumnConfig{TargetColumn: "total_amount_idn"}
+	shadow := 1500.5
+
+	tests := []struct {
+		name      string
+		colConfig config.ColumnConfig
+		amount    float64
+		existing  *float64
+		wantErr   bool
+	}{
+		{"zero", column, 0, nil, false},
+		{"rupiah amount", column, 1500500, nil, false},
+		{"below the ratio", column, 150.5, nil, true},
+		{"negative below the ratio", column, -15, nil, true},
+		{"below the column floor", config.ColumnConfig{GuardFloor: 5000}, 2500, nil, true},
+		{"above a lower column floor", config.ColumnConfig{GuardFloor: 100}, 500, nil, false},
+		{"matches the shadow value", config.ColumnConfig{GuardFloor: 1}, 1500.5, &shadow, true},
+		{"differs from the shadow value", column, 1500500, &shadow, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guard.Check("orders", "total_amount", tt.colConfig, tt.amount, tt.existing)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrSuspectAmount)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGuard_Policies(t *testing.T) {
+	column := config.ColumnConfig{TargetColumn: "total_amount_idn"}
+	for _, policy := range []string{"", config.GuardOff, config.GuardFlag} {
+		conv := config.ConversionConfig{Ratio: 1000, Precision: 4, Guard: config.GuardConfig{Policy: policy}}
+		assert.NoError(t, NewGuard(conv, SourceBackfill).Check("orders", "total_amount", column, 15, nil), policy)
+	}
+
+	// The conversion-wide floor applies to columns without their own
+	conv := config.ConversionConfig{Ratio: 1000, Precision: 4,
+		Guard: config.GuardConfig{Policy: config.GuardReject, MinAmount: 100}}
+	guard := NewGuard(conv, SourceBackfill)
+	assert.NoError(t, guard.Check("orders", "total_amount", column, 500, nil))
+	assert.ErrorIs(t, guard.Check("orders", "total_amount", column, 50, nil), ErrSuspectAmount)
+}
+
+func TestFailurePolicy(t *testing.T) {
+	cfg := &config.Config{Tables: config.TablesConfig{"orders": {FailurePolicy: config.FailOpen}}}
+	other := errors.New("rewrite failed")
+	placeholder := fmt.Errorf("column total_amount: %w", ErrPlaceholder)
+
+	assert.Equal(t, config.FailOpen, FailurePolicy(cfg, "orders", config.FailClosed, other))
+	assert.Equal(t, config.FailClosed, FailurePolicy(cfg, "orders", config.FailOpen, ErrSuspectAmount))
+	assert.Equal(t, config.FailClosed, FailurePolicy(cfg, "orders", config.FailOpen, placeholder))
+
+	cfg.Conversion.PlaceholderPolicy = config.PlaceholderFlag
+	assert.Equal(t, config.FailOpenWithAlert, FailurePolicy(cfg, "orders", config.FailOpen, placeholder))
+}
@@ -0,0 +1,24 @@
+package converter
+
+import (
+	"math"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// ShadowValue returns the shadow value the proxy writes for an IDR amount of
+// a column: the amount divided by the conversion ratio, rounded once with the
+// column's rounding strategy and precision. Backfill, repair and reconcile
+// compute the values they write and compare with it, so they agree with
+// dual-write on columns overriding the global rounding.
+func ShadowValue(conv config.ConversionConfig, colConfig config.ColumnConfig, amount float64) (string, error) {
+	return parser.FormatShadowValue(colConfig, conv.RoundingStrategy, conv.Precision, amount/float64(conv.Ratio))
+}
+
+// ShadowTolerance is the difference from the expected shadow value at which a
+// shadow value counts as wrong: one unit of the column's last decimal, with
+// the precision falling back to conversion.precision
+func ShadowTolerance(conv config.ConversionConfig, colConfig config.ColumnConfig) float64 {
+	return math.Pow10(-parser.ShadowDecimals(colConfig, conv.Precision))
+}
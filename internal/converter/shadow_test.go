@@ -0,0 +1,37 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowValue(t *testing.T) {
+	conv := config.ConversionConfig{Ratio: 1000, Precision: 4, RoundingStrategy: "BANKERS_ROUND"}
+
+	value, err := ShadowValue(conv, config.ColumnConfig{TargetType: "DECIMAL(12,4)"}, 1250)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2500", value)
+
+	// Columns overriding the rounding round like dual-write does
+	value, err = ShadowValue(conv, config.ColumnConfig{TargetType: "DECIMAL(12,4)", RoundingStrategy: "ARITHMETIC_ROUND", Precision: 1}, 1250)
+	require.NoError(t, err)
+	assert.Equal(t, "1.3", value)
+	value, err = ShadowValue(conv, config.ColumnConfig{TargetType: "DECIMAL(12,4)", Precision: 1}, 1250)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2", value)
+
+	_, err = ShadowValue(conv, config.ColumnConfig{TargetType: "DECIMAL(4,2)"}, 150000000)
+	assert.ErrorContains(t, err, "overflows")
+}
+
+func TestShadowTolerance(t *testing.T) {
+	conv := config.ConversionConfig{Ratio: 1000, Precision: 4}
+
+	assert.InDelta(t, 0.01, ShadowTolerance(conv, config.ColumnConfig{Precision: 2}), 1e-12)
+	// Columns without a precision use conversion.precision, not whole units
+	assert.InDelta(t, 0.0001, ShadowTolerance(conv, config.ColumnConfig{}), 1e-12)
+	assert.InDelta(t, 0.01, ShadowTolerance(conv, config.ColumnConfig{TargetType: "DECIMAL(12,2)"}), 1e-12)
+}
@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// ConvertStatement converts the currency values of a parsed write to IDN, keyed
+// as RewriteForDualWrite expects them: plain values by column, CASE branches
+// by parser.CaseValueKey and the rows of a multi-row INSERT by
+// parser.RowValueKey. Every amount goes through the conversion guard and the
+// column's bounds. current holds the shadow values of the row an UPDATE
+// writes by column, which the guard compares plain values with; it may be
+// nil. NULLs are left to the columns' null policies. On failure it also
+// returns the stage that failed: convert, guard or range.
+func ConvertStatement(conv config.ConversionConfig, tableConfig config.TableConfig, pq *parser.ParsedQuery, current map[string]float64) (map[string]float64, string, error) {
+	guard := NewGuard(conv, SourceProxy)
+	amounts := NewAmountParser(conv.AmountLocales)
+	converted := make(map[string]float64)
+
+	convert := func(key, col string, value interface{}, shadow *float64) (string, error) {
+		// Parse without truncating fractional or locale-formatted amounts
+		amount, err := amounts.Parse(value)
+		if err != nil {
+			return "convert", fmt.Errorf("column %s: %w", col, err)
+		}
+		_, colConfig, _ := tableConfig.LookupColumn(col)
+		if err := guard.Check(pq.TableName, col, colConfig, amount, shadow); err != nil {
+			return "guard", err
+		}
+		if err := CheckBounds(SourceProxy, pq.TableName, col, colConfig, amount); err != nil {
+			return "range", err
+		}
+		// Rounding happens once, per column, when the shadow value is formatted
+		converted[key] = ToIDN(pq.TableName, col, amount, conv.Ratio)
+		return "", nil
+	}
+
+	for _, col := range pq.CurrencyColumns {
+		value, exists := pq.Values[col]
+		if _, multiRow := pq.RowValues[col]; multiRow || !exists || value == nil {
+			continue
+		}
+		var shadow *float64
+		if existing, ok := current[col]; ok {
+			shadow = &existing
+		}
+		if stage, err := convert(col, col, value, shadow); err != nil {
+			return nil, stage, err
+		}
+	}
+	for col, values := range pq.CaseValues {
+		for i, value := range values {
+			if value == nil {
+				continue
+			}
+			if stage, err := convert(parser.CaseValueKey(col, i), col, value, nil); err != nil {
+				return nil, stage, err
+			}
+		}
+	}
+	for col, values := range pq.RowValues {
+		for i, value := range values {
+			if value == nil {
+				continue
+			}
+			if stage, err := convert(parser.RowValueKey(col, i), col, value, nil); err != nil {
+				return nil, stage, err
+			}
+		}
+	}
+	return converted, "", nil
+}
@@ -0,0 +1,52 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertStatement(t *testing.T) {
+	conv := config.ConversionConfig{Ratio: 1000, Precision: 4, Guard: config.GuardConfig{Policy: config.GuardReject}}
+	min := 0.0
+	tableConfig := config.TableConfig{Columns: map[string]config.ColumnConfig{
+		"total_amount": {TargetColumn: "total_amount_idn", BoundsMin: &min},
+		"shipping_fee": {TargetColumn: "shipping_fee_idn"},
+		"discount":     {TargetColumn: "discount_idn"},
+	}}
+	pq := &parser.ParsedQuery{
+		TableName:       "statement_orders",
+		CurrencyColumns: []string{"total_amount", "shipping_fee", "discount"},
+		Values:          map[string]interface{}{"total_amount": "1.500.000", "shipping_fee": int64(10000), "discount": nil},
+		CaseValues:      map[string][]interface{}{"discount": {int64(5000), nil}},
+		RowValues:       map[string][]interface{}{"shipping_fee": {int64(10000), int64(20000), nil}},
+	}
+
+	converted, stage, err := ConvertStatement(conv, tableConfig, pq, nil)
+	require.NoError(t, err)
+	assert.Empty(t, stage)
+	assert.Equal(t, map[string]float64{
+		"total_amount":                        1500,
+		parser.CaseValueKey("discount", 0):    5,
+		parser.RowValueKey("shipping_fee", 0): 10,
+		parser.RowValueKey("shipping_fee", 1): 20,
+	}, converted)
+
+	// The guard compares plain values with the row's shadow value
+	_, stage, err = ConvertStatement(conv, tableConfig, pq, map[string]float64{"total_amount": 1500000})
+	assert.ErrorIs(t, err, ErrSuspectAmount)
+	assert.Equal(t, "guard", stage)
+
+	pq.Values["total_amount"] = "-1.500.000"
+	_, stage, err = ConvertStatement(conv, tableConfig, pq, nil)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	assert.Equal(t, "range", stage)
+
+	pq.Values["total_amount"] = "abc"
+	_, stage, err = ConvertStatement(conv, tableConfig, pq, nil)
+	assert.ErrorContains(t, err, "column total_amount")
+	assert.Equal(t, "convert", stage)
+}
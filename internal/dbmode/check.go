@@ -0,0 +1,216 @@
+package dbmode
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/backfill"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// Mismatch is an amount MySQL converts differently from the proxy
+type Mismatch struct {
+	Column string `json:"column"`
+	Amount int64  `json:"amount"`
+	// Proxy is the shadow value the proxy writes, MySQL the one the
+	// expression computes
+	Proxy string `json:"proxy"`
+	MySQL string `json:"mysql"`
+}
+
+// sampleAmounts are converted by both MySQL and the proxy; ties at each
+// column's decimals are added to them
+var sampleAmounts = []int64{0, 1, 499, 500, 501, 1500, 2500, 15500, 16500, 123456789, 999999999999}
+
+// CheckExpressions evaluates the table's expressions on MySQL over sample
+// amounts, their negatives and rounding ties, and returns those converted
+// differently from the proxy. Amounts the target type cannot hold are left
+// out.
+func CheckExpressions(ctx context.Context, db *sql.DB, table string, tableConfig config.TableConfig, conv config.ConversionConfig) ([]Mismatch, error) {
+	mismatches := []Mismatch{}
+	for _, col := range sortedColumns(tableConfig) {
+		colConfig := tableConfig.Columns[col]
+		var expressions, want []string
+		var values []int64
+		for _, amount := range checkAmounts(colConfig, conv) {
+			literal, err := parser.FormatShadowValue(colConfig, conv.RoundingStrategy, conv.Precision, float64(amount)/float64(conv.Ratio))
+			if err != nil {
+				continue
+			}
+			expr, err := Expression(strconv.FormatInt(amount, 10), colConfig, conv)
+			if err != nil {
+				return nil, fmt.Errorf("table %s column %s: %w", table, col, err)
+			}
+			expressions = append(expressions, fmt.Sprintf("CAST(%s AS CHAR)", expr))
+			want = append(want, literal)
+			values = append(values, amount)
+		}
+		if len(expressions) == 0 {
+			continue
+		}
+
+		got := make([]sql.NullString, len(expressions))
+		dest := make([]interface{}, len(got))
+		for i := range got {
+			dest[i] = &got[i]
+		}
+		if err := db.QueryRowContext(ctx, "SELECT "+strings.Join(expressions, ", ")).Scan(dest...); err != nil {
+			return nil, fmt.Errorf("table %s column %s: failed to evaluate the expression: %w", table, col, err)
+		}
+		for i, value := range got {
+			if !value.Valid || !sameDecimal(value.String, want[i]) {
+				mismatches = append(mismatches, Mismatch{Column: col, Amount: values[i], Proxy: want[i], MySQL: value.String})
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+// checkAmounts returns the amounts a column's expression is checked with: the
+// samples, the halves between two shadow values and their negatives
+func checkAmounts(colConfig config.ColumnConfig, conv config.ConversionConfig) []int64 {
+	amounts := append([]int64(nil), sampleAmounts...)
+	// A source amount of half a shadow unit exists when the ratio divides
+	// into an even number of units
+	scale := int64(1)
+	for i := 0; i < parser.ShadowDecimals(colConfig, conv.Precision); i++ {
+		scale *= 10
+	}
+	if unit := int64(conv.Ratio); unit%scale == 0 && (unit/scale)%2 == 0 {
+		half := unit / scale / 2
+		for k := int64(0); k < 4; k++ {
+			amounts = append(amounts, (2*k+1)*half)
+		}
+	}
+	for _, amount := range amounts[1:] {
+		amounts = append(amounts, -amount)
+	}
+	return amounts
+}
+
+// sameDecimal reports whether two decimal strings are the same number
+func sameDecimal(a, b string) bool {
+	x, ok := new(big.Rat).SetString(a)
+	if !ok {
+		return false
+	}
+	y, ok := new(big.Rat).SetString(b)
+	return ok && x.Cmp(y) == 0
+}
+
+// Installation is what is installed in MySQL for a table in database mode
+type Installation struct {
+	Table  string `json:"table"`
+	Method string `json:"method"`
+	// Missing lists the triggers or generated columns not installed
+	Missing []string `json:"missing,omitempty"`
+	// Outdated lists those whose definition differs from the one Plan
+	// generates
+	Outdated []string `json:"outdated,omitempty"`
+}
+
+// OK reports whether everything is installed as planned
+func (i Installation) OK() bool {
+	return len(i.Missing) == 0 && len(i.Outdated) == 0
+}
+
+// Inspect compares the triggers or generated columns installed for the table
+// with those Plan generates. database is used for unqualified table names.
+func Inspect(ctx context.Context, db *sql.DB, database, table string, tableConfig config.TableConfig, conv config.ConversionConfig) (Installation, error) {
+	inst := Installation{Table: table, Method: tableConfig.Method()}
+	schemaName, name := splitTable(table)
+	if schemaName == "" {
+		schemaName = database
+	}
+
+	if inst.Method == config.DatabaseMethodGenerated {
+		for _, col := range sortedColumns(tableConfig) {
+			colConfig := tableConfig.Columns[col]
+			want, err := Expression(parser.QuoteIdentifier(col), colConfig, conv)
+			if err != nil {
+				return inst, fmt.Errorf("table %s column %s: %w", table, col, err)
+			}
+			var extra, expression sql.NullString
+			err = db.QueryRowContext(ctx,
+				"SELECT EXTRA, GENERATION_EXPRESSION FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+				schemaName, name, colConfig.TargetColumn).Scan(&extra, &expression)
+			switch {
+			case err == sql.ErrNoRows:
+				inst.Missing = append(inst.Missing, colConfig.TargetColumn)
+			case err != nil:
+				return inst, fmt.Errorf("failed to read column %s: %w", colConfig.TargetColumn, err)
+			case !strings.Contains(strings.ToUpper(extra.String), "STORED GENERATED"):
+				inst.Missing = append(inst.Missing, colConfig.TargetColumn)
+			case !sameExpression(expression.String, want):
+				inst.Outdated = append(inst.Outdated, colConfig.TargetColumn)
+			}
+		}
+		return inst, nil
+	}
+
+	body, err := triggerBody(tableConfig, sortedColumns(tableConfig), conv)
+	if err != nil {
+		return inst, fmt.Errorf("table %s: %w", table, err)
+	}
+	for _, event := range []string{insertTrigger, updateTrigger} {
+		trigger := triggerName(name, event)
+		var statement string
+		err := db.QueryRowContext(ctx,
+			"SELECT ACTION_STATEMENT FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = ? AND TRIGGER_NAME = ?",
+			schemaName, trigger).Scan(&statement)
+		switch {
+		case err == sql.ErrNoRows:
+			inst.Missing = append(inst.Missing, trigger)
+		case err != nil:
+			return inst, fmt.Errorf("failed to read trigger %s: %w", trigger, err)
+		case !sameExpression(statement, body):
+			inst.Outdated = append(inst.Outdated, trigger)
+		}
+	}
+	return inst, nil
+}
+
+// sameExpression compares SQL text ignoring case, whitespace, quoting and
+// the charset introducers MySQL adds to generation expressions
+func sameExpression(a, b string) bool {
+	return normalizeSQL(a) == normalizeSQL(b)
+}
+
+func normalizeSQL(s string) string {
+	s = strings.ToLower(s)
+	s = strings.ReplaceAll(s, "_utf8mb4", "")
+	s = strings.ReplaceAll(s, "_utf8mb3", "")
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n', '\r', '`', '(', ')':
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// Drift is a column's shadow values that disagree with its amounts
+type Drift struct {
+	Column     string `json:"column"`
+	Missing    int64  `json:"missing"`
+	Mismatched int64  `json:"mismatched"`
+}
+
+// CountDrift counts, for each column of the table, the rows whose shadow
+// value is missing or off from the amount, as reconciliation does
+func CountDrift(ctx context.Context, db *sql.DB, table string, tableConfig config.TableConfig, conv config.ConversionConfig) ([]Drift, error) {
+	drift := []Drift{}
+	for _, col := range sortedColumns(tableConfig) {
+		missing, mismatched, err := backfill.CountDrift(ctx, db, table, col, tableConfig.Columns[col], conv)
+		if err != nil {
+			return nil, fmt.Errorf("table %s column %s: %w", table, col, err)
+		}
+		drift = append(drift, Drift{Column: col, Missing: missing, Mismatched: mismatched})
+	}
+	return drift, nil
+}
@@ -0,0 +1,194 @@
+// Package dbmode manages the triggers and generated columns that fill the
+// shadow columns of tables in database write mode: it generates their DDL,
+// checks that MySQL rounds like the proxy and that they are installed as
+// generated.
+package dbmode
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// maxDecimals is the scale amounts are divided at, MySQL's largest
+const maxDecimals = 30
+
+// Trigger name suffixes
+const (
+	insertTrigger = "bi"
+	updateTrigger = "bu"
+)
+
+// Expression returns the SQL expression converting source, a column
+// reference or literal, to the column's shadow value: divided by the ratio
+// and rounded with the column's strategy to its decimals, exactly like the
+// proxy. It is NULL when source is NULL, or 0 with null policy zero.
+func Expression(source string, colConfig config.ColumnConfig, conv config.ConversionConfig) (string, error) {
+	if conv.Ratio <= 0 {
+		return "", fmt.Errorf("conversion ratio must be positive, got %d", conv.Ratio)
+	}
+	decimals := parser.ShadowDecimals(colConfig, conv.Precision)
+	if decimals > maxDecimals {
+		return "", fmt.Errorf("precision %d is above the %d decimals MySQL divides at", decimals, maxDecimals)
+	}
+
+	amount := fmt.Sprintf("(CAST(%s AS DECIMAL(65,%d)) / %d)", source, maxDecimals, conv.Ratio)
+	var expr string
+	switch strategy := colConfig.EffectiveRoundingStrategy(conv.RoundingStrategy); strategy {
+	case "ARITHMETIC_ROUND":
+		// ROUND on an exact DECIMAL rounds halves away from zero
+		expr = fmt.Sprintf("ROUND(%s, %d)", amount, decimals)
+	case "BANKERS_ROUND", "":
+		// Halves go to the even neighbour of the amount scaled to whole units
+		units := fmt.Sprintf("(%s * %s)", amount, pow10(decimals))
+		floor := fmt.Sprintf("FLOOR(%s)", units)
+		expr = fmt.Sprintf("(CASE WHEN %[1]s - %[2]s > 0.5 THEN %[2]s + 1 WHEN %[1]s - %[2]s < 0.5 THEN %[2]s "+
+			"WHEN MOD(%[2]s, 2) = 0 THEN %[2]s ELSE %[2]s + 1 END) * %[3]s", units, floor, unit(decimals))
+	default:
+		return "", fmt.Errorf("unsupported rounding strategy %s", strategy)
+	}
+
+	if colConfig.EffectiveNullPolicy() == config.NullPolicyZero {
+		expr = fmt.Sprintf("COALESCE(%s, 0)", expr)
+	}
+	return expr, nil
+}
+
+// pow10 returns 10^n as an integer literal
+func pow10(n int) string {
+	return "1" + strings.Repeat("0", n)
+}
+
+// unit returns 10^-n as an exact decimal literal
+func unit(n int) string {
+	if n == 0 {
+		return "1"
+	}
+	return "0." + strings.Repeat("0", n-1) + "1"
+}
+
+// Plan returns the statements installing the triggers or generated columns
+// that fill the table's shadow columns, replacing those installed before
+func Plan(table string, tableConfig config.TableConfig, conv config.ConversionConfig) ([]string, error) {
+	columns := sortedColumns(tableConfig)
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s has no currency columns", table)
+	}
+	if tableConfig.Method() == config.DatabaseMethodGenerated {
+		return generatedPlan(table, tableConfig, columns, conv)
+	}
+
+	body, err := triggerBody(tableConfig, columns, conv)
+	if err != nil {
+		return nil, fmt.Errorf("table %s: %w", table, err)
+	}
+	var statements []string
+	for _, event := range []string{insertTrigger, updateTrigger} {
+		name := TriggerName(table, event)
+		when := "INSERT"
+		if event == updateTrigger {
+			when = "UPDATE"
+		}
+		statements = append(statements,
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s", name),
+			fmt.Sprintf("CREATE TRIGGER %s BEFORE %s ON %s FOR EACH ROW %s", name, when, parser.QuoteTable(table), body))
+	}
+	return statements, nil
+}
+
+// triggerBody returns the SET statement of the table's triggers
+func triggerBody(tableConfig config.TableConfig, columns []string, conv config.ConversionConfig) (string, error) {
+	assignments := make([]string, len(columns))
+	for i, col := range columns {
+		colConfig := tableConfig.Columns[col]
+		source := "NEW." + parser.QuoteIdentifier(col)
+		target := "NEW." + parser.QuoteIdentifier(colConfig.TargetColumn)
+		expr, err := Expression(source, colConfig, conv)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", col, err)
+		}
+		if colConfig.EffectiveNullPolicy() == config.NullPolicySkip {
+			// The shadow value is kept when the amount is set to NULL
+			expr = fmt.Sprintf("IF(%s IS NULL, %s, %s)", source, target, expr)
+		}
+		assignments[i] = fmt.Sprintf("%s = %s", target, expr)
+	}
+	return "SET " + strings.Join(assignments, ", "), nil
+}
+
+// generatedPlan turns the shadow columns into stored generated columns
+func generatedPlan(table string, tableConfig config.TableConfig, columns []string, conv config.ConversionConfig) ([]string, error) {
+	clauses := make([]string, len(columns))
+	for i, col := range columns {
+		colConfig := tableConfig.Columns[col]
+		if colConfig.TargetType == "" {
+			return nil, fmt.Errorf("table %s column %s: target_type is required to define a generated column", table, col)
+		}
+		expr, err := Expression(parser.QuoteIdentifier(col), colConfig, conv)
+		if err != nil {
+			return nil, fmt.Errorf("table %s column %s: %w", table, col, err)
+		}
+		clauses[i] = fmt.Sprintf("MODIFY COLUMN %s %s GENERATED ALWAYS AS (%s) STORED",
+			parser.QuoteIdentifier(colConfig.TargetColumn), colConfig.TargetType, expr)
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s %s", parser.QuoteTable(table), strings.Join(clauses, ", "))}, nil
+}
+
+// RemovePlan returns the statements removing the table's triggers or turning
+// its generated shadow columns back into plain columns that keep their
+// values, before the proxy dual-writes the table again
+func RemovePlan(table string, tableConfig config.TableConfig) ([]string, error) {
+	if tableConfig.Method() != config.DatabaseMethodGenerated {
+		return []string{
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s", TriggerName(table, insertTrigger)),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s", TriggerName(table, updateTrigger)),
+		}, nil
+	}
+
+	columns := sortedColumns(tableConfig)
+	clauses := make([]string, len(columns))
+	for i, col := range columns {
+		colConfig := tableConfig.Columns[col]
+		if colConfig.TargetType == "" {
+			return nil, fmt.Errorf("table %s column %s: target_type is required to redefine the shadow column", table, col)
+		}
+		clauses[i] = fmt.Sprintf("MODIFY COLUMN %s %s NULL", parser.QuoteIdentifier(colConfig.TargetColumn), colConfig.TargetType)
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s %s", parser.QuoteTable(table), strings.Join(clauses, ", "))}, nil
+}
+
+// TriggerName returns the quoted name of a table's trigger for event (bi or
+// bu), in the table's database
+func TriggerName(table, event string) string {
+	db, name := splitTable(table)
+	trigger := parser.QuoteIdentifier(triggerName(name, event))
+	if db != "" {
+		return parser.QuoteIdentifier(db) + "." + trigger
+	}
+	return trigger
+}
+
+// triggerName returns the unquoted trigger name of an unqualified table
+func triggerName(table, event string) string {
+	return fmt.Sprintf("transisidb_%s_%s", table, event)
+}
+
+func sortedColumns(tableConfig config.TableConfig) []string {
+	columns := make([]string, 0, len(tableConfig.Columns))
+	for col := range tableConfig.Columns {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// splitTable splits a table name that may be qualified with its database
+func splitTable(table string) (string, string) {
+	if dot := strings.IndexByte(table, '.'); dot >= 0 {
+		return table[:dot], table[dot+1:]
+	}
+	return "", table
+}
@@ -0,0 +1,104 @@
+package dbmode
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var conv = config.ConversionConfig{Ratio: 1000, Precision: 4, RoundingStrategy: "BANKERS_ROUND"}
+
+func TestExpression(t *testing.T) {
+	expr, err := Expression("`total`", config.ColumnConfig{TargetType: "DECIMAL(19,2)", RoundingStrategy: "ARITHMETIC_ROUND"}, conv)
+	require.NoError(t, err)
+	assert.Equal(t, "ROUND((CAST(`total` AS DECIMAL(65,30)) / 1000), 2)", expr)
+
+	expr, err = Expression("`total`", config.ColumnConfig{Precision: 2}, conv)
+	require.NoError(t, err)
+	units := "((CAST(`total` AS DECIMAL(65,30)) / 1000) * 100)"
+	floor := "FLOOR(" + units + ")"
+	assert.Equal(t, "(CASE WHEN "+units+" - "+floor+" > 0.5 THEN "+floor+" + 1 WHEN "+units+" - "+floor+" < 0.5 THEN "+floor+
+		" WHEN MOD("+floor+", 2) = 0 THEN "+floor+" ELSE "+floor+" + 1 END) * 0.01", expr)
+
+	expr, err = Expression("`total`", config.ColumnConfig{RoundingStrategy: "ARITHMETIC_ROUND", NullPolicy: config.NullPolicyZero}, conv)
+	require.NoError(t, err)
+	// Without a precision of its own the column uses conversion.precision
+	assert.Equal(t, "COALESCE(ROUND((CAST(`total` AS DECIMAL(65,30)) / 1000), 4), 0)", expr)
+
+	_, err = Expression("`total`", config.ColumnConfig{}, config.ConversionConfig{})
+	assert.Error(t, err)
+	_, err = Expression("`total`", config.ColumnConfig{RoundingStrategy: "CEILING"}, conv)
+	assert.Error(t, err)
+}
+
+func TestPlan_Trigger(t *testing.T) {
+	tableConfig := config.TableConfig{WriteMode: config.WriteModeDatabase, Columns: map[string]config.ColumnConfig{
+		"total":    {TargetColumn: "total_idn", TargetType: "DECIMAL(19,2)", RoundingStrategy: "ARITHMETIC_ROUND"},
+		"shipping": {TargetColumn: "shipping_idn", TargetType: "DECIMAL(19,2)", RoundingStrategy: "ARITHMETIC_ROUND", NullPolicy: config.NullPolicySkip},
+	}}
+	statements, err := Plan("shop.orders", tableConfig, conv)
+	require.NoError(t, err)
+
+	body := "SET NEW.`shipping_idn` = IF(NEW.`shipping` IS NULL, NEW.`shipping_idn`, ROUND((CAST(NEW.`shipping` AS DECIMAL(65,30)) / 1000), 2)), " +
+		"NEW.`total_idn` = ROUND((CAST(NEW.`total` AS DECIMAL(65,30)) / 1000), 2)"
+	assert.Equal(t, []string{
+		"DROP TRIGGER IF EXISTS `shop`.`transisidb_orders_bi`",
+		"CREATE TRIGGER `shop`.`transisidb_orders_bi` BEFORE INSERT ON `shop`.`orders` FOR EACH ROW " + body,
+		"DROP TRIGGER IF EXISTS `shop`.`transisidb_orders_bu`",
+		"CREATE TRIGGER `shop`.`transisidb_orders_bu` BEFORE UPDATE ON `shop`.`orders` FOR EACH ROW " + body,
+	}, statements)
+
+	statements, err = RemovePlan("shop.orders", tableConfig)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DROP TRIGGER IF EXISTS `shop`.`transisidb_orders_bi`",
+		"DROP TRIGGER IF EXISTS `shop`.`transisidb_orders_bu`",
+	}, statements)
+
+	_, err = Plan("orders", config.TableConfig{}, conv)
+	assert.Error(t, err)
+}
+
+func TestPlan_Generated(t *testing.T) {
+	tableConfig := config.TableConfig{WriteMode: config.WriteModeDatabase, DatabaseMethod: config.DatabaseMethodGenerated,
+		Columns: map[string]config.ColumnConfig{
+			"total": {TargetColumn: "total_idn", TargetType: "DECIMAL(19,2)", RoundingStrategy: "ARITHMETIC_ROUND"},
+		}}
+	statements, err := Plan("orders", tableConfig, conv)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE `orders` MODIFY COLUMN `total_idn` DECIMAL(19,2) GENERATED ALWAYS AS " +
+		"(ROUND((CAST(`total` AS DECIMAL(65,30)) / 1000), 2)) STORED"}, statements)
+
+	statements, err = RemovePlan("orders", tableConfig)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE `orders` MODIFY COLUMN `total_idn` DECIMAL(19,2) NULL"}, statements)
+
+	// The column definition needs the target type
+	tableConfig.Columns["total"] = config.ColumnConfig{TargetColumn: "total_idn"}
+	_, err = Plan("orders", tableConfig, conv)
+	assert.Error(t, err)
+	_, err = RemovePlan("orders", tableConfig)
+	assert.Error(t, err)
+}
+
+func TestCheckAmounts(t *testing.T) {
+	// Half a cent is 5 rupiah at a ratio of 1000
+	amounts := checkAmounts(config.ColumnConfig{TargetType: "DECIMAL(19,2)"}, conv)
+	assert.Subset(t, amounts, []int64{5, 15, 25, 35, -5, -35, -15500})
+	assert.Len(t, amounts, 2*(len(sampleAmounts)+4)-1)
+
+	// No amount falls halfway between two ten-thousandths
+	amounts = checkAmounts(config.ColumnConfig{TargetType: "DECIMAL(19,4)"}, conv)
+	assert.Len(t, amounts, 2*len(sampleAmounts)-1)
+}
+
+func TestSameExpression(t *testing.T) {
+	assert.True(t, sameExpression("round((cast(`total` as decimal(65,30)) / 1000),2)",
+		"ROUND((CAST(`total` AS DECIMAL(65,30)) / 1000), 2)"))
+	assert.False(t, sameExpression("round((cast(`total` as decimal(65,30)) / 100),2)",
+		"ROUND((CAST(`total` AS DECIMAL(65,30)) / 1000), 2)"))
+	assert.True(t, sameDecimal("15.50", "15.5"))
+	assert.False(t, sameDecimal("15.49", "15.5"))
+}
@@ -0,0 +1,139 @@
+// Package discovery resolves backend endpoints from DNS SRV records or the
+// Consul catalog, so backends can be added and removed without config pushes.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+// DefaultConsulAddress is used when discovery.consul_address is not set
+const DefaultConsulAddress = "http://127.0.0.1:8500"
+
+// Endpoint is a resolved backend address
+type Endpoint struct {
+	Host string
+	Port int
+}
+
+// String returns the endpoint as host:port
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// Resolver returns the current endpoints of a service, preferred first
+type Resolver interface {
+	Resolve(ctx context.Context) ([]Endpoint, error)
+}
+
+// NewResolver creates the resolver for a discovery config
+func NewResolver(cfg config.DiscoveryConfig) (Resolver, error) {
+	switch cfg.Type {
+	case config.DiscoveryDNSSRV:
+		return &SRVResolver{Name: cfg.Name, Lookup: net.DefaultResolver.LookupSRV}, nil
+	case config.DiscoveryConsul:
+		address := cfg.ConsulAddress
+		if address == "" {
+			address = DefaultConsulAddress
+		}
+		return &ConsulResolver{Address: address, Service: cfg.Name, Tag: cfg.Tag, Client: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown discovery type: %s", cfg.Type)
+	}
+}
+
+// SRVResolver resolves a DNS SRV record such as _mysql._tcp.db.internal.
+// Kubernetes publishes SRV records for the named ports of headless services.
+type SRVResolver struct {
+	Name   string
+	Lookup func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Resolve returns the SRV targets ordered by priority, then by weight
+func (r *SRVResolver) Resolve(ctx context.Context) ([]Endpoint, error) {
+	_, records, err := r.Lookup(ctx, "", "", r.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up SRV %s: %w", r.Name, err)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Priority != records[j].Priority {
+			return records[i].Priority < records[j].Priority
+		}
+		return records[i].Weight > records[j].Weight
+	})
+
+	endpoints := make([]Endpoint, 0, len(records))
+	for _, srv := range records {
+		host := srv.Target
+		if n := len(host); n > 0 && host[n-1] == '.' {
+			host = host[:n-1]
+		}
+		endpoints = append(endpoints, Endpoint{Host: host, Port: int(srv.Port)})
+	}
+	return endpoints, nil
+}
+
+// ConsulResolver resolves the passing instances of a Consul service
+type ConsulResolver struct {
+	Address string
+	Service string
+	Tag     string
+	Client  *http.Client
+}
+
+// consulEntry is the part of a /v1/health/service entry the resolver reads
+type consulEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// Resolve returns the instances whose health checks pass, in catalog order
+func (r *ConsulResolver) Resolve(ctx context.Context) ([]Endpoint, error) {
+	query := url.Values{"passing": {"true"}}
+	if r.Tag != "" {
+		query.Set("tag", r.Tag)
+	}
+	endpoint := fmt.Sprintf("%s/v1/health/service/%s?%s", r.Address, url.PathEscape(r.Service), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consul: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul returned status %d", resp.StatusCode)
+	}
+
+	var entries []consulEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode consul response: %w", err)
+	}
+
+	endpoints := make([]Endpoint, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		endpoints = append(endpoints, Endpoint{Host: host, Port: entry.Service.Port})
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+func TestSRVResolver_Resolve(t *testing.T) {
+	resolver := &SRVResolver{
+		Name: "_mysql._tcp.db.internal",
+		Lookup: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			assert.Equal(t, "_mysql._tcp.db.internal", name)
+			return "", []*net.SRV{
+				{Target: "db-3.internal.", Port: 3306, Priority: 20, Weight: 10},
+				{Target: "db-2.internal.", Port: 3307, Priority: 10, Weight: 5},
+				{Target: "db-1.internal.", Port: 3306, Priority: 10, Weight: 50},
+			}, nil
+		},
+	}
+
+	endpoints, err := resolver.Resolve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{
+		{Host: "db-1.internal", Port: 3306},
+		{Host: "db-2.internal", Port: 3307},
+		{Host: "db-3.internal", Port: 3306},
+	}, endpoints)
+	assert.Equal(t, "db-2.internal:3307", endpoints[1].String())
+}
+
+func TestConsulResolver_Resolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/mysql-replica", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("passing"))
+		assert.Equal(t, "analytics", r.URL.Query().Get("tag"))
+		w.Write([]byte(`[
+			{"Node": {"Address": "10.0.0.5"}, "Service": {"Address": "10.0.1.5", "Port": 3306}},
+			{"Node": {"Address": "10.0.0.6"}, "Service": {"Address": "", "Port": 3307}}
+		]`))
+	}))
+	defer server.Close()
+
+	resolver, err := NewResolver(config.DiscoveryConfig{
+		Type:          config.DiscoveryConsul,
+		Name:          "mysql-replica",
+		ConsulAddress: server.URL,
+		Tag:           "analytics",
+	})
+	require.NoError(t, err)
+
+	endpoints, err := resolver.Resolve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{
+		{Host: "10.0.1.5", Port: 3306},
+		{Host: "10.0.0.6", Port: 3307},
+	}, endpoints)
+}
+
+func TestConsulResolver_ResolveError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no leader", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	resolver := &ConsulResolver{Address: server.URL, Service: "mysql", Client: server.Client()}
+	_, err := resolver.Resolve(context.Background())
+	assert.ErrorContains(t, err, "status 500")
+}
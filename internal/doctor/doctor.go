@@ -0,0 +1,411 @@
+// Package doctor checks that a deployment can start: the configuration, the
+// connections to MySQL, Redis and the replicas, the shadow columns, the
+// triggers or generated columns of database write mode, the TLS material and
+// the binlog settings change data capture reads.
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/dbmode"
+	"github.com/kafitramarna/TransisiDB/internal/proxy"
+	"github.com/kafitramarna/TransisiDB/internal/schema"
+)
+
+// Status is the outcome of a check
+type Status string
+
+// Check outcomes. Only failures keep the proxy from working; warnings are
+// settings worth a look.
+const (
+	Pass Status = "PASS"
+	Warn Status = "WARN"
+	Fail Status = "FAIL"
+	Skip Status = "SKIP"
+)
+
+// checkTimeout bounds each connection check
+const checkTimeout = 10 * time.Second
+
+// Check is one line of the checklist
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Report is the checklist in the order the checks ran
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+func (r *Report) add(name string, status Status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Count returns the number of checks with the status
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, check := range r.Checks {
+		if check.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// OK reports whether no check failed
+func (r *Report) OK() bool {
+	return r.Count(Fail) == 0
+}
+
+// Run checks the deployment configured in the file at path. Checks that need
+// a connection which failed are skipped.
+func Run(ctx context.Context, path string) *Report {
+	r := &Report{}
+	cfg, err := config.Load(path)
+	if err != nil {
+		r.add("config", Fail, "%v", err)
+		return r
+	}
+	r.add("config", Pass, "%s: %d tables, %d query rules", path, len(cfg.Tables), len(cfg.QueryRules))
+
+	r.checkTLS(cfg.Redis.TLS, time.Now())
+	r.checkRedis(cfg)
+
+	db := r.checkPrimary(ctx, cfg)
+	if db != nil {
+		defer db.Close()
+		cache := schema.NewCache(schema.NewSQLLoader(db), 0, cfg.Database.LowerCaseTableNames != 0)
+		r.checkShadowColumns(ctx, cache, cfg)
+		r.checkDatabaseMode(ctx, db, cfg)
+		r.checkBinlog(ctx, db)
+	} else {
+		r.add("shadow columns", Skip, "the MySQL primary is not reachable")
+		r.add("binlog", Skip, "the MySQL primary is not reachable")
+	}
+
+	for _, replica := range cfg.Database.Replicas {
+		r.checkReplica(ctx, cfg, replica)
+	}
+	return r
+}
+
+// checkTLS loads the Redis CA and client certificate and checks when they expire
+func (r *Report) checkTLS(t config.RedisTLSConfig, now time.Time) {
+	if !t.Enabled {
+		r.add("redis tls", Skip, "redis.tls is disabled")
+		return
+	}
+
+	var files []string
+	if t.CAFile != "" {
+		data, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			r.add("redis tls", Fail, "failed to read ca_file: %v", err)
+			return
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(data) {
+			r.add("redis tls", Fail, "no certificates found in ca_file %s", t.CAFile)
+			return
+		}
+		files = append(files, t.CAFile)
+	}
+	if t.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			r.add("redis tls", Fail, "failed to load cert_file and key_file: %v", err)
+			return
+		}
+		files = append(files, t.CertFile)
+	}
+	if len(files) == 0 {
+		r.add("redis tls", Pass, "enabled with the system roots")
+		return
+	}
+
+	status, details := Pass, make([]string, 0, len(files))
+	for _, path := range files {
+		notAfter, err := earliestExpiry(path)
+		if err != nil {
+			r.add("redis tls", Fail, "%v", err)
+			return
+		}
+		switch {
+		case !now.Before(notAfter):
+			status = Fail
+			details = append(details, fmt.Sprintf("%s expired %s", path, notAfter.UTC().Format(time.RFC3339)))
+		case notAfter.Sub(now) < config.DefaultCertWarnBefore:
+			if status == Pass {
+				status = Warn
+			}
+			details = append(details, fmt.Sprintf("%s expires soon, %s", path, notAfter.UTC().Format(time.RFC3339)))
+		default:
+			details = append(details, fmt.Sprintf("%s expires %s", path, notAfter.UTC().Format(time.RFC3339)))
+		}
+	}
+	r.add("redis tls", status, "%s", strings.Join(details, "; "))
+}
+
+// earliestExpiry returns the first expiry among the certificates in a PEM file
+func earliestExpiry(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	var earliest time.Time
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse certificate in %s: %w", path, err)
+		}
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	if earliest.IsZero() {
+		return time.Time{}, fmt.Errorf("no certificate found in %s", path)
+	}
+	return earliest, nil
+}
+
+// checkRedis connects to Redis
+func (r *Report) checkRedis(cfg *config.Config) {
+	store, err := config.NewRedisStore(&cfg.Redis)
+	if err != nil {
+		r.add("redis", Fail, "%v", err)
+		return
+	}
+	defer store.Close()
+
+	mode := cfg.Redis.Mode
+	if mode == "" {
+		mode = config.RedisModeStandalone
+	}
+	r.add("redis", Pass, "connected (%s)", mode)
+}
+
+// checkPrimary connects to the MySQL primary and returns the connection, nil
+// when it cannot be reached
+func (r *Report) checkPrimary(ctx context.Context, cfg *config.Config) *sql.DB {
+	if cfg.Database.Discovery.Enabled() {
+		r.add("mysql primary", Skip, "resolved through service discovery when the proxy starts")
+		return nil
+	}
+	db, detail, err := connect(ctx, cfg)
+	if err != nil {
+		r.add("mysql primary", Fail, "%v", err)
+		return nil
+	}
+	r.add("mysql primary", Pass, "%s", detail)
+	return db
+}
+
+// checkReplica connects to a replica
+func (r *Report) checkReplica(ctx context.Context, cfg *config.Config, replica config.ReplicaConfig) {
+	name := "replica " + replica.Name
+	if replica.Discovery.Enabled() {
+		r.add(name, Skip, "resolved through service discovery when the proxy starts")
+		return
+	}
+	db, detail, err := connect(ctx, cfg.ForReplica(replica))
+	if err != nil {
+		r.add(name, Fail, "%v", err)
+		return
+	}
+	db.Close()
+	r.add(name, Pass, "%s (role %s)", detail, replica.Role)
+}
+
+// connect opens a connection to the configured database and reports its
+// version and address
+func connect(ctx context.Context, cfg *config.Config) (*sql.DB, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	address := fmt.Sprintf("%s:%d", cfg.Database.Host, cfg.Database.Port)
+	if cfg.Database.Socket != "" {
+		address = cfg.Database.Socket
+	}
+	db, err := proxy.OpenBackend(cfg)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", address, err)
+	}
+	db.SetMaxOpenConns(2)
+
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		db.Close()
+		return nil, "", fmt.Errorf("%s: %w", address, err)
+	}
+	return db, fmt.Sprintf("%s at %s", version, address), nil
+}
+
+// checkShadowColumns checks the shadow columns of each enabled table: they
+// must exist with a DECIMAL scale that holds the converted precision
+func (r *Report) checkShadowColumns(ctx context.Context, cache *schema.Cache, cfg *config.Config) {
+	names := make([]string, 0, len(cfg.Tables))
+	for name, tableConfig := range cfg.Tables {
+		if tableConfig.Enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		r.add("shadow columns", Skip, "no table is enabled")
+		return
+	}
+
+	for _, name := range names {
+		tableConfig := cfg.Tables[name]
+		checkName := "table " + name
+		problems := schema.CheckTargetColumns(ctx, cache, cfg.Database.Database, config.TablesConfig{name: tableConfig})
+		problems = append(problems, scaleProblems(ctx, cache, cfg, name, tableConfig)...)
+		if len(problems) > 0 {
+			details := make([]string, len(problems))
+			for i, problem := range problems {
+				details[i] = problem.Error()
+			}
+			r.add(checkName, Fail, "%s", strings.Join(details, "; "))
+			continue
+		}
+		r.add(checkName, Pass, "%d shadow columns", len(tableConfig.Columns))
+	}
+}
+
+// scaleProblems reports the DECIMAL shadow columns of a table whose scale is
+// below the precision amounts are converted to, which MySQL would round
+func scaleProblems(ctx context.Context, cache *schema.Cache, cfg *config.Config, name string, tableConfig config.TableConfig) []error {
+	db, table := cfg.Database.Database, name
+	if dot := strings.IndexByte(name, '.'); dot >= 0 {
+		db, table = name[:dot], name[dot+1:]
+	}
+	meta, ok, err := cache.Table(ctx, db, table)
+	if err != nil || !ok {
+		// Reported by CheckTargetColumns
+		return nil
+	}
+
+	columns := make([]string, 0, len(tableConfig.Columns))
+	for colName := range tableConfig.Columns {
+		columns = append(columns, colName)
+	}
+	sort.Strings(columns)
+
+	var problems []error
+	for _, colName := range columns {
+		colConfig := tableConfig.Columns[colName]
+		precision := colConfig.Precision
+		if precision == 0 {
+			precision = cfg.Conversion.Precision
+		}
+		col, ok := meta.Column(colConfig.TargetColumn)
+		if !ok || col.DataType != "decimal" || col.Scale < 0 {
+			continue
+		}
+		if col.Scale < precision {
+			problems = append(problems, fmt.Errorf("table %s column %s: target column %s is %s, its scale %d is below the conversion precision %d",
+				name, colName, colConfig.TargetColumn, col.Type, col.Scale, precision))
+		}
+	}
+	return problems
+}
+
+// checkDatabaseMode checks the triggers or generated columns of each enabled
+// table in database write mode: they must be installed as configured and
+// round like the proxy
+func (r *Report) checkDatabaseMode(ctx context.Context, db *sql.DB, cfg *config.Config) {
+	names := make([]string, 0, len(cfg.Tables))
+	for name, tableConfig := range cfg.Tables {
+		if tableConfig.Enabled && tableConfig.InDatabase() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		tableConfig := cfg.Tables[name]
+		checkName := "database mode " + name
+		inst, err := dbmode.Inspect(ctx, db, cfg.Database.Database, name, tableConfig, cfg.Conversion)
+		if err != nil {
+			r.add(checkName, Fail, "%v", err)
+			continue
+		}
+		mismatches, err := dbmode.CheckExpressions(ctx, db, name, tableConfig, cfg.Conversion)
+		if err != nil {
+			r.add(checkName, Fail, "%v", err)
+			continue
+		}
+		status, detail := installationStatus(inst, len(mismatches))
+		r.add(checkName, status, "%s", detail)
+	}
+}
+
+// installationStatus rates what is installed for a table in database mode.
+// Missing triggers or columns and rounding mismatches leave shadow columns
+// wrong; an outdated definition may only be written differently.
+func installationStatus(inst dbmode.Installation, mismatches int) (Status, string) {
+	switch {
+	case len(inst.Missing) > 0:
+		return Fail, fmt.Sprintf("%s not installed, run transisidb dbmode apply", strings.Join(inst.Missing, ", "))
+	case mismatches > 0:
+		return Fail, fmt.Sprintf("MySQL rounds %d sample amounts differently from the proxy, run transisidb dbmode check", mismatches)
+	case len(inst.Outdated) > 0:
+		return Warn, fmt.Sprintf("%s differ from the configuration, run transisidb dbmode plan to compare", strings.Join(inst.Outdated, ", "))
+	}
+	return Pass, fmt.Sprintf("%s installed and rounding like the proxy", inst.Method)
+}
+
+// checkBinlog reads the binlog settings of the primary
+func (r *Report) checkBinlog(ctx context.Context, db *sql.DB) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var logBin int
+	var format, rowImage string
+	err := db.QueryRowContext(ctx, `SELECT @@GLOBAL.log_bin, @@GLOBAL.binlog_format, @@GLOBAL.binlog_row_image`).
+		Scan(&logBin, &format, &rowImage)
+	if err != nil {
+		r.add("binlog", Warn, "failed to read binlog settings: %v", err)
+		return
+	}
+	status, detail := binlogStatus(logBin != 0, format, rowImage)
+	r.add("binlog", status, "%s", detail)
+}
+
+// binlogStatus checks the settings change data capture needs: binary logging
+// with full row images, so every change carries its shadow columns. The proxy
+// does not need them, so they are warnings.
+func binlogStatus(logBin bool, format, rowImage string) (Status, string) {
+	var problems []string
+	if !logBin {
+		problems = append(problems, "log_bin is OFF")
+	}
+	if !strings.EqualFold(format, "ROW") {
+		problems = append(problems, fmt.Sprintf("binlog_format is %s, not ROW", format))
+	}
+	if !strings.EqualFold(rowImage, "FULL") {
+		problems = append(problems, fmt.Sprintf("binlog_row_image is %s, not FULL", rowImage))
+	}
+	if len(problems) > 0 {
+		return Warn, strings.Join(problems, "; ") + "; change data capture needs binary logging with full row images"
+	}
+	return Pass, "log_bin ON, binlog_format ROW, binlog_row_image FULL"
+}
@@ -0,0 +1,137 @@
+package doctor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/certgen"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/dbmode"
+	"github.com/kafitramarna/TransisiDB/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLoader serves tables from memory
+type fakeLoader struct {
+	tables map[string]*schema.Table
+}
+
+func (f *fakeLoader) LoadTables(ctx context.Context, database string, tables ...string) (map[string]*schema.Table, error) {
+	return f.tables, nil
+}
+
+func TestRun_InvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("proxy:\n  port: 3308\n"), 0o600))
+
+	report := Run(context.Background(), path)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, Fail, report.Checks[0].Status)
+	assert.Contains(t, report.Checks[0].Detail, "database host is required")
+	assert.False(t, report.OK())
+}
+
+func TestCheckTLS(t *testing.T) {
+	bundle, err := certgen.Generate(certgen.Options{Hosts: []string{"localhost"}, Clients: []string{"proxy"}, Validity: 30 * 24 * time.Hour})
+	require.NoError(t, err)
+	files, err := bundle.Write(t.TempDir(), false)
+	require.NoError(t, err)
+	tlsConfig := config.RedisTLSConfig{Enabled: true, CAFile: files.CA, CertFile: files.Clients[0].Cert, KeyFile: files.Clients[0].Key}
+
+	tests := []struct {
+		name   string
+		tls    config.RedisTLSConfig
+		now    time.Time
+		status Status
+	}{
+		{"disabled", config.RedisTLSConfig{}, time.Now(), Skip},
+		{"valid", tlsConfig, time.Now(), Pass},
+		{"expiring", tlsConfig, time.Now().Add(20 * 24 * time.Hour), Warn},
+		{"expired", tlsConfig, time.Now().Add(31 * 24 * time.Hour), Fail},
+		{"missing ca", config.RedisTLSConfig{Enabled: true, CAFile: files.CA + ".missing"}, time.Now(), Fail},
+		{"key mismatch", config.RedisTLSConfig{Enabled: true, CertFile: files.Clients[0].Cert, KeyFile: files.CAKey}, time.Now(), Fail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Report{}
+			r.checkTLS(tt.tls, tt.now)
+			require.Len(t, r.Checks, 1)
+			assert.Equal(t, tt.status, r.Checks[0].Status, r.Checks[0].Detail)
+		})
+	}
+}
+
+func TestCheckShadowColumns(t *testing.T) {
+	orders := &schema.Table{Database: "shop", Name: "orders", Columns: []schema.Column{
+		{Name: "id", Type: "bigint", DataType: "bigint", PrimaryKey: true, Precision: 19, Scale: 0},
+		{Name: "total_amount_idn", Type: "decimal(19,4)", DataType: "decimal", Precision: 19, Scale: 4},
+		{Name: "shipping_idn", Type: "decimal(19,2)", DataType: "decimal", Precision: 19, Scale: 2},
+	}}
+	cache := schema.NewCache(&fakeLoader{tables: map[string]*schema.Table{"orders": orders}}, 0, false)
+	cfg := &config.Config{
+		Database:   config.DatabaseConfig{Database: "shop"},
+		Conversion: config.ConversionConfig{Precision: 4},
+		Tables: config.TablesConfig{
+			"orders": {Enabled: true, Columns: map[string]config.ColumnConfig{
+				"total_amount": {SourceColumn: "total_amount", TargetColumn: "total_amount_idn"},
+				"shipping":     {SourceColumn: "shipping", TargetColumn: "shipping_idn"},
+			}},
+			"invoices": {Enabled: true},
+			"disabled": {Enabled: false},
+		},
+	}
+
+	r := &Report{}
+	r.checkShadowColumns(context.Background(), cache, cfg)
+	require.Len(t, r.Checks, 2)
+	assert.Equal(t, Check{Name: "table invoices", Status: Fail, Detail: "table invoices: not found in database shop"}, r.Checks[0])
+	assert.Equal(t, "table orders", r.Checks[1].Name)
+	assert.Equal(t, Fail, r.Checks[1].Status)
+	assert.Contains(t, r.Checks[1].Detail, "shipping_idn is decimal(19,2), its scale 2 is below the conversion precision 4")
+	assert.NotContains(t, r.Checks[1].Detail, "total_amount_idn")
+
+	// A column converted to fewer decimals fits
+	shipping := cfg.Tables["orders"].Columns["shipping"]
+	shipping.Precision = 2
+	cfg.Tables["orders"].Columns["shipping"] = shipping
+	delete(cfg.Tables, "invoices")
+	r = &Report{}
+	r.checkShadowColumns(context.Background(), cache, cfg)
+	assert.Equal(t, []Check{{Name: "table orders", Status: Pass, Detail: "2 shadow columns"}}, r.Checks)
+}
+
+func TestInstallationStatus(t *testing.T) {
+	status, detail := installationStatus(dbmode.Installation{Method: config.DatabaseMethodTrigger}, 0)
+	assert.Equal(t, Pass, status)
+	assert.Equal(t, "trigger installed and rounding like the proxy", detail)
+
+	status, detail = installationStatus(dbmode.Installation{Missing: []string{"transisidb_orders_bu"}}, 2)
+	assert.Equal(t, Fail, status)
+	assert.Contains(t, detail, "transisidb_orders_bu not installed")
+
+	status, _ = installationStatus(dbmode.Installation{}, 2)
+	assert.Equal(t, Fail, status)
+
+	status, detail = installationStatus(dbmode.Installation{Outdated: []string{"total_idn"}}, 0)
+	assert.Equal(t, Warn, status)
+	assert.Contains(t, detail, "total_idn differ")
+}
+
+func TestBinlogStatus(t *testing.T) {
+	status, detail := binlogStatus(true, "ROW", "FULL")
+	assert.Equal(t, Pass, status, detail)
+
+	status, detail = binlogStatus(true, "ROW", "MINIMAL")
+	assert.Equal(t, Warn, status)
+	assert.Contains(t, detail, "binlog_row_image is MINIMAL")
+
+	status, detail = binlogStatus(false, "STATEMENT", "FULL")
+	assert.Equal(t, Warn, status)
+	assert.True(t, strings.HasPrefix(detail, "log_bin is OFF; binlog_format is STATEMENT"), detail)
+}
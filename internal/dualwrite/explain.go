@@ -0,0 +1,323 @@
+package dualwrite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/converter"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/internal/rounding"
+)
+
+// Outcomes of an explained statement
+const (
+	// OutcomeRewritten statements reach the backend with their shadow columns
+	OutcomeRewritten = "rewritten"
+	// OutcomeUnchanged statements need no dual-write and are forwarded as-is
+	OutcomeUnchanged = "unchanged"
+	// OutcomeForwarded statements failed to rewrite and a fail_open policy
+	// forwards them without shadow columns
+	OutcomeForwarded = "forwarded"
+	// OutcomeRejected statements failed to rewrite and are refused
+	OutcomeRejected = "rejected"
+)
+
+// Explanation traces how the proxy treats one statement: what the parser saw,
+// the table config that matched, how each currency value was converted and
+// rounded, and the statement sent to the backend
+type Explanation struct {
+	Query     string `json:"query"`
+	Statement string `json:"statement"`
+	// Table is the configured table the statement writes, or the table it
+	// names when that is not configured
+	Table      string `json:"table,omitempty"`
+	Configured bool   `json:"configured"`
+	Enabled    bool   `json:"enabled"`
+	// Mode, WriteMode and RolloutPercent are the table's effective settings
+	Mode           string        `json:"mode,omitempty"`
+	WriteMode      string        `json:"write_mode,omitempty"`
+	RolloutPercent float64       `json:"rollout_percent,omitempty"`
+	Ratio          int           `json:"ratio"`
+	Columns        []ColumnTrace `json:"columns,omitempty"`
+	Outcome        string        `json:"outcome"`
+	// Stage and Error describe a failed rewrite: parse, convert, guard,
+	// range or rewrite
+	Stage         string `json:"stage,omitempty"`
+	Error         string `json:"error,omitempty"`
+	FailurePolicy string `json:"failure_policy,omitempty"`
+	// Rewritten is the statement with its shadow columns
+	Rewritten string `json:"rewritten,omitempty"`
+	// Notes explain settings that make the proxy send something other than
+	// Rewritten
+	Notes []string `json:"notes,omitempty"`
+}
+
+// ColumnTrace is the conversion of one currency value
+type ColumnTrace struct {
+	Column       string `json:"column"`
+	ShadowColumn string `json:"shadow_column"`
+	// Branch is the CASE branch of the value in WHEN order, ELSE last
+	Branch *int `json:"branch,omitempty"`
+	// Row is the row of the value in a multi-row INSERT, counting from 0
+	Row *int `json:"row,omitempty"`
+	// Value is the literal of the statement
+	Value            interface{} `json:"value"`
+	Amount           float64     `json:"amount"`
+	Converted        float64     `json:"converted"`
+	RoundingStrategy string      `json:"rounding_strategy"`
+	Decimals         int         `json:"decimals"`
+	Rounded          float64     `json:"rounded"`
+	// Shadow is the literal written to the shadow column
+	Shadow     string `json:"shadow,omitempty"`
+	NullPolicy string `json:"null_policy,omitempty"`
+	// Suspect is why the conversion guard flags the amount
+	Suspect string `json:"suspect,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Explain traces the rewrite of query under cfg the way the proxy performs
+// it, without executing it, logging or recording metrics. Query rules and
+// live column types from the schema cache are not applied.
+func Explain(cfg *config.Config, query string) *Explanation {
+	p := parser.NewParser(cfg.Tables)
+	p.SetSchema(cfg.Database.Database)
+	p.SetLowerCaseTableNames(cfg.Database.LowerCaseTableNames)
+	p.SetRoundingStrategy(cfg.Conversion.RoundingStrategy)
+	p.SetPrecision(cfg.Conversion.Precision)
+
+	e := &Explanation{Query: query, Statement: parser.QueryTypeUnknown.String(), Ratio: cfg.Conversion.Ratio}
+	pq, err := p.Parse(query)
+	if err != nil {
+		tables := p.GuessWriteTables(query)
+		if len(tables) == 0 {
+			e.Outcome = OutcomeUnchanged
+			e.Notes = append(e.Notes, "the statement does not parse and writes no configured table; it is forwarded as-is")
+			return e
+		}
+		table, _ := cfg.StrictestFailurePolicy(tables, config.FailOpen)
+		e.describeTable(cfg, p, table)
+		e.failed(cfg, "parse", err)
+		return e
+	}
+	e.Statement = pq.Type.String()
+	e.describeTable(cfg, p, pq.TableName)
+	if !pq.NeedsTransform {
+		e.Outcome = OutcomeUnchanged
+		switch {
+		case !e.Configured:
+			e.Notes = append(e.Notes, "no configured table is written")
+		case !e.Enabled:
+			e.Notes = append(e.Notes, "dual-write is disabled for the table")
+		default:
+			e.Notes = append(e.Notes, "the statement assigns no currency column")
+		}
+		return e
+	}
+
+	if e.WriteMode == config.WriteModeDatabase {
+		e.Outcome = OutcomeUnchanged
+		e.Notes = append(e.Notes, "triggers or generated columns in the database fill the table's shadow columns")
+		return e
+	}
+
+	converted, stage, err := e.convert(cfg, p, pq)
+	if err != nil {
+		e.failed(cfg, stage, err)
+		return e
+	}
+	rewritten, err := p.RewriteForDualWrite(pq, converted)
+	if err != nil {
+		e.failed(cfg, "rewrite", err)
+		return e
+	}
+	e.Outcome = OutcomeRewritten
+	e.Rewritten = rewritten
+
+	if e.RolloutPercent < 100 {
+		e.Notes = append(e.Notes, fmt.Sprintf("the table is rolled out to %v%% of statements; the others are forwarded as-is", e.RolloutPercent))
+	}
+	if e.Mode == config.TableModeObserve {
+		e.Notes = append(e.Notes, "the table is in observe mode: the rewrite is logged and counted, and the statement is forwarded as-is")
+	} else if e.WriteMode == config.WriteModeAsync {
+		e.Notes = append(e.Notes, "the table writes asynchronously: the statement is forwarded as-is and the outbox writes the shadow values")
+	}
+	return e
+}
+
+// describeTable records the effective settings of the table
+func (e *Explanation) describeTable(cfg *config.Config, p *parser.Parser, table string) {
+	e.Table = table
+	key, tableConfig, ok := p.LookupTable("", table)
+	if !ok {
+		return
+	}
+	e.Table = key
+	e.Configured = true
+	e.Enabled = tableConfig.Enabled
+	e.Mode = tableConfig.Mode
+	if e.Mode == "" {
+		e.Mode = config.TableModeEnforce
+	}
+	e.WriteMode = tableConfig.WriteMode
+	if e.WriteMode == "" {
+		e.WriteMode = config.WriteModeSync
+	}
+	e.RolloutPercent = 100
+	if tableConfig.Rollout != nil {
+		e.RolloutPercent = tableConfig.Rollout.Percent
+	}
+}
+
+// failed records a failed rewrite and what the table's failure policy does
+// with the statement
+func (e *Explanation) failed(cfg *config.Config, stage string, err error) {
+	e.Stage = stage
+	e.Error = err.Error()
+	e.FailurePolicy = converter.FailurePolicy(cfg, e.Table, config.FailOpen, err)
+	e.Outcome = OutcomeForwarded
+	if e.FailurePolicy == config.FailClosed {
+		e.Outcome = OutcomeRejected
+	}
+	if e.Mode == config.TableModeObserve {
+		e.Notes = append(e.Notes, "the table is in observe mode: the failure is counted and the statement is forwarded as-is")
+	}
+}
+
+// convert converts the currency values of pq like the proxy does, tracing
+// each one. On failure it also returns the stage that failed.
+func (e *Explanation) convert(cfg *config.Config, p *parser.Parser, pq *parser.ParsedQuery) (map[string]float64, string, error) {
+	_, tableConfig, _ := p.LookupTable("", pq.TableName)
+	guard := converter.NewGuard(cfg.Conversion, converter.SourceProxy)
+	amounts := converter.NewAmountParser(cfg.Conversion.AmountLocales)
+	converted := make(map[string]float64)
+
+	trace := func(key, col string, branch, row *int, value interface{}) (string, error) {
+		_, colConfig, _ := tableConfig.LookupColumn(col)
+		t := ColumnTrace{
+			Column:           col,
+			ShadowColumn:     colConfig.TargetColumn,
+			Branch:           branch,
+			Row:              row,
+			Value:            value,
+			RoundingStrategy: colConfig.EffectiveRoundingStrategy(cfg.Conversion.RoundingStrategy),
+			Decimals:         parser.ShadowDecimals(colConfig, cfg.Conversion.Precision),
+		}
+		defer func() { e.Columns = append(e.Columns, t) }()
+
+		if value == nil {
+			t.NullPolicy = colConfig.EffectiveNullPolicy()
+			return "", nil
+		}
+		amount, err := amounts.Parse(value)
+		if err != nil {
+			t.Error = err.Error()
+			return "convert", fmt.Errorf("column %s: %w", col, err)
+		}
+		t.Amount = amount
+		if t.Suspect = guard.Suspect(colConfig, amount, nil); t.Suspect != "" && guard.Rejects() {
+			err := fmt.Errorf("%w: column %s amount %v (%s)", converter.ErrSuspectAmount, col, amount, t.Suspect)
+			t.Error = err.Error()
+			return "guard", err
+		}
+		if _, err := converter.OutsideBounds(col, colConfig, amount); err != nil {
+			t.Error = err.Error()
+			return "range", err
+		}
+
+		t.Converted = amount / float64(cfg.Conversion.Ratio)
+		t.Rounded = rounding.NewEngine(rounding.Strategy(t.RoundingStrategy), t.Decimals).Round(t.Converted)
+		t.Shadow, err = parser.FormatShadowValue(colConfig, cfg.Conversion.RoundingStrategy, cfg.Conversion.Precision, t.Converted)
+		if err != nil {
+			t.Error = err.Error()
+		}
+		converted[key] = t.Converted
+		return "", nil
+	}
+
+	for _, col := range pq.CurrencyColumns {
+		value, ok := pq.Values[col]
+		_, isCase := pq.CaseValues[col]
+		if _, multiRow := pq.RowValues[col]; isCase || multiRow || !ok {
+			continue
+		}
+		if stage, err := trace(col, col, nil, nil, value); err != nil {
+			return nil, stage, err
+		}
+	}
+	for _, col := range pq.CurrencyColumns {
+		for i, value := range pq.CaseValues[col] {
+			branch := i
+			if stage, err := trace(parser.CaseValueKey(col, i), col, &branch, nil, value); err != nil {
+				return nil, stage, err
+			}
+		}
+		for i, value := range pq.RowValues[col] {
+			row := i
+			if stage, err := trace(parser.RowValueKey(col, i), col, nil, &row, value); err != nil {
+				return nil, stage, err
+			}
+		}
+	}
+	return converted, "", nil
+}
+
+// String formats the explanation as a report for people
+func (e *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Statement:  %s\n", e.Query)
+	fmt.Fprintf(&b, "Type:       %s\n", e.Statement)
+	switch {
+	case e.Table == "":
+	case !e.Configured:
+		fmt.Fprintf(&b, "Table:      %s (not configured)\n", e.Table)
+	default:
+		fmt.Fprintf(&b, "Table:      %s (enabled: %t, mode: %s, write mode: %s, rollout: %v%%)\n",
+			e.Table, e.Enabled, e.Mode, e.WriteMode, e.RolloutPercent)
+	}
+	if len(e.Columns) > 0 {
+		fmt.Fprintf(&b, "Conversion: ratio 1:%d\n", e.Ratio)
+	}
+	for _, c := range e.Columns {
+		name := c.Column
+		if c.Branch != nil {
+			name = fmt.Sprintf("%s (CASE branch %d)", c.Column, *c.Branch)
+		} else if c.Row != nil {
+			name = fmt.Sprintf("%s (row %d)", c.Column, *c.Row)
+		}
+		switch {
+		case c.Value == nil:
+			fmt.Fprintf(&b, "  %s -> %s: NULL, null policy %s\n", name, c.ShadowColumn, c.NullPolicy)
+			continue
+		case c.Error != "" && c.Shadow == "":
+			fmt.Fprintf(&b, "  %s -> %s: %v: %s\n", name, c.ShadowColumn, c.Value, c.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -> %s: %v -> %s / %d = %s -> %s to %d decimals = %s -> %s\n",
+			name, c.ShadowColumn, c.Value, formatAmount(c.Amount), e.Ratio, formatAmount(c.Converted),
+			c.RoundingStrategy, c.Decimals, formatAmount(c.Rounded), c.Shadow)
+		if c.Suspect != "" {
+			fmt.Fprintf(&b, "    flagged by the conversion guard: %s\n", c.Suspect)
+		}
+		if c.Error != "" {
+			fmt.Fprintf(&b, "    %s\n", c.Error)
+		}
+	}
+	fmt.Fprintf(&b, "Outcome:    %s\n", e.Outcome)
+	if e.Error != "" {
+		fmt.Fprintf(&b, "Failure:    %s failed: %s (failure policy %s)\n", e.Stage, e.Error, e.FailurePolicy)
+	}
+	if e.Rewritten != "" {
+		fmt.Fprintf(&b, "Rewritten:  %s\n", e.Rewritten)
+	}
+	for _, note := range e.Notes {
+		fmt.Fprintf(&b, "Note:       %s\n", note)
+	}
+	return b.String()
+}
+
+// formatAmount formats an amount without an exponent
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
@@ -0,0 +1,105 @@
+package dualwrite
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain_Rewritten(t *testing.T) {
+	cfg := getTestConfig()
+	e := Explain(cfg, "INSERT INTO orders (customer_id, total_amount, shipping_fee) VALUES (123, 500000, 25000)")
+
+	assert.Equal(t, OutcomeRewritten, e.Outcome)
+	assert.Equal(t, "INSERT", e.Statement)
+	assert.Equal(t, "orders", e.Table)
+	assert.True(t, e.Configured)
+	assert.Equal(t, config.TableModeEnforce, e.Mode)
+	assert.Contains(t, e.Rewritten, "total_amount_idn")
+	assert.Empty(t, e.Notes)
+
+	require.Len(t, e.Columns, 2)
+	col := e.Columns[0]
+	assert.Equal(t, "total_amount", col.Column)
+	assert.Equal(t, "total_amount_idn", col.ShadowColumn)
+	assert.Equal(t, 500000.0, col.Amount)
+	assert.Equal(t, 500.0, col.Converted)
+	assert.Equal(t, "BANKERS_ROUND", col.RoundingStrategy)
+	assert.Equal(t, 4, col.Decimals)
+	assert.Equal(t, "500.0000", col.Shadow)
+
+	report := e.String()
+	assert.Contains(t, report, "total_amount -> total_amount_idn")
+	assert.Contains(t, report, "Rewritten:")
+}
+
+func TestExplain_MultiRowInsert(t *testing.T) {
+	cfg := getTestConfig()
+	e := Explain(cfg, "INSERT INTO orders (customer_id, total_amount) VALUES (1, 100000), (2, 9900000)")
+
+	assert.Equal(t, OutcomeRewritten, e.Outcome)
+	assert.Contains(t, e.Rewritten, "(1, 100000, 100.0000), (2, 9900000, 9900.0000)")
+
+	require.Len(t, e.Columns, 2)
+	for i, shadow := range []string{"100.0000", "9900.0000"} {
+		require.NotNil(t, e.Columns[i].Row)
+		assert.Equal(t, i, *e.Columns[i].Row)
+		assert.Equal(t, shadow, e.Columns[i].Shadow)
+	}
+	assert.Contains(t, e.String(), "total_amount (row 1) -> total_amount_idn")
+}
+
+func TestExplain_Unchanged(t *testing.T) {
+	cfg := getTestConfig()
+
+	e := Explain(cfg, "SELECT * FROM orders")
+	assert.Equal(t, OutcomeUnchanged, e.Outcome)
+	assert.Empty(t, e.Rewritten)
+
+	e = Explain(cfg, "INSERT INTO customers (name) VALUES ('a')")
+	assert.Equal(t, OutcomeUnchanged, e.Outcome)
+	assert.False(t, e.Configured)
+}
+
+func TestExplain_FailurePolicy(t *testing.T) {
+	cfg := getTestConfig()
+	e := Explain(cfg, "INSERT INTO orders (total_amount) VALUES ('abc')")
+	assert.Equal(t, "convert", e.Stage)
+	assert.Equal(t, config.FailOpen, e.FailurePolicy)
+	assert.Equal(t, OutcomeForwarded, e.Outcome)
+
+	orders := cfg.Tables["orders"]
+	orders.FailurePolicy = config.FailClosed
+	cfg.Tables["orders"] = orders
+	e = Explain(cfg, "INSERT INTO orders (total_amount) VALUES ('abc')")
+	assert.Equal(t, OutcomeRejected, e.Outcome)
+	assert.Contains(t, e.String(), "convert failed")
+}
+
+func TestExplain_ObserveNote(t *testing.T) {
+	cfg := getTestConfig()
+	orders := cfg.Tables["orders"]
+	orders.Mode = config.TableModeObserve
+	cfg.Tables["orders"] = orders
+
+	e := Explain(cfg, "UPDATE orders SET total_amount = 1000 WHERE id = 1")
+	assert.Equal(t, OutcomeRewritten, e.Outcome)
+	require.Len(t, e.Notes, 1)
+	assert.Contains(t, e.Notes[0], "observe mode")
+}
+
+func TestExplain_DatabaseWriteMode(t *testing.T) {
+	cfg := getTestConfig()
+	orders := cfg.Tables["orders"]
+	orders.WriteMode = config.WriteModeDatabase
+	cfg.Tables["orders"] = orders
+
+	e := Explain(cfg, "UPDATE orders SET total_amount = 1000 WHERE id = 1")
+	assert.Equal(t, OutcomeUnchanged, e.Outcome)
+	assert.Equal(t, config.WriteModeDatabase, e.WriteMode)
+	assert.Empty(t, e.Rewritten)
+	require.Len(t, e.Notes, 1)
+	assert.Contains(t, e.Notes[0], "triggers or generated columns")
+}
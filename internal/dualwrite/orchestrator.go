@@ -3,41 +3,51 @@ package dualwrite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/converter"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
 	"github.com/kafitramarna/TransisiDB/internal/parser"
-	"github.com/kafitramarna/TransisiDB/internal/rounding"
 )
 
 // Orchestrator manages dual-write operations
 type Orchestrator struct {
-	db             *sql.DB
-	parser         *parser.Parser
-	roundingEngine *rounding.Engine
-	config         *config.Config
+	db     *sql.DB
+	parser *parser.Parser
+	config *config.Config
 }
 
 // NewOrchestrator creates a new dual-write orchestrator
 func NewOrchestrator(db *sql.DB, cfg *config.Config) *Orchestrator {
+	p := parser.NewParser(cfg.Tables)
+	p.SetSchema(cfg.Database.Database)
+	p.SetLowerCaseTableNames(cfg.Database.LowerCaseTableNames)
+	p.SetRoundingStrategy(cfg.Conversion.RoundingStrategy)
+	p.SetPrecision(cfg.Conversion.Precision)
+
 	return &Orchestrator{
 		db:     db,
-		parser: parser.NewParser(cfg.Tables),
-		roundingEngine: rounding.NewEngine(
-			rounding.Strategy(cfg.Conversion.RoundingStrategy),
-			cfg.Conversion.Precision,
-		),
+		parser: p,
 		config: cfg,
 	}
 }
 
-// InterceptAndRewrite intercepts a query and rewrites it for dual-write if needed
+// InterceptAndRewrite intercepts a query and rewrites it for dual-write if needed.
+// Failures are subject to the table's failure policy: fail_closed returns an
+// error, the fail_open variants return the original query unchanged.
 func (o *Orchestrator) InterceptAndRewrite(query string) (string, error) {
 	// Parse the query
 	pq, err := o.parser.Parse(query)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse query: %w", err)
+		tables := o.parser.GuessWriteTables(query)
+		if len(tables) == 0 {
+			return "", fmt.Errorf("failed to parse query: %w", err)
+		}
+		table, _ := o.config.StrictestFailurePolicy(tables, config.FailClosed)
+		return o.rewriteFailed(query, table, "parse", fmt.Errorf("failed to parse query: %w", err))
 	}
 
 	// If transformation is not needed, return original query
@@ -48,27 +58,48 @@ func (o *Orchestrator) InterceptAndRewrite(query string) (string, error) {
 	// Extract and convert values
 	convertedValues, err := o.convertCurrencyValues(pq)
 	if err != nil {
-		return "", fmt.Errorf("failed to convert values: %w", err)
+		return o.rewriteFailed(query, pq.TableName, "convert", fmt.Errorf("failed to convert values: %w", err))
 	}
 
 	// Rewrite query to include shadow columns
 	rewritten, err := o.parser.RewriteForDualWrite(pq, convertedValues)
 	if err != nil {
-		return "", fmt.Errorf("failed to rewrite query: %w", err)
+		return o.rewriteFailed(query, pq.TableName, "rewrite", fmt.Errorf("failed to rewrite query: %w", err))
 	}
 
 	return rewritten, nil
 }
 
+// rewriteFailed applies the table's failure policy to a failed rewrite.
+// Amounts the conversion guard rejects are always refused, and placeholder
+// amounts follow conversion.placeholder_policy.
+func (o *Orchestrator) rewriteFailed(query, table, stage string, cause error) (string, error) {
+	// Without a configured policy the orchestrator keeps failing closed
+	policy := converter.FailurePolicy(o.config, table, config.FailClosed, cause)
+	metrics.RecordRewriteFailure(table, stage, policy)
+	if errors.Is(cause, converter.ErrPlaceholder) {
+		converter.RecordPlaceholder(table, policy)
+	}
+
+	switch policy {
+	case config.FailOpen:
+		logger.Warn("Executing statement without dual-write", "table", table, "stage", stage, "error", cause)
+		return query, nil
+	case config.FailOpenWithAlert:
+		logger.Error("ALERT: executing statement without dual-write", "table", table, "stage", stage, "error", cause)
+		return query, nil
+	default:
+		return "", cause
+	}
+}
+
 // ExecuteWithDualWrite executes a query with dual-write transformation
 func (o *Orchestrator) ExecuteWithDualWrite(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	// Rewrite the query
 	rewritten, err := o.InterceptAndRewrite(query)
 	if err != nil {
-		// If rewrite fails, we can either:
-		// 1. Fail-safe: execute original query (lose dual-write)
-		// 2. Fail-closed: return error (safer for data consistency)
-		// We choose fail-closed approach
+		// The table's failure policy already decided between forwarding the
+		// original query and failing; reaching here means fail-closed
 		return nil, fmt.Errorf("dual-write rewrite failed: %w", err)
 	}
 
@@ -105,39 +136,8 @@ func (o *Orchestrator) QueryWithDualWrite(ctx context.Context, query string, arg
 
 // convertCurrencyValues converts IDR values to IDN for all currency columns
 func (o *Orchestrator) convertCurrencyValues(pq *parser.ParsedQuery) (map[string]float64, error) {
-	converted := make(map[string]float64)
-
-	for _, colName := range pq.CurrencyColumns {
-		// Get the value from parsed query
-		value, exists := pq.Values[colName]
-		if !exists {
-			continue
-		}
-
-		// Convert to int64
-		var intValue int64
-		switch v := value.(type) {
-		case int64:
-			intValue = v
-		case int:
-			intValue = int64(v)
-		case string:
-			// Parse string to int
-			parsed, err := strconv.ParseInt(v, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse value for column %s: %w", colName, err)
-			}
-			intValue = parsed
-		default:
-			return nil, fmt.Errorf("unsupported value type for column %s: %T", colName, v)
-		}
-
-		// Convert using rounding engine
-		convertedValue := o.roundingEngine.ConvertIDRtoIDN(intValue, o.config.Conversion.Ratio)
-		converted[colName] = convertedValue
-	}
-
-	return converted, nil
+	converted, _, err := converter.ConvertStatement(o.config.Conversion, o.config.Tables[pq.TableName], pq, nil)
+	return converted, err
 }
 
 // Stats tracks dual-write statistics
@@ -108,15 +108,13 @@ func TestInterceptAndRewrite_Insert(t *testing.T) {
 			// We'll test the rewrite logic without actual DB
 			// Create orchestrator with nil db (won't execute, just rewrite)
 			orch := &Orchestrator{
-				db:             nil, // nil for rewrite-only testing
-				parser:         nil, // will be set below
-				roundingEngine: nil, // will be set below
-				config:         cfg,
+				db:     nil, // nil for rewrite-only testing
+				parser: nil, // will be set below
+				config: cfg,
 			}
 
 			// Initialize components
 			orch.parser = NewOrchestrator(nil, cfg).parser
-			orch.roundingEngine = NewOrchestrator(nil, cfg).roundingEngine
 
 			rewritten, err := orch.InterceptAndRewrite(tt.query)
 
@@ -141,13 +139,11 @@ func TestInterceptAndRewrite_Update(t *testing.T) {
 	cfg := getTestConfig()
 
 	orch := &Orchestrator{
-		db:             nil,
-		parser:         nil,
-		roundingEngine: nil,
-		config:         cfg,
+		db:     nil,
+		parser: nil,
+		config: cfg,
 	}
 	orch.parser = NewOrchestrator(nil, cfg).parser
-	orch.roundingEngine = NewOrchestrator(nil, cfg).roundingEngine
 
 	query := "UPDATE orders SET total_amount = 750000 WHERE id = 123"
 	rewritten, err := orch.InterceptAndRewrite(query)
@@ -160,6 +156,16 @@ func TestInterceptAndRewrite_Update(t *testing.T) {
 	t.Logf("Rewritten: %s", rewritten)
 }
 
+func TestInterceptAndRewrite_UpdateCase(t *testing.T) {
+	orch := NewOrchestrator(nil, getTestConfig())
+
+	query := "UPDATE orders SET total_amount = CASE id WHEN 1 THEN 750000 WHEN 2 THEN 1234567 END WHERE id IN (1, 2)"
+	rewritten, err := orch.InterceptAndRewrite(query)
+
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "total_amount_idn = case id when 1 then 750.0000 when 2 then 1234.5670 end")
+}
+
 func TestConvertCurrencyValues(t *testing.T) {
 	cfg := getTestConfig()
 	orch := NewOrchestrator(nil, cfg)
@@ -208,3 +214,73 @@ func TestInterceptAndRewrite_NoTransform(t *testing.T) {
 		})
 	}
 }
+
+func TestInterceptAndRewrite_QuotedIdentifiers(t *testing.T) {
+	cfg := getTestConfig()
+	cfg.Database.LowerCaseTableNames = 1
+	orch := NewOrchestrator(nil, cfg)
+
+	query := "INSERT INTO `Orders` (`customer_id`, `TOTAL_AMOUNT`) VALUES (123, 500000)"
+	rewritten, err := orch.InterceptAndRewrite(query)
+
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "total_amount_idn")
+	assert.Contains(t, rewritten, "500.0000")
+}
+
+func TestInterceptAndRewrite_FailurePolicy(t *testing.T) {
+	query := "INSERT INTO orders (total_amount) VALUES (99999999999999999999999)"
+
+	cfg := getTestConfig()
+	orch := NewOrchestrator(nil, cfg)
+	_, err := orch.InterceptAndRewrite(query)
+	assert.Error(t, err, "default policy is fail_closed")
+
+	cfg = getTestConfig()
+	orders := cfg.Tables["orders"]
+	orders.FailurePolicy = config.FailOpen
+	cfg.Tables["orders"] = orders
+	orch = NewOrchestrator(nil, cfg)
+	rewritten, err := orch.InterceptAndRewrite(query)
+	require.NoError(t, err)
+	assert.Equal(t, query, rewritten)
+}
+
+func TestInterceptAndRewrite_FractionalAndFormattedAmounts(t *testing.T) {
+	cfg := getTestConfig()
+	orch := NewOrchestrator(nil, cfg)
+
+	rewritten, err := orch.InterceptAndRewrite("INSERT INTO orders (total_amount) VALUES (200.9)")
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "0.2009")
+
+	rewritten, err = orch.InterceptAndRewrite("INSERT INTO orders (total_amount) VALUES ('1.500.000')")
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "1500.0000")
+
+	// A numeric literal is unambiguous, a quoted "1.500" is not
+	rewritten, err = orch.InterceptAndRewrite("INSERT INTO orders (total_amount) VALUES (1.500)")
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "0.0015")
+
+	_, err = orch.InterceptAndRewrite("INSERT INTO orders (total_amount) VALUES ('1.500')")
+	assert.ErrorContains(t, err, "ambiguous")
+}
+
+func TestInterceptAndRewrite_AmountLocales(t *testing.T) {
+	cfg := getTestConfig()
+	cfg.Conversion.AmountLocales = []string{config.AmountLocaleID}
+	orch := NewOrchestrator(nil, cfg)
+
+	// With only Indonesian formatting a dot always groups
+	rewritten, err := orch.InterceptAndRewrite("INSERT INTO orders (total_amount) VALUES ('1.500')")
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "1.5000")
+
+	rewritten, err = orch.InterceptAndRewrite("INSERT INTO orders (total_amount) VALUES ('1500000,00')")
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "1500.0000")
+
+	_, err = orch.InterceptAndRewrite("INSERT INTO orders (total_amount) VALUES ('1,500,000.00')")
+	assert.ErrorContains(t, err, "not in conversion.amount_locales")
+}
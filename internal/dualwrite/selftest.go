@@ -0,0 +1,142 @@
+package dualwrite
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/internal/rounding"
+)
+
+// Failure is a conversion invariant a column broke
+type Failure struct {
+	Table  string
+	Column string
+	Amount float64
+	Err    error
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("%s.%s amount %v: %v", f.Table, f.Column, f.Amount, f.Err)
+}
+
+// SampleAmounts returns n IDR amounts for SelfTest: amounts on and around
+// ratio boundaries followed by random amounts from seed, of every magnitude
+// up to 10^14
+func SampleAmounts(seed int64, n int) []float64 {
+	amounts := []float64{0, 1, 499, 500, 501, 999, 1000, 1500, 1500500, 1500500.5, -2500, 99999999999999}
+	r := rand.New(rand.NewSource(seed))
+	for len(amounts) < n {
+		magnitude := math.Pow10(r.Intn(15))
+		amount := math.Round(r.Float64()*magnitude*100) / 100
+		if r.Intn(4) == 0 {
+			amount = -amount
+		}
+		amounts = append(amounts, amount)
+	}
+	return amounts[:n]
+}
+
+// SelfTest rewrites an UPDATE of every configured currency column for each
+// amount and checks the shadow literal: it must convert back to within one
+// rupiah of the amount, and ties of the column's rounding strategy must land
+// where the strategy puts them. Tables are tested whether enabled or not.
+// Amounts outside a column's bounds or too large for its shadow type are
+// skipped.
+func SelfTest(cfg *config.Config, amounts []float64) []Failure {
+	// The guard refuses implausible amounts; conversion is tested on all
+	tested := *cfg
+	tested.Conversion.Guard = config.GuardConfig{}
+	tested.Tables = make(config.TablesConfig, len(cfg.Tables))
+	for name, tableConfig := range cfg.Tables {
+		tableConfig.Enabled = true
+		tested.Tables[name] = tableConfig
+	}
+	orch := NewOrchestrator(nil, &tested)
+
+	tables := make([]string, 0, len(tested.Tables))
+	for name := range tested.Tables {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+
+	var failures []Failure
+	for _, table := range tables {
+		columns := make([]string, 0, len(tested.Tables[table].Columns))
+		for col := range tested.Tables[table].Columns {
+			columns = append(columns, col)
+		}
+		sort.Strings(columns)
+
+		for _, col := range columns {
+			colConfig := tested.Tables[table].Columns[col]
+			failures = append(failures, checkTies(table, col, colConfig, tested.Conversion)...)
+
+			literal := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(colConfig.TargetColumn) + "`? = (-?[0-9.]+)")
+			limit := amountLimit(colConfig, tested.Conversion.Ratio)
+			for _, amount := range amounts {
+				if math.Abs(amount) >= limit ||
+					(colConfig.BoundsMin != nil && amount < *colConfig.BoundsMin) || (colConfig.BoundsMax != nil && amount > *colConfig.BoundsMax) {
+					continue
+				}
+				if err := checkRewrite(orch, literal, table, col, amount, tested.Conversion.Ratio); err != nil {
+					failures = append(failures, Failure{Table: table, Column: col, Amount: amount, Err: err})
+				}
+			}
+		}
+	}
+	return failures
+}
+
+// checkRewrite rewrites an UPDATE setting column to amount and converts the
+// shadow literal back
+func checkRewrite(orch *Orchestrator, literal *regexp.Regexp, table, column string, amount float64, ratio int) error {
+	query := fmt.Sprintf("UPDATE %s SET %s = %s WHERE id = 1", table, column, strconv.FormatFloat(amount, 'f', -1, 64))
+	rewritten, err := orch.InterceptAndRewrite(query)
+	if err != nil {
+		return err
+	}
+	match := literal.FindStringSubmatch(rewritten)
+	if match == nil {
+		return fmt.Errorf("no shadow value in %q", rewritten)
+	}
+	idn, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid shadow value %q", match[1])
+	}
+	if back := idn * float64(ratio); math.Abs(back-amount) > 1+math.Abs(amount)*1e-15 {
+		return fmt.Errorf("shadow value %s converts back to %v", match[1], back)
+	}
+	return nil
+}
+
+// checkTies checks the ties of the column's rounding strategy at the scale
+// its shadow values are written with
+func checkTies(table, column string, colConfig config.ColumnConfig, conv config.ConversionConfig) []Failure {
+	// Same scale as parser.FormatShadowValue
+	decimals := parser.ShadowDecimals(colConfig, conv.Precision)
+	engine := rounding.NewEngine(rounding.Strategy(colConfig.EffectiveRoundingStrategy(conv.RoundingStrategy)), decimals)
+
+	var failures []Failure
+	for _, units := range []int64{0, 1, 2, 14, 15, 99, -1, -2, -15, rounding.MaxTieUnits - 1} {
+		if err := engine.CheckTie(units); err != nil {
+			failures = append(failures, Failure{Table: table, Column: column, Amount: (float64(units) + 0.5) / math.Pow10(decimals), Err: err})
+		}
+	}
+	return failures
+}
+
+// amountLimit returns the IDR amount of the largest whole IDN amount the
+// column's DECIMAL shadow type holds, or +Inf
+func amountLimit(colConfig config.ColumnConfig, ratio int) float64 {
+	precision, scale, ok := config.ParseDecimalType(colConfig.TargetType)
+	if !ok {
+		return math.Inf(1)
+	}
+	return (math.Pow10(precision-scale) - 1) * float64(ratio)
+}
@@ -0,0 +1,60 @@
+package dualwrite
+
+import (
+	"math"
+	"regexp"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTest(t *testing.T) {
+	cfg := getTestConfig()
+	assert.Empty(t, SelfTest(cfg, SampleAmounts(1, 500)))
+
+	// Two decimals of IDN are ten rupiah at a ratio of 1000
+	cfg.Tables["orders"].Columns["shipping_fee"] = config.ColumnConfig{
+		TargetColumn: "shipping_fee_idn", TargetType: "DECIMAL(12,2)", Precision: 2,
+	}
+	failures := SelfTest(cfg, []float64{1504})
+	require.Len(t, failures, 1)
+	assert.Equal(t, "shipping_fee", failures[0].Column)
+	assert.Contains(t, failures[0].String(), "orders.shipping_fee amount 1504: shadow value 1.50 converts back to 1500")
+}
+
+func TestSampleAmounts(t *testing.T) {
+	amounts := SampleAmounts(7, 200)
+	assert.Len(t, amounts, 200)
+	assert.Equal(t, amounts, SampleAmounts(7, 200), "samples must be reproducible")
+	for _, amount := range amounts {
+		assert.Less(t, math.Abs(amount), 1e14)
+	}
+}
+
+func FuzzInterceptAndRewrite_RoundTrip(f *testing.F) {
+	for _, seed := range []float64{0, 1, 500, 1500, 1500500, 1500500.5, -2500, 99999999999999} {
+		f.Add(seed)
+	}
+	var orchestrators []*Orchestrator
+	for _, strategy := range []string{"BANKERS_ROUND", "ARITHMETIC_ROUND"} {
+		cfg := getTestConfig()
+		column := cfg.Tables["orders"].Columns["total_amount"]
+		column.RoundingStrategy = strategy
+		cfg.Tables["orders"].Columns["total_amount"] = column
+		orchestrators = append(orchestrators, NewOrchestrator(nil, cfg))
+	}
+	literal := regexp.MustCompile(`total_amount_idn = (-?[0-9.]+)`)
+
+	f.Fuzz(func(t *testing.T, amount float64) {
+		if math.IsNaN(amount) || math.IsInf(amount, 0) || math.Abs(amount) >= 1e14 {
+			t.Skip()
+		}
+		for _, orch := range orchestrators {
+			if err := checkRewrite(orch, literal, "orders", "total_amount", amount, 1000); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,316 @@
+// Package e2e runs the end-to-end smoke test: MySQL and Redis in Docker with
+// the proxy and the management API in-process, and the dual-write,
+// transaction and rounding scenarios run through them.
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/kafitramarna/TransisiDB/internal/api"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/proxy"
+	"github.com/testcontainers/testcontainers-go"
+	tcmysql "github.com/testcontainers/testcontainers-go/modules/mysql"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Default images of the containers
+const (
+	DefaultMySQLImage = "mysql:8.0"
+	DefaultRedisImage = "redis:7-alpine"
+)
+
+// mysqlPassword is the root password of the MySQL container
+const mysqlPassword = "e2e-secret"
+
+// startTimeout bounds how long the proxy and API take to listen
+const startTimeout = 10 * time.Second
+
+// Options configure a run
+type Options struct {
+	// ConfigPath is the config file the proxy and API start from; its
+	// database, Redis and listen addresses are replaced
+	ConfigPath string
+	// SchemaPath is the SQL script creating the tables in ecommerce_db
+	SchemaPath string
+	MySQLImage string
+	RedisImage string
+}
+
+// Result is the outcome of one scenario
+type Result struct {
+	Name     string        `json:"name"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Passed reports whether the scenario passed
+func (r Result) Passed() bool {
+	return r.Error == ""
+}
+
+// Report is the outcome of every scenario, in the order they ran
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Failed returns the number of failed scenarios
+func (r *Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if !result.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// env is the running deployment the scenarios use
+type env struct {
+	// db connects through the proxy
+	db     *sql.DB
+	apiURL string
+	apiKey string
+	client *http.Client
+}
+
+// Run starts the containers, the proxy and the API and runs the scenarios.
+// It returns an error when the deployment cannot be started; failed
+// scenarios are in the report.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.MySQLImage == "" {
+		opts.MySQLImage = DefaultMySQLImage
+	}
+	if opts.RedisImage == "" {
+		opts.RedisImage = DefaultRedisImage
+	}
+	cfg, err := config.Load(opts.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	mysqlAddr, stopMySQL, err := startMySQL(ctx, opts.MySQLImage, opts.SchemaPath)
+	defer stopMySQL()
+	if err != nil {
+		return nil, err
+	}
+	redisAddr, stopRedis, err := startRedis(ctx, opts.RedisImage)
+	defer stopRedis()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := deploymentConfig(cfg, mysqlAddr, redisAddr); err != nil {
+		return nil, err
+	}
+	e, stop, err := start(ctx, cfg)
+	defer stop()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	for _, scenario := range scenarios {
+		started := time.Now()
+		result := Result{Name: scenario.name}
+		if err := scenario.run(ctx, e); err != nil {
+			result.Error = err.Error()
+		}
+		result.Duration = time.Since(started)
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// startMySQL runs a MySQL container with the schema in ecommerce_db and
+// returns its address and a function removing it
+func startMySQL(ctx context.Context, image, schema string) (string, func(), error) {
+	ctr, err := tcmysql.Run(ctx, image,
+		tcmysql.WithDatabase("ecommerce_db"),
+		tcmysql.WithUsername("root"),
+		tcmysql.WithPassword(mysqlPassword),
+		tcmysql.WithScripts(schema))
+	stop := func() {
+		if ctr != nil {
+			testcontainers.TerminateContainer(ctr)
+		}
+	}
+	if err != nil {
+		return "", stop, fmt.Errorf("failed to start %s: %w", image, err)
+	}
+	addr, err := containerAddr(ctx, ctr, "3306/tcp")
+	return addr, stop, err
+}
+
+// startRedis runs a Redis container and returns its address and a function
+// removing it
+func startRedis(ctx context.Context, image string) (string, func(), error) {
+	ctr, err := testcontainers.Run(ctx, image,
+		testcontainers.WithExposedPorts("6379/tcp"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("6379/tcp")))
+	stop := func() {
+		if ctr != nil {
+			testcontainers.TerminateContainer(ctr)
+		}
+	}
+	if err != nil {
+		return "", stop, fmt.Errorf("failed to start %s: %w", image, err)
+	}
+	addr, err := containerAddr(ctx, ctr, "6379/tcp")
+	return addr, stop, err
+}
+
+func containerAddr(ctx context.Context, ctr testcontainers.Container, port string) (string, error) {
+	host, err := ctr.Host(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get container host: %w", err)
+	}
+	mapped, err := ctr.MappedPort(ctx, port)
+	if err != nil {
+		return "", fmt.Errorf("failed to get container port: %w", err)
+	}
+	return net.JoinHostPort(host, mapped.Port()), nil
+}
+
+// deploymentConfig points cfg at the containers and at free local ports for
+// the proxy, its admin endpoint and the API
+func deploymentConfig(cfg *config.Config, mysqlAddr, redisAddr string) error {
+	mysqlHost, mysqlPort, _ := net.SplitHostPort(mysqlAddr)
+	redisHost, redisPort, _ := net.SplitHostPort(redisAddr)
+
+	cfg.Database.Host = mysqlHost
+	cfg.Database.Port, _ = strconv.Atoi(mysqlPort)
+	cfg.Database.Socket = ""
+	cfg.Database.User = "root"
+	cfg.Database.Password = mysqlPassword
+	cfg.Database.Database = "ecommerce_db"
+	cfg.Database.Replicas = nil
+	cfg.Database.Failover.Standbys = nil
+	cfg.Database.Discovery = config.DiscoveryConfig{}
+	cfg.Redis = config.RedisConfig{Host: redisHost, PoolSize: 4}
+	cfg.Redis.Port, _ = strconv.Atoi(redisPort)
+
+	ports := make([]int, 3)
+	for i := range ports {
+		port, err := freePort()
+		if err != nil {
+			return err
+		}
+		ports[i] = port
+	}
+	cfg.Proxy.Host = "127.0.0.1"
+	cfg.Proxy.Port = ports[0]
+	cfg.Proxy.Socket = ""
+	cfg.Proxy.Listeners = nil
+	cfg.Monitoring.PrometheusEnabled = true
+	cfg.Monitoring.PrometheusPort = ports[1]
+	cfg.API.Host = "127.0.0.1"
+	cfg.API.Port = ports[2]
+	cfg.API.ProxyAdminURL = ""
+	cfg.Canary.Enabled = false
+	return nil
+}
+
+// start runs the proxy and the API the way cmd/proxy and cmd/api do and
+// returns the environment with a function stopping them
+func start(ctx context.Context, cfg *config.Config) (*env, func(), error) {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	store, err := config.NewRedisStore(&cfg.Redis)
+	if err != nil {
+		return nil, stop, err
+	}
+	stops = append(stops, func() { store.Close() })
+	if cfg.Audit.HMACKey != "" {
+		store.SetAuditKey([]byte(cfg.Audit.HMACKey))
+	}
+	if err := store.SaveConfig(ctx, cfg); err != nil {
+		return nil, stop, err
+	}
+	if err := store.SyncTablesFromConfig(ctx, cfg); err != nil {
+		return nil, stop, err
+	}
+
+	server := proxy.NewServer(cfg)
+	if err := server.WatchQueryRules(ctx, store); err != nil {
+		return nil, stop, err
+	}
+	if err := server.WatchTableToggles(ctx, store); err != nil {
+		return nil, stop, err
+	}
+	go server.Start()
+	go server.StartAdmin()
+	stops = append(stops, server.Stop)
+
+	apiServer := api.NewServer(&cfg.API, store, nil)
+	apiServer.SetFileTables(cfg.Tables)
+	apiServer.SetProxyAdmin(cfg.ProxyAdminURL(), cfg.Monitoring.MetricsPath)
+	go apiServer.Start()
+	stops = append(stops, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		apiServer.Shutdown(shutdownCtx)
+	})
+
+	proxyAddr := net.JoinHostPort(cfg.Proxy.Host, strconv.Itoa(cfg.Proxy.Port))
+	apiAddr := net.JoinHostPort(cfg.API.Host, strconv.Itoa(cfg.API.Port))
+	for _, addr := range []string{proxyAddr, apiAddr, net.JoinHostPort(cfg.Proxy.Host, strconv.Itoa(cfg.Monitoring.PrometheusPort))} {
+		if err := waitListening(addr); err != nil {
+			return nil, stop, err
+		}
+	}
+
+	db, err := sql.Open("mysql", fmt.Sprintf("root:%s@tcp(%s)/ecommerce_db?interpolateParams=true", mysqlPassword, proxyAddr))
+	if err != nil {
+		return nil, stop, err
+	}
+	stops = append(stops, func() { db.Close() })
+	if err := db.PingContext(ctx); err != nil {
+		return nil, stop, fmt.Errorf("failed to connect through the proxy: %w", err)
+	}
+
+	return &env{
+		db:     db,
+		apiURL: "http://" + apiAddr,
+		apiKey: cfg.API.APIKey,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, stop, nil
+}
+
+// waitListening waits until something accepts connections on addr
+func waitListening(addr string) error {
+	deadline := time.Now().Add(startTimeout)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("nothing listens on %s after %s: %w", addr, startTimeout, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// freePort returns a TCP port nothing listens on
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
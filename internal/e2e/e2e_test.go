@@ -0,0 +1,40 @@
+package e2e
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeploymentConfig(t *testing.T) {
+	cfg, err := config.Load("../../config.yaml")
+	require.NoError(t, err)
+	cfg.Database.Replicas = []config.ReplicaConfig{{Name: "r1", Host: "replica"}}
+
+	require.NoError(t, deploymentConfig(cfg, "localhost:32768", "localhost:32769"))
+
+	assert.Equal(t, "localhost", cfg.Database.Host)
+	assert.Equal(t, 32768, cfg.Database.Port)
+	assert.Equal(t, mysqlPassword, cfg.Database.Password)
+	assert.Empty(t, cfg.Database.Replicas)
+	assert.Equal(t, 32769, cfg.Redis.Port)
+	assert.Equal(t, "127.0.0.1", cfg.Proxy.Host)
+	assert.NotZero(t, cfg.Proxy.Port)
+	assert.Empty(t, cfg.Proxy.Socket)
+	assert.True(t, cfg.Monitoring.PrometheusEnabled)
+	assert.NotEqual(t, cfg.Proxy.Port, cfg.API.Port)
+	assert.NotEqual(t, cfg.Proxy.Port, cfg.Monitoring.PrometheusPort)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestReportFailed(t *testing.T) {
+	r := &Report{Results: []Result{
+		{Name: "a"},
+		{Name: "b", Error: "order 9999: total_amount_idn is NULL, expected 50000.0000"},
+		{Name: "c"},
+	}}
+	assert.Equal(t, 1, r.Failed())
+	assert.False(t, r.Results[1].Passed())
+}
@@ -0,0 +1,219 @@
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// applyTimeout bounds how long a change made through the API takes to reach
+// the proxy
+const applyTimeout = 10 * time.Second
+
+// errNotApplied is returned when a change made through the API does not
+// reach the proxy in time
+var errNotApplied = errors.New("the proxy did not apply the change")
+
+// scenario is one end-to-end check. Scenarios run in order against the same
+// deployment and use their own order ids.
+type scenario struct {
+	name string
+	run  func(ctx context.Context, e *env) error
+}
+
+var scenarios = []scenario{
+	{"dual-write insert", dualWriteInsert},
+	{"dual-write update", dualWriteUpdate},
+	{"transaction commit", transactionCommit},
+	{"transaction rollback", transactionRollback},
+	{"banker's rounding", bankersRounding},
+	{"api table toggle", apiTableToggle},
+	{"api sessions", apiSessions},
+}
+
+func dualWriteInsert(ctx context.Context, e *env) error {
+	if _, err := e.db.ExecContext(ctx, `INSERT INTO orders (id, customer_id, total_amount, shipping_fee)
+		VALUES (9999, 8888, 50000000, 15000)`); err != nil {
+		return fmt.Errorf("INSERT failed: %w", err)
+	}
+	if err := e.expectShadow(ctx, 9999, "total_amount_idn", "50000.0000"); err != nil {
+		return err
+	}
+	return e.expectShadow(ctx, 9999, "shipping_fee_idn", "15.0000")
+}
+
+func dualWriteUpdate(ctx context.Context, e *env) error {
+	if _, err := e.db.ExecContext(ctx, `UPDATE orders SET total_amount = 75000000 WHERE id = 9999`); err != nil {
+		return fmt.Errorf("UPDATE failed: %w", err)
+	}
+	return e.expectShadow(ctx, 9999, "total_amount_idn", "75000.0000")
+}
+
+func transactionCommit(ctx context.Context, e *env) error {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BEGIN failed: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO orders (id, customer_id, total_amount, shipping_fee)
+		VALUES (9998, 8887, 25000000, 10000)`); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("INSERT in transaction failed: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("COMMIT failed: %w", err)
+	}
+	return e.expectShadow(ctx, 9998, "total_amount_idn", "25000.0000")
+}
+
+func transactionRollback(ctx context.Context, e *env) error {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BEGIN failed: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO orders (id, customer_id, total_amount, shipping_fee)
+		VALUES (9997, 8886, 30000000, 12000)`); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("INSERT in transaction failed: %w", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		return fmt.Errorf("ROLLBACK failed: %w", err)
+	}
+
+	var count int
+	if err := e.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders WHERE id = 9997`).Scan(&count); err != nil {
+		return fmt.Errorf("SELECT failed: %w", err)
+	}
+	if count != 0 {
+		return fmt.Errorf("order 9997 persisted after ROLLBACK")
+	}
+	return nil
+}
+
+func bankersRounding(ctx context.Context, e *env) error {
+	for _, tc := range []struct {
+		id     int
+		amount int64
+		want   string
+	}{
+		{9001, 15500, "15.5000"},
+		{9002, 16500, "16.5000"},
+	} {
+		if _, err := e.db.ExecContext(ctx, `INSERT INTO orders (id, customer_id, total_amount, shipping_fee)
+			VALUES (?, 8800, ?, 10000)`, tc.id, tc.amount); err != nil {
+			return fmt.Errorf("INSERT %d failed: %w", tc.id, err)
+		}
+		if err := e.expectShadow(ctx, tc.id, "total_amount_idn", tc.want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apiTableToggle disables orders through the API, checks that new writes
+// skip the shadow columns once the proxy applied it, and enables it again
+func apiTableToggle(ctx context.Context, e *env) error {
+	if err := e.api(ctx, http.MethodPatch, "/api/v1/tables/orders/disable", nil); err != nil {
+		return err
+	}
+	if err := e.waitTableEnabled(ctx, "orders", false); err != nil {
+		return err
+	}
+	if _, err := e.db.ExecContext(ctx, `INSERT INTO orders (id, customer_id, total_amount, shipping_fee)
+		VALUES (9996, 8885, 40000000, 10000)`); err != nil {
+		return fmt.Errorf("INSERT failed: %w", err)
+	}
+	if err := e.expectShadow(ctx, 9996, "total_amount_idn", "NULL"); err != nil {
+		return err
+	}
+
+	if err := e.api(ctx, http.MethodPatch, "/api/v1/tables/orders/enable", nil); err != nil {
+		return err
+	}
+	return e.waitTableEnabled(ctx, "orders", true)
+}
+
+// apiSessions lists the proxy's sessions through the API
+func apiSessions(ctx context.Context, e *env) error {
+	var body struct {
+		Count int `json:"count"`
+	}
+	if err := e.api(ctx, http.MethodGet, "/api/v1/sessions", &body); err != nil {
+		return err
+	}
+	if body.Count == 0 {
+		return fmt.Errorf("the API lists no sessions while the smoke test is connected")
+	}
+	return nil
+}
+
+// expectShadow checks a shadow column of an order; want is "NULL" for no value
+func (e *env) expectShadow(ctx context.Context, id int, column, want string) error {
+	var got sql.NullString
+	query := fmt.Sprintf("SELECT %s FROM orders WHERE id = ?", column)
+	if err := e.db.QueryRowContext(ctx, query, id).Scan(&got); err != nil {
+		return fmt.Errorf("SELECT of order %d failed: %w", id, err)
+	}
+	value := "NULL"
+	if got.Valid {
+		value = got.String
+	}
+	if value != want {
+		return fmt.Errorf("order %d: %s is %s, expected %s", id, column, value, want)
+	}
+	return nil
+}
+
+// waitTableEnabled polls transisidb.table_configs through the proxy until
+// the table has the wanted state
+func (e *env) waitTableEnabled(ctx context.Context, table string, enabled bool) error {
+	want := 0
+	if enabled {
+		want = 1
+	}
+	deadline := time.Now().Add(applyTimeout)
+	for {
+		var got int
+		err := e.db.QueryRowContext(ctx, `SELECT enabled FROM transisidb.table_configs WHERE table_name = ? LIMIT 1`, table).Scan(&got)
+		if err == nil && got == want {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: table %s enabled=%d after %s", errNotApplied, table, want, applyTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// api sends a request to the management API and decodes the response into
+// out when it is not nil
+func (e *env) api(ctx context.Context, method, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, e.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s answered %d: %s", method, path, resp.StatusCode, body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Package errs classifies the errors of the proxy, API and backfill so callers
+// can decide whether to retry, abort or alert, and report them to clients with
+// consistent MySQL error codes and HTTP statuses.
+//
+// An error is classified by wrapping it with a Code:
+//
+//	return errs.Wrap(errs.BackendQuery, err, "failed to update row %d", id)
+//
+// Unclassified errors keep working with the helpers below, which fall back to
+// ER_UNKNOWN_ERROR, HTTP 500 and Abort.
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Category groups error codes by the component that failed
+type Category string
+
+const (
+	// ProtocolError is a malformed or unsupported MySQL packet
+	ProtocolError Category = "protocol"
+	// ParseError is a statement the SQL parser could not handle
+	ParseError Category = "parse"
+	// ConversionError is an amount that could not be converted or written to
+	// its shadow column
+	ConversionError Category = "conversion"
+	// BackendError is a failure to reach or query a MySQL backend
+	BackendError Category = "backend"
+	// ConfigError is an invalid, missing or conflicting configuration
+	ConfigError Category = "config"
+)
+
+// Action is what a caller should do about an error
+type Action string
+
+const (
+	// Retry means the same operation may succeed later
+	Retry Action = "retry"
+	// Abort means the operation cannot succeed as it is
+	Abort Action = "abort"
+	// Alert means the operation was refused to protect data and an operator
+	// should look at it
+	Alert Action = "alert"
+)
+
+// Code identifies an error condition. Codes are stable; they appear in logs
+// and API responses.
+type Code string
+
+// Error codes. MySQL conditions use the server's error code, proxy-specific
+// failures use the 50xxx range so applications can tell them from backend
+// errors.
+const (
+	ProtocolMalformed   Code = "protocol.malformed"
+	ProtocolUnsupported Code = "protocol.unsupported"
+
+	ParseSyntax   Code = "parse.syntax"
+	ParseTooLarge Code = "parse.too_large"
+
+	ConversionSuspect     Code = "conversion.suspect_amount"
+	ConversionOutOfRange  Code = "conversion.out_of_range"
+	ConversionRewrite     Code = "conversion.rewrite"
+	ConversionPlaceholder Code = "conversion.placeholder"
+
+	BackendUnavailable Code = "backend.unavailable"
+	BackendConnect     Code = "backend.connect"
+	BackendQuery       Code = "backend.query"
+
+	ConfigInvalid     Code = "config.invalid"
+	ConfigNotFound    Code = "config.not_found"
+	ConfigConflict    Code = "config.conflict"
+	ConfigUnavailable Code = "config.unavailable"
+)
+
+// spec is how an error code is handled and reported
+type spec struct {
+	category   Category
+	action     Action
+	mysqlCode  uint16
+	sqlState   string
+	httpStatus int
+}
+
+var specs = map[Code]spec{
+	ProtocolMalformed:   {ProtocolError, Abort, 1835, "08S01", http.StatusBadRequest}, // ER_MALFORMED_PACKET
+	ProtocolUnsupported: {ProtocolError, Abort, 1043, "08S01", http.StatusBadRequest}, // ER_HANDSHAKE_ERROR
+
+	ParseSyntax:   {ParseError, Abort, 1064, "42000", http.StatusBadRequest},             // ER_PARSE_ERROR
+	ParseTooLarge: {ParseError, Abort, 50100, "HY000", http.StatusRequestEntityTooLarge}, // dual-write rejected
+
+	ConversionSuspect:     {ConversionError, Alert, 50100, "HY000", http.StatusUnprocessableEntity},
+	ConversionOutOfRange:  {ConversionError, Alert, 50100, "HY000", http.StatusUnprocessableEntity},
+	ConversionRewrite:     {ConversionError, Abort, 50100, "HY000", http.StatusUnprocessableEntity},
+	ConversionPlaceholder: {ConversionError, Abort, 50100, "HY000", http.StatusUnprocessableEntity},
+
+	BackendUnavailable: {BackendError, Retry, 50001, "08S01", http.StatusServiceUnavailable}, // circuit breaker open
+	BackendConnect:     {BackendError, Retry, 50002, "08S01", http.StatusBadGateway},
+	BackendQuery:       {BackendError, Retry, 1105, "HY000", http.StatusBadGateway}, // ER_UNKNOWN_ERROR
+
+	ConfigInvalid:     {ConfigError, Abort, 1231, "42000", http.StatusBadRequest}, // ER_WRONG_VALUE_FOR_VAR
+	ConfigNotFound:    {ConfigError, Abort, 1146, "42S02", http.StatusNotFound},   // ER_NO_SUCH_TABLE
+	ConfigConflict:    {ConfigError, Abort, 50300, "HY000", http.StatusConflict},
+	ConfigUnavailable: {ConfigError, Retry, 50300, "HY000", http.StatusServiceUnavailable},
+}
+
+// unknown reports unclassified errors
+var unknown = spec{"", Abort, 1105, "HY000", http.StatusInternalServerError}
+
+func (c Code) spec() spec {
+	if s, ok := specs[c]; ok {
+		return s
+	}
+	return unknown
+}
+
+// Category returns the category of the code, empty for an unknown code
+func (c Code) Category() Category { return c.spec().category }
+
+// Action returns what a caller should do about an error with the code
+func (c Code) Action() Action { return c.spec().action }
+
+// MySQL returns the MySQL error code and SQLSTATE an error with the code is
+// reported with
+func (c Code) MySQL() (uint16, string) {
+	s := c.spec()
+	return s.mysqlCode, s.sqlState
+}
+
+// HTTPStatus returns the HTTP status an error with the code is reported with
+func (c Code) HTTPStatus() int { return c.spec().httpStatus }
+
+// Error is a classified error
+type Error struct {
+	Code    Code
+	Message string
+	// Err is the underlying error, nil for errors created with New
+	Err error
+}
+
+// New returns a classified error with a formatted message
+func New(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap classifies err, adding a formatted message. An empty format keeps the
+// message of err.
+func Wrap(code Code, err error, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	default:
+		return e.Message + ": " + e.Err.Error()
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// CodeOf returns the code of the outermost classified error in err's chain,
+// empty when err is not classified
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
+
+// Is reports whether err is classified in category
+func Is(err error, category Category) bool {
+	code := CodeOf(err)
+	return code != "" && code.Category() == category
+}
+
+// ActionOf returns what a caller should do about err; unclassified errors
+// are aborted
+func ActionOf(err error) Action {
+	return CodeOf(err).Action()
+}
+
+// MySQL returns the MySQL error code and SQLSTATE to report err with
+func MySQL(err error) (uint16, string) {
+	return CodeOf(err).MySQL()
+}
+
+// HTTPStatus returns the HTTP status to report err with, 500 for
+// unclassified errors
+func HTTPStatus(err error) int {
+	return CodeOf(err).HTTPStatus()
+}
@@ -0,0 +1,72 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	cause := errors.New("connection reset")
+
+	tests := []struct {
+		name    string
+		err     *Error
+		message string
+	}{
+		{"new", New(ConfigInvalid, "batch_size %d out of range", 0), "batch_size 0 out of range"},
+		{"wrap", Wrap(BackendQuery, cause, "failed to update row %d", 7), "failed to update row 7: connection reset"},
+		{"wrap without message", Wrap(BackendQuery, cause, ""), "connection reset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.message, tt.err.Error())
+			assert.Equal(t, tt.err.Err != nil, errors.Is(tt.err, cause))
+		})
+	}
+}
+
+func TestClassification(t *testing.T) {
+	sentinel := New(ConfigNotFound, "table config not found")
+	wrapped := fmt.Errorf("restore orders: %w", sentinel)
+
+	assert.Equal(t, ConfigNotFound, CodeOf(wrapped))
+	assert.True(t, errors.Is(wrapped, sentinel))
+	assert.True(t, Is(wrapped, ConfigError))
+	assert.False(t, Is(wrapped, BackendError))
+	assert.Equal(t, Abort, ActionOf(wrapped))
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(wrapped))
+
+	// The outermost classification wins
+	retried := Wrap(BackendQuery, New(ParseSyntax, "near 'FORM'"), "replay failed")
+	assert.Equal(t, BackendQuery, CodeOf(retried))
+	assert.Equal(t, Retry, ActionOf(retried))
+}
+
+func TestUnclassified(t *testing.T) {
+	err := errors.New("boom")
+
+	assert.Equal(t, Code(""), CodeOf(err))
+	assert.False(t, Is(err, ConfigError))
+	assert.Equal(t, Abort, ActionOf(err))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(err))
+	code, sqlState := MySQL(err)
+	assert.Equal(t, uint16(1105), code)
+	assert.Equal(t, "HY000", sqlState)
+}
+
+func TestCodesAreMapped(t *testing.T) {
+	for code, s := range specs {
+		t.Run(string(code), func(t *testing.T) {
+			assert.NotEmpty(t, s.category)
+			assert.NotEmpty(t, s.action)
+			assert.NotZero(t, s.mysqlCode)
+			assert.Len(t, s.sqlState, 5)
+			assert.GreaterOrEqual(t, s.httpStatus, 400)
+		})
+	}
+}
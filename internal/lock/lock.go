@@ -0,0 +1,120 @@
+// Package lock grants exclusive operations, such as backfilling or
+// onboarding a table, to one process across hosts. Locks expire after a TTL
+// unless their holder extends them, so a crashed holder cannot keep a lock.
+// Every acquisition gets a fencing token larger than all earlier tokens of
+// the same lock, so a holder that lost its lock can be told apart from the
+// current one.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+// DefaultTTL is how long a lock outlives a holder that stopped extending it
+const DefaultTTL = 30 * time.Second
+
+var (
+	// ErrHeld is returned when another owner holds the lock
+	ErrHeld = errors.New("lock is held")
+	// ErrNotHeld is returned when extending or releasing a lease that expired
+	// or was taken over
+	ErrNotHeld = errors.New("lock is not held")
+)
+
+// Lease is a granted lock
+type Lease struct {
+	Name       string    `json:"name"`
+	Owner      string    `json:"owner"`
+	Token      int64     `json:"token"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Locker grants locks by name
+type Locker interface {
+	// Acquire grants the lock to owner for ttl, or returns ErrHeld
+	Acquire(ctx context.Context, name, owner string, ttl time.Duration) (Lease, error)
+	// Extend keeps a lease for another ttl, or returns ErrNotHeld
+	Extend(ctx context.Context, lease Lease, ttl time.Duration) (Lease, error)
+	// Release gives up a lease; releasing a lease no longer held returns
+	// ErrNotHeld and leaves the current holder's lock alone
+	Release(ctx context.Context, lease Lease) error
+	// List returns the held locks by name
+	List(ctx context.Context) ([]Lease, error)
+}
+
+// Table is the lock of operations that rewrite a table's rows or schema
+func Table(table string) string {
+	return "table:" + table
+}
+
+// Owner names this process as a lock owner
+func Owner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Hold acquires a lock and runs fn while holding it, see Keep
+func Hold(ctx context.Context, locker Locker, name, owner string, ttl time.Duration,
+	fn func(ctx context.Context, lease Lease) error) error {
+
+	lease, err := locker.Acquire(ctx, name, owner, ttl)
+	if err != nil {
+		return err
+	}
+	return Keep(ctx, locker, lease, ttl, fn)
+}
+
+// Keep runs fn, extends the lease every third of ttl while fn runs and
+// releases it when fn returns. fn's context is cancelled when the lease
+// cannot be extended, since another owner may hold the lock by then.
+func Keep(ctx context.Context, locker Locker, lease Lease, ttl time.Duration,
+	fn func(ctx context.Context, lease Lease) error) error {
+
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		current := lease
+		for {
+			select {
+			case <-fnCtx.Done():
+				return
+			case <-ticker.C:
+				extended, err := locker.Extend(fnCtx, current, ttl)
+				if errors.Is(err, ErrNotHeld) {
+					logger.Error("Lost lock, stopping", "lock", lease.Name, "token", lease.Token)
+					cancel()
+					return
+				}
+				if err != nil {
+					// Retried on the next tick, before the lease expires
+					logger.Warn("Failed to extend lock", "lock", lease.Name, "error", err)
+					continue
+				}
+				current = extended
+			}
+		}
+	}()
+
+	err := fn(fnCtx, lease)
+	cancel()
+	<-done
+
+	releaseCtx, releaseCancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer releaseCancel()
+	if err := locker.Release(releaseCtx, lease); err != nil {
+		logger.Warn("Failed to release lock", "lock", lease.Name, "token", lease.Token, "error", err)
+	}
+	return err
+}
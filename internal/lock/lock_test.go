@@ -0,0 +1,135 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLocker checks the semantics shared by all implementations
+func testLocker(t *testing.T, locker Locker, name string) {
+	ctx := context.Background()
+
+	first, err := locker.Acquire(ctx, name, "host-a", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "host-a", first.Owner)
+	assert.Positive(t, first.Token)
+
+	_, err = locker.Acquire(ctx, name, "host-b", time.Second)
+	assert.ErrorIs(t, err, ErrHeld)
+	assert.ErrorContains(t, err, "by host-a")
+
+	leases, err := locker.List(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, leaseNames(leases), name)
+
+	extended, err := locker.Extend(ctx, first, 2*time.Second)
+	require.NoError(t, err)
+	assert.True(t, extended.ExpiresAt.After(first.ExpiresAt))
+	require.NoError(t, locker.Release(ctx, first))
+
+	// Tokens keep growing across holders; a stale lease cannot touch the lock
+	second, err := locker.Acquire(ctx, name, "host-b", time.Second)
+	require.NoError(t, err)
+	assert.Greater(t, second.Token, first.Token)
+	_, err = locker.Extend(ctx, first, time.Second)
+	assert.ErrorIs(t, err, ErrNotHeld)
+	assert.ErrorIs(t, locker.Release(ctx, first), ErrNotHeld)
+	require.NoError(t, locker.Release(ctx, second))
+
+	leases, err = locker.List(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, leaseNames(leases), name)
+}
+
+func leaseNames(leases []Lease) []string {
+	names := make([]string, 0, len(leases))
+	for _, lease := range leases {
+		names = append(names, lease.Name)
+	}
+	return names
+}
+
+func TestMemory(t *testing.T) {
+	testLocker(t, NewMemory(), Table("orders"))
+}
+
+func TestMemory_Expiry(t *testing.T) {
+	locker := NewMemory()
+	now := time.Now()
+	locker.now = func() time.Time { return now }
+
+	crashed, err := locker.Acquire(context.Background(), "table:orders", "host-a", time.Minute)
+	require.NoError(t, err)
+
+	// A holder that stopped extending loses the lock after the TTL
+	now = now.Add(time.Minute)
+	lease, err := locker.Acquire(context.Background(), "table:orders", "host-b", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, crashed.Token+1, lease.Token)
+}
+
+func TestHold(t *testing.T) {
+	locker := NewMemory()
+	ctx := context.Background()
+
+	err := Hold(ctx, locker, "table:orders", "host-a", 30*time.Millisecond, func(ctx context.Context, lease Lease) error {
+		// Extended past its TTL while fn runs
+		time.Sleep(60 * time.Millisecond)
+		_, err := locker.Acquire(ctx, "table:orders", "host-b", time.Second)
+		assert.ErrorIs(t, err, ErrHeld)
+		return nil
+	})
+	require.NoError(t, err)
+
+	leases, err := locker.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, leases, "the lock is released when fn returns")
+}
+
+func TestHold_CancelsWhenLockIsLost(t *testing.T) {
+	locker := NewMemory()
+	lease, err := locker.Acquire(context.Background(), "table:orders", "host-a", 30*time.Millisecond)
+	require.NoError(t, err)
+
+	err = Keep(context.Background(), locker, lease, 30*time.Millisecond, func(ctx context.Context, lease Lease) error {
+		// Taken over, e.g. after a pause longer than the TTL
+		require.NoError(t, locker.Release(ctx, lease))
+		_, err := locker.Acquire(ctx, "table:orders", "host-b", time.Minute)
+		require.NoError(t, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	leases, err := locker.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, leases, 1)
+	assert.Equal(t, "host-b", leases[0].Owner, "the new holder keeps its lock")
+}
+
+func TestRedis(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 15})
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis not available, skipping test: %v", err)
+	}
+
+	testLocker(t, NewRedis(client), fmt.Sprintf("test:%d", time.Now().UnixNano()))
+}
@@ -0,0 +1,88 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory grants locks within one process, for tests and setups without Redis
+type Memory struct {
+	mu     sync.Mutex
+	leases map[string]Lease
+	tokens map[string]int64
+	now    func() time.Time
+}
+
+// NewMemory returns a locker without locks
+func NewMemory() *Memory {
+	return &Memory{leases: make(map[string]Lease), tokens: make(map[string]int64), now: time.Now}
+}
+
+// Acquire grants the lock unless an unexpired lease holds it
+func (m *Memory) Acquire(ctx context.Context, name, owner string, ttl time.Duration) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if held, ok := m.leases[name]; ok && now.Before(held.ExpiresAt) {
+		return Lease{}, fmt.Errorf("%w: %s by %s", ErrHeld, name, held.Owner)
+	}
+	m.tokens[name]++
+	lease := Lease{Name: name, Owner: owner, Token: m.tokens[name], AcquiredAt: now, ExpiresAt: now.Add(ttl)}
+	m.leases[name] = lease
+	return lease, nil
+}
+
+// Extend moves the expiry of a lease that still holds the lock
+func (m *Memory) Extend(ctx context.Context, lease Lease, ttl time.Duration) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	held, ok := m.held(lease)
+	if !ok {
+		return Lease{}, fmt.Errorf("%w: %s", ErrNotHeld, lease.Name)
+	}
+	held.ExpiresAt = m.now().Add(ttl)
+	m.leases[lease.Name] = held
+	return held, nil
+}
+
+// Release removes a lease that still holds the lock
+func (m *Memory) Release(ctx context.Context, lease Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.held(lease); !ok {
+		return fmt.Errorf("%w: %s", ErrNotHeld, lease.Name)
+	}
+	delete(m.leases, lease.Name)
+	return nil
+}
+
+// List returns the unexpired leases by name
+func (m *Memory) List(ctx context.Context) ([]Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	leases := make([]Lease, 0, len(m.leases))
+	for _, lease := range m.leases {
+		if now.Before(lease.ExpiresAt) {
+			leases = append(leases, lease)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].Name < leases[j].Name })
+	return leases, nil
+}
+
+// held returns the current lease if it is unexpired and matches lease
+func (m *Memory) held(lease Lease) (Lease, bool) {
+	held, ok := m.leases[lease.Name]
+	if !ok || !m.now().Before(held.ExpiresAt) || held.Owner != lease.Owner || held.Token != lease.Token {
+		return Lease{}, false
+	}
+	return held, true
+}
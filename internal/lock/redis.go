@@ -0,0 +1,167 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix is the prefix of the locks' Redis keys
+const KeyPrefix = "transisidb:locks"
+
+// acquireScript grants the lock when its key does not exist: the fencing
+// token comes from a counter that outlives the lock, and the name is indexed
+// for List. Returns the token, or 0 and the current owner.
+var acquireScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return {0, redis.call('HGET', KEYS[1], 'owner')}
+end
+local token = redis.call('HINCRBY', KEYS[2], ARGV[1], 1)
+redis.call('HSET', KEYS[1], 'owner', ARGV[2], 'token', token, 'acquired_at', ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+redis.call('SADD', KEYS[3], ARGV[1])
+return {token, ARGV[2]}
+`)
+
+// extendScript resets the expiry if owner and token still hold the lock
+var extendScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'owner') == ARGV[1] and redis.call('HGET', KEYS[1], 'token') == ARGV[2] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return 0
+`)
+
+// releaseScript deletes the lock if owner and token still hold it
+var releaseScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'owner') == ARGV[1] and redis.call('HGET', KEYS[1], 'token') == ARGV[2] then
+	redis.call('SREM', KEYS[2], ARGV[3])
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Redis keeps locks in Redis, shared by all hosts. Each lock is a hash with
+// a TTL; fencing tokens are counted per name in a separate hash.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string // KeyPrefix, hash-tagged on a cluster
+}
+
+// NewRedis returns a locker using client
+func NewRedis(client redis.UniversalClient) *Redis {
+	return &Redis{client: client, prefix: config.RedisKeyPrefix(client, KeyPrefix)}
+}
+
+func (r *Redis) lockKey(name string) string {
+	return r.prefix + ":held:" + name
+}
+
+func (r *Redis) tokensKey() string {
+	return r.prefix + ":tokens"
+}
+
+func (r *Redis) namesKey() string {
+	return r.prefix + ":names"
+}
+
+// Acquire grants the lock unless another lease holds it
+func (r *Redis) Acquire(ctx context.Context, name, owner string, ttl time.Duration) (Lease, error) {
+	now := time.Now()
+	result, err := acquireScript.Run(ctx, r.client,
+		[]string{r.lockKey(name), r.tokensKey(), r.namesKey()},
+		name, owner, ttl.Milliseconds(), now.UnixMilli()).Slice()
+	if err != nil {
+		return Lease{}, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	token, _ := result[0].(int64)
+	if token == 0 {
+		holder, _ := result[1].(string)
+		return Lease{}, fmt.Errorf("%w: %s by %s", ErrHeld, name, holder)
+	}
+	return Lease{Name: name, Owner: owner, Token: token, AcquiredAt: now, ExpiresAt: now.Add(ttl)}, nil
+}
+
+// Extend resets the TTL of a lease that still holds the lock
+func (r *Redis) Extend(ctx context.Context, lease Lease, ttl time.Duration) (Lease, error) {
+	extended, err := extendScript.Run(ctx, r.client, []string{r.lockKey(lease.Name)},
+		lease.Owner, lease.Token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return Lease{}, fmt.Errorf("failed to extend lock %s: %w", lease.Name, err)
+	}
+	if extended == 0 {
+		return Lease{}, fmt.Errorf("%w: %s", ErrNotHeld, lease.Name)
+	}
+	lease.ExpiresAt = time.Now().Add(ttl)
+	return lease, nil
+}
+
+// Release deletes a lease that still holds the lock
+func (r *Redis) Release(ctx context.Context, lease Lease) error {
+	released, err := releaseScript.Run(ctx, r.client, []string{r.lockKey(lease.Name), r.namesKey()},
+		lease.Owner, lease.Token, lease.Name).Int()
+	if err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", lease.Name, err)
+	}
+	if released == 0 {
+		return fmt.Errorf("%w: %s", ErrNotHeld, lease.Name)
+	}
+	return nil
+}
+
+// List returns the held locks by name; names of expired locks are dropped
+// from the index
+func (r *Redis) List(ctx context.Context) ([]Lease, error) {
+	names, err := r.client.SMembers(ctx, r.namesKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locks: %w", err)
+	}
+	sort.Strings(names)
+
+	leases := make([]Lease, 0, len(names))
+	for _, name := range names {
+		lease, err := r.load(ctx, name)
+		if errors.Is(err, ErrNotHeld) {
+			r.client.SRem(ctx, r.namesKey(), name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		leases = append(leases, lease)
+	}
+	return leases, nil
+}
+
+// load reads a held lock
+func (r *Redis) load(ctx context.Context, name string) (Lease, error) {
+	var fields *redis.MapStringStringCmd
+	var ttl *redis.DurationCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		fields = pipe.HGetAll(ctx, r.lockKey(name))
+		ttl = pipe.PTTL(ctx, r.lockKey(name))
+		return nil
+	})
+	if err != nil {
+		return Lease{}, fmt.Errorf("failed to load lock %s: %w", name, err)
+	}
+	values := fields.Val()
+	if len(values) == 0 || ttl.Val() <= 0 {
+		return Lease{}, fmt.Errorf("%w: %s", ErrNotHeld, name)
+	}
+
+	token, _ := strconv.ParseInt(values["token"], 10, 64)
+	acquiredAt, _ := strconv.ParseInt(values["acquired_at"], 10, 64)
+	return Lease{
+		Name:       name,
+		Owner:      values["owner"],
+		Token:      token,
+		AcquiredAt: time.UnixMilli(acquiredAt),
+		ExpiresAt:  time.Now().Add(ttl.Val()),
+	}, nil
+}
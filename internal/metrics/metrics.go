@@ -1,10 +1,20 @@
 package metrics
 
 import (
+	"math"
+	"strconv"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// amountBuckets covers IDN amounts from 0.001 to 10^12, one bucket per digit
+var amountBuckets = prometheus.ExponentialBuckets(0.001, 10, 16)
+
+// latencyBuckets covers 50µs to ~1.6s, fine-grained enough to show sub-millisecond proxy overhead
+var latencyBuckets = prometheus.ExponentialBuckets(0.00005, 2, 16)
+
 // Prometheus metrics for TransisiDB
 var (
 	// DualWriteTotal counts total dual-write operations
@@ -43,6 +53,138 @@ var (
 		},
 	)
 
+	// PoolAcquireDuration tracks how long sessions wait for a backend connection
+	PoolAcquireDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "transisidb_pool_acquire_duration_seconds",
+			Help:    "Time to check out a backend connection, including health checks and dialing",
+			Buckets: latencyBuckets,
+		},
+		[]string{"backend"},
+	)
+
+	// PoolConnections tracks pooled backend connections by state
+	PoolConnections = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "transisidb_pool_connections",
+			Help: "Number of backend connections idle in the pool or checked out by sessions",
+		},
+		[]string{"backend", "state"}, // state: idle, active
+	)
+
+	// CircuitBreakerState tracks the circuit breaker of each backend pool
+	CircuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "transisidb_circuit_breaker_state",
+			Help: "Circuit breaker state of each backend pool: 0 closed, 1 open, 2 half-open",
+		},
+		[]string{"backend"},
+	)
+
+	// PoolWaiting tracks sessions waiting in Acquire
+	PoolWaiting = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "transisidb_pool_waiting",
+			Help: "Number of sessions waiting for a backend connection",
+		},
+		[]string{"backend"},
+	)
+
+	// PoolCreateFailures counts backend connections the pool failed to create
+	PoolCreateFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_pool_create_failures_total",
+			Help: "Total number of failed backend connection attempts",
+		},
+		[]string{"backend", "reason"}, // reason: circuit_breaker, dial
+	)
+
+	// BytesBuffered tracks response bytes held by the proxy while being written to clients
+	BytesBuffered = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "transisidb_bytes_buffered",
+			Help: "Number of response bytes held in the proxy while being written to clients",
+		},
+	)
+
+	// SessionGoroutines tracks the goroutines serving client connections
+	SessionGoroutines = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "transisidb_session_goroutines",
+			Help: "Number of goroutines serving client connections, from accept until the connection is closed",
+		},
+	)
+
+	// ClientConnections counts client connections by listener and what was
+	// done with them
+	ClientConnections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_client_connections_total",
+			Help: "Total number of client connections accepted or rejected, by listener",
+		},
+		[]string{"listener", "result"}, // result: accepted, rate_limited, backlog_full, too_many
+	)
+
+	// ActiveClientConnections tracks open client connections by listener
+	ActiveClientConnections = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "transisidb_client_connections_active",
+			Help: "Number of open client connections, by listener",
+		},
+		[]string{"listener"},
+	)
+
+	// PendingHandshakes tracks accepted connections still in the handshake
+	PendingHandshakes = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "transisidb_client_handshakes_pending",
+			Help: "Number of accepted client connections that have not completed the handshake",
+		},
+	)
+
+	// AcceptErrors counts failed accepts by listener
+	AcceptErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_accept_errors_total",
+			Help: "Total number of errors accepting client connections, by listener",
+		},
+		[]string{"listener"},
+	)
+
+	// SessionPanics counts sessions closed after a panic was recovered
+	SessionPanics = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "transisidb_session_panics_total",
+			Help: "Total number of client sessions closed because handling them panicked",
+		},
+	)
+
+	// IdleSessionsClosed counts sessions closed after proxy.idle_timeout
+	IdleSessionsClosed = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "transisidb_idle_sessions_closed_total",
+			Help: "Total number of client sessions closed for exceeding the idle timeout",
+		},
+	)
+
+	// ClientSessions tracks open client sessions per MySQL user
+	ClientSessions = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "transisidb_client_sessions_active",
+			Help: "Number of open client sessions by MySQL user",
+		},
+		[]string{"user"},
+	)
+
+	// ClientQueries counts statements received per MySQL user
+	ClientQueries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_client_queries_total",
+			Help: "Total number of statements received by MySQL user and statement type",
+		},
+		[]string{"user", "statement"},
+	)
+
 	// ErrorsTotal counts errors by type
 	ErrorsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -70,6 +212,299 @@ var (
 		[]string{"table"},
 	)
 
+	// QueryParseDuration tracks time spent parsing statements in the proxy
+	QueryParseDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "transisidb_query_parse_duration_seconds",
+			Help:    "Time spent parsing statements in the proxy",
+			Buckets: latencyBuckets,
+		},
+		[]string{"statement"}, // labels: SELECT, INSERT, UPDATE, DELETE, UNKNOWN
+	)
+
+	// QueryRewriteDuration tracks time spent converting values and rewriting statements
+	QueryRewriteDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "transisidb_query_rewrite_duration_seconds",
+			Help:    "Time spent converting values and rewriting statements in the proxy",
+			Buckets: latencyBuckets,
+		},
+		[]string{"statement"},
+	)
+
+	// QueryBackendDuration tracks backend round-trip time (command sent to first response packet)
+	QueryBackendDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "transisidb_query_backend_duration_seconds",
+			Help:    "Backend round-trip time from forwarding a command to its first response packet",
+			Buckets: latencyBuckets,
+		},
+		[]string{"statement"},
+	)
+
+	// QueryStreamDuration tracks time spent relaying the response to the client
+	QueryStreamDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "transisidb_query_stream_duration_seconds",
+			Help:    "Time spent relaying the backend response to the client",
+			Buckets: latencyBuckets,
+		},
+		[]string{"statement"},
+	)
+
+	// CommandDuration tracks backend round-trip plus relay time for non-query
+	// commands (COM_PING, COM_INIT_DB, ...), kept apart from SQL statement types
+	CommandDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "transisidb_command_duration_seconds",
+			Help:    "Time spent forwarding non-query protocol commands and relaying their response",
+			Buckets: latencyBuckets,
+		},
+		[]string{"command"},
+	)
+
+	// RewriteFailures counts statements on configured tables that could not be dual-written
+	RewriteFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_rewrite_failures_total",
+			Help: "Total number of statements on configured tables that could not be parsed, converted or rewritten",
+		},
+		[]string{"table", "stage", "policy"}, // stage: parse, size, convert, rewrite
+	)
+
+	// UnparsedStatements counts statements above max_parse_size that skipped the parser
+	UnparsedStatements = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_unparsed_statements_total",
+			Help: "Total number of statements above max_parse_size forwarded without parsing, by the configured table they write",
+		},
+		[]string{"table"}, // empty when no configured table is written
+	)
+
+	// RolloutStatements counts statements on tables being rolled out by whether they were dual-written
+	RolloutStatements = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_rollout_statements_total",
+			Help: "Total number of statements on tables being rolled out, by whether they were dual-written",
+		},
+		[]string{"table", "decision"}, // decision: rewritten, skipped
+	)
+
+	// SuspectAmounts counts amounts that look already converted
+	SuspectAmounts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_suspect_amounts_total",
+			Help: "Total number of source amounts that look already converted, by where they were seen, why and what was done",
+		},
+		[]string{"source", "table", "column", "reason", "action"}, // source: proxy, backfill, outbox; action: flagged, rejected
+	)
+
+	// OutOfRangeAmounts counts amounts refused for falling outside their column's bounds
+	OutOfRangeAmounts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_out_of_range_amounts_total",
+			Help: "Total number of amounts refused for falling outside their column's bounds or overflowing its shadow type",
+		},
+		[]string{"source", "table", "column", "bound"}, // bound: min, max, target_type
+	)
+
+	// PlaceholderStatements counts statements carrying a bind placeholder
+	// where an amount belongs
+	PlaceholderStatements = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_placeholder_statements_total",
+			Help: "Total number of text protocol statements with a bind placeholder in place of an amount, by what was done",
+		},
+		[]string{"table", "action"}, // action: rejected, flagged
+	)
+
+	// ConvertedAmounts tracks the magnitude of converted amounts per column
+	ConvertedAmounts = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "transisidb_converted_amounts",
+			Help:    "Absolute IDN amounts converted for shadow columns",
+			Buckets: amountBuckets,
+		},
+		[]string{"table", "column"},
+	)
+
+	// ObservedStatements counts statements on tables in observe mode by the rewrite they would have had
+	ObservedStatements = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_observed_statements_total",
+			Help: "Total number of statements on tables in observe mode, forwarded untouched, by the rewrite they would have had",
+		},
+		[]string{"table", "outcome"}, // outcome: would_rewrite, would_fail
+	)
+
+	// CanaryComparisons counts statements replayed against the canary by outcome
+	CanaryComparisons = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_canary_comparisons_total",
+			Help: "Total number of rewritten statements replayed against the canary, by outcome",
+		},
+		[]string{"table", "outcome"}, // outcome: match, rows_mismatch, error_mismatch, unavailable, dropped
+	)
+
+	// OutboxTasks counts shadow column updates of async tables by outcome
+	OutboxTasks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_outbox_tasks_total",
+			Help: "Total number of outbox tasks of async tables, by outcome",
+		},
+		[]string{"table", "outcome"}, // outcome: enqueued, enqueue_failed, applied, failed
+	)
+
+	// OutboxLag measures the time from enqueueing a task to applying it
+	OutboxLag = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "transisidb_outbox_lag_seconds",
+			Help:    "Time from forwarding a statement of an async table to writing its shadow columns",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 20), // 1ms to ~9m
+		},
+		[]string{"table"},
+	)
+
+	// OutboxPending is the number of outbox tasks not yet applied
+	OutboxPending = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "transisidb_outbox_pending",
+			Help: "Number of outbox tasks enqueued or delivered but not yet applied",
+		},
+	)
+
+	// JobRuns counts scheduled job runs by outcome
+	JobRuns = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_job_runs_total",
+			Help: "Total number of scheduled job runs, by outcome",
+		},
+		[]string{"job", "outcome"}, // outcome: success, failed
+	)
+
+	// JobLastSuccess is the time of each job's last successful run
+	JobLastSuccess = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "transisidb_job_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful run of each scheduled job",
+		},
+		[]string{"job"},
+	)
+
+	// TransactionRollbacks counts transactions rolled back by the proxy after a dual-write failure
+	TransactionRollbacks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_dualwrite_tx_rollbacks_total",
+			Help: "Total number of transactions rolled back because a statement could not be dual-written",
+		},
+		[]string{"table"},
+	)
+
+	// ParserFailures counts statements sqlparser could not parse
+	ParserFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_parser_failures_total",
+			Help: "Total number of statements the SQL parser could not parse, by failure reason",
+		},
+		[]string{"reason"}, // empty, syntax_error, unsupported, other
+	)
+
+	// QueryRuleHits counts statements matched by query rules
+	QueryRuleHits = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_query_rule_hits_total",
+			Help: "Total number of statements matched by query rules",
+		},
+		[]string{"rule_id", "action"},
+	)
+
+	// SchemaChanges counts DDL statements the proxy observed, by kind
+	SchemaChanges = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_schema_changes_total",
+			Help: "Total number of DDL statements accepted by the backend, by kind",
+		},
+		[]string{"kind"}, // create, alter, drop, rename
+	)
+
+	// UnconfiguredCurrencyColumns counts columns added by DDL that look like
+	// currency amounts but have no shadow column config
+	UnconfiguredCurrencyColumns = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_unconfigured_currency_columns_total",
+			Help: "Total number of columns added by DDL that look like currency amounts but are not configured",
+		},
+		[]string{"table"},
+	)
+
+	// ResultLimitsExceeded counts resultsets terminated by a proxy limit
+	ResultLimitsExceeded = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_result_limits_exceeded_total",
+			Help: "Total number of resultsets terminated because they exceeded a configured limit",
+		},
+		[]string{"limit"},
+	)
+
+	// PrimaryFailovers counts switches of new sessions to a standby primary
+	PrimaryFailovers = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_primary_failovers_total",
+			Help: "Total number of failovers to a new primary backend",
+		},
+		[]string{"backend"},
+	)
+
+	// ProbeLatency is the end-to-end latency of the last SELECT 1 probe
+	ProbeLatency = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "transisidb_probe_latency_seconds",
+			Help: "End-to-end latency of the last SELECT 1 probe through the proxy",
+		},
+	)
+
+	// ProbeFailures counts SELECT 1 probes that failed or timed out
+	ProbeFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "transisidb_probe_failures_total",
+			Help: "Total number of SELECT 1 probes through the proxy that failed",
+		},
+	)
+
+	// ConfigWatcherUp reports whether the Redis config watcher is subscribed
+	ConfigWatcherUp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "transisidb_config_watcher_up",
+			Help: "Whether the config watcher is subscribed to Redis reload notifications (1) or not (0)",
+		},
+	)
+
+	// BreakGlassActive reports whether the proxy forwards every statement
+	// without parsing or rewriting
+	BreakGlassActive = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "transisidb_break_glass_active",
+			Help: "Whether break glass pass-through is active (1) or not (0)",
+		},
+	)
+
+	// BreakGlassStatements counts statements forwarded untouched by break glass
+	BreakGlassStatements = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "transisidb_break_glass_statements_total",
+			Help: "Total number of statements forwarded without parsing or rewriting while break glass was active",
+		},
+	)
+
+	// ConfigVersionSessions counts new sessions by the config version they use
+	ConfigVersionSessions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "transisidb_config_version_sessions_total",
+			Help: "Total number of sessions created on the active or the staged config version",
+		},
+		[]string{"version"},
+	)
+
 	// BackfillErrors counts backfill errors
 	BackfillErrors = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -116,6 +551,57 @@ func SetConnectionPoolActive(count int) {
 	ConnectionPoolActive.Set(float64(count))
 }
 
+// AddBytesBuffered adjusts the buffered response bytes gauge
+func AddBytesBuffered(delta int) {
+	BytesBuffered.Add(float64(delta))
+}
+
+// AddClientSession adjusts the open session count for a user (delta +1 or -1)
+func AddClientSession(user string, delta int) {
+	ClientSessions.WithLabelValues(user).Add(float64(delta))
+}
+
+// RecordClientConnection records what was done with an accepted client
+// connection
+func RecordClientConnection(listener, result string) {
+	ClientConnections.WithLabelValues(listener, result).Inc()
+}
+
+// AddActiveClientConnection adjusts the number of open client connections
+func AddActiveClientConnection(listener string, delta int) {
+	ActiveClientConnections.WithLabelValues(listener).Add(float64(delta))
+}
+
+// AddPendingHandshake adjusts the number of connections in the handshake
+func AddPendingHandshake(delta int) {
+	PendingHandshakes.Add(float64(delta))
+}
+
+// RecordAcceptError records a failed accept
+func RecordAcceptError(listener string) {
+	AcceptErrors.WithLabelValues(listener).Inc()
+}
+
+// AddSessionGoroutine adjusts the number of goroutines serving client connections
+func AddSessionGoroutine(delta int) {
+	SessionGoroutines.Add(float64(delta))
+}
+
+// RecordSessionPanic records a session closed after a recovered panic
+func RecordSessionPanic() {
+	SessionPanics.Inc()
+}
+
+// RecordIdleSessionClosed records a session closed after the idle timeout
+func RecordIdleSessionClosed() {
+	IdleSessionsClosed.Inc()
+}
+
+// RecordClientQuery records a statement received from a user
+func RecordClientQuery(user, statement string) {
+	ClientQueries.WithLabelValues(user, statement).Inc()
+}
+
 // RecordError records an error by type
 func RecordError(errorType string) {
 	ErrorsTotal.WithLabelValues(errorType).Inc()
@@ -125,3 +611,197 @@ func RecordError(errorType string) {
 func RecordAPIRequest(endpoint, method, status string) {
 	APIRequestsTotal.WithLabelValues(endpoint, method, status).Inc()
 }
+
+// RecordQueryPhases records the per-phase latency breakdown of a proxied statement
+func RecordQueryPhases(statement string, parse, rewrite, backend, stream time.Duration) {
+	QueryParseDuration.WithLabelValues(statement).Observe(parse.Seconds())
+	QueryRewriteDuration.WithLabelValues(statement).Observe(rewrite.Seconds())
+	QueryBackendDuration.WithLabelValues(statement).Observe(backend.Seconds())
+	QueryStreamDuration.WithLabelValues(statement).Observe(stream.Seconds())
+}
+
+// RecordCommandDuration records the latency of a forwarded protocol command
+func RecordCommandDuration(command string, duration time.Duration) {
+	CommandDuration.WithLabelValues(command).Observe(duration.Seconds())
+}
+
+// RecordQueryRuleHit increments the hit counter for a query rule
+func RecordQueryRuleHit(ruleID int, action string) {
+	QueryRuleHits.WithLabelValues(strconv.Itoa(ruleID), action).Inc()
+}
+
+// RecordRewriteFailure records a dual-write rewrite failure and the policy applied
+func RecordRewriteFailure(table, stage, policy string) {
+	RewriteFailures.WithLabelValues(table, stage, policy).Inc()
+}
+
+// RecordRolloutDecision records whether a statement on a table being rolled
+// out was dual-written
+func RecordRolloutDecision(table string, rewritten bool) {
+	decision := "skipped"
+	if rewritten {
+		decision = "rewritten"
+	}
+	RolloutStatements.WithLabelValues(table, decision).Inc()
+}
+
+// RecordSuspectAmount records an amount that looks already converted
+func RecordSuspectAmount(source, table, column, reason, action string) {
+	SuspectAmounts.WithLabelValues(source, table, column, reason, action).Inc()
+}
+
+// RecordOutOfRangeAmount records an amount refused for falling outside a bound
+func RecordOutOfRangeAmount(source, table, column, bound string) {
+	OutOfRangeAmounts.WithLabelValues(source, table, column, bound).Inc()
+}
+
+// RecordPlaceholderStatement records a statement with a placeholder amount
+func RecordPlaceholderStatement(table, action string) {
+	PlaceholderStatements.WithLabelValues(table, action).Inc()
+}
+
+// SetCircuitBreakerState records the circuit breaker state of a backend pool
+func SetCircuitBreakerState(backend string, state int) {
+	CircuitBreakerState.WithLabelValues(backend).Set(float64(state))
+}
+
+// RecordConvertedAmount records the IDN amount an IDR amount converted to
+func RecordConvertedAmount(table, column string, amount float64) {
+	ConvertedAmounts.WithLabelValues(table, column).Observe(math.Abs(amount))
+}
+
+// RecordObservedStatement records a statement forwarded untouched because its
+// table is in observe mode, and whether it would have been rewritten
+func RecordObservedStatement(table string, rewritable bool) {
+	outcome := "would_fail"
+	if rewritable {
+		outcome = "would_rewrite"
+	}
+	ObservedStatements.WithLabelValues(table, outcome).Inc()
+}
+
+// RecordCanaryComparison records the outcome of a canary replay
+func RecordCanaryComparison(table, outcome string) {
+	CanaryComparisons.WithLabelValues(table, outcome).Inc()
+}
+
+// RecordOutboxTask records an outbox task enqueued or applied
+func RecordOutboxTask(table, outcome string) {
+	OutboxTasks.WithLabelValues(table, outcome).Inc()
+}
+
+// RecordOutboxLag records how long a task waited before it was applied
+func RecordOutboxLag(table string, lag time.Duration) {
+	OutboxLag.WithLabelValues(table).Observe(lag.Seconds())
+}
+
+// SetOutboxPending sets the number of outbox tasks not yet applied
+func SetOutboxPending(pending int64) {
+	OutboxPending.Set(float64(pending))
+}
+
+// RecordJobRun records a finished run of a scheduled job
+func RecordJobRun(job, outcome string, finished time.Time) {
+	JobRuns.WithLabelValues(job, outcome).Inc()
+	if outcome == "success" {
+		JobLastSuccess.WithLabelValues(job).Set(float64(finished.Unix()))
+	}
+}
+
+// RecordUnparsedStatement records a statement above max_parse_size that skipped the parser
+func RecordUnparsedStatement(table string) {
+	UnparsedStatements.WithLabelValues(table).Inc()
+}
+
+// RecordParserFailure records a statement the SQL parser could not parse
+func RecordParserFailure(reason string) {
+	ParserFailures.WithLabelValues(reason).Inc()
+}
+
+// RecordTransactionRollback records a transaction rolled back after a dual-write failure
+func RecordTransactionRollback(table string) {
+	TransactionRollbacks.WithLabelValues(table).Inc()
+}
+
+// RecordSchemaChange records a DDL statement accepted by the backend
+func RecordSchemaChange(kind string) {
+	SchemaChanges.WithLabelValues(kind).Inc()
+}
+
+// RecordUnconfiguredCurrencyColumn records a currency-looking column added without a config
+func RecordUnconfiguredCurrencyColumn(table string) {
+	UnconfiguredCurrencyColumns.WithLabelValues(table).Inc()
+}
+
+// RecordResultLimitExceeded records a resultset terminated by max_rows or max_result_bytes
+func RecordResultLimitExceeded(limit string) {
+	ResultLimitsExceeded.WithLabelValues(limit).Inc()
+}
+
+// RecordPrimaryFailover records a failover to the named backend
+func RecordPrimaryFailover(backend string) {
+	PrimaryFailovers.WithLabelValues(backend).Inc()
+}
+
+// RecordProbe records a SELECT 1 probe; a failed probe counts as taking
+// its whole timeout
+func RecordProbe(latency time.Duration, failed bool) {
+	ProbeLatency.Set(latency.Seconds())
+	if failed {
+		ProbeFailures.Inc()
+	}
+}
+
+// SetConfigWatcherUp records whether the config watcher is subscribed
+func SetConfigWatcherUp(up bool) {
+	if up {
+		ConfigWatcherUp.Set(1)
+	} else {
+		ConfigWatcherUp.Set(0)
+	}
+}
+
+// SetBreakGlassActive records whether break glass pass-through is active
+func SetBreakGlassActive(active bool) {
+	if active {
+		BreakGlassActive.Set(1)
+	} else {
+		BreakGlassActive.Set(0)
+	}
+}
+
+// RecordBreakGlassStatement records a statement forwarded by break glass
+func RecordBreakGlassStatement() {
+	BreakGlassStatements.Inc()
+}
+
+// RecordConfigVersionSession records a new session on the staged or the
+// active config version
+func RecordConfigVersionSession(staged bool) {
+	version := "active"
+	if staged {
+		version = "staged"
+	}
+	ConfigVersionSessions.WithLabelValues(version).Inc()
+}
+
+// RecordPoolAcquire records the time a session waited for a backend connection
+func RecordPoolAcquire(backend string, duration time.Duration) {
+	PoolAcquireDuration.WithLabelValues(backend).Observe(duration.Seconds())
+}
+
+// AddPoolConnections adjusts the idle or active connection count of a backend
+func AddPoolConnections(backend, state string, delta int) {
+	PoolConnections.WithLabelValues(backend, state).Add(float64(delta))
+}
+
+// AddPoolWaiting adjusts the number of sessions waiting for a backend connection
+func AddPoolWaiting(backend string, delta int) {
+	PoolWaiting.WithLabelValues(backend).Add(float64(delta))
+}
+
+// RecordPoolCreateFailure records a failed connection attempt; reason is
+// circuit_breaker when the breaker rejected it and dial otherwise
+func RecordPoolCreateFailure(backend, reason string) {
+	PoolCreateFailures.WithLabelValues(backend, reason).Inc()
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// phaseMetrics maps summary phase names to their histogram metric names
+var phaseMetrics = map[string]string{
+	"parse":   "transisidb_query_parse_duration_seconds",
+	"rewrite": "transisidb_query_rewrite_duration_seconds",
+	"backend": "transisidb_query_backend_duration_seconds",
+	"stream":  "transisidb_query_stream_duration_seconds",
+}
+
+// PhaseSummary summarizes one latency phase for one statement type
+type PhaseSummary struct {
+	Count uint64  `json:"count"`
+	AvgMs float64 `json:"avg_ms"`
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// StatementSummary holds the latency breakdown for one statement type
+type StatementSummary struct {
+	Phases map[string]*PhaseSummary `json:"phases"`
+	// ProxyOverheadAvgMs is the average time the proxy itself adds (parse + rewrite)
+	ProxyOverheadAvgMs float64 `json:"proxy_overhead_avg_ms"`
+}
+
+// LatencySummary builds a per-statement latency breakdown from the given gatherer
+func LatencySummary(g prometheus.Gatherer) (map[string]*StatementSummary, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, mf := range families {
+		byName[mf.GetName()] = mf
+	}
+
+	summary := make(map[string]*StatementSummary)
+	for phase, name := range phaseMetrics {
+		mf, ok := byName[name]
+		if !ok {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			statement := labelValue(m, "statement")
+			stmtSummary, ok := summary[statement]
+			if !ok {
+				stmtSummary = &StatementSummary{Phases: make(map[string]*PhaseSummary)}
+				summary[statement] = stmtSummary
+			}
+			stmtSummary.Phases[phase] = summarizeHistogram(m.GetHistogram())
+		}
+	}
+
+	for _, stmtSummary := range summary {
+		for _, phase := range []string{"parse", "rewrite"} {
+			if ps, ok := stmtSummary.Phases[phase]; ok {
+				stmtSummary.ProxyOverheadAvgMs += ps.AvgMs
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// conversionMetrics maps conversion counter names to their metric names;
+// histograms count their observations
+var conversionMetrics = map[string]string{
+	"converted_amounts":     "transisidb_converted_amounts",
+	"rewrite_failures":      "transisidb_rewrite_failures_total",
+	"suspect_amounts":       "transisidb_suspect_amounts_total",
+	"out_of_range_amounts":  "transisidb_out_of_range_amounts_total",
+	"observed_statements":   "transisidb_observed_statements_total",
+	"rollout_statements":    "transisidb_rollout_statements_total",
+	"transaction_rollbacks": "transisidb_dualwrite_tx_rollbacks_total",
+	"unconfigured_columns":  "transisidb_unconfigured_currency_columns_total",
+	"outbox_tasks":          "transisidb_outbox_tasks_total",
+	"canary_comparisons":    "transisidb_canary_comparisons_total",
+}
+
+// ConversionCounters totals the conversion counters of the given gatherer
+// over all their labels. Counters that were never recorded are zero.
+func ConversionCounters(g prometheus.Gatherer) (map[string]float64, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, mf := range families {
+		byName[mf.GetName()] = mf
+	}
+
+	counters := make(map[string]float64, len(conversionMetrics))
+	for counter, name := range conversionMetrics {
+		counters[counter] = 0
+		mf, ok := byName[name]
+		if !ok {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if h := m.GetHistogram(); h != nil {
+				counters[counter] += float64(h.GetSampleCount())
+			} else {
+				counters[counter] += m.GetCounter().GetValue()
+			}
+		}
+	}
+	return counters, nil
+}
+
+// summarizeHistogram computes count, average and bucket-interpolated quantiles
+func summarizeHistogram(h *dto.Histogram) *PhaseSummary {
+	ps := &PhaseSummary{Count: h.GetSampleCount()}
+	if ps.Count == 0 {
+		return ps
+	}
+
+	ps.AvgMs = h.GetSampleSum() / float64(ps.Count) * 1000
+	ps.P50Ms = histogramQuantile(0.50, h) * 1000
+	ps.P95Ms = histogramQuantile(0.95, h) * 1000
+	ps.P99Ms = histogramQuantile(0.99, h) * 1000
+	return ps
+}
+
+// histogramQuantile estimates a quantile by linear interpolation within buckets,
+// the same approach PromQL's histogram_quantile uses
+func histogramQuantile(q float64, h *dto.Histogram) float64 {
+	total := float64(h.GetSampleCount())
+	if total == 0 {
+		return 0
+	}
+
+	rank := q * total
+	prevBound := 0.0
+	prevCount := 0.0
+	for _, b := range h.GetBucket() {
+		count := float64(b.GetCumulativeCount())
+		bound := b.GetUpperBound()
+		if count >= rank {
+			if count == prevCount {
+				return bound
+			}
+			return prevBound + (bound-prevBound)*(rank-prevCount)/(count-prevCount)
+		}
+		prevBound = bound
+		prevCount = count
+	}
+
+	// Quantile falls into the +Inf bucket; report the highest finite bound
+	if math.IsInf(prevBound, 1) {
+		return 0
+	}
+	return prevBound
+}
+
+// labelValue returns the value of the named label on a metric
+func labelValue(m *dto.Metric, name string) string {
+	for _, lp := range m.GetLabel() {
+		if lp.GetName() == name {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencySummary(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	parse := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transisidb_query_parse_duration_seconds",
+		Buckets: latencyBuckets,
+	}, []string{"statement"})
+	backend := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transisidb_query_backend_duration_seconds",
+		Buckets: latencyBuckets,
+	}, []string{"statement"})
+	reg.MustRegister(parse, backend)
+
+	for i := 0; i < 100; i++ {
+		parse.WithLabelValues("INSERT").Observe((200 * time.Microsecond).Seconds())
+		backend.WithLabelValues("INSERT").Observe((5 * time.Millisecond).Seconds())
+	}
+
+	summary, err := LatencySummary(reg)
+	require.NoError(t, err)
+	require.Contains(t, summary, "INSERT")
+
+	insert := summary["INSERT"]
+	require.Contains(t, insert.Phases, "parse")
+	require.Contains(t, insert.Phases, "backend")
+	assert.Equal(t, uint64(100), insert.Phases["parse"].Count)
+	assert.InDelta(t, 0.2, insert.Phases["parse"].AvgMs, 0.001)
+	assert.InDelta(t, 5.0, insert.Phases["backend"].AvgMs, 0.001)
+	assert.InDelta(t, 0.2, insert.ProxyOverheadAvgMs, 0.001)
+
+	// Quantiles are bucket estimates: they must fall within the observed value's bucket
+	assert.Greater(t, insert.Phases["parse"].P99Ms, 0.1)
+	assert.LessOrEqual(t, insert.Phases["parse"].P99Ms, 0.4)
+}
+
+func TestLatencySummary_Empty(t *testing.T) {
+	summary, err := LatencySummary(prometheus.NewRegistry())
+	require.NoError(t, err)
+	assert.Empty(t, summary)
+}
+
+func TestConversionCounters(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	failures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "transisidb_rewrite_failures_total",
+	}, []string{"table", "stage"})
+	converted := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transisidb_converted_amounts",
+		Buckets: amountBuckets,
+	}, []string{"table", "column"})
+	reg.MustRegister(failures, converted)
+
+	failures.WithLabelValues("orders", "parse").Add(2)
+	failures.WithLabelValues("invoices", "range").Inc()
+	converted.WithLabelValues("orders", "total_amount").Observe(1.5)
+	converted.WithLabelValues("orders", "shipping_fee").Observe(0.5)
+
+	counters, err := ConversionCounters(reg)
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, counters["rewrite_failures"])
+	assert.Equal(t, 2.0, counters["converted_amounts"])
+	assert.Contains(t, counters, "suspect_amounts", "counters never recorded are zero")
+	assert.Zero(t, counters["suspect_amounts"])
+}
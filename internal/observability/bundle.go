@@ -0,0 +1,29 @@
+// Package observability holds the curated Grafana dashboard and Prometheus
+// alerting rules for TransisiDB. They are copies of the files provisioned by
+// docker-compose, grafana/provisioning/dashboards/transisidb.json and
+// prometheus/alerts.yml, which a test keeps in sync.
+package observability
+
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+//go:embed dashboard.json
+var dashboard []byte
+
+//go:embed alerts.yml
+var alertRules string
+
+// Bundle is a monitoring setup ready to import
+type Bundle struct {
+	// Dashboard is the Grafana dashboard model
+	Dashboard json.RawMessage `json:"dashboard"`
+	// AlertRules is a Prometheus rule file
+	AlertRules string `json:"alert_rules"`
+}
+
+// Get returns the bundle
+func Get() Bundle {
+	return Bundle{Dashboard: dashboard, AlertRules: alertRules}
+}
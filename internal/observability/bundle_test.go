@@ -0,0 +1,82 @@
+package observability
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBundle_MatchesProvisionedFiles(t *testing.T) {
+	provisioned, err := os.ReadFile("../../grafana/provisioning/dashboards/transisidb.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(provisioned), string(dashboard), "copy the dashboard to internal/observability/dashboard.json")
+
+	provisioned, err = os.ReadFile("../../prometheus/alerts.yml")
+	require.NoError(t, err)
+	assert.Equal(t, string(provisioned), alertRules, "copy the alert rules to internal/observability/alerts.yml")
+}
+
+func TestBundle_Parses(t *testing.T) {
+	bundle := Get()
+
+	var model struct {
+		UID    string `json:"uid"`
+		Panels []struct {
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	require.NoError(t, json.Unmarshal(bundle.Dashboard, &model))
+	assert.Equal(t, "transisidb-overview", model.UID)
+
+	var rules struct {
+		Groups []struct {
+			Rules []struct {
+				Alert string `yaml:"alert"`
+				Expr  string `yaml:"expr"`
+			} `yaml:"rules"`
+		} `yaml:"groups"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(bundle.AlertRules), &rules))
+	require.Len(t, rules.Groups, 1)
+	alerts := map[string]string{}
+	for _, rule := range rules.Groups[0].Rules {
+		alerts[rule.Alert] = rule.Expr
+	}
+	for _, name := range []string{"DualWriteFailures", "CircuitBreakerOpen", "BackfillStalled", "CanaryMismatchDetected"} {
+		assert.Contains(t, alerts, name)
+	}
+
+	// Every query reads a metric the proxy exports
+	exprs := make([]string, 0, len(alerts))
+	for _, expr := range alerts {
+		exprs = append(exprs, expr)
+	}
+	for _, panel := range model.Panels {
+		for _, target := range panel.Targets {
+			exprs = append(exprs, target.Expr)
+		}
+	}
+	source, err := os.ReadFile("../metrics/metrics.go")
+	require.NoError(t, err)
+	exported := map[string]bool{}
+	for _, m := range regexp.MustCompile(`Name:\s+"(transisidb_[a-z_]+)"`).FindAllStringSubmatch(string(source), -1) {
+		exported[m[1]] = true
+	}
+	metric := regexp.MustCompile(`transisidb_[a-z_]+`)
+	histogramSeries := regexp.MustCompile(`_(bucket|count|sum)$`)
+	for _, expr := range exprs {
+		for _, name := range metric.FindAllString(expr, -1) {
+			if !exported[name] {
+				name = histogramSeries.ReplaceAllString(name, "")
+			}
+			assert.True(t, exported[name], "%s in %s is not exported", name, expr)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Package onboarding takes a configured table through the steps that put it
+// into production: schema validation, shadow columns, a partial rollout, a
+// sample backfill, verification and the full rollout. Every run is recorded
+// step by step, and the steps can be repeated, so an interrupted or failed
+// onboarding is resumed by starting it again.
+package onboarding
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+// Step names, in the order they run
+const (
+	StepValidate       = "validate_schema"
+	StepShadowDDL      = "shadow_ddl"
+	StepObserve        = "observe"
+	StepSampleBackfill = "sample_backfill"
+	StepVerify         = "verify"
+	StepActivate       = "activate"
+)
+
+// Statuses of runs and steps
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped" // not run because an earlier step failed
+)
+
+// Option defaults
+const (
+	DefaultObservePercent = 10
+	DefaultSampleRows     = 1000
+)
+
+var (
+	// ErrRunning is returned when the table is already being onboarded, or
+	// locked by another host's onboarding or backfill
+	ErrRunning = errs.New(errs.ConfigConflict, "table is already being onboarded")
+	// ErrInvalidOptions is returned for options out of range
+	ErrInvalidOptions = errs.New(errs.ConfigInvalid, "invalid onboarding options")
+)
+
+// Options control an onboarding run
+type Options struct {
+	// ApplyDDL adds missing shadow columns; without it the generated DDL is
+	// reported and the run stops until the columns exist
+	ApplyDDL bool `json:"apply_ddl"`
+	// ObservePercent is the rollout used while the sample is checked
+	ObservePercent float64 `json:"observe_percent"`
+	// SampleRows is the number of rows backfilled before verification
+	SampleRows int `json:"sample_rows"`
+}
+
+// withDefaults fills unset options and checks their ranges
+func (o Options) withDefaults() (Options, error) {
+	if o.ObservePercent == 0 {
+		o.ObservePercent = DefaultObservePercent
+	}
+	if o.SampleRows == 0 {
+		o.SampleRows = DefaultSampleRows
+	}
+	if o.ObservePercent < 0 || o.ObservePercent > 100 {
+		return o, fmt.Errorf("%w: observe_percent must be between 0 and 100, got %v", ErrInvalidOptions, o.ObservePercent)
+	}
+	if o.SampleRows < 0 {
+		return o, fmt.Errorf("%w: sample_rows must not be negative, got %d", ErrInvalidOptions, o.SampleRows)
+	}
+	return o, nil
+}
+
+// Step is the recorded state of one step of a run
+type Step struct {
+	Name     string     `json:"name"`
+	Status   string     `json:"status"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Detail   string     `json:"detail,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Record is the latest onboarding run of a table
+type Record struct {
+	Table    string     `json:"table"`
+	Status   string     `json:"status"`
+	Actor    string     `json:"actor"`
+	Options  Options    `json:"options"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Steps    []Step     `json:"steps"`
+}
+
+// Tables changes the stored table configs; *config.RedisStore implements it
+type Tables interface {
+	LoadTableConfig(ctx context.Context, tableName string) (*config.TableConfig, error)
+	SetTableEnabled(ctx context.Context, tableName string, enabled bool) error
+	SetTableRollout(ctx context.Context, tableName string, percent float64) error
+	SetTableMode(ctx context.Context, tableName, mode string) error
+	PublishReload(ctx context.Context) error
+	AppendAudit(ctx context.Context, entry config.AuditEntry) error
+}
+
+// run is the state shared by the steps of a run
+type run struct {
+	table       string
+	tableConfig config.TableConfig
+	options     Options
+	// missing lists the shadow columns validation did not find
+	missing []string
+	// ddl adds the missing shadow columns
+	ddl string
+}
+
+// step runs one stage of onboarding and describes what it did
+type step struct {
+	name string
+	run  func(ctx context.Context, r *run) (string, error)
+}
+
+// Pipeline onboards tables
+type Pipeline struct {
+	db      *sql.DB
+	tables  Tables
+	records Records
+	cfg     *config.Config
+	steps   []step
+	now     func() time.Time
+
+	// locks keeps other hosts from onboarding or backfilling the table while
+	// it is onboarded, nil disables it
+	locks lock.Locker
+
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+// New returns a pipeline onboarding the tables of cfg's database
+func New(db *sql.DB, cfg *config.Config, tables Tables, records Records) *Pipeline {
+	p := &Pipeline{
+		db:      db,
+		tables:  tables,
+		records: records,
+		cfg:     cfg,
+		now:     time.Now,
+		running: make(map[string]bool),
+	}
+	p.steps = []step{
+		{StepValidate, p.validateSchema},
+		{StepShadowDDL, p.shadowDDL},
+		{StepObserve, p.observe},
+		{StepSampleBackfill, p.sampleBackfill},
+		{StepVerify, p.verify},
+		{StepActivate, p.activate},
+	}
+	return p
+}
+
+// SetLocker makes runs hold the table's lock, shared with backfill jobs, so
+// a table is onboarded by one API server at a time and never while it is
+// backfilled
+func (p *Pipeline) SetLocker(locker lock.Locker) {
+	p.locks = locker
+}
+
+// Start records a new run for a configured table and runs its steps in the
+// background; Status reports their progress
+func (p *Pipeline) Start(ctx context.Context, table string, opts Options, actor string) (*Record, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	tableConfig, err := p.tables.LoadTableConfig(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if p.running[table] {
+		p.mu.Unlock()
+		return nil, ErrRunning
+	}
+	p.running[table] = true
+	p.mu.Unlock()
+
+	var lease lock.Lease
+	if p.locks != nil {
+		if lease, err = p.locks.Acquire(ctx, lock.Table(table), lock.Owner(), lock.DefaultTTL); err != nil {
+			p.done(table)
+			if errors.Is(err, lock.ErrHeld) {
+				return nil, fmt.Errorf("%w: %w", ErrRunning, err)
+			}
+			return nil, err
+		}
+	}
+
+	record := &Record{Table: table, Status: StatusRunning, Actor: actor, Options: opts, Started: p.now()}
+	for _, s := range p.steps {
+		record.Steps = append(record.Steps, Step{Name: s.name, Status: StatusPending})
+	}
+	if err := p.records.Save(ctx, record); err != nil {
+		p.release(ctx, lease)
+		p.done(table)
+		return nil, err
+	}
+
+	snapshot := *record
+	snapshot.Steps = append([]Step(nil), record.Steps...)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer p.done(table)
+		ctx := context.WithoutCancel(ctx)
+		r := &run{table: table, tableConfig: *tableConfig, options: opts}
+		if p.locks == nil {
+			p.execute(ctx, record, r)
+			return
+		}
+		// Steps stop at the lease's cancellation if another host takes the lock
+		lock.Keep(ctx, p.locks, lease, lock.DefaultTTL, func(ctx context.Context, lease lock.Lease) error {
+			p.execute(ctx, record, r)
+			return nil
+		})
+	}()
+	return &snapshot, nil
+}
+
+// release gives up a lease of a run that did not start
+func (p *Pipeline) release(ctx context.Context, lease lock.Lease) {
+	if p.locks == nil {
+		return
+	}
+	if err := p.locks.Release(ctx, lease); err != nil {
+		logger.Warn("Failed to release lock", "lock", lease.Name, "error", err)
+	}
+}
+
+// Status returns the latest run of a table, or nil if it was never onboarded
+func (p *Pipeline) Status(ctx context.Context, table string) (*Record, error) {
+	return p.records.Load(ctx, table)
+}
+
+// Wait waits for running onboardings to finish
+func (p *Pipeline) Wait() {
+	p.wg.Wait()
+}
+
+func (p *Pipeline) done(table string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.running, table)
+}
+
+// execute runs the steps in order, saving the record after each change, and
+// stops at the first failure
+func (p *Pipeline) execute(ctx context.Context, record *Record, r *run) {
+	logger.Info("Onboarding table", "table", r.table)
+	failed := ""
+	for i, s := range p.steps {
+		current := &record.Steps[i]
+		if failed != "" {
+			current.Status = StatusSkipped
+			continue
+		}
+
+		started := p.now()
+		current.Status, current.Started = StatusRunning, &started
+		p.save(ctx, record)
+
+		detail, err := s.run(ctx, r)
+		finished := p.now()
+		current.Finished, current.Detail = &finished, detail
+		if err != nil {
+			current.Status, current.Error = StatusFailed, err.Error()
+			failed = s.name
+			logger.Error("Onboarding step failed", "table", r.table, "step", s.name, "error", err)
+		} else {
+			current.Status = StatusSucceeded
+			logger.Info("Onboarding step finished", "table", r.table, "step", s.name, "detail", detail)
+		}
+	}
+
+	finished := p.now()
+	record.Finished = &finished
+	record.Status = StatusSucceeded
+	auditDetail := "succeeded"
+	if failed != "" {
+		record.Status = StatusFailed
+		auditDetail = "failed at " + failed
+	}
+	p.save(ctx, record)
+
+	entry := config.AuditEntry{Time: finished, Actor: record.Actor, Action: "onboard_table", Target: r.table, Detail: auditDetail}
+	if err := p.tables.AppendAudit(ctx, entry); err != nil {
+		logger.Warn("Failed to record audit entry", "action", entry.Action, "table", r.table, "error", err)
+	}
+	logger.Info("Onboarding finished", "table", r.table, "status", record.Status)
+}
+
+func (p *Pipeline) save(ctx context.Context, record *Record) {
+	if err := p.records.Save(ctx, record); err != nil {
+		logger.Warn("Failed to record onboarding progress", "table", record.Table, "error", err)
+	}
+}
@@ -0,0 +1,249 @@
+package onboarding
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
+	"github.com/kafitramarna/TransisiDB/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTables records the toggles and audit entries onboarding writes
+type fakeTables struct {
+	mu       sync.Mutex
+	configs  map[string]config.TableConfig
+	enabled  map[string]bool
+	modes    map[string]string
+	rollouts []float64
+	audit    []config.AuditEntry
+}
+
+func newFakeTables(tables ...string) *fakeTables {
+	f := &fakeTables{configs: make(map[string]config.TableConfig), enabled: make(map[string]bool), modes: make(map[string]string)}
+	for _, table := range tables {
+		f.configs[table] = config.TableConfig{Columns: map[string]config.ColumnConfig{
+			"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+		}}
+	}
+	return f
+}
+
+func (f *fakeTables) LoadTableConfig(ctx context.Context, tableName string) (*config.TableConfig, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tableConfig, ok := f.configs[tableName]
+	if !ok {
+		return nil, errors.New("table not found")
+	}
+	return &tableConfig, nil
+}
+
+func (f *fakeTables) SetTableEnabled(ctx context.Context, tableName string, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enabled[tableName] = enabled
+	return nil
+}
+
+func (f *fakeTables) SetTableRollout(ctx context.Context, tableName string, percent float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rollouts = append(f.rollouts, percent)
+	return nil
+}
+
+func (f *fakeTables) SetTableMode(ctx context.Context, tableName, mode string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.modes[tableName] = mode
+	return nil
+}
+
+func (f *fakeTables) PublishReload(ctx context.Context) error { return nil }
+
+func (f *fakeTables) AppendAudit(ctx context.Context, entry config.AuditEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.audit = append(f.audit, entry)
+	return nil
+}
+
+// replaceStep swaps the function of a step, leaving the others in place
+func replaceStep(p *Pipeline, name string, fn func(ctx context.Context, r *run) (string, error)) {
+	for i := range p.steps {
+		if p.steps[i].name == name {
+			p.steps[i].run = fn
+		}
+	}
+}
+
+func ok(detail string) func(ctx context.Context, r *run) (string, error) {
+	return func(ctx context.Context, r *run) (string, error) { return detail, nil }
+}
+
+func TestPipeline_RunsStepsAndRollsOut(t *testing.T) {
+	tables := newFakeTables("orders")
+	records := NewMemoryRecords()
+	p := New(nil, &config.Config{}, tables, records)
+	for _, name := range []string{StepValidate, StepShadowDDL, StepSampleBackfill, StepVerify} {
+		replaceStep(p, name, ok(name+" done"))
+	}
+
+	record, err := p.Start(context.Background(), "orders", Options{}, "api:10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, record.Status)
+	assert.Equal(t, float64(DefaultObservePercent), record.Options.ObservePercent)
+	assert.Equal(t, DefaultSampleRows, record.Options.SampleRows)
+	p.Wait()
+
+	record, err = p.Status(context.Background(), "orders")
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, record.Status)
+	require.Len(t, record.Steps, 6)
+	for _, s := range record.Steps {
+		assert.Equal(t, StatusSucceeded, s.Status, s.Name)
+		assert.NotNil(t, s.Finished, s.Name)
+	}
+	assert.Equal(t, "dual-write enabled for 10% of statements", record.Steps[2].Detail)
+
+	// Observe first, then the full rollout
+	assert.Equal(t, []float64{10, 100}, tables.rollouts)
+	assert.True(t, tables.enabled["orders"])
+	assert.Equal(t, config.TableModeEnforce, tables.modes["orders"])
+	require.Len(t, tables.audit, 1)
+	assert.Equal(t, "onboard_table", tables.audit[0].Action)
+	assert.Equal(t, "api:10.0.0.1", tables.audit[0].Actor)
+	assert.Equal(t, "succeeded", tables.audit[0].Detail)
+}
+
+func TestPipeline_StopsAtFailureAndCanBeRepeated(t *testing.T) {
+	tables := newFakeTables("orders")
+	p := New(nil, &config.Config{}, tables, NewMemoryRecords())
+	for _, s := range p.steps {
+		replaceStep(p, s.name, ok(s.name))
+	}
+	replaceStep(p, StepShadowDDL, func(ctx context.Context, r *run) (string, error) {
+		return "ALTER TABLE `orders` ADD COLUMN `total_amount_idn` DECIMAL(23,4) NULL", errors.New("shadow columns are missing")
+	})
+
+	_, err := p.Start(context.Background(), "orders", Options{}, "api:10.0.0.1")
+	require.NoError(t, err)
+	p.Wait()
+
+	record, err := p.Status(context.Background(), "orders")
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, record.Status)
+	assert.Equal(t, StatusSucceeded, record.Steps[0].Status)
+	assert.Equal(t, StatusFailed, record.Steps[1].Status)
+	assert.Equal(t, "shadow columns are missing", record.Steps[1].Error)
+	assert.Contains(t, record.Steps[1].Detail, "ALTER TABLE")
+	for _, s := range record.Steps[2:] {
+		assert.Equal(t, StatusSkipped, s.Status, s.Name)
+		assert.Nil(t, s.Started, s.Name)
+	}
+	assert.Equal(t, "failed at shadow_ddl", tables.audit[0].Detail)
+
+	// Starting again runs every step once the problem is fixed
+	replaceStep(p, StepShadowDDL, ok("shadow columns exist"))
+	_, err = p.Start(context.Background(), "orders", Options{}, "api:10.0.0.1")
+	require.NoError(t, err)
+	p.Wait()
+	record, err = p.Status(context.Background(), "orders")
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, record.Status)
+}
+
+func TestPipeline_Start(t *testing.T) {
+	p := New(nil, &config.Config{}, newFakeTables("orders"), NewMemoryRecords())
+	release := make(chan struct{})
+	for _, s := range p.steps {
+		replaceStep(p, s.name, ok(s.name))
+	}
+	replaceStep(p, StepValidate, func(ctx context.Context, r *run) (string, error) {
+		<-release
+		return "", nil
+	})
+	ctx := context.Background()
+
+	_, err := p.Start(ctx, "invoices", Options{}, "")
+	assert.Error(t, err, "tables must be configured first")
+	_, err = p.Start(ctx, "orders", Options{ObservePercent: 120}, "")
+	assert.ErrorContains(t, err, "observe_percent")
+
+	_, err = p.Start(ctx, "orders", Options{}, "")
+	require.NoError(t, err)
+	_, err = p.Start(ctx, "orders", Options{}, "")
+	assert.ErrorIs(t, err, ErrRunning)
+	close(release)
+	p.Wait()
+
+	record, err := p.Status(ctx, "invoices")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+}
+
+func TestPipeline_Start_HoldsTableLock(t *testing.T) {
+	p := New(nil, &config.Config{}, newFakeTables("orders"), NewMemoryRecords())
+	for _, s := range p.steps {
+		replaceStep(p, s.name, ok(s.name))
+	}
+	locks := lock.NewMemory()
+	p.SetLocker(locks)
+	ctx := context.Background()
+
+	backfill, err := locks.Acquire(ctx, lock.Table("orders"), "backfill-host", lock.DefaultTTL)
+	require.NoError(t, err)
+	_, err = p.Start(ctx, "orders", Options{}, "")
+	assert.ErrorIs(t, err, ErrRunning)
+	assert.ErrorIs(t, err, lock.ErrHeld)
+
+	require.NoError(t, locks.Release(ctx, backfill))
+	_, err = p.Start(ctx, "orders", Options{}, "")
+	require.NoError(t, err)
+	p.Wait()
+
+	leases, err := locks.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, leases, "the lock is released once the run finishes")
+}
+
+func TestCheckSchema(t *testing.T) {
+	meta := &schema.Table{Name: "orders", Columns: []schema.Column{
+		{Name: "id", Type: "bigint"},
+		{Name: "total_amount", Type: "bigint"},
+		{Name: "shipping_fee", Type: "decimal(12,2)"},
+		{Name: "discount", Type: "int"},
+		{Name: "discount_idn", Type: "int"},
+		{Name: "note", Type: "varchar(255)"},
+	}}
+	conv := config.ConversionConfig{Precision: 4}
+
+	tableConfig := config.TableConfig{Columns: map[string]config.ColumnConfig{
+		"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+		"shipping_fee": {TargetColumn: "shipping_fee_idn", TargetType: "DECIMAL(14,4)"},
+	}}
+	missing, ddl, problems := checkSchema("orders", meta, tableConfig, conv)
+	assert.Empty(t, problems)
+	assert.Equal(t, []string{"shipping_fee_idn", "total_amount_idn"}, missing)
+	assert.Equal(t, "ALTER TABLE `orders` ADD COLUMN `shipping_fee_idn` DECIMAL(14,4) NULL, "+
+		"ADD COLUMN `total_amount_idn` DECIMAL(23,4) NULL", ddl)
+
+	tableConfig = config.TableConfig{Columns: map[string]config.ColumnConfig{
+		"discount": {TargetColumn: "discount_idn"},
+		"note":     {TargetColumn: "note_idn"},
+		"tax":      {TargetColumn: "tax_idn"},
+	}}
+	missing, ddl, problems = checkSchema("shop.orders", meta, tableConfig, conv)
+	assert.Empty(t, missing)
+	assert.Empty(t, ddl)
+	assert.Equal(t, []string{
+		"shadow column discount_idn is int and would truncate converted decimals",
+		"column note is varchar(255), not an integer or decimal type",
+		"column tax does not exist",
+	}, problems)
+}
@@ -0,0 +1,92 @@
+package onboarding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Records keeps the latest onboarding run of each table
+type Records interface {
+	Save(ctx context.Context, record *Record) error
+	// Load returns nil when the table was never onboarded
+	Load(ctx context.Context, table string) (*Record, error)
+}
+
+// KeyPrefix starts the Redis keys of onboarding records
+const KeyPrefix = "transisidb:onboarding"
+
+// RedisRecords stores records as JSON in Redis, one key per table
+type RedisRecords struct {
+	client redis.UniversalClient
+}
+
+// NewRedisRecords returns records stored with client
+func NewRedisRecords(client redis.UniversalClient) *RedisRecords {
+	return &RedisRecords{client: client}
+}
+
+// Save replaces the table's record
+func (s *RedisRecords) Save(ctx context.Context, record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal onboarding record: %w", err)
+	}
+	if err := s.client.Set(ctx, KeyPrefix+":"+record.Table, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save onboarding record: %w", err)
+	}
+	return nil
+}
+
+// Load returns the table's record
+func (s *RedisRecords) Load(ctx context.Context, table string) (*Record, error) {
+	data, err := s.client.Get(ctx, KeyPrefix+":"+table).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load onboarding record: %w", err)
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal onboarding record: %w", err)
+	}
+	return &record, nil
+}
+
+// MemoryRecords keeps records in process, for tests and single-node setups
+type MemoryRecords struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryRecords returns empty in-memory records
+func NewMemoryRecords() *MemoryRecords {
+	return &MemoryRecords{records: make(map[string]Record)}
+}
+
+// Save replaces the table's record
+func (s *MemoryRecords) Save(ctx context.Context, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := *record
+	saved.Steps = append([]Step(nil), record.Steps...)
+	s.records[record.Table] = saved
+	return nil
+}
+
+// Load returns the table's record
+func (s *MemoryRecords) Load(ctx context.Context, table string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved, ok := s.records[table]
+	if !ok {
+		return nil, nil
+	}
+	saved.Steps = append([]Step(nil), saved.Steps...)
+	return &saved, nil
+}
@@ -0,0 +1,191 @@
+package onboarding
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/backfill"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/internal/schema"
+)
+
+// validateSchema checks the table config against the live schema and
+// generates the DDL for missing shadow columns
+func (p *Pipeline) validateSchema(ctx context.Context, r *run) (string, error) {
+	if len(r.tableConfig.Columns) == 0 {
+		return "", fmt.Errorf("no currency columns configured")
+	}
+	if p.db == nil {
+		return "", fmt.Errorf("database is not available")
+	}
+
+	database, table := p.cfg.Database.Database, r.table
+	if dot := strings.IndexByte(table, '.'); dot >= 0 {
+		database, table = table[:dot], table[dot+1:]
+	}
+	tables, err := schema.NewSQLLoader(p.db).LoadTables(ctx, database, table)
+	if err != nil {
+		return "", err
+	}
+	var meta *schema.Table
+	for name, t := range tables {
+		if strings.EqualFold(name, table) {
+			meta = t
+		}
+	}
+	if meta == nil {
+		return "", fmt.Errorf("table %s not found in database %s", table, database)
+	}
+
+	missing, ddl, problems := checkSchema(r.table, meta, r.tableConfig, p.cfg.Conversion)
+	if len(problems) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	r.missing, r.ddl = missing, ddl
+	return fmt.Sprintf("%d currency columns checked, %d shadow columns missing", len(r.tableConfig.Columns), len(missing)), nil
+}
+
+// checkSchema compares a table config with the table's metadata. It returns
+// the missing shadow columns with the statement adding them, and the problems
+// that stop onboarding: missing or non-numeric source columns and integer
+// shadow columns, which would truncate converted decimals.
+func checkSchema(table string, meta *schema.Table, tableConfig config.TableConfig, conv config.ConversionConfig) ([]string, string, []string) {
+	columns := make([]string, 0, len(tableConfig.Columns))
+	for col := range tableConfig.Columns {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	var missing, additions, problems []string
+	for _, col := range columns {
+		colConfig := tableConfig.Columns[col]
+		source, ok := meta.Column(col)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("column %s does not exist", col))
+			continue
+		}
+		if _, _, decimal := config.ParseDecimalType(source.Type); !decimal && !config.IsIntegerType(source.Type) {
+			problems = append(problems, fmt.Sprintf("column %s is %s, not an integer or decimal type", col, source.Type))
+			continue
+		}
+
+		target, ok := meta.Column(colConfig.TargetColumn)
+		switch {
+		case !ok:
+			targetType := colConfig.TargetType
+			if targetType == "" {
+				columnConv := conv
+				if colConfig.Precision != 0 {
+					columnConv.Precision = colConfig.Precision
+				}
+				change := parser.ColumnChange{Name: col, Type: strings.ToUpper(source.Type)}
+				targetType = parser.ProposeShadowColumn(table, change, columnConv).Config.TargetType
+			}
+			missing = append(missing, colConfig.TargetColumn)
+			additions = append(additions, fmt.Sprintf("ADD COLUMN %s %s NULL", parser.QuoteIdentifier(colConfig.TargetColumn), targetType))
+		case config.IsIntegerType(target.Type):
+			problems = append(problems, fmt.Sprintf("shadow column %s is %s and would truncate converted decimals",
+				colConfig.TargetColumn, target.Type))
+		}
+	}
+
+	ddl := ""
+	if len(additions) > 0 {
+		ddl = "ALTER TABLE " + parser.QuoteTable(table) + " " + strings.Join(additions, ", ")
+	}
+	return missing, ddl, problems
+}
+
+// shadowDDL adds the missing shadow columns when the run allows it
+func (p *Pipeline) shadowDDL(ctx context.Context, r *run) (string, error) {
+	if len(r.missing) == 0 {
+		return "shadow columns exist", nil
+	}
+	if !r.options.ApplyDDL {
+		return r.ddl, fmt.Errorf("shadow columns %s are missing; run the DDL or onboard with apply_ddl",
+			strings.Join(r.missing, ", "))
+	}
+	if _, err := p.db.ExecContext(ctx, r.ddl); err != nil {
+		return r.ddl, fmt.Errorf("failed to add shadow columns: %w", err)
+	}
+	return "applied: " + r.ddl, nil
+}
+
+// observe enables dual-write for a share of the statements only, so the
+// shadow columns fill while most traffic is unchanged
+func (p *Pipeline) observe(ctx context.Context, r *run) (string, error) {
+	if err := p.setRollout(ctx, r.table, r.options.ObservePercent); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("dual-write enabled for %s of statements", formatPercent(r.options.ObservePercent)), nil
+}
+
+// sampleBackfill converts the first rows without a shadow value
+func (p *Pipeline) sampleBackfill(ctx context.Context, r *run) (string, error) {
+	converted, err := backfill.NewWorker(p.db, p.cfg).Sample(ctx, r.table, r.tableConfig, r.options.SampleRows)
+	return fmt.Sprintf("converted %d rows", converted), err
+}
+
+// verify compares the shadow values written so far with their sources. Rows
+// without a shadow value are expected until the full backfill and only
+// reported.
+func (p *Pipeline) verify(ctx context.Context, r *run) (string, error) {
+	columns := make([]string, 0, len(r.tableConfig.Columns))
+	for col := range r.tableConfig.Columns {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	var report []string
+	var mismatched int64
+	for _, col := range columns {
+		missing, wrong, err := backfill.CountDrift(ctx, p.db, r.table, col, r.tableConfig.Columns[col], p.cfg.Conversion)
+		if err != nil {
+			return strings.Join(report, "; "), fmt.Errorf("column %s: %w", col, err)
+		}
+		report = append(report, fmt.Sprintf("%s: %d not backfilled, %d mismatched", col, missing, wrong))
+		mismatched += wrong
+	}
+
+	detail := strings.Join(report, "; ")
+	if mismatched > 0 {
+		return detail, fmt.Errorf("%d rows have shadow values that do not match their source", mismatched)
+	}
+	return detail, nil
+}
+
+// activate rolls dual-write out to every statement
+func (p *Pipeline) activate(ctx context.Context, r *run) (string, error) {
+	if err := p.setRollout(ctx, r.table, 100); err != nil {
+		return "", err
+	}
+	return "dual-write enabled for all statements; run the full backfill to convert the remaining rows", nil
+}
+
+// setRollout enables the table in enforce mode at a rollout percentage and
+// tells the proxies
+func (p *Pipeline) setRollout(ctx context.Context, table string, percent float64) error {
+	if err := p.tables.SetTableEnabled(ctx, table, true); err != nil {
+		return fmt.Errorf("failed to enable table: %w", err)
+	}
+	if err := p.tables.SetTableMode(ctx, table, config.TableModeEnforce); err != nil {
+		return fmt.Errorf("failed to set mode: %w", err)
+	}
+	if err := p.tables.SetTableRollout(ctx, table, percent); err != nil {
+		return fmt.Errorf("failed to set rollout: %w", err)
+	}
+	if err := p.tables.PublishReload(ctx); err != nil {
+		// Proxies poll the overrides, so they catch up without the reload
+		logger.Warn("Failed to publish reload after onboarding step", "table", table, "error", err)
+	}
+	return nil
+}
+
+func formatPercent(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', -1, 64) + "%"
+}
@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/converter"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// SQLApplier converts a row's current amounts and writes its shadow columns
+type SQLApplier struct {
+	db         *sql.DB
+	tables     config.TablesConfig
+	conversion config.ConversionConfig
+}
+
+// NewSQLApplier returns an applier writing to the configured tables through db
+func NewSQLApplier(db *sql.DB, cfg *config.Config) *SQLApplier {
+	return &SQLApplier{db: db, tables: cfg.Tables, conversion: cfg.Conversion}
+}
+
+// Apply reads the row's source columns and writes the converted shadow values.
+// The update only applies while the source values are the ones read, so a
+// concurrent write is left to its own task.
+func (a *SQLApplier) Apply(ctx context.Context, task Task) error {
+	tableConfig, ok := a.tables[task.Table]
+	if !ok {
+		logger.Warn("Skipping outbox task of unconfigured table", "table", task.Table, "key", task.Key)
+		return nil
+	}
+
+	columns := make([]string, 0, len(task.Values))
+	for col := range task.Values {
+		if _, _, ok := tableConfig.LookupColumn(col); ok {
+			columns = append(columns, col)
+		}
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+	sort.Strings(columns)
+
+	// The shadow values are read too, so the guard can spot amounts that
+	// were already converted
+	quoted := make([]string, len(columns))
+	targets := make([]string, len(columns))
+	for i, col := range columns {
+		_, colConfig, _ := tableConfig.LookupColumn(col)
+		quoted[i] = parser.QuoteIdentifier(col)
+		targets[i] = parser.QuoteIdentifier(colConfig.TargetColumn)
+	}
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s = ?",
+		strings.Join(quoted, ", "), strings.Join(targets, ", "), parser.QuoteTable(task.Table), parser.QuoteIdentifier(task.KeyColumn))
+
+	current := make([]sql.NullString, len(columns))
+	shadow := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, 0, 2*len(columns))
+	for i := range current {
+		dest = append(dest, &current[i])
+	}
+	for i := range shadow {
+		dest = append(dest, &shadow[i])
+	}
+	if err := a.db.QueryRowContext(ctx, query, task.Key).Scan(dest...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The row was deleted since; there is nothing to convert
+			return nil
+		}
+		return fmt.Errorf("failed to read row: %w", err)
+	}
+
+	assignments, args, err := shadowAssignments(task.Table, tableConfig, a.conversion, columns, current, shadow)
+	if err != nil {
+		return err
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	conditions := []string{parser.QuoteIdentifier(task.KeyColumn) + " = ?"}
+	args = append(args, task.Key)
+	for i, col := range quoted {
+		conditions = append(conditions, col+" <=> ?")
+		args = append(args, current[i])
+	}
+	update := fmt.Sprintf("UPDATE %s SET %s WHERE %s", parser.QuoteTable(task.Table),
+		strings.Join(assignments, ", "), strings.Join(conditions, " AND "))
+	if _, err := a.db.ExecContext(ctx, update, args...); err != nil {
+		return fmt.Errorf("failed to update shadow columns: %w", err)
+	}
+	return nil
+}
+
+// shadowAssignments converts the current source values of columns and returns
+// the SET assignments of their shadow columns with their arguments. NULL
+// sources follow each column's null policy. Shadow columns of amounts the
+// conversion guard rejects or outside the column's bounds are left as they
+// are.
+func shadowAssignments(table string, tableConfig config.TableConfig, conversion config.ConversionConfig,
+	columns []string, current, shadow []sql.NullString) ([]string, []interface{}, error) {
+
+	guard := converter.NewGuard(conversion, converter.SourceOutbox)
+	var assignments []string
+	var args []interface{}
+	for i, col := range columns {
+		_, colConfig, _ := tableConfig.LookupColumn(col)
+		target := parser.QuoteIdentifier(colConfig.TargetColumn) + " = ?"
+
+		if !current[i].Valid {
+			switch colConfig.EffectiveNullPolicy() {
+			case config.NullPolicySkip:
+			case config.NullPolicyZero:
+				literal, err := parser.FormatShadowValue(colConfig, conversion.RoundingStrategy, conversion.Precision, 0)
+				if err != nil {
+					return nil, nil, fmt.Errorf("column %s: %w", col, err)
+				}
+				assignments = append(assignments, target)
+				args = append(args, literal)
+			default:
+				assignments = append(assignments, target)
+				args = append(args, nil)
+			}
+			continue
+		}
+
+		// Values read back from MySQL are plain numbers
+		amount, err := strconv.ParseFloat(current[i].String, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("column %s: invalid amount %q", col, current[i].String)
+		}
+		var existing *float64
+		if shadow[i].Valid {
+			if value, err := strconv.ParseFloat(shadow[i].String, 64); err == nil {
+				existing = &value
+			}
+		}
+		if err := guard.Check(table, col, colConfig, amount, existing); err != nil {
+			continue
+		}
+		if err := converter.CheckBounds(converter.SourceOutbox, table, col, colConfig, amount); err != nil {
+			continue
+		}
+		literal, err := parser.FormatShadowValue(colConfig, conversion.RoundingStrategy, conversion.Precision, converter.ToIDN(table, col, amount, conversion.Ratio))
+		if err != nil {
+			return nil, nil, fmt.Errorf("column %s: %w", col, err)
+		}
+		assignments = append(assignments, target)
+		args = append(args, literal)
+	}
+	return assignments, args, nil
+}
@@ -0,0 +1,40 @@
+// Package outbox fills the shadow columns of tables with write_mode async. The
+// proxy forwards their statements untouched and enqueues a task naming the
+// written row; workers convert the row's amounts and update its shadow columns.
+package outbox
+
+import (
+	"context"
+	"time"
+)
+
+// Task names a row whose shadow columns need updating
+type Task struct {
+	Table     string `json:"table"`
+	KeyColumn string `json:"key_column"`
+	Key       string `json:"key"`
+	// Values are the source values the statement wrote, nil for NULL. They
+	// record what was written; workers convert the row's current values so
+	// tasks applied twice or out of order leave the latest amount.
+	Values     map[string]*string `json:"values"`
+	EnqueuedAt time.Time          `json:"enqueued_at"`
+}
+
+// Delivery is a task read by a worker, acknowledged once it is applied
+type Delivery struct {
+	ID   string
+	Task Task
+}
+
+// Queue is a durable queue of tasks shared by the proxies and workers
+type Queue interface {
+	// Enqueue adds a task
+	Enqueue(ctx context.Context, task Task) error
+	// Read returns up to count tasks for a consumer, retrying tasks other
+	// consumers left unacknowledged before new ones
+	Read(ctx context.Context, consumer string, count int) ([]Delivery, error)
+	// Ack removes applied tasks
+	Ack(ctx context.Context, ids ...string) error
+	// Pending returns the number of tasks not yet acknowledged
+	Pending(ctx context.Context) (int64, error)
+}
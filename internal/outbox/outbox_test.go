@@ -0,0 +1,128 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeApplier fails the tasks of one table
+type fakeApplier struct {
+	failTable string
+}
+
+func (a *fakeApplier) Apply(ctx context.Context, task Task) error {
+	if task.Table == a.failTable {
+		return errors.New("deadlock")
+	}
+	return nil
+}
+
+func TestWorker_AcknowledgesAppliedTasks(t *testing.T) {
+	q := NewQueue(queue.NewMemory(queue.Options{Block: 10 * time.Millisecond}))
+	for _, task := range []Task{
+		{Table: "orders", Key: "1", EnqueuedAt: time.Now()},
+		{Table: "payments", Key: "2", EnqueuedAt: time.Now()},
+		{Table: "orders", Key: "3", EnqueuedAt: time.Now()},
+	} {
+		require.NoError(t, q.Enqueue(context.Background(), task))
+	}
+	w := NewWorker(q, &fakeApplier{failTable: "payments"}, config.OutboxConfig{Workers: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	// The failed task stays unacknowledged so it is retried
+	assert.Eventually(t, func() bool {
+		pending, err := q.Pending(context.Background())
+		return err == nil && pending == 1
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	deliveries, err := q.Read(context.Background(), "test", 10)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries, "the failed task is not idle yet")
+}
+
+func TestTaskQueue_DropsMalformedTasks(t *testing.T) {
+	memory := queue.NewMemory(queue.Options{Block: time.Millisecond})
+	q := NewQueue(memory)
+	_, err := memory.Publish(context.Background(), []byte("not json"))
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(context.Background(), Task{Table: "orders", Key: "1"}))
+
+	deliveries, err := q.Read(context.Background(), "worker-1", 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "orders", deliveries[0].Task.Table)
+
+	pending, err := q.Pending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending)
+}
+
+func TestShadowAssignments(t *testing.T) {
+	tableConfig := config.TableConfig{Columns: map[string]config.ColumnConfig{
+		"total_amount": {TargetColumn: "total_amount_idn", TargetType: "DECIMAL(19,4)", Precision: 4},
+		"discount":     {TargetColumn: "discount_idn", TargetType: "DECIMAL(19,4)", Precision: 4, NullPolicy: config.NullPolicySkip},
+		"shipping_fee": {TargetColumn: "shipping_fee_idn", TargetType: "DECIMAL(19,4)", Precision: 4, NullPolicy: config.NullPolicyZero},
+		"tax":          {TargetColumn: "tax_idn", TargetType: "DECIMAL(19,4)", Precision: 4},
+	}}
+	conversion := config.ConversionConfig{Ratio: 1000, RoundingStrategy: "BANKERS_ROUND"}
+
+	assignments, args, err := shadowAssignments("orders", tableConfig, conversion,
+		[]string{"discount", "shipping_fee", "tax", "total_amount"},
+		[]sql.NullString{{}, {}, {}, {String: "1500500", Valid: true}}, make([]sql.NullString, 4))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"`shipping_fee_idn` = ?", "`tax_idn` = ?", "`total_amount_idn` = ?"}, assignments)
+	assert.Equal(t, []interface{}{"0.0000", nil, "1500.5000"}, args)
+
+	_, _, err = shadowAssignments("orders", tableConfig, conversion, []string{"total_amount"},
+		[]sql.NullString{{String: "n/a", Valid: true}}, make([]sql.NullString, 1))
+	assert.ErrorContains(t, err, "invalid amount")
+}
+
+func TestShadowAssignments_Guard(t *testing.T) {
+	tableConfig := config.TableConfig{Columns: map[string]config.ColumnConfig{
+		"total_amount": {TargetColumn: "total_amount_idn", TargetType: "DECIMAL(19,4)", Precision: 4},
+		"tax":          {TargetColumn: "tax_idn", TargetType: "DECIMAL(19,4)", Precision: 4},
+	}}
+	conversion := config.ConversionConfig{Ratio: 1000, Precision: 4, RoundingStrategy: "BANKERS_ROUND",
+		Guard: config.GuardConfig{Policy: config.GuardReject}}
+
+	// The source holds its own shadow value: converting it again is refused
+	assignments, args, err := shadowAssignments("orders", tableConfig, conversion,
+		[]string{"tax", "total_amount"},
+		[]sql.NullString{{String: "1500500", Valid: true}, {String: "1500.5", Valid: true}},
+		[]sql.NullString{{}, {String: "1500.5000", Valid: true}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"`tax_idn` = ?"}, assignments)
+	assert.Equal(t, []interface{}{"1500.5000"}, args)
+
+	// Amounts above the column's maximum are left unconverted too
+	max := 1e6
+	tableConfig.Columns["tax"] = config.ColumnConfig{TargetColumn: "tax_idn", TargetType: "DECIMAL(19,4)", Precision: 4, BoundsMax: &max}
+	assignments, _, err = shadowAssignments("orders", tableConfig, conversion,
+		[]string{"tax"}, []sql.NullString{{String: "1500500", Valid: true}}, make([]sql.NullString, 1))
+	require.NoError(t, err)
+	assert.Empty(t, assignments)
+
+	conversion.Guard.Policy = config.GuardFlag
+	assignments, _, err = shadowAssignments("orders", tableConfig, conversion,
+		[]string{"total_amount"}, []sql.NullString{{String: "1500.5", Valid: true}},
+		[]sql.NullString{{String: "1500.5000", Valid: true}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"`total_amount_idn` = ?"}, assignments)
+}
@@ -0,0 +1,90 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/queue"
+	"github.com/redis/go-redis/v9"
+)
+
+// TaskQueue carries tasks as JSON messages of a work queue
+type TaskQueue struct {
+	queue queue.Queue
+}
+
+// NewQueue returns a task queue on q
+func NewQueue(q queue.Queue) *TaskQueue {
+	return &TaskQueue{queue: q}
+}
+
+// NewRedisQueue returns a task queue on the configured Redis stream with
+// defaults applied
+func NewRedisQueue(client redis.UniversalClient, cfg config.OutboxConfig) *TaskQueue {
+	opts := queue.Options{
+		Stream:    cfg.Stream,
+		Group:     cfg.Group,
+		MaxLen:    cfg.MaxLen,
+		ClaimIdle: cfg.ClaimIdle,
+	}
+	if opts.Stream == "" {
+		opts.Stream = config.DefaultOutboxStream
+	}
+	if opts.Group == "" {
+		opts.Group = config.DefaultOutboxGroup
+	}
+	if opts.MaxLen <= 0 {
+		opts.MaxLen = config.DefaultOutboxMaxLen
+	}
+	if opts.ClaimIdle <= 0 {
+		opts.ClaimIdle = config.DefaultOutboxClaimIdle
+	}
+	return NewQueue(queue.NewRedis(client, opts))
+}
+
+// Enqueue adds a task
+func (q *TaskQueue) Enqueue(ctx context.Context, task Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox task: %w", err)
+	}
+	if _, err := q.queue.Publish(ctx, data); err != nil {
+		return fmt.Errorf("failed to enqueue outbox task: %w", err)
+	}
+	return nil
+}
+
+// Read returns up to count tasks for a consumer. Messages that are not tasks
+// can never be applied and are dropped.
+func (q *TaskQueue) Read(ctx context.Context, consumer string, count int) ([]Delivery, error) {
+	messages, err := q.queue.Consume(ctx, consumer, count)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, 0, len(messages))
+	for _, msg := range messages {
+		var task Task
+		if err := json.Unmarshal(msg.Payload, &task); err != nil {
+			logger.Error("Dropping malformed outbox task", "id", msg.ID, "error", err)
+			if err := q.queue.Ack(ctx, msg.ID); err != nil {
+				logger.Warn("Failed to drop malformed outbox task", "id", msg.ID, "error", err)
+			}
+			continue
+		}
+		deliveries = append(deliveries, Delivery{ID: msg.ID, Task: task})
+	}
+	return deliveries, nil
+}
+
+// Ack removes applied tasks
+func (q *TaskQueue) Ack(ctx context.Context, ids ...string) error {
+	return q.queue.Ack(ctx, ids...)
+}
+
+// Pending returns the number of tasks not yet applied
+func (q *TaskQueue) Pending(ctx context.Context) (int64, error) {
+	return q.queue.Len(ctx)
+}
@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+)
+
+// Outcomes of outbox tasks, also the values of the metric's outcome label
+const (
+	OutcomeEnqueued      = "enqueued"
+	OutcomeEnqueueFailed = "enqueue_failed"
+	OutcomeApplied       = "applied"
+	OutcomeFailed        = "failed"
+)
+
+// pendingInterval is how often the backlog gauge is refreshed
+const pendingInterval = 10 * time.Second
+
+// retryDelay is how long a worker waits after the queue failed
+const retryDelay = time.Second
+
+// Applier writes the shadow columns a task names
+type Applier interface {
+	Apply(ctx context.Context, task Task) error
+}
+
+// Worker applies queued tasks. Failed tasks stay unacknowledged and are
+// retried by any worker once they have been idle for claim_idle.
+type Worker struct {
+	queue     Queue
+	applier   Applier
+	workers   int
+	batchSize int
+	// name prefixes the consumer names, which must be unique across proxies
+	name string
+}
+
+// NewWorker returns a worker for the outbox config with defaults applied
+func NewWorker(queue Queue, applier Applier, cfg config.OutboxConfig) *Worker {
+	w := &Worker{
+		queue:     queue,
+		applier:   applier,
+		workers:   cfg.Workers,
+		batchSize: cfg.BatchSize,
+	}
+	if w.workers <= 0 {
+		w.workers = config.DefaultOutboxWorkers
+	}
+	if w.batchSize <= 0 {
+		w.batchSize = config.DefaultOutboxBatchSize
+	}
+	host, _ := os.Hostname()
+	w.name = fmt.Sprintf("%s-%d", host, os.Getpid())
+	return w
+}
+
+// Run applies tasks until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.workers; i++ {
+		wg.Add(1)
+		go func(consumer string) {
+			defer wg.Done()
+			w.consume(ctx, consumer)
+		}(fmt.Sprintf("%s-%d", w.name, i))
+	}
+
+	ticker := time.NewTicker(pendingInterval)
+	defer ticker.Stop()
+	for {
+		w.updatePending(ctx)
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// consume reads and applies batches of tasks as one consumer
+func (w *Worker) consume(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		deliveries, err := w.queue.Read(ctx, consumer, w.batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("Failed to read outbox, retrying", "consumer", consumer, "error", err)
+				sleep(ctx, retryDelay)
+			}
+			continue
+		}
+		w.apply(ctx, deliveries)
+	}
+}
+
+// apply applies a batch and acknowledges the tasks that succeeded
+func (w *Worker) apply(ctx context.Context, deliveries []Delivery) {
+	applied := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		if err := w.applier.Apply(ctx, d.Task); err != nil {
+			metrics.RecordOutboxTask(d.Task.Table, OutcomeFailed)
+			logger.Warn("Failed to apply outbox task, retrying later", "table", d.Task.Table,
+				"key", d.Task.Key, "id", d.ID, "error", err)
+			continue
+		}
+		metrics.RecordOutboxTask(d.Task.Table, OutcomeApplied)
+		metrics.RecordOutboxLag(d.Task.Table, time.Since(d.Task.EnqueuedAt))
+		applied = append(applied, d.ID)
+	}
+	if err := w.queue.Ack(ctx, applied...); err != nil {
+		// The tasks are applied again once claimed, which is harmless
+		logger.Warn("Failed to acknowledge outbox tasks", "count", len(applied), "error", err)
+	}
+}
+
+// updatePending refreshes the backlog gauge
+func (w *Worker) updatePending(ctx context.Context) {
+	pending, err := w.queue.Pending(ctx)
+	if err != nil {
+		logger.Debug("Failed to read outbox backlog", "error", err)
+		return
+	}
+	metrics.SetOutboxPending(pending)
+}
+
+// sleep waits for d or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,447 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+// Schema change kinds
+const (
+	SchemaChangeCreate = "create"
+	SchemaChangeAlter  = "alter"
+	SchemaChangeDrop   = "drop"
+	SchemaChangeRename = "rename"
+)
+
+// Column change actions within an ALTER TABLE
+const (
+	ColumnAdd    = "add"
+	ColumnDrop   = "drop"
+	ColumnModify = "modify"
+	ColumnRename = "rename"
+)
+
+// ShadowColumnSuffix is appended to a currency column's name to form the name
+// of its proposed shadow column
+const ShadowColumnSuffix = "_idn"
+
+// SchemaChange is a table-level effect of a DDL statement
+type SchemaChange struct {
+	Kind     string
+	Database string // qualifier of the table name, empty when unqualified
+	Table    string
+	NewTable string // target of RENAME TABLE / ALTER TABLE ... RENAME TO
+	Columns  []ColumnChange
+}
+
+// ColumnChange is a column added, dropped, modified or renamed by a DDL statement
+type ColumnChange struct {
+	Action  string
+	Name    string
+	NewName string // CHANGE and RENAME COLUMN
+	Type    string // column type as written, e.g. BIGINT or DECIMAL(19,4)
+}
+
+// ParseSchemaChange extracts the tables and columns a DDL statement changes.
+// It handles CREATE TABLE, ALTER TABLE, DROP TABLE and RENAME TABLE, which
+// sqlparser only partially understands; ok is false for any other statement.
+func ParseSchemaChange(query string) ([]SchemaChange, bool) {
+	tokens := ddlTokens(query)
+	if len(tokens) < 3 {
+		return nil, false
+	}
+
+	switch strings.ToUpper(tokens[0]) {
+	case "ALTER":
+		rest := skipWords(tokens[1:], "ONLINE", "IGNORE")
+		if len(rest) < 2 || !strings.EqualFold(rest[0], "TABLE") {
+			return nil, false
+		}
+		change := SchemaChange{Kind: SchemaChangeAlter}
+		change.Database, change.Table = splitTableName(rest[1])
+		for _, spec := range splitTopLevel(rest[2:]) {
+			parseAlterSpec(&change, spec)
+		}
+		return []SchemaChange{change}, true
+
+	case "CREATE":
+		rest := skipWords(tokens[1:], "TEMPORARY")
+		if len(rest) < 2 || !strings.EqualFold(rest[0], "TABLE") {
+			return nil, false
+		}
+		rest = skipIfExists(rest[1:], "IF", "NOT", "EXISTS")
+		if len(rest) == 0 {
+			return nil, false
+		}
+		change := SchemaChange{Kind: SchemaChangeCreate}
+		change.Database, change.Table = splitTableName(rest[0])
+		if len(rest) > 1 && isGroup(rest[1]) {
+			for _, def := range splitTopLevel(ddlTokens(ungroup(rest[1]))) {
+				if col, ok := parseColumnDefinition(def); ok {
+					col.Action = ColumnAdd
+					change.Columns = append(change.Columns, col)
+				}
+			}
+		}
+		return []SchemaChange{change}, true
+
+	case "DROP":
+		rest := skipWords(tokens[1:], "TEMPORARY")
+		if len(rest) < 2 || !strings.EqualFold(rest[0], "TABLE") {
+			return nil, false
+		}
+		var changes []SchemaChange
+		for _, names := range splitTopLevel(skipIfExists(rest[1:], "IF", "EXISTS")) {
+			if len(names) == 0 {
+				continue
+			}
+			change := SchemaChange{Kind: SchemaChangeDrop}
+			change.Database, change.Table = splitTableName(names[0])
+			changes = append(changes, change)
+		}
+		return changes, len(changes) > 0
+
+	case "RENAME":
+		if !strings.EqualFold(tokens[1], "TABLE") {
+			return nil, false
+		}
+		var changes []SchemaChange
+		for _, pair := range splitTopLevel(tokens[2:]) {
+			if len(pair) != 3 || !strings.EqualFold(pair[1], "TO") {
+				continue
+			}
+			change := SchemaChange{Kind: SchemaChangeRename}
+			change.Database, change.Table = splitTableName(pair[0])
+			_, change.NewTable = splitTableName(pair[2])
+			changes = append(changes, change)
+		}
+		return changes, len(changes) > 0
+	}
+
+	return nil, false
+}
+
+// parseAlterSpec records the column changes of one ALTER TABLE specification
+func parseAlterSpec(change *SchemaChange, spec []string) {
+	if len(spec) < 2 {
+		return
+	}
+
+	switch strings.ToUpper(spec[0]) {
+	case "ADD":
+		rest := skipWords(spec[1:], "COLUMN")
+		if len(rest) > 0 && isGroup(rest[0]) {
+			// ADD [COLUMN] (col1 type, col2 type)
+			for _, def := range splitTopLevel(ddlTokens(ungroup(rest[0]))) {
+				if col, ok := parseColumnDefinition(def); ok {
+					col.Action = ColumnAdd
+					change.Columns = append(change.Columns, col)
+				}
+			}
+			return
+		}
+		if col, ok := parseColumnDefinition(rest); ok {
+			col.Action = ColumnAdd
+			change.Columns = append(change.Columns, col)
+		}
+
+	case "DROP":
+		rest := skipWords(spec[1:], "COLUMN")
+		if len(rest) > 0 && !isIndexKeyword(rest[0]) {
+			change.Columns = append(change.Columns, ColumnChange{Action: ColumnDrop, Name: rest[0]})
+		}
+
+	case "MODIFY":
+		if col, ok := parseColumnDefinition(skipWords(spec[1:], "COLUMN")); ok {
+			col.Action = ColumnModify
+			change.Columns = append(change.Columns, col)
+		}
+
+	case "CHANGE":
+		rest := skipWords(spec[1:], "COLUMN")
+		if len(rest) < 3 {
+			return
+		}
+		if col, ok := parseColumnDefinition(rest[1:]); ok {
+			change.Columns = append(change.Columns, ColumnChange{
+				Action: ColumnModify, Name: rest[0], NewName: col.Name, Type: col.Type,
+			})
+		}
+
+	case "RENAME":
+		rest := spec[1:]
+		if strings.EqualFold(rest[0], "COLUMN") {
+			if len(rest) == 4 && strings.EqualFold(rest[2], "TO") {
+				change.Columns = append(change.Columns, ColumnChange{Action: ColumnRename, Name: rest[1], NewName: rest[3]})
+			}
+			return
+		}
+		rest = skipWords(rest, "TO", "AS")
+		if len(rest) == 1 {
+			_, change.NewTable = splitTableName(rest[0])
+		}
+	}
+}
+
+// parseColumnDefinition reads "name type [(...)] [UNSIGNED] ..." and skips
+// index and constraint definitions
+func parseColumnDefinition(def []string) (ColumnChange, bool) {
+	if len(def) < 2 || isIndexKeyword(def[0]) || isGroup(def[1]) {
+		return ColumnChange{}, false
+	}
+
+	colType := strings.ToUpper(def[1])
+	i := 2
+	if i < len(def) && isGroup(def[i]) {
+		colType += def[i]
+		i++
+	}
+	if i < len(def) && strings.EqualFold(def[i], "UNSIGNED") {
+		colType += " UNSIGNED"
+	}
+	return ColumnChange{Name: def[0], Type: colType}, true
+}
+
+// isIndexKeyword reports whether a definition starts an index or constraint
+// rather than a column
+func isIndexKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "INDEX", "KEY", "UNIQUE", "PRIMARY", "FULLTEXT", "SPATIAL",
+		"CONSTRAINT", "FOREIGN", "CHECK", "PARTITION":
+		return true
+	}
+	return false
+}
+
+// monetaryNameParts are column name fragments that suggest a currency amount
+var monetaryNameParts = []string{
+	"amount", "price", "total", "cost", "fee", "balance", "refund", "tax",
+	"discount", "payment", "salary", "charge", "revenue", "paid", "credit",
+	"debit", "wage", "budget",
+}
+
+// LooksMonetary reports whether a column appears to hold a currency amount:
+// an exact numeric type and a name containing a monetary word. Shadow columns
+// (ending in ShadowColumnSuffix) never match.
+func LooksMonetary(column, columnType string) bool {
+	name := strings.ToLower(NormalizeTableName(column))
+	if strings.HasSuffix(name, ShadowColumnSuffix) {
+		return false
+	}
+
+	base := strings.ToUpper(columnType)
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	switch base {
+	case "INT", "INTEGER", "BIGINT", "MEDIUMINT", "DECIMAL", "NUMERIC", "DEC", "FIXED":
+	default:
+		return false
+	}
+
+	for _, part := range monetaryNameParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShadowProposal is a suggested currency column config for a column added by DDL
+type ShadowProposal struct {
+	Table     string              `json:"table"`
+	Column    string              `json:"column"`
+	Config    config.ColumnConfig `json:"config"`
+	ShadowDDL string              `json:"shadow_ddl"` // statement that adds the shadow column
+	Timestamp time.Time           `json:"timestamp"`
+}
+
+// ProposeShadowColumn builds the column config and shadow column DDL for a
+// currency column, using the conversion defaults for rounding and precision
+func ProposeShadowColumn(table string, col ColumnChange, conv config.ConversionConfig) ShadowProposal {
+	// Keep every integer digit the source type can hold; dividing by the
+	// ratio only shortens values
+	digits := 19
+	switch {
+	case strings.HasPrefix(col.Type, "INT"):
+		digits = 10
+	case strings.HasPrefix(col.Type, "MEDIUMINT"):
+		digits = 8
+	default:
+		if precision, scale, ok := config.ParseDecimalType(col.Type); ok {
+			digits = precision - scale
+		}
+	}
+	targetType := fmt.Sprintf("DECIMAL(%d,%d)", digits+conv.Precision, conv.Precision)
+	target := NormalizeTableName(col.Name) + ShadowColumnSuffix
+
+	return ShadowProposal{
+		Table:  table,
+		Column: NormalizeTableName(col.Name),
+		Config: config.ColumnConfig{
+			SourceColumn:     NormalizeTableName(col.Name),
+			TargetColumn:     target,
+			SourceType:       col.Type,
+			TargetType:       targetType,
+			RoundingStrategy: conv.RoundingStrategy,
+			Precision:        conv.Precision,
+		},
+		ShadowDDL: fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s NULL", table, target, targetType),
+		Timestamp: time.Now(),
+	}
+}
+
+// ddlTokens splits a statement into words. Comments are dropped, backtick
+// quotes are removed, string literals become "?", a parenthesized group stays
+// a single token and tokenizing stops at ';'.
+func ddlTokens(query string) []string {
+	var tokens []string
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+
+		case c == ';':
+			return tokens
+
+		case c == ',':
+			tokens = append(tokens, ",")
+
+		case c == '-' && i+1 < len(query) && query[i+1] == '-', c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 3
+
+		case c == '\'' || c == '"':
+			i = skipQuoted(query, i)
+			tokens = append(tokens, "?")
+
+		case c == '(':
+			start := i
+			depth := 0
+			for ; i < len(query); i++ {
+				switch query[i] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				case '\'', '"', '`':
+					i = skipQuoted(query, i)
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if i >= len(query) {
+				return tokens
+			}
+			tokens = append(tokens, query[start:i+1])
+
+		default:
+			// A word; backticked parts are unquoted so `db`.`table` reads as db.table
+			var word strings.Builder
+			for ; i < len(query) && !strings.ContainsRune(" \t\n\r(),;'\"", rune(query[i])); i++ {
+				if query[i] != '`' {
+					word.WriteByte(query[i])
+					continue
+				}
+				end := strings.IndexByte(query[i+1:], '`')
+				if end < 0 {
+					return tokens
+				}
+				word.WriteString(query[i+1 : i+1+end])
+				i += end + 1
+			}
+			i--
+			tokens = append(tokens, word.String())
+		}
+	}
+	return tokens
+}
+
+// skipQuoted returns the index of the quote closing the one at i
+func skipQuoted(s string, i int) int {
+	quote := s[i]
+	for i++; i < len(s); i++ {
+		if s[i] == '\\' && quote != '`' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// splitTopLevel splits tokens at "," into groups
+func splitTopLevel(tokens []string) [][]string {
+	var groups [][]string
+	var current []string
+	for _, token := range tokens {
+		if token == "," {
+			groups = append(groups, current)
+			current = nil
+			continue
+		}
+		current = append(current, token)
+	}
+	return append(groups, current)
+}
+
+// skipWords drops leading tokens that equal any of words
+func skipWords(tokens []string, words ...string) []string {
+	for len(tokens) > 0 {
+		skipped := false
+		for _, word := range words {
+			if strings.EqualFold(tokens[0], word) {
+				tokens = tokens[1:]
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			break
+		}
+	}
+	return tokens
+}
+
+// skipIfExists drops a leading IF [NOT] EXISTS clause
+func skipIfExists(tokens []string, words ...string) []string {
+	if len(tokens) < len(words) {
+		return tokens
+	}
+	for i, word := range words {
+		if !strings.EqualFold(tokens[i], word) {
+			return tokens
+		}
+	}
+	return tokens[len(words):]
+}
+
+// splitTableName splits "db.table" into its parts
+func splitTableName(name string) (string, string) {
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		return NormalizeTableName(name[:dot]), NormalizeTableName(name[dot+1:])
+	}
+	return "", NormalizeTableName(name)
+}
+
+func isGroup(token string) bool {
+	return strings.HasPrefix(token, "(")
+}
+
+func ungroup(token string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(token, "("), ")")
+}
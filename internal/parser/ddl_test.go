@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchemaChange(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []SchemaChange
+	}{
+		{
+			name:  "alter add column",
+			query: "ALTER TABLE orders ADD COLUMN refund_amount BIGINT NOT NULL DEFAULT 0 AFTER total_amount",
+			want: []SchemaChange{{Kind: SchemaChangeAlter, Table: "orders", Columns: []ColumnChange{
+				{Action: ColumnAdd, Name: "refund_amount", Type: "BIGINT"},
+			}}},
+		},
+		{
+			name:  "qualified and quoted with several specs",
+			query: "ALTER TABLE `shop`.`orders` ADD `tax` DECIMAL(19, 4) UNSIGNED, ADD INDEX idx_tax (tax), DROP COLUMN legacy_total, CHANGE fee shipping_fee INT",
+			want: []SchemaChange{{Kind: SchemaChangeAlter, Database: "shop", Table: "orders", Columns: []ColumnChange{
+				{Action: ColumnAdd, Name: "tax", Type: "DECIMAL(19, 4) UNSIGNED"},
+				{Action: ColumnDrop, Name: "legacy_total"},
+				{Action: ColumnModify, Name: "fee", NewName: "shipping_fee", Type: "INT"},
+			}}},
+		},
+		{
+			name:  "add column list and rename",
+			query: "/* migration 42 */ ALTER TABLE invoices ADD (discount INT, note VARCHAR(255) COMMENT 'a, b'), RENAME COLUMN grand_total TO total",
+			want: []SchemaChange{{Kind: SchemaChangeAlter, Table: "invoices", Columns: []ColumnChange{
+				{Action: ColumnAdd, Name: "discount", Type: "INT"},
+				{Action: ColumnAdd, Name: "note", Type: "VARCHAR(255)"},
+				{Action: ColumnRename, Name: "grand_total", NewName: "total"},
+			}}},
+		},
+		{
+			name:  "create table",
+			query: "CREATE TABLE IF NOT EXISTS refunds (id BIGINT PRIMARY KEY, amount BIGINT, PRIMARY KEY (id), KEY idx (amount))",
+			want: []SchemaChange{{Kind: SchemaChangeCreate, Table: "refunds", Columns: []ColumnChange{
+				{Action: ColumnAdd, Name: "id", Type: "BIGINT"},
+				{Action: ColumnAdd, Name: "amount", Type: "BIGINT"},
+			}}},
+		},
+		{
+			name:  "drop tables",
+			query: "DROP TABLE IF EXISTS orders, shop.invoices",
+			want: []SchemaChange{
+				{Kind: SchemaChangeDrop, Table: "orders"},
+				{Kind: SchemaChangeDrop, Database: "shop", Table: "invoices"},
+			},
+		},
+		{
+			name:  "rename table",
+			query: "RENAME TABLE orders TO orders_old",
+			want:  []SchemaChange{{Kind: SchemaChangeRename, Table: "orders", NewTable: "orders_old"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, ok := ParseSchemaChange(tt.query)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, changes)
+		})
+	}
+
+	for _, query := range []string{"SELECT * FROM orders", "DROP INDEX idx ON orders", "CREATE VIEW v AS SELECT 1"} {
+		_, ok := ParseSchemaChange(query)
+		assert.False(t, ok, query)
+	}
+}
+
+func TestLooksMonetary(t *testing.T) {
+	assert.True(t, LooksMonetary("refund_amount", "BIGINT"))
+	assert.True(t, LooksMonetary("UnitPrice", "DECIMAL(12,2)"))
+	assert.True(t, LooksMonetary("`shipping_fee`", "INT UNSIGNED"))
+	assert.False(t, LooksMonetary("refund_amount_idn", "DECIMAL(19,4)"))
+	assert.False(t, LooksMonetary("refund_amount", "VARCHAR(20)"))
+	assert.False(t, LooksMonetary("total_weight_ratio", "DOUBLE"))
+	assert.False(t, LooksMonetary("quantity", "INT"))
+}
+
+func TestProposeShadowColumn(t *testing.T) {
+	conv := config.ConversionConfig{Ratio: 1000, Precision: 4, RoundingStrategy: "BANKERS_ROUND"}
+
+	proposal := ProposeShadowColumn("orders", ColumnChange{Action: ColumnAdd, Name: "refund_amount", Type: "BIGINT"}, conv)
+	assert.Equal(t, "refund_amount", proposal.Column)
+	assert.Equal(t, config.ColumnConfig{
+		SourceColumn:     "refund_amount",
+		TargetColumn:     "refund_amount_idn",
+		SourceType:       "BIGINT",
+		TargetType:       "DECIMAL(23,4)",
+		RoundingStrategy: "BANKERS_ROUND",
+		Precision:        4,
+	}, proposal.Config)
+	assert.Equal(t, "ALTER TABLE `orders` ADD COLUMN `refund_amount_idn` DECIMAL(23,4) NULL", proposal.ShadowDDL)
+
+	proposal = ProposeShadowColumn("orders", ColumnChange{Name: "fee", Type: "DECIMAL(12,2)"}, conv)
+	assert.Equal(t, "DECIMAL(14,4)", proposal.Config.TargetType)
+}
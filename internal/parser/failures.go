@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+)
+
+// maxFingerprintLength caps the stored fingerprint so huge statements
+// don't bloat the failure log
+const maxFingerprintLength = 1024
+
+// Parser failure reasons
+const (
+	FailureReasonEmpty       = "empty"
+	FailureReasonSyntax      = "syntax_error"
+	FailureReasonUnsupported = "unsupported"
+	FailureReasonOther       = "other"
+)
+
+// FailureSample is an unparseable statement, reduced to its fingerprint so
+// no literal values are retained
+type FailureSample struct {
+	Fingerprint string    `json:"fingerprint"`
+	Reason      string    `json:"reason"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// FailureLog is a fixed-size ring buffer of the most recent parser failures
+type FailureLog struct {
+	mu      sync.Mutex
+	samples []FailureSample
+	next    int
+	full    bool
+}
+
+// NewFailureLog creates a failure log holding the last size samples
+func NewFailureLog(size int) *FailureLog {
+	if size <= 0 {
+		size = 1
+	}
+	return &FailureLog{samples: make([]FailureSample, size)}
+}
+
+// Add appends a sample, overwriting the oldest one when full
+func (l *FailureLog) Add(sample FailureSample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.samples[l.next] = sample
+	l.next = (l.next + 1) % len(l.samples)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Recent returns the stored samples, newest first
+func (l *FailureLog) Recent() []FailureSample {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.samples)
+	}
+
+	recent := make([]FailureSample, 0, n)
+	for i := 1; i <= n; i++ {
+		idx := (l.next - i + len(l.samples)) % len(l.samples)
+		recent = append(recent, l.samples[idx])
+	}
+	return recent
+}
+
+// defaultFailureLog collects failures from every parser in the process
+var defaultFailureLog = NewFailureLog(100)
+
+// RecentFailures returns the most recent unparseable statements, newest first
+func RecentFailures() []FailureSample {
+	return defaultFailureLog.Recent()
+}
+
+// recordFailure counts a parser failure and keeps its fingerprint
+func recordFailure(query string, err error) {
+	fingerprint := Fingerprint(query)
+	reason := FailureReason(err)
+	if fingerprint == "" {
+		reason = FailureReasonEmpty
+	}
+	metrics.RecordParserFailure(reason)
+
+	if len(fingerprint) > maxFingerprintLength {
+		fingerprint = fingerprint[:maxFingerprintLength]
+	}
+	defaultFailureLog.Add(FailureSample{
+		Fingerprint: fingerprint,
+		Reason:      reason,
+		Timestamp:   time.Now(),
+	})
+}
+
+// FailureReason classifies a sqlparser error into a metric-friendly reason.
+// Statements that are empty once comments are stripped are reported as "empty"
+// by the caller, since sqlparser reports them as syntax errors.
+func FailureReason(err error) string {
+	if err == nil {
+		return FailureReasonOther
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "syntax error"):
+		return FailureReasonSyntax
+	case strings.Contains(msg, "unsupported"), strings.Contains(msg, "not supported"):
+		return FailureReasonUnsupported
+	default:
+		return FailureReasonOther
+	}
+}
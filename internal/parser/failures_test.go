@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureLogRing(t *testing.T) {
+	log := NewFailureLog(3)
+	assert.Empty(t, log.Recent())
+
+	for _, fp := range []string{"a", "b", "c", "d"} {
+		log.Add(FailureSample{Fingerprint: fp})
+	}
+
+	recent := log.Recent()
+	require.Len(t, recent, 3)
+	assert.Equal(t, "d", recent[0].Fingerprint)
+	assert.Equal(t, "c", recent[1].Fingerprint)
+	assert.Equal(t, "b", recent[2].Fingerprint)
+}
+
+func TestParseRecordsFailures(t *testing.T) {
+	parser := NewParser(getTestConfig())
+
+	_, err := parser.Parse("INSERT INTO orders (total_amount) VALUES (5000) RETURNING id")
+	require.Error(t, err)
+
+	recent := RecentFailures()
+	require.NotEmpty(t, recent)
+	assert.Equal(t, "INSERT INTO orders (total_amount) VALUES (?) RETURNING id", recent[0].Fingerprint)
+	assert.Equal(t, FailureReasonSyntax, recent[0].Reason)
+
+	_, err = parser.Parse("/* nothing */")
+	require.Error(t, err)
+	assert.Equal(t, FailureReasonEmpty, RecentFailures()[0].Reason)
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"strings"
+)
+
+// Fingerprint normalizes a query into a digest text: literals are replaced
+// with '?', comments are removed and whitespace is collapsed, so statements
+// that differ only in their values share the same fingerprint
+func Fingerprint(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	pendingSpace := false
+	writeByte := func(c byte) {
+		if pendingSpace && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		pendingSpace = false
+		b.WriteByte(c)
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pendingSpace = true
+
+		case c == '\'' || c == '"':
+			// Quoted string literal, honoring backslash and doubled-quote escapes
+			quote := c
+			i++
+			for i < len(query) {
+				if query[i] == '\\' {
+					i += 2
+					continue
+				}
+				if query[i] == quote {
+					if i+1 < len(query) && query[i+1] == quote {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			writeByte('?')
+
+		case c == '`':
+			// Quoted identifier, copied verbatim
+			start := i
+			i++
+			for i < len(query) && query[i] != '`' {
+				i++
+			}
+			end := i + 1
+			if end > len(query) {
+				end = len(query)
+			}
+			writeByte(query[start])
+			b.WriteString(query[start+1 : end])
+
+		case c == '-' && i+1 < len(query) && query[i+1] == '-',
+			c == '#':
+			// Line comment
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			pendingSpace = true
+
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			// Block comment
+			i += 2
+			for i+1 < len(query) && !(query[i] == '*' && query[i+1] == '/') {
+				i++
+			}
+			i++
+			pendingSpace = true
+
+		case isDigit(c) && !precededByIdentChar(query, i):
+			// Numeric literal (integer, decimal, exponent or hex)
+			for i+1 < len(query) && isNumberChar(query[i+1]) {
+				i++
+			}
+			writeByte('?')
+
+		default:
+			writeByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNumberChar(c byte) bool {
+	return isDigit(c) || c == '.' || c == 'e' || c == 'E' || c == 'x' || c == 'X' ||
+		(c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// precededByIdentChar reports whether the byte at i continues an identifier (e.g. col1)
+func precededByIdentChar(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	p := s[i-1]
+	return p == '_' || isDigit(p) || (p >= 'a' && p <= 'z') || (p >= 'A' && p <= 'Z')
+}
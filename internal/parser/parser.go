@@ -2,9 +2,13 @@ package parser
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/rounding"
 	"github.com/xwb1989/sqlparser"
 )
 
@@ -17,6 +21,7 @@ const (
 	QueryTypeInsert
 	QueryTypeUpdate
 	QueryTypeDelete
+	QueryTypeUse
 )
 
 // ParsedQuery represents a parsed SQL query with metadata
@@ -25,16 +30,60 @@ type ParsedQuery struct {
 	Type            QueryType
 	Statement       sqlparser.Statement
 	TableName       string
+	Database        string // target database of a USE statement
 	CurrencyColumns []string
 	Values          map[string]interface{}
-	NeedsTransform  bool
+	// CaseValues holds the branch values of currency columns assigned a CASE
+	// expression in an UPDATE, in WHEN order followed by ELSE. Converted
+	// values are passed to RewriteForDualWrite under CaseValueKey.
+	CaseValues map[string][]interface{}
+	// RowValues holds the values of currency columns in each row of a
+	// multi-row INSERT ... VALUES; Values keeps the first row's. Converted
+	// values are passed to RewriteForDualWrite under RowValueKey.
+	RowValues      map[string][]interface{}
+	NeedsTransform bool
+
+	// caseExprs are the CASE expressions behind CaseValues
+	caseExprs map[string]*sqlparser.CaseExpr
+	// shadowQualifier qualifies shadow assignments of multi-table UPDATEs
+	shadowQualifier sqlparser.TableName
+}
+
+// CaseValueKey is the converted values key of branch i of a CASE assignment
+func CaseValueKey(column string, branch int) string {
+	return fmt.Sprintf("%s[%d]", column, branch)
+}
+
+// RowValueKey is the converted values key of a column's value in row i of a
+// multi-row INSERT
+func RowValueKey(column string, row int) string {
+	return fmt.Sprintf("%s#%d", column, row)
 }
 
 // Parser handles SQL query parsing and analysis
 type Parser struct {
 	tableConfig config.TablesConfig
+	schema      string // database whose tables the table config applies to
+	currentDB   string // session's current database (COM_INIT_DB / USE)
+	foldCase    bool   // compare table/schema names case-insensitively
+	// roundingStrategy is the default for columns without their own strategy
+	roundingStrategy string
+	// precision is the default for columns without their own precision
+	precision int
+	// columnType returns a target column's live type, overriding target_type
+	columnType ColumnTypeResolver
+	// tableEnabled overrides the enabled flag of table configs
+	tableEnabled TableEnabledResolver
 }
 
+// ColumnTypeResolver returns the type of a column as the server reports it,
+// e.g. decimal(19,2); ok is false when the type is unknown
+type ColumnTypeResolver func(table, column string) (columnType string, ok bool)
+
+// TableEnabledResolver returns whether dual-write is enabled for the configured
+// table, given the enabled flag of its config
+type TableEnabledResolver func(table string, configured bool) bool
+
 // NewParser creates a new SQL parser
 func NewParser(tableConfig config.TablesConfig) *Parser {
 	return &Parser{
@@ -42,12 +91,102 @@ func NewParser(tableConfig config.TablesConfig) *Parser {
 	}
 }
 
+// SetSchema restricts table config resolution to tables in the given database.
+// An empty schema applies the table config regardless of database.
+func (p *Parser) SetSchema(schema string) {
+	p.schema = schema
+}
+
+// SetCurrentDatabase sets the session's current database, used to resolve
+// unqualified table names
+func (p *Parser) SetCurrentDatabase(db string) {
+	p.currentDB = db
+}
+
+// SetLowerCaseTableNames applies the server's lower_case_table_names setting:
+// 0 compares table and schema names case-sensitively, 1 and 2 case-insensitively
+func (p *Parser) SetLowerCaseTableNames(mode int) {
+	p.foldCase = mode != 0
+}
+
+// SetRoundingStrategy sets the default rounding strategy (conversion.rounding_strategy)
+// for currency columns that do not configure their own
+func (p *Parser) SetRoundingStrategy(strategy string) {
+	p.roundingStrategy = strategy
+}
+
+// SetPrecision sets the default precision (conversion.precision) for currency
+// columns that do not configure their own
+func (p *Parser) SetPrecision(precision int) {
+	p.precision = precision
+}
+
+// SetColumnTypeResolver makes shadow values follow the live type of the
+// target column instead of the configured target_type
+func (p *Parser) SetColumnTypeResolver(resolver ColumnTypeResolver) {
+	p.columnType = resolver
+}
+
+// SetTableEnabledResolver lets tables be enabled or disabled at runtime
+// without replacing the table config
+func (p *Parser) SetTableEnabledResolver(resolver TableEnabledResolver) {
+	p.tableEnabled = resolver
+}
+
+// enabled reports whether dual-write applies to the table with config key
+func (p *Parser) enabled(key string, tableConfig config.TableConfig) bool {
+	if p.tableEnabled != nil {
+		return p.tableEnabled(key, tableConfig.Enabled)
+	}
+	return tableConfig.Enabled
+}
+
+// CurrentDatabase returns the session's current database
+func (p *Parser) CurrentDatabase() string {
+	return p.currentDB
+}
+
+// lookupTable resolves the table config for a (possibly qualified) table name
+// and returns its canonical config key. Tables outside the configured schema
+// never match, so a same-named table in an unrelated database is not rewritten.
+func (p *Parser) lookupTable(table sqlparser.TableName) (string, config.TableConfig, bool) {
+	db := NormalizeTableName(table.Qualifier.String())
+	if db == "" {
+		db = p.currentDB
+	}
+
+	// An unknown current database is treated as the configured one
+	if p.schema != "" && db != "" && !p.sameName(db, p.schema) {
+		return "", config.TableConfig{}, false
+	}
+
+	return p.tableConfig.Lookup(table.Name.String(), p.foldCase)
+}
+
+// LookupTable resolves the table config for a table in database (empty for
+// the session's current database) and returns its canonical config key
+func (p *Parser) LookupTable(database, table string) (string, config.TableConfig, bool) {
+	return p.lookupTable(sqlparser.TableName{
+		Qualifier: sqlparser.NewTableIdent(database),
+		Name:      sqlparser.NewTableIdent(table),
+	})
+}
+
+// sameName compares two table or schema names per lower_case_table_names
+func (p *Parser) sameName(a, b string) bool {
+	if p.foldCase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
 // Parse parses a SQL query and returns metadata
 func (p *Parser) Parse(query string) (*ParsedQuery, error) {
 	// Parse SQL using sqlparser
 	stmt, err := sqlparser.Parse(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse query: %w", err)
+		recordFailure(query, err)
+		return nil, errs.Wrap(errs.ParseSyntax, err, "failed to parse query")
 	}
 
 	pq := &ParsedQuery{
@@ -82,6 +221,10 @@ func (p *Parser) Parse(query string) (*ParsedQuery, error) {
 			return nil, err
 		}
 
+	case *sqlparser.Use:
+		pq.Type = QueryTypeUse
+		pq.Database = stmt.DBName.String()
+
 	default:
 		pq.Type = QueryTypeUnknown
 	}
@@ -92,29 +235,27 @@ func (p *Parser) Parse(query string) (*ParsedQuery, error) {
 // analyzeInsert analyzes an INSERT statement
 func (p *Parser) analyzeInsert(stmt *sqlparser.Insert, pq *ParsedQuery) error {
 	// Extract table name
-	tableName := sqlparser.String(stmt.Table)
-	pq.TableName = tableName
+	pq.TableName = NormalizeTableName(stmt.Table.Name.String())
 
 	// Check if this table is configured for transformation
-	tableConfig, exists := p.tableConfig[tableName]
-	if !exists || !tableConfig.Enabled {
+	tableKey, tableConfig, exists := p.lookupTable(stmt.Table)
+	if !exists || !p.enabled(tableKey, tableConfig) {
 		pq.NeedsTransform = false
 		return nil
 	}
+	pq.TableName = tableKey
 
-	// Extract column names
+	// Extract column names, using the canonical config key for currency columns
 	var columns []string
 	if stmt.Columns != nil {
 		for _, col := range stmt.Columns {
-			columns = append(columns, col.String())
-		}
-	}
-
-	// Find currency columns in the INSERT
-	for _, col := range columns {
-		if _, exists := tableConfig.Columns[col]; exists {
-			pq.CurrencyColumns = append(pq.CurrencyColumns, col)
-			pq.NeedsTransform = true
+			name := NormalizeTableName(col.String())
+			if colKey, _, exists := tableConfig.LookupColumn(name); exists {
+				name = colKey
+				pq.CurrencyColumns = append(pq.CurrencyColumns, colKey)
+				pq.NeedsTransform = true
+			}
+			columns = append(columns, name)
 		}
 	}
 
@@ -128,52 +269,176 @@ func (p *Parser) analyzeInsert(stmt *sqlparser.Insert, pq *ParsedQuery) error {
 				}
 			}
 		}
+		// Each row of a multi-row INSERT is converted on its own
+		if len(rows) > 1 {
+			pq.RowValues = make(map[string][]interface{}, len(pq.CurrencyColumns))
+			for i, name := range columns {
+				if _, _, exists := tableConfig.LookupColumn(name); !exists {
+					continue
+				}
+				values := make([]interface{}, len(rows))
+				for r, row := range rows {
+					if i < len(row) {
+						values[r] = extractValue(row[i])
+					}
+				}
+				pq.RowValues[name] = values
+			}
+		}
 	}
 
 	return nil
 }
 
-// analyzeUpdate analyzes an UPDATE statement
+// updateTarget is a table referenced by an UPDATE
+type updateTarget struct {
+	table sqlparser.TableName
+	alias sqlparser.TableIdent
+	key   string // table config key; empty when the table is not configured
+	conf  config.TableConfig
+}
+
+// analyzeUpdate analyzes an UPDATE statement. In multi-table UPDATEs the SET
+// columns are resolved against the joined tables by alias or table name, and
+// unqualified columns against the configured table that has them; currency
+// columns of more than one configured table cannot be dual-written together.
 func (p *Parser) analyzeUpdate(stmt *sqlparser.Update, pq *ParsedQuery) error {
-	// Extract table name (handle multi-table updates)
-	if len(stmt.TableExprs) > 0 {
-		if aliasedTable, ok := stmt.TableExprs[0].(*sqlparser.AliasedTableExpr); ok {
-			if tableName, ok := aliasedTable.Expr.(sqlparser.TableName); ok {
-				pq.TableName = tableName.Name.String()
+	targets := p.updateTargets(stmt.TableExprs, nil)
+	if len(targets) > 0 {
+		pq.TableName = targets[0].key
+		if pq.TableName == "" {
+			pq.TableName = NormalizeTableName(targets[0].table.Name.String())
+		}
+	}
+
+	var target *updateTarget
+	for _, expr := range stmt.Exprs {
+		colTarget, colKey, err := p.resolveUpdateColumn(targets, expr.Name)
+		if err != nil {
+			return err
+		}
+		if colTarget == nil {
+			continue
+		}
+		if target != nil && target.key != colTarget.key {
+			return fmt.Errorf("UPDATE assigns currency columns of tables %s and %s", target.key, colTarget.key)
+		}
+		target = colTarget
+
+		pq.CurrencyColumns = append(pq.CurrencyColumns, colKey)
+		pq.NeedsTransform = true
+		// Bulk updates assign literals per row through CASE; the shadow
+		// column gets the same CASE with converted literals
+		if caseExpr, ok := expr.Expr.(*sqlparser.CaseExpr); ok {
+			if values, ok := caseLiterals(caseExpr); ok {
+				if pq.CaseValues == nil {
+					pq.CaseValues = make(map[string][]interface{})
+					pq.caseExprs = make(map[string]*sqlparser.CaseExpr)
+				}
+				pq.CaseValues[colKey] = values
+				pq.caseExprs[colKey] = caseExpr
+				continue
 			}
 		}
+		pq.Values[colKey] = extractValue(expr.Expr)
 	}
 
-	// Check if this table is configured
-	tableConfig, exists := p.tableConfig[pq.TableName]
-	if !exists || !tableConfig.Enabled {
+	if target == nil {
 		pq.NeedsTransform = false
 		return nil
 	}
+	pq.TableName = target.key
 
-	// Extract SET columns and values
-	for _, expr := range stmt.Exprs {
-		colName := expr.Name.Name.String()
-
-		// Check if this is a currency column
-		if _, exists := tableConfig.Columns[colName]; exists {
-			pq.CurrencyColumns = append(pq.CurrencyColumns, colName)
-			pq.Values[colName] = extractValue(expr.Expr)
-			pq.NeedsTransform = true
+	// Shadow assignments in a multi-table UPDATE are qualified like the
+	// target table is referenced
+	if len(targets) > 1 {
+		if !target.alias.IsEmpty() {
+			pq.shadowQualifier = sqlparser.TableName{Name: target.alias}
+		} else {
+			pq.shadowQualifier = target.table
 		}
 	}
 
 	return nil
 }
 
+// updateTargets collects the tables of an UPDATE's table references, including
+// joined and parenthesized ones. Derived tables are skipped.
+func (p *Parser) updateTargets(exprs sqlparser.TableExprs, targets []updateTarget) []updateTarget {
+	for _, expr := range exprs {
+		switch expr := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			table, ok := expr.Expr.(sqlparser.TableName)
+			if !ok {
+				continue
+			}
+			target := updateTarget{table: table, alias: expr.As}
+			if key, tableConfig, exists := p.lookupTable(table); exists && p.enabled(key, tableConfig) {
+				target.key, target.conf = key, tableConfig
+			}
+			targets = append(targets, target)
+		case *sqlparser.JoinTableExpr:
+			targets = p.updateTargets(sqlparser.TableExprs{expr.LeftExpr, expr.RightExpr}, targets)
+		case *sqlparser.ParenTableExpr:
+			targets = p.updateTargets(expr.Exprs, targets)
+		}
+	}
+	return targets
+}
+
+// resolveUpdateColumn returns the configured target and column config key of a
+// SET column, or a nil target when it is not a currency column
+func (p *Parser) resolveUpdateColumn(targets []updateTarget, col *sqlparser.ColName) (*updateTarget, string, error) {
+	name := col.Name.String()
+
+	if !col.Qualifier.IsEmpty() {
+		qualifier := NormalizeTableName(col.Qualifier.Name.String())
+		for i := range targets {
+			target := &targets[i]
+			if !target.alias.IsEmpty() {
+				if !p.sameName(target.alias.String(), qualifier) {
+					continue
+				}
+			} else if !p.sameName(NormalizeTableName(target.table.Name.String()), qualifier) ||
+				(!col.Qualifier.Qualifier.IsEmpty() && !p.sameName(NormalizeTableName(col.Qualifier.Qualifier.String()),
+					NormalizeTableName(target.table.Qualifier.String()))) {
+				continue
+			}
+			if target.key == "" {
+				return nil, "", nil
+			}
+			if colKey, _, exists := target.conf.LookupColumn(name); exists {
+				return target, colKey, nil
+			}
+			return nil, "", nil
+		}
+		return nil, "", nil
+	}
+
+	var found *updateTarget
+	var foundKey string
+	for i := range targets {
+		target := &targets[i]
+		if target.key == "" {
+			continue
+		}
+		if colKey, _, exists := target.conf.LookupColumn(name); exists {
+			if found != nil && found.key != target.key {
+				return nil, "", fmt.Errorf("UPDATE column %s is ambiguous between tables %s and %s", name, found.key, target.key)
+			}
+			found, foundKey = target, colKey
+		}
+	}
+	return found, foundKey, nil
+}
+
 // analyzeSelect analyzes a SELECT statement
 func (p *Parser) analyzeSelect(stmt *sqlparser.Select, pq *ParsedQuery) error {
 	// Extract table name from FROM clause
-	if len(stmt.From) > 0 {
-		if aliasedTable, ok := stmt.From[0].(*sqlparser.AliasedTableExpr); ok {
-			if tableName, ok := aliasedTable.Expr.(sqlparser.TableName); ok {
-				pq.TableName = tableName.Name.String()
-			}
+	if table, ok := selectTable(stmt); ok {
+		pq.TableName = NormalizeTableName(table.Name.String())
+		if tableKey, _, exists := p.lookupTable(table); exists {
+			pq.TableName = tableKey
 		}
 	}
 
@@ -184,13 +449,42 @@ func (p *Parser) analyzeSelect(stmt *sqlparser.Select, pq *ParsedQuery) error {
 	return nil
 }
 
+// selectTable returns the first table in the FROM clause of a SELECT
+func selectTable(stmt *sqlparser.Select) (sqlparser.TableName, bool) {
+	if len(stmt.From) == 0 {
+		return sqlparser.TableName{}, false
+	}
+	aliasedTable, ok := stmt.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return sqlparser.TableName{}, false
+	}
+	table, ok := aliasedTable.Expr.(sqlparser.TableName)
+	return table, ok
+}
+
+// ReadTable resolves the configured table a SELECT reads like the tables
+// INSERT and UPDATE write: a qualified name outside the configured schema does
+// not match, and names compare per lower_case_table_names. ok is false for
+// other statements and for SELECTs on unconfigured tables.
+func (p *Parser) ReadTable(pq *ParsedQuery) (string, config.TableConfig, bool) {
+	stmt, ok := pq.Statement.(*sqlparser.Select)
+	if !ok {
+		return "", config.TableConfig{}, false
+	}
+	table, ok := selectTable(stmt)
+	if !ok {
+		return "", config.TableConfig{}, false
+	}
+	return p.lookupTable(table)
+}
+
 // analyzeDelete analyzes a DELETE statement
 func (p *Parser) analyzeDelete(stmt *sqlparser.Delete, pq *ParsedQuery) error {
 	// Extract table name
 	if len(stmt.TableExprs) > 0 {
 		if aliasedTable, ok := stmt.TableExprs[0].(*sqlparser.AliasedTableExpr); ok {
 			if tableName, ok := aliasedTable.Expr.(sqlparser.TableName); ok {
-				pq.TableName = tableName.Name.String()
+				pq.TableName = NormalizeTableName(tableName.Name.String())
 			}
 		}
 	}
@@ -201,18 +495,117 @@ func (p *Parser) analyzeDelete(stmt *sqlparser.Delete, pq *ParsedQuery) error {
 	return nil
 }
 
+// GuessWriteTables returns the enabled configured table that a statement the
+// parser could not handle appears to write to. Only the target identifier of
+// INSERT/REPLACE ... INTO and UPDATE is considered, so tables that are merely
+// read (e.g. INSERT INTO audit SELECT ... FROM orders) do not match.
+func (p *Parser) GuessWriteTables(query string) []string {
+	words := strings.Fields(Fingerprint(query))
+	if len(words) < 2 {
+		return nil
+	}
+
+	var modifiers map[string]bool
+	switch strings.ToUpper(words[0]) {
+	case "INSERT", "REPLACE":
+		modifiers = map[string]bool{"LOW_PRIORITY": true, "DELAYED": true, "HIGH_PRIORITY": true, "IGNORE": true, "INTO": true}
+	case "UPDATE":
+		modifiers = map[string]bool{"LOW_PRIORITY": true, "IGNORE": true}
+	default:
+		return nil
+	}
+
+	target := ""
+	for _, word := range words[1:] {
+		if !modifiers[strings.ToUpper(word)] {
+			target = word
+			break
+		}
+	}
+
+	// Drop a column list or SET clause glued to the identifier
+	if i := strings.IndexAny(target, "(,"); i >= 0 {
+		target = target[:i]
+	}
+	if target == "" {
+		return nil
+	}
+
+	var table sqlparser.TableName
+	if dot := strings.LastIndex(target, "."); dot >= 0 {
+		table.Qualifier = sqlparser.NewTableIdent(NormalizeTableName(target[:dot]))
+		target = target[dot+1:]
+	}
+	table.Name = sqlparser.NewTableIdent(NormalizeTableName(target))
+
+	key, tableConfig, ok := p.lookupTable(table)
+	if !ok || !p.enabled(key, tableConfig) {
+		return nil
+	}
+	return []string{key}
+}
+
+// caseLiterals returns the branch values of a CASE expression when every
+// branch is a literal or NULL
+func caseLiterals(caseExpr *sqlparser.CaseExpr) ([]interface{}, bool) {
+	branches := make([]sqlparser.Expr, 0, len(caseExpr.Whens)+1)
+	for _, when := range caseExpr.Whens {
+		branches = append(branches, when.Val)
+	}
+	if caseExpr.Else != nil {
+		branches = append(branches, caseExpr.Else)
+	}
+
+	values := make([]interface{}, 0, len(branches))
+	for _, branch := range branches {
+		switch branch.(type) {
+		case *sqlparser.SQLVal, *sqlparser.NullVal:
+			values = append(values, extractValue(branch))
+		default:
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// Placeholder is a bind placeholder, such as ? or :name, or a quoted '?', left
+// where a value belongs in a text protocol statement. Clients send these when
+// their driver or templating layer failed to interpolate parameters; the
+// parser numbers ? as :v1, :v2 and so on.
+type Placeholder string
+
 // extractValue extracts the actual value from a sqlparser expression
 func extractValue(expr sqlparser.Expr) interface{} {
 	switch v := expr.(type) {
 	case *sqlparser.SQLVal:
 		switch v.Type {
 		case sqlparser.IntVal:
+			// Numeric literals are unambiguous; only quoted strings may carry
+			// locale formatting
+			if i, err := strconv.ParseInt(string(v.Val), 10, 64); err == nil {
+				return i
+			}
 			return string(v.Val)
 		case sqlparser.StrVal:
+			if strings.TrimSpace(string(v.Val)) == "?" {
+				return Placeholder("'?'")
+			}
 			return string(v.Val)
+		case sqlparser.ValArg:
+			return Placeholder(v.Val)
 		case sqlparser.FloatVal:
+			if f, err := strconv.ParseFloat(string(v.Val), 64); err == nil {
+				return f
+			}
 			return string(v.Val)
 		}
+	case *sqlparser.UnaryExpr:
+		// The grammar folds the sign into integer literals but not floats
+		if v.Operator == sqlparser.UMinusStr {
+			if f, ok := extractValue(v.Expr).(float64); ok {
+				return -f
+			}
+		}
 	case sqlparser.BoolVal:
 		return bool(v)
 	case *sqlparser.NullVal:
@@ -221,6 +614,146 @@ func extractValue(expr sqlparser.Expr) interface{} {
 	return sqlparser.String(expr)
 }
 
+// KeyValue returns the literal value a write gives column, which identifies
+// the row it writes: the column's value in the first row of an INSERT, or an
+// equality on it in the WHERE of an UPDATE. ok is false when the statement
+// does not pin the column to a literal.
+func (pq *ParsedQuery) KeyValue(column string) (string, bool) {
+	switch stmt := pq.Statement.(type) {
+	case *sqlparser.Insert:
+		rows, ok := stmt.Rows.(sqlparser.Values)
+		if !ok || len(rows) == 0 {
+			return "", false
+		}
+		for i, col := range stmt.Columns {
+			if col.EqualString(column) && i < len(rows[0]) {
+				return literalValue(rows[0][i])
+			}
+		}
+	case *sqlparser.Update:
+		if stmt.Where != nil {
+			return whereKeyValue(stmt.Where.Expr, column)
+		}
+	}
+	return "", false
+}
+
+// RowKey returns the key of the one row a write touches: the column's value in
+// a single-row INSERT ... VALUES, or an equality on it in the WHERE of a
+// single-table UPDATE. A single-row INSERT that leaves the column out returns
+// an empty key, to be assigned by AUTO_INCREMENT. ok is false for writes that
+// may touch several rows, including INSERTs with ON DUPLICATE KEY UPDATE.
+func (pq *ParsedQuery) RowKey(column string) (string, bool) {
+	switch stmt := pq.Statement.(type) {
+	case *sqlparser.Insert:
+		rows, ok := stmt.Rows.(sqlparser.Values)
+		if !ok || len(rows) != 1 || len(stmt.OnDup) > 0 || stmt.Action != sqlparser.InsertStr {
+			return "", false
+		}
+		for _, col := range stmt.Columns {
+			if col.EqualString(column) {
+				return pq.KeyValue(column)
+			}
+		}
+		return "", true
+	case *sqlparser.Update:
+		if len(stmt.TableExprs) != 1 {
+			return "", false
+		}
+		if _, ok := stmt.TableExprs[0].(*sqlparser.AliasedTableExpr); !ok {
+			return "", false
+		}
+		return pq.KeyValue(column)
+	}
+	return "", false
+}
+
+// ShadowLookup returns a SELECT of the current shadow values of the one row a
+// single-table UPDATE assigns currency columns of, keyed on keyColumn, with
+// the currency columns in the order their shadow columns are selected.
+// database qualifies a table written without one, so the SELECT reads the
+// same row on a connection with another default database. ok is false for
+// other statements and for UPDATEs that may touch several rows.
+func (pq *ParsedQuery) ShadowLookup(tableConfig config.TableConfig, keyColumn, database string) (string, []string, bool) {
+	stmt, isUpdate := pq.Statement.(*sqlparser.Update)
+	if !isUpdate {
+		return "", nil, false
+	}
+	key, ok := pq.RowKey(keyColumn)
+	if !ok || key == "" {
+		return "", nil, false
+	}
+	table, ok := stmt.TableExprs[0].(*sqlparser.AliasedTableExpr).Expr.(sqlparser.TableName)
+	if !ok {
+		return "", nil, false
+	}
+	if table.Qualifier.IsEmpty() && database != "" {
+		table.Qualifier = sqlparser.NewTableIdent(database)
+	}
+
+	sel := &sqlparser.Select{
+		From: sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: table}},
+		Where: sqlparser.NewWhere(sqlparser.WhereStr, &sqlparser.ComparisonExpr{
+			Operator: sqlparser.EqualStr,
+			Left:     &sqlparser.ColName{Name: sqlparser.NewColIdent(keyColumn)},
+			Right:    sqlparser.NewStrVal([]byte(key)),
+		}),
+		Limit: &sqlparser.Limit{Rowcount: sqlparser.NewIntVal([]byte("1"))},
+	}
+	var columns []string
+	for _, col := range pq.CurrencyColumns {
+		colConfig, exists := tableConfig.Columns[col]
+		if _, isCase := pq.caseExprs[col]; !exists || isCase {
+			continue
+		}
+		sel.SelectExprs = append(sel.SelectExprs, &sqlparser.AliasedExpr{
+			Expr: &sqlparser.ColName{Name: sqlparser.NewColIdent(colConfig.TargetColumn)},
+		})
+		columns = append(columns, col)
+	}
+	if len(columns) == 0 {
+		return "", nil, false
+	}
+	return sqlparser.String(sel), columns, true
+}
+
+// whereKeyValue finds column = literal among the AND-ed conditions of expr
+func whereKeyValue(expr sqlparser.Expr, column string) (string, bool) {
+	switch e := expr.(type) {
+	case *sqlparser.AndExpr:
+		if value, ok := whereKeyValue(e.Left, column); ok {
+			return value, true
+		}
+		return whereKeyValue(e.Right, column)
+	case *sqlparser.ParenExpr:
+		return whereKeyValue(e.Expr, column)
+	case *sqlparser.ComparisonExpr:
+		if e.Operator != sqlparser.EqualStr {
+			return "", false
+		}
+		if col, ok := e.Left.(*sqlparser.ColName); ok && col.Name.EqualString(column) {
+			return literalValue(e.Right)
+		}
+		if col, ok := e.Right.(*sqlparser.ColName); ok && col.Name.EqualString(column) {
+			return literalValue(e.Left)
+		}
+	}
+	return "", false
+}
+
+// literalValue returns the text of a number or string literal
+func literalValue(expr sqlparser.Expr) (string, bool) {
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok {
+		return "", false
+	}
+	switch val.Type {
+	case sqlparser.IntVal, sqlparser.StrVal, sqlparser.FloatVal:
+		return string(val.Val), true
+	}
+	return "", false
+}
+
 // RewriteForDualWrite rewrites a query to include shadow columns
 func (p *Parser) RewriteForDualWrite(pq *ParsedQuery, convertedValues map[string]float64) (string, error) {
 	if !pq.NeedsTransform {
@@ -243,6 +776,20 @@ func (p *Parser) RewriteForDualWrite(pq *ParsedQuery, convertedValues map[string
 func (p *Parser) rewriteInsert(stmt *sqlparser.Insert, pq *ParsedQuery,
 	tableConfig config.TableConfig, convertedValues map[string]float64) (string, error) {
 
+	// Bulk inserts are spliced rather than serialized again
+	if len(pq.Original) >= spliceMinLength {
+		if query, ok, err := p.spliceInsert(stmt, pq, tableConfig, convertedValues); err != nil || ok {
+			return query, err
+		}
+	}
+	return p.rewriteInsertTree(stmt, pq, tableConfig, convertedValues)
+}
+
+// rewriteInsertTree adds the shadow columns to a copy of the INSERT and
+// serializes it
+func (p *Parser) rewriteInsertTree(stmt *sqlparser.Insert, pq *ParsedQuery,
+	tableConfig config.TableConfig, convertedValues map[string]float64) (string, error) {
+
 	// Clone the statement
 	newStmt := *stmt
 
@@ -252,29 +799,37 @@ func (p *Parser) rewriteInsert(stmt *sqlparser.Insert, pq *ParsedQuery,
 		newColumns = append(newColumns, col)
 	}
 
-	// Add shadow columns
+	// Add shadow columns for every currency column that gets a shadow value
+	rows, _ := stmt.Rows.(sqlparser.Values)
+	var shadowVals [][]sqlparser.Expr
 	for _, currencyCol := range pq.CurrencyColumns {
-		if colConfig, exists := tableConfig.Columns[currencyCol]; exists {
-			shadowCol := sqlparser.NewColIdent(colConfig.TargetColumn)
-			newColumns = append(newColumns, shadowCol)
+		colConfig, exists := tableConfig.Columns[currencyCol]
+		if !exists {
+			continue
+		}
+		exprs, ok, err := p.insertShadowValues(colConfig, pq, currencyCol, len(rows), convertedValues)
+		if err != nil {
+			return "", err
+		} else if !ok {
+			continue
 		}
+		shadowVals = append(shadowVals, exprs)
+		newColumns = append(newColumns, sqlparser.NewColIdent(colConfig.TargetColumn))
 	}
 	newStmt.Columns = newColumns
 
 	// Add shadow values to VALUES clause
-	if rows, ok := stmt.Rows.(sqlparser.Values); ok {
+	if rows != nil {
 		var newRows sqlparser.Values
-		for _, row := range rows {
+		for r, row := range rows {
 			var newRow sqlparser.ValTuple
 			for _, val := range row {
 				newRow = append(newRow, val)
 			}
 
 			// Add converted values
-			for _, currencyCol := range pq.CurrencyColumns {
-				if convertedValue, exists := convertedValues[currencyCol]; exists {
-					newRow = append(newRow, sqlparser.NewFloatVal([]byte(fmt.Sprintf("%.4f", convertedValue))))
-				}
+			for _, exprs := range shadowVals {
+				newRow = append(newRow, exprs[r])
 			}
 
 			newRows = append(newRows, newRow)
@@ -301,12 +856,21 @@ func (p *Parser) rewriteUpdate(stmt *sqlparser.Update, pq *ParsedQuery,
 	// Add converted values for shadow columns
 	for _, currencyCol := range pq.CurrencyColumns {
 		if colConfig, exists := tableConfig.Columns[currencyCol]; exists {
-			if convertedValue, exists := convertedValues[currencyCol]; exists {
+			shadowValue := p.shadowValue
+			if _, isCase := pq.caseExprs[currencyCol]; isCase {
+				shadowValue = p.shadowCase
+			}
+			shadowVal, ok, err := shadowValue(colConfig, pq, currencyCol, convertedValues)
+			if err != nil {
+				return "", err
+			}
+			if ok {
 				shadowExpr := &sqlparser.UpdateExpr{
 					Name: &sqlparser.ColName{
-						Name: sqlparser.NewColIdent(colConfig.TargetColumn),
+						Name:      sqlparser.NewColIdent(colConfig.TargetColumn),
+						Qualifier: pq.shadowQualifier,
 					},
-					Expr: sqlparser.NewFloatVal([]byte(fmt.Sprintf("%.4f", convertedValue))),
+					Expr: shadowVal,
 				}
 				newExprs = append(newExprs, shadowExpr)
 			}
@@ -317,6 +881,196 @@ func (p *Parser) rewriteUpdate(stmt *sqlparser.Update, pq *ParsedQuery,
 	return sqlparser.String(&newStmt), nil
 }
 
+// shadowValue returns the expression to write to a currency column's shadow
+// column. NULL source values follow the column's null policy; ok is false when
+// the shadow column should be left out of the statement.
+func (p *Parser) shadowValue(colConfig config.ColumnConfig, pq *ParsedQuery, col string,
+	convertedValues map[string]float64) (sqlparser.Expr, bool, error) {
+
+	colConfig = p.withLiveType(colConfig, pq)
+	value, exists := pq.Values[col]
+	convertedValue, converted := convertedValues[col]
+	return p.shadowLiteral(colConfig, col, exists && value == nil, convertedValue, converted)
+}
+
+// insertShadowValues returns the shadow value of a currency column for each of
+// the rows of an INSERT. Rows of a multi-row INSERT are converted on their own;
+// a NULL row under the skip null policy gets the shadow column's default. ok is
+// false when the shadow column should be left out of the statement: a row has
+// no converted value, or every row skips it.
+func (p *Parser) insertShadowValues(colConfig config.ColumnConfig, pq *ParsedQuery, col string, rows int,
+	convertedValues map[string]float64) ([]sqlparser.Expr, bool, error) {
+
+	values, multiRow := pq.RowValues[col]
+	if !multiRow {
+		shadowVal, ok, err := p.shadowValue(colConfig, pq, col, convertedValues)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		exprs := make([]sqlparser.Expr, rows)
+		for i := range exprs {
+			exprs[i] = shadowVal
+		}
+		return exprs, true, nil
+	}
+
+	colConfig = p.withLiveType(colConfig, pq)
+	exprs := make([]sqlparser.Expr, len(values))
+	skipped := 0
+	for i, value := range values {
+		convertedValue, converted := convertedValues[RowValueKey(col, i)]
+		isNull := value == nil
+		if !isNull && !converted {
+			return nil, false, nil
+		}
+		expr, ok, err := p.shadowLiteral(colConfig, col, isNull, convertedValue, converted)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			expr = &sqlparser.Default{}
+			skipped++
+		}
+		exprs[i] = expr
+	}
+	return exprs, skipped < len(exprs), nil
+}
+
+// shadowCase mirrors a CASE assignment for the shadow column with each branch
+// value replaced by its converted literal. A NULL branch under the skip null
+// policy keeps the shadow column's current value. ok is false when a branch
+// has no converted value.
+func (p *Parser) shadowCase(colConfig config.ColumnConfig, pq *ParsedQuery, col string,
+	convertedValues map[string]float64) (sqlparser.Expr, bool, error) {
+
+	colConfig = p.withLiveType(colConfig, pq)
+	caseExpr := pq.caseExprs[col]
+	values := pq.CaseValues[col]
+
+	branch := func(i int) (sqlparser.Expr, bool, error) {
+		convertedValue, converted := convertedValues[CaseValueKey(col, i)]
+		isNull := values[i] == nil
+		if !isNull && !converted {
+			return nil, false, nil
+		}
+		expr, ok, err := p.shadowLiteral(colConfig, col, isNull, convertedValue, converted)
+		if err != nil || ok {
+			return expr, true, err
+		}
+		return &sqlparser.ColName{Name: sqlparser.NewColIdent(colConfig.TargetColumn), Qualifier: pq.shadowQualifier}, true, nil
+	}
+
+	shadow := &sqlparser.CaseExpr{Expr: caseExpr.Expr}
+	for i, when := range caseExpr.Whens {
+		val, ok, err := branch(i)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		shadow.Whens = append(shadow.Whens, &sqlparser.When{Cond: when.Cond, Val: val})
+	}
+	if caseExpr.Else != nil {
+		val, ok, err := branch(len(caseExpr.Whens))
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		shadow.Else = val
+	}
+	return shadow, true, nil
+}
+
+// withLiveType replaces the configured target type with the live column type
+// when a resolver is set
+func (p *Parser) withLiveType(colConfig config.ColumnConfig, pq *ParsedQuery) config.ColumnConfig {
+	if p.columnType != nil {
+		if liveType, ok := p.columnType(pq.TableName, colConfig.TargetColumn); ok {
+			colConfig.TargetType = liveType
+		}
+	}
+	return colConfig
+}
+
+// shadowLiteral formats one shadow value: NULL sources follow the column's null
+// policy, other values need a converted amount. ok is false when the shadow
+// column should be left unchanged.
+func (p *Parser) shadowLiteral(colConfig config.ColumnConfig, col string, isNull bool,
+	convertedValue float64, converted bool) (sqlparser.Expr, bool, error) {
+
+	if isNull {
+		switch colConfig.EffectiveNullPolicy() {
+		case config.NullPolicySkip:
+			return nil, false, nil
+		case config.NullPolicyZero:
+			literal, err := FormatShadowValue(colConfig, p.roundingStrategy, p.precision, 0)
+			if err != nil {
+				return nil, false, fmt.Errorf("column %s: %w", col, err)
+			}
+			return sqlparser.NewFloatVal([]byte(literal)), true, nil
+		default:
+			return &sqlparser.NullVal{}, true, nil
+		}
+	}
+
+	if !converted {
+		return nil, false, nil
+	}
+	literal, err := FormatShadowValue(colConfig, p.roundingStrategy, p.precision, convertedValue)
+	if err != nil {
+		return nil, false, fmt.Errorf("column %s: %w", col, err)
+	}
+	return sqlparser.NewFloatVal([]byte(literal)), true, nil
+}
+
+// FormatShadowValue rounds a converted value and formats it as a literal for the
+// shadow column. The number of decimals follows the column's configured precision
+// or defaultPrecision, capped at the scale of a DECIMAL(p,s) target type, and
+// rounding uses the column's strategy or defaultStrategy. Values that would not fit the target DECIMAL, and
+// integer target types, are rejected rather than letting MySQL clip or truncate
+// them mid-write.
+func FormatShadowValue(colConfig config.ColumnConfig, defaultStrategy string, defaultPrecision int, value float64) (string, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", fmt.Errorf("invalid converted value: %v", value)
+	}
+	if config.IsIntegerType(colConfig.TargetType) {
+		return "", fmt.Errorf("target type %s is an integer type and would truncate converted decimals", colConfig.TargetType)
+	}
+
+	decimals := ShadowDecimals(colConfig, defaultPrecision)
+	precision, scale, isDecimal := config.ParseDecimalType(colConfig.TargetType)
+
+	// Round exactly once with the configured strategy; formatting the rounded
+	// value only prints the digits already decided
+	strategy := colConfig.EffectiveRoundingStrategy(defaultStrategy)
+	rounded := rounding.NewEngine(rounding.Strategy(strategy), decimals).Round(value)
+	literal := strconv.FormatFloat(rounded, 'f', decimals, 64)
+
+	if isDecimal {
+		integerDigits := strings.TrimPrefix(literal, "-")
+		if dot := strings.IndexByte(integerDigits, '.'); dot >= 0 {
+			integerDigits = integerDigits[:dot]
+		}
+		integerDigits = strings.TrimLeft(integerDigits, "0")
+		if len(integerDigits) > precision-scale {
+			return "", fmt.Errorf("value %s overflows target type %s", literal, colConfig.TargetType)
+		}
+	}
+
+	return literal, nil
+}
+
+// ShadowDecimals returns the number of decimals shadow values of a column are
+// rounded to: its precision, or defaultPrecision (conversion.precision) when it
+// has none, capped at the scale of a DECIMAL(p,s) target type
+func ShadowDecimals(colConfig config.ColumnConfig, defaultPrecision int) int {
+	decimals := colConfig.Precision
+	if decimals <= 0 {
+		decimals = defaultPrecision
+	}
+	if _, scale, isDecimal := config.ParseDecimalType(colConfig.TargetType); isDecimal && (decimals <= 0 || decimals > scale) {
+		decimals = scale
+	}
+	return max(decimals, 0)
+}
+
 // GetQueryType returns a string representation of query type
 func (qt QueryType) String() string {
 	switch qt {
@@ -328,6 +1082,8 @@ func (qt QueryType) String() string {
 		return "UPDATE"
 	case QueryTypeDelete:
 		return "DELETE"
+	case QueryTypeUse:
+		return "USE"
 	default:
 		return "UNKNOWN"
 	}
@@ -340,7 +1096,5 @@ func (qt QueryType) IsMutation() bool {
 
 // NormalizeTableName removes backticks and quotes from table name
 func NormalizeTableName(name string) string {
-	name = strings.Trim(name, "`")
-	name = strings.Trim(name, "\"")
-	return name
+	return config.NormalizeIdentifier(name)
 }
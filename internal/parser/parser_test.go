@@ -180,6 +180,21 @@ func TestParseUpdate(t *testing.T) {
 	}
 }
 
+func TestParseNegativeLiterals(t *testing.T) {
+	parser := NewParser(getTestConfig())
+
+	// Integer literals carry their sign; float literals are negated
+	// expressions and must not fall back to text
+	pq, err := parser.Parse("UPDATE orders SET total_amount = -31.125, shipping_fee = -2500 WHERE id = 1")
+	require.NoError(t, err)
+	assert.Equal(t, -31.125, pq.Values["total_amount"])
+	assert.Equal(t, int64(-2500), pq.Values["shipping_fee"])
+
+	pq, err = parser.Parse("UPDATE orders SET total_amount = -(1 + 2) WHERE id = 1")
+	require.NoError(t, err)
+	assert.Equal(t, "-(1 + 2)", pq.Values["total_amount"])
+}
+
 func TestParseSelect(t *testing.T) {
 	parser := NewParser(getTestConfig())
 
@@ -264,6 +279,30 @@ func TestRewriteInsert(t *testing.T) {
 	t.Logf("Rewritten: %s", rewritten)
 }
 
+func TestRewriteMultiRowInsert(t *testing.T) {
+	parser := NewParser(getTestConfig())
+
+	query := "INSERT INTO orders (customer_id, total_amount) VALUES (1, 100000), (2, 9900000), (3, NULL)"
+	pq, err := parser.Parse(query)
+	require.NoError(t, err)
+	require.True(t, pq.NeedsTransform)
+	assert.Equal(t, []interface{}{int64(100000), int64(9900000), nil}, pq.RowValues["total_amount"])
+
+	// Each row gets the shadow value of its own amount
+	rewritten, err := parser.RewriteForDualWrite(pq, map[string]float64{
+		RowValueKey("total_amount", 0): 100,
+		RowValueKey("total_amount", 1): 9900,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "insert into orders(customer_id, total_amount, total_amount_idn) values "+
+		"(1, 100000, 100.0000), (2, 9900000, 9900.0000), (3, null, null)", rewritten)
+
+	// A row without a converted value leaves the shadow column out
+	rewritten, err = parser.RewriteForDualWrite(pq, map[string]float64{RowValueKey("total_amount", 0): 100})
+	require.NoError(t, err)
+	assert.NotContains(t, rewritten, "total_amount_idn")
+}
+
 func TestRewriteUpdate(t *testing.T) {
 	parser := NewParser(getTestConfig())
 
@@ -287,6 +326,124 @@ func TestRewriteUpdate(t *testing.T) {
 	t.Logf("Rewritten: %s", rewritten)
 }
 
+func TestRewriteUpdateCase(t *testing.T) {
+	parser := NewParser(getTestConfig())
+
+	query := "UPDATE orders SET total_amount = CASE id WHEN 1 THEN 750000 WHEN 2 THEN NULL ELSE 1000 END WHERE id IN (1, 2, 3)"
+	pq, err := parser.Parse(query)
+	require.NoError(t, err)
+	require.True(t, pq.NeedsTransform)
+	assert.Equal(t, []interface{}{int64(750000), nil, int64(1000)}, pq.CaseValues["total_amount"])
+	assert.NotContains(t, pq.Values, "total_amount")
+
+	convertedValues := map[string]float64{
+		CaseValueKey("total_amount", 0): 750,
+		CaseValueKey("total_amount", 2): 1,
+	}
+	rewritten, err := parser.RewriteForDualWrite(pq, convertedValues)
+	require.NoError(t, err)
+	assert.Equal(t, "update orders set total_amount = case id when 1 then 750000 when 2 then null else 1000 end, "+
+		"total_amount_idn = case id when 1 then 750.0000 when 2 then null else 1.0000 end where id in (1, 2, 3)", rewritten)
+
+	// A branch without a converted value leaves the shadow column out
+	delete(convertedValues, CaseValueKey("total_amount", 2))
+	rewritten, err = parser.RewriteForDualWrite(pq, convertedValues)
+	require.NoError(t, err)
+	assert.NotContains(t, rewritten, "total_amount_idn")
+
+	// Non-literal branches are not mirrored
+	pq, err = parser.Parse("UPDATE orders SET total_amount = CASE WHEN id = 1 THEN total_amount * 2 END")
+	require.NoError(t, err)
+	assert.Empty(t, pq.CaseValues)
+	assert.Equal(t, "case when id = 1 then total_amount * 2 end", pq.Values["total_amount"])
+}
+
+func TestRewriteUpdateCaseSkipNull(t *testing.T) {
+	tables := getTestConfig()
+	col := tables["orders"].Columns["total_amount"]
+	col.NullPolicy = config.NullPolicySkip
+	tables["orders"].Columns["total_amount"] = col
+	parser := NewParser(tables)
+
+	pq, err := parser.Parse("UPDATE orders SET total_amount = CASE WHEN id = 1 THEN 5000 WHEN id = 2 THEN NULL END")
+	require.NoError(t, err)
+
+	rewritten, err := parser.RewriteForDualWrite(pq, map[string]float64{CaseValueKey("total_amount", 0): 5})
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "total_amount_idn = case when id = 1 then 5.0000 when id = 2 then total_amount_idn end")
+}
+
+func TestRewriteMultiTableUpdate(t *testing.T) {
+	parser := NewParser(getTestConfig())
+
+	tests := []struct {
+		name    string
+		query   string
+		table   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "aliased join target",
+			query: "UPDATE orders o JOIN invoices i ON i.order_id = o.id SET o.total_amount = 5000 WHERE i.id = 1",
+			table: "orders",
+			want:  "update orders as o join invoices as i on i.order_id = o.id set o.total_amount = 5000, o.total_amount_idn = 5.0000 where i.id = 1",
+		},
+		{
+			name:  "second joined table",
+			query: "UPDATE orders o JOIN invoices i ON i.order_id = o.id SET i.grand_total = 5000, o.status = 'billed'",
+			table: "invoices",
+			want:  "update orders as o join invoices as i on i.order_id = o.id set i.grand_total = 5000, o.`status` = 'billed', i.grand_total_idn = 5.0000",
+		},
+		{
+			name:  "unqualified column resolved by config",
+			query: "UPDATE orders, invoices SET grand_total = 5000 WHERE invoices.order_id = orders.id",
+			table: "invoices",
+			want:  "update orders, invoices set grand_total = 5000, invoices.grand_total_idn = 5.0000 where invoices.order_id = orders.id",
+		},
+		{
+			name:  "single aliased table stays unqualified",
+			query: "UPDATE orders AS o SET o.total_amount = 5000",
+			table: "orders",
+			want:  "update orders as o set o.total_amount = 5000, total_amount_idn = 5.0000",
+		},
+		{
+			name:  "alias of unconfigured table",
+			query: "UPDATE orders o JOIN audit a ON a.order_id = o.id SET a.total_amount = 5000",
+		},
+		{
+			name:    "currency columns of two tables",
+			query:   "UPDATE orders o JOIN invoices i ON i.order_id = o.id SET o.total_amount = 5000, i.grand_total = 5000",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pq, err := parser.Parse(tt.query)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.False(t, pq.NeedsTransform)
+				return
+			}
+			require.True(t, pq.NeedsTransform)
+			assert.Equal(t, tt.table, pq.TableName)
+
+			converted := make(map[string]float64)
+			for _, col := range pq.CurrencyColumns {
+				converted[col] = 5
+			}
+			rewritten, err := parser.RewriteForDualWrite(pq, converted)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rewritten)
+		})
+	}
+}
+
 func TestQueryTypeString(t *testing.T) {
 	tests := []struct {
 		queryType QueryType
@@ -349,3 +506,480 @@ func TestParseInvalidSQL(t *testing.T) {
 		})
 	}
 }
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM orders WHERE id = 123", "SELECT * FROM orders WHERE id = ?"},
+		{"INSERT INTO orders (total_amount) VALUES (500000.50)", "INSERT INTO orders (total_amount) VALUES (?)"},
+		{"SELECT name FROM users WHERE email = 'a''b@example.com'", "SELECT name FROM users WHERE email = ?"},
+		{"SELECT  *\n FROM   `order1` -- trailing\n WHERE x = \"y\"", "SELECT * FROM `order1` WHERE x = ?"},
+		{"/* app=checkout */ UPDATE t2 SET col1 = 0x1F", "UPDATE t2 SET col1 = ?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.want, Fingerprint(tt.query))
+		})
+	}
+}
+
+func TestParseDatabaseAwareResolution(t *testing.T) {
+	p := NewParser(getTestConfig())
+	p.SetSchema("ecommerce_db")
+
+	tests := []struct {
+		name               string
+		currentDB          string
+		query              string
+		wantNeedsTransform bool
+	}{
+		{"unknown current database", "", "INSERT INTO orders (total_amount) VALUES (1000)", true},
+		{"configured current database", "ecommerce_db", "INSERT INTO orders (total_amount) VALUES (1000)", true},
+		{"unrelated current database", "analytics", "INSERT INTO orders (total_amount) VALUES (1000)", false},
+		{"qualified configured table", "analytics", "INSERT INTO ecommerce_db.orders (total_amount) VALUES (1000)", true},
+		{"qualified unrelated table", "ecommerce_db", "UPDATE archive.orders SET total_amount = 5 WHERE id = 1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.SetCurrentDatabase(tt.currentDB)
+			pq, err := p.Parse(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, "orders", pq.TableName)
+			assert.Equal(t, tt.wantNeedsTransform, pq.NeedsTransform)
+		})
+	}
+}
+
+func TestReadTable(t *testing.T) {
+	p := NewParser(getTestConfig())
+	p.SetSchema("ecommerce_db")
+	p.SetLowerCaseTableNames(1)
+
+	tests := []struct {
+		query string
+		want  string // empty when no configured table is read
+	}{
+		{"SELECT * FROM orders WHERE id = 1", "orders"},
+		{"SELECT * FROM ecommerce_db.ORDERS", "orders"},
+		{"SELECT * FROM archive.orders", ""},
+		{"SELECT * FROM customers", ""},
+		{"UPDATE orders SET total_amount = 5 WHERE id = 1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			pq, err := p.Parse(tt.query)
+			require.NoError(t, err)
+			table, _, ok := p.ReadTable(pq)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, table)
+		})
+	}
+}
+
+func TestParseUse(t *testing.T) {
+	p := NewParser(getTestConfig())
+
+	pq, err := p.Parse("USE ecommerce_db")
+	require.NoError(t, err)
+	assert.Equal(t, QueryTypeUse, pq.Type)
+	assert.Equal(t, "ecommerce_db", pq.Database)
+	assert.False(t, pq.NeedsTransform)
+}
+
+func TestParseQuotedAndCaseInsensitiveIdentifiers(t *testing.T) {
+	tests := []struct {
+		name               string
+		lowerCaseNames     int
+		query              string
+		wantTable          string
+		wantCurrencyCols   []string
+		wantNeedsTransform bool
+	}{
+		{
+			name:               "backticked table and columns",
+			query:              "INSERT INTO `orders` (`customer_id`, `total_amount`) VALUES (1, 500000)",
+			wantTable:          "orders",
+			wantCurrencyCols:   []string{"total_amount"},
+			wantNeedsTransform: true,
+		},
+		{
+			name:               "upper-case column names always match",
+			query:              "UPDATE orders SET TOTAL_AMOUNT = 750000 WHERE id = 1",
+			wantTable:          "orders",
+			wantCurrencyCols:   []string{"total_amount"},
+			wantNeedsTransform: true,
+		},
+		{
+			name:               "upper-case table with case-sensitive names",
+			lowerCaseNames:     0,
+			query:              "INSERT INTO ORDERS (total_amount) VALUES (500000)",
+			wantTable:          "ORDERS",
+			wantNeedsTransform: false,
+		},
+		{
+			name:               "upper-case table with lower_case_table_names=1",
+			lowerCaseNames:     1,
+			query:              "INSERT INTO `ORDERS` (Total_Amount) VALUES (500000)",
+			wantTable:          "orders",
+			wantCurrencyCols:   []string{"total_amount"},
+			wantNeedsTransform: true,
+		},
+		{
+			name:               "qualified schema compared per lower_case_table_names",
+			lowerCaseNames:     2,
+			query:              "UPDATE `ECOMMERCE_DB`.`Orders` SET total_amount = 1 WHERE id = 1",
+			wantTable:          "orders",
+			wantCurrencyCols:   []string{"total_amount"},
+			wantNeedsTransform: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(getTestConfig())
+			p.SetSchema("ecommerce_db")
+			p.SetLowerCaseTableNames(tt.lowerCaseNames)
+
+			pq, err := p.Parse(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTable, pq.TableName)
+			assert.Equal(t, tt.wantNeedsTransform, pq.NeedsTransform)
+			if len(tt.wantCurrencyCols) > 0 {
+				assert.ElementsMatch(t, tt.wantCurrencyCols, pq.CurrencyColumns)
+				for _, col := range tt.wantCurrencyCols {
+					assert.Contains(t, pq.Values, col)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatShadowValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		colConfig config.ColumnConfig
+		value     float64
+		want      string
+		wantErr   bool
+	}{
+		{"precision within scale", config.ColumnConfig{TargetType: "DECIMAL(19,4)", Precision: 4}, 500, "500.0000", false},
+		{"scale caps precision", config.ColumnConfig{TargetType: "DECIMAL(12,2)", Precision: 4, RoundingStrategy: "ARITHMETIC_ROUND"}, 25.125, "25.13", false},
+		{"bankers rounds half to even", config.ColumnConfig{TargetType: "DECIMAL(12,2)", RoundingStrategy: "BANKERS_ROUND"}, 25.125, "25.12", false},
+		{"default strategy applies", config.ColumnConfig{TargetType: "DECIMAL(12,2)"}, 0.125, "0.12", false},
+		{"scale used when precision unset", config.ColumnConfig{TargetType: "decimal(10, 3)"}, 1.5, "1.500", false},
+		{"lower precision than scale", config.ColumnConfig{TargetType: "DECIMAL(19,4)", Precision: 2}, 1234.567, "1234.57", false},
+		{"non-decimal target uses precision", config.ColumnConfig{TargetType: "DOUBLE", Precision: 3}, 1.23456, "1.235", false},
+		{"fits exactly", config.ColumnConfig{TargetType: "DECIMAL(5,2)"}, 999.99, "999.99", false},
+		{"negative value fits", config.ColumnConfig{TargetType: "DECIMAL(5,2)"}, -999.99, "-999.99", false},
+		{"overflow", config.ColumnConfig{TargetType: "DECIMAL(5,2)"}, 1000, "", true},
+		{"overflow after rounding", config.ColumnConfig{TargetType: "DECIMAL(5,2)"}, 999.999, "", true},
+		{"integer target", config.ColumnConfig{TargetType: "INT(11) UNSIGNED"}, 1.5, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatShadowValue(tt.colConfig, "BANKERS_ROUND", 0, tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRewriteInsertDefaultPrecision(t *testing.T) {
+	tables := getTestConfig()
+	col := tables["orders"].Columns["total_amount"]
+	col.TargetType, col.Precision = "", 0
+	tables["orders"].Columns["total_amount"] = col
+	parser := NewParser(tables)
+	parser.SetPrecision(4)
+
+	pq, err := parser.Parse("INSERT INTO orders (total_amount) VALUES (100123)")
+	require.NoError(t, err)
+
+	// Without a precision or DECIMAL target the column uses conversion.precision
+	rewritten, err := parser.RewriteForDualWrite(pq, map[string]float64{"total_amount": 100.123})
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "values (100123, 100.1230)")
+}
+
+func TestRewriteInsertOverflow(t *testing.T) {
+	tables := getTestConfig()
+	orders := tables["orders"]
+	col := orders.Columns["shipping_fee"]
+	col.TargetType = "DECIMAL(6,4)"
+	orders.Columns["shipping_fee"] = col
+	parser := NewParser(tables)
+
+	pq, err := parser.Parse("INSERT INTO orders (shipping_fee) VALUES (250000)")
+	require.NoError(t, err)
+
+	_, err = parser.RewriteForDualWrite(pq, map[string]float64{"shipping_fee": 250})
+	assert.Error(t, err)
+}
+
+func TestRewriteInsertUsesLiveColumnType(t *testing.T) {
+	parser := NewParser(getTestConfig())
+	parser.SetColumnTypeResolver(func(table, column string) (string, bool) {
+		if table == "orders" && column == "total_amount_idn" {
+			return "decimal(19,2)", true
+		}
+		return "", false
+	})
+
+	pq, err := parser.Parse("INSERT INTO orders (total_amount, shipping_fee) VALUES (1234567, 25125)")
+	require.NoError(t, err)
+
+	rewritten, err := parser.RewriteForDualWrite(pq, map[string]float64{"total_amount": 1234.567, "shipping_fee": 25.125})
+	require.NoError(t, err)
+	assert.Contains(t, rewritten, "1234.57")
+	assert.Contains(t, rewritten, "25.1250")
+
+	parser.SetColumnTypeResolver(func(table, column string) (string, bool) {
+		return "int(11)", true
+	})
+	_, err = parser.RewriteForDualWrite(pq, map[string]float64{"total_amount": 1234.567, "shipping_fee": 25.125})
+	assert.Error(t, err)
+}
+
+func TestParseTableEnabledResolver(t *testing.T) {
+	parser := NewParser(getTestConfig())
+	enabled := false
+	parser.SetTableEnabledResolver(func(table string, configured bool) bool {
+		assert.Equal(t, "orders", table)
+		assert.True(t, configured)
+		return enabled
+	})
+
+	pq, err := parser.Parse("INSERT INTO orders (total_amount) VALUES (1000)")
+	require.NoError(t, err)
+	assert.False(t, pq.NeedsTransform)
+	pq, err = parser.Parse("UPDATE orders SET total_amount = 5 WHERE id = 1")
+	require.NoError(t, err)
+	assert.False(t, pq.NeedsTransform)
+
+	enabled = true
+	pq, err = parser.Parse("INSERT INTO orders (total_amount) VALUES (1000)")
+	require.NoError(t, err)
+	assert.True(t, pq.NeedsTransform)
+}
+
+func TestParse_Placeholders(t *testing.T) {
+	parser := NewParser(getTestConfig())
+
+	pq, err := parser.Parse("UPDATE orders SET total_amount = ? WHERE id = 1")
+	require.NoError(t, err)
+	assert.Equal(t, Placeholder(":v1"), pq.Values["total_amount"])
+
+	pq, err = parser.Parse("INSERT INTO orders (id, total_amount) VALUES (1, '?')")
+	require.NoError(t, err)
+	assert.Equal(t, Placeholder("'?'"), pq.Values["total_amount"])
+
+	// A quoted string containing a question mark is a value
+	pq, err = parser.Parse("INSERT INTO orders (id, total_amount) VALUES (1, '?1000')")
+	require.NoError(t, err)
+	assert.Equal(t, "?1000", pq.Values["total_amount"])
+}
+
+func TestParsedQueryKeyValue(t *testing.T) {
+	parser := NewParser(getTestConfig())
+
+	tests := []struct {
+		query string
+		want  string
+		ok    bool
+	}{
+		{"INSERT INTO orders (id, total_amount) VALUES (42, 1000), (43, 2000)", "42", true},
+		{"INSERT INTO orders (total_amount) VALUES (1000)", "", false},
+		{"UPDATE orders SET total_amount = 5 WHERE status = 'open' AND id = 7", "7", true},
+		{"UPDATE orders SET total_amount = 5 WHERE (id = 'A-7')", "A-7", true},
+		{"UPDATE orders SET total_amount = 5 WHERE id = 7 OR id = 8", "", false},
+		{"UPDATE orders SET total_amount = 5 WHERE id > 7", "", false},
+	}
+
+	for _, tt := range tests {
+		pq, err := parser.Parse(tt.query)
+		require.NoError(t, err)
+		value, ok := pq.KeyValue("id")
+		assert.Equal(t, tt.ok, ok, tt.query)
+		assert.Equal(t, tt.want, value, tt.query)
+	}
+}
+
+func TestParsedQueryRowKey(t *testing.T) {
+	parser := NewParser(getTestConfig())
+
+	tests := []struct {
+		query string
+		want  string
+		ok    bool
+	}{
+		{"INSERT INTO orders (id, total_amount) VALUES (42, 1000)", "42", true},
+		{"INSERT INTO orders (total_amount) VALUES (1000)", "", true},
+		{"INSERT INTO orders (id, total_amount) VALUES (42, 1000), (43, 2000)", "", false},
+		{"INSERT INTO orders (id, total_amount) VALUES (42, 1000) ON DUPLICATE KEY UPDATE total_amount = 5", "", false},
+		{"REPLACE INTO orders (id, total_amount) VALUES (42, 1000)", "", false},
+		{"UPDATE orders SET total_amount = 5 WHERE id = 7", "7", true},
+		{"UPDATE orders SET total_amount = 5 WHERE status = 'open'", "", false},
+		{"UPDATE orders o JOIN users u ON u.id = o.user_id SET o.total_amount = 5 WHERE o.id = 7", "", false},
+	}
+
+	for _, tt := range tests {
+		pq, err := parser.Parse(tt.query)
+		require.NoError(t, err)
+		key, ok := pq.RowKey("id")
+		assert.Equal(t, tt.ok, ok, tt.query)
+		assert.Equal(t, tt.want, key, tt.query)
+	}
+}
+
+func TestParsedQueryShadowLookup(t *testing.T) {
+	parser := NewParser(getTestConfig())
+	tableConfig := getTestConfig()["orders"]
+
+	tests := []struct {
+		query    string
+		database string
+		want     string // empty when the row cannot be looked up
+		columns  []string
+	}{
+		{"UPDATE orders SET total_amount = 1500, note = 'x' WHERE id = 7", "", "select total_amount_idn from orders where id = '7' limit 1", []string{"total_amount"}},
+		{"UPDATE orders SET total_amount = 1500 WHERE id = 7", "shop", "select total_amount_idn from shop.orders where id = '7' limit 1", []string{"total_amount"}},
+		{"UPDATE shop.orders SET shipping_fee = 5, total_amount = 9 WHERE status = 'new' AND id = 'a''b'", "other",
+			"select shipping_fee_idn, total_amount_idn from shop.orders where id = 'a\\'b' limit 1", []string{"shipping_fee", "total_amount"}},
+		{"UPDATE orders SET total_amount = 1500 WHERE id IN (1, 2)", "", "", nil},
+		{"UPDATE orders SET total_amount = CASE id WHEN 1 THEN 10 END WHERE id = 1", "", "", nil},
+		{"INSERT INTO orders (id, total_amount) VALUES (7, 1500)", "", "", nil},
+	}
+
+	for _, tt := range tests {
+		pq, err := parser.Parse(tt.query)
+		require.NoError(t, err)
+		query, columns, ok := pq.ShadowLookup(tableConfig, "id", tt.database)
+		assert.Equal(t, tt.want != "", ok, tt.query)
+		assert.Equal(t, tt.want, query, tt.query)
+		assert.Equal(t, tt.columns, columns, tt.query)
+	}
+}
+
+func TestGuessWriteTables(t *testing.T) {
+	parser := NewParser(getTestConfig())
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"INSERT INTO orders (total_amount) VALUES (1) ON DUPLICATE KEY UPDATE x = JSON_VALUE(y)", []string{"orders"}},
+		{"update `orders` set total_amount = 5 where id = 1", []string{"orders"}},
+		{"INSERT INTO orders_archive SELECT * FROM legacy", nil},
+		{"INSERT INTO audit (id, note) SELECT id, JSON_VALUE(x) FROM orders", nil},
+		{"INSERT IGNORE INTO `ecommerce_db`.`orders`(total_amount) VALUES (1)", []string{"orders"}},
+		{"UPDATE LOW_PRIORITY orders SET total_amount = 5", []string{"orders"}},
+		{"UPDATE payments JOIN orders ON payments.order_id = orders.id SET payments.amount = 5", nil},
+		{"SELECT * FROM orders", nil},
+		{"DELETE FROM orders", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.want, parser.GuessWriteTables(tt.query))
+		})
+	}
+}
+
+func TestRewriteNullPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		query       string
+		converted   map[string]float64
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:     "insert propagate",
+			policy:   "",
+			query:    "INSERT INTO orders (total_amount) VALUES (NULL)",
+			contains: []string{"total_amount_idn", "values (null, null)"},
+		},
+		{
+			name:     "insert zero",
+			policy:   config.NullPolicyZero,
+			query:    "INSERT INTO orders (total_amount) VALUES (NULL)",
+			contains: []string{"total_amount_idn", "values (null, 0.0000)"},
+		},
+		{
+			name:        "insert skip",
+			policy:      config.NullPolicySkip,
+			query:       "INSERT INTO orders (total_amount, shipping_fee) VALUES (NULL, 5000)",
+			converted:   map[string]float64{"shipping_fee": 5},
+			contains:    []string{"shipping_fee_idn", "values (null, 5000, 5.0000)"},
+			notContains: []string{"total_amount_idn"},
+		},
+		{
+			name:      "multi-row insert skip",
+			policy:    config.NullPolicySkip,
+			query:     "INSERT INTO orders (total_amount) VALUES (NULL), (5000)",
+			converted: map[string]float64{RowValueKey("total_amount", 1): 5},
+			contains:  []string{"total_amount_idn", "values (null, default), (5000, 5.0000)"},
+		},
+		{
+			name:        "multi-row insert skip every row",
+			policy:      config.NullPolicySkip,
+			query:       "INSERT INTO orders (total_amount) VALUES (NULL), (NULL)",
+			notContains: []string{"total_amount_idn"},
+		},
+		{
+			name:     "update propagate",
+			policy:   config.NullPolicyPropagate,
+			query:    "UPDATE orders SET total_amount = NULL WHERE id = 1",
+			contains: []string{"total_amount_idn = null"},
+		},
+		{
+			name:        "update skip",
+			policy:      config.NullPolicySkip,
+			query:       "UPDATE orders SET total_amount = NULL WHERE id = 1",
+			notContains: []string{"total_amount_idn"},
+		},
+		{
+			name:      "zero is an ordinary amount",
+			policy:    config.NullPolicySkip,
+			query:     "INSERT INTO orders (total_amount) VALUES (0)",
+			converted: map[string]float64{"total_amount": 0},
+			contains:  []string{"values (0, 0.0000)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables := getTestConfig()
+			col := tables["orders"].Columns["total_amount"]
+			col.NullPolicy = tt.policy
+			tables["orders"].Columns["total_amount"] = col
+			parser := NewParser(tables)
+
+			pq, err := parser.Parse(tt.query)
+			require.NoError(t, err)
+			if v, ok := pq.Values["total_amount"]; ok && tt.converted == nil {
+				assert.Nil(t, v)
+			}
+
+			rewritten, err := parser.RewriteForDualWrite(pq, tt.converted)
+			require.NoError(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, rewritten, s)
+			}
+			for _, s := range tt.notContains {
+				assert.NotContains(t, rewritten, s)
+			}
+		})
+	}
+}
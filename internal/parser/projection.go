@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/xwb1989/sqlparser"
+)
+
+// ProjectedColumn is a currency column a SELECT reads from its shadow column
+type ProjectedColumn struct {
+	// Name is the name the result presents: the column's alias, or the
+	// column as written
+	Name string
+	// Column is the currency column and Shadow the column read in its place
+	Column string
+	Shadow string
+}
+
+// ProjectionRewrite is the result of reading the currency columns of a
+// SELECT's select list from their shadow columns
+type ProjectionRewrite struct {
+	// Query is the statement to forward; the original when no column was
+	// replaced
+	Query   string
+	Columns []ProjectedColumn
+}
+
+// RewriteProjection replaces the currency columns in the select list of a
+// SELECT on one table with their shadow columns, aliased to the name the
+// client selected, so results are read by the same names in IDN. Only bare
+// columns are replaced; stars, expressions and aggregates are left as they
+// are. Placeholders are kept, so prepared statements can be rewritten.
+func RewriteProjection(query string, tableConfig config.TableConfig) (*ProjectionRewrite, error) {
+	rewrite := &ProjectionRewrite{Query: query}
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+	sel, name, ok := singleTableSelect(stmt)
+	if !ok {
+		return rewrite, nil
+	}
+
+	for _, expr := range sel.SelectExprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		col, ok := aliased.Expr.(*sqlparser.ColName)
+		if !ok || (!col.Qualifier.IsEmpty() && strings.ToLower(col.Qualifier.Name.String()) != name) {
+			continue
+		}
+		column, colConfig, ok := tableConfig.LookupColumn(col.Name.String())
+		if !ok {
+			continue
+		}
+		if aliased.As.IsEmpty() {
+			aliased.As = col.Name
+		}
+		aliased.Expr = &sqlparser.ColName{Name: sqlparser.NewColIdent(colConfig.TargetColumn), Qualifier: col.Qualifier}
+		rewrite.Columns = append(rewrite.Columns, ProjectedColumn{
+			Name:   aliased.As.String(),
+			Column: column,
+			Shadow: colConfig.TargetColumn,
+		})
+	}
+
+	if len(rewrite.Columns) > 0 {
+		rewrite.Query = formatStatement(stmt)
+	}
+	return rewrite, nil
+}
+
+// formatStatement serializes a statement with its placeholders written as ?,
+// where sqlparser.String numbers them
+func formatStatement(stmt sqlparser.Statement) string {
+	buf := sqlparser.NewTrackedBuffer(func(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+		if val, ok := node.(*sqlparser.SQLVal); ok && val.Type == sqlparser.ValArg {
+			buf.WriteString("?")
+			return
+		}
+		node.Format(buf)
+	})
+	buf.Myprintf("%v", stmt)
+	return buf.String()
+}
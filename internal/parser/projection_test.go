@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteProjection(t *testing.T) {
+	rewrite, err := RewriteProjection("SELECT id, Total_Amount, o.shipping_fee AS fee, SUM(tax), discount * 2 FROM orders o WHERE id = ?", whereTable)
+	require.NoError(t, err)
+	assert.Equal(t, "select id, total_amount_idn as Total_Amount, o.shipping_fee_idn as fee, SUM(tax), discount * 2 from orders as o where id = ?", rewrite.Query)
+	assert.Equal(t, []ProjectedColumn{
+		{Name: "Total_Amount", Column: "total_amount", Shadow: "total_amount_idn"},
+		{Name: "fee", Column: "shipping_fee", Shadow: "shipping_fee_idn"},
+	}, rewrite.Columns)
+
+	for _, query := range []string{
+		"SELECT * FROM orders",
+		"SELECT c.total_amount FROM orders o",
+		"SELECT total_amount FROM orders JOIN customers",
+		"UPDATE orders SET total_amount = 1",
+	} {
+		rewrite, err := RewriteProjection(query, whereTable)
+		require.NoError(t, err)
+		assert.Equal(t, query, rewrite.Query)
+		assert.Empty(t, rewrite.Columns)
+	}
+}
@@ -0,0 +1,17 @@
+package parser
+
+import "strings"
+
+// QuoteIdentifier quotes a table or column name with backticks, for the
+// statements TransisiDB builds itself
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteTable quotes a table name that may be qualified with its database
+func QuoteTable(name string) string {
+	if dot := strings.IndexByte(name, '.'); dot >= 0 {
+		return QuoteIdentifier(name[:dot]) + "." + QuoteIdentifier(name[dot+1:])
+	}
+	return QuoteIdentifier(name)
+}
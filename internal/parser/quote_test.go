@@ -0,0 +1,18 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, "`total_amount`", QuoteIdentifier("total_amount"))
+	assert.Equal(t, "`odd``name`", QuoteIdentifier("odd`name"))
+}
+
+func TestQuoteTable(t *testing.T) {
+	assert.Equal(t, "`orders`", QuoteTable("orders"))
+	assert.Equal(t, "`shop`.`orders`", QuoteTable("shop.orders"))
+	assert.Equal(t, "`shop`.`odd``table`", QuoteTable("shop.odd`table"))
+}
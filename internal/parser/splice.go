@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/xwb1989/sqlparser"
+)
+
+// spliceMinLength is the statement length from which INSERT ... VALUES
+// rewrites splice the shadow columns into the original text. Shorter
+// statements are serialized from the rewritten tree, which is cheap at that
+// size and keeps the canonical form logs and explain show.
+const spliceMinLength = 64 << 10
+
+// insertSpans locates the parts of an INSERT ... VALUES statement that shadow
+// columns are spliced into: the closing parenthesis of the column list and of
+// each row. Quoted strings, quoted identifiers and comments are skipped. ok is
+// false when the statement does not have that shape.
+func insertSpans(query string) (columnsEnd int, rowEnds []int, ok bool) {
+	columnsEnd = -1
+	depth := 0
+	groupStart := -1
+	inValues := false
+	expectRow := false
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'' || c == '"':
+			// Quoted string literal, honoring backslash and doubled-quote escapes
+			quote := c
+			i++
+			for i < len(query) {
+				if query[i] == '\\' {
+					i += 2
+					continue
+				}
+				if query[i] == quote {
+					if i+1 < len(query) && query[i+1] == quote {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			if i >= len(query) {
+				return 0, nil, false
+			}
+
+		case c == '`':
+			i++
+			for i < len(query) && query[i] != '`' {
+				i++
+			}
+			if i >= len(query) {
+				return 0, nil, false
+			}
+
+		case c == '-' && strings.HasPrefix(query[i:], "-- "), c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return 0, nil, false
+			}
+			i += end + 3
+
+		case c == '(':
+			if depth == 0 {
+				if inValues && !expectRow {
+					// A parenthesis after the rows belongs to ON DUPLICATE KEY UPDATE
+					return columnsEnd, rowEnds, len(rowEnds) > 0
+				}
+				groupStart = i
+			}
+			depth++
+
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return 0, nil, false
+			}
+			if depth == 0 {
+				if strings.TrimSpace(query[groupStart+1:i]) == "" {
+					return 0, nil, false
+				}
+				if inValues {
+					rowEnds = append(rowEnds, i)
+					expectRow = false
+				} else {
+					// The last group before VALUES is the column list
+					columnsEnd = i
+				}
+			}
+
+		case depth == 0 && c == ',':
+			if !inValues || expectRow {
+				return 0, nil, false
+			}
+			expectRow = true
+
+		case depth == 0 && isIdentByte(c):
+			start := i
+			for i+1 < len(query) && isIdentByte(query[i+1]) {
+				i++
+			}
+			word := query[start : i+1]
+			if inValues {
+				if expectRow {
+					return 0, nil, false
+				}
+				// The rows end at the first keyword after them
+				return columnsEnd, rowEnds, len(rowEnds) > 0
+			}
+			if strings.EqualFold(word, "VALUES") || strings.EqualFold(word, "VALUE") {
+				if columnsEnd < 0 {
+					return 0, nil, false
+				}
+				inValues = true
+				expectRow = true
+			}
+		}
+	}
+
+	return columnsEnd, rowEnds, inValues && !expectRow && depth == 0 && len(rowEnds) > 0
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// spliceInsert adds the shadow columns and values to the original text of an
+// INSERT ... VALUES statement instead of serializing the rewritten statement,
+// so bulk inserts are copied once into a buffer sized up front. ok is false
+// when the statement text does not match its parsed rows; the caller then
+// rewrites the statement tree.
+func (p *Parser) spliceInsert(stmt *sqlparser.Insert, pq *ParsedQuery,
+	tableConfig config.TableConfig, convertedValues map[string]float64) (string, bool, error) {
+
+	rows, ok := stmt.Rows.(sqlparser.Values)
+	if !ok {
+		return "", false, nil
+	}
+	columnsEnd, rowEnds, ok := insertSpans(pq.Original)
+	if !ok || len(rowEnds) != len(rows) {
+		return "", false, nil
+	}
+
+	var columns strings.Builder
+	values := make([]strings.Builder, len(rowEnds))
+	for _, currencyCol := range pq.CurrencyColumns {
+		colConfig, exists := tableConfig.Columns[currencyCol]
+		if !exists {
+			continue
+		}
+		exprs, ok, err := p.insertShadowValues(colConfig, pq, currencyCol, len(rows), convertedValues)
+		if err != nil {
+			return "", false, err
+		} else if !ok {
+			continue
+		}
+		columns.WriteString(", ")
+		columns.WriteString(sqlparser.String(sqlparser.NewColIdent(colConfig.TargetColumn)))
+		for r, expr := range exprs {
+			values[r].WriteString(", ")
+			if val, ok := expr.(*sqlparser.SQLVal); ok {
+				values[r].Write(val.Val)
+			} else {
+				values[r].WriteString(sqlparser.String(expr))
+			}
+		}
+	}
+
+	query := pq.Original
+	size := len(query) + columns.Len()
+	for r := range values {
+		size += values[r].Len()
+	}
+	var b strings.Builder
+	b.Grow(size)
+	b.WriteString(query[:columnsEnd])
+	b.WriteString(columns.String())
+	prev := columnsEnd
+	for r, end := range rowEnds {
+		b.WriteString(query[prev:end])
+		b.WriteString(values[r].String())
+		prev = end
+	}
+	b.WriteString(query[prev:])
+
+	return b.String(), true, nil
+}
@@ -0,0 +1,203 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xwb1989/sqlparser"
+)
+
+func TestInsertSpans(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		columns string   // text up to the column list's closing parenthesis
+		rows    []string // text of each row, parentheses included
+	}{
+		{
+			name:    "single row",
+			query:   "INSERT INTO orders (customer_id, total_amount) VALUES (7, 1500000)",
+			columns: "INSERT INTO orders (customer_id, total_amount",
+			rows:    []string{"(7, 1500000)"},
+		},
+		{
+			name:    "multiple rows",
+			query:   "insert into orders(total_amount) values(1),(2) , (3)",
+			columns: "insert into orders(total_amount",
+			rows:    []string{"(1)", "(2)", "(3)"},
+		},
+		{
+			name:    "strings with parentheses and quotes",
+			query:   `INSERT INTO orders (note, total_amount) VALUES ('a), (b', 1), ("it''s \" (", 2)`,
+			columns: "INSERT INTO orders (note, total_amount",
+			rows:    []string{"('a), (b', 1)", `("it''s \" (", 2)`},
+		},
+		{
+			name:    "quoted identifiers and functions",
+			query:   "INSERT INTO `order (x)` (`a)`, total_amount) VALUES (NOW(), ROUND(1.5, 0))",
+			columns: "INSERT INTO `order (x)` (`a)`, total_amount",
+			rows:    []string{"(NOW(), ROUND(1.5, 0))"},
+		},
+		{
+			name:    "comments",
+			query:   "INSERT /* (x) */ INTO orders (total_amount) -- (y)\nVALUES # (z)\n(1)",
+			columns: "INSERT /* (x) */ INTO orders (total_amount",
+			rows:    []string{"(1)"},
+		},
+		{
+			name:    "partition before the column list",
+			query:   "INSERT INTO orders PARTITION (p0) (total_amount) VALUE (1)",
+			columns: "INSERT INTO orders PARTITION (p0) (total_amount",
+			rows:    []string{"(1)"},
+		},
+		{
+			name:    "on duplicate key update",
+			query:   "INSERT INTO orders (id, total_amount) VALUES (1, 2), (3, 4) ON DUPLICATE KEY UPDATE total_amount = VALUES(total_amount)",
+			columns: "INSERT INTO orders (id, total_amount",
+			rows:    []string{"(1, 2)", "(3, 4)"},
+		},
+		{name: "no column list", query: "INSERT INTO orders VALUES (1, 2)"},
+		{name: "insert select", query: "INSERT INTO orders (total_amount) SELECT total_amount FROM carts"},
+		{name: "insert set", query: "INSERT INTO orders SET total_amount = 1"},
+		{name: "unterminated string", query: "INSERT INTO orders (note) VALUES ('open"},
+		{name: "missing row", query: "INSERT INTO orders (total_amount) VALUES (1),"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columnsEnd, rowEnds, ok := insertSpans(tt.query)
+			if tt.rows == nil {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tt.columns, tt.query[:columnsEnd])
+
+			require.Len(t, rowEnds, len(tt.rows))
+			for i, end := range rowEnds {
+				assert.True(t, strings.HasSuffix(tt.query[:end+1], tt.rows[i]), "row %d ends %q", i, tt.query[:end+1])
+			}
+		})
+	}
+}
+
+func TestSpliceInsertMatchesTreeRewrite(t *testing.T) {
+	queries := []string{
+		"INSERT INTO orders (customer_id, total_amount, shipping_fee) VALUES (123, 500000, 25000)",
+		"INSERT INTO orders (customer_id, total_amount) VALUES (1, 100000), (2, 9900000), (3, NULL)",
+		"INSERT INTO orders (total_amount, shipping_fee) VALUES (100000, 5000), (9900000, 7000)",
+		"INSERT INTO orders (total_amount, note) VALUES (NULL, 'a), (b')",
+		"INSERT IGNORE INTO `orders` (`total_amount`) VALUES (500000) ON DUPLICATE KEY UPDATE total_amount = VALUES(total_amount)",
+	}
+
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			p := NewParser(getTestConfig())
+			pq, err := p.Parse(query)
+			require.NoError(t, err)
+			require.True(t, pq.NeedsTransform)
+			converted := convertAmounts(pq)
+			stmt := pq.Statement.(*sqlparser.Insert)
+
+			spliced, ok, err := p.spliceInsert(stmt, pq, p.tableConfig["orders"], converted)
+			require.NoError(t, err)
+			require.True(t, ok)
+			tree, err := p.rewriteInsertTree(stmt, pq, p.tableConfig["orders"], converted)
+			require.NoError(t, err)
+
+			// Both rewrites parse to the same statement
+			splicedStmt, err := sqlparser.Parse(spliced)
+			require.NoError(t, err, spliced)
+			assert.Equal(t, tree, sqlparser.String(splicedStmt))
+		})
+	}
+}
+
+// convertAmounts converts the currency values of pq at 1:1000, each row of a
+// multi-row INSERT on its own
+func convertAmounts(pq *ParsedQuery) map[string]float64 {
+	converted := make(map[string]float64)
+	for col, value := range pq.Values {
+		if amount, ok := value.(int64); ok {
+			converted[col] = float64(amount) / 1000
+		}
+	}
+	for col, values := range pq.RowValues {
+		for i, value := range values {
+			if amount, ok := value.(int64); ok {
+				converted[RowValueKey(col, i)] = float64(amount) / 1000
+			}
+		}
+	}
+	return converted
+}
+
+// bulkInsert returns a multi-row INSERT on orders of at least size bytes. Row i
+// inserts an amount of (i%10+1)*100000.
+func bulkInsert(size int) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO orders (customer_id, total_amount, status) VALUES ")
+	for i := 0; b.Len() < size; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "(%d, %d, 'pending')", i, (i%10+1)*100000)
+	}
+	return b.String()
+}
+
+func TestRewriteForDualWrite_SplicesBulkInsert(t *testing.T) {
+	query := bulkInsert(spliceMinLength)
+	p := NewParser(getTestConfig())
+	pq, err := p.Parse(query)
+	require.NoError(t, err)
+
+	rewritten, err := p.RewriteForDualWrite(pq, convertAmounts(pq))
+	require.NoError(t, err)
+
+	// The client's text is kept, only the shadow column and each row's value
+	// are added
+	assert.True(t, strings.HasPrefix(rewritten, "INSERT INTO orders (customer_id, total_amount, status, total_amount_idn) VALUES "+
+		"(0, 100000, 'pending', 100.0000), (1, 200000, 'pending', 200.0000), "))
+	rows := len(pq.Statement.(*sqlparser.Insert).Rows.(sqlparser.Values))
+	shadowLen := 0
+	for i := 0; i < rows; i++ {
+		shadow := fmt.Sprintf("%d, 'pending', %d.0000)", (i%10+1)*100000, (i%10+1)*100)
+		assert.Contains(t, rewritten, fmt.Sprintf("(%d, %s", i, shadow))
+		shadowLen += len(fmt.Sprintf(", %d.0000", (i%10+1)*100))
+	}
+	assert.Len(t, rewritten, len(query)+len(", total_amount_idn")+shadowLen)
+}
+
+func BenchmarkRewriteBulkInsert(b *testing.B) {
+	p := NewParser(getTestConfig())
+
+	for _, size := range []int{1 << 20, 8 << 20} {
+		pq, err := p.Parse(bulkInsert(size))
+		if err != nil {
+			b.Fatal(err)
+		}
+		stmt := pq.Statement.(*sqlparser.Insert)
+		converted := convertAmounts(pq)
+
+		b.Run(fmt.Sprintf("tree/%dMB", size>>20), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.rewriteInsertTree(stmt, pq, p.tableConfig["orders"], converted); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("splice/%dMB", size>>20), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := p.spliceInsert(stmt, pq, p.tableConfig["orders"], converted); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
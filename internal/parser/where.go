@@ -0,0 +1,475 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/rounding"
+	"github.com/xwb1989/sqlparser"
+)
+
+// decimalLiteral matches the amounts RewriteWhereAmounts converts
+var decimalLiteral = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// amountOperators are the comparisons whose amounts are converted, by the
+// operator that applies with the operands swapped. IN has no swapped form;
+// LIKE, REGEXP and the like compare text.
+var amountOperators = map[string]string{
+	sqlparser.EqualStr:         sqlparser.EqualStr,
+	sqlparser.NotEqualStr:      sqlparser.NotEqualStr,
+	sqlparser.NullSafeEqualStr: sqlparser.NullSafeEqualStr,
+	sqlparser.LessThanStr:      sqlparser.GreaterThanStr,
+	sqlparser.GreaterThanStr:   sqlparser.LessThanStr,
+	sqlparser.LessEqualStr:     sqlparser.GreaterEqualStr,
+	sqlparser.GreaterEqualStr:  sqlparser.LessEqualStr,
+	sqlparser.InStr:            "",
+	sqlparser.NotInStr:         "",
+}
+
+// WhereRewrite is the result of converting the IDN amounts of a SELECT's
+// WHERE clause
+type WhereRewrite struct {
+	// Query is the statement to forward; the original when nothing was
+	// converted
+	Query string
+	// Converted counts the amounts converted to IDR
+	Converted int
+	// Unconverted lists the comparisons on currency columns whose other side
+	// is not a literal amount, such as placeholders or expressions, or that
+	// are nested in functions, and are forwarded as written
+	Unconverted []string
+}
+
+// RewriteWhereAmounts converts the literal amounts that the WHERE clause of a
+// SELECT on one table compares its currency columns with from IDN to IDR.
+//
+// The client means the IDN amounts the shadow columns hold, rounded to each
+// column's decimals, so a bound covers every IDR amount that rounds to it:
+// lower bounds move down to the first such amount and upper bounds up to the
+// last, in steps of the source type's decimals. An equality becomes the range
+// of amounts rounding to the value, and an IN list one range per value.
+// Columns with a floating-point source type have their amounts multiplied by
+// the ratio instead.
+//
+// Comparison operators, IN lists and BETWEEN are handled, with the column on
+// either side of a comparison. Statements with placeholders, which would not
+// survive serialization, and other statements are returned as they are.
+func RewriteWhereAmounts(query string, tableConfig config.TableConfig, conv config.ConversionConfig) (*WhereRewrite, error) {
+	rewrite := &WhereRewrite{Query: query}
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+	sel, name, ok := singleTableSelect(stmt)
+	if !ok || sel.Where == nil {
+		return rewrite, nil
+	}
+
+	w := &whereRewriter{
+		tableConfig: tableConfig,
+		conv:        conv,
+		name:        name,
+		rewrite:     rewrite,
+	}
+	sel.Where.Expr = w.rewriteExpr(sel.Where.Expr)
+
+	if hasPlaceholder(stmt) {
+		rewrite.Converted = 0
+		rewrite.Unconverted = w.compared
+		return rewrite, nil
+	}
+	if rewrite.Converted > 0 {
+		rewrite.Query = sqlparser.String(stmt)
+	}
+	return rewrite, nil
+}
+
+// singleTableSelect returns a SELECT on one table and the name, lowercased,
+// its columns may be qualified with: the table's alias or name
+func singleTableSelect(stmt sqlparser.Statement) (*sqlparser.Select, string, bool) {
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || len(sel.From) != 1 {
+		return nil, "", false
+	}
+	aliased, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil, "", false
+	}
+	table, ok := aliased.Expr.(sqlparser.TableName)
+	if !ok {
+		return nil, "", false
+	}
+	if !aliased.As.IsEmpty() {
+		return sel, strings.ToLower(aliased.As.String()), true
+	}
+	return sel, strings.ToLower(table.Name.String()), true
+}
+
+// whereRewriter converts the amounts of one WHERE clause
+type whereRewriter struct {
+	tableConfig config.TableConfig
+	conv        config.ConversionConfig
+	// name is the name columns of the table may be qualified with
+	name    string
+	rewrite *WhereRewrite
+	// compared lists the comparisons on currency columns as written
+	compared []string
+}
+
+// rewriteExpr returns expr with the amounts of its comparisons converted.
+// Only comparisons combined with AND, OR, NOT, IS and parentheses are
+// rewritten; those nested anywhere else are reported.
+func (w *whereRewriter) rewriteExpr(expr sqlparser.Expr) sqlparser.Expr {
+	switch e := expr.(type) {
+	case *sqlparser.AndExpr:
+		e.Left, e.Right = w.rewriteExpr(e.Left), w.rewriteExpr(e.Right)
+	case *sqlparser.OrExpr:
+		e.Left, e.Right = w.rewriteExpr(e.Left), w.rewriteExpr(e.Right)
+	case *sqlparser.NotExpr:
+		e.Expr = w.rewriteExpr(e.Expr)
+	case *sqlparser.ParenExpr:
+		e.Expr = w.rewriteExpr(e.Expr)
+	case *sqlparser.IsExpr:
+		e.Expr = w.rewriteExpr(e.Expr)
+	case *sqlparser.ComparisonExpr:
+		swapped, isAmount := amountOperators[e.Operator]
+		if col, ok := w.currencyColumn(e.Left); ok && isAmount {
+			return w.comparison(e, col, e.Operator, e.Right)
+		}
+		if col, ok := w.currencyColumn(e.Right); ok && swapped != "" {
+			return w.comparison(e, col, swapped, e.Left)
+		}
+		w.reportNested(e)
+	case *sqlparser.RangeCond:
+		if col, ok := w.currencyColumn(e.Left); ok {
+			return w.between(e, col)
+		}
+		w.reportNested(e)
+	default:
+		w.reportNested(expr)
+	}
+	return expr
+}
+
+// currencyColumn returns the currency column expr names, if it is one
+func (w *whereRewriter) currencyColumn(expr sqlparser.Expr) (*sqlparser.ColName, bool) {
+	col, ok := expr.(*sqlparser.ColName)
+	if !ok {
+		return nil, false
+	}
+	if !col.Qualifier.IsEmpty() && strings.ToLower(col.Qualifier.Name.String()) != w.name {
+		return nil, false
+	}
+	_, _, ok = w.tableConfig.LookupColumn(col.Name.String())
+	return col, ok
+}
+
+// comparison converts a comparison of col, with op, to value
+func (w *whereRewriter) comparison(cond *sqlparser.ComparisonExpr, col *sqlparser.ColName, op string, value sqlparser.Expr) sqlparser.Expr {
+	w.compared = appendUnique(w.compared, sqlparser.String(cond))
+	values := sqlparser.ValTuple{value}
+	if tuple, ok := value.(sqlparser.ValTuple); ok {
+		values = tuple
+	}
+	amounts := make([]*big.Rat, len(values))
+	converted := 0
+	for i, v := range values {
+		if _, ok := v.(*sqlparser.NullVal); ok {
+			continue
+		}
+		amount, ok := literalAmount(v)
+		if !ok {
+			w.rewrite.Unconverted = appendUnique(w.rewrite.Unconverted, sqlparser.String(cond))
+			return cond
+		}
+		amounts[i] = amount
+		converted++
+	}
+	if converted == 0 {
+		return cond
+	}
+	w.rewrite.Converted += converted
+
+	scale := w.scaleFor(col)
+	if scale.step == nil {
+		exact := make(sqlparser.ValTuple, len(values))
+		for i, amount := range amounts {
+			exact[i] = values[i]
+			if amount != nil {
+				exact[i] = scale.multiply(amount)
+			}
+		}
+		if _, ok := value.(sqlparser.ValTuple); ok {
+			return &sqlparser.ComparisonExpr{Operator: op, Left: col, Right: exact}
+		}
+		return &sqlparser.ComparisonExpr{Operator: op, Left: col, Right: exact[0]}
+	}
+
+	switch op {
+	case sqlparser.GreaterEqualStr:
+		return &sqlparser.ComparisonExpr{Operator: op, Left: col, Right: scale.lower(ceilIndex(scale.index(amounts[0])))}
+	case sqlparser.GreaterThanStr:
+		next := new(big.Int).Add(floorIndex(scale.index(amounts[0])), big.NewInt(1))
+		return &sqlparser.ComparisonExpr{Operator: sqlparser.GreaterEqualStr, Left: col, Right: scale.lower(next)}
+	case sqlparser.LessEqualStr:
+		return &sqlparser.ComparisonExpr{Operator: op, Left: col, Right: scale.upper(floorIndex(scale.index(amounts[0])))}
+	case sqlparser.LessThanStr:
+		prev := new(big.Int).Sub(ceilIndex(scale.index(amounts[0])), big.NewInt(1))
+		return &sqlparser.ComparisonExpr{Operator: sqlparser.LessEqualStr, Left: col, Right: scale.upper(prev)}
+	case sqlparser.EqualStr:
+		return scale.equal(col, sqlparser.BetweenStr, amounts[0])
+	case sqlparser.NotEqualStr:
+		return scale.equal(col, sqlparser.NotBetweenStr, amounts[0])
+	case sqlparser.NullSafeEqualStr:
+		// BETWEEN is NULL for a NULL column where <=> is false
+		return &sqlparser.IsExpr{Operator: sqlparser.IsTrueStr, Expr: &sqlparser.ParenExpr{Expr: scale.equal(col, sqlparser.BetweenStr, amounts[0])}}
+	}
+
+	// IN and NOT IN: one range per value, and NULLs compared as written
+	var anyOf sqlparser.Expr
+	for i, amount := range amounts {
+		var term sqlparser.Expr = &sqlparser.ComparisonExpr{Operator: sqlparser.EqualStr, Left: col, Right: values[i]}
+		if amount != nil {
+			term = scale.equal(col, sqlparser.BetweenStr, amount)
+		}
+		if anyOf == nil {
+			anyOf = term
+		} else {
+			anyOf = &sqlparser.OrExpr{Left: anyOf, Right: term}
+		}
+	}
+	anyOf = &sqlparser.ParenExpr{Expr: anyOf}
+	if op == sqlparser.NotInStr {
+		return &sqlparser.NotExpr{Expr: anyOf}
+	}
+	return anyOf
+}
+
+// between converts the bounds of a BETWEEN on col
+func (w *whereRewriter) between(cond *sqlparser.RangeCond, col *sqlparser.ColName) sqlparser.Expr {
+	w.compared = appendUnique(w.compared, sqlparser.String(cond))
+	from, fromOK := literalAmount(cond.From)
+	to, toOK := literalAmount(cond.To)
+	if !fromOK || !toOK {
+		w.rewrite.Unconverted = appendUnique(w.rewrite.Unconverted, sqlparser.String(cond))
+		return cond
+	}
+	w.rewrite.Converted += 2
+
+	scale := w.scaleFor(col)
+	if scale.step == nil {
+		return &sqlparser.RangeCond{Operator: cond.Operator, Left: col, From: scale.multiply(from), To: scale.multiply(to)}
+	}
+	return &sqlparser.RangeCond{
+		Operator: cond.Operator,
+		Left:     col,
+		From:     scale.lower(ceilIndex(scale.index(from))),
+		To:       scale.upper(floorIndex(scale.index(to))),
+	}
+}
+
+// reportNested records the comparisons on currency columns within expr,
+// which are forwarded as written
+func (w *whereRewriter) reportNested(expr sqlparser.Expr) {
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch n := node.(type) {
+		case *sqlparser.Subquery:
+			// Subqueries read other tables, or this one under another name
+			return false, nil
+		case *sqlparser.ComparisonExpr:
+			_, isAmount := amountOperators[n.Operator]
+			_, left := w.currencyColumn(n.Left)
+			_, right := w.currencyColumn(n.Right)
+			if isAmount && (left || right) {
+				w.compared = appendUnique(w.compared, sqlparser.String(n))
+				w.rewrite.Unconverted = appendUnique(w.rewrite.Unconverted, sqlparser.String(n))
+			}
+		case *sqlparser.RangeCond:
+			if _, ok := w.currencyColumn(n.Left); ok {
+				w.compared = appendUnique(w.compared, sqlparser.String(n))
+				w.rewrite.Unconverted = appendUnique(w.rewrite.Unconverted, sqlparser.String(n))
+			}
+		}
+		return true, nil
+	}, expr)
+}
+
+// scaleFor returns how the amounts compared with col are converted
+func (w *whereRewriter) scaleFor(col *sqlparser.ColName) amountScale {
+	_, colConfig, _ := w.tableConfig.LookupColumn(col.Name.String())
+	strategy := colConfig.EffectiveRoundingStrategy(w.conv.RoundingStrategy)
+	scale := amountScale{
+		ratio:   big.NewRat(int64(w.conv.Ratio), 1),
+		unit:    new(big.Rat).SetFrac(big.NewInt(1), pow10(ShadowDecimals(colConfig, w.conv.Precision))),
+		bankers: strategy != string(rounding.ArithmeticRound),
+	}
+	_, sourceScale, isDecimal := config.ParseDecimalType(colConfig.SourceType)
+	switch {
+	case colConfig.SourceType == "" || config.IsIntegerType(colConfig.SourceType):
+		scale.step = big.NewRat(1, 1)
+	case isDecimal:
+		scale.step = new(big.Rat).SetFrac(big.NewInt(1), pow10(sourceScale))
+		scale.decimals = sourceScale
+	}
+	return scale
+}
+
+// amountScale converts the IDN amounts compared with one column to the IDR
+// amounts it stores
+type amountScale struct {
+	ratio *big.Rat
+	// unit is the smallest IDN amount the shadow column holds
+	unit *big.Rat
+	// step is the smallest IDR amount the source column holds and decimals
+	// its decimals; step is nil for floating-point source types
+	step     *big.Rat
+	decimals int
+	// bankers rounds halves to even, otherwise away from zero
+	bankers bool
+}
+
+// index returns amount in IDN units
+func (s amountScale) index(amount *big.Rat) *big.Rat {
+	return new(big.Rat).Quo(amount, s.unit)
+}
+
+// lower returns the least IDR amount whose IDN amount rounds to g units or
+// more
+func (s amountScale) lower(g *big.Int) sqlparser.Expr {
+	k := new(big.Int).Sub(g, big.NewInt(1))
+	edge := s.tie(k)
+	bound := s.ceilStep(edge)
+	if bound.Cmp(edge) == 0 && !s.tieRoundsUp(k) {
+		bound.Add(bound, s.step)
+	}
+	return decimalVal(bound, s.decimals)
+}
+
+// upper returns the greatest IDR amount whose IDN amount rounds to g units or
+// less
+func (s amountScale) upper(g *big.Int) sqlparser.Expr {
+	edge := s.tie(g)
+	bound := s.floorStep(edge)
+	if bound.Cmp(edge) == 0 && s.tieRoundsUp(g) {
+		bound.Sub(bound, s.step)
+	}
+	return decimalVal(bound, s.decimals)
+}
+
+// equal returns the range, with a BETWEEN operator, of IDR amounts whose IDN
+// amount rounds to amount. It is empty when amount has more decimals than
+// the shadow column.
+func (s amountScale) equal(col *sqlparser.ColName, op string, amount *big.Rat) sqlparser.Expr {
+	index := s.index(amount)
+	return &sqlparser.RangeCond{Operator: op, Left: col, From: s.lower(ceilIndex(index)), To: s.upper(floorIndex(index))}
+}
+
+// tie returns the IDR amount halfway between k and k+1 IDN units
+func (s amountScale) tie(k *big.Int) *big.Rat {
+	half := new(big.Rat).Add(new(big.Rat).SetInt(k), big.NewRat(1, 2))
+	half.Mul(half, s.unit)
+	return half.Mul(half, s.ratio)
+}
+
+// tieRoundsUp reports whether the amount halfway between k and k+1 IDN units
+// rounds to k+1, as the rounding engine does
+func (s amountScale) tieRoundsUp(k *big.Int) bool {
+	if s.bankers {
+		return k.Bit(0) == 1
+	}
+	return k.Sign() >= 0
+}
+
+func (s amountScale) floorStep(x *big.Rat) *big.Rat {
+	steps := floorIndex(new(big.Rat).Quo(x, s.step))
+	return new(big.Rat).Mul(new(big.Rat).SetInt(steps), s.step)
+}
+
+func (s amountScale) ceilStep(x *big.Rat) *big.Rat {
+	steps := ceilIndex(new(big.Rat).Quo(x, s.step))
+	return new(big.Rat).Mul(new(big.Rat).SetInt(steps), s.step)
+}
+
+// multiply converts amount exactly
+func (s amountScale) multiply(amount *big.Rat) sqlparser.Expr {
+	idr := new(big.Rat).Mul(amount, s.ratio)
+	decimals := 0
+	for d := new(big.Rat).Set(idr); !d.IsInt(); d.Mul(d, big.NewRat(10, 1)) {
+		decimals++
+	}
+	return decimalVal(idr, decimals)
+}
+
+func floorIndex(x *big.Rat) *big.Int {
+	// Div is Euclidean division, which floors for the positive denominator
+	return new(big.Int).Div(x.Num(), x.Denom())
+}
+
+func ceilIndex(x *big.Rat) *big.Int {
+	return new(big.Int).Neg(floorIndex(new(big.Rat).Neg(x)))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// decimalVal returns a numeric literal of x, which has at most decimals
+// decimals
+func decimalVal(x *big.Rat, decimals int) sqlparser.Expr {
+	if decimals == 0 {
+		return sqlparser.NewIntVal([]byte(x.FloatString(0)))
+	}
+	return sqlparser.NewFloatVal([]byte(x.FloatString(decimals)))
+}
+
+// literalAmount returns the amount of a numeric or quoted literal, possibly
+// negated
+func literalAmount(expr sqlparser.Expr) (*big.Rat, bool) {
+	switch e := expr.(type) {
+	case *sqlparser.UnaryExpr:
+		if e.Operator != sqlparser.UMinusStr {
+			return nil, false
+		}
+		amount, ok := literalAmount(e.Expr)
+		if !ok {
+			return nil, false
+		}
+		return amount.Neg(amount), true
+	case *sqlparser.SQLVal:
+		switch e.Type {
+		case sqlparser.IntVal, sqlparser.FloatVal, sqlparser.StrVal:
+		default:
+			return nil, false
+		}
+		amount := strings.TrimSpace(string(e.Val))
+		if !decimalLiteral.MatchString(amount) {
+			return nil, false
+		}
+		return new(big.Rat).SetString(amount)
+	}
+	return nil, false
+}
+
+// hasPlaceholder reports whether a statement has a ? placeholder
+func hasPlaceholder(stmt sqlparser.Statement) bool {
+	found := false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if val, ok := node.(*sqlparser.SQLVal); ok && val.Type == sqlparser.ValArg {
+			found = true
+		}
+		return !found, nil
+	}, stmt)
+	return found
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, seen := range list {
+		if seen == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/rounding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xwb1989/sqlparser"
+)
+
+var whereConv = config.ConversionConfig{Ratio: 1000, Precision: 2, RoundingStrategy: "BANKERS_ROUND"}
+
+var whereTable = config.TableConfig{Enabled: true, Columns: map[string]config.ColumnConfig{
+	"total_amount": {TargetColumn: "total_amount_idn", Precision: 2},
+	"shipping_fee": {TargetColumn: "shipping_fee_idn", Precision: 1, RoundingStrategy: "ARITHMETIC_ROUND"},
+	"tax":          {TargetColumn: "tax_idn", SourceType: "DECIMAL(19,2)", Precision: 2},
+	"discount":     {TargetColumn: "discount_idn", SourceType: "DOUBLE", Precision: 2},
+}}
+
+func TestRewriteWhereAmounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		want      string
+		converted int
+	}{
+		// 1.00 IDN holds 995 through 1005 IDR under banker's rounding
+		{"equality", "SELECT * FROM orders WHERE total_amount = 1",
+			"select * from orders where total_amount between 995 and 1005", 1},
+		{"not equal", "SELECT * FROM orders WHERE total_amount != 1.01",
+			"select * from orders where total_amount not between 1006 and 1014", 1},
+		{"null-safe", "SELECT * FROM orders WHERE total_amount <=> 1",
+			"select * from orders where (total_amount between 995 and 1005) is true", 1},
+		{"more decimals than the shadow column", "SELECT * FROM orders WHERE total_amount = 1.001",
+			"select * from orders where total_amount between 1006 and 1005", 1},
+		{"ranges", "SELECT id FROM orders WHERE total_amount >= 1 AND total_amount < 2 OR total_amount > 3 OR total_amount <= 4",
+			"select id from orders where total_amount >= 995 and total_amount <= 1994 or total_amount >= 3006 or total_amount <= 4005", 4},
+		{"bounds between shadow values", "SELECT id FROM orders WHERE total_amount >= 1.001 AND total_amount <= 1.019",
+			"select id from orders where total_amount >= 1006 and total_amount <= 1014", 2},
+		{"reversed operands", "SELECT id FROM orders WHERE '1.5' < total_amount",
+			"select id from orders where total_amount >= 1506", 1},
+		{"between", "SELECT id FROM orders o WHERE o.total_amount BETWEEN 1 AND 2",
+			"select id from orders as o where o.total_amount between 995 and 2005", 2},
+		{"not between", "SELECT id FROM orders WHERE total_amount NOT BETWEEN -2 AND -1",
+			"select id from orders where total_amount not between -2005 and -995", 2},
+		{"in", "SELECT id FROM orders WHERE total_amount IN (1, 2, NULL)",
+			"select id from orders where (total_amount between 995 and 1005 or total_amount between 1995 and 2005 or total_amount = null)", 2},
+		{"not in", "SELECT id FROM orders WHERE total_amount NOT IN (1)",
+			"select id from orders where not (total_amount between 995 and 1005)", 1},
+		// Halves round away from zero at one decimal
+		{"arithmetic rounding", "SELECT id FROM orders WHERE shipping_fee = 1 OR shipping_fee = -1",
+			"select id from orders where shipping_fee between 950 and 1049 or shipping_fee between -1049 and -950", 2},
+		{"decimal source", "SELECT id FROM orders WHERE tax >= 1 AND tax <= 1",
+			"select id from orders where tax >= 995.00 and tax <= 1005.00", 2},
+		{"floating-point source", "SELECT id FROM orders WHERE discount BETWEEN 1.5 AND 2.0005",
+			"select id from orders where discount between 1500 and 2000.5", 2},
+		{"other columns", "SELECT * FROM orders WHERE customer_id = 5 AND total_amount LIKE '5%'",
+			"SELECT * FROM orders WHERE customer_id = 5 AND total_amount LIKE '5%'", 0},
+		{"other qualifier", "SELECT * FROM orders o WHERE c.total_amount = 5",
+			"SELECT * FROM orders o WHERE c.total_amount = 5", 0},
+		{"subquery", "SELECT * FROM orders WHERE id IN (SELECT order_id FROM refunds WHERE total_amount = 5)",
+			"SELECT * FROM orders WHERE id IN (SELECT order_id FROM refunds WHERE total_amount = 5)", 0},
+		{"join", "SELECT * FROM orders JOIN customers WHERE total_amount = 5",
+			"SELECT * FROM orders JOIN customers WHERE total_amount = 5", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewrite, err := RewriteWhereAmounts(tt.query, whereTable, whereConv)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rewrite.Query)
+			assert.Equal(t, tt.converted, rewrite.Converted)
+			assert.Empty(t, rewrite.Unconverted)
+		})
+	}
+
+	// Expressions and comparisons inside functions cannot be converted and
+	// are reported
+	rewrite, err := RewriteWhereAmounts("SELECT * FROM orders WHERE shipping_fee > total_amount / 10 AND IF(total_amount > 5, 1, 0) AND total_amount < 7", whereTable, whereConv)
+	require.NoError(t, err)
+	assert.Equal(t, "select * from orders where shipping_fee > total_amount / 10 and if(total_amount > 5, 1, 0) and total_amount <= 6994", rewrite.Query)
+	assert.Equal(t, 1, rewrite.Converted)
+	assert.Equal(t, []string{"shipping_fee > total_amount / 10", "total_amount > 5"}, rewrite.Unconverted)
+
+	// Statements with placeholders are left as written
+	query := "SELECT * FROM orders WHERE total_amount = ? AND shipping_fee < 7"
+	rewrite, err = RewriteWhereAmounts(query, whereTable, whereConv)
+	require.NoError(t, err)
+	assert.Equal(t, query, rewrite.Query)
+	assert.Zero(t, rewrite.Converted)
+	assert.Equal(t, []string{"total_amount = :v1", "shipping_fee < 7"}, rewrite.Unconverted)
+
+	_, err = RewriteWhereAmounts("SELECT FROM", whereTable, whereConv)
+	assert.Error(t, err)
+}
+
+// TestRewriteWhereAmounts_Boundaries checks, for every IDR amount around the
+// bounds, that the rewritten predicate holds exactly when the amount's
+// converted IDN value satisfies the original one
+func TestRewriteWhereAmounts_Boundaries(t *testing.T) {
+	for _, column := range []string{"total_amount", "shipping_fee"} {
+		colConfig := whereTable.Columns[column]
+		engine := rounding.NewEngine(rounding.Strategy(colConfig.EffectiveRoundingStrategy(whereConv.RoundingStrategy)), ShadowDecimals(colConfig, whereConv.Precision))
+		for _, op := range []string{"=", "!=", "<", "<=", ">", ">="} {
+			for _, literal := range []string{"0", "1", "1.5", "1.04", "1.05", "1.055", "-1.05", "-2", "2.5"} {
+				query := fmt.Sprintf("SELECT id FROM orders WHERE %s %s %s", column, op, literal)
+				rewrite, err := RewriteWhereAmounts(query, whereTable, whereConv)
+				require.NoError(t, err)
+				predicate := parseWhere(t, rewrite.Query)
+				bound, _ := new(big.Rat).SetString(literal)
+
+				for idr := int64(-3500); idr <= 3500; idr++ {
+					idn, _ := new(big.Rat).SetString(strconv.FormatFloat(engine.ConvertIDRtoIDN(idr, whereConv.Ratio), 'f', -1, 64))
+					want := compare(idn.Cmp(bound), op)
+					if got := evalPredicate(t, predicate, idr); got != want {
+						t.Fatalf("%s: %d IDR (%s IDN): rewritten %q gives %v, want %v", query, idr, idn.FloatString(2), rewrite.Query, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func parseWhere(t *testing.T, query string) sqlparser.Expr {
+	stmt, err := sqlparser.Parse(query)
+	require.NoError(t, err)
+	return stmt.(*sqlparser.Select).Where.Expr
+}
+
+func compare(cmp int, op string) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+// evalPredicate evaluates the rewritten comparisons of one column with idr
+func evalPredicate(t *testing.T, expr sqlparser.Expr, idr int64) bool {
+	literal := func(e sqlparser.Expr) int64 {
+		n, err := strconv.ParseInt(strings.TrimSpace(sqlparser.String(e)), 10, 64)
+		require.NoError(t, err)
+		return n
+	}
+	switch e := expr.(type) {
+	case *sqlparser.ComparisonExpr:
+		return compare(big.NewInt(idr).Cmp(big.NewInt(literal(e.Right))), e.Operator)
+	case *sqlparser.RangeCond:
+		in := idr >= literal(e.From) && idr <= literal(e.To)
+		return in == (e.Operator == sqlparser.BetweenStr)
+	}
+	t.Fatalf("unexpected expression %s", sqlparser.String(expr))
+	return false
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// Accept results recorded for each accepted client connection
+const (
+	acceptAccepted    = "accepted"
+	acceptRateLimited = "rate_limited"
+	acceptBacklogFull = "backlog_full"
+	acceptTooMany     = "too_many"
+)
+
+// Backoff after a failed accept, e.g. when the process is out of file
+// descriptors, so the loop does not spin
+const (
+	minAcceptBackoff = 5 * time.Millisecond
+	maxAcceptBackoff = time.Second
+)
+
+// acceptLimiter is a token bucket bounding the rate connections are accepted
+// at on one listener
+type acceptLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newAcceptLimiter returns a limiter accepting rate connections per second,
+// burst at once; nil when rate is 0
+func newAcceptLimiter(rate float64, burst int) *acceptLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &acceptLimiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow reports whether a connection accepted at now is within the rate. A
+// nil limiter allows every connection.
+func (l *acceptLimiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// nextAcceptBackoff returns how long to wait after a failed accept, doubling
+// the previous wait
+func nextAcceptBackoff(previous time.Duration) time.Duration {
+	if previous == 0 {
+		return minAcceptBackoff
+	}
+	return min(2*previous, maxAcceptBackoff)
+}
+
+// resetConnection closes a connection the proxy does not serve. TCP
+// connections are reset rather than closed gracefully, so the client fails
+// at once and the socket does not linger in TIME_WAIT.
+func resetConnection(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
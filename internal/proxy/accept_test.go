@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+func TestAcceptLimiter(t *testing.T) {
+	if newAcceptLimiter(0, 10) != nil {
+		t.Fatal("expected no limiter without a rate")
+	}
+	var unlimited *acceptLimiter
+	if !unlimited.allow(time.Now()) {
+		t.Fatal("expected a nil limiter to allow connections")
+	}
+
+	// Two per second, bursts of three
+	limiter := newAcceptLimiter(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !limiter.allow(now) {
+			t.Fatalf("expected connection %d of the burst to be allowed", i+1)
+		}
+	}
+	if limiter.allow(now) {
+		t.Fatal("expected a connection beyond the burst to be rejected")
+	}
+	if !limiter.allow(now.Add(500 * time.Millisecond)) {
+		t.Fatal("expected a connection to be allowed after half a second")
+	}
+	if limiter.allow(now.Add(600 * time.Millisecond)) {
+		t.Fatal("expected the rate to be enforced")
+	}
+	// Tokens do not accumulate beyond the burst
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		limiter.allow(later)
+	}
+	if limiter.allow(later) {
+		t.Fatal("expected the burst to cap the tokens saved up")
+	}
+
+	// The burst defaults to the rate
+	if limiter := newAcceptLimiter(0.5, 0); limiter.burst != 1 {
+		t.Errorf("expected a burst of 1, got %v", limiter.burst)
+	}
+}
+
+func TestNextAcceptBackoff(t *testing.T) {
+	var backoff time.Duration
+	for _, want := range []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond} {
+		backoff = nextAcceptBackoff(backoff)
+		if backoff != want {
+			t.Fatalf("expected %v, got %v", want, backoff)
+		}
+	}
+	if got := nextAcceptBackoff(800 * time.Millisecond); got != time.Second {
+		t.Errorf("expected the backoff to stop at a second, got %v", got)
+	}
+}
+
+func TestServer_Admit(t *testing.T) {
+	s := &Server{config: &config.Config{Proxy: config.ProxyConfig{Accept: config.AcceptConfig{Rate: 1, Burst: 1, MaxPending: 2}}}}
+	ln := s.clientListener(nil, config.ListenerConfig{Name: "default"}, s.config)
+
+	if got := s.admit(ln); got != acceptAccepted {
+		t.Fatalf("expected the first connection to be accepted, got %s", got)
+	}
+	if got := s.admit(ln); got != acceptRateLimited {
+		t.Fatalf("expected the second connection to be rate limited, got %s", got)
+	}
+
+	s.pending.Store(2)
+	if got := s.admit(ln); got != acceptBacklogFull {
+		t.Fatalf("expected connections beyond max_pending to be rejected, got %s", got)
+	}
+}
+
+func TestResetConnection(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	resetConnection(server)
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = client.Read(make([]byte, 1))
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("expected the client to see a reset, got %v", err)
+	}
+}
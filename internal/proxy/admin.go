@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/dualwrite"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultMetricsPath is used when monitoring.metrics_path is empty
+const DefaultMetricsPath = "/metrics"
+
+// AdminHandler serves the proxy's process-local state (Prometheus metrics,
+// recent parser failures, active sessions, shadow column proposals, canary
+// mismatches, combined statistics, dual-write rates, readiness, statement explanations) to the management API, to scrapers and to load
+// balancers
+func (s *Server) AdminHandler() http.Handler {
+	metricsPath := s.config.Monitoring.MetricsPath
+	if metricsPath == "" {
+		metricsPath = DefaultMetricsPath
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.Handler())
+	mux.HandleFunc("/parser/failures", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, parser.RecentFailures())
+	})
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.Sessions())
+	})
+	mux.HandleFunc("/ddl/proposals", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.ShadowProposals())
+	})
+	mux.HandleFunc("/canary/mismatches", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.CanaryMismatches())
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.Stats())
+	})
+	mux.HandleFunc("/dual-writes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.DualWriteRates())
+	})
+	mux.HandleFunc("/explain", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if query == "" {
+			writeJSONStatus(w, http.StatusBadRequest, map[string]string{"error": "query is required"})
+			return
+		}
+		writeJSON(w, s.Explain(query))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		// A degraded backend still serves; only a failed probe takes the
+		// proxy out of rotation
+		status := s.Readiness()
+		code := http.StatusOK
+		if status.Status == ProbeUnavailable {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSONStatus(w, code, status)
+	})
+	return mux
+}
+
+// Explain traces how the proxy would rewrite query, with the tables enabled,
+// rolled out or switched to observe at runtime
+func (s *Server) Explain(query string) *dualwrite.Explanation {
+	cfg := *s.config
+	if s.toggles == nil {
+		return dualwrite.Explain(&cfg, query)
+	}
+	cfg.Tables = make(config.TablesConfig, len(s.config.Tables))
+	for name, tableConfig := range s.config.Tables {
+		tableConfig.Enabled = s.toggles.enabled(name, tableConfig.Enabled)
+		tableConfig.Rollout = s.toggles.rollout(name, tableConfig.Rollout)
+		tableConfig.Mode = s.toggles.mode(name, tableConfig.Mode)
+		cfg.Tables[name] = tableConfig
+	}
+	return dualwrite.Explain(&cfg, query)
+}
+
+// writeJSON writes v as a JSON response body
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+// writeJSONStatus writes v as a JSON response body with the status code
+func writeJSONStatus(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn("Failed to write admin response", "error", err)
+	}
+}
+
+// StartAdmin serves AdminHandler on monitoring.prometheus_port until the
+// proxy is stopped. It is a no-op when Prometheus is disabled.
+func (s *Server) StartAdmin() error {
+	if !s.config.Monitoring.PrometheusEnabled {
+		return nil
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.config.Proxy.Host, s.config.Monitoring.PrometheusPort)
+	httpServer := &http.Server{
+		Addr:         addr,
+		Handler:      s.AdminHandler(),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+
+	s.mu.Lock()
+	s.adminServer = httpServer
+	s.mu.Unlock()
+
+	logger.Info("Proxy admin endpoint listening", "address", addr)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("proxy admin endpoint failed: %w", err)
+	}
+	return nil
+}
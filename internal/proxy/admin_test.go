@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/dualwrite"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+func TestServer_AdminHandler_ServesMetrics(t *testing.T) {
+	metrics.RecordCommandDuration("COM_PING", time.Millisecond)
+
+	server := &Server{config: &config.Config{Monitoring: config.MonitoringConfig{MetricsPath: "/metrics"}}}
+	ts := httptest.NewServer(server.AdminHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), `transisidb_command_duration_seconds_count{command="COM_PING"}`) {
+		t.Error("expected proxy metrics to include the command duration histogram")
+	}
+	if !strings.Contains(string(body), "transisidb_bytes_buffered") {
+		t.Error("expected proxy metrics to include the bytes buffered gauge")
+	}
+}
+
+func TestServer_AdminHandler_ServesParserFailures(t *testing.T) {
+	parser.NewParser(nil).Parse("SELEC broken")
+
+	server := &Server{config: &config.Config{Monitoring: config.MonitoringConfig{MetricsPath: "/metrics"}}}
+	ts := httptest.NewServer(server.AdminHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/parser/failures")
+	if err != nil {
+		t.Fatalf("GET /parser/failures failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var failures []parser.FailureSample
+	if err := json.NewDecoder(resp.Body).Decode(&failures); err != nil {
+		t.Fatalf("failed to decode failures: %v", err)
+	}
+	if len(failures) == 0 || failures[0].Reason != parser.FailureReasonSyntax {
+		t.Errorf("expected the latest syntax failure, got %+v", failures)
+	}
+}
+
+func TestServer_AdminHandler_ServesSessions(t *testing.T) {
+	server := &Server{
+		config:   &config.Config{},
+		sessions: make(map[uint32]*Session),
+	}
+	session := NewSession(NewMockConn(), &config.Config{}, nil)
+	session.connID = server.nextConnID.Add(1)
+	session.user = "app_user"
+	server.sessions[session.connID] = session
+
+	ts := httptest.NewServer(server.AdminHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/sessions")
+	if err != nil {
+		t.Fatalf("GET /sessions failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var infos []SessionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		t.Fatalf("failed to decode sessions: %v", err)
+	}
+	if len(infos) != 1 || infos[0].User != "app_user" {
+		t.Errorf("unexpected sessions: %+v", infos)
+	}
+}
+
+func TestServer_AdminHandler_ServesStats(t *testing.T) {
+	metrics.RecordRewriteFailure("orders", "parse", "fail_closed")
+
+	server := &Server{
+		config:    &config.Config{},
+		router:    &replicaRouter{},
+		sessions:  make(map[uint32]*Session),
+		startedAt: time.Now().Add(-time.Minute),
+	}
+	for _, user := range []string{"app_user", "app_user", "report_user"} {
+		session := NewSession(NewMockConn(), &config.Config{}, nil)
+		session.connID = server.nextConnID.Add(1)
+		session.user = user
+		if user == "report_user" {
+			session.role = "analytics"
+		}
+		server.sessions[session.connID] = session
+	}
+
+	ts := httptest.NewServer(server.AdminHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/stats")
+	if err != nil {
+		t.Fatalf("GET /stats failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var stats Stats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	if stats.UptimeSeconds < 60 {
+		t.Errorf("expected the uptime to be at least a minute, got %v", stats.UptimeSeconds)
+	}
+	if stats.Sessions.Active != 3 || stats.Sessions.ByUser["app_user"] != 2 ||
+		stats.Sessions.ByRole["primary"] != 2 || stats.Sessions.ByRole["analytics"] != 1 {
+		t.Errorf("unexpected session counts: %+v", stats.Sessions)
+	}
+	if stats.Conversion["rewrite_failures"] < 1 {
+		t.Errorf("expected the rewrite failure to be counted, got %v", stats.Conversion)
+	}
+	if stats.SchemaCache != nil || stats.Probe.Status != ProbeReady {
+		t.Errorf("expected no schema cache and a ready proxy, got %+v %+v", stats.SchemaCache, stats.Probe)
+	}
+}
+
+func TestServer_AdminHandler_ServesReadiness(t *testing.T) {
+	server := &Server{config: &config.Config{}}
+	ts := httptest.NewServer(server.AdminHandler())
+	defer ts.Close()
+
+	readyz := func() (int, ProbeStatus) {
+		resp, err := http.Get(ts.URL + "/readyz")
+		if err != nil {
+			t.Fatalf("GET /readyz failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var status ProbeStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatalf("failed to decode readiness: %v", err)
+		}
+		return resp.StatusCode, status
+	}
+
+	if code, status := readyz(); code != http.StatusOK || status.Status != ProbeReady {
+		t.Errorf("expected ready without a probe, got %d %+v", code, status)
+	}
+
+	server.prober = newBackendProber(config.ProbeConfig{Interval: time.Second}, nil)
+	server.prober.status = ProbeStatus{Status: ProbeDegraded, LatencyMs: 900}
+	if code, status := readyz(); code != http.StatusOK || status.Status != ProbeDegraded {
+		t.Errorf("expected a slow backend to be served as degraded, got %d %+v", code, status)
+	}
+
+	server.prober.status = ProbeStatus{Status: ProbeUnavailable, Error: "timeout"}
+	if code, status := readyz(); code != http.StatusServiceUnavailable || status.Error != "timeout" {
+		t.Errorf("expected a failed probe to take the proxy out of rotation, got %d %+v", code, status)
+	}
+}
+
+func TestServer_AdminHandler_ServesExplain(t *testing.T) {
+	server := &Server{
+		config: &config.Config{
+			Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, RoundingStrategy: "BANKERS_ROUND"},
+			Tables: config.TablesConfig{
+				"orders": {
+					Enabled: false,
+					Columns: map[string]config.ColumnConfig{
+						"total_amount": {SourceColumn: "total_amount", TargetColumn: "total_amount_idn", Precision: 4},
+					},
+				},
+			},
+		},
+		toggles: newTableToggles(),
+	}
+	// Enabled at runtime, which the explanation must reflect
+	server.toggles.set("orders", true)
+	ts := httptest.NewServer(server.AdminHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/explain?query=" + url.QueryEscape("INSERT INTO orders (total_amount) VALUES (5000)"))
+	if err != nil {
+		t.Fatalf("GET /explain failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var explanation dualwrite.Explanation
+	if err := json.NewDecoder(resp.Body).Decode(&explanation); err != nil {
+		t.Fatalf("failed to decode explanation: %v", err)
+	}
+	if explanation.Outcome != dualwrite.OutcomeRewritten || !strings.Contains(explanation.Rewritten, "total_amount_idn") {
+		t.Errorf("expected the statement to be rewritten, got %+v", explanation)
+	}
+
+	resp, err = http.Get(ts.URL + "/explain")
+	if err != nil {
+		t.Fatalf("GET /explain failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without a query, got %d", resp.StatusCode)
+	}
+}
@@ -3,11 +3,15 @@ package proxy
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kafitramarna/TransisiDB/internal/config"
 	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
 )
 
 // BackendConn wraps a backend MySQL connection with metadata
@@ -17,8 +21,12 @@ type BackendConn struct {
 	createdAt     time.Time
 	lastUsedAt    time.Time
 	inTransaction bool
-	database      string
-	mu            sync.Mutex
+	// autocommitOff is set while the session disabled autocommit
+	autocommitOff bool
+	// inXA is set while an XA transaction branch is attached
+	inXA     bool
+	database string
+	mu       sync.Mutex
 }
 
 // NewBackendConn creates a new backend connection wrapper
@@ -56,6 +64,34 @@ func (bc *BackendConn) SetInTransaction(inTx bool) {
 	bc.inTransaction = inTx
 }
 
+// Autocommit returns whether autocommit is enabled on the connection
+func (bc *BackendConn) Autocommit() bool {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return !bc.autocommitOff
+}
+
+// SetAutocommit records the connection's autocommit setting
+func (bc *BackendConn) SetAutocommit(enabled bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.autocommitOff = !enabled
+}
+
+// IsInXA returns whether an XA transaction branch is attached to the connection
+func (bc *BackendConn) IsInXA() bool {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.inXA
+}
+
+// SetInXA sets the XA transaction state
+func (bc *BackendConn) SetInXA(inXA bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.inXA = inXA
+}
+
 // GetDatabase returns the current database context
 func (bc *BackendConn) GetDatabase() string {
 	bc.mu.Lock()
@@ -89,6 +125,29 @@ func (bc *BackendConn) IdleTime() time.Duration {
 	return time.Since(bc.lastUsedAt)
 }
 
+// Ping sends COM_PING and waits up to timeout for the OK packet. The
+// connection must be idle, i.e. not in the middle of a command.
+func (bc *BackendConn) Ping(timeout time.Duration) error {
+	if bc.conn == nil {
+		return fmt.Errorf("connection is closed")
+	}
+
+	bc.conn.SetDeadline(time.Now().Add(timeout))
+	defer bc.conn.SetDeadline(time.Time{})
+
+	if err := protocol.WritePacket(bc.conn, 0, []byte{protocol.COM_PING}); err != nil {
+		return fmt.Errorf("failed to send ping: %w", err)
+	}
+	resp, err := protocol.ReadPacket(bc.conn)
+	if err != nil {
+		return fmt.Errorf("failed to read ping response: %w", err)
+	}
+	if len(resp.Payload) == 0 || resp.Payload[0] != 0x00 {
+		return fmt.Errorf("unexpected ping response")
+	}
+	return nil
+}
+
 // IsHealthy performs a basic health check
 func (bc *BackendConn) IsHealthy() bool {
 	// Check if connection is still alive
@@ -136,33 +195,88 @@ func (bc *BackendConn) Reset() error {
 type BackendPool struct {
 	config         *config.Config
 	connections    chan *BackendConn
-	connCounter    uint32
+	backend        string // backend address, labels the pool's metrics
+	healthCheck    config.PoolHealthCheckConfig
+	connCounter    atomic.Uint32
 	mu             sync.Mutex
 	closed         bool
+	done           chan struct{}
 	wg             sync.WaitGroup
 	circuitBreaker *CircuitBreaker
 
-	// Metrics
-	totalCreated  uint64
-	totalAcquired uint64
-	totalReleased uint64
-	totalEvicted  uint64
-	currentActive int32
-	currentIdle   int32
+	// Lifetimes of idle connections, guarded by mu; lifetimesChanged wakes
+	// the cleanup worker after SetLifetimes
+	lifetimes        poolLifetimes
+	lifetimesChanged chan struct{}
+
+	// Metrics; updated without holding mu
+	totalCreated  atomic.Uint64
+	totalAcquired atomic.Uint64
+	totalReleased atomic.Uint64
+	totalEvicted  atomic.Uint64
+	currentActive atomic.Int32
+}
+
+// poolLifetimes bounds how long pooled connections are kept
+type poolLifetimes struct {
+	maxIdleTime     time.Duration
+	maxLifetime     time.Duration
+	cleanupInterval time.Duration
+}
+
+// lifetimesFor returns the configured lifetimes with defaults applied
+func lifetimesFor(p config.ProxyConfig) poolLifetimes {
+	l := poolLifetimes{
+		maxIdleTime:     p.MaxConnIdleTime,
+		maxLifetime:     p.MaxConnLifetime,
+		cleanupInterval: p.CleanupInterval,
+	}
+	if l.maxIdleTime <= 0 {
+		l.maxIdleTime = config.DefaultMaxConnIdleTime
+	}
+	if l.maxLifetime <= 0 {
+		l.maxLifetime = config.DefaultMaxConnLifetime
+	}
+	if l.cleanupInterval <= 0 {
+		l.cleanupInterval = config.DefaultCleanupInterval
+	}
+	return l
+}
+
+// expired reports whether a pooled connection outlived the limits
+func (l poolLifetimes) expired(conn *BackendConn) bool {
+	return conn.IdleTime() > l.maxIdleTime || conn.Age() > l.maxLifetime
 }
 
 // NewBackendPool creates a new backend connection pool
 func NewBackendPool(cfg *config.Config, poolSize int) (*BackendPool, error) {
 	pool := &BackendPool{
-		config:         cfg,
-		connections:    make(chan *BackendConn, poolSize),
-		circuitBreaker: NewCircuitBreaker(DefaultCircuitBreakerConfig()),
+		config:           cfg,
+		connections:      make(chan *BackendConn, poolSize),
+		backend:          backendLabel(&cfg.Database),
+		healthCheck:      cfg.Proxy.PoolHealthCheck,
+		circuitBreaker:   NewCircuitBreaker(DefaultCircuitBreakerConfig()),
+		lifetimes:        lifetimesFor(cfg.Proxy),
+		lifetimesChanged: make(chan struct{}, 1),
+		done:             make(chan struct{}),
+	}
+	pool.circuitBreaker.exportState(pool.backend)
+	if pool.healthCheck.Mode == "" {
+		pool.healthCheck.Mode = config.HealthCheckPing
+	}
+	if pool.healthCheck.IdleThreshold == 0 {
+		pool.healthCheck.IdleThreshold = config.DefaultPoolHealthCheckIdleThreshold
+	}
+	if pool.healthCheck.Timeout == 0 {
+		pool.healthCheck.Timeout = config.DefaultPoolHealthCheckTimeout
 	}
 
 	logger.Info("Backend connection pool created",
 		"pool_size", poolSize,
 		"circuit_breaker_max_failures", pool.circuitBreaker.config.MaxFailures,
-		"circuit_breaker_timeout", pool.circuitBreaker.config.Timeout)
+		"circuit_breaker_timeout", pool.circuitBreaker.config.Timeout,
+		"max_conn_idle_time", pool.lifetimes.maxIdleTime,
+		"max_conn_lifetime", pool.lifetimes.maxLifetime)
 
 	// Start background worker to clean up idle connections
 	pool.wg.Add(1)
@@ -171,8 +285,35 @@ func NewBackendPool(cfg *config.Config, poolSize int) (*BackendPool, error) {
 	return pool, nil
 }
 
-// Acquire gets a connection from the pool or creates a new one
+// backendLabel returns the address a pool connects to
+func backendLabel(db *config.DatabaseConfig) string {
+	if db.Socket != "" {
+		return db.Socket
+	}
+	return net.JoinHostPort(db.Host, strconv.Itoa(db.Port))
+}
+
+// Acquire gets a connection from the pool or creates a new one. The caller
+// hands it back with Release or Discard.
 func (bp *BackendPool) Acquire() (*BackendConn, error) {
+	start := time.Now()
+	metrics.AddPoolWaiting(bp.backend, 1)
+	conn, err := bp.acquire()
+	metrics.AddPoolWaiting(bp.backend, -1)
+	metrics.RecordPoolAcquire(bp.backend, time.Since(start))
+
+	if err != nil {
+		return nil, err
+	}
+	bp.totalAcquired.Add(1)
+	bp.currentActive.Add(1)
+	metrics.AddPoolConnections(bp.backend, "active", 1)
+	return conn, nil
+}
+
+// acquire checks out an idle connection that passes the health check or
+// creates a new one
+func (bp *BackendPool) acquire() (*BackendConn, error) {
 	bp.mu.Lock()
 	if bp.closed {
 		bp.mu.Unlock()
@@ -183,10 +324,11 @@ func (bp *BackendPool) Acquire() (*BackendConn, error) {
 	// Try to get an existing connection from the pool
 	select {
 	case conn := <-bp.connections:
-		// Check if connection is still healthy
-		if conn.IsHealthy() {
+		metrics.AddPoolConnections(bp.backend, "idle", -1)
+		// Check if connection is still healthy; connections past their
+		// lifetime may already have been closed by the server
+		if !bp.getLifetimes().expired(conn) && bp.isAlive(conn, bp.healthCheck.IdleThreshold) {
 			conn.UpdateLastUsed()
-			bp.totalAcquired++
 			logger.Debug("Reused backend connection from pool", "conn_id", conn.connectionID)
 			return conn, nil
 		}
@@ -194,7 +336,7 @@ func (bp *BackendPool) Acquire() (*BackendConn, error) {
 		// Connection is not healthy, close it and create a new one
 		logger.Warn("Evicting unhealthy connection from pool", "conn_id", conn.connectionID)
 		conn.Close()
-		bp.totalEvicted++
+		bp.totalEvicted.Add(1)
 		// Fall through to create new connection
 	default:
 		// No idle connections available, create new one
@@ -204,11 +346,48 @@ func (bp *BackendPool) Acquire() (*BackendConn, error) {
 	return bp.createConnection()
 }
 
+// isAlive runs the configured health check on a pooled connection that has
+// been idle for at least idleThreshold
+func (bp *BackendPool) isAlive(conn *BackendConn, idleThreshold time.Duration) bool {
+	if conn.IdleTime() < idleThreshold {
+		return true
+	}
+	switch bp.healthCheck.Mode {
+	case config.HealthCheckNone:
+		return true
+	case config.HealthCheckRead:
+		return conn.IsHealthy()
+	default:
+		if err := conn.Ping(bp.healthCheck.Timeout); err != nil {
+			logger.Debug("Backend connection failed ping", "conn_id", conn.connectionID, "error", err)
+			return false
+		}
+		return true
+	}
+}
+
+// Discard closes a connection checked out with Acquire instead of returning
+// it to the pool
+func (bp *BackendPool) Discard(conn *BackendConn) {
+	if conn == nil {
+		return
+	}
+	bp.checkIn()
+	conn.Close()
+}
+
+// checkIn records that a checked out connection was handed back
+func (bp *BackendPool) checkIn() {
+	bp.currentActive.Add(-1)
+	metrics.AddPoolConnections(bp.backend, "active", -1)
+}
+
 // Release returns a connection to the pool
 func (bp *BackendPool) Release(conn *BackendConn) {
 	if conn == nil {
 		return
 	}
+	bp.checkIn()
 
 	bp.mu.Lock()
 	if bp.closed {
@@ -219,12 +398,19 @@ func (bp *BackendPool) Release(conn *BackendConn) {
 	bp.mu.Unlock()
 
 	// Don't reuse connections that are in a transaction
-	if conn.IsInTransaction() {
+	if conn.IsInTransaction() || conn.IsInXA() {
 		logger.Warn("Not returning connection to pool (in transaction)", "conn_id", conn.connectionID)
 		conn.Close()
 		return
 	}
 
+	// Nor connections whose next user would silently run in a transaction
+	if !conn.Autocommit() {
+		logger.Warn("Not returning connection to pool (autocommit disabled)", "conn_id", conn.connectionID)
+		conn.Close()
+		return
+	}
+
 	// Reset connection state
 	if err := conn.Reset(); err != nil {
 		logger.Error("Failed to reset connection", "conn_id", conn.connectionID, "error", err)
@@ -235,7 +421,8 @@ func (bp *BackendPool) Release(conn *BackendConn) {
 	// Try to return to pool (non-blocking)
 	select {
 	case bp.connections <- conn:
-		bp.totalReleased++
+		bp.totalReleased.Add(1)
+		metrics.AddPoolConnections(bp.backend, "idle", 1)
 		logger.Debug("Returned backend connection to pool", "conn_id", conn.connectionID)
 	default:
 		// Pool is full, close the connection
@@ -251,39 +438,26 @@ func (bp *BackendPool) createConnection() (*BackendConn, error) {
 
 	// Use circuit breaker to protect against cascading failures
 	err := bp.circuitBreaker.Call(func() error {
-		addr := net.JoinHostPort(bp.config.Database.Host, fmt.Sprintf("%d", bp.config.Database.Port))
-
-		// Dial backend with timeout
-		dialer := &net.Dialer{
-			Timeout: bp.config.Database.ConnectionTimeout,
-		}
-
-		var dialErr error
-		conn, dialErr = dialer.Dial("tcp", addr)
-		if dialErr != nil {
-			connErr = fmt.Errorf("failed to connect to backend %s: %w", addr, dialErr)
-			return dialErr
-		}
-
-		return nil
+		// Dial backend with timeout and TCP tuning
+		conn, connErr = dialBackend(&bp.config.Database)
+		return connErr
 	})
 
 	// Check circuit breaker result
 	if err != nil {
 		if err == ErrCircuitBreakerOpen {
+			metrics.RecordPoolCreateFailure(bp.backend, "circuit_breaker")
 			logger.Warn("Circuit breaker is OPEN, rejecting connection attempt")
 			return nil, fmt.Errorf("backend unavailable (circuit breaker open): %w", err)
 		}
 		// Return the actual connection error
+		metrics.RecordPoolCreateFailure(bp.backend, "dial")
 		return nil, connErr
 	}
 
 	// Generate connection ID
-	bp.mu.Lock()
-	bp.connCounter++
-	connID := bp.connCounter
-	bp.totalCreated++
-	bp.mu.Unlock()
+	connID := bp.connCounter.Add(1)
+	bp.totalCreated.Add(1)
 
 	backendConn := NewBackendConn(conn, connID)
 
@@ -302,46 +476,65 @@ func (bp *BackendPool) Close() error {
 	bp.closed = true
 	bp.mu.Unlock()
 
+	// Stop the cleanup worker before draining, it puts connections back
+	if bp.done != nil {
+		close(bp.done)
+	}
+	bp.wg.Wait()
+
 	// Close all idle connections
 	close(bp.connections)
 	for conn := range bp.connections {
+		metrics.AddPoolConnections(bp.backend, "idle", -1)
 		conn.Close()
 	}
 
-	// Wait for cleanup worker to finish
-	bp.wg.Wait()
-
 	logger.Info("Backend connection pool closed")
 	return nil
 }
 
-// cleanupWorker periodically cleans up stale idle connections
+// SetLifetimes changes the idle time, lifetime and cleanup interval of a
+// running pool
+func (bp *BackendPool) SetLifetimes(l poolLifetimes) {
+	bp.mu.Lock()
+	bp.lifetimes = l
+	bp.mu.Unlock()
+
+	select {
+	case bp.lifetimesChanged <- struct{}{}:
+	default:
+	}
+}
+
+// getLifetimes returns the current lifetimes
+func (bp *BackendPool) getLifetimes() poolLifetimes {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.lifetimes
+}
+
+// cleanupWorker periodically cleans up stale idle connections until the
+// pool is closed
 func (bp *BackendPool) cleanupWorker() {
 	defer bp.wg.Done()
 
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(bp.getLifetimes().cleanupInterval)
 	defer ticker.Stop()
 
-	maxIdleTime := 5 * time.Minute
-	maxAge := 30 * time.Minute
-
 	for {
 		select {
+		case <-bp.done:
+			return
+		case <-bp.lifetimesChanged:
+			ticker.Reset(bp.getLifetimes().cleanupInterval)
 		case <-ticker.C:
-			bp.cleanupStaleConnections(maxIdleTime, maxAge)
-		case <-time.After(1 * time.Minute):
-			bp.mu.Lock()
-			if bp.closed {
-				bp.mu.Unlock()
-				return
-			}
-			bp.mu.Unlock()
+			bp.cleanupStaleConnections(bp.getLifetimes())
 		}
 	}
 }
 
 // cleanupStaleConnections removes connections that are too old or idle too long
-func (bp *BackendPool) cleanupStaleConnections(maxIdleTime, maxAge time.Duration) {
+func (bp *BackendPool) cleanupStaleConnections(lifetimes poolLifetimes) {
 	var healthyConns []*BackendConn
 
 	// Drain all connections from pool
@@ -354,13 +547,14 @@ func (bp *BackendPool) cleanupStaleConnections(maxIdleTime, maxAge time.Duration
 			}
 
 			// Check if connection should be evicted
-			if conn.IdleTime() > maxIdleTime || conn.Age() > maxAge || !conn.IsHealthy() {
+			if lifetimes.expired(conn) || !bp.isAlive(conn, 0) {
 				logger.Debug("Evicting stale connection",
 					"conn_id", conn.connectionID,
 					"idle_time", conn.IdleTime(),
 					"age", conn.Age())
 				conn.Close()
-				bp.totalEvicted++
+				bp.totalEvicted.Add(1)
+				metrics.AddPoolConnections(bp.backend, "idle", -1)
 			} else {
 				healthyConns = append(healthyConns, conn)
 			}
@@ -377,26 +571,25 @@ done:
 		case bp.connections <- conn:
 		default:
 			// Pool is somehow full, close excess connections
+			metrics.AddPoolConnections(bp.backend, "idle", -1)
 			conn.Close()
 		}
 	}
 
 	if len(healthyConns) > 0 {
-		logger.Debug("Cleanup completed", "healthy_conns", len(healthyConns), "evicted", bp.totalEvicted)
+		logger.Debug("Cleanup completed", "healthy_conns", len(healthyConns), "evicted", bp.totalEvicted.Load())
 	}
 }
 
 // Stats returns pool statistics
 func (bp *BackendPool) Stats() map[string]interface{} {
-	bp.mu.Lock()
-	defer bp.mu.Unlock()
-
 	stats := map[string]interface{}{
-		"total_created":   bp.totalCreated,
-		"total_acquired":  bp.totalAcquired,
-		"total_released":  bp.totalReleased,
-		"total_evicted":   bp.totalEvicted,
+		"total_created":   bp.totalCreated.Load(),
+		"total_acquired":  bp.totalAcquired.Load(),
+		"total_released":  bp.totalReleased.Load(),
+		"total_evicted":   bp.totalEvicted.Load(),
 		"current_idle":    len(bp.connections),
+		"current_active":  bp.currentActive.Load(),
 		"pool_capacity":   cap(bp.connections),
 		"circuit_breaker": bp.circuitBreaker.GetStats(),
 	}
@@ -1,10 +1,16 @@
 package proxy
 
 import (
+	"net"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
 	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
 )
 
 func TestBackendPool_CreateAndAcquire(t *testing.T) {
@@ -125,3 +131,212 @@ func TestBackendConn_Reset(t *testing.T) {
 		t.Errorf("Expected database to be reset, got %s", db)
 	}
 }
+
+func TestBackendConn_Ping(t *testing.T) {
+	tests := []struct {
+		name     string
+		response []byte
+		wantErr  bool
+	}{
+		{"ok packet", []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}, false},
+		{"err packet", protocol.EncodeERRPacket(1053, "08S01", "Server shutdown in progress"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			go func() {
+				pkt, err := protocol.ReadPacket(server)
+				if err != nil || len(pkt.Payload) != 1 || pkt.Payload[0] != protocol.COM_PING {
+					server.Close()
+					return
+				}
+				protocol.WritePacket(server, 1, tt.response)
+			}()
+
+			err := NewBackendConn(client, 1).Ping(time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackendPool_IsAlive(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	server.Close()
+
+	conn := NewBackendConn(client, 1)
+	pool := &BackendPool{healthCheck: config.PoolHealthCheckConfig{
+		Mode:          config.HealthCheckPing,
+		IdleThreshold: time.Minute,
+		Timeout:       100 * time.Millisecond,
+	}}
+
+	// Recently used connections are handed out without a round trip
+	if !pool.isAlive(conn, pool.healthCheck.IdleThreshold) {
+		t.Error("expected a recently used connection to be handed out unchecked")
+	}
+	if pool.isAlive(conn, 0) {
+		t.Error("expected a connection whose backend went away to fail the ping")
+	}
+
+	pool.healthCheck.Mode = config.HealthCheckNone
+	if !pool.isAlive(conn, 0) {
+		t.Error("expected no check with mode none")
+	}
+}
+
+func TestBackendPool_ConnectionGauges(t *testing.T) {
+	const backend = "gauge-test:3306"
+	client, server := net.Pipe()
+	defer server.Close()
+
+	pool := &BackendPool{
+		backend:        backend,
+		connections:    make(chan *BackendConn, 2),
+		healthCheck:    config.PoolHealthCheckConfig{Mode: config.HealthCheckNone},
+		circuitBreaker: NewCircuitBreaker(DefaultCircuitBreakerConfig()),
+		lifetimes:      lifetimesFor(config.ProxyConfig{}),
+	}
+	pool.connections <- NewBackendConn(client, 1)
+	metrics.AddPoolConnections(backend, "idle", 1)
+
+	gauge := func(state string) float64 {
+		return testutil.ToFloat64(metrics.PoolConnections.WithLabelValues(backend, state))
+	}
+
+	conn, err := pool.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if gauge("idle") != 0 || gauge("active") != 1 {
+		t.Errorf("after Acquire: idle=%v active=%v, want 0 and 1", gauge("idle"), gauge("active"))
+	}
+
+	pool.Release(conn)
+	if gauge("idle") != 1 || gauge("active") != 0 {
+		t.Errorf("after Release: idle=%v active=%v, want 1 and 0", gauge("idle"), gauge("active"))
+	}
+
+	conn, err = pool.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	pool.Discard(conn)
+	if gauge("idle") != 0 || gauge("active") != 0 {
+		t.Errorf("after Discard: idle=%v active=%v, want 0 and 0", gauge("idle"), gauge("active"))
+	}
+	if got := testutil.ToFloat64(metrics.PoolWaiting.WithLabelValues(backend)); got != 0 {
+		t.Errorf("waiting = %v, want 0", got)
+	}
+	if got := testutil.CollectAndCount(metrics.PoolAcquireDuration); got == 0 {
+		t.Error("expected acquire latency to be recorded")
+	}
+}
+
+// Run with -race: Acquire, Release and Stats are called from many sessions
+func TestPoolStats_ConcurrentAcquireRelease(t *testing.T) {
+	const (
+		workers    = 8
+		iterations = 200
+	)
+
+	pool := &BackendPool{
+		backend:        "race-test:3306",
+		connections:    make(chan *BackendConn, workers),
+		healthCheck:    config.PoolHealthCheckConfig{Mode: config.HealthCheckNone},
+		circuitBreaker: NewCircuitBreaker(DefaultCircuitBreakerConfig()),
+		lifetimes:      lifetimesFor(config.ProxyConfig{}),
+	}
+	// One idle connection per worker, so Acquire never has to dial
+	for i := 0; i < workers; i++ {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+		pool.connections <- NewBackendConn(client, uint32(i+1))
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				conn, err := pool.Acquire()
+				if err != nil {
+					t.Errorf("Acquire failed: %v", err)
+					return
+				}
+				pool.Stats()
+				pool.Release(conn)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := pool.Stats()
+	if got := stats["total_acquired"].(uint64); got != workers*iterations {
+		t.Errorf("total_acquired = %d, want %d", got, workers*iterations)
+	}
+	if got := stats["total_released"].(uint64); got != workers*iterations {
+		t.Errorf("total_released = %d, want %d", got, workers*iterations)
+	}
+	if got := stats["current_active"].(int32); got != 0 {
+		t.Errorf("current_active = %d, want 0", got)
+	}
+	if got := stats["current_idle"].(int); got != workers {
+		t.Errorf("current_idle = %d, want %d", got, workers)
+	}
+}
+
+func TestBackendPool_CleanupLifetimes(t *testing.T) {
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Host: "localhost", Port: 3307},
+		Proxy: config.ProxyConfig{
+			PoolHealthCheck: config.PoolHealthCheckConfig{Mode: config.HealthCheckNone},
+			MaxConnIdleTime: time.Hour,
+			CleanupInterval: 10 * time.Millisecond,
+		},
+	}
+	pool, err := NewBackendPool(cfg, 2)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := NewBackendConn(client, 1)
+	pool.Release(conn)
+
+	// Within the idle time the connection stays pooled
+	time.Sleep(50 * time.Millisecond)
+	if got := pool.Stats()["current_idle"].(int); got != 1 {
+		t.Fatalf("current_idle = %d, want 1", got)
+	}
+
+	// A reloaded idle time shorter than the connection's idle time evicts it
+	pool.SetLifetimes(lifetimesFor(config.ProxyConfig{MaxConnIdleTime: time.Millisecond, CleanupInterval: 10 * time.Millisecond}))
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Stats()["total_evicted"].(uint64) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the idle connection to be evicted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not stop the cleanup worker")
+	}
+}
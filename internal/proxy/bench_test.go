@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// Benchmarks for the per-query path. Compare them across releases with
+// make bench-compare before adding work to handleQuery or the relay.
+
+func benchConfig() *config.Config {
+	return &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, RoundingStrategy: "BANKERS_ROUND", FailurePolicy: config.FailClosed},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount":    {SourceColumn: "total_amount", TargetColumn: "total_amount_idn", TargetType: "DECIMAL(19,4)", Precision: 4},
+					"shipping_amount": {SourceColumn: "shipping_amount", TargetColumn: "shipping_amount_idn", TargetType: "DECIMAL(19,4)", Precision: 4},
+				},
+			},
+		},
+	}
+}
+
+// benchResultset encodes a text resultset with the given number of rows
+func benchResultset(rows int) [][]byte {
+	columns := []*protocol.ColumnDefinition41{
+		{Catalog: "def", Schema: "shop", Table: "orders", OrgTable: "orders", Name: "id", OrgName: "id",
+			CharacterSet: 63, ColumnLength: 20, Type: protocol.MYSQL_TYPE_LONGLONG, Flags: protocol.NOT_NULL_FLAG | protocol.PRI_KEY_FLAG},
+		{Catalog: "def", Schema: "shop", Table: "orders", OrgTable: "orders", Name: "total_amount", OrgName: "total_amount",
+			CharacterSet: 63, ColumnLength: 17, Type: protocol.MYSQL_TYPE_NEWDECIMAL, Decimals: 2},
+		{Catalog: "def", Schema: "shop", Table: "orders", OrgTable: "orders", Name: "customer_note", OrgName: "customer_note",
+			CharacterSet: 255, ColumnLength: 1020, Type: protocol.MYSQL_TYPE_VAR_STRING},
+	}
+
+	packets := [][]byte{protocol.EncodeColumnCount(len(columns))}
+	for _, col := range columns {
+		packets = append(packets, col.Encode())
+	}
+	packets = append(packets, protocol.EncodeEOFPacket(0, protocol.SERVER_STATUS_AUTOCOMMIT))
+	for i := 0; i < rows; i++ {
+		row := protocol.TextRow{[]byte(fmt.Sprint(i + 1)), []byte("1500000.00"), []byte("deliver before noon")}
+		packets = append(packets, row.Encode())
+	}
+	return append(packets, protocol.EncodeEOFPacket(0, protocol.SERVER_STATUS_AUTOCOMMIT))
+}
+
+// benchSession returns a session whose backend has the response queued once
+// per benchmark iteration
+func benchSession(b *testing.B, cfg *config.Config, response [][]byte) (*Session, *MockConn, *MockConn) {
+	b.Helper()
+	// Query logging is not part of what is measured
+	logger.Init("ERROR")
+
+	backend := NewMockConn()
+	for i := 0; i < b.N; i++ {
+		for seq, payload := range response {
+			if err := protocol.WritePacket(backend.ReadBuf, uint8(seq+1), payload); err != nil {
+				b.Fatalf("failed to prepare backend response: %v", err)
+			}
+		}
+	}
+
+	client := NewMockConn()
+	session := NewSession(client, cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+	session.backendConn = NewBackendConn(backend, 1)
+	return session, client, backend
+}
+
+func BenchmarkHandleQuery(b *testing.B) {
+	ok := [][]byte{protocol.EncodeOKPacket(1, 0, protocol.SERVER_STATUS_AUTOCOMMIT, 0)}
+
+	benchmarks := []struct {
+		name     string
+		query    string
+		response [][]byte
+	}{
+		{"select", "SELECT id, total_amount, customer_note FROM orders WHERE id = 42", benchResultset(1)},
+		{"insert unconfigured table", "INSERT INTO audit_log (action, actor) VALUES ('login', 'admin')", ok},
+		{"insert rewrite", "INSERT INTO orders (customer_id, total_amount, shipping_amount) VALUES (7, 1500000, 25000)", ok},
+		{"update rewrite", "UPDATE orders SET total_amount = 250000 WHERE id = 42", ok},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			session, client, backend := benchSession(b, benchConfig(), bm.response)
+			cmdPkt := newQueryPacket(0, bm.query)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := session.handleQuery(cmdPkt); err != nil {
+					b.Fatalf("handleQuery returned error: %v", err)
+				}
+				client.WriteBuf.Reset()
+				backend.WriteBuf.Reset()
+			}
+		})
+	}
+}
+
+func BenchmarkRelayResultset(b *testing.B) {
+	for _, rows := range []int{1, 100, 1000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			response := benchResultset(rows)
+			session, client, backend := benchSession(b, benchConfig(), response)
+			cmdPkt := newQueryPacket(0, "SELECT id, total_amount, customer_note FROM orders")
+
+			var size int64
+			for _, payload := range response {
+				size += int64(4 + len(payload))
+			}
+			b.SetBytes(size)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := session.relayCommand(cmdPkt, &queryTiming{}); err != nil {
+					b.Fatalf("relayCommand returned error: %v", err)
+				}
+				client.WriteBuf.Reset()
+				backend.WriteBuf.Reset()
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+)
+
+// breakGlassLogInterval is how often an active break glass is logged again
+const breakGlassLogInterval = time.Minute
+
+// breakGlass is the emergency switch that forwards every statement without
+// parsing or rewriting. It is shared by all sessions.
+type breakGlass struct {
+	mu      sync.Mutex // serializes set
+	current atomic.Pointer[config.BreakGlass]
+	// stop ends the watch of the current switch
+	stop chan struct{}
+}
+
+// newBreakGlass returns a switch that is off
+func newBreakGlass() *breakGlass {
+	return &breakGlass{}
+}
+
+// active reports whether statements are forwarded untouched. It is safe for
+// concurrent use and false on a nil switch.
+func (b *breakGlass) active() bool {
+	if b == nil {
+		return false
+	}
+	return b.current.Load().Active(time.Now())
+}
+
+// state returns the switch while it is on, nil otherwise
+func (b *breakGlass) state() *config.BreakGlass {
+	if !b.active() {
+		return nil
+	}
+	return b.current.Load()
+}
+
+// set turns the switch on with state, or off with nil, and reports whether it
+// changed. Setting the switch that is already on changes nothing, so the
+// switch can be reloaded from Redis on every poll.
+func (b *breakGlass) set(state *config.BreakGlass) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !state.Active(time.Now()) {
+		state = nil
+	}
+	current := b.current.Load()
+	if sameBreakGlass(current, state) {
+		return false
+	}
+
+	if b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+	b.current.Store(state)
+	if state == nil {
+		metrics.SetBreakGlassActive(false)
+		if current.Active(time.Now()) {
+			logger.Warn("BREAK GLASS turned off, statements are parsed and rewritten again", "actor", current.Actor)
+		} else {
+			logger.Warn("BREAK GLASS expired, statements are parsed and rewritten again", "actor", current.Actor)
+		}
+		return true
+	}
+
+	metrics.SetBreakGlassActive(true)
+	logger.Error("BREAK GLASS: forwarding every statement without parsing or rewriting",
+		"actor", state.Actor, "reason", state.Reason, "expires", state.Expires.Format(time.RFC3339))
+	b.stop = make(chan struct{})
+	go b.watch(state, b.stop)
+	return true
+}
+
+// watch logs the switch every breakGlassLogInterval and turns it off when it
+// expires, until stop is closed
+func (b *breakGlass) watch(state *config.BreakGlass, stop chan struct{}) {
+	expiry := time.NewTimer(time.Until(state.Expires))
+	defer expiry.Stop()
+	ticker := time.NewTicker(breakGlassLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			logger.Error("BREAK GLASS still active: statements are not parsed or rewritten",
+				"actor", state.Actor, "reason", state.Reason, "remaining", time.Until(state.Expires).Round(time.Second))
+		case <-expiry.C:
+			b.mu.Lock()
+			if b.current.Load() == state {
+				b.current.Store(nil)
+				b.stop = nil
+				metrics.SetBreakGlassActive(false)
+				logger.Warn("BREAK GLASS expired, statements are parsed and rewritten again", "actor", state.Actor)
+			}
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+// sameBreakGlass reports whether a and b are the same switch
+func sameBreakGlass(a, b *config.BreakGlass) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Actor == b.Actor && a.Started.Equal(b.Started) && a.Expires.Equal(b.Expires)
+}
+
+// BreakGlass returns the break glass switch while it is on, nil otherwise
+func (s *Server) BreakGlass() *config.BreakGlass {
+	return s.breakGlass.state()
+}
+
+// SetBreakGlass turns break glass on with state, or off with nil. With a
+// Redis store the switch is saved, recorded in the audit log and published
+// to the other proxies; without one it applies to this proxy until restart.
+func (s *Server) SetBreakGlass(state *config.BreakGlass, actor string) error {
+	s.mu.Lock()
+	store := s.store
+	s.mu.Unlock()
+
+	if store != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		entry := config.AuditEntry{Time: time.Now(), Actor: actor, Action: "break_glass_off", Target: "proxy"}
+		if state != nil {
+			if err := store.SetBreakGlass(ctx, state); err != nil {
+				return err
+			}
+			entry.Action = "break_glass_on"
+			entry.Detail = state.Detail()
+		} else if _, err := store.ClearBreakGlass(ctx); err != nil {
+			return err
+		}
+		if err := store.AppendAudit(ctx, entry); err != nil {
+			logger.Warn("Failed to record audit entry", "action", entry.Action, "error", err)
+		}
+		if err := store.PublishReload(ctx); err != nil {
+			logger.Warn("Failed to publish reload, other proxies apply break glass on their next poll", "error", err)
+		}
+	}
+
+	s.breakGlass.set(state)
+	return nil
+}
+
+// reloadBreakGlass applies the break glass switch stored in Redis
+func (s *Server) reloadBreakGlass(ctx context.Context, store *config.RedisStore) error {
+	state, err := store.LoadBreakGlass(ctx)
+	if err != nil {
+		return err
+	}
+	s.breakGlass.set(state)
+	return nil
+}
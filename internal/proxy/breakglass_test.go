@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBreakGlass(t *testing.T) {
+	b := newBreakGlass()
+	if b.active() {
+		t.Fatal("expected a new switch to be off")
+	}
+
+	state, err := config.NewBreakGlass("api:10.0.0.5", "parser incident", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("NewBreakGlass returned error: %v", err)
+	}
+	if !b.set(state) || !b.active() {
+		t.Fatal("expected the switch to be on")
+	}
+	if got := testutil.ToFloat64(metrics.BreakGlassActive); got != 1 {
+		t.Errorf("transisidb_break_glass_active = %v, want 1", got)
+	}
+
+	// Reloading the same switch from Redis changes nothing
+	reloaded := *state
+	if b.set(&reloaded) {
+		t.Error("expected reloading the same switch to change nothing")
+	}
+
+	if !b.set(nil) || b.active() {
+		t.Fatal("expected the switch to be off")
+	}
+	if got := testutil.ToFloat64(metrics.BreakGlassActive); got != 0 {
+		t.Errorf("transisidb_break_glass_active = %v, want 0", got)
+	}
+
+	// An expired switch is not turned on
+	expired := &config.BreakGlass{Actor: "api", Started: time.Now().Add(-time.Hour), Expires: time.Now().Add(-time.Minute)}
+	if b.set(expired) || b.active() {
+		t.Error("expected an expired switch to stay off")
+	}
+}
+
+func TestBreakGlass_Expires(t *testing.T) {
+	b := newBreakGlass()
+	b.set(&config.BreakGlass{Actor: "api", Started: time.Now(), Expires: time.Now().Add(50 * time.Millisecond)})
+	if !b.active() {
+		t.Fatal("expected the switch to be on")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for b.current.Load() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the switch to turn itself off")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(metrics.BreakGlassActive); got != 0 {
+		t.Errorf("transisidb_break_glass_active = %v, want 0", got)
+	}
+}
+
+func TestSession_HandleQuery_BreakGlass(t *testing.T) {
+	session, conn := newVirtualSession()
+	session.user = "dba"
+	session.config.Proxy.AdminUsers = []string{"dba"}
+	session.config.Conversion.Ratio = 1000
+	session.config.Conversion.Precision = 4
+	session.parser = parser.NewParser(session.config.Tables)
+	admin := session.admin.(*fakeAdmin)
+	admin.breakGlass = newBreakGlass()
+	session.breakGlass = admin.breakGlass
+
+	backend := NewMockConn()
+	if err := protocol.WritePacket(backend.ReadBuf, 1, protocol.EncodeOKPacket(1, 0, 0x0002, 0)); err != nil {
+		t.Fatalf("failed to prepare backend response: %v", err)
+	}
+	session.backendConn = NewBackendConn(backend, 1)
+
+	// Turning break glass on
+	query := "UPDATE transisidb.break_glass SET active = 1, minutes = 30, reason = 'parser incident'"
+	if err := session.handleQuery(newQueryPacket(0, query)); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if ok, err := protocol.ParseOKPacket(pkt.Payload); err != nil || ok.AffectedRows != 1 {
+		t.Fatalf("expected OK with one affected row, got %v %v", ok, err)
+	}
+
+	if err := session.handleQuery(newQueryPacket(0, "SELECT active, minutes, reason FROM transisidb.break_glass")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	_, rows := readTextResultset(t, conn.WriteBuf)
+	if len(rows) != 1 || rows[0].String(0) != "1" || rows[0].String(1) != "30" || rows[0].String(2) != "parser incident" {
+		t.Errorf("unexpected rows: %q", rows)
+	}
+
+	// Statements on dual-written tables reach the backend untouched
+	before := testutil.ToFloat64(metrics.BreakGlassStatements)
+	insert := "INSERT INTO orders (total_amount) VALUES (15000)"
+	if err := session.handleQuery(newQueryPacket(0, insert)); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	if sent := backend.WriteBuf.String(); strings.Contains(sent, "total_amount_idn") || !strings.Contains(sent, insert) {
+		t.Errorf("%q reached the backend as %q, want it untouched", insert, sent)
+	}
+	if got := testutil.ToFloat64(metrics.BreakGlassStatements) - before; got != 1 {
+		t.Errorf("break glass statements = %v, want 1", got)
+	}
+	conn.WriteBuf.Reset()
+
+	// Turning it off, twice
+	for i, affected := range []uint64{1, 0} {
+		if err := session.handleQuery(newQueryPacket(0, "UPDATE transisidb.break_glass SET active = 0")); err != nil {
+			t.Fatalf("handleQuery returned error: %v", err)
+		}
+		pkt, _ := protocol.ReadPacket(conn.WriteBuf)
+		if ok, err := protocol.ParseOKPacket(pkt.Payload); err != nil || ok.AffectedRows != affected {
+			t.Errorf("turning off #%d: expected OK with %d affected rows, got %v %v", i+1, affected, ok, err)
+		}
+	}
+	if session.breakGlass.active() {
+		t.Error("expected break glass to be off")
+	}
+}
+
+func TestSession_HandleQuery_BreakGlassErrors(t *testing.T) {
+	tests := []struct {
+		user  string
+		query string
+		code  uint16
+	}{
+		{"app", "UPDATE transisidb.break_glass SET active = 1, reason = 'incident'", codeAccessDenied},
+		{"dba", "UPDATE transisidb.break_glass SET active = 1", codeWrongArguments},
+		{"dba", "UPDATE transisidb.break_glass SET active = 1, minutes = 600, reason = 'incident'", codeWrongArguments},
+		{"dba", "UPDATE transisidb.break_glass SET minutes = 10", codeNotSupportedYet},
+		{"dba", "UPDATE transisidb.break_glass SET active = 1, reason = 'x' WHERE active = 0", codeNotSupportedYet},
+	}
+
+	for _, tt := range tests {
+		session, conn := newVirtualSession()
+		session.user = tt.user
+		session.config.Proxy.AdminUsers = []string{"dba"}
+		if err := session.handleQuery(newQueryPacket(0, tt.query)); err != nil {
+			t.Fatalf("%s: handleQuery returned error: %v", tt.query, err)
+		}
+		pkt, err := protocol.ReadPacket(conn.WriteBuf)
+		if err != nil {
+			t.Fatalf("%s: failed to read response: %v", tt.query, err)
+		}
+		errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+		if err != nil {
+			t.Fatalf("%s: expected ERR packet: %v", tt.query, err)
+		}
+		if errPkt.ErrorCode != tt.code {
+			t.Errorf("%s: error code = %d, want %d", tt.query, errPkt.ErrorCode, tt.code)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/canary"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// canaryExecutor reports each replayed statement as affecting one row
+type canaryExecutor struct {
+	queries chan string
+}
+
+func (c *canaryExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.queries <- query
+	return canaryResult{}, nil
+}
+
+type canaryResult struct{}
+
+func (canaryResult) LastInsertId() (int64, error) { return 0, nil }
+func (canaryResult) RowsAffected() (int64, error) { return 1, nil }
+
+func TestSession_HandleQuery_CanaryReplaysRewrites(t *testing.T) {
+	backend := NewMockConn()
+	// The primary reports two affected rows for every statement
+	okPacket := protocol.EncodeOKPacket(2, 0, 0x0002, 0)
+	for i := 0; i < 5; i++ {
+		if err := protocol.WritePacket(backend.ReadBuf, 1, okPacket); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+	}
+
+	tables := config.TablesConfig{
+		"orders": {Enabled: true, Columns: map[string]config.ColumnConfig{
+			"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+		}},
+	}
+	cfg := &config.Config{Tables: tables, Conversion: config.ConversionConfig{Ratio: 1000}}
+	session := NewSession(NewMockConn(), cfg, nil)
+	session.parser = parser.NewParser(tables)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	exec := &canaryExecutor{queries: make(chan string, 5)}
+	session.canary = canary.NewReplayer(exec, config.CanaryConfig{Workers: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go session.canary.Run(ctx)
+
+	queries := []string{
+		"UPDATE orders SET total_amount = 5000 WHERE id = 1",
+		"SELECT * FROM orders",
+		"BEGIN",
+		"UPDATE orders SET total_amount = 7000 WHERE id = 2",
+		"COMMIT",
+	}
+	for _, query := range queries {
+		if err := session.handleQuery(newQueryPacket(0, query)); err != nil {
+			t.Fatalf("handleQuery(%q) returned error: %v", query, err)
+		}
+	}
+
+	// Only the autocommitted rewrite reaches the canary
+	select {
+	case query := <-exec.queries:
+		if !strings.Contains(query, "total_amount_idn") || !strings.Contains(query, "id = 1") {
+			t.Errorf("expected the rewritten autocommitted statement, got %q", query)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rewritten statement was not replayed on the canary")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(session.canary.Mismatches()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	mismatches := session.canary.Mismatches()
+	if len(mismatches) != 1 || mismatches[0].Outcome != canary.OutcomeRowsMismatch || mismatches[0].Primary != "2 rows" {
+		t.Errorf("expected one rows mismatch against 2 primary rows, got %+v", mismatches)
+	}
+	select {
+	case query := <-exec.queries:
+		t.Errorf("statement in a transaction was replayed: %q", query)
+	default:
+	}
+}
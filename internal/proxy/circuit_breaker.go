@@ -1,11 +1,12 @@
 package proxy
 
 import (
-	"errors"
 	"sync"
 	"time"
 
+	"github.com/kafitramarna/TransisiDB/internal/errs"
 	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
 )
 
 // CircuitBreakerState represents the state of the circuit breaker
@@ -32,7 +33,7 @@ func (s CircuitBreakerState) String() string {
 
 var (
 	// ErrCircuitBreakerOpen is returned when circuit breaker is open
-	ErrCircuitBreakerOpen = errors.New("circuit breaker is open")
+	ErrCircuitBreakerOpen = errs.New(errs.BackendUnavailable, "circuit breaker is open")
 )
 
 // CircuitBreakerConfig holds circuit breaker configuration
@@ -59,6 +60,9 @@ type CircuitBreaker struct {
 	config CircuitBreakerConfig
 	mu     sync.RWMutex
 
+	// backend labels the state metric; breakers without one are not exported
+	backend string
+
 	state            CircuitBreakerState
 	failures         int
 	lastFailureTime  time.Time
@@ -205,9 +209,21 @@ func (cb *CircuitBreaker) setState(state CircuitBreakerState) {
 		logger.Info("Circuit breaker state changed",
 			"from", oldState.String(),
 			"to", state.String())
+		if cb.backend != "" {
+			metrics.SetCircuitBreakerState(cb.backend, int(state))
+		}
 	}
 }
 
+// exportState exports the breaker's state as the circuit breaker metric of
+// backend from now on
+func (cb *CircuitBreaker) exportState(backend string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.backend = backend
+	metrics.SetCircuitBreakerState(backend, int(cb.state))
+}
+
 // GetState returns the current state (thread-safe)
 func (cb *CircuitBreaker) GetState() CircuitBreakerState {
 	cb.mu.RLock()
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"testing"
 	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestCircuitBreaker_InitialState(t *testing.T) {
@@ -296,3 +299,17 @@ func TestCircuitBreaker_LimitRequestsInHalfOpen(t *testing.T) {
 		t.Errorf("Circuit should be CLOSED or HALF_OPEN, got %s", state)
 	}
 }
+
+func TestCircuitBreaker_ExportsState(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{MaxFailures: 1, Timeout: time.Hour, MaxRequests: 1})
+	cb.exportState("cb-test:3306")
+	gauge := metrics.CircuitBreakerState.WithLabelValues("cb-test:3306")
+	if got := testutil.ToFloat64(gauge); got != float64(StateClosed) {
+		t.Errorf("state = %v, want closed", got)
+	}
+
+	cb.Call(func() error { return errors.New("backend error") })
+	if got := testutil.ToFloat64(gauge); got != float64(StateOpen) {
+		t.Errorf("state = %v, want open", got)
+	}
+}
@@ -0,0 +1,481 @@
+//go:build integration
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mariadb"
+	tcmysql "github.com/testcontainers/testcontainers-go/modules/mysql"
+)
+
+// The conformance suite runs real servers in Docker with the proxy in front
+// and checks that clients see the same protocol through the proxy as without
+// it. Run it with:
+//
+//	go test -tags integration -run TestConformance ./internal/proxy/
+
+const conformancePassword = "conformance-secret"
+
+// conformanceBackends are the servers the suite runs against
+var conformanceBackends = []struct {
+	name  string
+	image string
+	// mariadb selects the MariaDB module and its user syntax
+	mariadb bool
+	// cachingSHA2 is set for servers with caching_sha2_password
+	cachingSHA2 bool
+	args        []string
+}{
+	{name: "mysql-5.7", image: "mysql:5.7"},
+	{name: "mysql-8.0", image: "mysql:8.0", cachingSHA2: true},
+	// 8.4 loads mysql_native_password only when asked to
+	{name: "mysql-8.4", image: "mysql:8.4", cachingSHA2: true, args: []string{"--mysql-native-password=ON"}},
+	{name: "mariadb-11.4", image: "mariadb:11.4", mariadb: true},
+}
+
+// conformanceEnv is one backend with a proxy in front of it
+type conformanceEnv struct {
+	backend string // host:port of the server
+	proxy   string // host:port of the proxy
+}
+
+// dsn returns a DSN for ecommerce_db through the proxy or, with direct, to
+// the server itself
+func (e conformanceEnv) dsn(user, password string, direct bool, params ...string) string {
+	addr := e.proxy
+	if direct {
+		addr = e.backend
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/ecommerce_db", user, password, addr)
+	if len(params) > 0 {
+		dsn += "?" + strings.Join(params, "&")
+	}
+	return dsn
+}
+
+// open opens a handle as root, closed when the test ends
+func (e conformanceEnv) open(t *testing.T, direct bool, params ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("mysql", e.dsn("root", conformancePassword, direct, params...))
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping conformance suite in short mode")
+	}
+
+	for _, backend := range conformanceBackends {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+
+			env := conformanceEnv{backend: startBackend(ctx, t, backend.image, backend.mariadb, backend.args)}
+			env.proxy = startProxy(t, env.backend)
+
+			t.Run("handshake", func(t *testing.T) { testConformanceHandshake(t, env) })
+			t.Run("auth", func(t *testing.T) { testConformanceAuth(t, env, backend.mariadb, backend.cachingSHA2) })
+			t.Run("dual_write", func(t *testing.T) { testConformanceDualWrite(t, env) })
+			t.Run("transactions", func(t *testing.T) { testConformanceTransactions(t, env) })
+			t.Run("prepared_statements", func(t *testing.T) { testConformancePrepared(t, env) })
+			t.Run("big_packets", func(t *testing.T) { testConformanceBigPackets(t, env) })
+			t.Run("load_data_local", func(t *testing.T) { testConformanceLoadData(t, env) })
+		})
+	}
+}
+
+// startBackend runs the server with the repository's schema and returns its
+// address; the container is removed when the test ends
+func startBackend(ctx context.Context, t *testing.T, image string, isMariaDB bool, args []string) string {
+	t.Helper()
+	args = append([]string{"--local-infile=1", "--max-allowed-packet=64M"}, args...)
+
+	var ctr testcontainers.Container
+	var err error
+	if isMariaDB {
+		var c *mariadb.MariaDBContainer
+		c, err = mariadb.Run(ctx, image,
+			mariadb.WithDatabase("ecommerce_db"),
+			mariadb.WithUsername("root"),
+			mariadb.WithPassword(conformancePassword),
+			mariadb.WithScripts("../../scripts/init.sql"),
+			testcontainers.WithCmdArgs(args...))
+		if c != nil {
+			ctr = c
+		}
+	} else {
+		var c *tcmysql.MySQLContainer
+		c, err = tcmysql.Run(ctx, image,
+			tcmysql.WithDatabase("ecommerce_db"),
+			tcmysql.WithUsername("root"),
+			tcmysql.WithPassword(conformancePassword),
+			tcmysql.WithScripts("../../scripts/init.sql"),
+			testcontainers.WithCmdArgs(args...))
+		if c != nil {
+			ctr = c
+		}
+	}
+	if ctr != nil {
+		testcontainers.CleanupContainer(t, ctr)
+	}
+	if err != nil {
+		t.Fatalf("failed to start %s: %v", image, err)
+	}
+
+	host, err := ctr.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get %s host: %v", image, err)
+	}
+	port, err := ctr.MappedPort(ctx, "3306/tcp")
+	if err != nil {
+		t.Fatalf("failed to get %s port: %v", image, err)
+	}
+	return net.JoinHostPort(host, port.Port())
+}
+
+// startProxy runs a proxy with the repository's config.yaml in front of the
+// backend and returns its address
+func startProxy(t *testing.T, backend string) string {
+	t.Helper()
+	cfg, err := config.Load("../../config.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config.yaml: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(backend)
+	cfg.Database.Host = host
+	cfg.Database.Port, _ = strconv.Atoi(port)
+	cfg.Database.Password = conformancePassword
+	cfg.Database.Failover.Standbys = nil
+	cfg.Proxy.Host = "127.0.0.1"
+	cfg.Proxy.Port = freePort(t)
+	cfg.Proxy.Socket = ""
+	cfg.Proxy.PoolSize = 4
+
+	server := NewServer(cfg)
+	go server.Start()
+	t.Cleanup(server.Stop)
+
+	addr := net.JoinHostPort(cfg.Proxy.Host, strconv.Itoa(cfg.Proxy.Port))
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("proxy did not start listening on %s: %v", addr, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// freePort returns a TCP port nothing listens on
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func testConformanceHandshake(t *testing.T, env conformanceEnv) {
+	proxied := env.open(t, false)
+	direct := env.open(t, true)
+
+	if err := proxied.Ping(); err != nil {
+		t.Fatalf("ping through the proxy failed: %v", err)
+	}
+	var viaProxy, viaServer string
+	if err := proxied.QueryRow("SELECT VERSION()").Scan(&viaProxy); err != nil {
+		t.Fatalf("SELECT VERSION() through the proxy failed: %v", err)
+	}
+	if err := direct.QueryRow("SELECT VERSION()").Scan(&viaServer); err != nil {
+		t.Fatalf("SELECT VERSION() failed: %v", err)
+	}
+	if viaProxy != viaServer {
+		t.Errorf("expected server version %q through the proxy, got %q", viaServer, viaProxy)
+	}
+
+	// Without a default database, then switching with USE
+	noDB, err := sql.Open("mysql", fmt.Sprintf("root:%s@tcp(%s)/", conformancePassword, env.proxy))
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer noDB.Close()
+	conn, err := noDB.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("connecting without a database failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), "USE ecommerce_db"); err != nil {
+		t.Fatalf("USE through the proxy failed: %v", err)
+	}
+	var database string
+	if err := conn.QueryRowContext(context.Background(), "SELECT DATABASE()").Scan(&database); err != nil || database != "ecommerce_db" {
+		t.Errorf("expected ecommerce_db after USE, got %q (%v)", database, err)
+	}
+}
+
+func testConformanceAuth(t *testing.T, env conformanceEnv, isMariaDB, cachingSHA2 bool) {
+	direct := env.open(t, true)
+
+	nativeUser := "CREATE USER 'conf_native'@'%' IDENTIFIED WITH mysql_native_password BY 'native-pw'"
+	if isMariaDB || !cachingSHA2 {
+		// The default plugin before caching_sha2_password
+		nativeUser = "CREATE USER 'conf_native'@'%' IDENTIFIED BY 'native-pw'"
+	}
+	statements := []string{nativeUser, "GRANT ALL ON ecommerce_db.* TO 'conf_native'@'%'"}
+	if cachingSHA2 {
+		statements = append(statements,
+			"CREATE USER 'conf_sha2'@'%' IDENTIFIED WITH caching_sha2_password BY 'sha2-pw'",
+			"GRANT ALL ON ecommerce_db.* TO 'conf_sha2'@'%'")
+	}
+	for _, stmt := range statements {
+		if _, err := direct.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	// connect opens and pings a fresh connection through the proxy
+	connect := func(user, password string) error {
+		db, err := sql.Open("mysql", env.dsn(user, password, false))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return db.Ping()
+	}
+
+	// Servers defaulting to caching_sha2_password switch plugins for this user
+	if err := connect("conf_native", "native-pw"); err != nil {
+		t.Errorf("mysql_native_password through the proxy failed: %v", err)
+	}
+	if cachingSHA2 {
+		// The first login performs full authentication with the server's RSA
+		// key; the second succeeds from the server's cache (fast auth)
+		if err := connect("conf_sha2", "sha2-pw"); err != nil {
+			t.Errorf("caching_sha2_password full authentication through the proxy failed: %v", err)
+		}
+		if err := connect("conf_sha2", "sha2-pw"); err != nil {
+			t.Errorf("caching_sha2_password fast authentication through the proxy failed: %v", err)
+		}
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if err := connect("conf_native", "wrong"); !errors.As(err, &mysqlErr) || mysqlErr.Number != 1045 {
+		t.Errorf("expected access denied (1045) for a wrong password, got %v", err)
+	}
+}
+
+func testConformanceDualWrite(t *testing.T, env conformanceEnv) {
+	proxied := env.open(t, false, "interpolateParams=true")
+	direct := env.open(t, true)
+
+	shadowOf := func(t *testing.T, id int) string {
+		t.Helper()
+		var shadow sql.NullString
+		if err := direct.QueryRow("SELECT total_amount_idn FROM orders WHERE id = ?", id).Scan(&shadow); err != nil {
+			t.Fatalf("reading order %d failed: %v", id, err)
+		}
+		return shadow.String
+	}
+
+	// Halfway amounts at the fifth decimal round to even
+	tests := []struct {
+		id     int
+		amount int64
+		want   string
+	}{
+		{8001, 15000000, "15000.0000"},
+		{8002, 15500, "15.5000"},
+		{8003, 1234565, "1234.5650"},
+		{8004, 5, "0.0050"},
+	}
+	for _, tt := range tests {
+		if _, err := proxied.Exec("INSERT INTO orders (id, customer_id, total_amount, shipping_fee) VALUES (?, ?, ?, ?)",
+			tt.id, tt.id, tt.amount, 10000); err != nil {
+			t.Fatalf("INSERT of order %d through the proxy failed: %v", tt.id, err)
+		}
+		if got := shadowOf(t, tt.id); got != tt.want {
+			t.Errorf("order %d: expected shadow value %s for %d, got %s", tt.id, tt.want, tt.amount, got)
+		}
+	}
+
+	if _, err := proxied.Exec("UPDATE orders SET total_amount = ? WHERE id = ?", 25000000, 8001); err != nil {
+		t.Fatalf("UPDATE through the proxy failed: %v", err)
+	}
+	if got := shadowOf(t, 8001); got != "25000.0000" {
+		t.Errorf("expected shadow value 25000.0000 after UPDATE, got %s", got)
+	}
+}
+
+func testConformanceTransactions(t *testing.T, env conformanceEnv) {
+	proxied := env.open(t, false, "interpolateParams=true")
+	direct := env.open(t, true)
+
+	tx, err := proxied.Begin()
+	if err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if _, err := tx.Exec("INSERT INTO orders (id, customer_id, total_amount, shipping_fee) VALUES (?, ?, ?, ?)",
+		9001, 9001, 15000500, 25000); err != nil {
+		t.Fatalf("INSERT in transaction failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("COMMIT failed: %v", err)
+	}
+	var shadow string
+	if err := direct.QueryRow("SELECT total_amount_idn FROM orders WHERE id = 9001").Scan(&shadow); err != nil {
+		t.Fatalf("committed row not found: %v", err)
+	}
+	if shadow != "15000.5000" {
+		t.Errorf("expected shadow value 15000.5000, got %s", shadow)
+	}
+
+	tx, err = proxied.Begin()
+	if err != nil {
+		t.Fatalf("BEGIN failed: %v", err)
+	}
+	if _, err := tx.Exec("INSERT INTO orders (id, customer_id, total_amount, shipping_fee) VALUES (?, ?, ?, ?)",
+		9002, 9002, 2000000, 30000); err != nil {
+		t.Fatalf("INSERT in transaction failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("ROLLBACK failed: %v", err)
+	}
+	var count int
+	if err := direct.QueryRow("SELECT COUNT(*) FROM orders WHERE id = 9002").Scan(&count); err != nil || count != 0 {
+		t.Errorf("expected the rolled back row to be absent, got %d (%v)", count, err)
+	}
+}
+
+func testConformancePrepared(t *testing.T, env conformanceEnv) {
+	// Without interpolateParams the driver prepares, executes and closes
+	// statements with COM_STMT_*
+	proxied := env.open(t, false)
+
+	stmt, err := proxied.Prepare("SELECT id, total_amount FROM orders WHERE customer_id = ? AND status = ?")
+	if err != nil {
+		t.Fatalf("prepare through the proxy failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		var id, amount int64
+		if err := stmt.QueryRow(1001, "completed").Scan(&id, &amount); err != nil {
+			t.Fatalf("execute %d through the proxy failed: %v", i, err)
+		}
+		if amount != 500000 {
+			t.Errorf("expected total_amount 500000, got %d", amount)
+		}
+	}
+	if err := stmt.Close(); err != nil {
+		t.Fatalf("closing the statement failed: %v", err)
+	}
+
+	// One-off statements are prepared and closed per call; the connection
+	// must stay usable afterwards
+	if _, err := proxied.Exec("UPDATE orders SET status = ? WHERE id = ?", "shipped", 3); err != nil {
+		t.Fatalf("prepared UPDATE through the proxy failed: %v", err)
+	}
+	var status string
+	if err := proxied.QueryRow("SELECT status FROM orders WHERE id = ?", 3).Scan(&status); err != nil || status != "shipped" {
+		t.Errorf("expected status shipped, got %q (%v)", status, err)
+	}
+}
+
+func testConformanceBigPackets(t *testing.T, env conformanceEnv) {
+	direct := env.open(t, true)
+	if _, err := direct.Exec("CREATE TABLE conformance_blobs (id INT PRIMARY KEY, data LONGBLOB)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	// 20MB spans two protocol packets in both directions
+	data := bytes.Repeat([]byte("transisidb"), 2_000_000)
+	want := sha256.Sum256(data)
+
+	check := func(t *testing.T, db *sql.DB, id int) {
+		t.Helper()
+		var got []byte
+		if err := db.QueryRow(fmt.Sprintf("SELECT data FROM conformance_blobs WHERE id = %d", id)).Scan(&got); err != nil {
+			t.Fatalf("reading the blob through the proxy failed: %v", err)
+		}
+		if sha256.Sum256(got) != want {
+			t.Errorf("blob %d differs after the round trip: %d bytes", id, len(got))
+		}
+	}
+
+	// A COM_QUERY of over 16MB
+	text := env.open(t, false, "interpolateParams=true")
+	if _, err := text.Exec("INSERT INTO conformance_blobs (id, data) VALUES (?, ?)", 1, data); err != nil {
+		t.Fatalf("INSERT of a 20MB query through the proxy failed: %v", err)
+	}
+	check(t, text, 1)
+
+	// COM_STMT_SEND_LONG_DATA, used for parameters of over half the
+	// driver's packet size
+	prepared := env.open(t, false, "maxAllowedPacket=16777216")
+	if _, err := prepared.Exec("INSERT INTO conformance_blobs (id, data) VALUES (?, ?)", 2, data); err != nil {
+		t.Fatalf("INSERT with long data through the proxy failed: %v", err)
+	}
+	check(t, text, 2)
+}
+
+func testConformanceLoadData(t *testing.T, env conformanceEnv) {
+	direct := env.open(t, true)
+	if _, err := direct.Exec("CREATE TABLE conformance_load (id INT PRIMARY KEY, amount BIGINT)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	// Handlers are global; backends run in parallel
+	const rows = 10000
+	handler := "conformance-" + env.proxy
+	mysql.RegisterReaderHandler(handler, func() io.Reader {
+		var buf bytes.Buffer
+		for i := 1; i <= rows; i++ {
+			fmt.Fprintf(&buf, "%d,%d\n", i, i*1000)
+		}
+		return &buf
+	})
+	defer mysql.DeregisterReaderHandler(handler)
+
+	proxied := env.open(t, false)
+	result, err := proxied.Exec("LOAD DATA LOCAL INFILE 'Reader::" + handler + "' INTO TABLE conformance_load FIELDS TERMINATED BY ','")
+	if err != nil {
+		t.Fatalf("LOAD DATA LOCAL INFILE through the proxy failed: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n != rows {
+		t.Errorf("expected %d rows affected, got %d", rows, n)
+	}
+	var count int
+	if err := direct.QueryRow("SELECT COUNT(*) FROM conformance_load").Scan(&count); err != nil || count != rows {
+		t.Errorf("expected %d rows loaded, got %d (%v)", rows, count, err)
+	}
+
+	// The connection stays usable after the file transfer
+	if err := proxied.Ping(); err != nil {
+		t.Errorf("ping after LOAD DATA failed: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// maxShadowProposals bounds the proposals kept in memory
+const maxShadowProposals = 100
+
+// schemaWatcher collects the effects of DDL accepted by the backend: shadow
+// column proposals for new currency-looking columns, and notifications to
+// subscribers holding per-table state that the DDL made stale
+type schemaWatcher struct {
+	mu          sync.Mutex
+	proposals   []parser.ShadowProposal
+	subscribers []func(database string, change parser.SchemaChange)
+}
+
+// newSchemaWatcher creates an empty watcher
+func newSchemaWatcher() *schemaWatcher {
+	return &schemaWatcher{}
+}
+
+// Subscribe registers fn to be called for every schema change
+func (w *schemaWatcher) Subscribe(fn func(database string, change parser.SchemaChange)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Proposals returns the recorded shadow column proposals, newest first
+func (w *schemaWatcher) Proposals() []parser.ShadowProposal {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	proposals := make([]parser.ShadowProposal, len(w.proposals))
+	for i, proposal := range w.proposals {
+		proposals[len(w.proposals)-1-i] = proposal
+	}
+	return proposals
+}
+
+// propose records a proposal, replacing an older one for the same column
+func (w *schemaWatcher) propose(proposal parser.ShadowProposal) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	kept := w.proposals[:0]
+	for _, existing := range w.proposals {
+		if existing.Table != proposal.Table || existing.Column != proposal.Column {
+			kept = append(kept, existing)
+		}
+	}
+	w.proposals = append(kept, proposal)
+	if len(w.proposals) > maxShadowProposals {
+		w.proposals = w.proposals[len(w.proposals)-maxShadowProposals:]
+	}
+}
+
+// notify calls the subscribers for a schema change
+func (w *schemaWatcher) notify(database string, change parser.SchemaChange) {
+	w.mu.Lock()
+	subscribers := append([]func(string, parser.SchemaChange){}, w.subscribers...)
+	w.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(database, change)
+	}
+}
+
+// observeSchemaChanges reacts to DDL the backend accepted: changes to
+// configured tables and columns are logged, new currency-looking columns are
+// handled per conversion.ddl_action, and subscribers are notified
+func (s *Session) observeSchemaChanges(changes []parser.SchemaChange) {
+	action := s.config.Conversion.DDLAction
+	if action == "" {
+		action = config.DDLActionWarn
+	}
+
+	for _, change := range changes {
+		metrics.RecordSchemaChange(change.Kind)
+
+		database := change.Database
+		if database == "" {
+			database = s.parser.CurrentDatabase()
+		}
+		tableKey, tableConfig, configured := s.parser.LookupTable(change.Database, change.Table)
+
+		if configured && (change.Kind == parser.SchemaChangeDrop || change.NewTable != "") {
+			logger.Warn("DDL dropped or renamed a table with currency columns; update its table config",
+				"table", tableKey, "kind", change.Kind, "new_table", change.NewTable, "conn_id", s.connID)
+		}
+
+		for _, col := range change.Columns {
+			if configured && col.Action != parser.ColumnAdd {
+				if colKey, _, exists := tableConfig.LookupColumn(col.Name); exists {
+					logger.Warn("DDL changed a configured currency column; review its column config",
+						"table", tableKey, "column", colKey, "action", col.Action,
+						"new_name", col.NewName, "type", col.Type, "conn_id", s.connID)
+				}
+				continue
+			}
+			if col.Action != parser.ColumnAdd || action == config.DDLActionIgnore {
+				continue
+			}
+			if !parser.LooksMonetary(col.Name, col.Type) {
+				continue
+			}
+			if configured {
+				if _, _, exists := tableConfig.LookupColumn(col.Name); exists {
+					continue
+				}
+			}
+
+			table := change.Table
+			if configured {
+				table = tableKey
+			}
+			metrics.RecordUnconfiguredCurrencyColumn(table)
+			logger.Warn("New column looks like a currency amount but has no shadow column config",
+				"table", table, "column", col.Name, "type", col.Type, "conn_id", s.connID)
+
+			if action == config.DDLActionPropose && s.ddl != nil {
+				s.ddl.propose(parser.ProposeShadowColumn(table, col, s.config.Conversion))
+			}
+		}
+
+		if s.ddl != nil {
+			s.ddl.notify(database, change)
+		}
+	}
+}
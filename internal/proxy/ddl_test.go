@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+func TestSession_ObserveSchemaChanges(t *testing.T) {
+	tables := config.TablesConfig{
+		"orders": {Enabled: true, Columns: map[string]config.ColumnConfig{
+			"total_amount": {SourceColumn: "total_amount", TargetColumn: "total_amount_idn"},
+		}},
+	}
+	cfg := &config.Config{
+		Tables:     tables,
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, DDLAction: config.DDLActionPropose},
+	}
+
+	session := NewSession(NewMockConn(), cfg, nil)
+	session.parser = parser.NewParser(tables)
+	session.parser.SetCurrentDatabase("shop")
+	session.ddl = newSchemaWatcher()
+
+	var notified []string
+	session.ddl.Subscribe(func(database string, change parser.SchemaChange) {
+		notified = append(notified, database+"."+change.Table)
+	})
+
+	changes, ok := parser.ParseSchemaChange("ALTER TABLE orders ADD COLUMN refund_amount BIGINT, ADD COLUMN total_amount_idn DECIMAL(19,4), ADD note TEXT")
+	if !ok {
+		t.Fatal("expected ALTER TABLE to parse")
+	}
+	session.observeSchemaChanges(changes)
+
+	proposals := session.ddl.Proposals()
+	if len(proposals) != 1 {
+		t.Fatalf("expected one proposal, got %+v", proposals)
+	}
+	if proposals[0].Table != "orders" || proposals[0].Config.TargetColumn != "refund_amount_idn" {
+		t.Errorf("unexpected proposal: %+v", proposals[0])
+	}
+	if len(notified) != 1 || notified[0] != "shop.orders" {
+		t.Errorf("expected subscribers to be notified for shop.orders, got %v", notified)
+	}
+
+	// Seeing the same column again replaces the proposal
+	session.observeSchemaChanges(changes)
+	if got := len(session.ddl.Proposals()); got != 1 {
+		t.Errorf("expected proposals to be deduplicated, got %d", got)
+	}
+
+	// warn records nothing
+	cfg.Conversion.DDLAction = config.DDLActionWarn
+	changes, _ = parser.ParseSchemaChange("ALTER TABLE invoices ADD COLUMN tax BIGINT")
+	session.observeSchemaChanges(changes)
+	if got := len(session.ddl.Proposals()); got != 1 {
+		t.Errorf("expected warn to record no proposal, got %d", got)
+	}
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/discovery"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+// discoveryWatch resolves one discovery config and applies the endpoints
+type discoveryWatch struct {
+	name     string
+	resolver discovery.Resolver
+	interval time.Duration
+	apply    func(endpoints []discovery.Endpoint)
+}
+
+// newDiscoveryWatch creates a watch for a discovery config
+func newDiscoveryWatch(name string, cfg config.DiscoveryConfig, apply func([]discovery.Endpoint)) (*discoveryWatch, error) {
+	resolver, err := discovery.NewResolver(cfg)
+	if err != nil {
+		return nil, err
+	}
+	interval := cfg.RefreshInterval
+	if interval <= 0 {
+		interval = config.DefaultDiscoveryRefreshInterval
+	}
+	return &discoveryWatch{name: name, resolver: resolver, interval: interval, apply: apply}, nil
+}
+
+// refresh resolves the endpoints once. A failed resolution keeps the
+// backends of the previous one.
+func (w *discoveryWatch) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, w.interval)
+	defer cancel()
+
+	endpoints, err := w.resolver.Resolve(ctx)
+	if err != nil {
+		logger.Warn("Backend discovery failed, keeping current backends", "backend", w.name, "error", err)
+		return
+	}
+	w.apply(endpoints)
+}
+
+// run refreshes every interval until ctx is cancelled
+func (w *discoveryWatch) run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.refresh(ctx)
+		}
+	}
+}
+
+// WatchDiscovery resolves the primary and replicas configured with discovery
+// and keeps their pools current until ctx is cancelled. The first resolution
+// runs before it returns so sessions start with discovered backends.
+func (s *Server) WatchDiscovery(ctx context.Context) {
+	var watches []*discoveryWatch
+
+	if s.config.Database.Discovery.Enabled() {
+		primary := &discoveredPrimary{server: s}
+		watch, err := newDiscoveryWatch("primary", s.config.Database.Discovery, primary.apply)
+		if err != nil {
+			logger.Error("Failed to configure primary discovery", "error", err)
+		} else {
+			watches = append(watches, watch)
+		}
+	}
+
+	for _, replica := range s.config.Database.Replicas {
+		if !replica.Discovery.Enabled() {
+			continue
+		}
+		replica := replica
+		apply := func(endpoints []discovery.Endpoint) {
+			s.router.setDiscovered(replica, endpoints, func(endpoint discovery.Endpoint) (*BackendPool, error) {
+				r := replica
+				r.Host, r.Port, r.Socket = endpoint.Host, endpoint.Port, ""
+				return s.newBackendPool(s.config.ForReplica(r))
+			})
+		}
+		watch, err := newDiscoveryWatch(replica.Name, replica.Discovery, apply)
+		if err != nil {
+			logger.Error("Failed to configure replica discovery", "replica", replica.Name, "error", err)
+			continue
+		}
+		watches = append(watches, watch)
+	}
+
+	for _, watch := range watches {
+		watch.refresh(ctx)
+		go watch.run(ctx)
+		logger.Info("Backend discovery enabled", "backend", watch.name, "refresh_interval", watch.interval)
+	}
+}
+
+// discoveredPrimary switches the primary pool when discovery returns a new
+// preferred endpoint
+type discoveredPrimary struct {
+	server  *Server
+	current string
+}
+
+// apply makes the first endpoint the primary. An empty result keeps the
+// current primary.
+func (p *discoveredPrimary) apply(endpoints []discovery.Endpoint) {
+	if len(endpoints) == 0 {
+		logger.Warn("Primary discovery returned no endpoints, keeping current primary", "primary", p.current)
+		return
+	}
+	endpoint := endpoints[0]
+	if endpoint.String() == p.current {
+		return
+	}
+
+	logger.Info("Discovered primary backend", "from", p.current, "to", endpoint.String())
+	p.current = endpoint.String()
+	p.server.switchPrimary(primaryCandidate{
+		name: endpoint.String(),
+		config: p.server.config.ForStandby(config.StandbyConfig{
+			Name: endpoint.String(),
+			Host: endpoint.Host,
+			Port: endpoint.Port,
+		}),
+	})
+}
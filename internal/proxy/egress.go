@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"golang.org/x/net/proxy"
+)
+
+// tunedDialer opens TCP connections with the backend socket options
+type tunedDialer struct {
+	dialer net.Dialer
+	tcp    config.TCPConfig
+}
+
+// newTunedDialer returns a dialer applying opts to every connection
+func newTunedDialer(opts config.TCPConfig) *tunedDialer {
+	return &tunedDialer{
+		dialer: net.Dialer{
+			KeepAlive: -1, // applied in applyTCPOptions
+			Control:   socketControl(opts),
+		},
+		tcp: opts,
+	}
+}
+
+// Dial implements proxy.Dialer
+func (d *tunedDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext implements proxy.ContextDialer
+func (d *tunedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyTCPOptions(conn, d.tcp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to tune connection: %w", err)
+	}
+	return conn, nil
+}
+
+// egressDialer returns the dialer for backend TCP addresses: base itself, or
+// the configured SOCKS5 or HTTP CONNECT proxy reached through base, so socket
+// options apply to the connection to the proxy
+func egressDialer(cfg config.EgressProxyConfig, base *tunedDialer) (proxy.ContextDialer, error) {
+	if !cfg.Enabled() {
+		return base, nil
+	}
+	endpoint, err := cfg.Endpoint()
+	if err != nil {
+		return nil, fmt.Errorf("egress proxy: %w", err)
+	}
+
+	switch endpoint.Scheme {
+	case config.EgressHTTP:
+		return &connectDialer{endpoint: endpoint, forward: base}, nil
+	default:
+		var auth *proxy.Auth
+		if endpoint.User != nil {
+			password, _ := endpoint.User.Password()
+			auth = &proxy.Auth{User: endpoint.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", endpoint.Host, auth, base)
+		if err != nil {
+			return nil, fmt.Errorf("egress proxy: %w", err)
+		}
+		if endpoint.Scheme == config.EgressSOCKS5 {
+			// socks5 resolves names here; socks5h leaves it to the proxy
+			return &resolvingDialer{forward: dialer.(proxy.ContextDialer)}, nil
+		}
+		return dialer.(proxy.ContextDialer), nil
+	}
+}
+
+// OpenBackend opens a database/sql handle on the backend of cfg whose TCP
+// connections go through the egress proxy, like the pool's
+func OpenBackend(cfg *config.Config) (*sql.DB, error) {
+	if !cfg.Database.EgressProxy.Enabled() || cfg.Database.Socket != "" {
+		return sql.Open("mysql", cfg.GetDatabaseDSN())
+	}
+	dsn, err := mysql.ParseDSN(cfg.GetDatabaseDSN())
+	if err != nil {
+		return nil, err
+	}
+	dialer, err := egressDialer(cfg.Database.EgressProxy, newTunedDialer(cfg.Database.TCP))
+	if err != nil {
+		return nil, err
+	}
+	dsn.DialFunc = dialer.DialContext
+	connector, err := mysql.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// resolvingDialer resolves host names locally before dialing by address
+type resolvingDialer struct {
+	forward proxy.ContextDialer
+}
+
+// DialContext implements proxy.ContextDialer
+func (d *resolvingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) == nil {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		addr = net.JoinHostPort(addrs[0], port)
+	}
+	return d.forward.DialContext(ctx, network, addr)
+}
+
+// connectDialer tunnels connections through an HTTP proxy with CONNECT
+type connectDialer struct {
+	endpoint *url.URL
+	forward  proxy.ContextDialer
+}
+
+// DialContext implements proxy.ContextDialer
+func (d *connectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, network, d.endpoint.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to egress proxy %s: %w", d.endpoint.Host, err)
+	}
+	tunnel, err := d.connect(ctx, conn, addr)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("egress proxy %s: %w", d.endpoint.Host, err)
+	}
+	return tunnel, nil
+}
+
+// connect asks the proxy on conn to open a tunnel to addr
+func (d *connectDialer) connect(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.endpoint.User != nil {
+		password, _ := d.endpoint.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.endpoint.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("failed to send CONNECT: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s refused: %s", addr, resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		// The server spoke first and its bytes were read with the response
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes sit in a reader
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read drains the buffered bytes before reading from the connection
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,259 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+// greetingBackend accepts one connection and writes a greeting first, as
+// MySQL does
+func greetingBackend(t *testing.T) *net.TCPAddr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Write([]byte("hello"))
+			io.Copy(io.Discard, conn)
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr)
+}
+
+// relay copies between two connections until either closes
+func relay(a, b net.Conn) {
+	go func() { io.Copy(a, b); a.Close() }()
+	io.Copy(b, a)
+	b.Close()
+}
+
+// connectProxy serves one CONNECT request, checking its Proxy-Authorization
+func connectProxy(t *testing.T, wantAuth string, targets chan<- string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect {
+			conn.Close()
+			return
+		}
+		targets <- req.Host
+		if req.Header.Get("Proxy-Authorization") != wantAuth {
+			io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			conn.Close()
+			return
+		}
+		backend, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+			conn.Close()
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		relay(conn, backend)
+	}()
+	return ln.Addr().String()
+}
+
+// socks5Proxy serves one SOCKS5 CONNECT with username/password auth
+func socks5Proxy(t *testing.T, user, password string, targets chan<- string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		r := bufio.NewReader(conn)
+		// Greeting: version, methods; choose username/password
+		header := make([]byte, 2)
+		io.ReadFull(r, header)
+		io.ReadFull(r, make([]byte, header[1]))
+		conn.Write([]byte{5, 2})
+
+		// RFC 1929 sub-negotiation
+		version, _ := r.ReadByte()
+		n, _ := r.ReadByte()
+		gotUser := make([]byte, n)
+		io.ReadFull(r, gotUser)
+		n, _ = r.ReadByte()
+		gotPassword := make([]byte, n)
+		io.ReadFull(r, gotPassword)
+		if version != 1 || string(gotUser) != user || string(gotPassword) != password {
+			conn.Write([]byte{1, 1})
+			conn.Close()
+			return
+		}
+		conn.Write([]byte{1, 0})
+
+		// Request: version, CONNECT, reserved, address type, address, port
+		request := make([]byte, 4)
+		io.ReadFull(r, request)
+		var host string
+		switch request[3] {
+		case 1:
+			ip := make([]byte, 4)
+			io.ReadFull(r, ip)
+			host = net.IP(ip).String()
+		case 3:
+			n, _ := r.ReadByte()
+			name := make([]byte, n)
+			io.ReadFull(r, name)
+			host = string(name)
+		}
+		port := make([]byte, 2)
+		io.ReadFull(r, port)
+		target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+		targets <- target
+
+		backend, err := net.Dial("tcp", target)
+		if err != nil {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			conn.Close()
+			return
+		}
+		conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+		relay(conn, backend)
+	}()
+	return ln.Addr().String()
+}
+
+// readGreeting reads the backend's first bytes through conn
+func readGreeting(t *testing.T, conn net.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Errorf("expected the backend greeting through the proxy, got %q (%v)", buf, err)
+	}
+}
+
+func TestDialBackend_HTTPConnect(t *testing.T) {
+	backend := greetingBackend(t)
+	targets := make(chan string, 1)
+	// "db:s3cret" in base64
+	proxyAddr := connectProxy(t, "Basic ZGI6czNjcmV0", targets)
+
+	conn, err := dialBackend(&config.DatabaseConfig{
+		Host:              backend.IP.String(),
+		Port:              backend.Port,
+		ConnectionTimeout: 2 * time.Second,
+		EgressProxy:       config.EgressProxyConfig{URL: "http://" + proxyAddr, Username: "db", Password: "s3cret"},
+	})
+	if err != nil {
+		t.Fatalf("dialBackend through CONNECT failed: %v", err)
+	}
+	defer conn.Close()
+	if got := <-targets; got != backend.String() {
+		t.Errorf("expected CONNECT to %s, got %s", backend, got)
+	}
+	readGreeting(t, conn)
+}
+
+func TestDialBackend_HTTPConnectRefused(t *testing.T) {
+	backend := greetingBackend(t)
+	targets := make(chan string, 1)
+	proxyAddr := connectProxy(t, "Basic ZGI6czNjcmV0", targets)
+
+	_, err := dialBackend(&config.DatabaseConfig{
+		Host:              backend.IP.String(),
+		Port:              backend.Port,
+		ConnectionTimeout: 2 * time.Second,
+		EgressProxy:       config.EgressProxyConfig{URL: "http://" + proxyAddr},
+	})
+	if err == nil || !strings.Contains(err.Error(), "407") {
+		t.Errorf("expected the proxy's 407 in the error, got %v", err)
+	}
+}
+
+func TestDialBackend_SOCKS5(t *testing.T) {
+	backend := greetingBackend(t)
+	targets := make(chan string, 1)
+	proxyAddr := socks5Proxy(t, "db", "s3cret", targets)
+
+	conn, err := dialBackend(&config.DatabaseConfig{
+		Host:              backend.IP.String(),
+		Port:              backend.Port,
+		ConnectionTimeout: 2 * time.Second,
+		EgressProxy:       config.EgressProxyConfig{URL: "socks5://db:s3cret@" + proxyAddr},
+	})
+	if err != nil {
+		t.Fatalf("dialBackend through SOCKS5 failed: %v", err)
+	}
+	defer conn.Close()
+	if got := <-targets; got != backend.String() {
+		t.Errorf("expected SOCKS5 CONNECT to %s, got %s", backend, got)
+	}
+	readGreeting(t, conn)
+}
+
+func TestDialBackend_SOCKS5WrongPassword(t *testing.T) {
+	backend := greetingBackend(t)
+	proxyAddr := socks5Proxy(t, "db", "s3cret", make(chan string, 1))
+
+	_, err := dialBackend(&config.DatabaseConfig{
+		Host:              backend.IP.String(),
+		Port:              backend.Port,
+		ConnectionTimeout: 2 * time.Second,
+		EgressProxy:       config.EgressProxyConfig{URL: "socks5://" + proxyAddr, Username: "db", Password: "wrong"},
+	})
+	if err == nil {
+		t.Error("expected dialBackend to fail with wrong SOCKS5 credentials")
+	}
+}
+
+func TestOpenBackend_UsesEgressProxy(t *testing.T) {
+	backend := greetingBackend(t)
+	targets := make(chan string, 1)
+	proxyAddr := connectProxy(t, "", targets)
+
+	db, err := OpenBackend(&config.Config{Database: config.DatabaseConfig{
+		Type:        "mysql",
+		Host:        backend.IP.String(),
+		Port:        backend.Port,
+		EgressProxy: config.EgressProxyConfig{URL: "http://" + proxyAddr},
+	}})
+	if err != nil {
+		t.Fatalf("OpenBackend failed: %v", err)
+	}
+	defer db.Close()
+
+	// The fake backend does not speak MySQL; only the route matters
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go db.PingContext(ctx)
+	select {
+	case got := <-targets:
+		if got != backend.String() {
+			t.Errorf("expected CONNECT to %s, got %s", backend, got)
+		}
+	case <-ctx.Done():
+		t.Error("expected the metadata connection to go through the egress proxy")
+	}
+}
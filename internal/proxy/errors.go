@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"io"
+
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// Error codes of failures the proxy reports itself. Conditions MySQL has an
+// error for use the server's code; proxy-specific failures use the 50xxx
+// range so applications can tell them from backend errors. Backend failures
+// are reported with the codes of their errs classification.
+const (
+	codeTooManyConnections = 1040 // ER_CON_COUNT_ERROR
+	codeHandshakeError     = 1043 // ER_HANDSHAKE_ERROR
+	codeBadField           = 1054 // ER_BAD_FIELD_ERROR
+	codeNoSuchTable        = 1146 // ER_NO_SUCH_TABLE
+	codeWrongArguments     = 1210 // ER_WRONG_ARGUMENTS
+	codeAccessDenied       = 1227 // ER_SPECIFIC_ACCESS_DENIED_ERROR
+	codeNotSupportedYet    = 1235 // ER_NOT_SUPPORTED_YET
+	codeNonUpdatableTable  = 1288 // ER_NON_UPDATABLE_TABLE
+	codeReadOnly           = 1792 // ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION
+	codeInteractionTimeout = 4031 // ER_CLIENT_INTERACTION_TIMEOUT
+
+	codeDualWriteRejected  = 50100 // fail_closed table could not be dual-written
+	codeTransactionAborted = 50101 // transaction rolled back after a dual-write failure
+	codeQueryBlocked       = 50200 // statement blocked by a query rule
+	codeAdminFailed        = 50300 // proxy state change could not be saved
+)
+
+// proxyError is an ERR packet the proxy sends instead of a backend response
+type proxyError struct {
+	code     uint16
+	sqlState string
+	message  string
+}
+
+// Error returns the message, so proxy errors can be returned up to the
+// session that sends them
+func (e proxyError) Error() string {
+	return e.message
+}
+
+// classifiedError returns an ERR packet with the MySQL error code and
+// SQLSTATE of an errs code
+func classifiedError(code errs.Code, message string) proxyError {
+	mysqlCode, sqlState := code.MySQL()
+	return proxyError{mysqlCode, sqlState, message}
+}
+
+// backendError translates a failure to get a backend connection
+func backendError(err error) proxyError {
+	if errs.CodeOf(err) == errs.BackendUnavailable {
+		return classifiedError(errs.BackendUnavailable, "TransisiDB: backend unavailable (circuit breaker open)")
+	}
+	return classifiedError(errs.BackendConnect, "TransisiDB: cannot connect to backend: "+err.Error())
+}
+
+// tooManyConnections is sent to clients beyond max_connections_per_host
+var tooManyConnections = proxyError{codeTooManyConnections, "08004", "TransisiDB: too many connections"}
+
+// idleTimeoutExceeded is sent to clients disconnected after proxy.idle_timeout, as
+// MySQL does after wait_timeout
+var idleTimeoutExceeded = proxyError{codeInteractionTimeout, "HY000",
+	"TransisiDB: the client was disconnected because it was idle longer than proxy.idle_timeout"}
+
+// write sends the ERR packet with the given sequence ID. Before the handshake
+// the sequence ID is 0, which clients report as a connection error.
+func (e proxyError) write(w io.Writer, sequenceID uint8) error {
+	return protocol.WritePacket(w, sequenceID, protocol.EncodeERRPacket(e.code, e.sqlState, e.message))
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+func TestBackendError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errs.Code
+	}{
+		{"circuit open", fmt.Errorf("backend unavailable (circuit breaker open): %w", ErrCircuitBreakerOpen), errs.BackendUnavailable},
+		{"dial error", errors.New("dial tcp 10.0.0.5:3306: connect: connection refused"), errs.BackendConnect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, _ := tt.want.MySQL()
+			if got := backendError(tt.err); got.code != want || got.sqlState != "08S01" {
+				t.Errorf("backendError() = %d %s, want %d 08S01", got.code, got.sqlState, want)
+			}
+		})
+	}
+}
+
+func TestSession_Handle_BackendUnavailable(t *testing.T) {
+	cfg := &config.Config{Database: config.DatabaseConfig{Host: "localhost", Port: 3307}}
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{MaxFailures: 1, Timeout: time.Hour})
+	breaker.Call(func() error { return errors.New("connection refused") })
+	pool := &BackendPool{
+		config:         cfg,
+		connections:    make(chan *BackendConn, 1),
+		circuitBreaker: breaker,
+		lifetimes:      lifetimesFor(config.ProxyConfig{}),
+	}
+
+	conn := NewMockConn()
+	session := NewSession(conn, cfg, pool)
+	if err := session.Handle(context.Background()); err == nil {
+		t.Fatal("expected Handle to fail without a backend")
+	}
+
+	// The error replaces the server handshake
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+	if err != nil {
+		t.Fatalf("expected ERR packet: %v", err)
+	}
+	if want, _ := errs.BackendUnavailable.MySQL(); pkt.SequenceID != 0 || errPkt.ErrorCode != want {
+		t.Errorf("unexpected error: sequence %d code %d %q", pkt.SequenceID, errPkt.ErrorCode, errPkt.ErrorMessage)
+	}
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+)
+
+// primaryProbe reports whether the backend described by cfg accepts writes
+type primaryProbe func(ctx context.Context, cfg *config.Config) (writable bool, err error)
+
+// primaryCandidate is the configured primary or one of its standbys
+type primaryCandidate struct {
+	name   string
+	config *config.Config
+}
+
+// primaryMonitor probes the current primary and fails over to the first
+// writable standby once the primary failed enough consecutive probes
+type primaryMonitor struct {
+	candidates []primaryCandidate
+	current    int
+	failures   int
+	interval   time.Duration
+	timeout    time.Duration
+	threshold  int
+	probe      primaryProbe
+	// switchTo makes new sessions use a candidate
+	switchTo func(candidate primaryCandidate)
+}
+
+// newPrimaryMonitor creates a monitor for the configured primary and standbys
+func newPrimaryMonitor(cfg *config.Config, probe primaryProbe, switchTo func(primaryCandidate)) *primaryMonitor {
+	failover := cfg.Database.Failover
+	m := &primaryMonitor{
+		interval:  failover.ProbeInterval,
+		timeout:   failover.ProbeTimeout,
+		threshold: failover.FailureThreshold,
+		probe:     probe,
+		switchTo:  switchTo,
+	}
+	if m.interval <= 0 {
+		m.interval = config.DefaultFailoverProbeInterval
+	}
+	if m.timeout <= 0 {
+		m.timeout = config.DefaultFailoverProbeTimeout
+	}
+	if m.threshold <= 0 {
+		m.threshold = config.DefaultFailoverFailureThreshold
+	}
+
+	primary := *cfg
+	primary.Database.Failover = config.FailoverConfig{}
+	m.candidates = append(m.candidates, primaryCandidate{name: "primary", config: &primary})
+	for _, sb := range failover.Standbys {
+		m.candidates = append(m.candidates, primaryCandidate{name: sb.Name, config: cfg.ForStandby(sb)})
+	}
+	return m
+}
+
+// run probes until ctx is cancelled
+func (m *primaryMonitor) run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(ctx)
+		}
+	}
+}
+
+// check probes the current primary and fails over when it reached the
+// failure threshold. A backend that is reachable but read-only counts as
+// failed, so a demoted primary is left too.
+func (m *primaryMonitor) check(ctx context.Context) {
+	current := m.candidates[m.current]
+	writable, err := m.probeCandidate(ctx, current)
+	if writable {
+		m.failures = 0
+		return
+	}
+
+	m.failures++
+	logger.Warn("Primary probe failed", "backend", current.name, "failures", m.failures,
+		"threshold", m.threshold, "error", err)
+	if m.failures < m.threshold {
+		return
+	}
+
+	for i, candidate := range m.candidates {
+		if i == m.current {
+			continue
+		}
+		writable, err := m.probeCandidate(ctx, candidate)
+		if !writable {
+			logger.Debug("Failover candidate not writable", "backend", candidate.name, "error", err)
+			continue
+		}
+
+		logger.Warn("Failing over to new primary", "from", current.name, "to", candidate.name)
+		metrics.RecordPrimaryFailover(candidate.name)
+		m.current = i
+		m.failures = 0
+		m.switchTo(candidate)
+		return
+	}
+	logger.Error("Primary is down and no standby accepts writes", "backend", current.name)
+}
+
+// probeCandidate runs the probe within the probe timeout
+func (m *primaryMonitor) probeCandidate(ctx context.Context, candidate primaryCandidate) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	return m.probe(ctx, candidate.config)
+}
+
+// probeReadOnly connects with the configured credentials and checks that the
+// server has read_only disabled
+func probeReadOnly(ctx context.Context, cfg *config.Config) (bool, error) {
+	db, err := OpenBackend(cfg)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var readOnly bool
+	if err := db.QueryRowContext(ctx, "SELECT @@global.read_only").Scan(&readOnly); err != nil {
+		return false, fmt.Errorf("failed to query read_only: %w", err)
+	}
+	if readOnly {
+		return false, fmt.Errorf("read_only is enabled")
+	}
+	return true, nil
+}
+
+// WatchPrimary follows primary failovers to the configured standbys until ctx
+// is cancelled. Sessions already connected keep their backend connection.
+func (s *Server) WatchPrimary(ctx context.Context) {
+	if !s.config.Database.Failover.Enabled() {
+		return
+	}
+	monitor := newPrimaryMonitor(s.config, probeReadOnly, s.switchPrimary)
+	go monitor.run(ctx)
+	logger.Info("Primary failover enabled", "standbys", len(s.config.Database.Failover.Standbys),
+		"probe_interval", monitor.interval, "failure_threshold", monitor.threshold)
+}
+
+// switchPrimary replaces the primary pool with one for candidate
+func (s *Server) switchPrimary(candidate primaryCandidate) {
+	pool, err := s.newBackendPool(candidate.config)
+	if err != nil {
+		logger.Error("Failed to create pool for new primary", "backend", candidate.name, "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	old := s.backendPool
+	s.backendPool = pool
+	s.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+}
+
+// newBackendPool creates a pool that uses the current pool lifetimes, which
+// may have been reloaded since cfg was loaded
+func (s *Server) newBackendPool(cfg *config.Config) (*BackendPool, error) {
+	pool, err := NewBackendPool(cfg, s.config.Proxy.PoolSize)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	lifetimes := s.lifetimes
+	s.mu.Unlock()
+	pool.SetLifetimes(lifetimes)
+	return pool, nil
+}
+
+// primaryPool returns the pool of the current primary
+func (s *Server) primaryPool() *BackendPool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.backendPool
+}
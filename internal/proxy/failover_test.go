@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+func TestPrimaryMonitor_FailsOverAfterThreshold(t *testing.T) {
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Host: "db-1",
+		Port: 3306,
+		Failover: config.FailoverConfig{
+			FailureThreshold: 2,
+			Standbys: []config.StandbyConfig{
+				{Name: "db-2", Host: "db-2", Port: 3306},
+				{Name: "db-3", Host: "db-3", Port: 3306},
+			},
+		},
+	}}
+
+	writable := map[string]bool{"db-1": true}
+	probe := func(ctx context.Context, cfg *config.Config) (bool, error) {
+		if writable[cfg.Database.Host] {
+			return true, nil
+		}
+		return false, errors.New("read_only is enabled")
+	}
+
+	var switched []string
+	m := newPrimaryMonitor(cfg, probe, func(c primaryCandidate) {
+		switched = append(switched, c.name)
+	})
+	ctx := context.Background()
+
+	m.check(ctx)
+	if m.failures != 0 || len(switched) != 0 {
+		t.Fatalf("healthy primary: failures=%d switched=%v", m.failures, switched)
+	}
+
+	// The old primary dies before the standby is promoted
+	writable["db-1"] = false
+	m.check(ctx)
+	m.check(ctx)
+	if len(switched) != 0 {
+		t.Fatalf("expected no failover without a writable standby, got %v", switched)
+	}
+
+	// db-3 was promoted
+	writable["db-3"] = true
+	m.check(ctx)
+	if len(switched) != 1 || switched[0] != "db-3" {
+		t.Fatalf("expected failover to db-3, got %v", switched)
+	}
+
+	// No failback once the old primary is writable again
+	writable["db-1"] = true
+	m.check(ctx)
+	if len(switched) != 1 || m.candidates[m.current].name != "db-3" {
+		t.Errorf("expected to stay on db-3, got %v (current %s)", switched, m.candidates[m.current].name)
+	}
+}
+
+func TestServer_StopWithConnectionReadingPrimaryPool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{running: true, router: &replicaRouter{}, sessionCtx: ctx, cancelSessions: cancel}
+
+	// A connection accepted just before Stop looks up the primary pool
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		<-ctx.Done()
+		s.primaryPool()
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop deadlocked waiting for a connection that needs the server lock")
+	}
+}
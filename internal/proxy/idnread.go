@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// rewriteIDNRead rewrites a SELECT on a table read in IDN: the amounts its
+// WHERE clause compares currency columns with are converted to IDR, and its
+// currency columns are read from their shadow columns under their own names.
+// The projected columns are kept for the column definitions of the result.
+func (s *Session) rewriteIDNRead(table, query string) string {
+	tableConfig := s.config.Tables[table]
+	where, err := parser.RewriteWhereAmounts(query, tableConfig, s.config.Conversion)
+	if err != nil {
+		logger.Warn("Failed to convert IDN amounts, forwarding untouched", "table", table, "error", err, "conn_id", s.connID)
+		return query
+	}
+	if len(where.Unconverted) > 0 {
+		logger.Warn("IDN amounts left unconverted", "table", table, "comparisons", where.Unconverted, "conn_id", s.connID)
+	}
+	rewritten := where.Query
+
+	projection, err := parser.RewriteProjection(rewritten, tableConfig)
+	if err != nil {
+		logger.Warn("Failed to read shadow columns, forwarding without them", "table", table, "error", err, "conn_id", s.connID)
+	} else {
+		rewritten = projection.Query
+		s.projection = projection.Columns
+	}
+	if rewritten != query {
+		logger.Debug("Rewrote IDN read", "table", table, "original", query, "new", rewritten)
+	}
+	return rewritten
+}
+
+// renameProjected restores, in a column definition of the result of an IDN
+// read, the original name of a currency column read from its shadow column.
+// MySQL reports the alias as the name already; the original name is the
+// shadow column's, which drivers reading it would otherwise see.
+func renameProjected(payload []byte, projection []parser.ProjectedColumn) []byte {
+	col, err := protocol.DecodeColumnDefinition41(payload)
+	if err != nil {
+		return payload
+	}
+	for _, p := range projection {
+		if col.Name == p.Name && strings.EqualFold(col.OrgName, p.Shadow) {
+			col.OrgName = p.Column
+			return col.Encode()
+		}
+	}
+	return payload
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+)
+
+// resultLimitCode is the error code sent when a resultset exceeds a limit
+// (ER_QUERY_INTERRUPTED)
+const resultLimitCode = 1317
+
+// errResultLimitExceeded ends a session whose resultset was cut short: the
+// rest of the result is still in flight on the backend connection, and
+// draining it would do the work the limit is meant to prevent
+var errResultLimitExceeded = errors.New("result limit exceeded")
+
+// resultCounter tracks the rows and row bytes of a resultset against a limit
+type resultCounter struct {
+	limit config.LimitConfig
+	rows  int64
+	bytes int64
+}
+
+// add counts a row packet and returns the message to send when the resultset
+// exceeded a limit, or "" while it is within its limits
+func (c *resultCounter) add(payload []byte) string {
+	c.rows++
+	c.bytes += int64(len(payload))
+
+	switch {
+	case c.limit.MaxRows > 0 && c.rows > c.limit.MaxRows:
+		metrics.RecordResultLimitExceeded("max_rows")
+		return fmt.Sprintf("TransisiDB: result exceeded max_rows (%d); query aborted", c.limit.MaxRows)
+	case c.limit.MaxResultBytes > 0 && c.bytes > c.limit.MaxResultBytes:
+		metrics.RecordResultLimitExceeded("max_result_bytes")
+		return fmt.Sprintf("TransisiDB: result exceeded max_result_bytes (%d); query aborted", c.limit.MaxResultBytes)
+	}
+	return ""
+}
+
+// withMaxExecutionTime adds a MAX_EXECUTION_TIME optimizer hint after the
+// leading SELECT keyword. Statements that already carry the hint are left
+// alone; ok is false when the query was not changed.
+func withMaxExecutionTime(query string, timeout time.Duration) (string, bool) {
+	start, end := leadingKeywordSpan(query)
+	if !strings.EqualFold(query[start:end], "SELECT") {
+		return query, false
+	}
+	if strings.Contains(strings.ToUpper(query), "MAX_EXECUTION_TIME") {
+		return query, false
+	}
+	hint := fmt.Sprintf(" /*+ MAX_EXECUTION_TIME(%d) */", timeout.Milliseconds())
+	return query[:end] + hint + query[end:], true
+}
@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+func TestWithMaxExecutionTime(t *testing.T) {
+	tests := []struct {
+		query  string
+		want   string
+		wantOK bool
+	}{
+		{"SELECT * FROM orders", "SELECT /*+ MAX_EXECUTION_TIME(30000) */ * FROM orders", true},
+		{"/* app */ select id FROM orders", "/* app */ select /*+ MAX_EXECUTION_TIME(30000) */ id FROM orders", true},
+		{"SELECT /*+ MAX_EXECUTION_TIME(5) */ 1", "SELECT /*+ MAX_EXECUTION_TIME(5) */ 1", false},
+		{"SHOW TABLES", "SHOW TABLES", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := withMaxExecutionTime(tt.query, 30*time.Second)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("withMaxExecutionTime(%q) = %q, %v, want %q, %v", tt.query, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// writeResultset queues a one-column text resultset with the given rows
+func writeResultset(t *testing.T, conn *MockConn, rows ...string) {
+	t.Helper()
+	seq := uint8(1)
+	write := func(payload []byte) {
+		if err := protocol.WritePacket(conn.ReadBuf, seq, payload); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+		seq++
+	}
+	eof := []byte{0xfe, 0x00, 0x00, 0x02, 0x00}
+
+	write([]byte{0x01})
+	write([]byte{0x03, 'd', 'e', 'f'})
+	write(eof)
+	for _, row := range rows {
+		write(append([]byte{byte(len(row))}, row...))
+	}
+	write(eof)
+}
+
+func TestSession_HandleQuery_MaxRows(t *testing.T) {
+	cfg := &config.Config{Proxy: config.ProxyConfig{Limits: []config.LimitConfig{
+		{Table: "orders", MaxRows: 2, MaxExecutionTime: time.Second},
+	}}}
+
+	backend := NewMockConn()
+	client := NewMockConn()
+	session := NewSession(client, cfg, nil)
+	session.parser = parser.NewParser(nil)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	// Within the limit the resultset is relayed in full
+	writeResultset(t, backend, "1", "2")
+	if err := session.handleQuery(newQueryPacket(0, "SELECT id FROM orders")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	sent, err := protocol.ReadPacket(backend.WriteBuf)
+	if err != nil {
+		t.Fatalf("expected query to be forwarded: %v", err)
+	}
+	if got := string(sent.Payload[1:]); got != "SELECT /*+ MAX_EXECUTION_TIME(1000) */ id FROM orders" {
+		t.Errorf("unexpected forwarded query %q", got)
+	}
+	client.WriteBuf.Reset()
+
+	// Other tables are not limited
+	writeResultset(t, backend, "1", "2", "3")
+	if err := session.handleQuery(newQueryPacket(0, "SELECT id FROM invoices")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	client.WriteBuf.Reset()
+
+	// A third row terminates the resultset with an error
+	writeResultset(t, backend, "1", "2", "3")
+	err = session.handleQuery(newQueryPacket(0, "SELECT id FROM orders"))
+	if !errors.Is(err, errResultLimitExceeded) {
+		t.Fatalf("expected result limit error, got %v", err)
+	}
+
+	var last *protocol.Packet
+	rows := 0
+	for client.WriteBuf.Len() > 0 {
+		last, err = protocol.ReadPacket(client.WriteBuf)
+		if err != nil {
+			t.Fatalf("failed to read client packet: %v", err)
+		}
+		if len(last.Payload) == 2 {
+			rows++
+		}
+	}
+	if rows != 2 {
+		t.Errorf("expected 2 rows before the error, got %d", rows)
+	}
+	errPkt, err := protocol.ParseERRPacket(last.Payload)
+	if err != nil {
+		t.Fatalf("expected resultset to end with ERR packet: %v", err)
+	}
+	if errPkt.ErrorCode != resultLimitCode || last.SequenceID != 6 {
+		t.Errorf("unexpected error packet: code %d sequence %d", errPkt.ErrorCode, last.SequenceID)
+	}
+}
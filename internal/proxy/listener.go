@@ -1,24 +1,75 @@
 package proxy
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"net"
+	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/kafitramarna/TransisiDB/internal/canary"
 	"github.com/kafitramarna/TransisiDB/internal/config"
 	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/outbox"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/internal/rules"
+	"github.com/kafitramarna/TransisiDB/internal/schema"
 )
 
 // Server represents the proxy server
 type Server struct {
 	config      *config.Config
-	listener    net.Listener
+	listeners   []*clientListener
+	adminServer *http.Server
 	backendPool *BackendPool
+	router      *replicaRouter
+	rules       *rules.Engine
+	ddl         *schemaWatcher
+	schema      *schema.Cache
+	toggles     *tableToggles
+	breakGlass  *breakGlass
+	versions    *configVersions
+	writes      *writeRates
+	canary      *canary.Replayer
+	outbox      outbox.Queue
+	shadows     shadowReader
+	prober      *backendProber // nil unless proxy.probe is enabled
 	mu          sync.Mutex
+	lifetimes   poolLifetimes      // current pool lifetimes, changed on reload
+	store       *config.RedisStore // saves table toggles, nil without Redis
 	running     bool
 	wg          sync.WaitGroup
 	connSem     chan struct{} // Semaphore for connection limits
+	startedAt   time.Time
+
+	// sessionCtx is cancelled by Stop to end the sessions still running
+	sessionCtx     context.Context
+	cancelSessions context.CancelFunc
+
+	// Active client sessions by connection ID
+	sessionsMu sync.RWMutex
+	sessions   map[uint32]*Session
+	nextConnID atomic.Uint32
+
+	// pending counts accepted connections still in the handshake
+	pending atomic.Int64
+}
+
+// clientListener is an endpoint accepting client connections together with
+// the settings its sessions use
+type clientListener struct {
+	net.Listener
+	name       string
+	endpoint   config.ListenerConfig
+	config     *config.Config
+	simulation bool
+	// limiter bounds the rate connections are accepted at, nil for none
+	limiter *acceptLimiter
 }
 
 // NewServer creates a new proxy server
@@ -30,31 +81,136 @@ func NewServer(cfg *config.Config) *Server {
 		// Continue without pool, will create connections on-demand
 	}
 
+	// Compile query rules; the engine is shared by all sessions so rules
+	// reloaded from Redis apply to open connections too
+	ruleEngine, err := rules.NewEngine(cfg.QueryRules)
+	if err != nil {
+		logger.Error("Failed to compile query rules, continuing without rules", "error", err)
+		ruleEngine, _ = rules.NewEngine(nil)
+	} else if ruleEngine.Len() > 0 {
+		logger.Info("Query rules loaded", "active_rules", ruleEngine.Len())
+	}
+
+	// Create replica pools for sessions routed away from the primary
+	router, err := newReplicaRouter(cfg)
+	if err != nil {
+		logger.Error("Failed to create replica router, routing all sessions to the primary", "error", err)
+		router = &replicaRouter{}
+	}
+
 	// Create connection semaphore for max connections limit
 	connSem := make(chan struct{}, cfg.Proxy.MaxConnectionsPerHost)
 
+	sessionCtx, cancelSessions := context.WithCancel(context.Background())
+
 	return &Server{
 		config:      cfg,
 		backendPool: backendPool,
+		router:      router,
+		lifetimes:   lifetimesFor(cfg.Proxy),
+		rules:       ruleEngine,
+		ddl:         newSchemaWatcher(),
+		toggles:     newTableToggles(),
+		breakGlass:  newBreakGlass(),
+		versions:    newConfigVersions(),
+		writes:      newWriteRates(),
 		connSem:     connSem,
+		startedAt:   time.Now(),
+		sessions:    make(map[uint32]*Session),
+
+		sessionCtx:     sessionCtx,
+		cancelSessions: cancelSessions,
 	}
 }
 
-// Start starts the proxy server
+// Start starts the proxy server on the TCP address and, when configured, the
+// Unix socket. It blocks until the server is stopped.
 func (s *Server) Start() error {
-	addr := fmt.Sprintf("%s:%d", s.config.Proxy.Host, s.config.Proxy.Port)
-	ln, err := net.Listen("tcp", addr)
+	listeners, err := s.listen()
 	if err != nil {
-		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+		return err
 	}
 
 	s.mu.Lock()
-	s.listener = ln
+	s.listeners = listeners
 	s.running = true
 	s.mu.Unlock()
 
-	logger.Info("Proxy server listening", "address", addr)
+	var serving sync.WaitGroup
+	for _, ln := range listeners {
+		serving.Add(1)
+		go func(ln *clientListener) {
+			defer serving.Done()
+			s.serve(ln)
+		}(ln)
+	}
+	serving.Wait()
+	return nil
+}
+
+// listen opens the default client listeners and the named listeners
+func (s *Server) listen() ([]*clientListener, error) {
+	var listeners []*clientListener
+	closeAll := func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}
+
+	endpoints := []config.ListenerConfig{{
+		Name:   "default",
+		Host:   s.config.Proxy.Host,
+		Port:   s.config.Proxy.Port,
+		Socket: s.config.Proxy.Socket,
+	}}
+	endpoints = append(endpoints, s.config.Proxy.Listeners...)
+
+	for _, endpoint := range endpoints {
+		cfg := s.config
+		if endpoint.Name != "default" {
+			cfg = s.config.ForListener(endpoint)
+		}
+
+		if endpoint.Port != 0 {
+			addr := fmt.Sprintf("%s:%d", endpoint.Host, endpoint.Port)
+			ln, err := newListenConfig(s.config.Proxy.TCP).Listen(context.Background(), "tcp", addr)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("listener %s: failed to listen on %s: %w", endpoint.Name, addr, err)
+			}
+			listeners = append(listeners, s.clientListener(ln, endpoint, cfg))
+			logger.Info("Proxy server listening", "listener", endpoint.Name, "address", addr, "simulation", endpoint.Simulation)
+		}
 
+		if endpoint.Socket != "" {
+			ln, err := listenUnix(endpoint.Socket)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("listener %s: failed to listen on socket %s: %w", endpoint.Name, endpoint.Socket, err)
+			}
+			listeners = append(listeners, s.clientListener(ln, endpoint, cfg))
+			logger.Info("Proxy server listening", "listener", endpoint.Name, "socket", endpoint.Socket, "simulation", endpoint.Simulation)
+		}
+	}
+
+	return listeners, nil
+}
+
+// clientListener wraps a listener opened for endpoint
+func (s *Server) clientListener(ln net.Listener, endpoint config.ListenerConfig, cfg *config.Config) *clientListener {
+	return &clientListener{
+		Listener:   ln,
+		name:       endpoint.Name,
+		endpoint:   endpoint,
+		config:     cfg,
+		simulation: endpoint.Simulation,
+		limiter:    newAcceptLimiter(s.config.Proxy.Accept.Rate, s.config.Proxy.Accept.Burst),
+	}
+}
+
+// serve accepts connections on ln until the server is stopped
+func (s *Server) serve(ln *clientListener) {
+	var backoff time.Duration
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
@@ -62,61 +218,290 @@ func (s *Server) Start() error {
 			running := s.running
 			s.mu.Unlock()
 			if !running {
-				return nil
+				return
 			}
-			logger.Error("Accept error", "error", err)
+			metrics.RecordAcceptError(ln.name)
+			backoff = nextAcceptBackoff(backoff)
+			logger.Error("Accept error", "listener", ln.name, "address", ln.Addr().String(), "error", err, "retry_in", backoff)
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+
+		if result := s.admit(ln); result != acceptAccepted {
+			metrics.RecordClientConnection(ln.name, result)
+			logger.Debug("Resetting connection", "listener", ln.name, "remote_addr", conn.RemoteAddr().String(), "reason", result)
+			resetConnection(conn)
 			continue
 		}
+		metrics.RecordClientConnection(ln.name, acceptAccepted)
 
+		s.pending.Add(1)
+		metrics.AddPendingHandshake(1)
 		s.wg.Add(1)
-		go s.handleConnection(conn)
+		go s.handleConnection(conn, ln)
 	}
 }
 
+// admit decides whether a connection accepted on ln is served: it is not
+// when the listener is over its accept rate or too many connections are
+// still in the handshake
+func (s *Server) admit(ln *clientListener) string {
+	if maxPending := s.config.Proxy.Accept.MaxPending; maxPending > 0 && s.pending.Load() >= int64(maxPending) {
+		return acceptBacklogFull
+	}
+	if !ln.limiter.allow(time.Now()) {
+		return acceptRateLimited
+	}
+	return acceptAccepted
+}
+
 // Stop stops the proxy server
 func (s *Server) Stop() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	if !s.running {
+		s.mu.Unlock()
 		return
 	}
-
 	s.running = false
-	if s.listener != nil {
-		s.listener.Close()
+	listeners := s.listeners
+	adminServer := s.adminServer
+	backendPool := s.backendPool
+	// Connections still being served take s.mu, e.g. to read the primary
+	// pool, so it is released before waiting for them
+	s.mu.Unlock()
+
+	for _, ln := range listeners {
+		ln.Close()
+	}
+	if adminServer != nil {
+		adminServer.Close()
 	}
 
+	// Sessions blocked on an idle client or a wedged backend would keep
+	// Stop waiting forever
+	s.cancelSessions()
+
 	// Close backend pool
-	if s.backendPool != nil {
-		s.backendPool.Close()
+	if backendPool != nil {
+		backendPool.Close()
 	}
+	s.router.Close()
 
 	s.wg.Wait()
 	logger.Info("Proxy server stopped gracefully")
 }
 
-func (s *Server) handleConnection(conn net.Conn) {
+func (s *Server) handleConnection(conn net.Conn, ln *clientListener) {
 	defer s.wg.Done()
 	defer conn.Close()
+	metrics.AddSessionGoroutine(1)
+	defer metrics.AddSessionGoroutine(-1)
+	metrics.AddActiveClientConnection(ln.name, 1)
+	defer metrics.AddActiveClientConnection(ln.name, -1)
 
-	// Acquire connection slot (enforce max connections)
-	s.connSem <- struct{}{}
-	defer func() { <-s.connSem }()
+	// serve counted the connection as pending; it stops being so once the
+	// handshake completed or the connection closed
+	var handshakeDone sync.Once
+	authenticated := func() {
+		handshakeDone.Do(func() {
+			s.pending.Add(-1)
+			metrics.AddPendingHandshake(-1)
+		})
+	}
+	defer authenticated()
 
-	// Enable TCP keep-alive
-	if tcpConn, ok := conn.(*net.TCPConn); ok {
-		tcpConn.SetKeepAlive(true)
-		tcpConn.SetKeepAlivePeriod(30 * time.Second)
+	// Acquire connection slot (enforce max connections); clients beyond the
+	// limit get ER_CON_COUNT_ERROR like from a full MySQL server
+	select {
+	case s.connSem <- struct{}{}:
+		defer func() { <-s.connSem }()
+	default:
+		logger.Warn("Rejecting connection, too many connections", "remote_addr", conn.RemoteAddr().String(),
+			"max_connections_per_host", cap(s.connSem))
+		metrics.RecordClientConnection(ln.name, acceptTooMany)
+		if err := tooManyConnections.write(conn, 0); err != nil {
+			logger.Debug("Failed to send too many connections error", "error", err)
+		}
+		return
+	}
+
+	// Apply TCP keep-alive, TCP_NODELAY and buffer sizes
+	if err := applyTCPOptions(conn, s.config.Proxy.TCP); err != nil {
+		logger.Warn("Failed to apply TCP options", "remote_addr", conn.RemoteAddr().String(), "error", err)
 	}
 
 	// Note: We don't set read/write deadlines here because:
 	// 1. Handshake needs variable time depending on auth method
-	// 2. Deadlines are refreshed in handleCommands() for each command
+	// 2. The idle timeout is applied in handleCommands() for each command
 	// 3. Setting them too early causes "i/o timeout" during auth
 
-	session := NewSession(conn, s.config, s.backendPool)
-	if err := session.Handle(); err != nil {
-		logger.Error("Session error", "remote_addr", conn.RemoteAddr().String(), "error", err)
+	pool := s.primaryPool()
+	role := s.router.Route(ln.name, conn.RemoteAddr())
+	if role != "" {
+		if replicaPool := s.router.Pool(role); replicaPool != nil {
+			pool = replicaPool
+		}
+		logger.Debug("Routing session to replica role", "role", role, "remote_addr", conn.RemoteAddr().String())
+	}
+
+	cfg, ruleEngine, version, staged := s.sessionConfig(ln, conn.RemoteAddr())
+	session := NewSession(conn, cfg, pool)
+	session.rules = ruleEngine
+	session.configVersion = version
+	session.configStaged = staged
+	session.ddl = s.ddl
+	session.schema = s.schema
+	session.admin = s
+	session.toggles = s.toggles
+	session.breakGlass = s.breakGlass
+	session.writes = s.writes
+	session.canary = s.canary
+	session.outbox = s.outbox
+	session.shadows = s.shadows
+	session.role = role
+	session.authenticated = authenticated
+	session.listener = ln.name
+	session.simulation = ln.simulation
+	session.connID = s.nextConnID.Add(1)
+
+	s.sessionsMu.Lock()
+	s.sessions[session.connID] = session
+	s.sessionsMu.Unlock()
+	defer func() {
+		s.sessionsMu.Lock()
+		delete(s.sessions, session.connID)
+		s.sessionsMu.Unlock()
+	}()
+
+	if err := session.Handle(s.sessionCtx); err != nil {
+		if s.sessionCtx.Err() != nil {
+			logger.Info("Session closed on shutdown", "remote_addr", conn.RemoteAddr().String(),
+				"user", session.User(), "conn_id", session.connID)
+			return
+		}
+		logger.Error("Session error", "remote_addr", conn.RemoteAddr().String(),
+			"user", session.User(), "conn_id", session.connID, "error", err)
+	}
+}
+
+// Sessions returns a snapshot of the active client sessions ordered by connection ID
+func (s *Server) Sessions() []SessionInfo {
+	s.sessionsMu.RLock()
+	infos := make([]SessionInfo, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		infos = append(infos, session.Info())
+	}
+	s.sessionsMu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ConnID < infos[j].ConnID
+	})
+	return infos
+}
+
+// Uptime returns how long the server has been running
+func (s *Server) Uptime() time.Duration {
+	return time.Since(s.startedAt)
+}
+
+// PoolStats returns the statistics of the primary pool, nil without a pool
+func (s *Server) PoolStats() map[string]interface{} {
+	pool := s.primaryPool()
+	if pool == nil {
+		return nil
+	}
+	return pool.Stats()
+}
+
+// PoolInfo is a snapshot of one backend pool
+type PoolInfo struct {
+	// Role is "primary" or the replica role the pool serves
+	Role    string `json:"role"`
+	Backend string `json:"backend"`
+	// Healthy is false while the pool's circuit breaker is open
+	Healthy bool                   `json:"healthy"`
+	Stats   map[string]interface{} `json:"stats"`
+}
+
+// Pools returns the statistics of the primary pool followed by the replica
+// pools by role
+func (s *Server) Pools() []PoolInfo {
+	var infos []PoolInfo
+	if pool := s.primaryPool(); pool != nil {
+		infos = append(infos, poolInfo("primary", pool))
+	}
+
+	rolePools := s.router.rolePools()
+	roles := make([]string, 0, len(rolePools))
+	for role := range rolePools {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		for _, pool := range rolePools[role] {
+			infos = append(infos, poolInfo(role, pool))
+		}
+	}
+	return infos
+}
+
+// poolInfo returns a snapshot of a pool serving role
+func poolInfo(role string, pool *BackendPool) PoolInfo {
+	return PoolInfo{
+		Role:    role,
+		Backend: pool.backend,
+		Healthy: pool.circuitBreaker.GetState() != StateOpen,
+		Stats:   pool.Stats(),
+	}
+}
+
+// ShadowProposals returns the shadow column configs proposed for currency-looking
+// columns added by DDL, newest first
+func (s *Server) ShadowProposals() []parser.ShadowProposal {
+	if s.ddl == nil {
+		return []parser.ShadowProposal{}
+	}
+	return s.ddl.Proposals()
+}
+
+// SetSchemaCache gives sessions access to live table metadata. Tables changed
+// by DDL the proxy sees are reloaded on their next lookup.
+func (s *Server) SetSchemaCache(cache *schema.Cache) {
+	s.schema = cache
+	s.ddl.Subscribe(func(database string, change parser.SchemaChange) {
+		cache.Invalidate(database, change.Table)
+		if change.NewTable != "" {
+			cache.Invalidate(database, change.NewTable)
+		}
+	})
+}
+
+// SetCanary replays the rewritten statements of new sessions on a canary
+// backend. Call it before Start.
+func (s *Server) SetCanary(replayer *canary.Replayer) {
+	s.canary = replayer
+}
+
+// SetOutbox queues the shadow column updates of tables with write_mode async
+// instead of rewriting their statements. Call it before Start.
+func (s *Server) SetOutbox(queue outbox.Queue) {
+	s.outbox = queue
+}
+
+// SetShadowLookup reads the current shadow values the conversion guard
+// compares single-row UPDATEs with from db, a connection pool of the proxy's
+// own. Without it the guard only checks amounts against its floor. Call it
+// before Start.
+func (s *Server) SetShadowLookup(db *sql.DB) {
+	s.shadows = dbShadowReader{db: db}
+}
+
+// CanaryMismatches returns the most recent canary mismatches, newest first.
+// It is empty when canary mode is disabled.
+func (s *Server) CanaryMismatches() []canary.Mismatch {
+	if s.canary == nil {
+		return []canary.Mismatch{}
 	}
+	return s.canary.Mismatches()
 }
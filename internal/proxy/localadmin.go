@@ -0,0 +1,282 @@
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// adminSource is the proxy state that admin commands are answered from
+type adminSource interface {
+	Sessions() []SessionInfo
+	Uptime() time.Duration
+	PoolStats() map[string]interface{}
+	Pools() []PoolInfo
+	SetTableEnabled(table string, enabled bool, actor string) error
+	SetBreakGlass(state *config.BreakGlass, actor string) error
+}
+
+var (
+	// processlistPattern matches SHOW [FULL] PROCESSLIST
+	processlistPattern = regexp.MustCompile(`^SHOW\s+(FULL\s+)?PROCESSLIST\s*;?$`)
+	// proxyVariablesPattern matches SHOW VARIABLES LIKE 'transisidb...'; other
+	// variables are the backend's and are forwarded
+	proxyVariablesPattern = regexp.MustCompile(`(?i)^SHOW\s+(?:(?:GLOBAL|SESSION|LOCAL)\s+)?VARIABLES\s+LIKE\s+'(transisidb[^']*)'\s*;?$`)
+)
+
+// processlistInfoLength is how much of a statement SHOW PROCESSLIST shows
+// without FULL, as in MySQL
+const processlistInfoLength = 100
+
+// handleLocalAdmin answers the admin statements the proxy virtualizes. It
+// reports false for statements that go to the backend.
+func (s *Session) handleLocalAdmin(cmdPkt *protocol.Packet, query, upperQuery string) (bool, error) {
+	if s.admin == nil {
+		return false, nil
+	}
+
+	if match := processlistPattern.FindStringSubmatch(upperQuery); match != nil {
+		return true, s.writeProcesslist(cmdPkt.SequenceID+1, query, match[1] != "")
+	}
+	if match := proxyVariablesPattern.FindStringSubmatch(strings.TrimSpace(query)); match != nil {
+		return true, s.writeProxyVariables(cmdPkt.SequenceID+1, match[1])
+	}
+	if isVirtualSelect(upperQuery) {
+		return s.handleVirtualSelect(cmdPkt.SequenceID+1, query)
+	}
+	if isVirtualUpdate(upperQuery) {
+		return s.handleVirtualUpdate(cmdPkt.SequenceID+1, query)
+	}
+	return false, nil
+}
+
+// writeProcesslist lists the proxy's client sessions in the layout of SHOW
+// PROCESSLIST. The proxy does not know what other sessions are running, so
+// only the current session reports its statement.
+func (s *Session) writeProcesslist(sequenceID uint8, query string, full bool) error {
+	columns := []*protocol.ColumnDefinition41{
+		localColumn("Id", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("User", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("Host", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("db", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("Command", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("Time", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("State", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("Info", protocol.MYSQL_TYPE_VAR_STRING),
+	}
+
+	var rows []protocol.TextRow
+	for _, info := range s.admin.Sessions() {
+		command, statement := "Sleep", []byte(nil)
+		if info.ConnID == s.connID {
+			command = "Query"
+			if !full && len(query) > processlistInfoLength {
+				query = query[:processlistInfoLength]
+			}
+			statement = []byte(query)
+		}
+		state := info.Listener
+		if info.Role != "" {
+			state += " -> " + info.Role
+		}
+		rows = append(rows, protocol.TextRow{
+			[]byte(strconv.FormatUint(uint64(info.ConnID), 10)),
+			[]byte(info.User),
+			[]byte(info.RemoteAddr),
+			nullIfEmpty(info.Database),
+			[]byte(command),
+			[]byte(strconv.FormatInt(int64(time.Since(info.ConnectedAt).Seconds()), 10)),
+			[]byte(state),
+			statement,
+		})
+	}
+	return s.writeResultset(sequenceID, columns, rows)
+}
+
+// proxyVariables returns the proxy settings SHOW VARIABLES reports
+func (s *Session) proxyVariables() map[string]string {
+	onOff := func(b bool) string {
+		if b {
+			return "ON"
+		}
+		return "OFF"
+	}
+	tables := make([]string, 0, len(s.config.Tables))
+	for name := range s.config.Tables {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+
+	return map[string]string{
+		"transisidb_conn_id":                  strconv.FormatUint(uint64(s.connID), 10),
+		"transisidb_listener":                 s.listener,
+		"transisidb_role":                     s.role,
+		"transisidb_simulation":               onOff(s.simulation),
+		"transisidb_break_glass":              onOff(s.breakGlass.active()),
+		"transisidb_config_version":           strconv.FormatInt(s.configVersion, 10),
+		"transisidb_conversion_ratio":         strconv.Itoa(s.config.Conversion.Ratio),
+		"transisidb_rounding_strategy":        s.config.Conversion.RoundingStrategy,
+		"transisidb_failure_policy":           s.config.Conversion.FailurePolicy,
+		"transisidb_pool_size":                strconv.Itoa(s.config.Proxy.PoolSize),
+		"transisidb_max_connections_per_host": strconv.Itoa(s.config.Proxy.MaxConnectionsPerHost),
+		"transisidb_dual_write_tables":        strings.Join(tables, ","),
+		"transisidb_uptime":                   strconv.FormatInt(int64(s.admin.Uptime().Seconds()), 10),
+	}
+}
+
+// writeProxyVariables answers SHOW VARIABLES LIKE with the proxy settings
+// whose names match pattern
+func (s *Session) writeProxyVariables(sequenceID uint8, pattern string) error {
+	columns := []*protocol.ColumnDefinition41{
+		localColumn("Variable_name", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("Value", protocol.MYSQL_TYPE_VAR_STRING),
+	}
+
+	variables := s.proxyVariables()
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		if likeMatch(pattern, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	rows := make([]protocol.TextRow, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, protocol.TextRow{[]byte(name), []byte(variables[name])})
+	}
+	return s.writeResultset(sequenceID, columns, rows)
+}
+
+// writeStatistics answers COM_STATISTICS with the proxy's counters in the
+// one-line format of the server
+func (s *Session) writeStatistics(sequenceID uint8) error {
+	sessions := s.admin.Sessions()
+	var questions uint64
+	for _, info := range sessions {
+		questions += info.Queries
+	}
+	uptime := s.admin.Uptime().Seconds()
+	perSecond := 0.0
+	if uptime > 0 {
+		perSecond = float64(questions) / uptime
+	}
+
+	stats := fmt.Sprintf("Uptime: %d  Threads: %d  Questions: %d", int64(uptime), len(sessions), questions)
+	if pool := s.admin.PoolStats(); pool != nil {
+		stats += fmt.Sprintf("  Pool idle: %v  Pool active: %v", pool["current_idle"], pool["current_active"])
+	}
+	stats += fmt.Sprintf("  Queries per second avg: %.3f", perSecond)
+
+	return s.writeLocal(sequenceID, []byte(stats))
+}
+
+// logDebugInfo writes the proxy's state to the log when a client sends
+// COM_DEBUG, which makes the server dump its state to the error log
+func (s *Session) logDebugInfo() {
+	if s.admin == nil {
+		return
+	}
+	logger.Info("COM_DEBUG: proxy state", "conn_id", s.connID, "sessions", len(s.admin.Sessions()),
+		"uptime", s.admin.Uptime(), "pool", s.admin.PoolStats())
+}
+
+// writeResultset sends a text resultset built by the proxy. CLIENT_DEPRECATE_EOF
+// is masked from handshakes, so column definitions and rows both end with EOF.
+func (s *Session) writeResultset(sequenceID uint8, columns []*protocol.ColumnDefinition41, rows []protocol.TextRow) error {
+	packets := [][]byte{protocol.EncodeColumnCount(len(columns))}
+	for _, col := range columns {
+		packets = append(packets, col.Encode())
+	}
+	packets = append(packets, protocol.EncodeEOFPacket(0, s.statusFlags()))
+	for _, row := range rows {
+		packets = append(packets, row.Encode())
+	}
+	packets = append(packets, protocol.EncodeEOFPacket(0, s.statusFlags()))
+
+	for _, payload := range packets {
+		if err := s.writeLocal(sequenceID, payload); err != nil {
+			return err
+		}
+		sequenceID++
+	}
+	return nil
+}
+
+// writeLocal writes a response packet the proxy produced itself
+func (s *Session) writeLocal(sequenceID uint8, payload []byte) error {
+	if err := protocol.WritePacket(s.clientConn, sequenceID, payload); err != nil {
+		return fmt.Errorf("failed to write response to client: %w", err)
+	}
+	return nil
+}
+
+// statusFlags returns the server status flags for proxy-built responses
+func (s *Session) statusFlags() uint16 {
+	var flags uint16
+	if s.inTx {
+		flags |= protocol.SERVER_STATUS_IN_TRANS
+	}
+	if s.autocommit {
+		flags |= protocol.SERVER_STATUS_AUTOCOMMIT
+	}
+	return flags
+}
+
+// localColumn describes a column of a proxy-built resultset
+func localColumn(name string, columnType byte) *protocol.ColumnDefinition41 {
+	col := &protocol.ColumnDefinition41{
+		Catalog:      "def",
+		Name:         name,
+		OrgName:      name,
+		CharacterSet: 33, // utf8_general_ci
+		ColumnLength: 256,
+		Type:         columnType,
+	}
+	if columnType == protocol.MYSQL_TYPE_LONGLONG {
+		col.CharacterSet = 63 // binary
+		col.ColumnLength = 20
+		col.Flags = protocol.NUM_FLAG
+	}
+	return col
+}
+
+// nullIfEmpty returns NULL for an empty text value
+func nullIfEmpty(value string) []byte {
+	if value == "" {
+		return nil
+	}
+	return []byte(value)
+}
+
+// likeMatch reports whether s matches a SQL LIKE pattern, case-insensitively.
+// % matches any run of characters, _ one character and \ escapes either.
+func likeMatch(pattern, s string) bool {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr.WriteString(".*")
+		case r == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String()).MatchString(s)
+}
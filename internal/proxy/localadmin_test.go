@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// fakeAdmin is a fixed proxy state for virtualized admin commands
+type fakeAdmin struct {
+	sessions []SessionInfo
+	pools    []PoolInfo
+	toggles  *tableToggles
+	// breakGlass is set by SetBreakGlass, share it with the session
+	breakGlass *breakGlass
+}
+
+func (f *fakeAdmin) Sessions() []SessionInfo { return f.sessions }
+func (f *fakeAdmin) Uptime() time.Duration   { return 100 * time.Second }
+func (f *fakeAdmin) PoolStats() map[string]interface{} {
+	return map[string]interface{}{"current_idle": 3, "current_active": int32(2)}
+}
+func (f *fakeAdmin) Pools() []PoolInfo { return f.pools }
+func (f *fakeAdmin) SetTableEnabled(table string, enabled bool, actor string) error {
+	if f.toggles == nil {
+		f.toggles = newTableToggles()
+	}
+	f.toggles.set(table, enabled)
+	return nil
+}
+
+func (f *fakeAdmin) SetBreakGlass(state *config.BreakGlass, actor string) error {
+	if f.breakGlass == nil {
+		f.breakGlass = newBreakGlass()
+	}
+	f.breakGlass.set(state)
+	return nil
+}
+
+// readTextResultset reads a classic (EOF-terminated) text resultset
+func readTextResultset(t *testing.T, buf *bytes.Buffer) (columns []string, rows []protocol.TextRow) {
+	t.Helper()
+	pkt, err := protocol.ReadPacket(buf)
+	if err != nil {
+		t.Fatalf("failed to read column count: %v", err)
+	}
+	count, err := protocol.DecodeColumnCount(pkt.Payload)
+	if err != nil {
+		t.Fatalf("expected column count: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		pkt, err := protocol.ReadPacket(buf)
+		if err != nil {
+			t.Fatalf("failed to read column: %v", err)
+		}
+		col, err := protocol.DecodeColumnDefinition41(pkt.Payload)
+		if err != nil {
+			t.Fatalf("expected column definition: %v", err)
+		}
+		columns = append(columns, col.Name)
+	}
+	if pkt, err := protocol.ReadPacket(buf); err != nil || !protocol.IsEOFPacket(pkt.Payload) {
+		t.Fatalf("expected EOF after columns")
+	}
+	for {
+		pkt, err := protocol.ReadPacket(buf)
+		if err != nil {
+			t.Fatalf("failed to read row: %v", err)
+		}
+		if protocol.IsEOFPacket(pkt.Payload) {
+			return columns, rows
+		}
+		row, err := protocol.DecodeTextRow(pkt.Payload, count)
+		if err != nil {
+			t.Fatalf("expected text row: %v", err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestSession_HandleQuery_ShowProcesslist(t *testing.T) {
+	conn := NewMockConn()
+	session := NewSession(conn, &config.Config{}, nil)
+	session.connID = 7
+	session.admin = &fakeAdmin{sessions: []SessionInfo{
+		{ConnID: 3, User: "app", RemoteAddr: "10.0.0.5:50000", Database: "shop", Listener: "default", ConnectedAt: time.Now()},
+		{ConnID: 7, User: "dba", RemoteAddr: "10.0.0.9:50001", Listener: "bi", Role: "analytics", ConnectedAt: time.Now()},
+	}}
+
+	if err := session.handleQuery(newQueryPacket(0, "show processlist")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+
+	columns, rows := readTextResultset(t, conn.WriteBuf)
+	if strings.Join(columns, ",") != "Id,User,Host,db,Command,Time,State,Info" {
+		t.Errorf("unexpected columns: %v", columns)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	if rows[0].String(1) != "app" || rows[0].String(4) != "Sleep" || !rows[0].IsNull(7) {
+		t.Errorf("unexpected row for another session: %q", rows[0])
+	}
+	if rows[1].String(4) != "Query" || rows[1].String(7) != "show processlist" || rows[1].String(6) != "bi -> analytics" {
+		t.Errorf("unexpected row for the current session: %q", rows[1])
+	}
+	if !rows[1].IsNull(3) {
+		t.Error("expected NULL db for a session without a database")
+	}
+}
+
+func TestSession_HandleQuery_ShowProxyVariables(t *testing.T) {
+	conn := NewMockConn()
+	session := NewSession(conn, &config.Config{Proxy: config.ProxyConfig{PoolSize: 50}}, nil)
+	session.admin = &fakeAdmin{}
+
+	if err := session.handleQuery(newQueryPacket(0, "SHOW VARIABLES LIKE 'transisidb_pool%'")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+
+	_, rows := readTextResultset(t, conn.WriteBuf)
+	if len(rows) != 1 || rows[0].String(0) != "transisidb_pool_size" || rows[0].String(1) != "50" {
+		t.Errorf("unexpected rows: %q", rows)
+	}
+}
+
+func TestSession_WriteStatistics(t *testing.T) {
+	conn := NewMockConn()
+	session := NewSession(conn, &config.Config{}, nil)
+	session.admin = &fakeAdmin{sessions: []SessionInfo{{ConnID: 1, Queries: 40}, {ConnID: 2, Queries: 10}}}
+
+	if err := session.writeStatistics(1); err != nil {
+		t.Fatalf("writeStatistics returned error: %v", err)
+	}
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	want := "Uptime: 100  Threads: 2  Questions: 50  Pool idle: 3  Pool active: 2  Queries per second avg: 0.500"
+	if pkt.SequenceID != 1 || string(pkt.Payload) != want {
+		t.Errorf("unexpected statistics: %d %q", pkt.SequenceID, pkt.Payload)
+	}
+}
+
+func TestLikeMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"transisidb%", "transisidb_pool_size", true},
+		{"TRANSISIDB_ROLE", "transisidb_role", true},
+		{"transisidb_rol_", "transisidb_role", true},
+		{"transisidb\\_role", "transisidb_role", true},
+		{"transisidb\\_role", "transisidbxrole", false},
+		{"transisidb_pool", "transisidb_pool_size", false},
+		{"transisidb.*", "transisidb_role", false},
+	}
+
+	for _, tt := range tests {
+		if got := likeMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("likeMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
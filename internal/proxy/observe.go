@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// tableMode returns a table's mode, enforce when it is not set
+func tableMode(mode string) string {
+	if mode == "" {
+		return config.TableModeEnforce
+	}
+	return mode
+}
+
+// observing reports whether a table is in observe mode, with a runtime toggle
+// taking precedence over its config
+func (s *Session) observing(table string) bool {
+	mode := s.config.Tables[table].Mode
+	if s.toggles != nil {
+		mode = s.toggles.mode(table, mode)
+	}
+	return mode == config.TableModeObserve
+}
+
+// observeStatement computes the rewrite of a statement on a table in observe
+// mode, logs and counts it and forwards the original statement. Failures are
+// reported the same way and never reach the client, whatever the table's
+// failure policy.
+func (s *Session) observeStatement(cmdPkt *protocol.Packet, timing *queryTiming, pq *parser.ParsedQuery, query string) error {
+	rewriteStart := time.Now()
+	newQuery, stage, err := s.dualWriteQuery(pq)
+	timing.rewrite = time.Since(rewriteStart)
+
+	metrics.RecordObservedStatement(pq.TableName, err == nil)
+	if err != nil {
+		logger.Warn("Observe mode: statement could not be dual-written",
+			"table", pq.TableName, "stage", stage, "error", err, "query", query, "conn_id", s.connID)
+	} else {
+		logger.Info("Observe mode: would rewrite query", "table", pq.TableName, "original", query, "new", newQuery)
+	}
+	return s.forwardTimed(cmdPkt, timing)
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSession_HandleQuery_ObserveModeForwardsUntouched(t *testing.T) {
+	backend := NewMockConn()
+	okPacket := protocol.EncodeOKPacket(1, 0, 0x0002, 0)
+	for i := 0; i < 3; i++ {
+		if err := protocol.WritePacket(backend.ReadBuf, 1, okPacket); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+	}
+
+	// The shadow column overflows for large amounts, which fail_closed would reject
+	tables := config.TablesConfig{
+		"observed_orders": {Enabled: true, Mode: config.TableModeObserve, Columns: map[string]config.ColumnConfig{
+			"total_amount": {TargetColumn: "total_amount_idn", TargetType: "DECIMAL(6,4)", Precision: 4},
+		}},
+	}
+	cfg := &config.Config{Tables: tables, Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, FailurePolicy: config.FailClosed}}
+	client := NewMockConn()
+	session := NewSession(client, cfg, nil)
+	session.parser = parser.NewParser(tables)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	for _, query := range []string{
+		"INSERT INTO observed_orders (total_amount) VALUES (15000)",
+		"UPDATE observed_orders SET total_amount = 250000000 WHERE id = 1",
+	} {
+		backend.WriteBuf.Reset()
+		if err := session.handleQuery(newQueryPacket(0, query)); err != nil {
+			t.Fatalf("handleQuery(%q) returned error: %v", query, err)
+		}
+		if sent := backend.WriteBuf.String(); strings.Contains(sent, "total_amount_idn") || !strings.Contains(sent, query) {
+			t.Errorf("%q reached the backend as %q, want it untouched", query, sent)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.ObservedStatements.WithLabelValues("observed_orders", "would_rewrite")); got != 1 {
+		t.Errorf("would_rewrite = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.ObservedStatements.WithLabelValues("observed_orders", "would_fail")); got != 1 {
+		t.Errorf("would_fail = %v, want 1", got)
+	}
+
+	// Switching the table to enforce at runtime rewrites its statements
+	session.toggles = newTableToggles()
+	session.toggles.replace(map[string]bool{}, map[string]float64{}, map[string]string{"observed_orders": config.TableModeEnforce})
+	backend.WriteBuf.Reset()
+	if err := session.handleQuery(newQueryPacket(0, "INSERT INTO observed_orders (total_amount) VALUES (15000)")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	if !strings.Contains(backend.WriteBuf.String(), "total_amount_idn") {
+		t.Errorf("expected the statement to be rewritten in enforce mode, got %q", backend.WriteBuf.String())
+	}
+
+	// No error packet reached the client
+	for client.WriteBuf.Len() > 0 {
+		pkt, err := protocol.ReadPacket(client.WriteBuf)
+		if err != nil {
+			t.Fatalf("failed to read client packet: %v", err)
+		}
+		if protocol.IsERRPacket(pkt.Payload) {
+			t.Fatalf("unexpected ERR packet: %q", pkt.Payload)
+		}
+	}
+}
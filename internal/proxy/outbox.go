@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/outbox"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// outboxEnqueueTimeout bounds how long a client waits for Redis after its
+// statement was executed
+const outboxEnqueueTimeout = 2 * time.Second
+
+// outboxTask returns the task that fills the shadow columns of a statement on
+// an async table. Statements the outbox cannot key to one row, and statements
+// inside a transaction, which could still be rolled back, are dual-written
+// synchronously instead.
+func (s *Session) outboxTask(pq *parser.ParsedQuery) (outbox.Task, bool) {
+	tableConfig := s.config.Tables[pq.TableName]
+	if s.outbox == nil || !tableConfig.IsAsync() || s.inTx || len(pq.CaseValues) > 0 {
+		return outbox.Task{}, false
+	}
+	key, ok := pq.RowKey(tableConfig.KeyColumn())
+	if !ok {
+		return outbox.Task{}, false
+	}
+
+	values := make(map[string]*string, len(pq.CurrencyColumns))
+	for _, col := range pq.CurrencyColumns {
+		var value *string
+		if v := pq.Values[col]; v != nil {
+			text := fmt.Sprint(v)
+			value = &text
+		}
+		values[col] = value
+	}
+	return outbox.Task{Table: pq.TableName, KeyColumn: tableConfig.KeyColumn(), Key: key, Values: values}, true
+}
+
+// enqueueOutbox queues a task for a statement the backend accepted. A failure
+// cannot undo the statement, so it is logged and the row is left for backfill.
+func (s *Session) enqueueOutbox(task outbox.Task, timing *queryTiming) {
+	if task.Key == "" {
+		// The INSERT left the key to AUTO_INCREMENT
+		if timing.lastInsertID == 0 {
+			metrics.RecordOutboxTask(task.Table, outbox.OutcomeEnqueueFailed)
+			logger.Error("Cannot key outbox task, shadow columns left for backfill",
+				"table", task.Table, "key_column", task.KeyColumn, "conn_id", s.connID)
+			return
+		}
+		task.Key = strconv.FormatUint(timing.lastInsertID, 10)
+	}
+	task.EnqueuedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), outboxEnqueueTimeout)
+	defer cancel()
+	if err := s.outbox.Enqueue(ctx, task); err != nil {
+		metrics.RecordOutboxTask(task.Table, outbox.OutcomeEnqueueFailed)
+		logger.Error("Failed to enqueue outbox task, shadow columns left for backfill",
+			"table", task.Table, "key", task.Key, "error", err, "conn_id", s.connID)
+		return
+	}
+	metrics.RecordOutboxTask(task.Table, outbox.OutcomeEnqueued)
+	logger.Debug("Enqueued outbox task", "table", task.Table, "key", task.Key)
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/outbox"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/internal/queue"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+func TestSession_HandleQuery_AsyncTableUsesOutbox(t *testing.T) {
+	backend := NewMockConn()
+	okPacket := protocol.EncodeOKPacket(1, 57, 0x0002, 0)
+	for i := 0; i < 6; i++ {
+		if err := protocol.WritePacket(backend.ReadBuf, 1, okPacket); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+	}
+
+	tables := config.TablesConfig{
+		"orders": {Enabled: true, WriteMode: config.WriteModeAsync, Columns: map[string]config.ColumnConfig{
+			"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+		}},
+	}
+	cfg := &config.Config{Tables: tables, Conversion: config.ConversionConfig{Ratio: 1000}}
+	session := NewSession(NewMockConn(), cfg, nil)
+	session.parser = parser.NewParser(tables)
+	session.backendConn = NewBackendConn(backend, 1)
+	tasks := outbox.NewQueue(queue.NewMemory(queue.Options{Block: time.Millisecond}))
+	session.outbox = tasks
+
+	steps := []struct {
+		query string
+		// rewritten is set when the statement must reach the backend with
+		// its shadow column
+		rewritten bool
+	}{
+		{"INSERT INTO orders (total_amount) VALUES (1500000)", false},
+		{"UPDATE orders SET total_amount = NULL WHERE id = 9", false},
+		{"UPDATE orders SET total_amount = 5000 WHERE status = 'open'", true},
+		{"BEGIN", false},
+		{"UPDATE orders SET total_amount = 7000 WHERE id = 2", true},
+		{"COMMIT", false},
+	}
+	for _, step := range steps {
+		backend.WriteBuf.Reset()
+		if err := session.handleQuery(newQueryPacket(0, step.query)); err != nil {
+			t.Fatalf("handleQuery(%q) returned error: %v", step.query, err)
+		}
+		sent := backend.WriteBuf.String()
+		if strings.Contains(sent, "total_amount_idn") != step.rewritten {
+			t.Errorf("%q reached the backend as %q, want rewritten=%v", step.query, sent, step.rewritten)
+		}
+	}
+
+	deliveries, err := tasks.Read(context.Background(), "test", 10)
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("expected 2 outbox tasks, got %+v (%v)", deliveries, err)
+	}
+	insert := deliveries[0].Task
+	if insert.Table != "orders" || insert.KeyColumn != "id" || insert.Key != "57" || *insert.Values["total_amount"] != "1500000" {
+		t.Errorf("unexpected INSERT task %+v", insert)
+	}
+	if update := deliveries[1].Task; update.Key != "9" || update.Values["total_amount"] != nil {
+		t.Errorf("unexpected UPDATE task %+v", update)
+	}
+}
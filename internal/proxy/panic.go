@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// recoverPanic turns a panic while serving the session into an error, so only
+// this client's connection is closed. Deferred by Handle; the connections are
+// closed by Handle's other deferred calls.
+func (s *Session) recoverPanic(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	metrics.RecordSessionPanic()
+	logger.Error("Session panicked, closing connection", "panic", fmt.Sprint(r), "stack", string(stack),
+		"user", s.User(), "conn_id", s.connID)
+
+	if dir := s.config.Proxy.QuarantineDir; dir != "" && s.lastPacket != nil {
+		if path, qerr := s.quarantinePacket(dir, r, stack); qerr != nil {
+			logger.Warn("Failed to quarantine packet", "error", qerr, "conn_id", s.connID)
+		} else {
+			logger.Info("Quarantined packet that caused the panic", "path", path, "conn_id", s.connID)
+		}
+	}
+
+	*err = fmt.Errorf("session panicked: %v", r)
+}
+
+// quarantinePacket writes the last client packet to a file in dir, in the
+// capture format read by pkg/protocol's fixture tests, with the panic and its
+// stack as comments
+func (s *Session) quarantinePacket(dir string, r interface{}, stack []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Session %d (user %q) panicked at %s\n", s.connID, s.User(), time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# panic: %v\n", r)
+	for _, line := range strings.Split(strings.TrimRight(string(stack), "\n"), "\n") {
+		b.WriteString("# " + line + "\n")
+	}
+	b.WriteString("C " + hex.EncodeToString(encodePacket(s.lastPacket)) + "\n")
+
+	name := fmt.Sprintf("panic-%s-conn%d.capture", time.Now().UTC().Format("20060102T150405.000000000"), s.connID)
+	path := filepath.Join(dir, name)
+	// Packets carry statement text and auth data
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// encodePacket returns the packet with its 4-byte header
+func encodePacket(pkt *protocol.Packet) []byte {
+	length := len(pkt.Payload)
+	return append([]byte{byte(length), byte(length >> 8), byte(length >> 16), pkt.SequenceID}, pkt.Payload...)
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+func TestSession_RecoverPanic(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "quarantine")
+	cfg := &config.Config{Proxy: config.ProxyConfig{QuarantineDir: dir}}
+	session := NewSession(NewMockConn(), cfg, nil)
+	session.connID = 42
+	session.lastPacket = newQueryPacket(0, "SELECT 1")
+
+	err := func() (err error) {
+		defer session.recoverPanic(&err)
+		panic("malformed packet")
+	}()
+	if err == nil || !strings.Contains(err.Error(), "malformed packet") {
+		t.Fatalf("expected the panic as an error, got %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read quarantine dir: %v", err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "-conn42.capture") {
+		t.Fatalf("expected one quarantine file for conn 42, got %v", files)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatalf("failed to read quarantine file: %v", err)
+	}
+	if !strings.Contains(string(data), "# panic: malformed packet\n") {
+		t.Errorf("expected the panic value in the quarantine file:\n%s", data)
+	}
+	// COM_QUERY "SELECT 1" with its header, as in the capture fixtures
+	if !strings.HasSuffix(string(data), "\nC 090000000353454c4543542031\n") {
+		t.Errorf("expected the packet as the last line:\n%s", data)
+	}
+}
+
+func TestSession_RecoverPanicWithoutQuarantine(t *testing.T) {
+	session := NewSession(NewMockConn(), &config.Config{}, nil)
+	session.lastPacket = newQueryPacket(0, "SELECT 1")
+
+	err := func() (err error) {
+		defer session.recoverPanic(&err)
+		var columns []string
+		_ = columns[3]
+		return nil
+	}()
+	if err == nil || !strings.Contains(err.Error(), "index out of range") {
+		t.Fatalf("expected the runtime error, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+)
+
+// Backend probe statuses served by /readyz
+const (
+	// ProbeReady means the last probe answered within the slow threshold
+	ProbeReady = "ready"
+	// ProbeDegraded means the backend answers, but slower than the threshold
+	ProbeDegraded = "degraded"
+	// ProbeUnavailable means the last probe failed or timed out
+	ProbeUnavailable = "unavailable"
+)
+
+// ProbeStatus is the outcome of the last backend probe
+type ProbeStatus struct {
+	Status    string    `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// queryProbe runs the probe query through the proxy
+type queryProbe func(ctx context.Context) error
+
+// backendProber runs a cheap query through the full proxy path every
+// interval and keeps the outcome, catching a backend that still accepts
+// connections but answers slowly, which the circuit breaker does not see
+type backendProber struct {
+	interval time.Duration
+	timeout  time.Duration
+	slow     time.Duration
+	probe    queryProbe
+
+	mu     sync.RWMutex
+	status ProbeStatus
+}
+
+// newBackendProber creates a prober; it reports ready until the first probe
+func newBackendProber(cfg config.ProbeConfig, probe queryProbe) *backendProber {
+	p := &backendProber{
+		interval: cfg.Interval,
+		timeout:  cfg.Timeout,
+		slow:     cfg.SlowThreshold,
+		probe:    probe,
+		status:   ProbeStatus{Status: ProbeReady},
+	}
+	if p.timeout <= 0 {
+		p.timeout = config.DefaultProbeTimeout
+	}
+	if p.slow <= 0 {
+		p.slow = config.DefaultProbeSlowThreshold
+	}
+	return p
+}
+
+// run probes until ctx is cancelled
+func (p *backendProber) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.check(ctx)
+		}
+	}
+}
+
+// check runs the probe within the timeout and records its latency
+func (p *backendProber) check(ctx context.Context) {
+	start := time.Now()
+	probeCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	err := p.probe(probeCtx)
+	cancel()
+	latency := time.Since(start)
+
+	status := ProbeStatus{
+		Status:    ProbeReady,
+		LatencyMs: float64(latency) / float64(time.Millisecond),
+		CheckedAt: start,
+	}
+	switch {
+	case err != nil:
+		status.Status = ProbeUnavailable
+		status.Error = err.Error()
+		metrics.RecordProbe(p.timeout, true)
+	case latency > p.slow:
+		status.Status = ProbeDegraded
+		metrics.RecordProbe(latency, false)
+	default:
+		metrics.RecordProbe(latency, false)
+	}
+
+	p.mu.Lock()
+	previous := p.status.Status
+	p.status = status
+	p.mu.Unlock()
+
+	if status.Status == previous {
+		return
+	}
+	if status.Status == ProbeReady {
+		logger.Info("Backend probe recovered", "latency", latency)
+	} else {
+		logger.Warn("Backend probe "+status.Status, "latency", latency,
+			"slow_threshold", p.slow, "error", err)
+	}
+}
+
+// Status returns the outcome of the last probe
+func (p *backendProber) Status() ProbeStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+// probeDSN returns a DSN connecting to the proxy's own listener with the
+// database credentials, so the probe takes the path clients take
+func probeDSN(cfg *config.Config) string {
+	probe := *cfg
+	probe.Database.Type = "mysql"
+	probe.Database.Host = cfg.Proxy.Host
+	probe.Database.Port = cfg.Proxy.Port
+	probe.Database.Socket = ""
+	switch {
+	case cfg.Proxy.Port == 0:
+		probe.Database.Socket = cfg.Proxy.Socket
+	case cfg.Proxy.Host == "" || cfg.Proxy.Host == "0.0.0.0" || cfg.Proxy.Host == "::":
+		probe.Database.Host = "127.0.0.1"
+	}
+	return probe.GetDatabaseDSN()
+}
+
+// StartProbe runs SELECT 1 through the proxy every proxy.probe.interval until
+// ctx is cancelled. Every probe opens a new client connection, so a
+// saturated backend pool shows up as latency too.
+func (s *Server) StartProbe(ctx context.Context) {
+	probeConfig := s.config.Proxy.Probe
+	if !probeConfig.Enabled() {
+		return
+	}
+	db, err := sql.Open("mysql", probeDSN(s.config))
+	if err != nil {
+		logger.Warn("Backend probe disabled", "error", err)
+		return
+	}
+	db.SetMaxIdleConns(0)
+
+	prober := newBackendProber(probeConfig, func(ctx context.Context) error {
+		var one int
+		return db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+	})
+	s.mu.Lock()
+	s.prober = prober
+	s.mu.Unlock()
+
+	go func() {
+		defer db.Close()
+		prober.run(ctx)
+	}()
+	logger.Info("Backend probe enabled", "interval", prober.interval, "slow_threshold", prober.slow)
+}
+
+// Readiness returns the outcome of the last backend probe; the proxy is
+// reported ready when the probe is disabled
+func (s *Server) Readiness() ProbeStatus {
+	s.mu.Lock()
+	prober := s.prober
+	s.mu.Unlock()
+	if prober == nil {
+		return ProbeStatus{Status: ProbeReady}
+	}
+	return prober.Status()
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBackendProber_Check(t *testing.T) {
+	var delay time.Duration
+	var probeErr error
+	prober := newBackendProber(config.ProbeConfig{
+		Interval: time.Second, Timeout: time.Second, SlowThreshold: 20 * time.Millisecond,
+	}, func(ctx context.Context) error {
+		time.Sleep(delay)
+		return probeErr
+	})
+	if got := prober.Status().Status; got != ProbeReady {
+		t.Fatalf("expected ready before the first probe, got %s", got)
+	}
+
+	prober.check(context.Background())
+	if status := prober.Status(); status.Status != ProbeReady || status.CheckedAt.IsZero() {
+		t.Errorf("expected a fast probe to be ready, got %+v", status)
+	}
+
+	delay = 40 * time.Millisecond
+	prober.check(context.Background())
+	status := prober.Status()
+	if status.Status != ProbeDegraded || status.LatencyMs < 40 {
+		t.Errorf("expected a slow probe to be degraded, got %+v", status)
+	}
+	if got := testutil.ToFloat64(metrics.ProbeLatency); got < 0.04 {
+		t.Errorf("expected the latency gauge to hold the slow probe, got %v", got)
+	}
+
+	failures := testutil.ToFloat64(metrics.ProbeFailures)
+	delay, probeErr = 0, errors.New("connection refused")
+	prober.check(context.Background())
+	status = prober.Status()
+	if status.Status != ProbeUnavailable || status.Error != "connection refused" {
+		t.Errorf("expected a failed probe to be unavailable, got %+v", status)
+	}
+	if got := testutil.ToFloat64(metrics.ProbeFailures); got != failures+1 {
+		t.Errorf("expected one more probe failure, got %v", got-failures)
+	}
+	if got := testutil.ToFloat64(metrics.ProbeLatency); got != 1 {
+		t.Errorf("expected a failed probe to count as the whole timeout, got %v", got)
+	}
+}
+
+func TestBackendProber_Timeout(t *testing.T) {
+	prober := newBackendProber(config.ProbeConfig{
+		Interval: time.Second, Timeout: 20 * time.Millisecond, SlowThreshold: 10 * time.Millisecond,
+	}, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	prober.check(context.Background())
+	if status := prober.Status(); status.Status != ProbeUnavailable {
+		t.Errorf("expected a probe past its timeout to be unavailable, got %+v", status)
+	}
+}
+
+func TestProbeDSN(t *testing.T) {
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Type: "mysql", Host: "db-1", Port: 3306, User: "app", Password: "secret", Database: "shop"},
+		Proxy:    config.ProxyConfig{Host: "0.0.0.0", Port: 3308},
+	}
+	if dsn := probeDSN(cfg); !strings.HasPrefix(dsn, "app:secret@tcp(127.0.0.1:3308)/shop?") {
+		t.Errorf("expected the probe to connect to the local proxy listener, got %s", dsn)
+	}
+
+	cfg.Proxy = config.ProxyConfig{Socket: "/var/run/transisidb.sock"}
+	if dsn := probeDSN(cfg); !strings.HasPrefix(dsn, "app:secret@unix(/var/run/transisidb.sock)/shop?") {
+		t.Errorf("expected the probe to use the proxy socket, got %s", dsn)
+	}
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+// WatchQueryRules loads the query rules saved through the API and reloads them
+// whenever a config reload is published. Rules from config.yaml stay active
+// until a rule set has been saved to Redis. Pool lifetimes are reloaded from
+// the published config too, and table toggles from Redis.
+func (s *Server) WatchQueryRules(ctx context.Context, store *config.RedisStore) error {
+	if err := s.reloadQueryRules(ctx, store); err != nil {
+		return err
+	}
+
+	reloadCh, err := store.WatchConfigChanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for newCfg := range reloadCh {
+			if err := s.reloadQueryRules(ctx, store); err != nil {
+				logger.Error("Failed to reload query rules, keeping current rules", "error", err)
+			}
+			s.reloadPoolLifetimes(newCfg.Proxy)
+			if err := s.reloadTableToggles(ctx, store); err != nil {
+				logger.Warn("Failed to reload table toggles, keeping current toggles", "error", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// reloadQueryRules replaces the active rules with the ones stored in Redis
+func (s *Server) reloadQueryRules(ctx context.Context, store *config.RedisStore) error {
+	exists, err := store.HasQueryRules(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	queryRules, err := store.LoadQueryRules(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.rules.Replace(queryRules); err != nil {
+		return fmt.Errorf("invalid query rules in Redis: %w", err)
+	}
+
+	logger.Info("Query rules reloaded", "active_rules", s.rules.Len())
+	return nil
+}
+
+// reloadPoolLifetimes applies the connection lifetimes of a reloaded config to
+// the primary and replica pools and to pools created later
+func (s *Server) reloadPoolLifetimes(proxyCfg config.ProxyConfig) {
+	lifetimes := lifetimesFor(proxyCfg)
+
+	s.mu.Lock()
+	changed := lifetimes != s.lifetimes
+	s.lifetimes = lifetimes
+	pool := s.backendPool
+	s.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	pools := s.router.pools()
+	if pool != nil {
+		pools = append(pools, pool)
+	}
+	for _, p := range pools {
+		p.SetLifetimes(lifetimes)
+	}
+	logger.Info("Pool lifetimes reloaded", "max_conn_idle_time", lifetimes.maxIdleTime,
+		"max_conn_lifetime", lifetimes.maxLifetime, "cleanup_interval", lifetimes.cleanupInterval)
+}
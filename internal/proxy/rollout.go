@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// inRollout reports whether a statement on a configured table is dual-written.
+// Tables being rolled out rewrite the statements whose connection or row key
+// hashes below the rollout percentage; the hash is stable, so a session or row
+// stays in the rollout as the percentage grows. Statements hashed by primary
+// key that do not pin the key to a literal stay out until the table is fully
+// rolled out.
+func (s *Session) inRollout(pq *parser.ParsedQuery) bool {
+	rollout := s.config.Tables[pq.TableName].Rollout
+	if s.toggles != nil {
+		rollout = s.toggles.rollout(pq.TableName, rollout)
+	}
+	percent := rolloutPercent(rollout)
+	if percent >= 100 {
+		return true
+	}
+
+	key := strconv.FormatUint(uint64(s.connID), 10)
+	if rollout.HashBy == config.RolloutHashPrimaryKey {
+		column := rollout.PrimaryKey
+		if column == "" {
+			column = config.DefaultRolloutPrimaryKey
+		}
+		value, ok := pq.KeyValue(column)
+		if !ok {
+			metrics.RecordRolloutDecision(pq.TableName, false)
+			return false
+		}
+		key = value
+	}
+
+	in := rolloutBucket(pq.TableName, key) < percent
+	metrics.RecordRolloutDecision(pq.TableName, in)
+	return in
+}
+
+// rolloutPercent returns the share of statements a rollout rewrites, 100
+// without a rollout
+func rolloutPercent(rollout *config.RolloutConfig) float64 {
+	if rollout == nil {
+		return 100
+	}
+	return rollout.Percent
+}
+
+// rolloutBucket maps a table and key to [0, 100) in steps of 0.01
+func rolloutBucket(table, key string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(table))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return float64(h.Sum32()%10000) / 100
+}
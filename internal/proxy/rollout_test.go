@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+func newRolloutSession(rollout *config.RolloutConfig) *Session {
+	tables := config.TablesConfig{
+		"orders": {Enabled: true, Rollout: rollout, Columns: map[string]config.ColumnConfig{
+			"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+		}},
+	}
+	session := NewSession(NewMockConn(), &config.Config{Tables: tables}, nil)
+	session.parser = parser.NewParser(tables)
+	return session
+}
+
+func TestSession_InRollout(t *testing.T) {
+	parse := func(s *Session, query string) *parser.ParsedQuery {
+		pq, err := s.parser.Parse(query)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", query, err)
+		}
+		return pq
+	}
+	insert := "INSERT INTO orders (id, total_amount) VALUES (42, 1000)"
+
+	if !newRolloutSession(nil).inRollout(parse(newRolloutSession(nil), insert)) {
+		t.Error("expected every statement to be rewritten without a rollout")
+	}
+
+	session := newRolloutSession(&config.RolloutConfig{Percent: 0})
+	if session.inRollout(parse(session, insert)) {
+		t.Error("expected no statement to be rewritten at 0%")
+	}
+
+	// Hashing by connection keeps a session in or out
+	session = newRolloutSession(&config.RolloutConfig{Percent: 50})
+	rewritten := 0
+	for id := uint32(1); id <= 1000; id++ {
+		session.connID = id
+		in := session.inRollout(parse(session, insert))
+		if in != session.inRollout(parse(session, "UPDATE orders SET total_amount = 5 WHERE id = 7")) {
+			t.Fatalf("connection %d is in and out of the rollout", id)
+		}
+		if in {
+			rewritten++
+		}
+	}
+	if rewritten < 400 || rewritten > 600 {
+		t.Errorf("rewrote %d of 1000 connections at 50%%", rewritten)
+	}
+
+	// Hashing by primary key keeps a row in or out
+	session = newRolloutSession(&config.RolloutConfig{Percent: 50, HashBy: config.RolloutHashPrimaryKey})
+	for id := uint32(1); id <= 20; id++ {
+		session.connID = id
+		if session.inRollout(parse(session, insert)) != session.inRollout(parse(session, "UPDATE orders SET total_amount = 5 WHERE id = 42")) {
+			t.Fatal("expected the INSERT and UPDATE of a row to share its rollout")
+		}
+	}
+	if session.inRollout(parse(session, "UPDATE orders SET total_amount = 5 WHERE status = 'open'")) {
+		t.Error("expected statements without a key to stay out of a partial rollout")
+	}
+
+	// Toggled percentages override the config
+	session.toggles = newTableToggles()
+	session.toggles.replace(map[string]bool{}, map[string]float64{"orders": 100}, map[string]string{})
+	if !session.inRollout(parse(session, "UPDATE orders SET total_amount = 5 WHERE status = 'open'")) {
+		t.Error("expected every statement to be rewritten once rolled out to 100%")
+	}
+}
+
+func TestRolloutBucket(t *testing.T) {
+	if rolloutBucket("orders", "42") != rolloutBucket("orders", "42") {
+		t.Error("expected the bucket to be stable")
+	}
+	for _, key := range []string{"1", "42", "A-7", ""} {
+		if b := rolloutBucket("orders", key); b < 0 || b >= 100 {
+			t.Errorf("bucket %v of %q out of range", b, key)
+		}
+	}
+}
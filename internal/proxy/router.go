@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/discovery"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+// replicaRouter picks the backend pool for a new client session. Sessions
+// that match no route use the primary.
+type replicaRouter struct {
+	routes []replicaRoute
+	groups map[string]*replicaGroup
+}
+
+// replicaRoute is a compiled config.RouteConfig
+type replicaRoute struct {
+	role     string
+	listener string
+	networks []*net.IPNet
+}
+
+// replicaGroup balances sessions across the replicas of one role
+type replicaGroup struct {
+	mu    sync.RWMutex
+	pools []*BackendPool
+	// static are the pools of replicas configured by address
+	static []*BackendPool
+	// discovered are the pools of discovered replicas by entry and address
+	discovered map[string]*BackendPool
+	next       atomic.Uint32
+}
+
+// newReplicaRouter creates a pool per replica and compiles the routes
+func newReplicaRouter(cfg *config.Config) (*replicaRouter, error) {
+	router := &replicaRouter{groups: make(map[string]*replicaGroup)}
+
+	for _, rc := range cfg.Proxy.Routes {
+		route := replicaRoute{role: rc.Role, listener: rc.Listener}
+		for _, cidr := range rc.SourceCIDRs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid source CIDR %s: %w", cidr, err)
+			}
+			route.networks = append(route.networks, network)
+		}
+		router.routes = append(router.routes, route)
+	}
+
+	for _, replica := range cfg.Database.Replicas {
+		group, exists := router.groups[replica.Role]
+		if !exists {
+			group = &replicaGroup{discovered: make(map[string]*BackendPool)}
+			router.groups[replica.Role] = group
+		}
+		// Discovered replicas join the group once resolved
+		if replica.Discovery.Enabled() {
+			continue
+		}
+
+		pool, err := NewBackendPool(cfg.ForReplica(replica), cfg.Proxy.PoolSize)
+		if err != nil {
+			router.Close()
+			return nil, fmt.Errorf("replica %s: %w", replica.Name, err)
+		}
+		group.static = append(group.static, pool)
+		group.pools = append(group.pools, pool)
+		logger.Info("Replica backend configured", "replica", replica.Name, "role", replica.Role)
+	}
+
+	return router, nil
+}
+
+// Route returns the role of the first route matching a session accepted on
+// listener from remote, or "" when the session should use the primary
+func (r *replicaRouter) Route(listener string, remote net.Addr) string {
+	for _, route := range r.routes {
+		if route.matches(listener, remote) {
+			return route.role
+		}
+	}
+	return ""
+}
+
+// Pool returns the next replica pool of role in round-robin order
+func (r *replicaRouter) Pool(role string) *BackendPool {
+	group, exists := r.groups[role]
+	if !exists {
+		return nil
+	}
+	group.mu.RLock()
+	defer group.mu.RUnlock()
+	if len(group.pools) == 0 {
+		return nil
+	}
+	i := group.next.Add(1) - 1
+	return group.pools[i%uint32(len(group.pools))]
+}
+
+// setDiscovered replaces the discovered replicas of a replica entry with a
+// pool per endpoint. Pools of endpoints that are still listed are kept;
+// pools of endpoints that disappeared are closed.
+func (r *replicaRouter) setDiscovered(replica config.ReplicaConfig, endpoints []discovery.Endpoint,
+	newPool func(discovery.Endpoint) (*BackendPool, error)) {
+
+	group, exists := r.groups[replica.Role]
+	if !exists {
+		return
+	}
+	prefix := replica.Name + "/"
+
+	group.mu.Lock()
+	listed := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		key := prefix + endpoint.String()
+		listed[key] = true
+		if _, exists := group.discovered[key]; exists {
+			continue
+		}
+		pool, err := newPool(endpoint)
+		if err != nil {
+			logger.Error("Failed to create pool for discovered replica", "replica", replica.Name,
+				"endpoint", endpoint.String(), "error", err)
+			continue
+		}
+		group.discovered[key] = pool
+		logger.Info("Discovered replica backend", "replica", replica.Name, "role", replica.Role, "endpoint", endpoint.String())
+	}
+
+	var removed []*BackendPool
+	for key, pool := range group.discovered {
+		if strings.HasPrefix(key, prefix) && !listed[key] {
+			removed = append(removed, pool)
+			delete(group.discovered, key)
+			logger.Info("Discovered replica backend removed", "replica", replica.Name, "role", replica.Role,
+				"endpoint", strings.TrimPrefix(key, prefix))
+		}
+	}
+
+	keys := make([]string, 0, len(group.discovered))
+	for key := range group.discovered {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	group.pools = append([]*BackendPool{}, group.static...)
+	for _, key := range keys {
+		group.pools = append(group.pools, group.discovered[key])
+	}
+	group.mu.Unlock()
+
+	for _, pool := range removed {
+		pool.Close()
+	}
+}
+
+// pools returns every replica pool
+func (r *replicaRouter) pools() []*BackendPool {
+	var pools []*BackendPool
+	for _, group := range r.groups {
+		group.mu.RLock()
+		pools = append(pools, group.pools...)
+		group.mu.RUnlock()
+	}
+	return pools
+}
+
+// rolePools returns the pools of each replica role
+func (r *replicaRouter) rolePools() map[string][]*BackendPool {
+	pools := make(map[string][]*BackendPool, len(r.groups))
+	for role, group := range r.groups {
+		group.mu.RLock()
+		pools[role] = append([]*BackendPool(nil), group.pools...)
+		group.mu.RUnlock()
+	}
+	return pools
+}
+
+// Close closes every replica pool
+func (r *replicaRouter) Close() {
+	for _, group := range r.groups {
+		group.mu.RLock()
+		pools := group.pools
+		group.mu.RUnlock()
+		for _, pool := range pools {
+			pool.Close()
+		}
+	}
+}
+
+// matches reports whether every condition of the route holds
+func (route replicaRoute) matches(listener string, remote net.Addr) bool {
+	if route.listener != "" && route.listener != listener {
+		return false
+	}
+	if len(route.networks) == 0 {
+		return true
+	}
+
+	tcpAddr, ok := remote.(*net.TCPAddr)
+	if !ok {
+		// Unix socket clients have no source address
+		return false
+	}
+	for _, network := range route.networks {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/discovery"
+)
+
+func TestReplicaRouter_Route(t *testing.T) {
+	router, err := newReplicaRouter(&config.Config{
+		Proxy: config.ProxyConfig{Routes: []config.RouteConfig{
+			{Role: "analytics", Listener: "bi"},
+			{Role: "analytics", SourceCIDRs: []string{"10.20.0.0/16"}},
+			{Role: "oltp", Listener: "default", SourceCIDRs: []string{"192.168.1.0/24", "fd00::/8"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("newReplicaRouter failed: %v", err)
+	}
+
+	tcp := func(ip string) net.Addr { return &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000} }
+	tests := []struct {
+		name     string
+		listener string
+		remote   net.Addr
+		want     string
+	}{
+		{"listener route", "bi", tcp("127.0.0.1"), "analytics"},
+		{"source network", "default", tcp("10.20.3.4"), "analytics"},
+		{"listener and network", "default", tcp("192.168.1.7"), "oltp"},
+		{"ipv6 network", "default", tcp("fd00::1"), "oltp"},
+		{"network on other listener", "simulation", tcp("192.168.1.7"), ""},
+		{"no match", "default", tcp("127.0.0.1"), ""},
+		{"unix socket client", "default", &net.UnixAddr{Name: "@", Net: "unix"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := router.Route(tt.listener, tt.remote); got != tt.want {
+				t.Errorf("Route(%q, %v) = %q, want %q", tt.listener, tt.remote, got, tt.want)
+			}
+		})
+	}
+
+	if pool := router.Pool("analytics"); pool != nil {
+		t.Error("expected no pool for a role without replicas")
+	}
+}
+
+func TestReplicaRouter_SetDiscovered(t *testing.T) {
+	replica := config.ReplicaConfig{
+		Name:      "analytics",
+		Role:      "analytics",
+		Discovery: config.DiscoveryConfig{Type: config.DiscoveryDNSSRV, Name: "_mysql._tcp.analytics"},
+	}
+	cfg := &config.Config{Database: config.DatabaseConfig{Replicas: []config.ReplicaConfig{replica}}}
+	router, err := newReplicaRouter(cfg)
+	if err != nil {
+		t.Fatalf("newReplicaRouter failed: %v", err)
+	}
+	if pool := router.Pool("analytics"); pool != nil {
+		t.Fatal("expected no pool before discovery")
+	}
+
+	created := map[string]*BackendPool{}
+	newPool := func(endpoint discovery.Endpoint) (*BackendPool, error) {
+		r := replica
+		r.Host, r.Port = endpoint.Host, endpoint.Port
+		pool := &BackendPool{config: cfg.ForReplica(r), closed: true}
+		created[endpoint.String()] = pool
+		return pool, nil
+	}
+
+	router.setDiscovered(replica, []discovery.Endpoint{{Host: "db-1", Port: 3306}, {Host: "db-2", Port: 3306}}, newPool)
+	if len(created) != 2 {
+		t.Fatalf("created %d pools, want 2", len(created))
+	}
+	seen := map[*BackendPool]bool{}
+	for i := 0; i < 4; i++ {
+		seen[router.Pool("analytics")] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("sessions balanced across %d pools, want 2", len(seen))
+	}
+
+	kept := created["db-2:3306"]
+	router.setDiscovered(replica, []discovery.Endpoint{{Host: "db-2", Port: 3306}}, newPool)
+	if len(created) != 2 {
+		t.Errorf("existing endpoint got a new pool")
+	}
+	for i := 0; i < 3; i++ {
+		if pool := router.Pool("analytics"); pool != kept {
+			t.Fatalf("Pool() = %v, want the pool of the remaining endpoint", pool)
+		}
+	}
+
+	router.setDiscovered(replica, nil, newPool)
+	if pool := router.Pool("analytics"); pool != nil {
+		t.Error("expected no pool after every endpoint disappeared")
+	}
+}
@@ -1,16 +1,27 @@
 package proxy
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/kafitramarna/TransisiDB/internal/canary"
 	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/converter"
 	"github.com/kafitramarna/TransisiDB/internal/dualwrite"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
 	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/outbox"
 	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/internal/rules"
+	"github.com/kafitramarna/TransisiDB/internal/schema"
 	"github.com/kafitramarna/TransisiDB/pkg/protocol"
 )
 
@@ -22,28 +33,177 @@ type Session struct {
 	backendPool  *BackendPool
 	orchestrator *dualwrite.Orchestrator
 	parser       *parser.Parser
+	rules        *rules.Engine
 	connID       uint32
-	database     string
-	inTx         bool
+	connectedAt  time.Time
+	queries      atomic.Uint64
+	// listener is the name of the endpoint the client connected to
+	listener string
+	// simulation rejects statements that modify data
+	simulation bool
+	// role is the replica role the session was routed to ("" for the primary)
+	role string
+	// authenticated is called once the handshake completed, nil for none
+	authenticated func()
+	// ddl collects schema changes shared by all sessions
+	ddl *schemaWatcher
+	// schema is the live table metadata cache, nil when disabled
+	schema *schema.Cache
+	// admin answers virtualized admin commands, nil to forward them
+	admin adminSource
+	// toggles are the tables enabled, disabled or rolled out at runtime, nil
+	// to use the table config only
+	toggles *tableToggles
+	// breakGlass forwards every statement untouched while it is on, nil to
+	// always parse statements
+	breakGlass *breakGlass
+	// writes counts the statements dual-written per table, nil to not count
+	// them
+	writes *writeRates
+	// configVersion is the config version the session was created with, 0
+	// for the config file, and configStaged is set when it is the staged one
+	configVersion int64
+	configStaged  bool
+	// canary replays rewritten statements on a second backend, nil when
+	// canary mode is disabled
+	canary *canary.Replayer
+	// outbox queues shadow column updates of async tables, nil to dual-write
+	// them synchronously
+	outbox outbox.Queue
+	// shadows reads the current shadow values the conversion guard compares
+	// UPDATEs with, nil to guard without them
+	shadows shadowReader
+	// mu guards user and database, which are read by the sessions API
+	mu       sync.RWMutex
+	user     string
+	database string
+	inTx     bool
+	// autocommit mirrors the session's autocommit setting; when disabled the
+	// first statement implicitly starts a transaction
+	autocommit bool
+	// xa is set while an XA transaction branch is open on the backend
+	// connection, from XA START until XA COMMIT or XA ROLLBACK
+	xa bool
+	// limit bounds the resultset of the command being relayed
+	limit config.LimitConfig
+	// projection lists the currency columns the command being relayed reads
+	// from their shadow columns, and preparedProjections those of prepared
+	// statements by statement ID
+	projection          []parser.ProjectedColumn
+	preparedProjections map[uint32][]parser.ProjectedColumn
+	// txRewrites counts dual-written statements in the current transaction
+	txRewrites int
+	// txAborted is set when the proxy rolled back the client's transaction
+	// after a dual-write failure; statements are rejected until COMMIT/ROLLBACK
+	txAborted bool
+	// lastPacket is the last handshake or command packet read from the
+	// client, quarantined if handling it panics
+	lastPacket *protocol.Packet
 }
 
+// schemaLookupTimeout bounds a metadata load triggered by a shadow write
+const schemaLookupTimeout = 2 * time.Second
+
+// liveColumnType returns the type of a shadow column from the schema cache so
+// values are formatted with the column's actual scale. table is a table config
+// key, optionally qualified with its database.
+func (s *Session) liveColumnType(table, column string) (string, bool) {
+	database := s.config.Database.Database
+	if database == "" {
+		database = s.parser.CurrentDatabase()
+	}
+	if dot := strings.IndexByte(table, '.'); dot >= 0 {
+		database, table = table[:dot], table[dot+1:]
+	}
+	if database == "" {
+		return "", false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), schemaLookupTimeout)
+	defer cancel()
+	meta, ok, err := s.schema.Table(ctx, database, table)
+	if err != nil {
+		logger.Warn("Schema lookup failed, using configured target type",
+			"table", table, "column", column, "error", err, "conn_id", s.connID)
+		return "", false
+	}
+	if !ok {
+		return "", false
+	}
+	col, ok := meta.Column(column)
+	if !ok {
+		return "", false
+	}
+	return col.Type, true
+}
+
+// txAbortedMessage is returned for statements sent after the proxy rolled back
+// the client's transaction
+const txAbortedMessage = "TransisiDB: transaction was rolled back after a dual-write failure; issue ROLLBACK to continue"
+
 // NewSession creates a new session
 func NewSession(conn net.Conn, cfg *config.Config, pool *BackendPool) *Session {
 	return &Session{
 		clientConn:  conn,
 		config:      cfg,
 		backendPool: pool,
-		connID:      1, // TODO: Generate unique ID from server
+		connID:      1,
+		connectedAt: time.Now(),
+		autocommit:  true,
+	}
+}
+
+// SessionInfo is a point-in-time snapshot of a client session
+type SessionInfo struct {
+	ConnID      uint32    `json:"conn_id"`
+	User        string    `json:"user"`
+	Database    string    `json:"database"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Listener    string    `json:"listener,omitempty"`
+	Role        string    `json:"role,omitempty"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Queries     uint64    `json:"queries"`
+	// ConfigVersion is the config version the session uses, 0 for the
+	// config file
+	ConfigVersion int64 `json:"config_version"`
+	ConfigStaged  bool  `json:"config_staged,omitempty"`
+}
+
+// Info returns a snapshot of the session, safe to call from other goroutines
+func (s *Session) Info() SessionInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return SessionInfo{
+		ConnID:      s.connID,
+		User:        s.user,
+		Database:    s.database,
+		RemoteAddr:  s.clientConn.RemoteAddr().String(),
+		Listener:    s.listener,
+		Role:        s.role,
+		ConnectedAt: s.connectedAt,
+		Queries:     s.queries.Load(),
+
+		ConfigVersion: s.configVersion,
+		ConfigStaged:  s.configStaged,
 	}
 }
 
-// Handle processes the session
-func (s *Session) Handle() error {
+// User returns the MySQL user the client authenticated as
+func (s *Session) User() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.user
+}
+
+// Handle processes the session until the client disconnects or ctx is
+// cancelled. Cancelling ctx closes both connections, so a session blocked on
+// a client or backend read returns instead of leaking its goroutine.
+func (s *Session) Handle(ctx context.Context) (err error) {
+	defer s.recoverPanic(&err)
 	logger.Info("New connection", "remote_addr", s.clientConn.RemoteAddr().String())
 	defer s.clientConn.Close()
 
-	var err error
-
 	// 1. Acquire backend connection from pool or create new one
 	if s.backendPool != nil {
 		s.backendConn, err = s.backendPool.Acquire()
@@ -53,12 +213,29 @@ func (s *Session) Handle() error {
 	}
 
 	if err != nil {
+		// Reply in place of the handshake so the client sees why
+		if werr := backendError(err).write(s.clientConn, 0); werr != nil {
+			logger.Debug("Failed to send backend error", "error", werr, "conn_id", s.connID)
+		}
 		return fmt.Errorf("failed to acquire backend connection: %w", err)
 	}
 	defer s.releaseBackendConnection()
 
+	stop := context.AfterFunc(ctx, s.interrupt)
+	defer stop()
+
 	// Initialize parser and orchestrator
 	s.parser = parser.NewParser(s.config.Tables)
+	s.parser.SetSchema(s.config.Database.Database)
+	s.parser.SetLowerCaseTableNames(s.config.Database.LowerCaseTableNames)
+	s.parser.SetRoundingStrategy(s.config.Conversion.RoundingStrategy)
+	s.parser.SetPrecision(s.config.Conversion.Precision)
+	if s.schema != nil {
+		s.parser.SetColumnTypeResolver(s.liveColumnType)
+	}
+	if s.toggles != nil {
+		s.parser.SetTableEnabledResolver(s.toggles.enabled)
+	}
 
 	// 2. Proxy Handshake (Backend -> Client)
 	logger.Debug("Reading handshake from backend...")
@@ -68,7 +245,7 @@ func (s *Session) Handle() error {
 	}
 	logger.Debug("Handshake received from backend", "length", len(handshakePkt.Payload))
 
-	if err := protocol.WritePacket(s.clientConn, handshakePkt.SequenceID, handshakePkt.Payload); err != nil {
+	if err := protocol.WritePacket(s.clientConn, handshakePkt.SequenceID, maskServerHandshake(handshakePkt.Payload)); err != nil {
 		return fmt.Errorf("failed to forward handshake to client: %w", err)
 	}
 
@@ -77,8 +254,48 @@ func (s *Session) Handle() error {
 	if err != nil {
 		return fmt.Errorf("failed to read client handshake response: %w", err)
 	}
+	s.lastPacket = authPkt
+
+	if protocol.IsXProtocolMessage(authPkt.SequenceID, authPkt.Payload) {
+		// An X Protocol client (usually one configured for port 33060) would
+		// otherwise wait on a reply it cannot parse
+		logger.Warn("Client speaks the MySQL X Protocol, which the proxy does not support; point it at the classic protocol port",
+			"remote_addr", s.clientConn.RemoteAddr().String(), "conn_id", s.connID)
+		if _, err := s.clientConn.Write(protocol.EncodeXError(codeHandshakeError, "08S01",
+			"TransisiDB: the MySQL X Protocol is not supported; use the classic protocol")); err != nil {
+			logger.Debug("Failed to send X Protocol rejection", "error", err, "conn_id", s.connID)
+		}
+		return fmt.Errorf("client speaks the MySQL X Protocol, which the proxy does not support")
+	}
+
+	if protocol.IsSSLRequest(authPkt.Payload) {
+		// ER_HANDSHAKE_ERROR, so the client reports why the connection closed
+		if err := s.writeError(authPkt.SequenceID+1, codeHandshakeError, "08S01",
+			"TransisiDB: SSL connections are not supported by the proxy"); err != nil {
+			logger.Warn("Failed to send SSL rejection", "error", err, "conn_id", s.connID)
+		}
+		return fmt.Errorf("client requested SSL, which the proxy does not support")
+	}
+
+	authPayload := authPkt.Payload
+	if resp, err := protocol.DecodeHandshakeResponse41(authPkt.Payload); err != nil {
+		logger.Warn("Failed to decode client handshake response", "error", err, "conn_id", s.connID)
+	} else {
+		logger.Info("Client handshake", "user", resp.Username, "database", resp.Database,
+			"auth_plugin", resp.AuthPluginName, "conn_id", s.connID)
+		s.mu.Lock()
+		s.user = resp.Username
+		s.mu.Unlock()
+		if resp.Database != "" {
+			s.setDatabase(resp.Database)
+		}
+		if resp.CapabilityFlags&protocol.UnsupportedCapabilities != 0 {
+			resp.CapabilityFlags &^= protocol.UnsupportedCapabilities
+			authPayload = resp.Encode()
+		}
+	}
 
-	if err := protocol.WritePacket(s.backendConn.Conn(), authPkt.SequenceID, authPkt.Payload); err != nil {
+	if err := protocol.WritePacket(s.backendConn.Conn(), authPkt.SequenceID, authPayload); err != nil {
 		return fmt.Errorf("failed to forward auth response to backend: %w", err)
 	}
 
@@ -98,7 +315,7 @@ func (s *Session) Handle() error {
 
 			// OK Packet -> Auth Success
 			if protocol.IsOKPacket(authResultPkt.Payload) {
-				logger.Info("Handshake completed successfully", "conn_id", s.connID)
+				logger.Info("Handshake completed successfully", "user", s.user, "conn_id", s.connID)
 				break
 			}
 
@@ -107,6 +324,12 @@ func (s *Session) Handle() error {
 				return fmt.Errorf("authentication failed")
 			}
 
+			// caching_sha2_password fast auth success: the server sends
+			// OK next without waiting for the client
+			if pktType == 0x01 && len(authResultPkt.Payload) == 2 && authResultPkt.Payload[1] == 0x03 {
+				continue
+			}
+
 			// Auth Switch Request (0xFE) or Auth More Data (0x01)
 			if pktType == 0xFE || pktType == 0x01 {
 				logger.Debug("Handling Auth Switch/More Data", "type", fmt.Sprintf("0x%X", pktType))
@@ -115,6 +338,7 @@ func (s *Session) Handle() error {
 				if err != nil {
 					return fmt.Errorf("failed to read client auth response: %w", err)
 				}
+				s.lastPacket = clientAuthPkt
 
 				if err := protocol.WritePacket(s.backendConn.Conn(), clientAuthPkt.SequenceID, clientAuthPkt.Payload); err != nil {
 					return fmt.Errorf("failed to forward client auth response to backend: %w", err)
@@ -125,28 +349,71 @@ func (s *Session) Handle() error {
 	}
 
 	// 5. Command Loop
-	return s.handleCommands()
+	if s.authenticated != nil {
+		s.authenticated()
+	}
+	metrics.AddClientSession(s.user, 1)
+	defer metrics.AddClientSession(s.user, -1)
+	return s.handleCommands(ctx)
 }
 
-// handleCommands processes client commands
-func (s *Session) handleCommands() error {
+// interrupt closes the session's connections to unblock pending reads and
+// writes. The backend connection is never returned to the pool, so closing
+// it here is safe.
+func (s *Session) interrupt() {
+	s.clientConn.Close()
+	if s.backendConn != nil {
+		s.backendConn.Conn().Close()
+	}
+}
+
+// closeIdle ends a session whose client sent no command within timeout.
+// Closing the backend connection rolls back an open transaction.
+func (s *Session) closeIdle(timeout time.Duration) error {
+	logger.Info("Closing idle session", "idle_timeout", timeout, "in_tx", s.inTx, "user", s.user, "conn_id", s.connID)
+	metrics.RecordIdleSessionClosed()
+	return idleTimeoutExceeded.write(s.clientConn, 0)
+}
+
+// handleCommands processes client commands until the client disconnects,
+// stays idle longer than proxy.idle_timeout or ctx is cancelled
+func (s *Session) handleCommands(ctx context.Context) error {
 	for {
 		// Note: We don't set aggressive deadlines here because:
 		// - Client may be slow between commands (legitimate idle time)
 		// - Backend queries can take variable time
 		// - TCP keep-alive (set in listener) handles dead connections
 		// - Only set deadline if we need to enforce a specific timeout
+		idleTimeout := s.config.Proxy.IdleTimeout
+		if idleTimeout > 0 {
+			s.clientConn.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
 
 		// Read Command from Client
 		cmdPkt, err := protocol.ReadPacket(s.clientConn)
 		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("session interrupted: %w", context.Cause(ctx))
+			}
+			var netErr net.Error
+			if idleTimeout > 0 && errors.As(err, &netErr) && netErr.Timeout() {
+				return s.closeIdle(idleTimeout)
+			}
 			return fmt.Errorf("read command error: %w", err)
 		}
+		if idleTimeout > 0 {
+			s.clientConn.SetReadDeadline(time.Time{})
+		}
+		s.lastPacket = cmdPkt
 
 		if len(cmdPkt.Payload) == 0 {
 			continue
 		}
 
+		// Statement-specific limits are applied by handleQuery
+		s.limit = s.config.Proxy.LimitFor(s.user, "")
+		s.projection = nil
+
 		cmd := cmdPkt.Payload[0]
 		cmdName := protocol.GetCommandName(cmd)
 		logger.Debug("Received command", "command", cmdName, "conn_id", s.connID)
@@ -163,27 +430,78 @@ func (s *Session) handleCommands() error {
 				return err
 			}
 
-		case protocol.COM_INIT_DB:
-			// Track database change
-			if len(cmdPkt.Payload) > 1 {
-				s.database = string(cmdPkt.Payload[1:])
-				s.backendConn.SetDatabase(s.database)
-				logger.Info("Database changed", "database", s.database, "conn_id", s.connID)
+		case protocol.COM_STATISTICS:
+			// Report the proxy's counters rather than the backend's
+			if s.admin != nil {
+				if err := s.writeStatistics(cmdPkt.SequenceID + 1); err != nil {
+					return err
+				}
+				continue
 			}
 			if err := s.forwardCommand(cmdPkt); err != nil {
 				return err
 			}
 
+		case protocol.COM_DEBUG:
+			// The backend checks the SUPER privilege and dumps its state;
+			// the proxy adds its own to the proxy log
+			timing := &queryTiming{command: cmdName}
+			if err := s.forwardTimed(cmdPkt, timing); err != nil {
+				return err
+			}
+			if timing.ok {
+				s.logDebugInfo()
+			}
+
+		case protocol.COM_INIT_DB:
+			timing := &queryTiming{command: cmdName}
+			if err := s.forwardTimed(cmdPkt, timing); err != nil {
+				return err
+			}
+			// Track database change once the backend accepted it
+			if timing.ok && len(cmdPkt.Payload) > 1 {
+				s.setDatabase(string(cmdPkt.Payload[1:]))
+			}
+
 		case protocol.COM_QUERY:
 			if err := s.handleQuery(cmdPkt); err != nil {
 				return err
 			}
 
+		case protocol.COM_STMT_EXECUTE:
+			// Prepared statements are subject to the same aborted-transaction
+			// gate as text queries
+			if s.txAborted {
+				if err := s.writeError(cmdPkt.SequenceID+1, codeTransactionAborted, "40000", txAbortedMessage); err != nil {
+					return err
+				}
+				continue
+			}
+			if !s.autocommit && !s.inTx {
+				s.beginTransaction("autocommit disabled")
+			}
+			if len(cmdPkt.Payload) >= 5 {
+				s.projection = s.preparedProjections[binary.LittleEndian.Uint32(cmdPkt.Payload[1:5])]
+			}
+			if err := s.forwardCommand(cmdPkt); err != nil {
+				return err
+			}
+
 		case protocol.COM_STMT_PREPARE:
 			if err := s.handlePrepare(cmdPkt); err != nil {
 				return err
 			}
 
+		case protocol.COM_STMT_SEND_LONG_DATA, protocol.COM_STMT_CLOSE:
+			// The server sends no response to these
+			if cmd == protocol.COM_STMT_CLOSE && len(cmdPkt.Payload) >= 5 {
+				delete(s.preparedProjections, binary.LittleEndian.Uint32(cmdPkt.Payload[1:5]))
+			}
+			s.backendConn.Conn().SetWriteDeadline(time.Now().Add(s.config.Proxy.WriteTimeout))
+			if err := protocol.WritePacket(s.backendConn.Conn(), cmdPkt.SequenceID, cmdPkt.Payload); err != nil {
+				return fmt.Errorf("failed to forward %s: %w", cmdName, err)
+			}
+
 		default:
 			// Forward unknown commands as-is
 			if err := s.forwardCommand(cmdPkt); err != nil {
@@ -193,76 +511,406 @@ func (s *Session) handleCommands() error {
 	}
 }
 
+// maskServerHandshake removes the capabilities the proxy cannot relay from the
+// server's initial handshake. Handshakes that cannot be decoded are forwarded as-is.
+func maskServerHandshake(payload []byte) []byte {
+	handshake, err := protocol.DecodeHandshakeV10(payload)
+	if err != nil {
+		logger.Warn("Failed to decode backend handshake", "error", err)
+		return payload
+	}
+	if handshake.CapabilityFlags&protocol.UnsupportedCapabilities == 0 {
+		return payload
+	}
+	// Encode only produces the modern (4.1, plugin auth) layout
+	required := uint32(protocol.CLIENT_PROTOCOL_41 | protocol.CLIENT_SECURE_CONNECTION | protocol.CLIENT_PLUGIN_AUTH)
+	if handshake.CapabilityFlags&required != required {
+		return payload
+	}
+
+	logger.Debug("Masking unsupported capabilities", "server_version", handshake.ServerVersion,
+		"capabilities", fmt.Sprintf("0x%08X", handshake.CapabilityFlags))
+	handshake.CapabilityFlags &^= protocol.UnsupportedCapabilities
+	return handshake.Encode()
+}
+
 // handleQuery processes a COM_QUERY command
 func (s *Session) handleQuery(cmdPkt *protocol.Packet) error {
 	query := string(cmdPkt.Payload[1:])
-	logger.Info("Received query", "query", query, "conn_id", s.connID)
+	s.queries.Add(1)
+	logger.Info("Received query", "query", query, "user", s.user, "conn_id", s.connID)
 
 	// Track transaction state
 	upperQuery := strings.ToUpper(strings.TrimSpace(query))
-	if upperQuery == "BEGIN" || upperQuery == "START TRANSACTION" {
-		s.inTx = true
-		s.backendConn.SetInTransaction(true)
-		logger.Debug("Transaction started", "conn_id", s.connID)
-	} else if upperQuery == "COMMIT" || upperQuery == "ROLLBACK" {
-		s.inTx = false
-		s.backendConn.SetInTransaction(false)
-		logger.Debug("Transaction ended", "conn_id", s.connID, "command", upperQuery)
+	txKind, txChain := transactionControl(upperQuery)
+	txControl := true
+	switch txKind {
+	case "BEGIN":
+		s.beginTransaction(upperQuery)
+	case "COMMIT", "ROLLBACK":
+		aborted := s.txAborted
+		s.endTransaction(txKind)
+		if txChain {
+			s.beginTransaction("chain")
+		}
+		// The backend no longer has an open transaction, so a COMMIT would
+		// report success for work that was already rolled back
+		if aborted && txKind == "COMMIT" {
+			return s.writeError(cmdPkt.SequenceID+1, codeTransactionAborted, "40000",
+				"TransisiDB: transaction was rolled back after a dual-write failure")
+		}
+	default:
+		txControl = false
+		if s.txAborted {
+			return s.writeError(cmdPkt.SequenceID+1, codeTransactionAborted, "40000", txAbortedMessage)
+		}
+		if verb := xaVerb(upperQuery); verb != "" {
+			return s.handleXA(cmdPkt, verb)
+		}
+		if handled, err := s.handleLocalAdmin(cmdPkt, query, upperQuery); handled {
+			return err
+		}
+	}
+
+	// Break glass forwards statements untouched: no rules, parsing or
+	// rewriting. Admin statements above still work, so it can be turned off.
+	passThrough := s.breakGlass.active()
+
+	// Apply query rules before parsing. Transaction control is left alone so
+	// rules cannot desynchronize the transaction state tracked above.
+	if !txControl && !passThrough && s.rules.Len() > 0 {
+		result := s.rules.Evaluate(rules.MatchContext{
+			Query:  query,
+			User:   s.user,
+			Schema: s.database,
+		})
+
+		if result.Blocked {
+			logger.Warn("Query blocked by rule", "rules", result.MatchedRules, "query", query, "user", s.user, "conn_id", s.connID)
+			return s.writeError(cmdPkt.SequenceID+1, codeQueryBlocked, "HY000", result.ErrorMessage)
+		}
+		if result.Rewritten {
+			logger.Info("Query rewritten by rule", "rules", result.MatchedRules, "original", query, "new", result.Query)
+			query = result.Query
+			cmdPkt = newQueryPacket(cmdPkt.SequenceID, query)
+		}
+	}
+	if !txControl {
+		s.trackImplicitTransaction(query)
+	}
+
+	// Simulation listeners check the statement that would reach the backend,
+	// after any rule rewrite
+	if !txControl && s.simulation && !isReadOnlyStatement(query) {
+		logger.Warn("Rejecting write on simulation listener", "listener", s.listener, "query", query, "user", s.user, "conn_id", s.connID)
+		return s.writeError(cmdPkt.SequenceID+1, codeReadOnly, "25006", simulationMessage)
+	}
+
+	if passThrough {
+		metrics.RecordBreakGlassStatement()
+		return s.forwardTimed(cmdPkt, &queryTiming{statement: parser.QueryTypeUnknown.String()})
+	}
+
+	// DDL is forwarded as-is; once the backend accepted it the new schema is
+	// checked against the table config
+	if changes, ok := parser.ParseSchemaChange(query); ok {
+		timing := &queryTiming{statement: "DDL"}
+		if err := s.forwardTimed(cmdPkt, timing); err != nil {
+			return err
+		}
+		if timing.ok {
+			s.observeSchemaChanges(changes)
+		}
+		return nil
+	}
+
+	timing := &queryTiming{statement: parser.QueryTypeUnknown.String()}
+
+	// Batch statements above max_parse_size would spend longer in the parser
+	// than on the backend
+	if limit := s.config.Proxy.MaxParseSize; limit > 0 && len(query) > limit {
+		return s.forwardUnparsed(cmdPkt, timing, query, limit)
 	}
 
 	// Parse query
+	parseStart := time.Now()
 	pq, err := s.parser.Parse(query)
+	timing.parse = time.Since(parseStart)
 	if err != nil {
 		logger.Warn("Failed to parse query", "error", err, "query", query)
-		// Forward original query if parsing fails
-		return s.forwardCommand(cmdPkt)
+		// Writes that appear to target configured tables are subject to the
+		// table's failure policy; everything else is forwarded as-is
+		if tables := s.parser.GuessWriteTables(query); len(tables) > 0 {
+			table, _ := s.config.StrictestFailurePolicy(tables, config.FailOpen)
+			if s.observing(table) {
+				metrics.RecordObservedStatement(table, false)
+				return s.forwardTimed(cmdPkt, timing)
+			}
+			return s.rewriteFailed(cmdPkt, timing, table, "parse", err)
+		}
+		return s.forwardTimed(cmdPkt, timing)
+	}
+	timing.statement = pq.Type.String()
+
+	// Track database change from USE statements once the backend accepted it
+	if pq.Type == parser.QueryTypeUse {
+		if err := s.forwardTimed(cmdPkt, timing); err != nil {
+			return err
+		}
+		if timing.ok {
+			s.setDatabase(pq.Database)
+		}
+		return nil
+	}
+
+	// Clients of tables read in IDN compare and read currency columns in IDN
+	if table, tableConfig, ok := s.parser.ReadTable(pq); ok && tableConfig.ReadsIDN() {
+		if rewritten := s.rewriteIDNRead(table, query); rewritten != query {
+			query = rewritten
+			cmdPkt = newQueryPacket(cmdPkt.SequenceID, query)
+		}
+	}
+
+	if pq.Type == parser.QueryTypeSelect {
+		s.limit = s.config.Proxy.LimitFor(s.user, pq.TableName)
+		if s.limit.MaxExecutionTime > 0 {
+			if hinted, ok := withMaxExecutionTime(query, s.limit.MaxExecutionTime); ok {
+				cmdPkt = newQueryPacket(cmdPkt.SequenceID, hinted)
+			}
+		}
 	}
 
 	// Check if query needs transformation
 	if !pq.NeedsTransform {
 		logger.Debug("Query does not need transformation", "query_type", pq.Type)
-		return s.forwardCommand(cmdPkt)
+		return s.forwardTimed(cmdPkt, timing)
 	}
 
-	logger.Info("Query needs transformation", "table", pq.TableName, "query_type", pq.Type)
+	// MySQL fills the shadow columns of database tables with triggers or
+	// generated columns
+	if s.config.Tables[pq.TableName].InDatabase() {
+		logger.Debug("Shadow columns maintained by the database, forwarding untouched", "table", pq.TableName)
+		return s.forwardTimed(cmdPkt, timing)
+	}
+
+	// Tables being rolled out dual-write only a share of their statements
+	if !s.inRollout(pq) {
+		logger.Debug("Statement outside the table's rollout, forwarding untouched", "table", pq.TableName)
+		return s.forwardTimed(cmdPkt, timing)
+	}
+
+	// Tables in observe mode only report the rewrite they would have made
+	if s.observing(pq.TableName) {
+		return s.observeStatement(cmdPkt, timing, pq, query)
+	}
 
-	// Convert currency values
-	convertedValues := make(map[string]float64)
-	for col, val := range pq.Values {
-		var floatVal float64
-		if strVal, ok := val.(string); ok {
-			fmt.Sscanf(strVal, "%f", &floatVal)
-			// Apply conversion ratio and rounding
-			convertedVal := floatVal / float64(s.config.Conversion.Ratio)
-			convertedValues[col] = convertedVal
+	// Async tables are forwarded untouched; the outbox fills their shadow
+	// columns once the backend accepted the write
+	if task, ok := s.outboxTask(pq); ok {
+		if err := s.forwardTimed(cmdPkt, timing); err != nil {
+			return err
+		}
+		if timing.ok {
+			s.enqueueOutbox(task, timing)
+			s.writes.record(pq.TableName, time.Now())
 		}
+		return nil
+	}
+
+	logger.Info("Query needs transformation", "table", pq.TableName, "query_type", pq.Type)
+
+	rewriteStart := time.Now()
+	newQuery, stage, err := s.dualWriteQuery(pq)
+	timing.rewrite = time.Since(rewriteStart)
+	if err != nil {
+		return s.rewriteFailed(cmdPkt, timing, pq.TableName, stage, err)
+	}
+
+	logger.Info("Rewrote query", "original", query, "new", newQuery)
+	if s.inTx {
+		s.txRewrites++
+	}
+
+	// Forward rewritten command
+	if err := s.forwardTimed(newQueryPacket(cmdPkt.SequenceID, newQuery), timing); err != nil {
+		return err
+	}
+	if timing.ok {
+		s.writes.record(pq.TableName, time.Now())
+	}
+
+	// Statements inside a transaction depend on its earlier statements, which
+	// the canary did not see, so only autocommitted ones are compared
+	if s.canary != nil && !s.inTx {
+		s.canary.Submit(canary.Statement{
+			Table:        pq.TableName,
+			Query:        newQuery,
+			AffectedRows: timing.affectedRows,
+			ErrorCode:    timing.errorCode,
+		})
+	}
+	return nil
+}
+
+// dualWriteQuery converts the statement's currency values and rewrites it with
+// its shadow columns. On failure it also returns the stage that failed.
+func (s *Session) dualWriteQuery(pq *parser.ParsedQuery) (string, string, error) {
+	tableConfig := s.config.Tables[pq.TableName]
+	// Amounts equal to the row's current shadow value look already converted
+	existing := s.currentShadowValues(pq, tableConfig)
+	convertedValues, stage, err := converter.ConvertStatement(s.config.Conversion, tableConfig, pq, existing)
+	if err != nil {
+		return "", stage, err
 	}
 
 	// Rewrite query with shadow columns
 	newQuery, err := s.parser.RewriteForDualWrite(pq, convertedValues)
 	if err != nil {
-		logger.Error("Failed to rewrite query", "error", err)
-		return s.forwardCommand(cmdPkt)
+		return "", "rewrite", err
 	}
+	return newQuery, "", nil
+}
 
-	logger.Info("Rewrote query", "original", query, "new", newQuery)
+// unparsedHeadLength is how much of a statement above max_parse_size is read
+// to find the table it writes
+const unparsedHeadLength = 1024
+
+// forwardUnparsed handles a statement above max_parse_size without parsing it.
+// A write to a configured table is treated like one the parser rejects: the
+// table's failure policy decides whether it is forwarded with its shadow
+// columns left for backfill or rejected.
+func (s *Session) forwardUnparsed(cmdPkt *protocol.Packet, timing *queryTiming, query string, limit int) error {
+	head := query
+	if len(head) > unparsedHeadLength {
+		head = head[:unparsedHeadLength]
+	}
+	tables := s.parser.GuessWriteTables(head)
+	if len(tables) == 0 {
+		metrics.RecordUnparsedStatement("")
+		logger.Debug("Forwarding statement above max_parse_size without parsing", "bytes", len(query))
+		return s.forwardTimed(cmdPkt, timing)
+	}
 
-	// Create new packet with rewritten query
-	newPayload := make([]byte, 1+len(newQuery))
-	newPayload[0] = protocol.COM_QUERY
-	copy(newPayload[1:], newQuery)
+	table, _ := s.config.StrictestFailurePolicy(tables, config.FailOpen)
+	metrics.RecordUnparsedStatement(table)
+	if s.observing(table) {
+		metrics.RecordObservedStatement(table, false)
+		return s.forwardTimed(cmdPkt, timing)
+	}
+	cause := errs.New(errs.ParseTooLarge, "statement of %d bytes exceeds max_parse_size of %d, shadow columns left for backfill", len(query), limit)
+	return s.rewriteFailed(cmdPkt, timing, table, "size", cause)
+}
 
-	// Forward rewritten command
-	rewrittenPkt := &protocol.Packet{
-		SequenceID: cmdPkt.SequenceID,
-		Payload:    newPayload,
+// rewriteFailed applies the table's failure policy to a statement that could
+// not be dual-written: fail_closed rejects it with an ERR packet, the fail_open
+// variants forward the original statement without shadow columns. Amounts the
+// conversion guard rejects are always refused.
+func (s *Session) rewriteFailed(cmdPkt *protocol.Packet, timing *queryTiming, table, stage string, cause error) error {
+	// Without a configured policy the proxy keeps forwarding, as it always has
+	policy := converter.FailurePolicy(s.config, table, config.FailOpen, cause)
+	if errs.CodeOf(cause) == "" {
+		cause = errs.Wrap(errs.ConversionRewrite, cause, "")
+	}
+	metrics.RecordRewriteFailure(table, stage, policy)
+	if errors.Is(cause, converter.ErrPlaceholder) {
+		converter.RecordPlaceholder(table, policy)
 	}
 
-	return s.forwardCommand(rewrittenPkt)
+	switch policy {
+	case config.FailClosed:
+		logger.Error("Rejecting statement, dual-write failed",
+			"table", table, "stage", stage, "error", cause, "code", errs.CodeOf(cause), "user", s.user, "conn_id", s.connID)
+		message := fmt.Sprintf("TransisiDB: dual-write %s failed for table %s: %v", stage, table, cause)
+		if s.inTx {
+			// Committing the rest of the transaction would leave IDR and IDN
+			// columns diverged within one unit of work
+			if err := s.abortTransaction(table); err != nil {
+				return err
+			}
+			message += "; transaction rolled back"
+		}
+		return s.writeError(cmdPkt.SequenceID+1, codeDualWriteRejected, "HY000", message)
+	case config.FailOpenWithAlert:
+		logger.Error("ALERT: forwarding statement without dual-write",
+			"table", table, "stage", stage, "error", cause, "code", errs.CodeOf(cause), "tx_rewrites", s.txRewrites, "user", s.user, "conn_id", s.connID)
+	default:
+		logger.Warn("Forwarding statement without dual-write",
+			"table", table, "stage", stage, "error", cause, "code", errs.CodeOf(cause), "tx_rewrites", s.txRewrites, "user", s.user, "conn_id", s.connID)
+	}
+
+	return s.forwardTimed(cmdPkt, timing)
+}
+
+// abortTransaction rolls back the client's open transaction on the backend and
+// rejects further statements until the client ends the transaction itself
+func (s *Session) abortTransaction(table string) error {
+	logger.Warn("Rolling back transaction after dual-write failure",
+		"table", table, "tx_rewrites", s.txRewrites, "conn_id", s.connID)
+
+	rollback := newQueryPacket(0, "ROLLBACK")
+	if err := protocol.WritePacket(s.backendConn.Conn(), rollback.SequenceID, rollback.Payload); err != nil {
+		return fmt.Errorf("failed to send rollback to backend: %w", err)
+	}
+	respPkt, err := protocol.ReadPacket(s.backendConn.Conn())
+	if err != nil {
+		return fmt.Errorf("failed to read rollback response: %w", err)
+	}
+	if protocol.IsERRPacket(respPkt.Payload) {
+		if errPkt, err := protocol.ParseERRPacket(respPkt.Payload); err == nil {
+			return fmt.Errorf("backend rejected rollback: %s", errPkt.ErrorMessage)
+		}
+		return fmt.Errorf("backend rejected rollback")
+	}
+
+	s.inTx = false
+	s.txRewrites = 0
+	s.txAborted = true
+	s.backendConn.SetInTransaction(false)
+	metrics.RecordTransactionRollback(table)
+	return nil
+}
+
+// newQueryPacket builds a COM_QUERY packet for the given statement
+func newQueryPacket(sequenceID uint8, query string) *protocol.Packet {
+	payload := make([]byte, 1+len(query))
+	payload[0] = protocol.COM_QUERY
+	copy(payload[1:], query)
+
+	return &protocol.Packet{
+		SequenceID: sequenceID,
+		Payload:    payload,
+	}
+}
+
+// writeError sends a proxy-generated ERR packet to the client
+func (s *Session) writeError(sequenceID uint8, code uint16, sqlState string, message string) error {
+	if err := (proxyError{code, sqlState, message}).write(s.clientConn, sequenceID); err != nil {
+		return fmt.Errorf("failed to write error to client: %w", err)
+	}
+	return nil
 }
 
 // handlePrepare processes COM_STMT_PREPARE command
 func (s *Session) handlePrepare(cmdPkt *protocol.Packet) error {
+	// Executing a prepared statement carries no SQL, so simulation listeners
+	// check the statement when it is prepared
+	if s.simulation && !isReadOnlyStatement(string(cmdPkt.Payload[1:])) {
+		return s.writeError(cmdPkt.SequenceID+1, codeReadOnly, "25006", simulationMessage)
+	}
+
+	// Prepared SELECTs on tables read in IDN read their currency columns from
+	// the shadow columns like text queries
+	if s.parser != nil && !s.breakGlass.active() {
+		query := string(cmdPkt.Payload[1:])
+		if pq, err := s.parser.Parse(query); err == nil {
+			if table, tableConfig, ok := s.parser.ReadTable(pq); ok && tableConfig.ReadsIDN() {
+				if rewritten := s.rewriteIDNRead(table, query); rewritten != query {
+					cmdPkt = &protocol.Packet{SequenceID: cmdPkt.SequenceID, Payload: append([]byte{protocol.COM_STMT_PREPARE}, rewritten...)}
+				}
+			}
+		}
+	}
+
 	// Forward command to backend
 	if err := protocol.WritePacket(s.backendConn.Conn(), cmdPkt.SequenceID, cmdPkt.Payload); err != nil {
 		return fmt.Errorf("failed to forward prepare command: %w", err)
@@ -295,6 +943,12 @@ func (s *Session) handlePrepare(cmdPkt *protocol.Packet) error {
 
 	numColumns := binary.LittleEndian.Uint16(respPkt.Payload[5:7])
 	numParams := binary.LittleEndian.Uint16(respPkt.Payload[7:9])
+	if len(s.projection) > 0 {
+		if s.preparedProjections == nil {
+			s.preparedProjections = make(map[uint32][]parser.ProjectedColumn)
+		}
+		s.preparedProjections[binary.LittleEndian.Uint32(respPkt.Payload[1:5])] = s.projection
+	}
 
 	// Read Parameter Definitions
 	if numParams > 0 {
@@ -324,7 +978,11 @@ func (s *Session) handlePrepare(cmdPkt *protocol.Packet) error {
 			if err != nil {
 				return fmt.Errorf("failed to read column packet: %w", err)
 			}
-			if err := protocol.WritePacket(s.clientConn, pkt.SequenceID, pkt.Payload); err != nil {
+			payload := pkt.Payload
+			if len(s.projection) > 0 {
+				payload = renameProjected(payload, s.projection)
+			}
+			if err := protocol.WritePacket(s.clientConn, pkt.SequenceID, payload); err != nil {
 				return fmt.Errorf("failed to forward column packet: %w", err)
 			}
 		}
@@ -341,8 +999,58 @@ func (s *Session) handlePrepare(cmdPkt *protocol.Packet) error {
 	return nil
 }
 
+// setDatabase records the session's current database so table configs
+// are only applied to tables in the configured schema
+func (s *Session) setDatabase(db string) {
+	s.mu.Lock()
+	s.database = db
+	s.mu.Unlock()
+	s.backendConn.SetDatabase(db)
+	if s.parser != nil {
+		s.parser.SetCurrentDatabase(db)
+	}
+	logger.Info("Database changed", "database", db, "conn_id", s.connID)
+}
+
+// queryTiming collects the per-phase latency breakdown of a forwarded statement.
+// For non-query commands command is set instead of statement.
+type queryTiming struct {
+	statement string
+	command   string
+	parse     time.Duration
+	rewrite   time.Duration
+	backend   time.Duration
+	stream    time.Duration
+	// ok is set when the backend answered with an OK packet
+	ok bool
+	// affectedRows and lastInsertID are read from the OK packet
+	affectedRows uint64
+	lastInsertID uint64
+	// errorCode is set when the backend answered with an ERR packet
+	errorCode uint16
+}
+
 // forwardCommand forwards a command to backend and proxies response
 func (s *Session) forwardCommand(cmdPkt *protocol.Packet) error {
+	return s.forwardTimed(cmdPkt, &queryTiming{command: protocol.GetCommandName(cmdPkt.Payload[0])})
+}
+
+// forwardTimed forwards a command and records backend round-trip and streaming time
+func (s *Session) forwardTimed(cmdPkt *protocol.Packet, timing *queryTiming) error {
+	if err := s.relayCommand(cmdPkt, timing); err != nil {
+		return err
+	}
+	if timing.command != "" {
+		metrics.RecordCommandDuration(timing.command, timing.backend+timing.stream)
+		return nil
+	}
+	metrics.RecordQueryPhases(timing.statement, timing.parse, timing.rewrite, timing.backend, timing.stream)
+	metrics.RecordClientQuery(s.user, timing.statement)
+	return nil
+}
+
+// relayCommand writes a command to the backend and relays the full response to the client
+func (s *Session) relayCommand(cmdPkt *protocol.Packet, timing *queryTiming) error {
 	// Set write deadline
 	s.backendConn.Conn().SetWriteDeadline(time.Now().Add(s.config.Proxy.WriteTimeout))
 
@@ -353,20 +1061,42 @@ func (s *Session) forwardCommand(cmdPkt *protocol.Packet) error {
 
 	// Proxy response back to client
 	// Read response from backend
+	backendStart := time.Now()
 	respPkt, err := protocol.ReadPacket(s.backendConn.Conn())
 	if err != nil {
 		return fmt.Errorf("failed to read backend response: %w", err)
 	}
+	timing.backend = time.Since(backendStart)
+
+	streamStart := time.Now()
+	defer func() { timing.stream = time.Since(streamStart) }()
+
+	// Response packets are written through as they arrive
+	w := s.newClientWriter()
 
 	// Forward response to client
-	if err := protocol.WritePacket(s.clientConn, respPkt.SequenceID, respPkt.Payload); err != nil {
+	if err := w.writePacket(respPkt.SequenceID, respPkt.Payload); err != nil {
 		return fmt.Errorf("failed to forward response to client: %w", err)
 	}
 
 	// Check if it's OK or ERR
-	if protocol.IsOKPacket(respPkt.Payload) || protocol.IsERRPacket(respPkt.Payload) {
+	timing.ok = protocol.IsOKPacket(respPkt.Payload)
+	if timing.ok {
+		if ok, err := protocol.ParseOKPacket(respPkt.Payload); err == nil {
+			timing.affectedRows = ok.AffectedRows
+			timing.lastInsertID = ok.LastInsertID
+		}
 		return nil
 	}
+	if protocol.IsERRPacket(respPkt.Payload) {
+		if errPkt, err := protocol.ParseERRPacket(respPkt.Payload); err == nil {
+			timing.errorCode = errPkt.ErrorCode
+		}
+		return nil
+	}
+	if protocol.IsLocalInfileRequest(respPkt.Payload) {
+		return s.relayLocalInfile(w, timing)
+	}
 
 	// It's likely a Result Set (column count packet)
 	// Read Column Definitions until EOF
@@ -375,36 +1105,90 @@ func (s *Session) forwardCommand(cmdPkt *protocol.Packet) error {
 		if err != nil {
 			return fmt.Errorf("failed to read column packet: %w", err)
 		}
-		if err := protocol.WritePacket(s.clientConn, pkt.SequenceID, pkt.Payload); err != nil {
-			return fmt.Errorf("failed to forward column packet: %w", err)
-		}
 		if protocol.IsEOFPacket(pkt.Payload) {
+			if err := w.writePacket(pkt.SequenceID, pkt.Payload); err != nil {
+				return fmt.Errorf("failed to forward column packet: %w", err)
+			}
 			break
 		}
+		payload := pkt.Payload
+		if len(s.projection) > 0 {
+			payload = renameProjected(payload, s.projection)
+		}
+		if err := w.writePacket(pkt.SequenceID, payload); err != nil {
+			return fmt.Errorf("failed to forward column packet: %w", err)
+		}
 	}
 
 	// Read Rows until EOF
+	counter := &resultCounter{limit: s.limit}
 	for {
 		pkt, err := protocol.ReadPacket(s.backendConn.Conn())
 		if err != nil {
 			return fmt.Errorf("failed to read row packet: %w", err)
 		}
-		if err := protocol.WritePacket(s.clientConn, pkt.SequenceID, pkt.Payload); err != nil {
+		last := protocol.IsEOFPacket(pkt.Payload) || protocol.IsERRPacket(pkt.Payload)
+		if !last {
+			if message := counter.add(pkt.Payload); message != "" {
+				logger.Warn("Terminating resultset, limit exceeded", "rows", counter.rows, "bytes", counter.bytes,
+					"user", s.user, "conn_id", s.connID)
+				if err := w.writePacket(pkt.SequenceID, protocol.EncodeERRPacket(resultLimitCode, "70100", message)); err != nil {
+					return fmt.Errorf("failed to forward error packet: %w", err)
+				}
+				return errResultLimitExceeded
+			}
+		}
+		if err := w.writePacket(pkt.SequenceID, pkt.Payload); err != nil {
 			return fmt.Errorf("failed to forward row packet: %w", err)
 		}
-		if protocol.IsEOFPacket(pkt.Payload) || protocol.IsERRPacket(pkt.Payload) {
+		if last {
+			break
+		}
+	}
+
+	return nil
+}
+
+// relayLocalInfile completes LOAD DATA LOCAL INFILE once the backend has asked
+// for the file: the client's file packets go to the backend up to the empty
+// packet that ends them, then the backend's OK or ERR goes to the client
+func (s *Session) relayLocalInfile(w *clientWriter, timing *queryTiming) error {
+	for {
+		pkt, err := protocol.ReadPacket(s.clientConn)
+		if err != nil {
+			return fmt.Errorf("failed to read LOCAL INFILE data: %w", err)
+		}
+		s.backendConn.Conn().SetWriteDeadline(time.Now().Add(s.config.Proxy.WriteTimeout))
+		if err := protocol.WritePacket(s.backendConn.Conn(), pkt.SequenceID, pkt.Payload); err != nil {
+			return fmt.Errorf("failed to forward LOCAL INFILE data: %w", err)
+		}
+		if len(pkt.Payload) == 0 {
 			break
 		}
 	}
 
+	respPkt, err := protocol.ReadPacket(s.backendConn.Conn())
+	if err != nil {
+		return fmt.Errorf("failed to read LOCAL INFILE result: %w", err)
+	}
+	if err := w.writePacket(respPkt.SequenceID, respPkt.Payload); err != nil {
+		return fmt.Errorf("failed to forward LOCAL INFILE result: %w", err)
+	}
+	timing.ok = protocol.IsOKPacket(respPkt.Payload)
+	if timing.ok {
+		if ok, err := protocol.ParseOKPacket(respPkt.Payload); err == nil {
+			timing.affectedRows = ok.AffectedRows
+		}
+	} else if errPkt, err := protocol.ParseERRPacket(respPkt.Payload); err == nil {
+		timing.errorCode = errPkt.ErrorCode
+	}
 	return nil
 }
 
 func (s *Session) createDirectBackendConnection() (*BackendConn, error) {
-	backendDSN := net.JoinHostPort(s.config.Database.Host, fmt.Sprintf("%d", s.config.Database.Port))
-	backendConn, err := net.DialTimeout("tcp", backendDSN, s.config.Database.ConnectionTimeout)
+	backendConn, err := dialBackend(&s.config.Database)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to backend %s: %w", backendDSN, err)
+		return nil, err
 	}
 	return NewBackendConn(backendConn, s.connID), nil
 }
@@ -420,7 +1204,11 @@ func (s *Session) releaseBackendConnection() {
 
 	// Always close backend connection for now since we can't reuse them
 	// without handling the handshake/auth replay logic.
-	s.backendConn.Close()
+	if s.backendPool != nil {
+		s.backendPool.Discard(s.backendConn)
+	} else {
+		s.backendConn.Close()
+	}
 	/*
 		if s.backendPool != nil {
 			s.backendPool.Release(s.backendConn)
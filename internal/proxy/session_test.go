@@ -2,11 +2,19 @@ package proxy
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"io"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/internal/rules"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
 )
 
 type MockConn struct {
@@ -61,8 +69,1283 @@ func TestSession_Handle_ConnectionError(t *testing.T) {
 	conn := NewMockConn()
 	session := NewSession(conn, cfg, nil)
 
-	err := session.Handle()
+	err := session.Handle(context.Background())
 	if err == nil {
 		t.Error("Expected error when backend connection fails")
 	}
 }
+
+func TestSession_HandleQuery_BlockedByRule(t *testing.T) {
+	engine, err := rules.NewEngine([]config.QueryRule{
+		{ID: 1, Active: true, MatchDigest: `^DROP TABLE`, Action: config.RuleActionBlock, ErrorMessage: "DROP is not allowed"},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	conn := NewMockConn()
+	session := NewSession(conn, &config.Config{}, nil)
+	session.rules = engine
+
+	if err := session.handleQuery(newQueryPacket(0, "DROP TABLE orders")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	if pkt.SequenceID != 1 {
+		t.Errorf("expected sequence id 1, got %d", pkt.SequenceID)
+	}
+	errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+	if err != nil {
+		t.Fatalf("expected ERR packet: %v", err)
+	}
+	if errPkt.ErrorMessage != "DROP is not allowed" {
+		t.Errorf("unexpected error message: %q", errPkt.ErrorMessage)
+	}
+}
+
+func TestSession_HandleQuery_RulesSkipTransactionControl(t *testing.T) {
+	engine, err := rules.NewEngine([]config.QueryRule{
+		{ID: 1, Active: true, MatchPattern: ".*", Action: config.RuleActionComment, Comment: "app=shop"},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	backend := NewMockConn()
+	okPacket := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	for i := 0; i < 2; i++ {
+		if err := protocol.WritePacket(backend.ReadBuf, 1, okPacket); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+	}
+
+	session := NewSession(NewMockConn(), &config.Config{}, nil)
+	session.parser = parser.NewParser(nil)
+	session.backendConn = NewBackendConn(backend, 1)
+	session.rules = engine
+
+	if err := session.handleQuery(newQueryPacket(0, "BEGIN")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	if !session.inTx {
+		t.Error("expected BEGIN to start a transaction")
+	}
+	sent, err := protocol.ReadPacket(backend.WriteBuf)
+	if err != nil {
+		t.Fatalf("expected BEGIN to be forwarded: %v", err)
+	}
+	if string(sent.Payload[1:]) != "BEGIN" {
+		t.Errorf("transaction control must not be rewritten by rules, got %q", sent.Payload[1:])
+	}
+
+	if err := session.handleQuery(newQueryPacket(0, "SELECT 1")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	sent, err = protocol.ReadPacket(backend.WriteBuf)
+	if err != nil {
+		t.Fatalf("expected SELECT to be forwarded: %v", err)
+	}
+	if string(sent.Payload[1:]) != "/* app=shop */ SELECT 1" {
+		t.Errorf("expected rule to apply to ordinary statements, got %q", sent.Payload[1:])
+	}
+}
+
+func TestSession_HandleQuery_RewriteFailClosed(t *testing.T) {
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, FailurePolicy: config.FailClosed},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {SourceColumn: "total_amount", TargetColumn: "total_amount_idn", TargetType: "DECIMAL(6,4)", Precision: 4},
+				},
+			},
+		},
+	}
+
+	conn := NewMockConn()
+	session := NewSession(conn, cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+
+	if err := session.handleQuery(newQueryPacket(0, "INSERT INTO orders (total_amount) VALUES (250000)")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	if _, err := protocol.ParseERRPacket(pkt.Payload); err != nil {
+		t.Fatalf("expected ERR packet: %v", err)
+	}
+}
+
+func TestSession_HandleQuery_GuardRejectsConvertedAmount(t *testing.T) {
+	// fail_open would forward other failed rewrites
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, FailurePolicy: config.FailOpen,
+			Guard: config.GuardConfig{Policy: config.GuardReject}},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+				},
+			},
+		},
+	}
+
+	conn := NewMockConn()
+	backend := NewMockConn()
+	session := NewSession(conn, cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	if err := session.handleQuery(newQueryPacket(0, "INSERT INTO orders (total_amount) VALUES (150.5)")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	if backend.WriteBuf.Len() != 0 {
+		t.Errorf("expected nothing to reach the backend, got %q", backend.WriteBuf.String())
+	}
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	if _, err := protocol.ParseERRPacket(pkt.Payload); err != nil {
+		t.Fatalf("expected ERR packet: %v", err)
+	}
+}
+
+func TestSession_HandleQuery_MultiRowInsert(t *testing.T) {
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+				},
+			},
+		},
+	}
+
+	backend := NewMockConn()
+	okPacket := []byte{0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00}
+	if err := protocol.WritePacket(backend.ReadBuf, 1, okPacket); err != nil {
+		t.Fatalf("failed to prepare backend response: %v", err)
+	}
+	session := NewSession(NewMockConn(), cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	if err := session.handleQuery(newQueryPacket(0, "INSERT INTO orders (total_amount) VALUES (100000), (9900000)")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	sent, err := protocol.ReadPacket(backend.WriteBuf)
+	if err != nil {
+		t.Fatalf("expected INSERT to be forwarded: %v", err)
+	}
+	want := "insert into orders(total_amount, total_amount_idn) values (100000, 100.0000), (9900000, 9900.0000)"
+	if string(sent.Payload[1:]) != want {
+		t.Errorf("expected each row's own shadow value, got %q", sent.Payload[1:])
+	}
+}
+
+func TestSession_HandleQuery_GuardChecksEveryRow(t *testing.T) {
+	// fail_open would forward other failed rewrites
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, FailurePolicy: config.FailOpen,
+			Guard: config.GuardConfig{Policy: config.GuardReject}},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+				},
+			},
+		},
+	}
+
+	conn := NewMockConn()
+	backend := NewMockConn()
+	session := NewSession(conn, cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	if err := session.handleQuery(newQueryPacket(0, "INSERT INTO orders (total_amount) VALUES (100000), (150.5)")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	if backend.WriteBuf.Len() != 0 {
+		t.Errorf("expected nothing to reach the backend, got %q", backend.WriteBuf.String())
+	}
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	if _, err := protocol.ParseERRPacket(pkt.Payload); err != nil {
+		t.Fatalf("expected ERR packet: %v", err)
+	}
+}
+
+func TestSession_HandleQuery_GuardMatchesShadow(t *testing.T) {
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, FailurePolicy: config.FailOpen,
+			Guard: config.GuardConfig{Policy: config.GuardReject}},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		shadow    string // the row's current shadow value
+		forwarded bool
+	}{
+		// 1500 IDN written to the IDR column
+		{"1500.0000", false},
+		{"9.0000", true},
+	}
+
+	for _, tt := range tests {
+		conn := NewMockConn()
+		backend := NewMockConn()
+		if err := protocol.WritePacket(backend.ReadBuf, 1, protocol.EncodeOKPacket(1, 0, 0, 0)); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+		shadows := &stubShadowReader{row: []sql.NullString{{String: tt.shadow, Valid: true}}}
+		session := NewSession(conn, cfg, nil)
+		session.parser = parser.NewParser(cfg.Tables)
+		session.backendConn = NewBackendConn(backend, 1)
+		session.shadows = shadows
+
+		if err := session.handleQuery(newQueryPacket(0, "UPDATE orders SET total_amount = 1500 WHERE id = 7")); err != nil {
+			t.Fatalf("handleQuery returned error: %v", err)
+		}
+		if len(shadows.queries) != 1 || shadows.queries[0] != "select total_amount_idn from orders where id = '7' limit 1" {
+			t.Errorf("unexpected shadow lookups %q", shadows.queries)
+		}
+		// The lookup never goes through the client's backend connection
+		pkt, err := protocol.ReadPacket(backend.WriteBuf)
+		if forwarded := err == nil; forwarded != tt.forwarded {
+			t.Errorf("shadow %s: expected forwarded=%t, got %t", tt.shadow, tt.forwarded, forwarded)
+		}
+		if err == nil && !strings.HasPrefix(string(pkt.Payload[1:]), "update orders") {
+			t.Errorf("unexpected statement on the backend connection %q", pkt.Payload[1:])
+		}
+		pkt, err = protocol.ReadPacket(conn.WriteBuf)
+		if err != nil {
+			t.Fatalf("failed to read response packet: %v", err)
+		}
+		if protocol.IsERRPacket(pkt.Payload) == tt.forwarded {
+			t.Errorf("shadow %s: unexpected response %x", tt.shadow, pkt.Payload)
+		}
+	}
+}
+
+// stubShadowReader returns row for every shadow value lookup and records the
+// queries
+type stubShadowReader struct {
+	row     []sql.NullString
+	queries []string
+}
+
+func (r *stubShadowReader) readRow(ctx context.Context, query string, columns int) ([]sql.NullString, error) {
+	r.queries = append(r.queries, query)
+	return r.row, nil
+}
+
+func TestSession_HandleQuery_PlaceholderAmount(t *testing.T) {
+	// fail_open would forward other failed rewrites
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, FailurePolicy: config.FailOpen},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+				},
+			},
+		},
+	}
+	statements := []string{
+		"UPDATE orders SET total_amount = ? WHERE id = 1",
+		"UPDATE orders SET total_amount = '?' WHERE id = 1",
+	}
+
+	for _, query := range statements {
+		conn := NewMockConn()
+		backend := NewMockConn()
+		session := NewSession(conn, cfg, nil)
+		session.parser = parser.NewParser(cfg.Tables)
+		session.backendConn = NewBackendConn(backend, 1)
+
+		if err := session.handleQuery(newQueryPacket(0, query)); err != nil {
+			t.Fatalf("handleQuery returned error: %v", err)
+		}
+		if backend.WriteBuf.Len() != 0 {
+			t.Errorf("%s: expected nothing to reach the backend, got %q", query, backend.WriteBuf.String())
+		}
+		pkt, err := protocol.ReadPacket(conn.WriteBuf)
+		if err != nil {
+			t.Fatalf("failed to read response packet: %v", err)
+		}
+		errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+		if err != nil {
+			t.Fatalf("expected ERR packet: %v", err)
+		}
+		if !strings.Contains(errPkt.ErrorMessage, "parameters were not interpolated") {
+			t.Errorf("expected the error to name the placeholder, got %q", errPkt.ErrorMessage)
+		}
+	}
+
+	// The flag policy forwards the statement without dual-write
+	cfg.Conversion.PlaceholderPolicy = config.PlaceholderFlag
+	conn := NewMockConn()
+	backend := NewMockConn()
+	if err := protocol.WritePacket(backend.ReadBuf, 1, protocol.EncodeOKPacket(1, 0, 0, 0)); err != nil {
+		t.Fatalf("failed to prepare backend response: %v", err)
+	}
+	session := NewSession(conn, cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	if err := session.handleQuery(newQueryPacket(0, statements[0])); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	sent, err := protocol.ReadPacket(backend.WriteBuf)
+	if err != nil {
+		t.Fatalf("expected the statement to be forwarded: %v", err)
+	}
+	if string(sent.Payload[1:]) != statements[0] {
+		t.Errorf("expected the statement untouched, got %q", sent.Payload[1:])
+	}
+}
+
+func TestSession_HandleQuery_ReadCurrencyIDN(t *testing.T) {
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled:      true,
+				ReadCurrency: config.ReadCurrencyIDN,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+				},
+			},
+			"invoices": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM orders WHERE total_amount = 50000", "select * from orders where total_amount between 50000000 and 50000000"},
+		{"SELECT * FROM invoices WHERE total_amount = 50000", "SELECT * FROM invoices WHERE total_amount = 50000"},
+		{"SELECT * FROM orders WHERE id = 1", "SELECT * FROM orders WHERE id = 1"},
+	}
+
+	for _, tt := range tests {
+		conn := NewMockConn()
+		backend := NewMockConn()
+		if err := protocol.WritePacket(backend.ReadBuf, 1, protocol.EncodeOKPacket(0, 0, 0, 0)); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+		session := NewSession(conn, cfg, nil)
+		session.parser = parser.NewParser(cfg.Tables)
+		session.backendConn = NewBackendConn(backend, 1)
+
+		if err := session.handleQuery(newQueryPacket(0, tt.query)); err != nil {
+			t.Fatalf("handleQuery returned error: %v", err)
+		}
+		sent, err := protocol.ReadPacket(backend.WriteBuf)
+		if err != nil {
+			t.Fatalf("expected the statement to be forwarded: %v", err)
+		}
+		if string(sent.Payload[1:]) != tt.want {
+			t.Errorf("%s: expected %q to be forwarded, got %q", tt.query, tt.want, sent.Payload[1:])
+		}
+	}
+}
+
+func TestSession_HandleQuery_IDNReadResolvesTable(t *testing.T) {
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled:      true,
+				ReadCurrency: config.ReadCurrencyIDN,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		query         string
+		lowerCaseMode int
+		want          string
+	}{
+		{"SELECT * FROM shop.orders WHERE total_amount = 50000", 0, "select * from shop.orders where total_amount between 50000000 and 50000000"},
+		// Same-named table in another database
+		{"SELECT * FROM otherdb.orders WHERE total_amount = 50000", 0, "SELECT * FROM otherdb.orders WHERE total_amount = 50000"},
+		{"SELECT * FROM ORDERS WHERE total_amount = 50000", 1, "select * from ORDERS where total_amount between 50000000 and 50000000"},
+		{"SELECT * FROM ORDERS WHERE total_amount = 50000", 0, "SELECT * FROM ORDERS WHERE total_amount = 50000"},
+	}
+
+	for _, tt := range tests {
+		backend := NewMockConn()
+		if err := protocol.WritePacket(backend.ReadBuf, 1, protocol.EncodeOKPacket(0, 0, 0, 0)); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+		session := NewSession(NewMockConn(), cfg, nil)
+		session.parser = parser.NewParser(cfg.Tables)
+		session.parser.SetSchema("shop")
+		session.parser.SetCurrentDatabase("shop")
+		session.parser.SetLowerCaseTableNames(tt.lowerCaseMode)
+		session.backendConn = NewBackendConn(backend, 1)
+
+		if err := session.handleQuery(newQueryPacket(0, tt.query)); err != nil {
+			t.Fatalf("handleQuery returned error: %v", err)
+		}
+		sent, err := protocol.ReadPacket(backend.WriteBuf)
+		if err != nil {
+			t.Fatalf("expected the statement to be forwarded: %v", err)
+		}
+		if string(sent.Payload[1:]) != tt.want {
+			t.Errorf("%s (lower_case_table_names=%d): expected %q to be forwarded, got %q",
+				tt.query, tt.lowerCaseMode, tt.want, sent.Payload[1:])
+		}
+	}
+}
+
+// idnReadConfig reads orders in IDN
+func idnReadConfig() *config.Config {
+	return &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 2},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled:      true,
+				ReadCurrency: config.ReadCurrencyIDN,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", Precision: 2},
+				},
+			},
+		},
+	}
+}
+
+// shadowColumns are the column definitions MySQL returns for
+// SELECT id, total_amount_idn AS total_amount
+func shadowColumns() [][]byte {
+	return [][]byte{
+		(&protocol.ColumnDefinition41{Catalog: "def", Schema: "shop", Table: "orders", OrgTable: "orders", Name: "id", OrgName: "id",
+			CharacterSet: 63, ColumnLength: 20, Type: protocol.MYSQL_TYPE_LONGLONG}).Encode(),
+		(&protocol.ColumnDefinition41{Catalog: "def", Schema: "shop", Table: "orders", OrgTable: "orders", Name: "total_amount", OrgName: "total_amount_idn",
+			CharacterSet: 63, ColumnLength: 21, Type: protocol.MYSQL_TYPE_NEWDECIMAL, Decimals: 2}).Encode(),
+	}
+}
+
+// readColumnDefinitions reads count column definitions and their EOF
+func readColumnDefinitions(t *testing.T, buf *bytes.Buffer, count int) []*protocol.ColumnDefinition41 {
+	t.Helper()
+	var columns []*protocol.ColumnDefinition41
+	for i := 0; i < count; i++ {
+		pkt, err := protocol.ReadPacket(buf)
+		if err != nil {
+			t.Fatalf("failed to read column: %v", err)
+		}
+		col, err := protocol.DecodeColumnDefinition41(pkt.Payload)
+		if err != nil {
+			t.Fatalf("expected column definition: %v", err)
+		}
+		columns = append(columns, col)
+	}
+	if pkt, err := protocol.ReadPacket(buf); err != nil || !protocol.IsEOFPacket(pkt.Payload) {
+		t.Fatalf("expected EOF after columns")
+	}
+	return columns
+}
+
+func TestSession_HandleQuery_IDNProjection(t *testing.T) {
+	cfg := idnReadConfig()
+	conn := NewMockConn()
+	backend := NewMockConn()
+	response := append([][]byte{protocol.EncodeColumnCount(2)}, shadowColumns()...)
+	response = append(response,
+		protocol.EncodeEOFPacket(0, 0),
+		protocol.TextRow{[]byte("1"), []byte("1.50")}.Encode(),
+		protocol.EncodeEOFPacket(0, 0))
+	for seq, payload := range response {
+		if err := protocol.WritePacket(backend.ReadBuf, uint8(seq+1), payload); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+	}
+	session := NewSession(conn, cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	if err := session.handleQuery(newQueryPacket(0, "SELECT id, total_amount FROM orders WHERE total_amount = 1.5")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	sent, err := protocol.ReadPacket(backend.WriteBuf)
+	if err != nil {
+		t.Fatalf("expected the statement to be forwarded: %v", err)
+	}
+	want := "select id, total_amount_idn as total_amount from orders where total_amount between 1495 and 1505"
+	if string(sent.Payload[1:]) != want {
+		t.Errorf("expected %q to be forwarded, got %q", want, sent.Payload[1:])
+	}
+
+	if _, err := protocol.ReadPacket(conn.WriteBuf); err != nil {
+		t.Fatalf("failed to read column count: %v", err)
+	}
+	columns := readColumnDefinitions(t, conn.WriteBuf, 2)
+	if columns[1].Name != "total_amount" || columns[1].OrgName != "total_amount" {
+		t.Errorf("expected the currency column under its own name, got name %q, original name %q", columns[1].Name, columns[1].OrgName)
+	}
+	if columns[1].Type != protocol.MYSQL_TYPE_NEWDECIMAL || columns[1].Decimals != 2 || columns[0].OrgName != "id" {
+		t.Errorf("expected the rest of the column definitions untouched, got %+v, %+v", columns[0], columns[1])
+	}
+}
+
+func TestSession_HandlePrepare_IDNProjection(t *testing.T) {
+	cfg := idnReadConfig()
+	conn := NewMockConn()
+	backend := NewMockConn()
+	// Statement 7 with one parameter and two columns
+	ok := []byte{0x00, 7, 0, 0, 0, 2, 0, 1, 0, 0x00, 0, 0}
+	param := (&protocol.ColumnDefinition41{Catalog: "def", Name: "?", CharacterSet: 63, Type: protocol.MYSQL_TYPE_LONGLONG}).Encode()
+	response := [][]byte{ok, param, protocol.EncodeEOFPacket(0, 0)}
+	response = append(response, shadowColumns()...)
+	response = append(response, protocol.EncodeEOFPacket(0, 0))
+	for seq, payload := range response {
+		if err := protocol.WritePacket(backend.ReadBuf, uint8(seq+1), payload); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+	}
+	session := NewSession(conn, cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	query := "SELECT id, total_amount FROM orders WHERE id = ?"
+	prepare := &protocol.Packet{SequenceID: 0, Payload: append([]byte{protocol.COM_STMT_PREPARE}, query...)}
+	if err := session.handlePrepare(prepare); err != nil {
+		t.Fatalf("handlePrepare returned error: %v", err)
+	}
+	sent, err := protocol.ReadPacket(backend.WriteBuf)
+	if err != nil {
+		t.Fatalf("expected the statement to be forwarded: %v", err)
+	}
+	want := "select id, total_amount_idn as total_amount from orders where id = ?"
+	if string(sent.Payload[1:]) != want {
+		t.Errorf("expected %q to be prepared, got %q", want, sent.Payload[1:])
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := protocol.ReadPacket(conn.WriteBuf); err != nil {
+			t.Fatalf("failed to read prepare response: %v", err)
+		}
+	}
+	columns := readColumnDefinitions(t, conn.WriteBuf, 2)
+	if columns[1].Name != "total_amount" || columns[1].OrgName != "total_amount" {
+		t.Errorf("expected the currency column under its own name, got name %q, original name %q", columns[1].Name, columns[1].OrgName)
+	}
+	// Executing the statement renames its binary resultset's columns too
+	if len(session.preparedProjections[7]) != 1 {
+		t.Errorf("expected the projection of statement 7 to be kept, got %v", session.preparedProjections)
+	}
+}
+
+func TestSession_HandleQuery_AmountOutOfRange(t *testing.T) {
+	max := 1e15
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, FailurePolicy: config.FailClosed},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {TargetColumn: "total_amount_idn", TargetType: "DECIMAL(19,4)", Precision: 4, BoundsMax: &max},
+				},
+			},
+		},
+	}
+
+	conn := NewMockConn()
+	session := NewSession(conn, cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+
+	if err := session.handleQuery(newQueryPacket(0, "UPDATE orders SET total_amount = 5000000000000000 WHERE id = 1")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+	if err != nil {
+		t.Fatalf("expected ERR packet: %v", err)
+	}
+	if !strings.Contains(errPkt.ErrorMessage, "above the maximum") {
+		t.Errorf("error message = %q, want the exceeded bound", errPkt.ErrorMessage)
+	}
+}
+
+func TestSession_HandleQuery_MaxParseSize(t *testing.T) {
+	tables := config.TablesConfig{
+		"orders": {
+			Enabled: true,
+			Columns: map[string]config.ColumnConfig{
+				"total_amount": {TargetColumn: "total_amount_idn", TargetType: "DECIMAL(19,4)", Precision: 4},
+			},
+		},
+	}
+	okPacket := []byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00}
+	batch := "INSERT INTO orders (total_amount) VALUES (250000)" + strings.Repeat(", (250000)", 20)
+
+	tests := []struct {
+		name      string
+		policy    string
+		query     string
+		forwarded string // statement the backend receives, empty when rejected
+	}{
+		{"below limit is rewritten", config.FailClosed, "INSERT INTO orders (total_amount) VALUES (250000)",
+			"insert into orders(total_amount, total_amount_idn) values (250000, 250.0000)"},
+		{"fail_open forwards untouched", config.FailOpen, batch, batch},
+		{"fail_closed rejects", config.FailClosed, batch, ""},
+		{"unconfigured table forwards untouched", config.FailClosed,
+			"INSERT INTO audit_log (note) VALUES " + strings.Repeat("('x'), ", 20) + "('x')",
+			"INSERT INTO audit_log (note) VALUES " + strings.Repeat("('x'), ", 20) + "('x')"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Proxy:      config.ProxyConfig{MaxParseSize: 100},
+				Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, FailurePolicy: tt.policy},
+				Tables:     tables,
+			}
+			conn := NewMockConn()
+			backend := NewMockConn()
+			if err := protocol.WritePacket(backend.ReadBuf, 1, okPacket); err != nil {
+				t.Fatalf("failed to prepare backend response: %v", err)
+			}
+			session := NewSession(conn, cfg, nil)
+			session.parser = parser.NewParser(cfg.Tables)
+			session.backendConn = NewBackendConn(backend, 1)
+
+			if err := session.handleQuery(newQueryPacket(0, tt.query)); err != nil {
+				t.Fatalf("handleQuery returned error: %v", err)
+			}
+
+			pkt, err := protocol.ReadPacket(conn.WriteBuf)
+			if err != nil {
+				t.Fatalf("failed to read response packet: %v", err)
+			}
+			if tt.forwarded == "" {
+				if _, err := protocol.ParseERRPacket(pkt.Payload); err != nil {
+					t.Fatalf("expected ERR packet: %v", err)
+				}
+				if backend.WriteBuf.Len() != 0 {
+					t.Errorf("expected nothing to reach the backend, got %q", backend.WriteBuf.String())
+				}
+				return
+			}
+			sent, err := protocol.ReadPacket(backend.WriteBuf)
+			if err != nil {
+				t.Fatalf("expected statement to be forwarded: %v", err)
+			}
+			if string(sent.Payload[1:]) != tt.forwarded {
+				t.Errorf("forwarded %q, want %q", sent.Payload[1:], tt.forwarded)
+			}
+		})
+	}
+}
+
+func TestSession_HandleQuery_FailClosedRollsBackTransaction(t *testing.T) {
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, FailurePolicy: config.FailClosed},
+		Tables: config.TablesConfig{
+			"orders": {
+				Enabled: true,
+				Columns: map[string]config.ColumnConfig{
+					"total_amount": {SourceColumn: "total_amount", TargetColumn: "total_amount_idn", TargetType: "DECIMAL(6,4)", Precision: 4},
+				},
+			},
+		},
+	}
+
+	backend := NewMockConn()
+	// OK response to the proxy-issued ROLLBACK
+	if err := protocol.WritePacket(backend.ReadBuf, 1, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}); err != nil {
+		t.Fatalf("failed to prepare backend response: %v", err)
+	}
+
+	conn := NewMockConn()
+	session := NewSession(conn, cfg, nil)
+	session.parser = parser.NewParser(cfg.Tables)
+	session.backendConn = NewBackendConn(backend, 1)
+	session.inTx = true
+	session.txRewrites = 1
+
+	if err := session.handleQuery(newQueryPacket(0, "INSERT INTO orders (total_amount) VALUES (250000)")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+
+	sent, err := protocol.ReadPacket(backend.WriteBuf)
+	if err != nil {
+		t.Fatalf("expected ROLLBACK to be sent to backend: %v", err)
+	}
+	if string(sent.Payload[1:]) != "ROLLBACK" {
+		t.Errorf("expected ROLLBACK, got %q", sent.Payload[1:])
+	}
+	if session.inTx || !session.txAborted {
+		t.Errorf("expected aborted transaction state, got inTx=%v txAborted=%v", session.inTx, session.txAborted)
+	}
+
+	// The client's COMMIT must not report success
+	conn.WriteBuf.Reset()
+	if err := session.handleQuery(newQueryPacket(0, "COMMIT")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	if !protocol.IsERRPacket(pkt.Payload) {
+		t.Error("expected ERR packet for COMMIT after rollback")
+	}
+	if backend.WriteBuf.Len() != 0 {
+		t.Error("COMMIT should not be forwarded after rollback")
+	}
+}
+
+func TestSession_StmtExecuteRejectedInAbortedTransaction(t *testing.T) {
+	backend := NewMockConn()
+	client := NewMockConn()
+	session := NewSession(client, &config.Config{}, nil)
+	session.backendConn = NewBackendConn(backend, 1)
+	session.txAborted = true
+
+	// COM_STMT_EXECUTE for statement 1, no parameters
+	execute := []byte{protocol.COM_STMT_EXECUTE, 0x01, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}
+	protocol.WritePacket(client.ReadBuf, 0, execute)
+	session.handleCommands(context.Background()) // returns once the client buffer is drained
+
+	if backend.WriteBuf.Len() != 0 {
+		t.Error("COM_STMT_EXECUTE must not reach the backend inside an aborted transaction")
+	}
+	pkt, err := protocol.ReadPacket(client.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+	if err != nil {
+		t.Fatalf("expected ERR packet: %v", err)
+	}
+	if errPkt.ErrorMessage != txAbortedMessage {
+		t.Errorf("unexpected error message: %q", errPkt.ErrorMessage)
+	}
+}
+
+func TestSession_DatabaseChangesOnlyAfterOK(t *testing.T) {
+	backend := NewMockConn()
+	session := NewSession(NewMockConn(), &config.Config{}, nil)
+	session.parser = parser.NewParser(nil)
+	session.backendConn = NewBackendConn(backend, 1)
+	session.setDatabase("ecommerce_db")
+
+	unknownDB := protocol.EncodeERRPacket(1049, "42000", "Unknown database 'missing_db'")
+	okPacket := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+
+	// A rejected USE keeps the current database
+	protocol.WritePacket(backend.ReadBuf, 1, unknownDB)
+	if err := session.handleQuery(newQueryPacket(0, "USE missing_db")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	if db := session.Info().Database; db != "ecommerce_db" {
+		t.Errorf("expected database to stay ecommerce_db after failed USE, got %q", db)
+	}
+
+	// A rejected COM_INIT_DB keeps it too
+	protocol.WritePacket(backend.ReadBuf, 1, unknownDB)
+	client := session.clientConn.(*MockConn)
+	protocol.WritePacket(client.ReadBuf, 0, append([]byte{protocol.COM_INIT_DB}, "missing_db"...))
+	session.handleCommands(context.Background()) // returns once the client buffer is drained
+	if db := session.Info().Database; db != "ecommerce_db" {
+		t.Errorf("expected database to stay ecommerce_db after failed COM_INIT_DB, got %q", db)
+	}
+
+	// An accepted USE switches the database
+	protocol.WritePacket(backend.ReadBuf, 1, okPacket)
+	if err := session.handleQuery(newQueryPacket(0, "USE analytics_db")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	if db := session.Info().Database; db != "analytics_db" {
+		t.Errorf("expected database analytics_db after successful USE, got %q", db)
+	}
+}
+
+func TestServer_Sessions(t *testing.T) {
+	server := &Server{sessions: make(map[uint32]*Session)}
+
+	for _, user := range []string{"app_user", "report_user"} {
+		session := NewSession(NewMockConn(), &config.Config{}, nil)
+		session.connID = server.nextConnID.Add(1)
+		session.user = user
+		server.sessions[session.connID] = session
+	}
+	server.sessions[2].database = "ecommerce_db"
+
+	infos := server.Sessions()
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(infos))
+	}
+	if infos[0].ConnID != 1 || infos[0].User != "app_user" {
+		t.Errorf("unexpected first session: %+v", infos[0])
+	}
+	if infos[1].User != "report_user" || infos[1].Database != "ecommerce_db" {
+		t.Errorf("unexpected second session: %+v", infos[1])
+	}
+}
+
+func TestSession_XProtocolClientRejected(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+
+	// Fake backend that sends its initial handshake and records what follows
+	forwarded := make(chan int, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		protocol.WritePacket(conn, 0, protocol.NewHandshakeV10(1).Encode())
+		n, _ := io.Copy(io.Discard, conn)
+		forwarded <- int(n)
+	}()
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Host:              "127.0.0.1",
+		Port:              ln.Addr().(*net.TCPAddr).Port,
+		ConnectionTimeout: time.Second,
+	}}
+	done := make(chan error, 1)
+	go func() { done <- NewSession(proxySide, cfg, nil).Handle(context.Background()) }()
+
+	if _, err := protocol.ReadPacket(clientSide); err != nil {
+		t.Fatalf("failed to read handshake: %v", err)
+	}
+
+	// Mysqlx.Connection.CapabilitiesGet
+	if _, err := clientSide.Write([]byte{0x01, 0x00, 0x00, 0x00, 0x01}); err != nil {
+		t.Fatalf("failed to send CapabilitiesGet: %v", err)
+	}
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(clientSide, header); err != nil {
+		t.Fatalf("expected an X Protocol error, got read error: %v", err)
+	}
+	if header[4] != 0x01 {
+		t.Errorf("expected Mysqlx.Error (type 1), got type %d", header[4])
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(header)-1)
+	if _, err := io.ReadFull(clientSide, body); err != nil {
+		t.Fatalf("failed to read X Protocol error: %v", err)
+	}
+	if !bytes.Contains(body, []byte("X Protocol is not supported")) {
+		t.Errorf("unexpected error message: %q", body)
+	}
+
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "X Protocol") {
+		t.Errorf("expected Handle to fail for an X Protocol client, got %v", err)
+	}
+	if n := <-forwarded; n != 0 {
+		t.Errorf("expected nothing forwarded to the backend, got %d bytes", n)
+	}
+}
+
+func TestSession_SSLRequestGetsErrorPacket(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+
+	// Fake backend that sends its initial handshake
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		protocol.WritePacket(conn, 0, protocol.NewHandshakeV10(1).Encode())
+		io.Copy(io.Discard, conn)
+	}()
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Host:              "127.0.0.1",
+		Port:              ln.Addr().(*net.TCPAddr).Port,
+		ConnectionTimeout: time.Second,
+	}}
+	done := make(chan error, 1)
+	go func() { done <- NewSession(proxySide, cfg, nil).Handle(context.Background()) }()
+
+	if _, err := protocol.ReadPacket(clientSide); err != nil {
+		t.Fatalf("failed to read handshake: %v", err)
+	}
+
+	sslRequest := make([]byte, 32)
+	binary.LittleEndian.PutUint32(sslRequest, protocol.CLIENT_PROTOCOL_41|protocol.CLIENT_SSL)
+	if err := protocol.WritePacket(clientSide, 1, sslRequest); err != nil {
+		t.Fatalf("failed to send SSLRequest: %v", err)
+	}
+
+	pkt, err := protocol.ReadPacket(clientSide)
+	if err != nil {
+		t.Fatalf("expected an ERR packet, got read error: %v", err)
+	}
+	if !protocol.IsERRPacket(pkt.Payload) || pkt.SequenceID != 2 {
+		t.Errorf("expected ERR packet with sequence 2, got %x (seq %d)", pkt.Payload, pkt.SequenceID)
+	}
+	if err := <-done; err == nil {
+		t.Error("expected Handle to fail for an SSL request")
+	}
+}
+
+func TestSession_CachingSHA2FastAuth(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+
+	// Fake backend that accepts the password from its cache: fast auth
+	// success followed by OK, with nothing expected from the client between
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		protocol.WritePacket(conn, 0, protocol.NewHandshakeV10(1).Encode())
+		if _, err := protocol.ReadPacket(conn); err != nil {
+			return
+		}
+		protocol.WritePacket(conn, 2, []byte{0x01, 0x03})
+		protocol.WritePacket(conn, 3, protocol.EncodeOKPacket(0, 0, 0, 0))
+		io.Copy(io.Discard, conn)
+	}()
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Host:              "127.0.0.1",
+		Port:              ln.Addr().(*net.TCPAddr).Port,
+		ConnectionTimeout: time.Second,
+	}}
+	go NewSession(proxySide, cfg, nil).Handle(context.Background())
+
+	if _, err := protocol.ReadPacket(clientSide); err != nil {
+		t.Fatalf("failed to read handshake: %v", err)
+	}
+	resp := &protocol.HandshakeResponse41{
+		CapabilityFlags: protocol.CLIENT_PROTOCOL_41 | protocol.CLIENT_SECURE_CONNECTION | protocol.CLIENT_PLUGIN_AUTH,
+		MaxPacketSize:   1 << 24,
+		CharacterSet:    45,
+		Username:        "app",
+		AuthPluginName:  "caching_sha2_password",
+	}
+	if err := protocol.WritePacket(clientSide, 1, resp.Encode()); err != nil {
+		t.Fatalf("failed to send handshake response: %v", err)
+	}
+
+	clientSide.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if pkt, err := protocol.ReadPacket(clientSide); err != nil || !bytes.Equal(pkt.Payload, []byte{0x01, 0x03}) {
+		t.Fatalf("expected fast auth success, got %v (%v)", pkt, err)
+	}
+	pkt, err := protocol.ReadPacket(clientSide)
+	if err != nil {
+		t.Fatalf("expected OK after fast auth success, got read error: %v", err)
+	}
+	if !protocol.IsOKPacket(pkt.Payload) || pkt.SequenceID != 3 {
+		t.Errorf("expected OK packet with sequence 3, got %x (seq %d)", pkt.Payload, pkt.SequenceID)
+	}
+}
+
+func TestSession_MasksDeprecateEOF(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+
+	// Fake MySQL 8 backend offering CLIENT_DEPRECATE_EOF
+	received := make(chan uint32, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handshake := protocol.NewHandshakeV10(1)
+		handshake.CapabilityFlags = protocol.CLIENT_PROTOCOL_41 | protocol.CLIENT_SECURE_CONNECTION |
+			protocol.CLIENT_PLUGIN_AUTH | protocol.CLIENT_TRANSACTIONS | protocol.CLIENT_DEPRECATE_EOF
+		protocol.WritePacket(conn, 0, handshake.Encode())
+		pkt, err := protocol.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if resp, err := protocol.DecodeHandshakeResponse41(pkt.Payload); err == nil {
+			received <- resp.CapabilityFlags
+		}
+		protocol.WritePacket(conn, 2, protocol.EncodeOKPacket(0, 0, 0, 0))
+		io.Copy(io.Discard, conn)
+	}()
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Host:              "127.0.0.1",
+		Port:              ln.Addr().(*net.TCPAddr).Port,
+		ConnectionTimeout: time.Second,
+	}}
+	go NewSession(proxySide, cfg, nil).Handle(context.Background())
+
+	clientSide.SetReadDeadline(time.Now().Add(2 * time.Second))
+	pkt, err := protocol.ReadPacket(clientSide)
+	if err != nil {
+		t.Fatalf("failed to read handshake: %v", err)
+	}
+	handshake, err := protocol.DecodeHandshakeV10(pkt.Payload)
+	if err != nil {
+		t.Fatalf("failed to decode handshake: %v", err)
+	}
+	if handshake.CapabilityFlags&protocol.CLIENT_DEPRECATE_EOF != 0 {
+		t.Error("expected CLIENT_DEPRECATE_EOF to be masked from the server handshake")
+	}
+
+	// Clients such as Connector/J may ask for it regardless
+	resp := &protocol.HandshakeResponse41{
+		CapabilityFlags: protocol.CLIENT_PROTOCOL_41 | protocol.CLIENT_SECURE_CONNECTION | protocol.CLIENT_PLUGIN_AUTH |
+			protocol.CLIENT_DEPRECATE_EOF,
+		MaxPacketSize:  1 << 24,
+		CharacterSet:   45,
+		Username:       "app",
+		AuthPluginName: "mysql_native_password",
+	}
+	if err := protocol.WritePacket(clientSide, 1, resp.Encode()); err != nil {
+		t.Fatalf("failed to send handshake response: %v", err)
+	}
+
+	select {
+	case flags := <-received:
+		if flags&protocol.CLIENT_DEPRECATE_EOF != 0 {
+			t.Error("expected CLIENT_DEPRECATE_EOF to be masked from the client response")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend did not receive the handshake response")
+	}
+	if pkt, err := protocol.ReadPacket(clientSide); err != nil || !protocol.IsOKPacket(pkt.Payload) {
+		t.Errorf("expected OK after authentication, got %v (%v)", pkt, err)
+	}
+}
+
+func TestSession_LoadDataLocalInfile(t *testing.T) {
+	backend := NewMockConn()
+	client := NewMockConn()
+	session := NewSession(client, &config.Config{}, nil)
+	session.parser = parser.NewParser(nil)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	// The backend asks for the file, the client sends it and ends it with an
+	// empty packet, then the backend reports the rows loaded
+	protocol.WritePacket(backend.ReadBuf, 1, append([]byte{protocol.LOCAL_INFILE_PACKET}, "rows.csv"...))
+	protocol.WritePacket(backend.ReadBuf, 4, protocol.EncodeOKPacket(2, 0, 0, 0))
+	protocol.WritePacket(client.ReadBuf, 2, []byte("1,2\n3,4\n"))
+	protocol.WritePacket(client.ReadBuf, 3, nil)
+
+	if err := session.handleQuery(newQueryPacket(0, "LOAD DATA LOCAL INFILE 'rows.csv' INTO TABLE imports")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+
+	var forwarded [][]byte
+	for backend.WriteBuf.Len() > 0 {
+		pkt, err := protocol.ReadPacket(backend.WriteBuf)
+		if err != nil {
+			t.Fatalf("failed to read forwarded packet: %v", err)
+		}
+		forwarded = append(forwarded, pkt.Payload)
+	}
+	if len(forwarded) != 3 || string(forwarded[1]) != "1,2\n3,4\n" || len(forwarded[2]) != 0 {
+		t.Errorf("expected the query, the file and the empty packet at the backend, got %q", forwarded)
+	}
+
+	request, err := protocol.ReadPacket(client.WriteBuf)
+	if err != nil || !protocol.IsLocalInfileRequest(request.Payload) {
+		t.Fatalf("expected the LOCAL INFILE request at the client, got %v (%v)", request, err)
+	}
+	result, err := protocol.ReadPacket(client.WriteBuf)
+	if err != nil || !protocol.IsOKPacket(result.Payload) || result.SequenceID != 4 {
+		t.Errorf("expected OK with sequence 4 at the client, got %v (%v)", result, err)
+	}
+}
+
+func TestSession_StmtCloseExpectsNoResponse(t *testing.T) {
+	backend := NewMockConn()
+	client := NewMockConn()
+	session := NewSession(client, &config.Config{}, nil)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	// COM_STMT_CLOSE for statement 1 followed by a ping; only the ping is answered
+	protocol.WritePacket(client.ReadBuf, 0, []byte{protocol.COM_STMT_CLOSE, 0x01, 0x00, 0x00, 0x00})
+	protocol.WritePacket(client.ReadBuf, 0, []byte{protocol.COM_PING})
+	protocol.WritePacket(backend.ReadBuf, 1, protocol.EncodeOKPacket(0, 0, 0, 0))
+	session.handleCommands(context.Background()) // returns once the client buffer is drained
+
+	var commands []byte
+	for backend.WriteBuf.Len() > 0 {
+		pkt, err := protocol.ReadPacket(backend.WriteBuf)
+		if err != nil {
+			t.Fatalf("failed to read forwarded packet: %v", err)
+		}
+		commands = append(commands, pkt.Payload[0])
+	}
+	if !bytes.Equal(commands, []byte{protocol.COM_STMT_CLOSE, protocol.COM_PING}) {
+		t.Errorf("expected COM_STMT_CLOSE and COM_PING at the backend, got %x", commands)
+	}
+	pkt, err := protocol.ReadPacket(client.WriteBuf)
+	if err != nil || !protocol.IsOKPacket(pkt.Payload) {
+		t.Fatalf("expected the ping's OK at the client, got %v (%v)", pkt, err)
+	}
+	if client.WriteBuf.Len() != 0 {
+		t.Error("expected nothing else at the client")
+	}
+}
+
+func TestSession_CancelUnblocksWedgedBackend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	// Fake backend that accepts the connection but never sends its handshake
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Host:              "127.0.0.1",
+		Port:              ln.Addr().(*net.TCPAddr).Port,
+		ConnectionTimeout: time.Second,
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewSession(proxySide, cfg, nil).Handle(ctx) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected Handle to report the interrupted handshake")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Handle did not return after its context was cancelled")
+	}
+}
+
+func TestSession_IdleTimeout(t *testing.T) {
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+
+	cfg := &config.Config{Proxy: config.ProxyConfig{IdleTimeout: 50 * time.Millisecond}}
+	session := NewSession(proxySide, cfg, nil)
+	session.parser = parser.NewParser(nil)
+	session.backendConn = NewBackendConn(NewMockConn(), 1)
+
+	done := make(chan error, 1)
+	go func() { done <- session.handleCommands(context.Background()) }()
+
+	pkt, err := protocol.ReadPacket(clientSide)
+	if err != nil {
+		t.Fatalf("expected an ERR packet before the connection closes: %v", err)
+	}
+	errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+	if err != nil {
+		t.Fatalf("expected ERR packet: %v", err)
+	}
+	if errPkt.ErrorCode != codeInteractionTimeout {
+		t.Errorf("error code = %d, want %d", errPkt.ErrorCode, codeInteractionTimeout)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected an idle session to close cleanly, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handleCommands did not return after the idle timeout")
+	}
+}
+
+func TestSession_HandleQuery_DatabaseWriteModeForwardsUntouched(t *testing.T) {
+	backend := NewMockConn()
+	if err := protocol.WritePacket(backend.ReadBuf, 1, protocol.EncodeOKPacket(1, 0, 0x0002, 0)); err != nil {
+		t.Fatalf("failed to prepare backend response: %v", err)
+	}
+
+	tables := config.TablesConfig{
+		"orders": {Enabled: true, WriteMode: config.WriteModeDatabase, Columns: map[string]config.ColumnConfig{
+			"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+		}},
+	}
+	cfg := &config.Config{Tables: tables, Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4}}
+	session := NewSession(NewMockConn(), cfg, nil)
+	session.parser = parser.NewParser(tables)
+	session.backendConn = NewBackendConn(backend, 1)
+	session.writes = newWriteRates()
+
+	query := "INSERT INTO orders (total_amount) VALUES (15000)"
+	if err := session.handleQuery(newQueryPacket(0, query)); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	if sent := backend.WriteBuf.String(); strings.Contains(sent, "total_amount_idn") || !strings.Contains(sent, query) {
+		t.Errorf("statement reached the backend as %q, want it untouched", sent)
+	}
+	if rates := session.writes.rates(time.Now()); len(rates) != 0 {
+		t.Errorf("expected no dual-write to be counted, got %+v", rates)
+	}
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// shadowLookupTimeout bounds the read of a row's shadow values for the
+// conversion guard
+const shadowLookupTimeout = 2 * time.Second
+
+// shadowReader runs the conversion guard's shadow value lookups on a
+// connection of the proxy's own, never a client's
+type shadowReader interface {
+	// readRow returns the first row of query, nil when it has none
+	readRow(ctx context.Context, query string, columns int) ([]sql.NullString, error)
+}
+
+// dbShadowReader reads shadow values from a connection pool
+type dbShadowReader struct {
+	db *sql.DB
+}
+
+func (r dbShadowReader) readRow(ctx context.Context, query string, columns int) ([]sql.NullString, error) {
+	row := make([]sql.NullString, columns)
+	dest := make([]interface{}, columns)
+	for i := range row {
+		dest[i] = &row[i]
+	}
+	if err := r.db.QueryRowContext(ctx, query).Scan(dest...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read shadow values: %w", err)
+	}
+	return row, nil
+}
+
+// currentShadowValues reads the shadow values of the row a single-row UPDATE
+// writes, by currency column, for the conversion guard to compare its amounts
+// with. The row is read on the proxy's own connection, so it shows what is
+// committed rather than the client transaction's own writes. It returns nil
+// when the guard is off, no reader is set, the UPDATE may touch several rows
+// or the row cannot be read; NULL shadow values are left out.
+func (s *Session) currentShadowValues(pq *parser.ParsedQuery, tableConfig config.TableConfig) map[string]float64 {
+	if policy := s.config.Conversion.Guard.Policy; policy == "" || policy == config.GuardOff || s.shadows == nil {
+		return nil
+	}
+	database := s.parser.CurrentDatabase()
+	if database == "" {
+		database = s.config.Database.Database
+	}
+	query, columns, ok := pq.ShadowLookup(tableConfig, tableConfig.KeyColumn(), database)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shadowLookupTimeout)
+	defer cancel()
+	row, err := s.shadows.readRow(ctx, query, len(columns))
+	if err != nil {
+		logger.Warn("Failed to read current shadow values for the conversion guard",
+			"table", pq.TableName, "error", err, "conn_id", s.connID)
+		return nil
+	}
+	if row == nil {
+		return nil
+	}
+	values := make(map[string]float64, len(columns))
+	for i, col := range columns {
+		if !row[i].Valid {
+			continue
+		}
+		if value, err := strconv.ParseFloat(row[i].String, 64); err == nil {
+			values[col] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"strings"
+)
+
+// simulationMessage is returned for statements rejected on a simulation listener
+const simulationMessage = "TransisiDB: this endpoint is read-only (simulation); statements that modify data are rejected"
+
+// readOnlyKeywords are the leading keywords of statements allowed on a
+// simulation listener. It is an allowlist so unparsed or unknown statements
+// (DDL, CALL, LOAD DATA, ...) are rejected rather than forwarded.
+var readOnlyKeywords = map[string]bool{
+	"SELECT":   true,
+	"SHOW":     true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"EXPLAIN":  true,
+	"USE":      true,
+	"SET":      true,
+	"BEGIN":    true,
+	"START":    true,
+	"COMMIT":   true,
+	"ROLLBACK": true,
+}
+
+// isReadOnlyStatement reports whether a statement may run on a simulation listener
+func isReadOnlyStatement(query string) bool {
+	keyword := leadingKeyword(query)
+	if !readOnlyKeywords[keyword] {
+		return false
+	}
+	// SELECT ... INTO OUTFILE/DUMPFILE writes files on the server
+	if keyword == "SELECT" {
+		upper := strings.ToUpper(query)
+		return !strings.Contains(upper, "INTO OUTFILE") && !strings.Contains(upper, "INTO DUMPFILE")
+	}
+	return true
+}
+
+// leadingKeyword returns the first keyword of a statement in upper case,
+// skipping whitespace, comments and opening parentheses
+func leadingKeyword(query string) string {
+	start, end := leadingKeywordSpan(query)
+	return strings.ToUpper(query[start:end])
+}
+
+// leadingKeywordSpan returns the byte offsets of a statement's first keyword;
+// start equals end when there is none
+func leadingKeywordSpan(query string) (start, end int) {
+	pos := 0
+	for {
+		rest := strings.TrimLeft(query[pos:], " \t\r\n(")
+		pos = len(query) - len(rest)
+		switch {
+		case strings.HasPrefix(rest, "/*"):
+			stop := strings.Index(rest, "*/")
+			if stop < 0 {
+				return len(query), len(query)
+			}
+			pos += stop + 2
+		case strings.HasPrefix(rest, "--"), strings.HasPrefix(rest, "#"):
+			newline := strings.IndexByte(rest, '\n')
+			if newline < 0 {
+				return len(query), len(query)
+			}
+			pos += newline + 1
+		default:
+			n := strings.IndexFunc(rest, func(r rune) bool {
+				return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_')
+			})
+			if n < 0 {
+				n = len(rest)
+			}
+			return pos, pos + n
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+func TestIsReadOnlyStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT * FROM orders", true},
+		{"  /* app=shop */ select 1", true},
+		{"(SELECT 1) UNION (SELECT 2)", true},
+		{"-- report\nSHOW TABLES", true},
+		{"EXPLAIN SELECT * FROM orders", true},
+		{"SET NAMES utf8mb4", true},
+		{"USE shop", true},
+		{"INSERT INTO orders (total) VALUES (1)", false},
+		{"UPDATE orders SET total = 1", false},
+		{"DELETE FROM orders", false},
+		{"DROP TABLE orders", false},
+		{"CALL refresh_totals()", false},
+		{"SELECT * FROM orders INTO OUTFILE '/tmp/orders.csv'", false},
+		{"/* unterminated", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isReadOnlyStatement(tt.query); got != tt.want {
+			t.Errorf("isReadOnlyStatement(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSession_HandleQuery_SimulationRejectsWrites(t *testing.T) {
+	conn := NewMockConn()
+	session := NewSession(conn, &config.Config{}, nil)
+	session.simulation = true
+	session.listener = "simulation"
+
+	if err := session.handleQuery(newQueryPacket(0, "UPDATE orders SET total = 1")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response packet: %v", err)
+	}
+	errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+	if err != nil {
+		t.Fatalf("expected ERR packet: %v", err)
+	}
+	if errPkt.ErrorCode != 1792 || errPkt.ErrorMessage != simulationMessage {
+		t.Errorf("unexpected error: %d %q", errPkt.ErrorCode, errPkt.ErrorMessage)
+	}
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/schema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Stats is a snapshot of the proxy's runtime state for periodic collectors
+type Stats struct {
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	// Pools are the primary pool followed by the replica pools by role, each
+	// with its circuit breaker statistics
+	Pools    []PoolInfo   `json:"pools"`
+	Sessions SessionStats `json:"sessions"`
+	// SchemaCache is nil when the schema cache is disabled
+	SchemaCache *schema.CacheStats `json:"schema_cache"`
+	Probe       ProbeStatus        `json:"probe"`
+	// Conversion totals the conversion counters since the proxy started
+	Conversion map[string]float64 `json:"conversion"`
+}
+
+// SessionStats counts the active client sessions
+type SessionStats struct {
+	Active     int            `json:"active"`
+	ByUser     map[string]int `json:"by_user"`
+	ByListener map[string]int `json:"by_listener"`
+	ByRole     map[string]int `json:"by_role"`
+}
+
+// Stats aggregates pool, circuit breaker, replica, session, schema cache,
+// probe and conversion statistics into one document
+func (s *Server) Stats() Stats {
+	stats := Stats{
+		StartedAt:     s.startedAt,
+		UptimeSeconds: s.Uptime().Seconds(),
+		Pools:         s.Pools(),
+		Sessions:      sessionStats(s.Sessions()),
+		Probe:         s.Readiness(),
+	}
+	if stats.Pools == nil {
+		stats.Pools = []PoolInfo{}
+	}
+	if s.schema != nil {
+		cacheStats := s.schema.Stats()
+		stats.SchemaCache = &cacheStats
+	}
+
+	conversion, err := metrics.ConversionCounters(prometheus.DefaultGatherer)
+	if err != nil {
+		logger.Warn("Failed to gather conversion counters", "error", err)
+	}
+	stats.Conversion = conversion
+	return stats
+}
+
+// sessionStats counts sessions by user, listener and replica role. Sessions
+// on the primary count under the role "primary".
+func sessionStats(sessions []SessionInfo) SessionStats {
+	stats := SessionStats{
+		Active:     len(sessions),
+		ByUser:     make(map[string]int),
+		ByListener: make(map[string]int),
+		ByRole:     make(map[string]int),
+	}
+	for _, session := range sessions {
+		stats.ByUser[session.User]++
+		if session.Listener != "" {
+			stats.ByListener[session.Listener]++
+		}
+		role := session.Role
+		if role == "" {
+			role = "primary"
+		}
+		stats.ByRole[role]++
+	}
+	return stats
+}
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// clientWriter relays response packets to the client synchronously. Each
+// write is bounded by the proxy write timeout, so a slow client stops the
+// relay from reading the backend and TCP flow control pushes back on MySQL
+// instead of the proxy buffering the resultset.
+type clientWriter struct {
+	conn         net.Conn
+	writeTimeout time.Duration
+}
+
+// newClientWriter creates a writer for the session's client connection
+func (s *Session) newClientWriter() *clientWriter {
+	return &clientWriter{
+		conn:         s.clientConn,
+		writeTimeout: s.config.Proxy.WriteTimeout,
+	}
+}
+
+// writePacket writes one packet to the client within the write timeout
+func (w *clientWriter) writePacket(sequenceID uint8, payload []byte) error {
+	if w.writeTimeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+		defer w.conn.SetWriteDeadline(time.Time{})
+	}
+
+	n := 4 + len(payload)
+	metrics.AddBytesBuffered(n)
+	defer metrics.AddBytesBuffered(-n)
+
+	if err := protocol.WritePacket(w.conn, sequenceID, payload); err != nil {
+		return fmt.Errorf("failed to write to client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func bytesBuffered(t *testing.T) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.BytesBuffered.Write(&m); err != nil {
+		t.Fatalf("failed to read gauge: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestClientWriter_WritesThrough(t *testing.T) {
+	conn := NewMockConn()
+	session := NewSession(conn, &config.Config{}, nil)
+
+	w := session.newClientWriter()
+	row := bytes.Repeat([]byte{'x'}, 20)
+	for i := 0; i < 3; i++ {
+		if err := w.writePacket(uint8(i), row); err != nil {
+			t.Fatalf("writePacket failed: %v", err)
+		}
+		// Nothing is held back once writePacket returns
+		if conn.WriteBuf.Len() != (i+1)*(4+len(row)) {
+			t.Fatalf("packet %d was not written through", i)
+		}
+	}
+	if got := bytesBuffered(t); got != 0 {
+		t.Errorf("expected no bytes held after the writes, gauge is %v", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		pkt, err := protocol.ReadPacket(conn.WriteBuf)
+		if err != nil {
+			t.Fatalf("failed to read packet %d: %v", i, err)
+		}
+		if pkt.SequenceID != uint8(i) || !bytes.Equal(pkt.Payload, row) {
+			t.Fatalf("unexpected packet %d: seq=%d", i, pkt.SequenceID)
+		}
+	}
+}
+
+func TestClientWriter_SlowClientTimesOut(t *testing.T) {
+	// Nobody reads the client side, so the write blocks until the deadline
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+	defer proxySide.Close()
+
+	cfg := &config.Config{Proxy: config.ProxyConfig{WriteTimeout: 50 * time.Millisecond}}
+	w := NewSession(proxySide, cfg, nil).newClientWriter()
+
+	if err := w.writePacket(1, []byte("row")); err == nil {
+		t.Fatal("expected the write to time out")
+	}
+	if got := bytesBuffered(t); got != 0 {
+		t.Errorf("expected gauge to be released after the failed write, got %v", got)
+	}
+}
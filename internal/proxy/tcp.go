@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+// defaultKeepAlivePeriod is used when no keep-alive interval is configured
+const defaultKeepAlivePeriod = 30 * time.Second
+
+// keepAlivePeriod resolves the configured keep-alive interval; negative disables keep-alive
+func keepAlivePeriod(opts config.TCPConfig) time.Duration {
+	if opts.KeepAlivePeriod == 0 {
+		return defaultKeepAlivePeriod
+	}
+	return opts.KeepAlivePeriod
+}
+
+// applyTCPOptions applies keep-alive, TCP_NODELAY and buffer sizes to a connection.
+// Non-TCP connections are left untouched.
+func applyTCPOptions(conn net.Conn, opts config.TCPConfig) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	if period := keepAlivePeriod(opts); period > 0 {
+		if err := tcpConn.SetKeepAlive(true); err != nil {
+			return fmt.Errorf("failed to enable keep-alive: %w", err)
+		}
+		if err := tcpConn.SetKeepAlivePeriod(period); err != nil {
+			return fmt.Errorf("failed to set keep-alive period: %w", err)
+		}
+	} else if err := tcpConn.SetKeepAlive(false); err != nil {
+		return fmt.Errorf("failed to disable keep-alive: %w", err)
+	}
+
+	if opts.NoDelay != nil {
+		if err := tcpConn.SetNoDelay(*opts.NoDelay); err != nil {
+			return fmt.Errorf("failed to set TCP_NODELAY: %w", err)
+		}
+	}
+	if opts.ReadBufferSize > 0 {
+		if err := tcpConn.SetReadBuffer(opts.ReadBufferSize); err != nil {
+			return fmt.Errorf("failed to set SO_RCVBUF: %w", err)
+		}
+	}
+	if opts.WriteBufferSize > 0 {
+		if err := tcpConn.SetWriteBuffer(opts.WriteBufferSize); err != nil {
+			return fmt.Errorf("failed to set SO_SNDBUF: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// socketControl returns a Control hook binding new sockets to the configured interface
+func socketControl(opts config.TCPConfig) func(network, address string, c syscall.RawConn) error {
+	if opts.BindInterface == "" {
+		return nil
+	}
+
+	return func(network, address string, c syscall.RawConn) error {
+		var bindErr error
+		if err := c.Control(func(fd uintptr) {
+			bindErr = bindToDevice(fd, opts.BindInterface)
+		}); err != nil {
+			return err
+		}
+		if bindErr != nil {
+			return fmt.Errorf("failed to bind to interface %s: %w", opts.BindInterface, bindErr)
+		}
+		return nil
+	}
+}
+
+// newListenConfig builds the listener configuration for client connections
+func newListenConfig(opts config.TCPConfig) *net.ListenConfig {
+	return &net.ListenConfig{
+		// Keep-alive is applied per accepted connection in applyTCPOptions
+		KeepAlive: -1,
+		Control:   socketControl(opts),
+	}
+}
+
+// dialBackend opens a tuned connection to the backend database, over its Unix
+// socket when one is configured and through the egress proxy otherwise
+func dialBackend(cfg *config.DatabaseConfig) (net.Conn, error) {
+	if cfg.Socket != "" {
+		conn, err := net.DialTimeout("unix", cfg.Socket, cfg.ConnectionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to backend socket %s: %w", cfg.Socket, err)
+		}
+		return conn, nil
+	}
+
+	addr := net.JoinHostPort(cfg.Host, fmt.Sprintf("%d", cfg.Port))
+	dialer, err := egressDialer(cfg.EgressProxy, newTunedDialer(cfg.TCP))
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if cfg.ConnectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ConnectionTimeout)
+		defer cancel()
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to backend %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// listenUnix listens on a Unix socket path, replacing a stale socket file left
+// by a previous run. The socket is made connectable by all local users, as
+// mysqld does for its own socket; restrict access via the parent directory.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o666); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,8 @@
+package proxy
+
+import "syscall"
+
+// bindToDevice binds a socket to a network interface (SO_BINDTODEVICE)
+func bindToDevice(fd uintptr, iface string) error {
+	return syscall.BindToDevice(int(fd), iface)
+}
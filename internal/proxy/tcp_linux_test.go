@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+// sockopt reads an integer socket option from a TCP connection
+func sockopt(t *testing.T, conn net.Conn, level, opt int) int {
+	t.Helper()
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("SyscallConn failed: %v", err)
+	}
+
+	var value int
+	var optErr error
+	if err := raw.Control(func(fd uintptr) {
+		value, optErr = syscall.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		t.Fatalf("Control failed: %v", err)
+	}
+	if optErr != nil {
+		t.Fatalf("getsockopt(%d, %d) failed: %v", level, opt, optErr)
+	}
+	return value
+}
+
+func TestDialBackend_AppliesTCPOptions(t *testing.T) {
+	ln, err := newListenConfig(config.TCPConfig{}).Listen(t.Context(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	noDelay := false
+	conn, err := dialBackend(&config.DatabaseConfig{
+		Host:              "127.0.0.1",
+		Port:              addr.Port,
+		ConnectionTimeout: time.Second,
+		TCP: config.TCPConfig{
+			KeepAlivePeriod: 10 * time.Second,
+			NoDelay:         &noDelay,
+			ReadBufferSize:  256 * 1024,
+			WriteBufferSize: 256 * 1024,
+		},
+	})
+	if err != nil {
+		t.Fatalf("dialBackend failed: %v", err)
+	}
+	defer conn.Close()
+
+	if got := sockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY); got != 0 {
+		t.Errorf("expected TCP_NODELAY off, got %d", got)
+	}
+	if got := sockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); got != 1 {
+		t.Errorf("expected SO_KEEPALIVE on, got %d", got)
+	}
+	if got := sockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE); got != 10 {
+		t.Errorf("expected TCP_KEEPIDLE 10s, got %d", got)
+	}
+	// Linux doubles the requested buffer sizes for bookkeeping overhead
+	if got := sockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_RCVBUF); got < 256*1024 {
+		t.Errorf("expected SO_RCVBUF >= %d, got %d", 256*1024, got)
+	}
+	if got := sockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_SNDBUF); got < 256*1024 {
+		t.Errorf("expected SO_SNDBUF >= %d, got %d", 256*1024, got)
+	}
+}
+
+func TestApplyTCPOptions_DisablesKeepAlive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	if err := applyTCPOptions(conn, config.TCPConfig{KeepAlivePeriod: -1}); err != nil {
+		t.Fatalf("applyTCPOptions failed: %v", err)
+	}
+	if got := sockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); got != 0 {
+		t.Errorf("expected SO_KEEPALIVE off, got %d", got)
+	}
+	// Go enables TCP_NODELAY by default and it is left alone when unset
+	if got := sockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY); got != 1 {
+		t.Errorf("expected TCP_NODELAY on, got %d", got)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package proxy
+
+import "fmt"
+
+// bindToDevice is only supported on Linux
+func bindToDevice(fd uintptr, iface string) error {
+	return fmt.Errorf("binding to an interface is not supported on this platform")
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+func TestKeepAlivePeriod(t *testing.T) {
+	tests := []struct {
+		configured time.Duration
+		want       time.Duration
+	}{
+		{0, defaultKeepAlivePeriod},
+		{10 * time.Second, 10 * time.Second},
+		{-1, -1},
+	}
+
+	for _, tt := range tests {
+		if got := keepAlivePeriod(config.TCPConfig{KeepAlivePeriod: tt.configured}); got != tt.want {
+			t.Errorf("keepAlivePeriod(%v) = %v, want %v", tt.configured, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTCPOptions_NonTCPConn(t *testing.T) {
+	if err := applyTCPOptions(NewMockConn(), config.TCPConfig{ReadBufferSize: 1024}); err != nil {
+		t.Errorf("expected non-TCP connections to be ignored, got %v", err)
+	}
+	if socketControl(config.TCPConfig{}) != nil {
+		t.Error("expected no socket control hook without bind_interface")
+	}
+}
+
+func TestDialBackend_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql.sock")
+	ln, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("listenUnix failed: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Write([]byte("hi"))
+			conn.Close()
+		}
+	}()
+
+	conn, err := dialBackend(&config.DatabaseConfig{Socket: path, ConnectionTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("dialBackend failed: %v", err)
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hi" {
+		t.Errorf("expected to read from the socket, got %q (%v)", buf, err)
+	}
+}
+
+func TestListenUnix_ReplacesStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "transisidb.sock")
+
+	// A listener that exits without cleanup leaves the socket file behind
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	ln.Close()
+
+	// Regular files are never removed
+	file := filepath.Join(dir, "not-a-socket")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnix(file); err == nil {
+		t.Error("expected an error for a path that is not a socket")
+	}
+}
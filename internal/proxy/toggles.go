@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+// tableToggles are the tables whose dual-write was enabled, disabled, rolled
+// out or switched between enforce and observe at runtime. Tables without a toggle keep their config.
+type tableToggles struct {
+	mu      sync.Mutex // serializes writers
+	current atomic.Pointer[tableOverrides]
+}
+
+// tableOverrides is a snapshot of the toggles, replaced as a whole
+type tableOverrides struct {
+	enabled map[string]bool
+	rollout map[string]float64
+	mode    map[string]string
+}
+
+// newTableToggles returns an empty set of toggles
+func newTableToggles() *tableToggles {
+	t := &tableToggles{}
+	t.current.Store(&tableOverrides{enabled: map[string]bool{}, rollout: map[string]float64{}, mode: map[string]string{}})
+	return t
+}
+
+// enabled reports whether dual-write applies to a table, given its configured
+// flag. It is the parser's TableEnabledResolver and safe for concurrent use.
+func (t *tableToggles) enabled(table string, configured bool) bool {
+	if enabled, ok := t.current.Load().enabled[table]; ok {
+		return enabled
+	}
+	return configured
+}
+
+// rollout returns the table's rollout with a toggled percentage applied
+func (t *tableToggles) rollout(table string, configured *config.RolloutConfig) *config.RolloutConfig {
+	percent, ok := t.current.Load().rollout[table]
+	if !ok {
+		return configured
+	}
+	rollout := config.RolloutConfig{}
+	if configured != nil {
+		rollout = *configured
+	}
+	rollout.Percent = percent
+	return &rollout
+}
+
+// mode returns the table's mode, given its configured mode
+func (t *tableToggles) mode(table, configured string) string {
+	if mode, ok := t.current.Load().mode[table]; ok {
+		return mode
+	}
+	return configured
+}
+
+// set toggles one table
+func (t *tableToggles) set(table string, enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := t.current.Load()
+	next := make(map[string]bool, len(current.enabled)+1)
+	for name, value := range current.enabled {
+		next[name] = value
+	}
+	next[table] = enabled
+	t.current.Store(&tableOverrides{enabled: next, rollout: current.rollout, mode: current.mode})
+}
+
+// replace swaps in the toggles loaded from Redis and returns the tables whose
+// toggles changed
+func (t *tableToggles) replace(enabled map[string]bool, rollout map[string]float64, mode map[string]string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := t.current.Load()
+	changed := make(map[string]bool)
+	diffOverrides(current.enabled, enabled, changed)
+	diffOverrides(current.rollout, rollout, changed)
+	diffOverrides(current.mode, mode, changed)
+	t.current.Store(&tableOverrides{enabled: enabled, rollout: rollout, mode: mode})
+
+	tables := make([]string, 0, len(changed))
+	for table := range changed {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// diffOverrides adds the tables set differently in a and b to changed
+func diffOverrides[V comparable](a, b map[string]V, changed map[string]bool) {
+	for table, value := range b {
+		if previous, ok := a[table]; !ok || previous != value {
+			changed[table] = true
+		}
+	}
+	for table := range a {
+		if _, ok := b[table]; !ok {
+			changed[table] = true
+		}
+	}
+}
+
+// WatchTableToggles applies the tables enabled, disabled or rolled out through
+// the API. Toggles are reloaded when a config reload is published and polled
+// every proxy.table_toggle_interval, which bounds how long a change takes to
+// apply when a notification is missed.
+func (s *Server) WatchTableToggles(ctx context.Context, store *config.RedisStore) error {
+	s.mu.Lock()
+	s.store = store
+	s.mu.Unlock()
+
+	if err := s.reloadTableToggles(ctx, store); err != nil {
+		return err
+	}
+
+	interval := s.config.Proxy.TableToggleInterval
+	if interval <= 0 {
+		interval = config.DefaultTableToggleInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.reloadTableToggles(ctx, store); err != nil {
+					logger.Warn("Failed to reload table toggles, keeping current toggles", "error", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// reloadTableToggles replaces the active toggles, break glass switch and
+// config versions with the ones stored in Redis
+func (s *Server) reloadTableToggles(ctx context.Context, store *config.RedisStore) error {
+	if err := s.reloadBreakGlass(ctx, store); err != nil {
+		return err
+	}
+	if err := s.reloadConfigVersions(ctx, store); err != nil {
+		return err
+	}
+	enabled, err := store.LoadTableToggles(ctx)
+	if err != nil {
+		return err
+	}
+	rollout, err := store.LoadTableRollouts(ctx)
+	if err != nil {
+		return err
+	}
+	mode, err := store.LoadTableModes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, table := range s.toggles.replace(enabled, rollout, mode) {
+		logger.Info("Table dual-write toggle reloaded", "table", table,
+			"enabled", s.toggles.enabled(table, s.config.Tables[table].Enabled),
+			"rollout_percent", rolloutPercent(s.toggles.rollout(table, s.config.Tables[table].Rollout)),
+			"mode", tableMode(s.toggles.mode(table, s.config.Tables[table].Mode)))
+	}
+	return nil
+}
+
+// SetTableEnabled enables or disables dual-write for a table. With a Redis
+// store the toggle is saved, recorded in the audit log and published to the
+// other proxies; without one it applies to this proxy until restart.
+func (s *Server) SetTableEnabled(table string, enabled bool, actor string) error {
+	action := "disable_table"
+	if enabled {
+		action = "enable_table"
+	}
+
+	s.mu.Lock()
+	store := s.store
+	s.mu.Unlock()
+
+	if store != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := store.SetTableEnabled(ctx, table, enabled); err != nil {
+			return fmt.Errorf("failed to save table toggle: %w", err)
+		}
+		entry := config.AuditEntry{Time: time.Now(), Actor: actor, Action: action, Target: table}
+		if err := store.AppendAudit(ctx, entry); err != nil {
+			logger.Warn("Failed to record audit entry", "action", action, "table", table, "error", err)
+		}
+		if err := store.PublishReload(ctx); err != nil {
+			logger.Warn("Failed to publish reload, other proxies apply the toggle on their next poll", "error", err)
+		}
+	}
+
+	s.toggles.set(table, enabled)
+	logger.Info("Table dual-write toggled", "table", table, "enabled", enabled, "actor", actor, "persisted", store != nil)
+	return nil
+}
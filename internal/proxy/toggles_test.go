@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+func TestTableToggles(t *testing.T) {
+	toggles := newTableToggles()
+	if !toggles.enabled("orders", true) || toggles.enabled("orders", false) {
+		t.Fatal("expected the configured flag for a table without a toggle")
+	}
+
+	toggles.set("orders", false)
+	if toggles.enabled("orders", true) {
+		t.Error("expected orders to be disabled")
+	}
+
+	changed := toggles.replace(map[string]bool{"orders": false, "payments": true}, map[string]float64{"refunds": 25},
+		map[string]string{"invoices": config.TableModeObserve})
+	if strings.Join(changed, ",") != "invoices,payments,refunds" {
+		t.Errorf("changed = %v, want [invoices payments refunds]", changed)
+	}
+	if toggles.mode("invoices", "") != config.TableModeObserve || toggles.mode("orders", config.TableModeEnforce) != config.TableModeEnforce {
+		t.Error("expected the toggled mode, or the configured one without a toggle")
+	}
+	if !toggles.enabled("payments", false) {
+		t.Error("expected payments to be enabled")
+	}
+	configured := &config.RolloutConfig{Percent: 5, HashBy: config.RolloutHashPrimaryKey}
+	if rollout := toggles.rollout("refunds", configured); rollout.Percent != 25 || rollout.HashBy != config.RolloutHashPrimaryKey {
+		t.Errorf("unexpected rollout: %+v", rollout)
+	}
+	if configured.Percent != 5 {
+		t.Error("expected the configured rollout to be left unchanged")
+	}
+	if toggles.rollout("orders", nil) != nil {
+		t.Error("expected no rollout for a table without one")
+	}
+
+	changed = toggles.replace(map[string]bool{}, map[string]float64{}, map[string]string{})
+	if strings.Join(changed, ",") != "invoices,orders,payments,refunds" {
+		t.Errorf("changed = %v, want [invoices orders payments refunds]", changed)
+	}
+	if !toggles.enabled("orders", true) {
+		t.Error("expected the configured flag once the toggle is removed")
+	}
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// autocommitPattern matches a session autocommit assignment within a SET
+// statement. SET GLOBAL autocommit does not change the current session.
+var autocommitPattern = regexp.MustCompile(`(?i)(?:\bSET|,)\s*(?:(?:SESSION|LOCAL)\s+|@@(?:SESSION\.|LOCAL\.)?)?` +
+	"`?autocommit`?" + `\s*:?=\s*'?(\w+)'?`)
+
+// parseAutocommit returns the autocommit value set by a statement; ok is
+// false when the statement does not set autocommit for the session
+func parseAutocommit(query string) (enabled bool, ok bool) {
+	if leadingKeyword(query) != "SET" {
+		return false, false
+	}
+	match := autocommitPattern.FindStringSubmatch(query)
+	if match == nil {
+		return false, false
+	}
+	switch strings.ToUpper(match[1]) {
+	case "1", "ON", "TRUE":
+		return true, true
+	case "0", "OFF", "FALSE":
+		return false, true
+	}
+	return false, false
+}
+
+// transactionControl classifies explicit transaction statements. It returns
+// BEGIN for BEGIN [WORK] and START TRANSACTION, COMMIT or ROLLBACK for the
+// statements ending a transaction, and "" otherwise; ROLLBACK TO SAVEPOINT
+// keeps the transaction open. chain is set for COMMIT/ROLLBACK AND CHAIN,
+// which immediately start a new transaction.
+func transactionControl(upperQuery string) (kind string, chain bool) {
+	words := strings.Fields(strings.TrimRight(upperQuery, "; \t\r\n"))
+	if len(words) == 0 {
+		return "", false
+	}
+
+	switch words[0] {
+	case "BEGIN":
+		if len(words) == 1 || (len(words) == 2 && words[1] == "WORK") {
+			return "BEGIN", false
+		}
+	case "START":
+		if len(words) >= 2 && words[1] == "TRANSACTION" {
+			return "BEGIN", false
+		}
+	case "COMMIT", "ROLLBACK":
+		rest := words[1:]
+		if len(rest) > 0 && rest[0] == "WORK" {
+			rest = rest[1:]
+		}
+		for i, word := range rest {
+			switch word {
+			case "TO":
+				return "", false
+			case "CHAIN":
+				chain = i == 0 || rest[i-1] != "NO"
+			case "AND", "NO", "RELEASE":
+			default:
+				return "", false
+			}
+		}
+		return words[0], chain
+	}
+	return "", false
+}
+
+// implicitCommitKeywords are the leading keywords of statements that commit
+// the open transaction before they run
+var implicitCommitKeywords = map[string]bool{
+	"ALTER":    true,
+	"CREATE":   true,
+	"DROP":     true,
+	"RENAME":   true,
+	"TRUNCATE": true,
+	"GRANT":    true,
+	"REVOKE":   true,
+	"LOCK":     true,
+}
+
+// implicitStartKeywords are the leading keywords of statements that start a
+// transaction when autocommit is disabled
+var implicitStartKeywords = map[string]bool{
+	"SELECT":  true,
+	"INSERT":  true,
+	"UPDATE":  true,
+	"DELETE":  true,
+	"REPLACE": true,
+	"LOAD":    true,
+	"CALL":    true,
+	"WITH":    true,
+	"TABLE":   true,
+}
+
+// beginTransaction records that the backend connection has an open transaction
+func (s *Session) beginTransaction(reason string) {
+	s.inTx = true
+	s.txRewrites = 0
+	s.txAborted = false
+	s.backendConn.SetInTransaction(true)
+	logger.Debug("Transaction started", "conn_id", s.connID, "reason", reason)
+}
+
+// endTransaction records that the backend connection's transaction ended
+func (s *Session) endTransaction(reason string) {
+	s.inTx = false
+	s.txRewrites = 0
+	s.txAborted = false
+	s.backendConn.SetInTransaction(false)
+	logger.Debug("Transaction ended", "conn_id", s.connID, "reason", reason)
+}
+
+// trackImplicitTransaction updates the transaction state for a statement that
+// is not explicit transaction control: SET autocommit, statements with an
+// implicit commit, and the first statement after autocommit was disabled.
+// It runs before the statement is forwarded so a failing statement still
+// keeps the connection out of the pool.
+func (s *Session) trackImplicitTransaction(query string) {
+	if enabled, ok := parseAutocommit(query); ok {
+		// Enabling autocommit commits the open transaction
+		if enabled && s.inTx {
+			s.endTransaction("autocommit enabled")
+		}
+		s.autocommit = enabled
+		s.backendConn.SetAutocommit(enabled)
+		return
+	}
+
+	keyword := leadingKeyword(query)
+	if s.inTx && implicitCommitKeywords[keyword] {
+		s.endTransaction("implicit commit")
+	}
+	if !s.autocommit && !s.inTx && !s.xa && implicitStartKeywords[keyword] {
+		s.beginTransaction("autocommit disabled")
+	}
+}
+
+// xaVerb returns the verb of an XA statement (START, END, PREPARE, COMMIT,
+// ROLLBACK or RECOVER), or "" for other statements. XA BEGIN is reported as
+// START.
+func xaVerb(upperQuery string) string {
+	words := strings.Fields(upperQuery)
+	if len(words) < 2 || words[0] != "XA" {
+		return ""
+	}
+	switch verb := strings.TrimRight(words[1], ";"); verb {
+	case "START", "BEGIN":
+		return "START"
+	case "END", "PREPARE", "COMMIT", "ROLLBACK", "RECOVER":
+		return verb
+	}
+	return ""
+}
+
+// handleXA forwards an XA statement and tracks the branch lifetime. The
+// backend connection is pinned from XA START until the backend accepted XA
+// COMMIT or XA ROLLBACK, so it is never pooled with a branch attached.
+func (s *Session) handleXA(cmdPkt *protocol.Packet, verb string) error {
+	if verb == "START" {
+		s.setXA(true)
+	}
+
+	timing := &queryTiming{statement: "XA"}
+	if err := s.forwardTimed(cmdPkt, timing); err != nil {
+		return err
+	}
+
+	switch {
+	case verb == "START" && !timing.ok:
+		s.setXA(false)
+	case (verb == "COMMIT" || verb == "ROLLBACK") && timing.ok && s.xa:
+		s.setXA(false)
+	}
+	return nil
+}
+
+// setXA records whether an XA branch is open on the backend connection
+func (s *Session) setXA(open bool) {
+	s.xa = open
+	s.backendConn.SetInXA(open)
+	if open {
+		logger.Debug("XA transaction started", "conn_id", s.connID)
+	} else {
+		logger.Debug("XA transaction ended", "conn_id", s.connID)
+	}
+}
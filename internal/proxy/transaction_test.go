@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+func TestParseAutocommit(t *testing.T) {
+	tests := []struct {
+		query       string
+		wantEnabled bool
+		wantOK      bool
+	}{
+		{"SET autocommit=0", false, true},
+		{"set AUTOCOMMIT = 1", true, true},
+		{"SET SESSION autocommit = OFF", false, true},
+		{"SET @@session.autocommit=ON", true, true},
+		{"SET @@autocommit := 'false'", false, true},
+		{"SET NAMES utf8mb4, autocommit = 0", false, true},
+		{"/* app */ SET autocommit=0", false, true},
+		{"SET GLOBAL autocommit = 0", false, false},
+		{"SET @autocommit_backup = 0", false, false},
+		{"SET autocommit = @saved", false, false},
+		{"SELECT 'SET autocommit=0'", false, false},
+	}
+
+	for _, tt := range tests {
+		enabled, ok := parseAutocommit(tt.query)
+		if enabled != tt.wantEnabled || ok != tt.wantOK {
+			t.Errorf("parseAutocommit(%q) = %v, %v, want %v, %v", tt.query, enabled, ok, tt.wantEnabled, tt.wantOK)
+		}
+	}
+}
+
+func TestTransactionControl(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantKind  string
+		wantChain bool
+	}{
+		{"BEGIN", "BEGIN", false},
+		{"BEGIN WORK", "BEGIN", false},
+		{"START TRANSACTION READ ONLY", "BEGIN", false},
+		{"COMMIT;", "COMMIT", false},
+		{"COMMIT WORK AND NO CHAIN NO RELEASE", "COMMIT", false},
+		{"COMMIT AND CHAIN", "COMMIT", true},
+		{"ROLLBACK WORK", "ROLLBACK", false},
+		{"ROLLBACK TO SAVEPOINT sp1", "", false},
+		{"ROLLBACK TO sp1", "", false},
+		{"BEGIN NOT ATOMIC", "", false},
+		{"SELECT 1", "", false},
+	}
+
+	for _, tt := range tests {
+		kind, chain := transactionControl(tt.query)
+		if kind != tt.wantKind || chain != tt.wantChain {
+			t.Errorf("transactionControl(%q) = %q, %v, want %q, %v", tt.query, kind, chain, tt.wantKind, tt.wantChain)
+		}
+	}
+}
+
+func TestSession_HandleQuery_AutocommitImplicitTransaction(t *testing.T) {
+	backend := NewMockConn()
+	okPacket := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	for i := 0; i < 6; i++ {
+		if err := protocol.WritePacket(backend.ReadBuf, 1, okPacket); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+	}
+
+	session := NewSession(NewMockConn(), &config.Config{}, nil)
+	session.parser = parser.NewParser(nil)
+	session.backendConn = NewBackendConn(backend, 1)
+
+	steps := []struct {
+		query      string
+		wantInTx   bool
+		autocommit bool
+	}{
+		{"SET autocommit=0", false, false},
+		{"UPDATE orders SET status = 'paid' WHERE id = 1", true, false},
+		{"COMMIT", false, false},
+		{"DELETE FROM carts WHERE id = 2", true, false},
+		{"SET autocommit=1", false, true},
+		{"INSERT INTO audit (id) VALUES (1)", false, true},
+	}
+
+	for _, step := range steps {
+		if err := session.handleQuery(newQueryPacket(0, step.query)); err != nil {
+			t.Fatalf("handleQuery(%q) returned error: %v", step.query, err)
+		}
+		if session.inTx != step.wantInTx || session.backendConn.IsInTransaction() != step.wantInTx {
+			t.Errorf("after %q: inTx=%v backend=%v, want %v", step.query, session.inTx, session.backendConn.IsInTransaction(), step.wantInTx)
+		}
+		if session.backendConn.Autocommit() != step.autocommit {
+			t.Errorf("after %q: backend autocommit=%v, want %v", step.query, session.backendConn.Autocommit(), step.autocommit)
+		}
+	}
+}
+
+func TestXAVerb(t *testing.T) {
+	tests := map[string]string{
+		"XA START 'pay-1'":            "START",
+		"XA BEGIN 'pay-1'":            "START",
+		"XA END 'pay-1'":              "END",
+		"XA PREPARE 'pay-1'":          "PREPARE",
+		"XA COMMIT 'pay-1' ONE PHASE": "COMMIT",
+		"XA ROLLBACK 'pay-1'":         "ROLLBACK",
+		"XA RECOVER;":                 "RECOVER",
+		"XA":                          "",
+		"SELECT 'XA START'":           "",
+	}
+
+	for query, want := range tests {
+		if got := xaVerb(query); got != want {
+			t.Errorf("xaVerb(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestSession_HandleQuery_XAPinsBackendConnection(t *testing.T) {
+	backend := NewMockConn()
+	okPacket := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	xaErr := protocol.EncodeERRPacket(1399, "XAE07", "XAER_RMFAIL: The command cannot be executed when global transaction is in the  PREPARED state")
+
+	session := NewSession(NewMockConn(), &config.Config{}, nil)
+	session.parser = parser.NewParser(nil)
+	session.backendConn = NewBackendConn(backend, 1)
+	session.autocommit = false
+
+	steps := []struct {
+		query    string
+		response []byte
+		wantXA   bool
+	}{
+		{"XA START 'pay-1'", okPacket, true},
+		{"UPDATE payments SET status = 'held' WHERE id = 1", okPacket, true},
+		{"XA END 'pay-1'", okPacket, true},
+		{"XA PREPARE 'pay-1'", okPacket, true},
+		{"XA ROLLBACK 'other'", xaErr, true},
+		{"XA COMMIT 'pay-1'", okPacket, false},
+	}
+
+	for _, step := range steps {
+		if err := protocol.WritePacket(backend.ReadBuf, 1, step.response); err != nil {
+			t.Fatalf("failed to prepare backend response: %v", err)
+		}
+		if err := session.handleQuery(newQueryPacket(0, step.query)); err != nil {
+			t.Fatalf("handleQuery(%q) returned error: %v", step.query, err)
+		}
+		if session.xa != step.wantXA || session.backendConn.IsInXA() != step.wantXA {
+			t.Errorf("after %q: xa=%v backend=%v, want %v", step.query, session.xa, session.backendConn.IsInXA(), step.wantXA)
+		}
+		if session.inTx {
+			t.Errorf("after %q: XA branch must not be tracked as a local transaction", step.query)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/rules"
+)
+
+// configVersions are the config versions new sessions are created with: the
+// active version and, while one is staged, the staged version. A session
+// keeps the version it was created with until it disconnects.
+type configVersions struct {
+	mu sync.Mutex // serializes replace
+	// active is the version promoted last, nil for the config file
+	active atomic.Pointer[config.ConfigVersion]
+	staged atomic.Pointer[stagedVersion]
+}
+
+// stagedVersion is a staged config version ready for new sessions
+type stagedVersion struct {
+	*config.StagedConfig
+	nets []*net.IPNet
+	// rules are the staged query rules, nil when the version keeps the
+	// shared rules
+	rules *rules.Engine
+}
+
+// newConfigVersions returns the versions of a proxy running its config file
+func newConfigVersions() *configVersions {
+	return &configVersions{}
+}
+
+// pick returns the version a new session from addr uses, nil for the config
+// file, with the rules engine of a staged version that has its own rules and
+// whether the version is the staged one
+func (v *configVersions) pick(addr net.Addr) (*config.ConfigVersion, *rules.Engine, bool) {
+	if staged := v.staged.Load(); staged != nil && (staged.canary(addr) || rand.Float64()*100 < staged.Percent) {
+		return &staged.ConfigVersion, staged.rules, true
+	}
+	return v.active.Load(), nil, false
+}
+
+// canary reports whether addr is one of the canary addresses
+func (s *stagedVersion) canary(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, ipNet := range s.nets {
+		if ipNet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// replace swaps in the active and staged versions loaded from Redis. Versions
+// are compared by number, so they can be reloaded on every poll.
+func (v *configVersions) replace(active *config.ConfigVersion, staged *config.StagedConfig) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if versionNumber(active) != versionNumber(v.active.Load()) {
+		v.active.Store(active)
+		logger.Info("Config version activated", "version", versionNumber(active))
+	}
+
+	current := v.staged.Load()
+	switch {
+	case staged == nil && current == nil:
+	case staged == nil:
+		v.staged.Store(nil)
+		logger.Info("Staged config version removed, new sessions use the active version", "version", current.Version)
+	case current == nil || staged.Version != current.Version:
+		next, err := newStagedVersion(staged)
+		if err != nil {
+			return fmt.Errorf("invalid staged config version %d: %w", staged.Version, err)
+		}
+		v.staged.Store(next)
+		logger.Info("Config version staged", "version", staged.Version, "canary_ips", staged.CanaryIPs,
+			"percent", staged.Percent, "own_rules", next.rules != nil)
+	}
+	return nil
+}
+
+// newStagedVersion parses the canary addresses and compiles the rules of a
+// staged version
+func newStagedVersion(staged *config.StagedConfig) (*stagedVersion, error) {
+	nets, err := staged.CanaryNets()
+	if err != nil {
+		return nil, err
+	}
+	next := &stagedVersion{StagedConfig: staged, nets: nets}
+	if staged.QueryRules != nil {
+		if next.rules, err = rules.NewEngine(staged.QueryRules); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// versionNumber returns the number of a config version, 0 for the config file
+func versionNumber(v *config.ConfigVersion) int64 {
+	if v == nil {
+		return 0
+	}
+	return v.Version
+}
+
+// reloadConfigVersions applies the active and staged config versions stored
+// in Redis to new sessions
+func (s *Server) reloadConfigVersions(ctx context.Context, store *config.RedisStore) error {
+	active, err := store.LoadActiveVersion(ctx)
+	if err != nil {
+		return err
+	}
+	staged, err := store.LoadStagedConfig(ctx)
+	if err != nil {
+		return err
+	}
+	return s.versions.replace(active, staged)
+}
+
+// sessionConfig returns the config and rules of a new session from addr on
+// ln, with the number of the config version they come from and whether it is
+// the staged version
+func (s *Server) sessionConfig(ln *clientListener, addr net.Addr) (*config.Config, *rules.Engine, int64, bool) {
+	v, engine, staged := s.versions.pick(addr)
+	metrics.RecordConfigVersionSession(staged)
+	if engine == nil {
+		engine = s.rules
+	}
+	if v == nil {
+		return ln.config, engine, 0, false
+	}
+	return v.Apply(s.config).ForListener(ln.endpoint), engine, v.Version, staged
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/rules"
+)
+
+func TestConfigVersions_Pick(t *testing.T) {
+	v := newConfigVersions()
+	canary := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 50000}
+	other := &net.TCPAddr{IP: net.ParseIP("10.0.0.6"), Port: 50000}
+
+	if version, engine, staged := v.pick(canary); version != nil || engine != nil || staged {
+		t.Fatalf("expected the config file, got version %v staged %v", version, staged)
+	}
+
+	active := &config.ConfigVersion{Version: 1}
+	staged := &config.StagedConfig{
+		ConfigVersion: config.ConfigVersion{Version: 2, QueryRules: []config.QueryRule{{ID: 1, Active: true, Action: config.RuleActionBlock, MatchDigest: "DELETE FROM orders"}}},
+		CanaryIPs:     []string{"10.0.0.4/31"},
+	}
+	if err := v.replace(active, staged); err != nil {
+		t.Fatalf("replace returned error: %v", err)
+	}
+
+	version, engine, isStaged := v.pick(canary)
+	if version.Version != 2 || !isStaged || engine == nil || engine.Len() != 1 {
+		t.Errorf("canary session: got version %d staged %v, want the staged version with its rules", version.Version, isStaged)
+	}
+	version, engine, isStaged = v.pick(other)
+	if version.Version != 1 || isStaged || engine != nil {
+		t.Errorf("other session: got version %d staged %v, want the active version", version.Version, isStaged)
+	}
+	if version, _, isStaged := v.pick(&net.UnixAddr{Name: "/tmp/transisidb.sock", Net: "unix"}); version.Version != 1 || isStaged {
+		t.Errorf("socket session: got version %d staged %v, want the active version", version.Version, isStaged)
+	}
+
+	// Every new session takes a staged version at 100 percent
+	if err := v.replace(active, &config.StagedConfig{ConfigVersion: config.ConfigVersion{Version: 3}, Percent: 100}); err != nil {
+		t.Fatalf("replace returned error: %v", err)
+	}
+	if version, engine, isStaged := v.pick(other); version.Version != 3 || !isStaged || engine != nil {
+		t.Errorf("got version %d staged %v, want version 3 with the shared rules", version.Version, isStaged)
+	}
+
+	// Reloading the same versions keeps them; aborting removes the staged one
+	previous := v.staged.Load()
+	if err := v.replace(active, &config.StagedConfig{ConfigVersion: config.ConfigVersion{Version: 3}, Percent: 100}); err != nil {
+		t.Fatalf("replace returned error: %v", err)
+	}
+	if v.staged.Load() != previous {
+		t.Error("expected reloading the same staged version to keep it")
+	}
+	if err := v.replace(active, nil); err != nil {
+		t.Fatalf("replace returned error: %v", err)
+	}
+	if version, _, isStaged := v.pick(other); version.Version != 1 || isStaged {
+		t.Errorf("got version %d staged %v after abort, want the active version", version.Version, isStaged)
+	}
+}
+
+func TestServer_SessionConfig(t *testing.T) {
+	engine, _ := rules.NewEngine(nil)
+	cfg := &config.Config{
+		Proxy:      config.ProxyConfig{Port: 3308},
+		Conversion: config.ConversionConfig{Ratio: 1000, FailurePolicy: config.FailOpen},
+		Tables:     config.TablesConfig{"orders": {Enabled: true}},
+	}
+	s := &Server{config: cfg, rules: engine, versions: newConfigVersions()}
+	endpoint := config.ListenerConfig{Name: "batch", FailurePolicy: config.FailClosed}
+	ln := &clientListener{name: "batch", endpoint: endpoint, config: cfg.ForListener(endpoint)}
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 50000}
+
+	got, gotRules, version, staged := s.sessionConfig(ln, addr)
+	if got != ln.config || gotRules != engine || version != 0 || staged {
+		t.Fatalf("expected the listener's config file settings, got version %d staged %v", version, staged)
+	}
+
+	err := s.versions.replace(nil, &config.StagedConfig{
+		ConfigVersion: config.ConfigVersion{
+			Version:    4,
+			Conversion: config.ConversionConfig{Ratio: 100, FailurePolicy: config.FailOpen},
+			Tables:     config.TablesConfig{"invoices": {Enabled: true}},
+		},
+		CanaryIPs: []string{"10.0.0.5"},
+	})
+	if err != nil {
+		t.Fatalf("replace returned error: %v", err)
+	}
+	got, gotRules, version, staged = s.sessionConfig(ln, addr)
+	if version != 4 || !staged || gotRules != engine {
+		t.Fatalf("got version %d staged %v, want staged version 4 with the shared rules", version, staged)
+	}
+	if got.Conversion.Ratio != 100 || !got.Tables["invoices"].Enabled || got.Proxy.Port != 3308 {
+		t.Errorf("session config does not carry the staged version: %+v", got)
+	}
+	if got.Conversion.FailurePolicy != config.FailClosed {
+		t.Errorf("failure policy = %q, want the listener's %q", got.Conversion.FailurePolicy, config.FailClosed)
+	}
+}
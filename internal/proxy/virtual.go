@@ -0,0 +1,703 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+	"github.com/xwb1989/sqlparser"
+)
+
+// virtualSchema is the schema of the tables the proxy answers itself
+const virtualSchema = "transisidb"
+
+// virtualTable is a snapshot of proxy state in the shape of a table
+type virtualTable struct {
+	columns []*protocol.ColumnDefinition41
+	rows    []protocol.TextRow
+}
+
+// virtualTables builds the tables of the virtual schema by name
+var virtualTables = map[string]func(*Session) virtualTable{
+	"sessions":      (*Session).sessionsTable,
+	"pool_stats":    (*Session).poolStatsTable,
+	"table_configs": (*Session).tableConfigsTable,
+	"break_glass":   (*Session).breakGlassTable,
+}
+
+// isVirtualSelect is a cheap check for statements that may read the virtual
+// schema, so that only those are parsed
+func isVirtualSelect(upperQuery string) bool {
+	return strings.HasPrefix(upperQuery, "SELECT") && strings.Contains(upperQuery, strings.ToUpper(virtualSchema)+".")
+}
+
+// handleVirtualSelect answers a SELECT on a single table of the virtual
+// schema. It supports column lists, WHERE with comparisons, LIKE, IN and IS
+// NULL, ORDER BY and LIMIT. It reports false for other statements.
+func (s *Session) handleVirtualSelect(sequenceID uint8, query string) (bool, error) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return false, nil
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || len(sel.From) != 1 {
+		return false, nil
+	}
+	aliased, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return false, nil
+	}
+	tableName, ok := aliased.Expr.(sqlparser.TableName)
+	if !ok || !strings.EqualFold(tableName.Qualifier.String(), virtualSchema) {
+		return false, nil
+	}
+
+	build, exists := virtualTables[strings.ToLower(tableName.Name.String())]
+	if !exists {
+		message := fmt.Sprintf("Table '%s.%s' doesn't exist", virtualSchema, tableName.Name.String())
+		return true, s.writeError(sequenceID, codeNoSuchTable, "42S02", message)
+	}
+
+	columns, rows, err := build(s).query(sel)
+	if err != nil {
+		return true, s.writeVirtualError(sequenceID, err)
+	}
+	return true, s.writeResultset(sequenceID, columns, rows)
+}
+
+// isVirtualUpdate is the cheap check of isVirtualSelect for UPDATE
+func isVirtualUpdate(upperQuery string) bool {
+	return strings.HasPrefix(upperQuery, "UPDATE") && strings.Contains(upperQuery, strings.ToUpper(virtualSchema)+".")
+}
+
+// handleVirtualUpdate answers
+//
+//	UPDATE transisidb.table_configs SET enabled = 0 WHERE table_name = 'orders'
+//
+// which disables or enables dual-write for a table without a config push, and
+//
+//	UPDATE transisidb.break_glass SET active = 1, minutes = 30, reason = '...'
+//
+// which turns break glass on or off. Only proxy.admin_users may run them. It
+// reports false for other statements.
+func (s *Session) handleVirtualUpdate(sequenceID uint8, query string) (bool, error) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return false, nil
+	}
+	upd, ok := stmt.(*sqlparser.Update)
+	if !ok || len(upd.TableExprs) != 1 {
+		return false, nil
+	}
+	aliased, ok := upd.TableExprs[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return false, nil
+	}
+	tableName, ok := aliased.Expr.(sqlparser.TableName)
+	if !ok || !strings.EqualFold(tableName.Qualifier.String(), virtualSchema) {
+		return false, nil
+	}
+
+	name := strings.ToLower(tableName.Name.String())
+	if _, exists := virtualTables[name]; !exists {
+		message := fmt.Sprintf("Table '%s.%s' doesn't exist", virtualSchema, tableName.Name.String())
+		return true, s.writeError(sequenceID, codeNoSuchTable, "42S02", message)
+	}
+	if name != "table_configs" && name != "break_glass" {
+		message := fmt.Sprintf("The target table %s of the UPDATE is not updatable", tableName.Name.String())
+		return true, s.writeError(sequenceID, codeNonUpdatableTable, "HY000", message)
+	}
+	if !s.config.Proxy.IsAdminUser(s.user) {
+		return true, s.writeError(sequenceID, codeAccessDenied, "42000",
+			"Access denied; user must be listed in proxy.admin_users to change TransisiDB state")
+	}
+	if name == "break_glass" {
+		return true, s.updateBreakGlass(sequenceID, upd)
+	}
+
+	enabled, table, err := parseTableToggle(upd)
+	if err != nil {
+		return true, s.writeVirtualError(sequenceID, err)
+	}
+
+	// Affected rows are the table's rows in table_configs, one per column
+	var affected uint64
+	tableConfig, exists := s.config.Tables[table]
+	if exists && s.tableEnabledNow(table, tableConfig) != enabled {
+		actor := fmt.Sprintf("mysql:%s@%s", s.user, s.clientConn.RemoteAddr())
+		if err := s.admin.SetTableEnabled(table, enabled, actor); err != nil {
+			return true, s.writeError(sequenceID, codeAdminFailed, "HY000", "TransisiDB: "+err.Error())
+		}
+		affected = uint64(len(tableConfig.Columns))
+	}
+	return true, s.writeLocal(sequenceID, protocol.EncodeOKPacket(affected, 0, s.statusFlags(), 0))
+}
+
+// parseTableToggle returns the flag and table of
+// SET enabled = <0|1> WHERE table_name = '<table>'
+func parseTableToggle(upd *sqlparser.Update) (bool, string, error) {
+	usage := "UPDATE transisidb.table_configs SET enabled = 0|1 WHERE table_name = '<table>'"
+	if len(upd.Exprs) != 1 || !upd.Exprs[0].Name.Name.EqualString("enabled") || upd.Where == nil ||
+		len(upd.OrderBy) > 0 || upd.Limit != nil {
+		return false, "", notSupported("updates other than " + usage)
+	}
+
+	enabled, ok := parseFlag(upd.Exprs[0].Expr)
+	if !ok {
+		return false, "", notSupported("updates other than " + usage)
+	}
+
+	cmp, ok := upd.Where.Expr.(*sqlparser.ComparisonExpr)
+	if !ok || cmp.Operator != sqlparser.EqualStr {
+		return false, "", notSupported("updates other than " + usage)
+	}
+	col, ok := cmp.Left.(*sqlparser.ColName)
+	val, isVal := cmp.Right.(*sqlparser.SQLVal)
+	if !ok || !col.Name.EqualString("table_name") || !isVal || val.Type != sqlparser.StrVal {
+		return false, "", notSupported("updates other than " + usage)
+	}
+	return enabled, string(val.Val), nil
+}
+
+// parseFlag returns the value of a 0, 1, TRUE or FALSE literal
+func parseFlag(expr sqlparser.Expr) (bool, bool) {
+	switch value := expr.(type) {
+	case sqlparser.BoolVal:
+		return bool(value), true
+	case *sqlparser.SQLVal:
+		switch string(value.Val) {
+		case "0":
+			return false, true
+		case "1":
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// updateBreakGlass turns break glass on or off for every proxy
+func (s *Session) updateBreakGlass(sequenceID uint8, upd *sqlparser.Update) error {
+	active, minutes, reason, err := parseBreakGlass(upd)
+	if err != nil {
+		return s.writeVirtualError(sequenceID, err)
+	}
+
+	actor := fmt.Sprintf("mysql:%s@%s", s.user, s.clientConn.RemoteAddr())
+	var state *config.BreakGlass
+	if active {
+		state, err = config.NewBreakGlass(actor, reason, time.Duration(minutes)*time.Minute)
+		if err != nil {
+			return s.writeError(sequenceID, codeWrongArguments, "HY000", "TransisiDB: "+err.Error())
+		}
+	} else if !s.breakGlass.active() {
+		return s.writeLocal(sequenceID, protocol.EncodeOKPacket(0, 0, s.statusFlags(), 0))
+	}
+	if err := s.admin.SetBreakGlass(state, actor); err != nil {
+		return s.writeError(sequenceID, codeAdminFailed, "HY000", "TransisiDB: "+err.Error())
+	}
+	return s.writeLocal(sequenceID, protocol.EncodeOKPacket(1, 0, s.statusFlags(), 0))
+}
+
+// parseBreakGlass returns the values of
+// SET active = <0|1>[, minutes = <n>][, reason = '<reason>']
+func parseBreakGlass(upd *sqlparser.Update) (bool, int, string, error) {
+	usage := "UPDATE transisidb.break_glass SET active = 0|1[, minutes = <n>][, reason = '<reason>']"
+	if upd.Where != nil || len(upd.OrderBy) > 0 || upd.Limit != nil {
+		return false, 0, "", notSupported("updates other than " + usage)
+	}
+
+	var active, set bool
+	var minutes int
+	var reason string
+	for _, expr := range upd.Exprs {
+		val, isVal := expr.Expr.(*sqlparser.SQLVal)
+		switch {
+		case expr.Name.Name.EqualString("active"):
+			var ok bool
+			if active, ok = parseFlag(expr.Expr); !ok {
+				return false, 0, "", notSupported("updates other than " + usage)
+			}
+			set = true
+		case expr.Name.Name.EqualString("minutes") && isVal && val.Type == sqlparser.IntVal:
+			n, err := strconv.Atoi(string(val.Val))
+			if err != nil {
+				return false, 0, "", notSupported("updates other than " + usage)
+			}
+			minutes = n
+		case expr.Name.Name.EqualString("reason") && isVal && val.Type == sqlparser.StrVal:
+			reason = string(val.Val)
+		default:
+			return false, 0, "", notSupported("updates other than " + usage)
+		}
+	}
+	if !set {
+		return false, 0, "", notSupported("updates other than " + usage)
+	}
+	return active, minutes, reason, nil
+}
+
+// tableEnabledNow returns whether dual-write currently applies to a table
+func (s *Session) tableEnabledNow(table string, tableConfig config.TableConfig) bool {
+	if s.toggles != nil {
+		return s.toggles.enabled(table, tableConfig.Enabled)
+	}
+	return tableConfig.Enabled
+}
+
+// writeVirtualError sends the ERR packet of a failed virtual table statement
+func (s *Session) writeVirtualError(sequenceID uint8, err error) error {
+	var perr proxyError
+	if errors.As(err, &perr) {
+		return s.writeError(sequenceID, perr.code, perr.sqlState, perr.message)
+	}
+	return err
+}
+
+// sessionsTable lists the proxy's client sessions
+func (s *Session) sessionsTable() virtualTable {
+	t := virtualTable{columns: []*protocol.ColumnDefinition41{
+		localColumn("conn_id", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("user", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("host", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("db", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("listener", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("role", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("connected_at", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("time", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("queries", protocol.MYSQL_TYPE_LONGLONG),
+	}}
+	for _, info := range s.admin.Sessions() {
+		t.rows = append(t.rows, protocol.TextRow{
+			[]byte(strconv.FormatUint(uint64(info.ConnID), 10)),
+			[]byte(info.User),
+			[]byte(info.RemoteAddr),
+			nullIfEmpty(info.Database),
+			[]byte(info.Listener),
+			nullIfEmpty(info.Role),
+			[]byte(info.ConnectedAt.Format("2006-01-02 15:04:05")),
+			[]byte(strconv.FormatInt(int64(time.Since(info.ConnectedAt).Seconds()), 10)),
+			[]byte(strconv.FormatUint(info.Queries, 10)),
+		})
+	}
+	return t
+}
+
+// poolStatsTable lists the primary and replica pools
+func (s *Session) poolStatsTable() virtualTable {
+	t := virtualTable{columns: []*protocol.ColumnDefinition41{
+		localColumn("role", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("backend", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("pool_capacity", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("current_idle", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("current_active", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("total_created", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("total_acquired", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("total_released", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("total_evicted", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("circuit_breaker", protocol.MYSQL_TYPE_VAR_STRING),
+	}}
+	for _, info := range s.admin.Pools() {
+		stat := func(name string) []byte {
+			if value, ok := info.Stats[name]; ok {
+				return []byte(fmt.Sprint(value))
+			}
+			return nil
+		}
+		var breaker []byte
+		if cb, ok := info.Stats["circuit_breaker"].(map[string]interface{}); ok {
+			breaker = []byte(fmt.Sprint(cb["state"]))
+		}
+		t.rows = append(t.rows, protocol.TextRow{
+			[]byte(info.Role),
+			[]byte(info.Backend),
+			stat("pool_capacity"),
+			stat("current_idle"),
+			stat("current_active"),
+			stat("total_created"),
+			stat("total_acquired"),
+			stat("total_released"),
+			stat("total_evicted"),
+			breaker,
+		})
+	}
+	return t
+}
+
+// tableConfigsTable lists the dual-write columns of the session's listener,
+// one row per column
+func (s *Session) tableConfigsTable() virtualTable {
+	t := virtualTable{columns: []*protocol.ColumnDefinition41{
+		localColumn("table_name", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("enabled", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("rollout_percent", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("mode", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("failure_policy", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("column_name", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("target_column", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("source_type", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("target_type", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("rounding_strategy", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("precision", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("null_policy", protocol.MYSQL_TYPE_VAR_STRING),
+	}}
+
+	tables := make([]string, 0, len(s.config.Tables))
+	for name := range s.config.Tables {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+
+	for _, name := range tables {
+		tc := s.config.Tables[name]
+		enabled := "0"
+		if s.tableEnabledNow(name, tc) {
+			enabled = "1"
+		}
+		rollout := tc.Rollout
+		if s.toggles != nil {
+			rollout = s.toggles.rollout(name, rollout)
+		}
+		percent := strconv.FormatFloat(rolloutPercent(rollout), 'f', -1, 64)
+		mode := tc.Mode
+		if s.toggles != nil {
+			mode = s.toggles.mode(name, mode)
+		}
+
+		columns := make([]string, 0, len(tc.Columns))
+		for column := range tc.Columns {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		for _, column := range columns {
+			cc := tc.Columns[column]
+			t.rows = append(t.rows, protocol.TextRow{
+				[]byte(name),
+				[]byte(enabled),
+				[]byte(percent),
+				[]byte(tableMode(mode)),
+				[]byte(s.config.FailurePolicyFor(name, config.FailOpen)),
+				[]byte(column),
+				nullIfEmpty(cc.TargetColumn),
+				nullIfEmpty(cc.SourceType),
+				nullIfEmpty(cc.TargetType),
+				nullIfEmpty(cc.RoundingStrategy),
+				[]byte(strconv.Itoa(cc.Precision)),
+				nullIfEmpty(cc.NullPolicy),
+			})
+		}
+	}
+	return t
+}
+
+// breakGlassTable has one row with the break glass switch
+func (s *Session) breakGlassTable() virtualTable {
+	t := virtualTable{columns: []*protocol.ColumnDefinition41{
+		localColumn("active", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("minutes", protocol.MYSQL_TYPE_LONGLONG),
+		localColumn("reason", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("actor", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("started_at", protocol.MYSQL_TYPE_VAR_STRING),
+		localColumn("expires_at", protocol.MYSQL_TYPE_VAR_STRING),
+	}}
+	state := s.breakGlass.state()
+	if state == nil {
+		t.rows = append(t.rows, protocol.TextRow{[]byte("0"), nil, nil, nil, nil, nil})
+		return t
+	}
+	minutes := int64(state.Expires.Sub(state.Started) / time.Minute)
+	t.rows = append(t.rows, protocol.TextRow{
+		[]byte("1"),
+		[]byte(strconv.FormatInt(minutes, 10)),
+		[]byte(state.Reason),
+		[]byte(state.Actor),
+		[]byte(state.Started.Local().Format("2006-01-02 15:04:05")),
+		[]byte(state.Expires.Local().Format("2006-01-02 15:04:05")),
+	})
+	return t
+}
+
+// notSupported reports a part of a statement the virtual tables cannot answer
+func notSupported(what string) error {
+	return proxyError{codeNotSupportedYet, "42000", "TransisiDB: virtual tables do not support " + what}
+}
+
+// column returns the index of the named column
+func (t virtualTable) column(col *sqlparser.ColName) (int, error) {
+	for i, c := range t.columns {
+		if col.Name.EqualString(c.Name) {
+			return i, nil
+		}
+	}
+	return 0, proxyError{codeBadField, "42S22", fmt.Sprintf("Unknown column '%s'", col.Name.String())}
+}
+
+// query runs a SELECT against the table
+func (t virtualTable) query(sel *sqlparser.Select) ([]*protocol.ColumnDefinition41, []protocol.TextRow, error) {
+	if sel.Distinct != "" || len(sel.GroupBy) > 0 || sel.Having != nil {
+		return nil, nil, notSupported("DISTINCT, GROUP BY or HAVING")
+	}
+
+	// Projection
+	var columns []*protocol.ColumnDefinition41
+	var indexes []int
+	for _, expr := range sel.SelectExprs {
+		switch e := expr.(type) {
+		case *sqlparser.StarExpr:
+			for i, c := range t.columns {
+				columns = append(columns, c)
+				indexes = append(indexes, i)
+			}
+		case *sqlparser.AliasedExpr:
+			col, ok := e.Expr.(*sqlparser.ColName)
+			if !ok {
+				return nil, nil, notSupported("expressions in the select list")
+			}
+			i, err := t.column(col)
+			if err != nil {
+				return nil, nil, err
+			}
+			c := *t.columns[i]
+			if !e.As.IsEmpty() {
+				c.Name = e.As.String()
+			}
+			columns = append(columns, &c)
+			indexes = append(indexes, i)
+		default:
+			return nil, nil, notSupported("this select list")
+		}
+	}
+
+	// Filter
+	rows := make([]protocol.TextRow, 0, len(t.rows))
+	for _, row := range t.rows {
+		if sel.Where != nil {
+			match, err := t.match(sel.Where.Expr, row)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	// Order
+	for i := len(sel.OrderBy) - 1; i >= 0; i-- {
+		order := sel.OrderBy[i]
+		col, ok := order.Expr.(*sqlparser.ColName)
+		if !ok {
+			return nil, nil, notSupported("ORDER BY expressions")
+		}
+		index, err := t.column(col)
+		if err != nil {
+			return nil, nil, err
+		}
+		desc := order.Direction == sqlparser.DescScr
+		sort.SliceStable(rows, func(a, b int) bool {
+			if desc {
+				return compareValues(rows[b][index], rows[a][index]) < 0
+			}
+			return compareValues(rows[a][index], rows[b][index]) < 0
+		})
+	}
+
+	// Limit
+	if sel.Limit != nil {
+		offset, err := limitValue(sel.Limit.Offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		count, err := limitValue(sel.Limit.Rowcount)
+		if err != nil {
+			return nil, nil, err
+		}
+		if offset > len(rows) {
+			offset = len(rows)
+		}
+		rows = rows[offset:]
+		if sel.Limit.Rowcount != nil && count < len(rows) {
+			rows = rows[:count]
+		}
+	}
+
+	projected := make([]protocol.TextRow, 0, len(rows))
+	for _, row := range rows {
+		out := make(protocol.TextRow, len(indexes))
+		for i, index := range indexes {
+			out[i] = row[index]
+		}
+		projected = append(projected, out)
+	}
+	return columns, projected, nil
+}
+
+// match evaluates a WHERE condition against a row. Comparisons with NULL are
+// false.
+func (t virtualTable) match(expr sqlparser.Expr, row protocol.TextRow) (bool, error) {
+	switch e := expr.(type) {
+	case *sqlparser.AndExpr:
+		left, err := t.match(e.Left, row)
+		if err != nil || !left {
+			return false, err
+		}
+		return t.match(e.Right, row)
+	case *sqlparser.OrExpr:
+		left, err := t.match(e.Left, row)
+		if err != nil || left {
+			return left, err
+		}
+		return t.match(e.Right, row)
+	case *sqlparser.NotExpr:
+		inner, err := t.match(e.Expr, row)
+		return !inner, err
+	case *sqlparser.ParenExpr:
+		return t.match(e.Expr, row)
+	case *sqlparser.IsExpr:
+		value, err := t.value(e.Expr, row)
+		if err != nil {
+			return false, err
+		}
+		switch e.Operator {
+		case sqlparser.IsNullStr:
+			return value == nil, nil
+		case sqlparser.IsNotNullStr:
+			return value != nil, nil
+		}
+		return false, notSupported("IS " + strings.ToUpper(e.Operator))
+	case *sqlparser.ComparisonExpr:
+		return t.compare(e, row)
+	}
+	return false, notSupported("this WHERE condition")
+}
+
+// compare evaluates a comparison against a row
+func (t virtualTable) compare(e *sqlparser.ComparisonExpr, row protocol.TextRow) (bool, error) {
+	left, err := t.value(e.Left, row)
+	if err != nil {
+		return false, err
+	}
+
+	switch e.Operator {
+	case sqlparser.InStr, sqlparser.NotInStr:
+		tuple, ok := e.Right.(sqlparser.ValTuple)
+		if !ok {
+			return false, notSupported("IN with a subquery")
+		}
+		if left == nil {
+			return false, nil
+		}
+		found := false
+		for _, item := range tuple {
+			value, err := t.value(item, row)
+			if err != nil {
+				return false, err
+			}
+			if value != nil && compareValues(left, value) == 0 {
+				found = true
+				break
+			}
+		}
+		return found == (e.Operator == sqlparser.InStr), nil
+	}
+
+	right, err := t.value(e.Right, row)
+	if err != nil {
+		return false, err
+	}
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	switch e.Operator {
+	case sqlparser.EqualStr:
+		return compareValues(left, right) == 0, nil
+	case sqlparser.NotEqualStr:
+		return compareValues(left, right) != 0, nil
+	case sqlparser.LessThanStr:
+		return compareValues(left, right) < 0, nil
+	case sqlparser.LessEqualStr:
+		return compareValues(left, right) <= 0, nil
+	case sqlparser.GreaterThanStr:
+		return compareValues(left, right) > 0, nil
+	case sqlparser.GreaterEqualStr:
+		return compareValues(left, right) >= 0, nil
+	case sqlparser.LikeStr:
+		return likeMatch(string(right), string(left)), nil
+	case sqlparser.NotLikeStr:
+		return !likeMatch(string(right), string(left)), nil
+	}
+	return false, notSupported("the " + strings.ToUpper(e.Operator) + " operator")
+}
+
+// value returns the text value of a column or literal, nil for NULL
+func (t virtualTable) value(expr sqlparser.Expr, row protocol.TextRow) ([]byte, error) {
+	switch e := expr.(type) {
+	case *sqlparser.ColName:
+		i, err := t.column(e)
+		if err != nil {
+			return nil, err
+		}
+		return row[i], nil
+	case *sqlparser.SQLVal:
+		switch e.Type {
+		case sqlparser.StrVal, sqlparser.IntVal, sqlparser.FloatVal:
+			return e.Val, nil
+		}
+	case *sqlparser.NullVal:
+		return nil, nil
+	case sqlparser.BoolVal:
+		if e {
+			return []byte("1"), nil
+		}
+		return []byte("0"), nil
+	}
+	return nil, notSupported("expressions other than columns and literals")
+}
+
+// compareValues orders two values, numerically when both are numbers and
+// case-insensitively otherwise. NULL sorts first.
+func compareValues(a, b []byte) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	x, errA := strconv.ParseFloat(string(a), 64)
+	y, errB := strconv.ParseFloat(string(b), 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(string(a)), strings.ToLower(string(b)))
+}
+
+// limitValue returns the integer of a LIMIT clause, 0 when absent
+func limitValue(expr sqlparser.Expr) (int, error) {
+	if expr == nil {
+		return 0, nil
+	}
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.IntVal {
+		return 0, notSupported("LIMIT with placeholders")
+	}
+	n, err := strconv.Atoi(string(val.Val))
+	if err != nil {
+		return 0, notSupported("this LIMIT")
+	}
+	return n, nil
+}
@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/pkg/protocol"
+)
+
+// newVirtualSession returns a session whose admin source has three sessions
+// and two pools
+func newVirtualSession() (*Session, *MockConn) {
+	conn := NewMockConn()
+	cfg := &config.Config{
+		Conversion: config.ConversionConfig{FailurePolicy: config.FailClosed},
+		Tables: map[string]config.TableConfig{
+			"orders": {Enabled: true, Columns: map[string]config.ColumnConfig{
+				"total_amount": {TargetColumn: "total_amount_idn", RoundingStrategy: "BANKERS_ROUND", Precision: 4},
+				"discount":     {TargetColumn: "discount_idn", Precision: 4},
+			}},
+		},
+	}
+	session := NewSession(conn, cfg, nil)
+	session.admin = &fakeAdmin{
+		sessions: []SessionInfo{
+			{ConnID: 3, User: "app", Listener: "default", ConnectedAt: time.Now(), Queries: 12},
+			{ConnID: 5, User: "app", Listener: "default", ConnectedAt: time.Now(), Queries: 40},
+			{ConnID: 7, User: "dba", Listener: "bi", Role: "analytics", ConnectedAt: time.Now(), Queries: 3},
+		},
+		pools: []PoolInfo{
+			{Role: "primary", Backend: "db1:3306", Stats: map[string]interface{}{
+				"pool_capacity": 10, "current_idle": 4, "current_active": int32(2),
+				"circuit_breaker": map[string]interface{}{"state": "closed"},
+			}},
+			{Role: "analytics", Backend: "db2:3306", Stats: map[string]interface{}{"pool_capacity": 5}},
+		},
+	}
+	return session, conn
+}
+
+func TestSession_HandleQuery_VirtualTables(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		columns string
+		rows    []string
+	}{
+		{
+			name:    "sessions with filter and order",
+			query:   "SELECT conn_id, queries AS q FROM transisidb.sessions WHERE user = 'app' ORDER BY queries DESC",
+			columns: "conn_id,q",
+			rows:    []string{"5,40", "3,12"},
+		},
+		{
+			name:    "sessions with IS NULL and LIMIT",
+			query:   "select conn_id from TransisiDB.sessions s where s.role is null limit 1, 5",
+			columns: "conn_id",
+			rows:    []string{"5"},
+		},
+		{
+			name:    "pool stats",
+			query:   "SELECT role, backend, current_active, circuit_breaker FROM transisidb.pool_stats WHERE role IN ('primary')",
+			columns: "role,backend,current_active,circuit_breaker",
+			rows:    []string{"primary,db1:3306,2,closed"},
+		},
+		{
+			name:    "table configs",
+			query:   "SELECT table_name, column_name, target_column, failure_policy FROM transisidb.table_configs WHERE column_name LIKE 'total%'",
+			columns: "table_name,column_name,target_column,failure_policy",
+			rows:    []string{"orders,total_amount,total_amount_idn,fail_closed"},
+		},
+		{
+			name:    "numeric comparison",
+			query:   "SELECT conn_id FROM transisidb.sessions WHERE queries > 9 AND NOT conn_id = 5",
+			columns: "conn_id",
+			rows:    []string{"3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, conn := newVirtualSession()
+			if err := session.handleQuery(newQueryPacket(0, tt.query)); err != nil {
+				t.Fatalf("handleQuery returned error: %v", err)
+			}
+
+			columns, rows := readTextResultset(t, conn.WriteBuf)
+			if strings.Join(columns, ",") != tt.columns {
+				t.Errorf("columns = %v, want %s", columns, tt.columns)
+			}
+			var got []string
+			for _, row := range rows {
+				values := make([]string, len(row))
+				for i := range row {
+					values[i] = row.String(i)
+				}
+				got = append(got, strings.Join(values, ","))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.rows, "|") {
+				t.Errorf("rows = %v, want %v", got, tt.rows)
+			}
+		})
+	}
+}
+
+func TestSession_HandleQuery_VirtualTableErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		code  uint16
+	}{
+		{"SELECT * FROM transisidb.backends", codeNoSuchTable},
+		{"SELECT missing FROM transisidb.sessions", codeBadField},
+		{"SELECT COUNT(*) FROM transisidb.sessions", codeNotSupportedYet},
+		{"SELECT * FROM transisidb.sessions GROUP BY user", codeNotSupportedYet},
+	}
+
+	for _, tt := range tests {
+		session, conn := newVirtualSession()
+		if err := session.handleQuery(newQueryPacket(0, tt.query)); err != nil {
+			t.Fatalf("%s: handleQuery returned error: %v", tt.query, err)
+		}
+		pkt, err := protocol.ReadPacket(conn.WriteBuf)
+		if err != nil {
+			t.Fatalf("%s: failed to read response: %v", tt.query, err)
+		}
+		errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+		if err != nil {
+			t.Fatalf("%s: expected ERR packet: %v", tt.query, err)
+		}
+		if errPkt.ErrorCode != tt.code {
+			t.Errorf("%s: error code = %d, want %d", tt.query, errPkt.ErrorCode, tt.code)
+		}
+	}
+}
+
+func TestSession_HandleQuery_VirtualTableToggle(t *testing.T) {
+	session, conn := newVirtualSession()
+	session.user = "dba"
+	session.config.Proxy.AdminUsers = []string{"dba"}
+	admin := session.admin.(*fakeAdmin)
+	admin.toggles = newTableToggles()
+	session.toggles = admin.toggles
+
+	query := "UPDATE transisidb.table_configs SET enabled = 0 WHERE table_name = 'orders'"
+	if err := session.handleQuery(newQueryPacket(0, query)); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	pkt, err := protocol.ReadPacket(conn.WriteBuf)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	ok, err := protocol.ParseOKPacket(pkt.Payload)
+	if err != nil {
+		t.Fatalf("expected OK packet: %v", err)
+	}
+	if ok.AffectedRows != 2 {
+		t.Errorf("affected rows = %d, want one per column", ok.AffectedRows)
+	}
+	if admin.toggles.enabled("orders", true) {
+		t.Error("expected orders to be disabled")
+	}
+
+	// Disabling again changes nothing
+	if err := session.handleQuery(newQueryPacket(0, query)); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	pkt, _ = protocol.ReadPacket(conn.WriteBuf)
+	if ok, err := protocol.ParseOKPacket(pkt.Payload); err != nil || ok.AffectedRows != 0 {
+		t.Errorf("expected OK with no affected rows, got %v %v", ok, err)
+	}
+
+	// The table shows as disabled
+	if err := session.handleQuery(newQueryPacket(0, "SELECT enabled FROM transisidb.table_configs LIMIT 1")); err != nil {
+		t.Fatalf("handleQuery returned error: %v", err)
+	}
+	if _, rows := readTextResultset(t, conn.WriteBuf); len(rows) != 1 || rows[0].String(0) != "0" {
+		t.Errorf("unexpected rows: %q", rows)
+	}
+}
+
+func TestSession_HandleQuery_VirtualTableToggleErrors(t *testing.T) {
+	tests := []struct {
+		user  string
+		query string
+		code  uint16
+	}{
+		{"app", "UPDATE transisidb.table_configs SET enabled = 0 WHERE table_name = 'orders'", codeAccessDenied},
+		{"dba", "UPDATE transisidb.sessions SET user = 'x'", codeNonUpdatableTable},
+		{"dba", "UPDATE transisidb.table_configs SET enabled = 0", codeNotSupportedYet},
+		{"dba", "UPDATE transisidb.table_configs SET precision = 2 WHERE table_name = 'orders'", codeNotSupportedYet},
+	}
+
+	for _, tt := range tests {
+		session, conn := newVirtualSession()
+		session.user = tt.user
+		session.config.Proxy.AdminUsers = []string{"dba"}
+		if err := session.handleQuery(newQueryPacket(0, tt.query)); err != nil {
+			t.Fatalf("%s: handleQuery returned error: %v", tt.query, err)
+		}
+		pkt, err := protocol.ReadPacket(conn.WriteBuf)
+		if err != nil {
+			t.Fatalf("%s: failed to read response: %v", tt.query, err)
+		}
+		errPkt, err := protocol.ParseERRPacket(pkt.Payload)
+		if err != nil {
+			t.Fatalf("%s: expected ERR packet: %v", tt.query, err)
+		}
+		if errPkt.ErrorCode != tt.code {
+			t.Errorf("%s: error code = %d, want %d", tt.query, errPkt.ErrorCode, tt.code)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// writeWindow is how far back dual-write counts look, in one-minute buckets
+const writeWindow = 60
+
+// writeRates counts the statements dual-written per table over the last
+// hour. It is shared by all sessions.
+type writeRates struct {
+	mu     sync.Mutex
+	tables map[string]*[writeWindow]minuteCount
+}
+
+// minuteCount is the count of one minute since the epoch
+type minuteCount struct {
+	minute int64
+	count  int64
+}
+
+// DualWriteRate is a table's dual-write traffic over the last hour
+type DualWriteRate struct {
+	Table string `json:"table"`
+	// LastHour counts the statements dual-written in the last 60 minutes,
+	// including the current one
+	LastHour int64 `json:"last_hour"`
+	// PerMinute averages LastHour over the hour
+	PerMinute float64 `json:"per_minute"`
+}
+
+// newWriteRates returns empty counts
+func newWriteRates() *writeRates {
+	return &writeRates{tables: make(map[string]*[writeWindow]minuteCount)}
+}
+
+// record counts a statement dual-written at now. It does nothing on nil
+// counts.
+func (w *writeRates) record(table string, now time.Time) {
+	if w == nil {
+		return
+	}
+	minute := now.Unix() / 60
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	buckets, ok := w.tables[table]
+	if !ok {
+		buckets = new([writeWindow]minuteCount)
+		w.tables[table] = buckets
+	}
+	bucket := &buckets[minute%writeWindow]
+	if bucket.minute != minute {
+		*bucket = minuteCount{minute: minute}
+	}
+	bucket.count++
+}
+
+// rates returns the last hour's counts as of now, sorted by table. Tables
+// without writes in the hour are left out.
+func (w *writeRates) rates(now time.Time) []DualWriteRate {
+	if w == nil {
+		return []DualWriteRate{}
+	}
+	minute := now.Unix() / 60
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rates := make([]DualWriteRate, 0, len(w.tables))
+	for table, buckets := range w.tables {
+		var total int64
+		for _, bucket := range buckets {
+			if bucket.minute > minute-writeWindow && bucket.minute <= minute {
+				total += bucket.count
+			}
+		}
+		if total == 0 {
+			continue
+		}
+		rates = append(rates, DualWriteRate{Table: table, LastHour: total, PerMinute: float64(total) / writeWindow})
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Table < rates[j].Table })
+	return rates
+}
+
+// DualWriteRates returns the statements each table dual-wrote in the last
+// hour
+func (s *Server) DualWriteRates() []DualWriteRate {
+	return s.writes.rates(time.Now())
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+func TestWriteRates_CountsTheLastHour(t *testing.T) {
+	w := newWriteRates()
+	start := time.Date(2026, 10, 1, 12, 0, 30, 0, time.UTC)
+
+	w.record("orders", start.Add(-2*time.Hour)) // outside the hour once it is reused
+	for i := 0; i < 3; i++ {
+		w.record("orders", start)
+	}
+	w.record("orders", start.Add(30*time.Minute))
+	w.record("invoices", start.Add(59*time.Minute))
+
+	rates := w.rates(start.Add(59 * time.Minute))
+	if len(rates) != 2 {
+		t.Fatalf("expected 2 tables, got %+v", rates)
+	}
+	if rates[0].Table != "invoices" || rates[0].LastHour != 1 {
+		t.Errorf("unexpected invoices rate %+v", rates[0])
+	}
+	if rates[1].Table != "orders" || rates[1].LastHour != 4 {
+		t.Errorf("unexpected orders rate %+v", rates[1])
+	}
+	if rates[1].PerMinute != 4.0/60 {
+		t.Errorf("expected %v per minute, got %v", 4.0/60, rates[1].PerMinute)
+	}
+
+	// An hour later the first minute has left the window
+	rates = w.rates(start.Add(60 * time.Minute))
+	if len(rates) != 2 || rates[1].LastHour != 1 {
+		t.Errorf("expected 1 orders statement in the hour, got %+v", rates)
+	}
+
+	// A reused bucket starts over
+	w.record("orders", start.Add(61*time.Minute))
+	rates = w.rates(start.Add(61 * time.Minute))
+	if rates[1].LastHour != 2 {
+		t.Errorf("expected 2 orders statements in the hour, got %+v", rates[1])
+	}
+
+	if got := w.rates(start.Add(3 * time.Hour)); len(got) != 0 {
+		t.Errorf("expected no tables after an idle hour, got %+v", got)
+	}
+}
+
+func TestServer_AdminHandler_ServesDualWriteRates(t *testing.T) {
+	server := &Server{
+		config: &config.Config{Monitoring: config.MonitoringConfig{MetricsPath: "/metrics"}},
+		writes: newWriteRates(),
+	}
+	server.writes.record("orders", time.Now())
+	ts := httptest.NewServer(server.AdminHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/dual-writes")
+	if err != nil {
+		t.Fatalf("GET /dual-writes failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var rates []DualWriteRate
+	if err := json.NewDecoder(resp.Body).Decode(&rates); err != nil {
+		t.Fatalf("failed to decode rates: %v", err)
+	}
+	if len(rates) != 1 || rates[0].Table != "orders" || rates[0].LastHour != 1 {
+		t.Errorf("unexpected rates %+v", rates)
+	}
+}
@@ -0,0 +1,157 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process queue with the delivery semantics of Redis, for
+// tests and single-process setups. Messages are lost when the process exits.
+type Memory struct {
+	opts Options
+
+	mu       sync.Mutex
+	nextID   uint64
+	messages []*memoryMessage // in publish order
+	// published wakes consumers waiting for new messages
+	published chan struct{}
+}
+
+// memoryMessage is a message with its delivery state
+type memoryMessage struct {
+	Message
+	consumer    string // "" until delivered
+	deliveredAt time.Time
+}
+
+// NewMemory returns an empty in-memory queue
+func NewMemory(opts Options) *Memory {
+	return &Memory{opts: opts.withDefaults(), published: make(chan struct{})}
+}
+
+// Publish adds a message, dropping the oldest beyond MaxLen
+func (q *Memory) Publish(ctx context.Context, payload []byte) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	id := strconv.FormatUint(q.nextID, 10) + "-0"
+	q.messages = append(q.messages, &memoryMessage{Message: Message{ID: id, Payload: payload}})
+	if int64(len(q.messages)) > q.opts.MaxLen {
+		q.messages = q.messages[int64(len(q.messages))-q.opts.MaxLen:]
+	}
+
+	close(q.published)
+	q.published = make(chan struct{})
+	return id, nil
+}
+
+// Consume claims messages idle for ClaimIdle first, then waits up to Block for
+// new ones
+func (q *Memory) Consume(ctx context.Context, consumer string, count int) ([]Message, error) {
+	timer := time.NewTimer(q.opts.Block)
+	defer timer.Stop()
+
+	for {
+		q.mu.Lock()
+		messages := q.deliver(consumer, count)
+		published := q.published
+		q.mu.Unlock()
+		if len(messages) > 0 {
+			return messages, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, nil
+		case <-published:
+		}
+	}
+}
+
+// deliver hands out idle pending messages or, without any, new ones
+func (q *Memory) deliver(consumer string, count int) []Message {
+	now := time.Now()
+	var messages []Message
+	take := func(idle bool) {
+		for _, msg := range q.messages {
+			if len(messages) >= count {
+				return
+			}
+			delivered := msg.consumer != ""
+			if delivered != idle || (idle && now.Sub(msg.deliveredAt) < q.opts.ClaimIdle) {
+				continue
+			}
+			msg.consumer = consumer
+			msg.deliveredAt = now
+			msg.Attempts++
+			messages = append(messages, msg.Message)
+		}
+	}
+	take(true)
+	if len(messages) == 0 {
+		take(false)
+	}
+	return messages
+}
+
+// Ack removes messages
+func (q *Memory) Ack(ctx context.Context, ids ...string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	acked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		acked[id] = true
+	}
+	kept := q.messages[:0]
+	for _, msg := range q.messages {
+		if !acked[msg.ID] {
+			kept = append(kept, msg)
+		}
+	}
+	q.messages = kept
+	return nil
+}
+
+// Touch resets the idle time of a consumer's messages
+func (q *Memory) Touch(ctx context.Context, consumer string, ids ...string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	touched := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		touched[id] = true
+	}
+	now := time.Now()
+	for _, msg := range q.messages {
+		if touched[msg.ID] {
+			msg.consumer = consumer
+			msg.deliveredAt = now
+		}
+	}
+	return nil
+}
+
+// Len returns the number of messages not yet acknowledged
+func (q *Memory) Len(ctx context.Context) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int64(len(q.messages)), nil
+}
+
+// List returns the messages not yet acknowledged in publish order
+func (q *Memory) List(ctx context.Context) ([]Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	messages := make([]Message, 0, len(q.messages))
+	for _, msg := range q.messages {
+		messages = append(messages, Message{ID: msg.ID, Payload: msg.Payload})
+	}
+	return messages, nil
+}
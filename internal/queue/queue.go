@@ -0,0 +1,73 @@
+// Package queue is a durable work queue with consumer groups for background
+// jobs. Messages stay with the consumer that read them until acknowledged;
+// messages a consumer left unacknowledged for ClaimIdle are delivered again,
+// to any consumer, so work survives crashed workers.
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Defaults applied to zero Options
+const (
+	DefaultClaimIdle = time.Minute
+	DefaultBlock     = time.Second
+	DefaultMaxLen    = 1000000
+)
+
+// Message is a payload delivered to a consumer
+type Message struct {
+	ID      string
+	Payload []byte
+	// Attempts counts deliveries of the message, 1 on first delivery
+	Attempts int64
+}
+
+// Queue is a work queue shared by producers and a group of consumers
+type Queue interface {
+	// Publish adds a message and returns its ID
+	Publish(ctx context.Context, payload []byte) (string, error)
+	// Consume returns up to count messages for a consumer: messages left
+	// unacknowledged for ClaimIdle first, then new ones, waiting up to Block
+	// when there are none
+	Consume(ctx context.Context, consumer string, count int) ([]Message, error)
+	// Ack removes processed messages
+	Ack(ctx context.Context, ids ...string) error
+	// Touch resets the idle time of messages a consumer is still working on,
+	// so long jobs are not delivered to another consumer
+	Touch(ctx context.Context, consumer string, ids ...string) error
+	// Len returns the number of messages not yet acknowledged
+	Len(ctx context.Context) (int64, error)
+	// List returns the messages not yet acknowledged in publish order,
+	// delivered or not, without delivering them. Attempts is not set.
+	List(ctx context.Context) ([]Message, error)
+}
+
+// Options configure a queue
+type Options struct {
+	// Stream names the queue; Redis uses it as the stream key
+	Stream string
+	// Group is the consumer group sharing the messages
+	Group string
+	// MaxLen caps the number of messages; the oldest are trimmed beyond it
+	MaxLen int64
+	// ClaimIdle is how long an unacknowledged message stays with its consumer
+	ClaimIdle time.Duration
+	// Block is how long Consume waits for new messages
+	Block time.Duration
+}
+
+// withDefaults fills in zero options
+func (o Options) withDefaults() Options {
+	if o.MaxLen <= 0 {
+		o.MaxLen = DefaultMaxLen
+	}
+	if o.ClaimIdle <= 0 {
+		o.ClaimIdle = DefaultClaimIdle
+	}
+	if o.Block <= 0 {
+		o.Block = DefaultBlock
+	}
+	return o
+}
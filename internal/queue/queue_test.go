@@ -0,0 +1,131 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOptions keep the queue tests fast
+func testOptions(stream string) Options {
+	return Options{Stream: stream, Group: "test", ClaimIdle: 200 * time.Millisecond, Block: 20 * time.Millisecond}
+}
+
+// testQueue checks the delivery semantics shared by all implementations
+func testQueue(t *testing.T, q Queue) {
+	ctx := context.Background()
+
+	first, err := q.Publish(ctx, []byte("a"))
+	require.NoError(t, err)
+	_, err = q.Publish(ctx, []byte("b"))
+	require.NoError(t, err)
+
+	// Listing delivers nothing
+	messages, err := q.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, first, messages[0].ID)
+	assert.Equal(t, "b", string(messages[1].Payload))
+
+	messages, err = q.Consume(ctx, "worker-1", 1)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, first, messages[0].ID)
+	assert.Equal(t, "a", string(messages[0].Payload))
+	assert.Equal(t, int64(1), messages[0].Attempts)
+
+	// Delivered messages go to no other consumer until they are idle
+	messages, err = q.Consume(ctx, "worker-2", 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "b", string(messages[0].Payload))
+	require.NoError(t, q.Ack(ctx, messages[0].ID))
+
+	messages, err = q.Consume(ctx, "worker-2", 10)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	// Touched messages stay with their consumer
+	time.Sleep(150 * time.Millisecond)
+	require.NoError(t, q.Touch(ctx, "worker-1", first))
+	time.Sleep(100 * time.Millisecond)
+	messages, err = q.Consume(ctx, "worker-2", 10)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	// Unacknowledged messages are delivered again once idle
+	time.Sleep(150 * time.Millisecond)
+	messages, err = q.Consume(ctx, "worker-2", 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, first, messages[0].ID)
+	assert.Equal(t, int64(2), messages[0].Attempts)
+
+	n, err := q.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	messages, err = q.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, first, messages[0].ID)
+
+	require.NoError(t, q.Ack(ctx, first))
+	n, err = q.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestMemory(t *testing.T) {
+	testQueue(t, NewMemory(testOptions("jobs")))
+}
+
+func TestMemory_ConsumeWakesOnPublish(t *testing.T) {
+	q := NewMemory(Options{Block: time.Second})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Publish(context.Background(), []byte("a"))
+	}()
+
+	start := time.Now()
+	messages, err := q.Consume(context.Background(), "worker-1", 1)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestMemory_MaxLen(t *testing.T) {
+	q := NewMemory(Options{MaxLen: 2})
+	for i := 0; i < 3; i++ {
+		_, err := q.Publish(context.Background(), []byte(fmt.Sprint(i)))
+		require.NoError(t, err)
+	}
+
+	messages, err := q.Consume(context.Background(), "worker-1", 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "1", string(messages[0].Payload))
+}
+
+func TestRedis(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Redis integration test in short mode")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 15})
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis not available, skipping test: %v", err)
+	}
+
+	stream := fmt.Sprintf("transisidb:test:queue:%d", time.Now().UnixNano())
+	defer client.Del(context.Background(), stream)
+	testQueue(t, NewRedis(client, testOptions(stream)))
+}
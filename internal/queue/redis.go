@@ -0,0 +1,190 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// payloadField is the stream entry field holding the payload
+const payloadField = "payload"
+
+// Redis keeps messages in a Redis stream read through a consumer group.
+// Acknowledged messages are deleted, so the stream length is the backlog.
+type Redis struct {
+	client redis.UniversalClient
+	opts   Options
+	// grouped is set once the consumer group exists
+	grouped atomic.Bool
+}
+
+// NewRedis returns a queue on the stream and group of opts
+func NewRedis(client redis.UniversalClient, opts Options) *Redis {
+	return &Redis{client: client, opts: opts.withDefaults()}
+}
+
+// Publish adds a message, trimming the oldest beyond MaxLen
+func (q *Redis) Publish(ctx context.Context, payload []byte) (string, error) {
+	id, err := q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.opts.Stream,
+		MaxLen: q.opts.MaxLen,
+		Approx: true,
+		Values: map[string]interface{}{payloadField: payload},
+	}).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to publish to %s: %w", q.opts.Stream, err)
+	}
+	return id, nil
+}
+
+// Consume claims messages idle for ClaimIdle first, then reads new ones
+func (q *Redis) Consume(ctx context.Context, consumer string, count int) ([]Message, error) {
+	if err := q.ensureGroup(ctx); err != nil {
+		return nil, err
+	}
+
+	claimed, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   q.opts.Stream,
+		Group:    q.opts.Group,
+		Consumer: consumer,
+		MinIdle:  q.opts.ClaimIdle,
+		Start:    "0-0",
+		Count:    int64(count),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim from %s: %w", q.opts.Stream, err)
+	}
+	if len(claimed) > 0 {
+		return q.withAttempts(ctx, consumer, claimed)
+	}
+
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.opts.Group,
+		Consumer: consumer,
+		Streams:  []string{q.opts.Stream, ">"},
+		Count:    int64(count),
+		Block:    q.opts.Block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from %s: %w", q.opts.Stream, err)
+	}
+	var messages []Message
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			messages = append(messages, Message{ID: msg.ID, Payload: payload(msg), Attempts: 1})
+		}
+	}
+	return messages, nil
+}
+
+// withAttempts converts claimed entries, reading their delivery counts
+func (q *Redis) withAttempts(ctx context.Context, consumer string, claimed []redis.XMessage) ([]Message, error) {
+	pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   q.opts.Stream,
+		Group:    q.opts.Group,
+		Consumer: consumer,
+		Start:    claimed[0].ID,
+		End:      claimed[len(claimed)-1].ID,
+		Count:    int64(len(claimed)),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery counts of %s: %w", q.opts.Stream, err)
+	}
+	attempts := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		attempts[p.ID] = p.RetryCount
+	}
+
+	messages := make([]Message, 0, len(claimed))
+	for _, msg := range claimed {
+		n, ok := attempts[msg.ID]
+		if !ok {
+			// A claimed message was delivered at least once before
+			n = 2
+		}
+		messages = append(messages, Message{ID: msg.ID, Payload: payload(msg), Attempts: n})
+	}
+	return messages, nil
+}
+
+// payload returns the payload field of a stream entry
+func payload(msg redis.XMessage) []byte {
+	data, _ := msg.Values[payloadField].(string)
+	return []byte(data)
+}
+
+// Ack acknowledges messages and deletes them from the stream
+func (q *Redis) Ack(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, q.opts.Stream, q.opts.Group, ids...)
+		pipe.XDel(ctx, q.opts.Stream, ids...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge on %s: %w", q.opts.Stream, err)
+	}
+	return nil
+}
+
+// Touch claims the messages again for the same consumer, resetting their idle
+// time without counting a delivery
+func (q *Redis) Touch(ctx context.Context, consumer string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := q.client.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   q.opts.Stream,
+		Group:    q.opts.Group,
+		Consumer: consumer,
+		Messages: ids,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to extend messages on %s: %w", q.opts.Stream, err)
+	}
+	return nil
+}
+
+// Len returns the stream length, the messages not yet acknowledged
+func (q *Redis) Len(ctx context.Context) (int64, error) {
+	n, err := q.client.XLen(ctx, q.opts.Stream).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read length of %s: %w", q.opts.Stream, err)
+	}
+	return n, nil
+}
+
+// List returns the stream entries, the messages not yet acknowledged
+func (q *Redis) List(ctx context.Context) ([]Message, error) {
+	entries, err := q.client.XRange(ctx, q.opts.Stream, "-", "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", q.opts.Stream, err)
+	}
+	messages := make([]Message, 0, len(entries))
+	for _, msg := range entries {
+		messages = append(messages, Message{ID: msg.ID, Payload: payload(msg)})
+	}
+	return messages, nil
+}
+
+// ensureGroup creates the stream and consumer group on first use
+func (q *Redis) ensureGroup(ctx context.Context) error {
+	if q.grouped.Load() {
+		return nil
+	}
+	err := q.client.XGroupCreateMkStream(ctx, q.opts.Stream, q.opts.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s: %w", q.opts.Group, err)
+	}
+	q.grouped.Store(true)
+	return nil
+}
@@ -0,0 +1,276 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/converter"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// Divergence kinds
+const (
+	// KindConversion is a shadow value that differs from its converted
+	// source: the proxy or the backfill wrote it wrong, whatever the extract
+	// holds
+	KindConversion = "conversion"
+	// KindDownstream is a correct shadow value the extract differs from
+	KindDownstream = "downstream"
+	// KindNotBackfilled is a row without a shadow value
+	KindNotBackfilled = "not_backfilled"
+	// KindMissing is an extract row that is not in the table
+	KindMissing = "missing"
+)
+
+// kinds lists the divergence kinds in report order
+var kinds = []string{KindConversion, KindDownstream, KindNotBackfilled, KindMissing}
+
+const (
+	// batchSize is how many extract rows are looked up at once
+	batchSize = 1000
+	// maxSampleIDs is how many row ids a report lists per kind
+	maxSampleIDs = 10
+)
+
+// Report counts the divergences between a column and its extract
+type Report struct {
+	Table  string
+	Column string
+	// Compared is the number of extract rows read
+	Compared int64
+	Matched  int64
+	// Skipped rows have a NULL source value and are not compared
+	Skipped int64
+	// Divergences counts rows by kind, and SampleIDs lists the first of them
+	Divergences map[string]int64
+	SampleIDs   map[string][]int64
+}
+
+// Diverged returns the number of rows that diverge
+func (r *Report) Diverged() int64 {
+	var total int64
+	for _, count := range r.Divergences {
+		total += count
+	}
+	return total
+}
+
+// String summarises the report for a job's detail
+func (r *Report) String() string {
+	parts := []string{fmt.Sprintf("%d compared", r.Compared), fmt.Sprintf("%d matched", r.Matched)}
+	if r.Skipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", r.Skipped))
+	}
+	var samples []string
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%d %s", r.Divergences[kind], strings.ReplaceAll(kind, "_", " ")))
+		if ids := r.SampleIDs[kind]; len(ids) > 0 {
+			samples = append(samples, fmt.Sprintf("%s ids %s", kind, formatIDs(ids)))
+		}
+	}
+	summary := fmt.Sprintf("%s.%s: %s", r.Table, r.Column, strings.Join(parts, ", "))
+	if len(samples) > 0 {
+		summary += " (" + strings.Join(samples, "; ") + ")"
+	}
+	return summary
+}
+
+// row is a table row's source and shadow values
+type row struct {
+	source sql.NullFloat64
+	shadow sql.NullFloat64
+}
+
+// comparer classifies extract rows against the table
+type comparer struct {
+	conv      config.ConversionConfig
+	column    config.ColumnConfig
+	unit      string
+	tolerance float64
+	// lookup reads the rows of the given ids; absent ids are not in the table
+	lookup func(ctx context.Context, ids []int64) (map[int64]row, error)
+}
+
+// Compare reads the extract and compares it with the configured currency
+// column it names. The shadow value dual-write writes for the source value is
+// the reference: a shadow value off from it by a unit of the column's
+// precision or more is a conversion divergence, and an extract value off from
+// a correct shadow value is a downstream one.
+func Compare(ctx context.Context, db *sql.DB, cfg *config.Config, extract config.ExtractConfig, src Source) (*Report, error) {
+	tableConfig, ok := cfg.Tables[extract.Table]
+	if !ok {
+		return nil, fmt.Errorf("table %s is not configured", extract.Table)
+	}
+	colConfig, ok := tableConfig.Columns[extract.Column]
+	if !ok {
+		return nil, fmt.Errorf("column %s of table %s is not configured", extract.Column, extract.Table)
+	}
+
+	query := fmt.Sprintf("SELECT id, %s, %s FROM %s WHERE id IN ",
+		parser.QuoteIdentifier(extract.Column), parser.QuoteIdentifier(colConfig.TargetColumn), parser.QuoteTable(extract.Table))
+	c := &comparer{
+		conv:      cfg.Conversion,
+		column:    colConfig,
+		unit:      extract.Unit,
+		tolerance: converter.ShadowTolerance(cfg.Conversion, colConfig),
+		lookup: func(ctx context.Context, ids []int64) (map[int64]row, error) {
+			return lookupRows(ctx, db, query, ids)
+		},
+	}
+	report := newReport(extract.Table, extract.Column)
+	if err := c.run(ctx, src, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func newReport(table, column string) *Report {
+	return &Report{Table: table, Column: column, Divergences: make(map[string]int64), SampleIDs: make(map[string][]int64)}
+}
+
+// run reads the extract in batches and classifies its rows into report
+func (c *comparer) run(ctx context.Context, src Source, report *Report) error {
+	batch := make([]Value, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		ids := make([]int64, len(batch))
+		for i, v := range batch {
+			ids[i] = v.ID
+		}
+		rows, err := c.lookup(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, v := range batch {
+			r, found := rows[v.ID]
+			report.add(v.ID, c.classify(v, r, found))
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := src.Read(ctx, func(v Value) error {
+		batch = append(batch, v)
+		if len(batch) < batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// Classification results that are not divergences
+const (
+	matched = ""
+	skipped = "skipped"
+)
+
+// classify returns the divergence kind of an extract row, matched or skipped
+func (c *comparer) classify(v Value, r row, found bool) string {
+	switch {
+	case !found:
+		return KindMissing
+	case !r.source.Valid:
+		return skipped
+	case !r.shadow.Valid:
+		return KindNotBackfilled
+	}
+
+	expected, ok := c.shadowValue(r.source.Float64)
+	if !ok || math.Abs(r.shadow.Float64-expected) >= c.tolerance {
+		return KindConversion
+	}
+	if !v.Amount.Valid {
+		return KindDownstream
+	}
+	amount := v.Amount.Float64
+	if c.unit == config.ExtractUnitIDR {
+		if amount, ok = c.shadowValue(amount); !ok {
+			return KindDownstream
+		}
+	}
+	if math.Abs(amount-r.shadow.Float64) >= c.tolerance {
+		return KindDownstream
+	}
+	return matched
+}
+
+// shadowValue returns the shadow value dual-write writes for an IDR amount
+// of the column, false when the shadow column cannot hold it
+func (c *comparer) shadowValue(amount float64) (float64, bool) {
+	value, err := converter.ShadowValue(c.conv, c.column, amount)
+	if err != nil {
+		return 0, false
+	}
+	shadow, err := strconv.ParseFloat(value, 64)
+	return shadow, err == nil
+}
+
+// add counts a classified row
+func (r *Report) add(id int64, kind string) {
+	r.Compared++
+	switch kind {
+	case matched:
+		r.Matched++
+	case skipped:
+		r.Skipped++
+	default:
+		r.Divergences[kind]++
+		if len(r.SampleIDs[kind]) < maxSampleIDs {
+			r.SampleIDs[kind] = append(r.SampleIDs[kind], id)
+		}
+	}
+}
+
+// lookupRows reads the source and shadow values of rows by id
+func lookupRows(ctx context.Context, db *sql.DB, query string, ids []int64) (map[int64]row, error) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	rows, err := db.QueryContext(ctx, query+"("+placeholders+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]row, len(ids))
+	for rows.Next() {
+		var id int64
+		var r row
+		// DECIMAL sources may hold fractional amounts
+		var source sql.NullString
+		if err := rows.Scan(&id, &source, &r.shadow); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if source.Valid {
+			amount, err := converter.ParseStoredAmount(source.String)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the amount of row %d: %w", id, err)
+			}
+			r.source = sql.NullFloat64{Float64: amount, Valid: true}
+		}
+		result[id] = r
+	}
+	return result, rows.Err()
+}
+
+// formatIDs lists row ids for a report
+func formatIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	return strings.Join(parts, ",")
+}
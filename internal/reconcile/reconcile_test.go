@@ -0,0 +1,163 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sliceSource is an extract held in memory
+type sliceSource []Value
+
+func (s sliceSource) Read(ctx context.Context, fn func(Value) error) error {
+	for _, v := range s {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s sliceSource) Close() error { return nil }
+
+func amount(v float64) sql.NullFloat64 { return sql.NullFloat64{Float64: v, Valid: true} }
+
+func newTestComparer(unit string, rows map[int64]row) *comparer {
+	return &comparer{
+		conv:      config.ConversionConfig{Ratio: 1000, Precision: 4, RoundingStrategy: "BANKERS_ROUND"},
+		unit:      unit,
+		tolerance: 0.0001,
+		lookup: func(ctx context.Context, ids []int64) (map[int64]row, error) {
+			found := make(map[int64]row)
+			for _, id := range ids {
+				if r, ok := rows[id]; ok {
+					found[id] = r
+				}
+			}
+			return found, nil
+		},
+	}
+}
+
+func TestComparer_ClassifiesDivergences(t *testing.T) {
+	rows := map[int64]row{
+		1: {source: amount(150500), shadow: amount(150.5)},
+		2: {source: amount(150500), shadow: amount(150)}, // converted wrong
+		3: {source: amount(200000), shadow: amount(200)}, // extract differs
+		4: {source: amount(99000), shadow: sql.NullFloat64{}},
+		5: {shadow: amount(1)},
+		6: {source: amount(1000), shadow: amount(1)},        // extract has no amount
+		8: {source: amount(1500.5), shadow: amount(1.5005)}, // fractional DECIMAL source
+	}
+	extract := sliceSource{
+		{ID: 1, Amount: amount(150.5)},
+		{ID: 2, Amount: amount(150)},
+		{ID: 3, Amount: amount(201)},
+		{ID: 4, Amount: amount(99)},
+		{ID: 5, Amount: amount(1)},
+		{ID: 6},
+		{ID: 7, Amount: amount(5)},
+		{ID: 8, Amount: amount(1.5005)},
+	}
+
+	report := newReport("orders", "total_amount")
+	require.NoError(t, newTestComparer(config.ExtractUnitIDN, rows).run(context.Background(), extract, report))
+
+	assert.Equal(t, int64(8), report.Compared)
+	assert.Equal(t, int64(2), report.Matched)
+	assert.Equal(t, int64(1), report.Skipped)
+	assert.Equal(t, map[string]int64{KindConversion: 1, KindDownstream: 2, KindNotBackfilled: 1, KindMissing: 1}, report.Divergences)
+	assert.Equal(t, []int64{3, 6}, report.SampleIDs[KindDownstream])
+	assert.Equal(t, int64(5), report.Diverged())
+	assert.Equal(t,
+		"orders.total_amount: 8 compared, 2 matched, 1 skipped, 1 conversion, 2 downstream, 1 not backfilled, 1 missing"+
+			" (conversion ids 2; downstream ids 3,6; not_backfilled ids 4; missing ids 7)",
+		report.String())
+}
+
+func TestComparer_ConvertsIDRExtracts(t *testing.T) {
+	rows := map[int64]row{1: {source: amount(150500), shadow: amount(150.5)}}
+
+	report := newReport("orders", "total_amount")
+	extract := sliceSource{{ID: 1, Amount: amount(150500)}}
+	require.NoError(t, newTestComparer(config.ExtractUnitIDR, rows).run(context.Background(), extract, report))
+	assert.Equal(t, int64(1), report.Matched)
+}
+
+func TestComparer_UsesColumnPrecision(t *testing.T) {
+	rows := map[int64]row{
+		1: {source: amount(1234), shadow: amount(1.234)},
+		2: {source: amount(1234), shadow: amount(1.23)},
+	}
+	c := newTestComparer(config.ExtractUnitIDN, rows)
+	// conversion.precision alone would round 1.234 to 1.23
+	c.conv.Precision = 2
+	c.column = config.ColumnConfig{Precision: 4}
+
+	report := newReport("orders", "total_amount")
+	extract := sliceSource{{ID: 1, Amount: amount(1.234)}, {ID: 2, Amount: amount(1.23)}}
+	require.NoError(t, c.run(context.Background(), extract, report))
+	assert.Equal(t, int64(1), report.Matched)
+	assert.Equal(t, []int64{2}, report.SampleIDs[KindConversion])
+}
+
+func TestCSVSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.csv")
+	require.NoError(t, os.WriteFile(path, []byte("order_id,region,total\n1,jkt,150.5\n2,sby,\n"), 0o600))
+
+	src, err := OpenCSV(path, "order_id", "total")
+	require.NoError(t, err)
+	defer src.Close()
+
+	var values []Value
+	require.NoError(t, src.Read(context.Background(), func(v Value) error {
+		values = append(values, v)
+		return nil
+	}))
+	assert.Equal(t, []Value{{ID: 1, Amount: amount(150.5)}, {ID: 2}}, values)
+}
+
+func TestCSVSource_Errors(t *testing.T) {
+	dir := t.TempDir()
+	read := func(content string) error {
+		path := filepath.Join(dir, "extract.csv")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		src, err := OpenCSV(path, "", "")
+		require.NoError(t, err)
+		defer src.Close()
+		return src.Read(context.Background(), func(Value) error { return nil })
+	}
+
+	assert.ErrorContains(t, read("order_id,amount\n1,5\n"), "extract header needs fields id and amount")
+	assert.ErrorContains(t, read("id,amount\nabc,5\n"), `line 2: invalid id "abc"`)
+	assert.ErrorContains(t, read("id,amount\n1,5\n2,five\n"), `line 3: invalid amount "five"`)
+
+	_, err := Open(config.ExtractConfig{Format: "parquet"})
+	assert.ErrorContains(t, err, "invalid extract format: parquet")
+}
+
+func TestReport_Result(t *testing.T) {
+	report := newReport("orders", "total_amount")
+	report.add(3, KindDownstream)
+	checked := time.Date(2026, 10, 17, 5, 0, 0, 0, time.UTC)
+
+	results := NewMemoryResults()
+	ctx := context.Background()
+	require.NoError(t, results.Save(ctx, report.Result("warehouse", checked)))
+	require.NoError(t, results.Save(ctx, Result{Job: "nightly", Table: "orders", Column: "total_amount"}))
+
+	latest, err := results.Latest(ctx)
+	require.NoError(t, err)
+	require.Len(t, latest, 2)
+	assert.Equal(t, "nightly", latest[0].Job)
+	assert.Equal(t, map[string]int64{KindConversion: 0, KindDownstream: 1, KindNotBackfilled: 0, KindMissing: 0}, latest[1].Counts)
+	assert.Equal(t, []int64{3}, latest[1].SampleIDs[KindDownstream])
+	assert.Equal(t, checked, latest[1].Checked)
+}
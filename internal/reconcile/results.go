@@ -0,0 +1,131 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Result is the latest check of a currency column by a reconcile or
+// warehouse_reconcile job
+type Result struct {
+	Checked time.Time `json:"checked"`
+	Job     string    `json:"job"`
+	Table   string    `json:"table"`
+	Column  string    `json:"column"`
+	// Counts has the rows of each divergence kind, zero when the column
+	// has none
+	Counts map[string]int64 `json:"counts"`
+	// SampleIDs lists the first ids of each kind, when the job reports them
+	SampleIDs map[string][]int64 `json:"sample_ids,omitempty"`
+}
+
+// Result returns the report as the result of job's check at checked
+func (r *Report) Result(job string, checked time.Time) Result {
+	counts := make(map[string]int64, len(kinds))
+	for _, kind := range kinds {
+		counts[kind] = r.Divergences[kind]
+	}
+	return Result{Checked: checked, Job: job, Table: r.Table, Column: r.Column, Counts: counts, SampleIDs: r.SampleIDs}
+}
+
+// Results keeps the latest result of each job and column
+type Results interface {
+	Save(ctx context.Context, result Result) error
+	// Latest returns the results ordered by job, table and column
+	Latest(ctx context.Context) ([]Result, error)
+}
+
+// ResultsKey is the Redis hash of the latest results
+const ResultsKey = "transisidb:reconcile:results"
+
+// RedisResults stores results in a Redis hash, one field per job and column
+type RedisResults struct {
+	client redis.UniversalClient
+}
+
+// NewRedisResults returns results stored with client
+func NewRedisResults(client redis.UniversalClient) *RedisResults {
+	return &RedisResults{client: client}
+}
+
+// Save replaces the result of the job and column
+func (s *RedisResults) Save(ctx context.Context, result Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reconcile result: %w", err)
+	}
+	if err := s.client.HSet(ctx, ResultsKey, resultField(result), data).Err(); err != nil {
+		return fmt.Errorf("failed to save reconcile result: %w", err)
+	}
+	return nil
+}
+
+// Latest returns the stored results
+func (s *RedisResults) Latest(ctx context.Context) ([]Result, error) {
+	fields, err := s.client.HGetAll(ctx, ResultsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reconcile results: %w", err)
+	}
+	results := make([]Result, 0, len(fields))
+	for field, data := range fields {
+		var result Result
+		if err := json.Unmarshal([]byte(data), &result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal reconcile result %s: %w", field, err)
+		}
+		results = append(results, result)
+	}
+	sortResults(results)
+	return results, nil
+}
+
+// MemoryResults keeps results in process, for tests and single-node setups
+type MemoryResults struct {
+	mu      sync.Mutex
+	results map[string]Result
+}
+
+// NewMemoryResults returns empty in-memory results
+func NewMemoryResults() *MemoryResults {
+	return &MemoryResults{results: make(map[string]Result)}
+}
+
+// Save replaces the result of the job and column
+func (s *MemoryResults) Save(ctx context.Context, result Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[resultField(result)] = result
+	return nil
+}
+
+// Latest returns the saved results
+func (s *MemoryResults) Latest(ctx context.Context) ([]Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]Result, 0, len(s.results))
+	for _, result := range s.results {
+		results = append(results, result)
+	}
+	sortResults(results)
+	return results, nil
+}
+
+func resultField(result Result) string {
+	return result.Job + "/" + result.Table + "." + result.Column
+}
+
+func sortResults(results []Result) {
+	sort.Slice(results, func(i, j int) bool {
+		return resultField(results[i]) < resultField(results[j])
+	})
+}
+
+// Kinds returns the divergence kinds in report order
+func Kinds() []string {
+	return append([]string(nil), kinds...)
+}
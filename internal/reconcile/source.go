@@ -0,0 +1,168 @@
+// Package reconcile compares the converted values in MySQL with an extract of
+// the same column from a downstream system, such as a data warehouse, and
+// tells divergences caused by the proxy's conversions from those introduced
+// downstream.
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+// Value is the amount an extract holds for a row, NULL when it has none
+type Value struct {
+	ID     int64
+	Amount sql.NullFloat64
+}
+
+// Source reads an extract. Other systems are supported by implementing it.
+type Source interface {
+	// Read calls fn for every row of the extract, in any order, and stops at
+	// the first error fn returns
+	Read(ctx context.Context, fn func(Value) error) error
+	Close() error
+}
+
+// Default CSV header names
+const (
+	DefaultIDField     = "id"
+	DefaultAmountField = "amount"
+)
+
+// Open returns the source of an extract
+func Open(extract config.ExtractConfig) (Source, error) {
+	switch extract.Format {
+	case config.ExtractFormatCSV:
+		return OpenCSV(extract.Path, extract.IDField, extract.AmountField)
+	case config.ExtractFormatSQL:
+		return OpenSQL(extract.DSN, extract.Query)
+	default:
+		return nil, fmt.Errorf("invalid extract format: %s", extract.Format)
+	}
+}
+
+// CSVSource reads a CSV file whose first row names the fields
+type CSVSource struct {
+	file        *os.File
+	idField     string
+	amountField string
+}
+
+// OpenCSV opens a CSV extract; empty field names take the defaults
+func OpenCSV(path, idField, amountField string) (*CSVSource, error) {
+	if idField == "" {
+		idField = DefaultIDField
+	}
+	if amountField == "" {
+		amountField = DefaultAmountField
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open extract: %w", err)
+	}
+	return &CSVSource{file: file, idField: idField, amountField: amountField}, nil
+}
+
+// Read reads the file from its header. An empty amount is NULL.
+func (s *CSVSource) Read(ctx context.Context, fn func(Value) error) error {
+	r := csv.NewReader(s.file)
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read extract header: %w", err)
+	}
+	idIndex, amountIndex := -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(name) {
+		case s.idField:
+			idIndex = i
+		case s.amountField:
+			amountIndex = i
+		}
+	}
+	if idIndex < 0 || amountIndex < 0 {
+		return fmt.Errorf("extract header needs fields %s and %s", s.idField, s.amountField)
+	}
+
+	for line := 2; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read extract: %w", err)
+		}
+
+		var v Value
+		if v.ID, err = strconv.ParseInt(strings.TrimSpace(record[idIndex]), 10, 64); err != nil {
+			return fmt.Errorf("line %d: invalid id %q", line, record[idIndex])
+		}
+		if amount := strings.TrimSpace(record[amountIndex]); amount != "" {
+			if v.Amount.Float64, err = strconv.ParseFloat(amount, 64); err != nil {
+				return fmt.Errorf("line %d: invalid amount %q", line, amount)
+			}
+			v.Amount.Valid = true
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the file
+func (s *CSVSource) Close() error {
+	return s.file.Close()
+}
+
+// SQLSource runs a query selecting the row id and the amount over the MySQL
+// protocol, which ClickHouse and other warehouses also serve
+type SQLSource struct {
+	db    *sql.DB
+	query string
+}
+
+// OpenSQL returns a source running query on the server at dsn
+func OpenSQL(dsn, query string) (*SQLSource, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open extract database: %w", err)
+	}
+	return &SQLSource{db: db, query: query}, nil
+}
+
+// Read runs the query
+func (s *SQLSource) Read(ctx context.Context, fn func(Value) error) error {
+	rows, err := s.db.QueryContext(ctx, s.query)
+	if err != nil {
+		return fmt.Errorf("failed to query extract: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v Value
+		if err := rows.Scan(&v.ID, &v.Amount); err != nil {
+			return fmt.Errorf("failed to scan extract row: %w", err)
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Close closes the connection pool
+func (s *SQLSource) Close() error {
+	return s.db.Close()
+}
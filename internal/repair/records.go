@@ -0,0 +1,104 @@
+package repair
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Records keeps repair plans by token
+type Records interface {
+	Save(ctx context.Context, record *Record) error
+	// Load returns nil for an unknown token
+	Load(ctx context.Context, token string) (*Record, error)
+}
+
+// KeyPrefix starts the Redis keys of repair records
+const KeyPrefix = "transisidb:repair"
+
+// RecordTTL is how long Redis keeps a repair record after its last change
+const RecordTTL = 30 * 24 * time.Hour
+
+// RedisRecords stores records as JSON in Redis, one key per token
+type RedisRecords struct {
+	client redis.UniversalClient
+}
+
+// NewRedisRecords returns records stored with client
+func NewRedisRecords(client redis.UniversalClient) *RedisRecords {
+	return &RedisRecords{client: client}
+}
+
+// Save replaces the plan's record
+func (s *RedisRecords) Save(ctx context.Context, record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal repair record: %w", err)
+	}
+	if err := s.client.Set(ctx, KeyPrefix+":"+record.Token, data, RecordTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save repair record: %w", err)
+	}
+	return nil
+}
+
+// Load returns the plan's record
+func (s *RedisRecords) Load(ctx context.Context, token string) (*Record, error) {
+	data, err := s.client.Get(ctx, KeyPrefix+":"+token).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load repair record: %w", err)
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal repair record: %w", err)
+	}
+	return &record, nil
+}
+
+// MemoryRecords keeps records in process, for tests and single-node setups
+type MemoryRecords struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryRecords returns empty in-memory records
+func NewMemoryRecords() *MemoryRecords {
+	return &MemoryRecords{records: make(map[string]Record)}
+}
+
+// Save replaces the plan's record
+func (s *MemoryRecords) Save(ctx context.Context, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := *record
+	saved.Columns = copyColumns(record.Columns)
+	s.records[record.Token] = saved
+	return nil
+}
+
+// Load returns the plan's record
+func (s *MemoryRecords) Load(ctx context.Context, token string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved, ok := s.records[token]
+	if !ok {
+		return nil, nil
+	}
+	saved.Columns = copyColumns(saved.Columns)
+	return &saved, nil
+}
+
+func copyColumns(columns []Column) []Column {
+	copied := append([]Column(nil), columns...)
+	for i := range copied {
+		copied[i].Samples = append([]Row(nil), copied[i].Samples...)
+	}
+	return copied
+}
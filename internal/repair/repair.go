@@ -0,0 +1,465 @@
+// Package repair rewrites the currency values that reconciliation reports as
+// mismatched. A repair is planned first: the plan counts and samples the
+// mismatched rows without changing them and returns a token. Applying the
+// plan with its token rewrites the rows in throttled batches, recording how
+// far it got, so an interrupted repair is resumed by applying it again.
+package repair
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/backfill"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/lock"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+// Authorities, the side of a mismatch that is kept
+const (
+	// AuthorityIDR rewrites the shadow value from the source value
+	AuthorityIDR = "idr"
+	// AuthorityIDN rewrites the source value from the shadow value, for
+	// tables whose applications already treat the shadow column as the truth
+	AuthorityIDN = "idn"
+)
+
+// Statuses of a repair
+const (
+	StatusPlanned   = "planned"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Option defaults and limits
+const (
+	DefaultBatchSize    = 500
+	DefaultBatchDelayMs = 100
+	MaxBatchSize        = 10000
+	// SampleRows is how many mismatched rows a plan shows per column
+	SampleRows = 10
+	// PlanTTL is how long a plan can be applied after it was made
+	PlanTTL = time.Hour
+)
+
+var (
+	// ErrUnknownPlan is returned for a token that names no plan of the table
+	ErrUnknownPlan = errs.New(errs.ConfigNotFound, "unknown repair plan")
+	// ErrExpired is returned when applying a plan made more than PlanTTL ago
+	ErrExpired = errs.New(errs.ConfigConflict, "repair plan has expired, plan the repair again")
+	// ErrApplied is returned when applying a plan that already finished
+	ErrApplied = errs.New(errs.ConfigConflict, "repair plan has already been applied")
+	// ErrRunning is returned when the plan is being applied, or the table is
+	// locked by a backfill, onboarding or another repair
+	ErrRunning = errs.New(errs.ConfigConflict, "table is already being repaired")
+	// ErrInvalidOptions is returned for options out of range
+	ErrInvalidOptions = errs.New(errs.ConfigInvalid, "invalid repair options")
+)
+
+// Options control a repair
+type Options struct {
+	// Authority is the side kept, idr (default) or idn
+	Authority string `json:"authority"`
+	// BatchSize is the number of mismatched rows rewritten per batch
+	BatchSize int `json:"batch_size"`
+	// BatchDelayMs pauses between batches to limit the load on the database
+	BatchDelayMs int `json:"batch_delay_ms"`
+}
+
+// withDefaults fills unset options and checks their ranges
+func (o Options) withDefaults() (Options, error) {
+	if o.Authority == "" {
+		o.Authority = AuthorityIDR
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.BatchDelayMs == 0 {
+		o.BatchDelayMs = DefaultBatchDelayMs
+	}
+	if o.Authority != AuthorityIDR && o.Authority != AuthorityIDN {
+		return o, fmt.Errorf("%w: authority must be %s or %s, got %q", ErrInvalidOptions, AuthorityIDR, AuthorityIDN, o.Authority)
+	}
+	if o.BatchSize < 0 || o.BatchSize > MaxBatchSize {
+		return o, fmt.Errorf("%w: batch_size must be between 1 and %d, got %d", ErrInvalidOptions, MaxBatchSize, o.BatchSize)
+	}
+	if o.BatchDelayMs < 0 {
+		return o, fmt.Errorf("%w: batch_delay_ms must not be negative, got %d", ErrInvalidOptions, o.BatchDelayMs)
+	}
+	return o, nil
+}
+
+// Row is a mismatched row and the value a repair writes to it
+type Row struct {
+	ID     int64   `json:"id"`
+	Source float64 `json:"source"`
+	Shadow float64 `json:"shadow"`
+	// Repaired is the value written: the shadow value when IDR is the
+	// authority, the source value when IDN is. It is null when the shadow
+	// column cannot hold the converted source value; apply skips the row.
+	Repaired *float64 `json:"repaired"`
+
+	// source is Source as it was read, value Repaired as it is written
+	source, value string
+}
+
+// Column is the plan and progress of one currency column
+type Column struct {
+	Column string `json:"column"`
+	Target string `json:"target"`
+	// Mismatched is the number of mismatched rows when the plan was made
+	Mismatched int64 `json:"mismatched"`
+	// Missing rows have no shadow value; they are left to the backfill
+	Missing int64 `json:"missing"`
+	Samples []Row `json:"samples,omitempty"`
+	// Cursor is the last id the repair walked past, where it resumes
+	Cursor   int64 `json:"cursor"`
+	Repaired int64 `json:"repaired"`
+	// Skipped rows changed since they were read or cannot be converted
+	Skipped int64 `json:"skipped"`
+	Done    bool  `json:"done"`
+}
+
+// Record is a repair plan and the progress of applying it
+type Record struct {
+	Token     string     `json:"token"`
+	Table     string     `json:"table"`
+	Status    string     `json:"status"`
+	Options   Options    `json:"options"`
+	PlannedBy string     `json:"planned_by"`
+	Planned   time.Time  `json:"planned"`
+	Expires   time.Time  `json:"expires"`
+	AppliedBy string     `json:"applied_by,omitempty"`
+	Applied   *time.Time `json:"applied,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	Columns   []Column   `json:"columns"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Tables reads table configs and records audit entries; *config.RedisStore
+// implements it
+type Tables interface {
+	LoadTableConfig(ctx context.Context, tableName string) (*config.TableConfig, error)
+	AppendAudit(ctx context.Context, entry config.AuditEntry) error
+}
+
+// Repairer plans and applies repairs
+type Repairer struct {
+	db      *sql.DB
+	tables  Tables
+	records Records
+	cfg     *config.Config
+	now     func() time.Time
+
+	// locks keeps backfills, onboardings and other repairs off the table
+	// while it is repaired, nil disables it
+	locks lock.Locker
+
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+// New returns a repairer for the tables of cfg's database
+func New(db *sql.DB, cfg *config.Config, tables Tables, records Records) *Repairer {
+	return &Repairer{
+		db:      db,
+		tables:  tables,
+		records: records,
+		cfg:     cfg,
+		now:     time.Now,
+		running: make(map[string]bool),
+	}
+}
+
+// SetLocker makes repairs hold the table's lock, shared with backfill jobs
+// and onboarding
+func (r *Repairer) SetLocker(locker lock.Locker) {
+	r.locks = locker
+}
+
+// Plan counts and samples the mismatched rows of a table, changing nothing,
+// and saves the plan. Its token is needed to apply it.
+func (r *Repairer) Plan(ctx context.Context, table string, opts Options, actor string) (*Record, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	tableConfig, err := r.tables.LoadTableConfig(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if r.db == nil {
+		return nil, errs.New(errs.BackendUnavailable, "database is not available")
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	planned := r.now()
+	record := &Record{
+		Token:     token,
+		Table:     table,
+		Status:    StatusPlanned,
+		Options:   opts,
+		PlannedBy: actor,
+		Planned:   planned,
+		Expires:   planned.Add(PlanTTL),
+	}
+	var mismatched int64
+	for _, column := range currencyColumns(*tableConfig) {
+		colConfig := tableConfig.Columns[column]
+		missing, count, err := backfill.CountDrift(ctx, r.db, table, column, colConfig, r.cfg.Conversion)
+		if err != nil {
+			return nil, errs.Wrap(errs.BackendQuery, err, "failed to count mismatches of %s", column)
+		}
+		samples, err := r.mismatches(ctx, table, column, colConfig, opts.Authority, 0, SampleRows)
+		if err != nil {
+			return nil, err
+		}
+		record.Columns = append(record.Columns, Column{
+			Column:     column,
+			Target:     colConfig.TargetColumn,
+			Mismatched: count,
+			Missing:    missing,
+			Samples:    samples,
+			Done:       count == 0,
+		})
+		mismatched += count
+	}
+	if err := r.records.Save(ctx, record); err != nil {
+		return nil, err
+	}
+
+	r.audit(ctx, actor, "plan_repair", table, fmt.Sprintf("token %s: %d mismatched rows, %s authoritative", token, mismatched, opts.Authority))
+	return record, nil
+}
+
+// Apply starts rewriting the rows of a plan in the background; Status
+// reports its progress. A plan that was interrupted or failed resumes where
+// it stopped.
+func (r *Repairer) Apply(ctx context.Context, table, token, actor string) (*Record, error) {
+	record, err := r.records.Load(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.Table != table {
+		return nil, ErrUnknownPlan
+	}
+	switch {
+	case record.Status == StatusSucceeded:
+		return nil, ErrApplied
+	case record.Status == StatusPlanned && r.now().After(record.Expires):
+		return nil, ErrExpired
+	}
+	tableConfig, err := r.tables.LoadTableConfig(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if r.db == nil {
+		return nil, errs.New(errs.BackendUnavailable, "database is not available")
+	}
+
+	r.mu.Lock()
+	if r.running[token] {
+		r.mu.Unlock()
+		return nil, ErrRunning
+	}
+	r.running[token] = true
+	r.mu.Unlock()
+
+	var lease lock.Lease
+	if r.locks != nil {
+		if lease, err = r.locks.Acquire(ctx, lock.Table(table), lock.Owner(), lock.DefaultTTL); err != nil {
+			r.done(token)
+			if errors.Is(err, lock.ErrHeld) {
+				return nil, fmt.Errorf("%w: %w", ErrRunning, err)
+			}
+			return nil, err
+		}
+	}
+
+	resumed := record.Applied != nil
+	applied := r.now()
+	record.Status, record.AppliedBy, record.Applied, record.Error = StatusRunning, actor, &applied, ""
+	if err := r.records.Save(ctx, record); err != nil {
+		r.release(ctx, lease)
+		r.done(token)
+		return nil, err
+	}
+	action := "apply_repair"
+	if resumed {
+		action = "resume_repair"
+	}
+	r.audit(ctx, actor, action, table, "token "+token)
+
+	snapshot := *record
+	snapshot.Columns = copyColumns(record.Columns)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer r.done(token)
+		ctx := context.WithoutCancel(ctx)
+		if r.locks == nil {
+			r.execute(ctx, record, *tableConfig)
+			return
+		}
+		// Batches stop at the lease's cancellation if another host takes the lock
+		lock.Keep(ctx, r.locks, lease, lock.DefaultTTL, func(ctx context.Context, lease lock.Lease) error {
+			r.execute(ctx, record, *tableConfig)
+			return nil
+		})
+	}()
+	return &snapshot, nil
+}
+
+// Status returns a plan of a table and its progress, nil for an unknown token
+func (r *Repairer) Status(ctx context.Context, table, token string) (*Record, error) {
+	record, err := r.records.Load(ctx, token)
+	if err != nil || record == nil || record.Table != table {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Wait waits for running repairs to finish
+func (r *Repairer) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Repairer) done(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.running, token)
+}
+
+// release gives up a lease of a repair that did not start
+func (r *Repairer) release(ctx context.Context, lease lock.Lease) {
+	if r.locks == nil {
+		return
+	}
+	if err := r.locks.Release(ctx, lease); err != nil {
+		logger.Warn("Failed to release lock", "lock", lease.Name, "error", err)
+	}
+}
+
+// execute repairs the columns in order, saving the record after each batch,
+// and stops at the first failure
+func (r *Repairer) execute(ctx context.Context, record *Record, tableConfig config.TableConfig) {
+	logger.Info("Repairing table", "table", record.Table, "token", record.Token, "authority", record.Options.Authority)
+	var err error
+	for i := range record.Columns {
+		current := &record.Columns[i]
+		if current.Done {
+			continue
+		}
+		colConfig, ok := tableConfig.Columns[current.Column]
+		if !ok {
+			err = fmt.Errorf("column %s is no longer configured", current.Column)
+			break
+		}
+		if err = r.repairColumn(ctx, record, current, colConfig); err != nil {
+			break
+		}
+	}
+
+	finished := r.now()
+	record.Finished = &finished
+	record.Status = StatusSucceeded
+	detail := "token " + record.Token + ": succeeded"
+	if err != nil {
+		record.Status, record.Error = StatusFailed, err.Error()
+		detail = "token " + record.Token + ": failed: " + err.Error()
+		logger.Error("Repair failed", "table", record.Table, "token", record.Token, "error", err)
+	}
+	r.save(ctx, record)
+	r.audit(ctx, record.AppliedBy, "finish_repair", record.Table, detail)
+	logger.Info("Repair finished", "table", record.Table, "token", record.Token, "status", record.Status)
+}
+
+// repairColumn rewrites the column's mismatched rows in batches from its
+// cursor until none are left
+func (r *Repairer) repairColumn(ctx context.Context, record *Record, column *Column, colConfig config.ColumnConfig) error {
+	delay := time.Duration(record.Options.BatchDelayMs) * time.Millisecond
+	for {
+		rows, err := r.mismatches(ctx, record.Table, column.Column, colConfig, record.Options.Authority, column.Cursor, record.Options.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			column.Done = true
+			r.save(ctx, record)
+			return nil
+		}
+
+		var repaired []int64
+		for _, row := range rows {
+			ok, err := r.rewrite(ctx, record.Table, column.Column, colConfig, record.Options.Authority, row)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				column.Skipped++
+				continue
+			}
+			repaired = append(repaired, row.ID)
+			logger.Info("Repaired row", "table", record.Table, "column", column.Column, "id", row.ID,
+				"source", row.Source, "shadow", row.Shadow, "repaired", row.value, "authority", record.Options.Authority, "token", record.Token)
+		}
+		column.Cursor = rows[len(rows)-1].ID
+		column.Repaired += int64(len(repaired))
+		r.save(ctx, record)
+		if len(repaired) > 0 {
+			r.audit(ctx, record.AppliedBy, "repair_rows", record.Table, fmt.Sprintf("token %s: %s ids %s", record.Token, column.Column, formatIDs(repaired)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (r *Repairer) save(ctx context.Context, record *Record) {
+	if err := r.records.Save(ctx, record); err != nil {
+		logger.Warn("Failed to record repair progress", "table", record.Table, "token", record.Token, "error", err)
+	}
+}
+
+func (r *Repairer) audit(ctx context.Context, actor, action, table, detail string) {
+	entry := config.AuditEntry{Time: r.now(), Actor: actor, Action: action, Target: table, Detail: detail}
+	if err := r.tables.AppendAudit(ctx, entry); err != nil {
+		logger.Warn("Failed to record audit entry", "action", action, "table", table, "error", err)
+	}
+}
+
+// currencyColumns returns the currency columns of a table in name order
+func currencyColumns(tableConfig config.TableConfig) []string {
+	columns := make([]string, 0, len(tableConfig.Columns))
+	for column := range tableConfig.Columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// newToken returns a random plan token
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate repair token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
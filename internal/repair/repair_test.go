@@ -0,0 +1,146 @@
+package repair
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTables has an orders table and records audit entries
+type fakeTables struct {
+	audit []config.AuditEntry
+}
+
+func (f *fakeTables) LoadTableConfig(ctx context.Context, tableName string) (*config.TableConfig, error) {
+	if tableName != "orders" {
+		return nil, errors.New("table not found")
+	}
+	return &config.TableConfig{Columns: map[string]config.ColumnConfig{
+		"total_amount": {TargetColumn: "total_amount_idn", Precision: 4},
+	}}, nil
+}
+
+func (f *fakeTables) AppendAudit(ctx context.Context, entry config.AuditEntry) error {
+	f.audit = append(f.audit, entry)
+	return nil
+}
+
+func testConfig() *config.Config {
+	return &config.Config{Conversion: config.ConversionConfig{Ratio: 1000, Precision: 4, RoundingStrategy: "BANKERS_ROUND"}}
+}
+
+func TestOptions_WithDefaults(t *testing.T) {
+	opts, err := Options{}.withDefaults()
+	require.NoError(t, err)
+	assert.Equal(t, Options{Authority: AuthorityIDR, BatchSize: DefaultBatchSize, BatchDelayMs: DefaultBatchDelayMs}, opts)
+
+	for _, invalid := range []Options{
+		{Authority: "usd"},
+		{BatchSize: -1},
+		{BatchSize: MaxBatchSize + 1},
+		{BatchDelayMs: -5},
+	} {
+		_, err := invalid.withDefaults()
+		assert.ErrorIs(t, err, ErrInvalidOptions, "%+v", invalid)
+	}
+}
+
+func TestRepairer_RepairedValue(t *testing.T) {
+	r := New(nil, testConfig(), &fakeTables{}, NewMemoryRecords())
+	row := Row{ID: 1, Source: 150500, Shadow: 150}
+	colConfig := config.ColumnConfig{TargetType: "DECIMAL(15,4)"}
+
+	value, err := r.repairedValue(row, colConfig, AuthorityIDR)
+	require.NoError(t, err)
+	assert.Equal(t, "150.5000", value)
+	value, err = r.repairedValue(row, colConfig, AuthorityIDN)
+	require.NoError(t, err)
+	assert.Equal(t, "150000", value)
+	value, err = r.repairedValue(Row{ID: 3, Source: 150500.5}, colConfig, AuthorityIDR)
+	require.NoError(t, err)
+	assert.Equal(t, "150.5005", value)
+
+	// The column's own rounding applies, as in dual-write
+	value, err = r.repairedValue(Row{ID: 2, Source: 1250}, config.ColumnConfig{RoundingStrategy: "ARITHMETIC_ROUND", Precision: 1}, AuthorityIDR)
+	require.NoError(t, err)
+	assert.Equal(t, "1.3", value)
+
+	_, err = r.repairedValue(row, config.ColumnConfig{TargetType: "DECIMAL(4,2)"}, AuthorityIDR)
+	assert.Error(t, err)
+}
+
+func TestRepairer_Plan_NeedsDatabase(t *testing.T) {
+	r := New(nil, testConfig(), &fakeTables{}, NewMemoryRecords())
+	ctx := context.Background()
+
+	_, err := r.Plan(ctx, "orders", Options{Authority: "usd"}, "test")
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+
+	_, err = r.Plan(ctx, "invoices", Options{}, "test")
+	assert.Error(t, err)
+
+	_, err = r.Plan(ctx, "orders", Options{}, "test")
+	assert.Equal(t, errs.BackendUnavailable, errs.CodeOf(err))
+}
+
+func TestRepairer_Apply_NeedsValidToken(t *testing.T) {
+	records := NewMemoryRecords()
+	tables := &fakeTables{}
+	r := New(nil, testConfig(), tables, records)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	save := func(token, status string, planned time.Time) {
+		require.NoError(t, records.Save(ctx, &Record{
+			Token: token, Table: "orders", Status: status, Planned: planned, Expires: planned.Add(PlanTTL),
+		}))
+	}
+	save("fresh", StatusPlanned, now.Add(-time.Minute))
+	save("stale", StatusPlanned, now.Add(-2*PlanTTL))
+	save("done", StatusSucceeded, now.Add(-2*PlanTTL))
+	save("failed", StatusFailed, now.Add(-2*PlanTTL))
+
+	_, err := r.Apply(ctx, "orders", "unknown", "test")
+	assert.ErrorIs(t, err, ErrUnknownPlan)
+	_, err = r.Apply(ctx, "invoices", "fresh", "test")
+	assert.ErrorIs(t, err, ErrUnknownPlan, "a token only applies to its table")
+	_, err = r.Apply(ctx, "orders", "stale", "test")
+	assert.ErrorIs(t, err, ErrExpired)
+	_, err = r.Apply(ctx, "orders", "done", "test")
+	assert.ErrorIs(t, err, ErrApplied)
+
+	// Valid plans, including a failed one that resumes after the TTL,
+	// get as far as the database
+	for _, token := range []string{"fresh", "failed"} {
+		_, err = r.Apply(ctx, "orders", token, "test")
+		assert.Equal(t, errs.BackendUnavailable, errs.CodeOf(err), token)
+	}
+	assert.Empty(t, tables.audit)
+}
+
+func TestRepairer_Status(t *testing.T) {
+	records := NewMemoryRecords()
+	r := New(nil, testConfig(), &fakeTables{}, records)
+	ctx := context.Background()
+	require.NoError(t, records.Save(ctx, &Record{Token: "abc", Table: "orders", Columns: []Column{{Column: "total_amount", Cursor: 42}}}))
+
+	record, err := r.Status(ctx, "orders", "abc")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, int64(42), record.Columns[0].Cursor)
+
+	record, err = r.Status(ctx, "invoices", "abc")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+}
+
+func TestFormatIDs(t *testing.T) {
+	assert.Equal(t, "3,7,12", formatIDs([]int64{3, 7, 12}))
+}
@@ -0,0 +1,94 @@
+package repair
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/converter"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// mismatches reads up to limit mismatched rows of a column after an id, with
+// the value a repair writes to them. The condition is the one
+// backfill.CountDrift counts.
+func (r *Repairer) mismatches(ctx context.Context, table, column string, colConfig config.ColumnConfig, authority string, afterID int64, limit int) ([]Row, error) {
+	source, target := parser.QuoteIdentifier(column), parser.QuoteIdentifier(colConfig.TargetColumn)
+	query := fmt.Sprintf(
+		"SELECT id, %s, %s FROM %s WHERE id > ? AND %s IS NOT NULL AND %s IS NOT NULL AND ABS(%s - %s / ?) >= ? ORDER BY id LIMIT %d",
+		source, target, parser.QuoteTable(table), source, target, target, source, limit)
+
+	tolerance := converter.ShadowTolerance(r.cfg.Conversion, colConfig)
+	rows, err := r.db.QueryContext(ctx, query, afterID, r.cfg.Conversion.Ratio, tolerance)
+	if err != nil {
+		return nil, errs.Wrap(errs.BackendQuery, err, "failed to read mismatched rows of %s", column)
+	}
+	defer rows.Close()
+
+	var result []Row
+	for rows.Next() {
+		var row Row
+		// DECIMAL sources may hold fractional amounts
+		if err := rows.Scan(&row.ID, &row.source, &row.Shadow); err != nil {
+			return nil, errs.Wrap(errs.BackendQuery, err, "failed to scan row")
+		}
+		if row.Source, err = converter.ParseStoredAmount(row.source); err != nil {
+			return nil, errs.Wrap(errs.BackendQuery, err, "failed to read the amount of row %d", row.ID)
+		}
+		if row.value, err = r.repairedValue(row, colConfig, authority); err == nil {
+			repaired, _ := strconv.ParseFloat(row.value, 64)
+			row.Repaired = &repaired
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.Wrap(errs.BackendQuery, err, "row iteration error")
+	}
+	return result, nil
+}
+
+// repairedValue is the value written to the side of a row that is not the
+// authority: the shadow value dual-write writes for the source value, or the
+// source value the shadow value converts back to. It fails when the shadow
+// column cannot hold the converted value.
+func (r *Repairer) repairedValue(row Row, colConfig config.ColumnConfig, authority string) (string, error) {
+	if authority == AuthorityIDN {
+		return strconv.FormatFloat(math.Round(row.Shadow*float64(r.cfg.Conversion.Ratio)), 'f', 0, 64), nil
+	}
+	return converter.ShadowValue(r.cfg.Conversion, colConfig, row.Source)
+}
+
+// rewrite writes the repaired value of a row unless the row changed since it
+// was read, and reports whether it did. Shadow values the target column
+// cannot hold are left for an operator.
+func (r *Repairer) rewrite(ctx context.Context, table, column string, colConfig config.ColumnConfig, authority string, row Row) (bool, error) {
+	if row.Repaired == nil {
+		return false, nil
+	}
+	source, target := parser.QuoteIdentifier(column), parser.QuoteIdentifier(colConfig.TargetColumn)
+	set := target
+	if authority == AuthorityIDN {
+		set = source
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ? AND %s = ? AND %s = ?", parser.QuoteTable(table), set, source, target)
+	result, err := r.db.ExecContext(ctx, query, row.value, row.ID, row.source, row.Shadow)
+	if err != nil {
+		return false, errs.Wrap(errs.BackendQuery, err, "failed to repair row %d", row.ID)
+	}
+	affected, err := result.RowsAffected()
+	return err == nil && affected > 0, nil
+}
+
+// formatIDs lists row ids for the audit log
+func formatIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
@@ -70,15 +70,36 @@ func (e *Engine) bankersRound(value float64) float64 {
 }
 
 // arithmeticRound implements standard arithmetic rounding (round half up)
+// Halfway values round away from zero, like math.Round
 func (e *Engine) arithmeticRound(value float64) float64 {
 	multiplier := math.Pow(10, float64(e.precision))
-	return math.Round(value*multiplier) / multiplier
+	adjusted := math.Abs(value * multiplier)
+	floor := math.Floor(adjusted)
+
+	// Same halfway tolerance as bankersRound: 0.145 is stored as
+	// 0.14499999... and must still round up
+	const epsilon = 1e-9
+
+	rounded := floor
+	if adjusted-floor >= 0.5-epsilon {
+		rounded = floor + 1
+	}
+	if value < 0 {
+		rounded = -rounded
+	}
+	return rounded / multiplier
 }
 
 // ConvertIDRtoIDN converts IDR (integer) to IDN (decimal) with rounding
 func (e *Engine) ConvertIDRtoIDN(idrValue int64, ratio int) float64 {
-	// Convert to float and divide by ratio
-	idnValue := float64(idrValue) / float64(ratio)
+	return e.ConvertAmountIDRtoIDN(float64(idrValue), ratio)
+}
+
+// ConvertAmountIDRtoIDN converts a possibly fractional IDR amount to IDN with
+// rounding, so fractional inputs are not truncated before the division
+func (e *Engine) ConvertAmountIDRtoIDN(idrValue float64, ratio int) float64 {
+	// Divide by ratio
+	idnValue := idrValue / float64(ratio)
 	
 	// Round according to strategy
 	return e.Round(idnValue)
@@ -0,0 +1,49 @@
+package rounding
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxTieUnits bounds the ties CheckTie can decide: beyond it the binary
+// representation error of a halfway value exceeds the halfway tolerance
+const MaxTieUnits = 1_000_000
+
+// CheckRoundTrip converts an IDR amount to IDN and back and returns an error
+// when the result is more than one rupiah from the amount. It holds when the
+// precision keeps at least log10(ratio) decimals.
+func (e *Engine) CheckRoundTrip(idr float64, ratio int) error {
+	idn := e.ConvertAmountIDRtoIDN(idr, ratio)
+	back := idn * float64(ratio)
+	if math.Abs(back-idr) > 1+math.Abs(idr)*1e-15 {
+		return fmt.Errorf("%v IDR converts to %v IDN, which is %v IDR", idr, idn, back)
+	}
+	return nil
+}
+
+// CheckTie rounds the value halfway between units and units+1 steps of the
+// engine's precision and returns an error unless it lands where the strategy
+// puts ties: on the even step for banker's rounding, away from zero for
+// arithmetic rounding.
+func (e *Engine) CheckTie(units int64) error {
+	if units > MaxTieUnits || units < -MaxTieUnits {
+		return fmt.Errorf("tie %d is beyond %d units", units, MaxTieUnits)
+	}
+	multiplier := math.Pow(10, float64(e.precision))
+	value := (float64(units) + 0.5) / multiplier
+
+	want := units + 1
+	switch {
+	case e.strategy == ArithmeticRound && value < 0:
+		want = units
+	case e.strategy != ArithmeticRound && units%2 == 0:
+		want = units
+	}
+
+	got := e.Round(value) * multiplier
+	if math.Abs(got-float64(want)) > 1e-6 {
+		return fmt.Errorf("%s rounds %v to %v, want %v",
+			e.strategy, value, got/multiplier, float64(want)/multiplier)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package rounding
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	strategies = []Strategy{BankersRound, ArithmeticRound}
+	ratios     = []int{1, 10, 100, 1000, 10000}
+)
+
+// precisionFor returns the smallest precision keeping every digit the ratio
+// divides away, which round trips need
+func precisionFor(ratio int) int {
+	return int(math.Ceil(math.Log10(float64(ratio))))
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	for _, seed := range []float64{0, 1, 499, 500, 1500, 1500500, -2500, 123456789.5, 99999999999999} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, idr float64) {
+		// DECIMAL(19,4) shadow columns hold IDN amounts below 10^15
+		if math.IsNaN(idr) || math.IsInf(idr, 0) || math.Abs(idr) >= 1e14 {
+			t.Skip()
+		}
+		for _, strategy := range strategies {
+			for _, ratio := range ratios {
+				for _, extra := range []int{0, 2} {
+					engine := NewEngine(strategy, precisionFor(ratio)+extra)
+					if err := engine.CheckRoundTrip(idr, ratio); err != nil {
+						t.Errorf("ratio %d precision %d: %v", ratio, engine.precision, err)
+					}
+				}
+			}
+		}
+	})
+}
+
+func FuzzTie(f *testing.F) {
+	for _, seed := range []int64{0, 1, 2, 14, 15, -1, -2, -15, MaxTieUnits} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, units int64) {
+		units %= MaxTieUnits
+		for _, strategy := range strategies {
+			for _, precision := range []int{0, 2, 4, 6} {
+				if err := NewEngine(strategy, precision).CheckTie(units); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	})
+}
+
+func TestCheckRoundTrip_PrecisionTooLow(t *testing.T) {
+	// Two decimals of IDN are ten rupiah at a ratio of 1000
+	if err := NewEngine(BankersRound, 2).CheckRoundTrip(1504, 1000); err == nil {
+		t.Error("expected a round trip losing four rupiah to fail")
+	}
+	if err := NewEngine(BankersRound, 3).CheckRoundTrip(1504, 1000); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckTie(t *testing.T) {
+	tests := []struct {
+		strategy Strategy
+		value    float64
+		want     float64
+	}{
+		{BankersRound, 0.145, 0.14},
+		{BankersRound, 0.155, 0.16},
+		{BankersRound, -0.145, -0.14},
+		{ArithmeticRound, 0.145, 0.15},
+		{ArithmeticRound, -0.145, -0.15},
+		{ArithmeticRound, 1.005, 1.01},
+	}
+	for _, tt := range tests {
+		if got := NewEngine(tt.strategy, 2).Round(tt.value); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s Round(%v) = %v, want %v", tt.strategy, tt.value, got, tt.want)
+		}
+	}
+	if err := NewEngine(BankersRound, 4).CheckTie(MaxTieUnits + 1); err == nil {
+		t.Error("expected ties beyond MaxTieUnits to be refused")
+	}
+}
@@ -0,0 +1,183 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+)
+
+// MatchContext describes the statement being evaluated
+type MatchContext struct {
+	Query  string
+	User   string
+	Schema string
+}
+
+// Result is the outcome of evaluating all rules against a statement
+type Result struct {
+	// Query is the statement after rewrite/comment actions were applied
+	Query        string
+	Rewritten    bool
+	Blocked      bool
+	ErrorMessage string
+	MatchedRules []int
+}
+
+// compiledRule is a QueryRule with its regular expressions compiled
+type compiledRule struct {
+	config.QueryRule
+	pattern *regexp.Regexp
+	digest  *regexp.Regexp
+	hits    atomic.Uint64
+}
+
+// Engine evaluates query rules against incoming statements. Its rule set can
+// be replaced at runtime; sessions sharing an engine see the new rules on
+// their next statement.
+type Engine struct {
+	mu    sync.RWMutex
+	rules []*compiledRule
+}
+
+// NewEngine compiles the given rules into an engine
+func NewEngine(queryRules []config.QueryRule) (*Engine, error) {
+	compiled, err := compile(queryRules)
+	if err != nil {
+		return nil, err
+	}
+	return &Engine{rules: compiled}, nil
+}
+
+// Replace compiles and atomically swaps in a new rule set. On error the
+// current rules stay in place.
+func (e *Engine) Replace(queryRules []config.QueryRule) error {
+	compiled, err := compile(queryRules)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.rules = compiled
+	e.mu.Unlock()
+	return nil
+}
+
+// compile validates the rules and returns the active ones sorted by ID
+func compile(queryRules []config.QueryRule) ([]*compiledRule, error) {
+	if err := config.ValidateQueryRules(queryRules); err != nil {
+		return nil, err
+	}
+
+	compiled := make([]*compiledRule, 0, len(queryRules))
+	for _, rule := range queryRules {
+		if !rule.Active {
+			continue
+		}
+
+		cr := &compiledRule{QueryRule: rule}
+		if rule.MatchPattern != "" {
+			cr.pattern = regexp.MustCompile(rule.MatchPattern)
+		}
+		if rule.MatchDigest != "" {
+			cr.digest = regexp.MustCompile(rule.MatchDigest)
+		}
+		compiled = append(compiled, cr)
+	}
+
+	sort.Slice(compiled, func(i, j int) bool {
+		return compiled[i].ID < compiled[j].ID
+	})
+
+	return compiled, nil
+}
+
+// Len returns the number of active rules
+func (e *Engine) Len() int {
+	return len(e.snapshot())
+}
+
+// snapshot returns the current rule set
+func (e *Engine) snapshot() []*compiledRule {
+	if e == nil {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rules
+}
+
+// Evaluate runs all active rules against the statement
+func (e *Engine) Evaluate(mc MatchContext) *Result {
+	result := &Result{Query: mc.Query}
+	compiled := e.snapshot()
+	if len(compiled) == 0 {
+		return result
+	}
+
+	// The digest is computed lazily and refreshed after rewrites
+	var digest string
+	digestValid := false
+
+	for _, rule := range compiled {
+		if rule.User != "" && rule.User != mc.User {
+			continue
+		}
+		if rule.Schema != "" && rule.Schema != mc.Schema {
+			continue
+		}
+		if rule.pattern != nil && !rule.pattern.MatchString(result.Query) {
+			continue
+		}
+		if rule.digest != nil {
+			if !digestValid {
+				digest = parser.Fingerprint(result.Query)
+				digestValid = true
+			}
+			if !rule.digest.MatchString(digest) {
+				continue
+			}
+		}
+
+		rule.hits.Add(1)
+		metrics.RecordQueryRuleHit(rule.ID, rule.Action)
+		result.MatchedRules = append(result.MatchedRules, rule.ID)
+
+		switch rule.Action {
+		case config.RuleActionRewrite:
+			result.Query = rule.pattern.ReplaceAllString(result.Query, rule.ReplacePattern)
+			result.Rewritten = true
+			digestValid = false
+		case config.RuleActionComment:
+			result.Query = fmt.Sprintf("/* %s */ %s", rule.Comment, result.Query)
+			result.Rewritten = true
+		case config.RuleActionBlock:
+			result.Blocked = true
+			result.ErrorMessage = rule.ErrorMessage
+			if result.ErrorMessage == "" {
+				result.ErrorMessage = fmt.Sprintf("Query blocked by TransisiDB rule %d", rule.ID)
+			}
+			return result
+		}
+
+		if rule.Apply {
+			break
+		}
+	}
+
+	return result
+}
+
+// Stats returns hit counts per rule ID
+func (e *Engine) Stats() map[int]uint64 {
+	stats := make(map[int]uint64)
+	for _, rule := range e.snapshot() {
+		stats[rule.ID] = rule.hits.Load()
+	}
+	return stats
+}
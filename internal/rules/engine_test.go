@@ -0,0 +1,102 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineRewrite(t *testing.T) {
+	engine, err := NewEngine([]config.QueryRule{
+		{
+			ID:             1,
+			Active:         true,
+			MatchPattern:   `(?i)^SELECT \* FROM orders`,
+			Action:         config.RuleActionRewrite,
+			ReplacePattern: "SELECT id, total_amount FROM orders",
+		},
+	})
+	require.NoError(t, err)
+
+	result := engine.Evaluate(MatchContext{Query: "SELECT * FROM orders WHERE id = 1"})
+	assert.True(t, result.Rewritten)
+	assert.Equal(t, "SELECT id, total_amount FROM orders WHERE id = 1", result.Query)
+	assert.Equal(t, []int{1}, result.MatchedRules)
+}
+
+func TestEngineBlockStopsEvaluation(t *testing.T) {
+	engine, err := NewEngine([]config.QueryRule{
+		{ID: 20, Active: true, MatchPattern: ".*", Action: config.RuleActionComment, Comment: "never"},
+		{ID: 10, Active: true, MatchDigest: `^DELETE FROM orders$`, Action: config.RuleActionBlock},
+	})
+	require.NoError(t, err)
+
+	result := engine.Evaluate(MatchContext{Query: "DELETE FROM orders"})
+	assert.True(t, result.Blocked)
+	assert.Contains(t, result.ErrorMessage, "rule 10")
+	assert.Equal(t, []int{10}, result.MatchedRules)
+	assert.Equal(t, "DELETE FROM orders", result.Query)
+}
+
+func TestEngineUserSchemaAndApply(t *testing.T) {
+	engine, err := NewEngine([]config.QueryRule{
+		{ID: 1, Active: true, User: "bi_reader", MatchDigest: `^SELECT`, Action: config.RuleActionComment, Comment: "source=bi", Apply: true},
+		{ID: 2, Active: true, Schema: "ecommerce_db", MatchDigest: `^SELECT`, Action: config.RuleActionComment, Comment: "schema=ecommerce"},
+		{ID: 3, Active: false, MatchPattern: ".*", Action: config.RuleActionBlock},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, engine.Len())
+
+	bi := engine.Evaluate(MatchContext{Query: "SELECT 1", User: "bi_reader", Schema: "ecommerce_db"})
+	assert.Equal(t, "/* source=bi */ SELECT 1", bi.Query, "apply must stop evaluation after rule 1")
+
+	app := engine.Evaluate(MatchContext{Query: "SELECT 1", User: "app", Schema: "ecommerce_db"})
+	assert.Equal(t, "/* schema=ecommerce */ SELECT 1", app.Query)
+	assert.False(t, app.Blocked, "inactive rules must be ignored")
+
+	assert.Equal(t, map[int]uint64{1: 1, 2: 1}, engine.Stats())
+}
+
+func TestNewEngineInvalidRule(t *testing.T) {
+	_, err := NewEngine([]config.QueryRule{{ID: 1, Active: true, MatchPattern: "(", Action: config.RuleActionBlock}})
+	assert.Error(t, err)
+
+	_, err = NewEngine([]config.QueryRule{{ID: 1, Active: true, Action: "explode"}})
+	assert.Error(t, err)
+
+	// Comment text closing the comment would inject SQL
+	_, err = NewEngine([]config.QueryRule{{ID: 1, Active: true, MatchPattern: ".*", Action: config.RuleActionComment,
+		Comment: "x */ DROP TABLE orders; /*"}})
+	assert.ErrorContains(t, err, "must not contain */")
+
+	for _, action := range []string{config.RuleActionRoute, config.RuleActionCache} {
+		_, err = NewEngine([]config.QueryRule{{ID: 1, Active: true, MatchPattern: ".*", Action: action}})
+		assert.ErrorContains(t, err, "not supported", action)
+	}
+}
+
+func TestEngineReplace(t *testing.T) {
+	engine, err := NewEngine([]config.QueryRule{
+		{ID: 1, Active: true, MatchDigest: `^DELETE`, Action: config.RuleActionBlock},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, engine.Replace([]config.QueryRule{
+		{ID: 2, Active: true, MatchDigest: `^SELECT`, Action: config.RuleActionComment, Comment: "reloaded"},
+	}))
+	assert.False(t, engine.Evaluate(MatchContext{Query: "DELETE FROM orders"}).Blocked)
+	assert.Equal(t, "/* reloaded */ SELECT 1", engine.Evaluate(MatchContext{Query: "SELECT 1"}).Query)
+
+	// Invalid rule sets leave the current rules active
+	assert.Error(t, engine.Replace([]config.QueryRule{{ID: 3, Active: true, MatchPattern: "(", Action: config.RuleActionBlock}}))
+	assert.Equal(t, 1, engine.Len())
+}
+
+func TestNilEngine(t *testing.T) {
+	var engine *Engine
+	result := engine.Evaluate(MatchContext{Query: "SELECT 1"})
+	assert.Equal(t, "SELECT 1", result.Query)
+	assert.Empty(t, engine.Stats())
+}
@@ -0,0 +1,249 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/backfill"
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/reconcile"
+	"gopkg.in/yaml.v3"
+)
+
+// Reconcile returns the reconcile job: it counts the rows whose shadow values
+// are missing or differ from their converted source values by more than the
+// column's precision, and fails when it finds any. Rows with a NULL source
+// are not checked. Each column's counts are saved to results, unless it is
+// nil.
+func Reconcile(db *sql.DB, cfg *config.Config, results reconcile.Results) Func {
+	return func(ctx context.Context, job config.JobConfig) (string, error) {
+		if db == nil {
+			return "", fmt.Errorf("database is not available")
+		}
+
+		tables := job.Tables
+		if len(tables) == 0 {
+			for name, tableConfig := range cfg.Tables {
+				if tableConfig.Enabled {
+					tables = append(tables, name)
+				}
+			}
+			sort.Strings(tables)
+		}
+
+		var report []string
+		var drifted int64
+		for _, table := range tables {
+			tableConfig, ok := cfg.Tables[table]
+			if !ok {
+				return "", fmt.Errorf("table %s is not configured", table)
+			}
+			columns := make([]string, 0, len(tableConfig.Columns))
+			for col := range tableConfig.Columns {
+				columns = append(columns, col)
+			}
+			sort.Strings(columns)
+
+			for _, col := range columns {
+				colConfig := tableConfig.Columns[col]
+				missing, mismatched, err := backfill.CountDrift(ctx, db, table, col, colConfig, cfg.Conversion)
+				if err != nil {
+					return strings.Join(report, "; "), fmt.Errorf("table %s column %s: %w", table, col, err)
+				}
+				report = append(report, fmt.Sprintf("%s.%s: %d missing, %d mismatched", table, col, missing, mismatched))
+				drifted += missing + mismatched
+				saveResult(ctx, results, reconcile.Result{
+					Checked: time.Now(),
+					Job:     job.Name,
+					Table:   table,
+					Column:  col,
+					Counts:  map[string]int64{reconcile.KindNotBackfilled: missing, reconcile.KindConversion: mismatched},
+				})
+			}
+		}
+
+		detail := strings.Join(report, "; ")
+		if drifted > 0 {
+			return detail, fmt.Errorf("%d rows have missing or mismatched shadow values", drifted)
+		}
+		return detail, nil
+	}
+}
+
+// WarehouseReconcile returns the warehouse_reconcile job: it compares a
+// currency column with the job's extract from a downstream system and fails
+// when they diverge, counting apart the divergences caused by conversions.
+// The counts are saved to results, unless it is nil.
+func WarehouseReconcile(db *sql.DB, cfg *config.Config, results reconcile.Results) Func {
+	return func(ctx context.Context, job config.JobConfig) (string, error) {
+		if db == nil {
+			return "", fmt.Errorf("database is not available")
+		}
+		if job.Extract == nil {
+			return "", fmt.Errorf("job has no extract")
+		}
+
+		src, err := reconcile.Open(*job.Extract)
+		if err != nil {
+			return "", err
+		}
+		defer src.Close()
+
+		report, err := reconcile.Compare(ctx, db, cfg, *job.Extract, src)
+		if err != nil {
+			if report != nil {
+				return report.String(), err
+			}
+			return "", err
+		}
+		saveResult(ctx, results, report.Result(job.Name, time.Now()))
+		if diverged := report.Diverged(); diverged > 0 {
+			return report.String(), fmt.Errorf("%d rows diverge from the extract, %d of them from conversions",
+				diverged, report.Divergences[reconcile.KindConversion])
+		}
+		return report.String(), nil
+	}
+}
+
+// saveResult keeps a column's latest result for the reconciliation report
+func saveResult(ctx context.Context, results reconcile.Results, result reconcile.Result) {
+	if results == nil {
+		return
+	}
+	if err := results.Save(ctx, result); err != nil {
+		logger.Warn("Failed to save reconcile result", "job", result.Job, "table", result.Table, "column", result.Column, "error", err)
+	}
+}
+
+// CertExpiry is the cert_expiry job: it fails when a certificate in one of the
+// job's PEM files expires within warn_before or cannot be read
+func CertExpiry(ctx context.Context, job config.JobConfig) (string, error) {
+	warnBefore := job.WarnBefore
+	if warnBefore == 0 {
+		warnBefore = config.DefaultCertWarnBefore
+	}
+
+	var report, expiring []string
+	for _, path := range job.Certificates {
+		notAfter, subject, err := earliestExpiry(path)
+		if err != nil {
+			return strings.Join(report, "; "), err
+		}
+		report = append(report, fmt.Sprintf("%s (%s) expires %s", path, subject, notAfter.UTC().Format(time.RFC3339)))
+		if time.Until(notAfter) < warnBefore {
+			expiring = append(expiring, path)
+		}
+	}
+
+	detail := strings.Join(report, "; ")
+	if len(expiring) > 0 {
+		return detail, fmt.Errorf("certificates expiring within %s: %s", warnBefore, strings.Join(expiring, ", "))
+	}
+	return detail, nil
+}
+
+// earliestExpiry returns the first expiry among the certificates in a PEM file
+func earliestExpiry(path string) (time.Time, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	var earliest *x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("failed to parse certificate in %s: %w", path, err)
+		}
+		if earliest == nil || cert.NotAfter.Before(earliest.NotAfter) {
+			earliest = cert
+		}
+	}
+	if earliest == nil {
+		return time.Time{}, "", fmt.Errorf("no certificate found in %s", path)
+	}
+	return earliest.NotAfter, earliest.Subject.CommonName, nil
+}
+
+// ConfigSource returns the running configuration; *config.RedisStore
+// implements it
+type ConfigSource interface {
+	ExportConfig(ctx context.Context) (*config.Config, error)
+}
+
+// backupPrefix starts the file names of config backups
+const backupPrefix = "transisidb-config-"
+
+// ConfigBackup returns the config_backup job: it writes the running
+// configuration as a YAML bundle, which POST /api/v1/config/import restores,
+// to the job's directory and removes all but the newest keep backups. Backups
+// contain credentials and are only readable by the owner.
+func ConfigBackup(source ConfigSource) Func {
+	return func(ctx context.Context, job config.JobConfig) (string, error) {
+		if source == nil {
+			return "", fmt.Errorf("config store is not available")
+		}
+		cfg, err := source.ExportConfig(ctx)
+		if err != nil {
+			return "", err
+		}
+		data, err := yaml.Marshal(config.NewBundle(cfg, false))
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal config: %w", err)
+		}
+
+		if err := os.MkdirAll(job.Path, 0o700); err != nil {
+			return "", fmt.Errorf("failed to create backup directory: %w", err)
+		}
+		name := filepath.Join(job.Path, backupPrefix+time.Now().UTC().Format("20060102T150405Z")+".yaml")
+		if err := os.WriteFile(name, data, 0o600); err != nil {
+			return "", fmt.Errorf("failed to write backup: %w", err)
+		}
+
+		keep := job.Keep
+		if keep == 0 {
+			keep = config.DefaultBackupKeep
+		}
+		removed, err := pruneBackups(job.Path, keep)
+		if err != nil {
+			return name, err
+		}
+		return fmt.Sprintf("wrote %s, removed %d old backups", name, removed), nil
+	}
+}
+
+// pruneBackups removes all but the newest keep backups in dir; the timestamps
+// in their names sort chronologically
+func pruneBackups(dir string, keep int) (int, error) {
+	backups, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*.yaml"))
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(backups)
+
+	removed := 0
+	for len(backups)-removed > keep {
+		if err := os.Remove(backups[removed]); err != nil {
+			return removed, fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix is the prefix of the scheduler's Redis keys
+const KeyPrefix = "transisidb:jobs"
+
+// claimScript advances a job's claimed due time only forwards, so exactly
+// one scheduler runs each due time
+var claimScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+return 1
+`)
+
+// RedisStore keeps jobs and runs in Redis, shared by all API servers
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string // KeyPrefix, hash-tagged on a cluster
+}
+
+// NewRedisStore returns a store using client
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client, prefix: config.RedisKeyPrefix(client, KeyPrefix)}
+}
+
+func (r *RedisStore) definitionsKey() string {
+	return r.prefix + ":definitions"
+}
+
+func (r *RedisStore) claimKey(name string) string {
+	return fmt.Sprintf("%s:%s:claimed", r.prefix, name)
+}
+
+func (r *RedisStore) runsKey(name string) string {
+	return fmt.Sprintf("%s:%s:runs", r.prefix, name)
+}
+
+func (r *RedisStore) LoadJobs(ctx context.Context) ([]config.JobConfig, error) {
+	values, err := r.client.HGetAll(ctx, r.definitionsKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+
+	jobs := make([]config.JobConfig, 0, len(values))
+	for name, value := range values {
+		var job config.JobConfig
+		if err := json.Unmarshal([]byte(value), &job); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job %s: %w", name, err)
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs, nil
+}
+
+func (r *RedisStore) SaveJob(ctx context.Context, job config.JobConfig) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+	return r.client.HSet(ctx, r.definitionsKey(), job.Name, data).Err()
+}
+
+func (r *RedisStore) DeleteJob(ctx context.Context, name string) (bool, error) {
+	var deleted *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.HDel(ctx, r.definitionsKey(), name)
+		pipe.Del(ctx, r.claimKey(name), r.runsKey(name))
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete job: %w", err)
+	}
+	return deleted.Val() > 0, nil
+}
+
+func (r *RedisStore) Claim(ctx context.Context, name string, due time.Time) (bool, error) {
+	claimed, err := claimScript.Run(ctx, r.client, []string{r.claimKey(name)}, due.Unix()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job run: %w", err)
+	}
+	return claimed == 1, nil
+}
+
+func (r *RedisStore) AppendRun(ctx context.Context, name string, run Run, keep int) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job run: %w", err)
+	}
+
+	key := r.runsKey(name)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, data)
+		pipe.LTrim(ctx, key, 0, int64(keep)-1)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStore) LoadRuns(ctx context.Context, name string, limit int) ([]Run, error) {
+	values, err := r.client.LRange(ctx, r.runsKey(name), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job runs: %w", err)
+	}
+
+	runs := make([]Run, 0, len(values))
+	for _, value := range values {
+		var run Run
+		if err := json.Unmarshal([]byte(value), &run); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule computes when a job runs next
+type Schedule interface {
+	// Next returns the first run time after t
+	Next(t time.Time) time.Time
+}
+
+// every runs a job at a fixed interval aligned to the clock, so every
+// scheduler computes the same run times
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Duration(e)).Add(time.Duration(e))
+}
+
+// daily runs a job once a day at a UTC time of day
+type daily time.Duration
+
+func (d daily) Next(t time.Time) time.Time {
+	t = t.UTC()
+	next := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Add(time.Duration(d))
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// minInterval keeps @every jobs from running more than once a minute
+const minInterval = time.Minute
+
+// Parse parses "@every <duration>", "@hourly", "@daily" and "@daily HH:MM"
+func Parse(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty schedule")
+	}
+	switch {
+	case fields[0] == "@every" && len(fields) == 2:
+		interval, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		if interval < minInterval {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be at least %s", spec, minInterval)
+		}
+		return every(interval), nil
+	case fields[0] == "@hourly" && len(fields) == 1:
+		return every(time.Hour), nil
+	case fields[0] == "@daily" && len(fields) == 1:
+		return daily(0), nil
+	case fields[0] == "@daily" && len(fields) == 2:
+		at, err := time.Parse("15:04", fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: time of day must be HH:MM", spec)
+		}
+		return daily(time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute), nil
+	}
+	return nil, fmt.Errorf("invalid schedule %q: want @every <duration>, @hourly, @daily or @daily HH:MM", spec)
+}
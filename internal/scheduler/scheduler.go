@@ -0,0 +1,337 @@
+// Package scheduler runs recurring maintenance jobs. Every API server runs a
+// scheduler; runs are claimed in the shared store, so each due run happens on
+// exactly one of them.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/errs"
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+	"github.com/kafitramarna/TransisiDB/internal/metrics"
+)
+
+// Run outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailed  = "failed"
+)
+
+// Job sources
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+)
+
+// reloadInterval is how often jobs defined through other API servers are picked up
+const reloadInterval = 30 * time.Second
+
+var (
+	// ErrJobNotFound is returned for jobs that are not defined
+	ErrJobNotFound = errs.New(errs.ConfigNotFound, "job not found")
+	// ErrConfigJob is returned when changing a job defined in the config file
+	ErrConfigJob = errs.New(errs.ConfigConflict, "job is defined in the config file")
+)
+
+// Func runs a job and returns a short description of what it did
+type Func func(ctx context.Context, job config.JobConfig) (string, error)
+
+// JobStatus describes a job for the API
+type JobStatus struct {
+	config.JobConfig
+	Source      string     `json:"source"`
+	NextRun     time.Time  `json:"next_run"`
+	Running     bool       `json:"running"`
+	LastRun     *Run       `json:"last_run,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	History     []Run      `json:"history"`
+}
+
+// entry is a loaded job
+type entry struct {
+	job      config.JobConfig
+	source   string
+	schedule Schedule
+	next     time.Time
+	running  bool
+}
+
+// Scheduler runs jobs defined in the config file and through the API
+type Scheduler struct {
+	store      Store
+	configJobs []config.JobConfig
+	history    int
+	instance   string
+	funcs      map[string]Func
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	wg      sync.WaitGroup
+}
+
+// New returns a scheduler for the jobs in cfg; job types are added with
+// Register before Load
+func New(store Store, cfg config.SchedulerConfig) *Scheduler {
+	history := cfg.History
+	if history == 0 {
+		history = config.DefaultJobHistory
+	}
+	host, _ := os.Hostname()
+	return &Scheduler{
+		store:      store,
+		configJobs: cfg.Jobs,
+		history:    history,
+		instance:   fmt.Sprintf("%s-%d", host, os.Getpid()),
+		funcs:      make(map[string]Func),
+		now:        time.Now,
+		entries:    make(map[string]*entry),
+	}
+}
+
+// Register sets the function running jobs of a type
+func (s *Scheduler) Register(jobType string, fn Func) {
+	s.funcs[jobType] = fn
+}
+
+// Validate checks a job definition, its schedule and that its type is registered
+func (s *Scheduler) Validate(job config.JobConfig) error {
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	if _, err := Parse(job.Schedule); err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	if s.funcs[job.Type] == nil {
+		return fmt.Errorf("job %s: type %s is not available", job.Name, job.Type)
+	}
+	return nil
+}
+
+// Load (re)loads the job definitions. Invalid config jobs are an error;
+// invalid jobs from the store are logged and skipped.
+func (s *Scheduler) Load(ctx context.Context) error {
+	defined := make(map[string]*entry)
+	for _, job := range s.configJobs {
+		if err := s.Validate(job); err != nil {
+			return err
+		}
+		defined[job.Name] = &entry{job: job, source: SourceConfig}
+	}
+
+	stored, err := s.store.LoadJobs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range stored {
+		if _, ok := defined[job.Name]; ok {
+			logger.Warn("Ignoring stored job shadowed by the config file", "job", job.Name)
+			continue
+		}
+		if err := s.Validate(job); err != nil {
+			logger.Warn("Ignoring invalid stored job", "job", job.Name, "error", err)
+			continue
+		}
+		defined[job.Name] = &entry{job: job, source: SourceAPI}
+	}
+
+	now := s.now()
+	for name, e := range defined {
+		e.schedule, _ = Parse(e.job.Schedule)
+
+		s.mu.Lock()
+		current, ok := s.entries[name]
+		s.mu.Unlock()
+		if ok && current.job.Schedule == e.job.Schedule {
+			continue
+		}
+
+		// A run missed while no scheduler was up happens once, right away
+		e.next = e.schedule.Next(now)
+		runs, err := s.store.LoadRuns(ctx, name, 1)
+		if err != nil {
+			return err
+		}
+		if len(runs) > 0 {
+			if missed := e.schedule.Next(runs[0].Due); missed.Before(e.next) {
+				e.next = missed
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, e := range defined {
+		// Unchanged schedules keep their next run
+		if current, ok := s.entries[name]; ok && current.job.Schedule == e.job.Schedule {
+			current.job, current.source = e.job, e.source
+			continue
+		}
+		s.entries[name] = e
+	}
+	for name := range s.entries {
+		if _, ok := defined[name]; !ok {
+			delete(s.entries, name)
+		}
+	}
+	return nil
+}
+
+// Run starts due jobs until ctx is cancelled, then waits for running jobs
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	lastReload := s.now()
+	for {
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+			if s.now().Sub(lastReload) >= reloadInterval {
+				if err := s.Load(ctx); err != nil {
+					logger.Warn("Failed to reload scheduled jobs", "error", err)
+				}
+				lastReload = s.now()
+			}
+			s.runDue(ctx)
+		}
+	}
+}
+
+// runDue starts the jobs whose next run has come
+func (s *Scheduler) runDue(ctx context.Context) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.running || e.next.After(now) {
+			continue
+		}
+		e.running = true
+		s.wg.Add(1)
+		go s.execute(ctx, e, e.job, e.next)
+	}
+}
+
+// execute runs a job for its due time unless another scheduler claimed it
+func (s *Scheduler) execute(ctx context.Context, e *entry, job config.JobConfig, due time.Time) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		e.running = false
+		// Skip the runs missed while this one ran
+		now := s.now()
+		for !e.next.After(now) {
+			e.next = e.schedule.Next(e.next)
+		}
+	}()
+
+	claimed, err := s.store.Claim(ctx, job.Name, due)
+	if err != nil {
+		logger.Warn("Failed to claim scheduled job", "job", job.Name, "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	run := Run{Due: due, Started: s.now(), Instance: s.instance}
+	logger.Info("Running scheduled job", "job", job.Name, "type", job.Type)
+	detail, err := s.funcs[job.Type](ctx, job)
+	run.Finished = s.now()
+	run.Detail = detail
+	run.Outcome = OutcomeSuccess
+	if err != nil {
+		run.Outcome = OutcomeFailed
+		run.Error = err.Error()
+		logger.Error("Scheduled job failed", "job", job.Name, "error", err)
+	} else {
+		logger.Info("Scheduled job finished", "job", job.Name, "detail", detail)
+	}
+	metrics.RecordJobRun(job.Name, run.Outcome, run.Finished)
+
+	// Record the run even when shutdown cancelled it
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := s.store.AppendRun(recordCtx, job.Name, run, s.history); err != nil {
+		logger.Warn("Failed to record scheduled job run", "job", job.Name, "error", err)
+	}
+}
+
+// SaveJob adds or replaces a job defined through the API
+func (s *Scheduler) SaveJob(ctx context.Context, job config.JobConfig) error {
+	if s.isConfigJob(job.Name) {
+		return ErrConfigJob
+	}
+	if err := s.Validate(job); err != nil {
+		return err
+	}
+	if err := s.store.SaveJob(ctx, job); err != nil {
+		return err
+	}
+	return s.Load(ctx)
+}
+
+// DeleteJob removes a job defined through the API
+func (s *Scheduler) DeleteJob(ctx context.Context, name string) error {
+	if s.isConfigJob(name) {
+		return ErrConfigJob
+	}
+	deleted, err := s.store.DeleteJob(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrJobNotFound
+	}
+	return s.Load(ctx)
+}
+
+func (s *Scheduler) isConfigJob(name string) bool {
+	for _, job := range s.configJobs {
+		if job.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Jobs returns the status and run history of every job, sorted by name
+func (s *Scheduler) Jobs(ctx context.Context) ([]JobStatus, error) {
+	s.mu.Lock()
+	jobs := make([]JobStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		jobs = append(jobs, JobStatus{JobConfig: e.job, Source: e.source, NextRun: e.next, Running: e.running})
+	}
+	s.mu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+
+	for i := range jobs {
+		runs, err := s.store.LoadRuns(ctx, jobs[i].Name, s.history)
+		if err != nil {
+			return nil, err
+		}
+		jobs[i].History = runs
+		if len(runs) > 0 {
+			jobs[i].LastRun = &runs[0]
+		}
+		for _, run := range runs {
+			if run.Outcome == OutcomeFailed {
+				jobs[i].LastError = run.Error
+				finished := run.Finished
+				jobs[i].LastErrorAt = &finished
+				break
+			}
+		}
+	}
+	return jobs, nil
+}
@@ -0,0 +1,235 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	at := time.Date(2026, 3, 10, 14, 20, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"@every 15m", time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"@daily 02:00", time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC)},
+		{"@daily 18:45", time.Date(2026, 3, 10, 18, 45, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.next, schedule.Next(at), tt.spec)
+	}
+
+	for _, spec := range []string{"", "@weekly", "@every 10s", "@every soon", "@daily 2am", "0 2 * * *"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// clock is a settable time source shared by the schedulers of a test
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+func newTestScheduler(store Store, c *clock, cfg config.SchedulerConfig, fn Func) *Scheduler {
+	s := New(store, cfg)
+	s.now = c.Now
+	s.Register(config.JobTypeReconcile, fn)
+	return s
+}
+
+func TestScheduler_RunsEachDueRunOnce(t *testing.T) {
+	store := NewMemoryStore()
+	c := &clock{now: time.Date(2026, 3, 10, 10, 30, 0, 0, time.UTC)}
+	cfg := config.SchedulerConfig{Jobs: []config.JobConfig{{Name: "sync", Type: config.JobTypeReconcile, Schedule: "@hourly"}}}
+	var runs atomic.Int32
+	fn := func(ctx context.Context, job config.JobConfig) (string, error) {
+		runs.Add(1)
+		return "ok", nil
+	}
+
+	// Two API servers share the store
+	first := newTestScheduler(store, c, cfg, fn)
+	second := newTestScheduler(store, c, cfg, fn)
+	require.NoError(t, first.Load(context.Background()))
+	require.NoError(t, second.Load(context.Background()))
+
+	first.runDue(context.Background())
+	first.wg.Wait()
+	assert.Equal(t, int32(0), runs.Load(), "nothing is due yet")
+
+	c.Set(time.Date(2026, 3, 10, 11, 0, 1, 0, time.UTC))
+	first.runDue(context.Background())
+	second.runDue(context.Background())
+	first.wg.Wait()
+	second.wg.Wait()
+	assert.Equal(t, int32(1), runs.Load())
+
+	jobs, err := second.Jobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, SourceConfig, jobs[0].Source)
+	assert.Equal(t, time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC), jobs[0].NextRun)
+	require.NotNil(t, jobs[0].LastRun)
+	assert.Equal(t, time.Date(2026, 3, 10, 11, 0, 0, 0, time.UTC), jobs[0].LastRun.Due)
+	assert.Equal(t, OutcomeSuccess, jobs[0].LastRun.Outcome)
+	assert.Empty(t, jobs[0].LastError)
+}
+
+func TestScheduler_CatchesUpMissedRunAndRecordsFailures(t *testing.T) {
+	store := NewMemoryStore()
+	require.NoError(t, store.AppendRun(context.Background(), "nightly", Run{
+		Due: time.Date(2026, 3, 7, 2, 0, 0, 0, time.UTC), Outcome: OutcomeSuccess,
+	}, 10))
+
+	c := &clock{now: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)}
+	cfg := config.SchedulerConfig{Jobs: []config.JobConfig{{Name: "nightly", Type: config.JobTypeReconcile, Schedule: "@daily 02:00"}}}
+	s := newTestScheduler(store, c, cfg, func(ctx context.Context, job config.JobConfig) (string, error) {
+		return "3 rows", errors.New("rows out of sync")
+	})
+	require.NoError(t, s.Load(context.Background()))
+
+	// The runs missed while no scheduler was up are made up for once
+	s.runDue(context.Background())
+	s.wg.Wait()
+	s.runDue(context.Background())
+	s.wg.Wait()
+
+	jobs, err := s.Jobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Len(t, jobs[0].History, 2)
+	assert.Equal(t, time.Date(2026, 3, 8, 2, 0, 0, 0, time.UTC), jobs[0].LastRun.Due)
+	assert.Equal(t, OutcomeFailed, jobs[0].LastRun.Outcome)
+	assert.Equal(t, "3 rows", jobs[0].LastRun.Detail)
+	assert.Equal(t, "rows out of sync", jobs[0].LastError)
+	assert.Equal(t, time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC), jobs[0].NextRun)
+}
+
+func TestScheduler_SaveAndDeleteJobs(t *testing.T) {
+	c := &clock{now: time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC)}
+	cfg := config.SchedulerConfig{Jobs: []config.JobConfig{{Name: "nightly", Type: config.JobTypeReconcile, Schedule: "@daily"}}}
+	noop := func(ctx context.Context, job config.JobConfig) (string, error) { return "", nil }
+	s := newTestScheduler(NewMemoryStore(), c, cfg, noop)
+	require.NoError(t, s.Load(context.Background()))
+	ctx := context.Background()
+
+	assert.ErrorIs(t, s.SaveJob(ctx, config.JobConfig{Name: "nightly", Type: config.JobTypeReconcile, Schedule: "@hourly"}), ErrConfigJob)
+	assert.Error(t, s.SaveJob(ctx, config.JobConfig{Name: "check", Type: config.JobTypeReconcile, Schedule: "@every 5s"}))
+	assert.Error(t, s.SaveJob(ctx, config.JobConfig{Name: "check", Type: config.JobTypeConfigBackup, Schedule: "@hourly", Path: "/tmp"}),
+		"unregistered job types are rejected")
+	require.NoError(t, s.SaveJob(ctx, config.JobConfig{Name: "check", Type: config.JobTypeReconcile, Schedule: "@every 30m"}))
+
+	jobs, err := s.Jobs(ctx)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "check", jobs[0].Name)
+	assert.Equal(t, SourceAPI, jobs[0].Source)
+	assert.Equal(t, time.Date(2026, 3, 10, 10, 30, 0, 0, time.UTC), jobs[0].NextRun)
+
+	assert.ErrorIs(t, s.DeleteJob(ctx, "nightly"), ErrConfigJob)
+	assert.ErrorIs(t, s.DeleteJob(ctx, "missing"), ErrJobNotFound)
+	require.NoError(t, s.DeleteJob(ctx, "check"))
+	jobs, err = s.Jobs(ctx)
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+}
+
+// writeCertificate writes a self-signed certificate valid for validFor
+func writeCertificate(t *testing.T, path string, validFor time.Duration) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "proxy.internal"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+}
+
+func TestCertExpiry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.pem")
+	writeCertificate(t, path, 72*time.Hour)
+
+	job := config.JobConfig{Name: "certs", Type: config.JobTypeCertExpiry, Certificates: []string{path}}
+	detail, err := CertExpiry(context.Background(), job)
+	assert.ErrorContains(t, err, "expiring within 336h0m0s")
+	assert.Contains(t, detail, "proxy.internal")
+
+	job.WarnBefore = 24 * time.Hour
+	_, err = CertExpiry(context.Background(), job)
+	assert.NoError(t, err)
+
+	job.Certificates = append(job.Certificates, filepath.Join(t.TempDir(), "missing.pem"))
+	_, err = CertExpiry(context.Background(), job)
+	assert.ErrorContains(t, err, "failed to read certificate")
+}
+
+// staticConfig is a ConfigSource returning a fixed config
+type staticConfig struct {
+	cfg *config.Config
+}
+
+func (s staticConfig) ExportConfig(ctx context.Context) (*config.Config, error) {
+	return s.cfg, nil
+}
+
+func TestConfigBackup_KeepsNewestBackups(t *testing.T) {
+	dir := t.TempDir()
+	for _, stamp := range []string{"20260301T020000Z", "20260302T020000Z", "20260303T020000Z"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, backupPrefix+stamp+".yaml"), []byte("old"), 0o600))
+	}
+
+	cfg := &config.Config{Tables: config.TablesConfig{"orders": {Enabled: true}}}
+	backup := ConfigBackup(staticConfig{cfg: cfg})
+	detail, err := backup(context.Background(), config.JobConfig{Name: "backup", Type: config.JobTypeConfigBackup, Path: dir, Keep: 2})
+	require.NoError(t, err)
+	assert.Contains(t, detail, "removed 2 old backups")
+
+	backups, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*.yaml"))
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	assert.Equal(t, filepath.Join(dir, backupPrefix+"20260303T020000Z.yaml"), backups[0])
+
+	data, err := os.ReadFile(backups[1])
+	require.NoError(t, err)
+	restored, err := config.ParseBundle(data)
+	require.NoError(t, err)
+	assert.True(t, restored.Config.Tables["orders"].Enabled)
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+// Run is one execution of a job
+type Run struct {
+	Due      time.Time `json:"due"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance"`
+}
+
+// Store persists job definitions made through the API and the run history
+// shared by all schedulers
+type Store interface {
+	// LoadJobs returns the jobs defined through the API
+	LoadJobs(ctx context.Context) ([]config.JobConfig, error)
+	// SaveJob adds or replaces a job defined through the API
+	SaveJob(ctx context.Context, job config.JobConfig) error
+	// DeleteJob removes a job defined through the API and its history; it
+	// reports false when there was no such job
+	DeleteJob(ctx context.Context, name string) (bool, error)
+	// Claim reserves the run of a job due at due. It fails when the run, or a
+	// later one, was already claimed by any scheduler.
+	Claim(ctx context.Context, name string, due time.Time) (bool, error)
+	// AppendRun records a run, keeping the newest keep runs
+	AppendRun(ctx context.Context, name string, run Run, keep int) error
+	// LoadRuns returns up to limit runs, newest first
+	LoadRuns(ctx context.Context, name string, limit int) ([]Run, error)
+}
+
+// MemoryStore keeps jobs and runs in memory, for a single scheduler and tests
+type MemoryStore struct {
+	mu      sync.Mutex
+	jobs    map[string]config.JobConfig
+	claimed map[string]time.Time
+	runs    map[string][]Run
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		jobs:    make(map[string]config.JobConfig),
+		claimed: make(map[string]time.Time),
+		runs:    make(map[string][]Run),
+	}
+}
+
+func (m *MemoryStore) LoadJobs(ctx context.Context) ([]config.JobConfig, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]config.JobConfig, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs, nil
+}
+
+func (m *MemoryStore) SaveJob(ctx context.Context, job config.JobConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.Name] = job
+	return nil
+}
+
+func (m *MemoryStore) DeleteJob(ctx context.Context, name string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.jobs[name]
+	delete(m.jobs, name)
+	delete(m.claimed, name)
+	delete(m.runs, name)
+	return ok, nil
+}
+
+func (m *MemoryStore) Claim(ctx context.Context, name string, due time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !due.After(m.claimed[name]) {
+		return false, nil
+	}
+	m.claimed[name] = due
+	return true, nil
+}
+
+func (m *MemoryStore) AppendRun(ctx context.Context, name string, run Run, keep int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := append([]Run{run}, m.runs[name]...)
+	if len(runs) > keep {
+		runs = runs[:keep]
+	}
+	m.runs[name] = runs
+	return nil
+}
+
+func (m *MemoryStore) LoadRuns(ctx context.Context, name string, limit int) ([]Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := m.runs[name]
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return append([]Run(nil), runs...), nil
+}
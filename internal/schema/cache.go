@@ -0,0 +1,226 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kafitramarna/TransisiDB/internal/logger"
+)
+
+// DefaultRefreshInterval is used when schema.refresh_interval is not set
+const DefaultRefreshInterval = 5 * time.Minute
+
+// Column is a column's metadata as reported by the server
+type Column struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`      // full column type, e.g. decimal(19,4) unsigned
+	DataType   string `json:"data_type"` // base type, e.g. decimal
+	Nullable   bool   `json:"nullable"`
+	PrimaryKey bool   `json:"primary_key"`
+	// Precision and Scale are set for numeric types (-1 otherwise)
+	Precision int `json:"precision"`
+	Scale     int `json:"scale"`
+}
+
+// Table is a table's metadata; columns are in ordinal order
+type Table struct {
+	Database   string   `json:"database"`
+	Name       string   `json:"name"`
+	Columns    []Column `json:"columns"`
+	PrimaryKey []string `json:"primary_key"`
+}
+
+// Column returns a column by name. Column names are case-insensitive in MySQL.
+func (t *Table) Column(name string) (Column, bool) {
+	for _, col := range t.Columns {
+		if strings.EqualFold(col.Name, name) {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+// Loader reads table metadata for a database. When tables are given only
+// those tables are loaded.
+type Loader interface {
+	LoadTables(ctx context.Context, database string, tables ...string) (map[string]*Table, error)
+}
+
+// databaseEntry is the cached metadata of one database
+type databaseEntry struct {
+	tables   map[string]*Table
+	stale    map[string]bool // tables to reload on next access
+	loadedAt time.Time
+}
+
+// Cache holds table metadata per database. Databases are loaded on first use,
+// refreshed by Run and individual tables are reloaded after Invalidate.
+type Cache struct {
+	loader   Loader
+	interval time.Duration
+	foldCase bool
+
+	mu        sync.RWMutex
+	databases map[string]*databaseEntry
+}
+
+// NewCache creates a cache backed by loader. Table names are compared
+// case-insensitively when foldCase is set (lower_case_table_names 1 or 2).
+func NewCache(loader Loader, refreshInterval time.Duration, foldCase bool) *Cache {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+	return &Cache{
+		loader:    loader,
+		interval:  refreshInterval,
+		foldCase:  foldCase,
+		databases: make(map[string]*databaseEntry),
+	}
+}
+
+// Table returns the metadata of a table, loading its database on first use.
+// ok is false when the table does not exist.
+func (c *Cache) Table(ctx context.Context, database, table string) (*Table, bool, error) {
+	c.mu.RLock()
+	entry, loaded := c.databases[database]
+	stale := loaded && entry.stale[c.key(table)]
+	c.mu.RUnlock()
+
+	switch {
+	case !loaded:
+		if err := c.Refresh(ctx, database); err != nil {
+			return nil, false, err
+		}
+	case stale:
+		if err := c.reloadTable(ctx, database, table); err != nil {
+			return nil, false, err
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	t, ok := c.databases[database].tables[c.key(table)]
+	return t, ok, nil
+}
+
+// Refresh reloads every table of a database
+func (c *Cache) Refresh(ctx context.Context, database string) error {
+	tables, err := c.loader.LoadTables(ctx, database)
+	if err != nil {
+		return fmt.Errorf("failed to load schema of %s: %w", database, err)
+	}
+
+	entry := &databaseEntry{
+		tables:   make(map[string]*Table, len(tables)),
+		stale:    make(map[string]bool),
+		loadedAt: time.Now(),
+	}
+	for name, table := range tables {
+		entry.tables[c.key(name)] = table
+	}
+
+	c.mu.Lock()
+	c.databases[database] = entry
+	c.mu.Unlock()
+	return nil
+}
+
+// reloadTable reloads one table of an already loaded database
+func (c *Cache) reloadTable(ctx context.Context, database, table string) error {
+	tables, err := c.loader.LoadTables(ctx, database, table)
+	if err != nil {
+		return fmt.Errorf("failed to load schema of %s.%s: %w", database, table, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, loaded := c.databases[database]
+	if !loaded {
+		return nil
+	}
+	key := c.key(table)
+	delete(entry.tables, key)
+	delete(entry.stale, key)
+	for name, t := range tables {
+		if c.key(name) == key {
+			entry.tables[key] = t
+		}
+	}
+	return nil
+}
+
+// Invalidate marks a table for reload on its next access, e.g. after DDL
+func (c *Cache) Invalidate(database, table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, loaded := c.databases[database]; loaded {
+		entry.stale[c.key(table)] = true
+	}
+}
+
+// Databases returns the loaded databases in name order
+func (c *Cache) Databases() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.databases))
+	for name := range c.databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CacheStats is a snapshot of what the cache holds
+type CacheStats struct {
+	Databases   int `json:"databases"`
+	Tables      int `json:"tables"`
+	StaleTables int `json:"stale_tables"`
+}
+
+// Stats returns the number of cached databases and tables, and of tables
+// waiting to be reloaded
+func (c *Cache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := CacheStats{Databases: len(c.databases)}
+	for _, entry := range c.databases {
+		stats.Tables += len(entry.tables)
+		stats.StaleTables += len(entry.stale)
+	}
+	return stats
+}
+
+// Run refreshes every loaded database each refresh interval until ctx is
+// cancelled. A failed refresh keeps the previous metadata.
+func (c *Cache) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, database := range c.Databases() {
+				if err := c.Refresh(ctx, database); err != nil {
+					logger.Warn("Schema refresh failed, keeping cached metadata", "database", database, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// key is the map key of a table name
+func (c *Cache) key(table string) string {
+	if c.foldCase {
+		return strings.ToLower(table)
+	}
+	return table
+}
@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLoader serves tables from memory and counts loads
+type fakeLoader struct {
+	tables map[string]map[string]*Table
+	loads  []string
+	err    error
+}
+
+func (f *fakeLoader) LoadTables(ctx context.Context, database string, tables ...string) (map[string]*Table, error) {
+	f.loads = append(f.loads, database+":"+strings.Join(tables, ","))
+	if f.err != nil {
+		return nil, f.err
+	}
+	result := make(map[string]*Table)
+	for name, table := range f.tables[database] {
+		if len(tables) == 0 || slices.Contains(tables, name) {
+			result[name] = table
+		}
+	}
+	return result, nil
+}
+
+func ordersTable(targetType string, scale int) *Table {
+	return &Table{
+		Database: "shop",
+		Name:     "orders",
+		Columns: []Column{
+			{Name: "id", Type: "bigint", DataType: "bigint", PrimaryKey: true, Precision: 19, Scale: 0},
+			{Name: "total_amount_idn", Type: targetType, DataType: "decimal", Nullable: true, Precision: 19, Scale: scale},
+		},
+		PrimaryKey: []string{"id"},
+	}
+}
+
+func TestCache_LoadsOnFirstUseAndReloadsInvalidatedTables(t *testing.T) {
+	loader := &fakeLoader{tables: map[string]map[string]*Table{
+		"shop": {"orders": ordersTable("decimal(19,4)", 4)},
+	}}
+	cache := NewCache(loader, 0, false)
+	ctx := context.Background()
+
+	table, ok, err := cache.Table(ctx, "shop", "orders")
+	require.NoError(t, err)
+	require.True(t, ok)
+	col, ok := table.Column("TOTAL_AMOUNT_IDN")
+	require.True(t, ok)
+	assert.Equal(t, 4, col.Scale)
+	assert.Equal(t, []string{"id"}, table.PrimaryKey)
+
+	_, ok, err = cache.Table(ctx, "shop", "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []string{"shop:"}, loader.loads, "the database is loaded once")
+
+	// DDL changed the table: only that table is reloaded
+	loader.tables["shop"]["orders"] = ordersTable("decimal(19,2)", 2)
+	cache.Invalidate("shop", "orders")
+	assert.Equal(t, CacheStats{Databases: 1, Tables: 1, StaleTables: 1}, cache.Stats())
+	table, _, err = cache.Table(ctx, "shop", "orders")
+	require.NoError(t, err)
+	col, _ = table.Column("total_amount_idn")
+	assert.Equal(t, 2, col.Scale)
+	assert.Equal(t, []string{"shop:", "shop:orders"}, loader.loads)
+
+	// A dropped table disappears after invalidation
+	delete(loader.tables["shop"], "orders")
+	cache.Invalidate("shop", "orders")
+	_, ok, err = cache.Table(ctx, "shop", "orders")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestCache_FoldCaseAndErrors(t *testing.T) {
+	loader := &fakeLoader{tables: map[string]map[string]*Table{
+		"shop": {"Orders": ordersTable("decimal(19,4)", 4)},
+	}}
+	cache := NewCache(loader, 0, true)
+
+	_, ok, err := cache.Table(context.Background(), "shop", "orders")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"shop"}, cache.Databases())
+
+	// A failed refresh keeps the cached metadata
+	loader.err = errors.New("connection refused")
+	assert.Error(t, cache.Refresh(context.Background(), "shop"))
+	_, ok, err = cache.Table(context.Background(), "shop", "ORDERS")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, _, err = cache.Table(context.Background(), "other", "orders")
+	assert.ErrorContains(t, err, "failed to load schema of other")
+}
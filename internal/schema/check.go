@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+)
+
+// CheckTargetColumns compares the shadow columns of enabled table configs with
+// the live schema. It reports tables and shadow columns that do not exist and
+// shadow columns with an integer type, which would truncate converted
+// decimals. Unqualified table keys belong to database.
+func CheckTargetColumns(ctx context.Context, cache *Cache, database string, tables config.TablesConfig) []error {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []error
+	for _, name := range names {
+		tableConfig := tables[name]
+		if !tableConfig.Enabled {
+			continue
+		}
+
+		db, table := database, name
+		if dot := strings.IndexByte(name, '.'); dot >= 0 {
+			db, table = name[:dot], name[dot+1:]
+		}
+		meta, ok, err := cache.Table(ctx, db, table)
+		if err != nil {
+			return append(problems, err)
+		}
+		if !ok {
+			problems = append(problems, fmt.Errorf("table %s: not found in database %s", name, db))
+			continue
+		}
+
+		columns := make([]string, 0, len(tableConfig.Columns))
+		for colName := range tableConfig.Columns {
+			columns = append(columns, colName)
+		}
+		sort.Strings(columns)
+
+		for _, colName := range columns {
+			target := tableConfig.Columns[colName].TargetColumn
+			col, ok := meta.Column(target)
+			switch {
+			case !ok:
+				problems = append(problems, fmt.Errorf("table %s column %s: target column %s does not exist", name, colName, target))
+			case config.IsIntegerType(col.Type):
+				problems = append(problems, fmt.Errorf("table %s column %s: target column %s is %s and would truncate converted decimals",
+					name, colName, target, col.Type))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTargetColumns(t *testing.T) {
+	loader := &fakeLoader{tables: map[string]map[string]*Table{
+		"shop": {"orders": ordersTable("int(11)", 0)},
+	}}
+	cache := NewCache(loader, 0, false)
+
+	tables := config.TablesConfig{
+		"orders": {Enabled: true, Columns: map[string]config.ColumnConfig{
+			"total_amount":    {SourceColumn: "total_amount", TargetColumn: "total_amount_idn"},
+			"shipping_amount": {SourceColumn: "shipping_amount", TargetColumn: "shipping_amount_idn"},
+		}},
+		"invoices": {Enabled: true},
+		"disabled": {Enabled: false},
+	}
+
+	problems := CheckTargetColumns(context.Background(), cache, "shop", tables)
+	require.Len(t, problems, 3)
+	assert.Contains(t, problems[0].Error(), "table invoices: not found")
+	assert.Contains(t, problems[1].Error(), "shipping_amount_idn does not exist")
+	assert.Contains(t, problems[2].Error(), "total_amount_idn is int(11)")
+
+	loader.tables["shop"]["orders"] = ordersTable("decimal(19,4)", 4)
+	require.NoError(t, cache.Refresh(context.Background(), "shop"))
+	delete(tables, "invoices")
+	delete(tables["orders"].Columns, "shipping_amount")
+	assert.Empty(t, CheckTargetColumns(context.Background(), cache, "shop", tables))
+}
@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// columnsQuery reads column metadata from information_schema
+const columnsQuery = `SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, DATA_TYPE, IS_NULLABLE, COLUMN_KEY,
+	NUMERIC_PRECISION, NUMERIC_SCALE
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = ?`
+
+// SQLLoader loads metadata from information_schema over a database/sql connection
+type SQLLoader struct {
+	db *sql.DB
+}
+
+// NewSQLLoader creates a loader using db
+func NewSQLLoader(db *sql.DB) *SQLLoader {
+	return &SQLLoader{db: db}
+}
+
+// LoadTables reads the columns of a database, or of the given tables only
+func (l *SQLLoader) LoadTables(ctx context.Context, database string, tables ...string) (map[string]*Table, error) {
+	query := columnsQuery
+	args := []interface{}{database}
+	if len(tables) > 0 {
+		query += " AND TABLE_NAME IN (?" + strings.Repeat(", ?", len(tables)-1) + ")"
+		for _, table := range tables {
+			args = append(args, table)
+		}
+	}
+	query += " ORDER BY TABLE_NAME, ORDINAL_POSITION"
+
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query information_schema: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]*Table)
+	for rows.Next() {
+		var (
+			tableName, columnKey, nullable string
+			precision, scale               sql.NullInt64
+			col                            Column
+		)
+		if err := rows.Scan(&tableName, &col.Name, &col.Type, &col.DataType, &nullable, &columnKey,
+			&precision, &scale); err != nil {
+			return nil, fmt.Errorf("failed to scan column metadata: %w", err)
+		}
+		col.Nullable = nullable == "YES"
+		col.PrimaryKey = columnKey == "PRI"
+		col.Precision, col.Scale = -1, -1
+		if precision.Valid {
+			col.Precision = int(precision.Int64)
+		}
+		if scale.Valid {
+			col.Scale = int(scale.Int64)
+		}
+
+		table, exists := result[tableName]
+		if !exists {
+			table = &Table{Database: database, Name: tableName}
+			result[tableName] = table
+		}
+		table.Columns = append(table.Columns, col)
+		if col.PrimaryKey {
+			table.PrimaryKey = append(table.PrimaryKey, col.Name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read column metadata: %w", err)
+	}
+
+	return result, nil
+}
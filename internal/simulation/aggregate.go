@@ -0,0 +1,191 @@
+package simulation
+
+import (
+	"fmt"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/kafitramarna/TransisiDB/internal/converter"
+	"github.com/kafitramarna/TransisiDB/internal/parser"
+	"github.com/kafitramarna/TransisiDB/internal/rounding"
+	"github.com/xwb1989/sqlparser"
+)
+
+// How a result column is converted to IDN
+const (
+	// ConvertNone leaves the value as the database returned it
+	ConvertNone = "none"
+	// ConvertRow converts a currency column's value in each row
+	ConvertRow = "row"
+	// ConvertAggregate converts the aggregate of a currency column once,
+	// after the database aggregated the IDR amounts
+	ConvertAggregate = "aggregate"
+)
+
+// convertedAggregates are the aggregates whose result is an amount. COUNT
+// and the like return other figures and are left alone.
+var convertedAggregates = map[string]bool{"sum": true, "avg": true, "min": true, "max": true}
+
+// resultAmounts parses values as MySQL returns them, where a '.' is always
+// the decimal point
+var resultAmounts = converter.NewAmountParser([]string{config.AmountLocalePlain})
+
+// resultColumn is how one column of a result is converted
+type resultColumn struct {
+	mode      string
+	colConfig config.ColumnConfig
+	// expr is the select expression, for expressions over currency columns
+	// left unconverted
+	expr string
+}
+
+// ResultPlan is how the columns of a SELECT's result are converted
+type ResultPlan struct {
+	tableConfig config.TableConfig
+	// columns follow the select list; nil when it has a star, and columns
+	// are then matched to currency columns by name
+	columns []resultColumn
+}
+
+// PlanQuery plans the conversion of the result of a SELECT on one configured
+// table. Bare currency columns are converted in each row; SUM, AVG, MIN and
+// MAX of a currency column are converted after aggregation. Other
+// expressions over currency columns are left as they are and reported by
+// Unconverted.
+func (s *Simulator) PlanQuery(query string) (*ResultPlan, error) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || len(sel.From) != 1 {
+		return nil, fmt.Errorf("only SELECT statements on one table can be simulated")
+	}
+	aliased, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil, fmt.Errorf("only SELECT statements on one table can be simulated")
+	}
+	table, ok := aliased.Expr.(sqlparser.TableName)
+	if !ok {
+		return nil, fmt.Errorf("only SELECT statements on one table can be simulated")
+	}
+	tableName := parser.NormalizeTableName(table.Name.String())
+	_, tableConfig, exists := s.config.Tables.Lookup(tableName, s.config.Database.LowerCaseTableNames != 0)
+	if !exists {
+		return nil, fmt.Errorf("table not configured: %s", tableName)
+	}
+
+	plan := &ResultPlan{tableConfig: tableConfig}
+	for _, expr := range sel.SelectExprs {
+		aliasedExpr, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			// A star expands to columns matched by name
+			plan.columns = nil
+			return plan, nil
+		}
+		plan.columns = append(plan.columns, plan.resultColumn(aliasedExpr.Expr))
+	}
+	return plan, nil
+}
+
+// resultColumn decides how the value of a select expression is converted
+func (p *ResultPlan) resultColumn(expr sqlparser.Expr) resultColumn {
+	switch e := expr.(type) {
+	case *sqlparser.ColName:
+		if _, colConfig, ok := p.tableConfig.LookupColumn(e.Name.String()); ok {
+			return resultColumn{mode: ConvertRow, colConfig: colConfig}
+		}
+	case *sqlparser.FuncExpr:
+		if e.IsAggregate() && !convertedAggregates[e.Name.Lowered()] {
+			return resultColumn{mode: ConvertNone}
+		}
+		if e.IsAggregate() && len(e.Exprs) == 1 {
+			if arg, ok := e.Exprs[0].(*sqlparser.AliasedExpr); ok {
+				if col, ok := arg.Expr.(*sqlparser.ColName); ok {
+					if _, colConfig, ok := p.tableConfig.LookupColumn(col.Name.String()); ok {
+						return resultColumn{mode: ConvertAggregate, colConfig: colConfig}
+					}
+				}
+			}
+		}
+	}
+	if p.referencesCurrency(expr) {
+		return resultColumn{mode: ConvertNone, expr: sqlparser.String(expr)}
+	}
+	return resultColumn{mode: ConvertNone}
+}
+
+// referencesCurrency reports whether an expression reads a currency column
+func (p *ResultPlan) referencesCurrency(expr sqlparser.Expr) bool {
+	found := false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if col, ok := node.(*sqlparser.ColName); ok {
+			if _, _, isCurrency := p.tableConfig.LookupColumn(col.Name.String()); isCurrency {
+				found = true
+			}
+		}
+		return !found, nil
+	}, expr)
+	return found
+}
+
+// Modes returns how each result column is converted, by name
+func (p *ResultPlan) Modes(columns []string) map[string]string {
+	modes := make(map[string]string, len(columns))
+	for i, col := range columns {
+		modes[col] = p.column(i, col).mode
+	}
+	return modes
+}
+
+// Unconverted returns the select expressions over currency columns that are
+// returned as the database computed them
+func (p *ResultPlan) Unconverted() []string {
+	var exprs []string
+	for _, col := range p.columns {
+		if col.expr != "" {
+			exprs = append(exprs, col.expr)
+		}
+	}
+	return exprs
+}
+
+// column returns how the result column at index i, named name, is converted
+func (p *ResultPlan) column(i int, name string) resultColumn {
+	if p.columns == nil {
+		if _, colConfig, ok := p.tableConfig.LookupColumn(name); ok {
+			return resultColumn{mode: ConvertRow, colConfig: colConfig}
+		}
+		return resultColumn{mode: ConvertNone}
+	}
+	if i < len(p.columns) {
+		return p.columns[i]
+	}
+	return resultColumn{mode: ConvertNone}
+}
+
+// convertRow converts the values of one result row in place
+func (s *Simulator) convertRow(plan *ResultPlan, columns []string, values []interface{}) error {
+	for i, name := range columns {
+		col := plan.column(i, name)
+		if col.mode == ConvertNone || values[i] == nil {
+			continue
+		}
+		amount, err := resultAmounts.Parse(values[i])
+		if err != nil {
+			return fmt.Errorf("column %s: %w", name, err)
+		}
+		values[i] = s.engineFor(col.colConfig).ConvertAmountIDRtoIDN(amount, s.config.Conversion.Ratio)
+	}
+	return nil
+}
+
+// engineFor returns the rounding of a column: its strategy and precision, or
+// the conversion's
+func (s *Simulator) engineFor(colConfig config.ColumnConfig) *rounding.Engine {
+	precision := colConfig.Precision
+	if precision == 0 {
+		precision = s.config.Conversion.Precision
+	}
+	strategy := colConfig.EffectiveRoundingStrategy(s.config.Conversion.RoundingStrategy)
+	return rounding.NewEngine(rounding.Strategy(strategy), precision)
+}
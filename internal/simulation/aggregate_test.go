@@ -0,0 +1,82 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/kafitramarna/TransisiDB/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSimulator() *Simulator {
+	return NewSimulator(&config.Config{
+		Conversion: config.ConversionConfig{Ratio: 1000, Precision: 2, RoundingStrategy: "BANKERS_ROUND"},
+		Tables: config.TablesConfig{
+			"orders": {Enabled: true, Columns: map[string]config.ColumnConfig{
+				"total_amount": {TargetColumn: "total_amount_idn"},
+				"shipping_fee": {TargetColumn: "shipping_fee_idn", RoundingStrategy: "ARITHMETIC_ROUND", Precision: 1},
+			}},
+		},
+	})
+}
+
+func TestPlanQuery(t *testing.T) {
+	sim := testSimulator()
+	plan, err := sim.PlanQuery("SELECT customer_id, SUM(total_amount) AS revenue, COUNT(total_amount), AVG(o.shipping_fee), " +
+		"MAX(total_amount), total_amount * 2, SUM(quantity) FROM orders o GROUP BY customer_id")
+	require.NoError(t, err)
+
+	columns := []string{"customer_id", "revenue", "COUNT(total_amount)", "AVG(o.shipping_fee)", "MAX(total_amount)", "total_amount * 2", "SUM(quantity)"}
+	assert.Equal(t, map[string]string{
+		"customer_id":         ConvertNone,
+		"revenue":             ConvertAggregate,
+		"COUNT(total_amount)": ConvertNone,
+		"AVG(o.shipping_fee)": ConvertAggregate,
+		"MAX(total_amount)":   ConvertAggregate,
+		"total_amount * 2":    ConvertNone,
+		"SUM(quantity)":       ConvertNone,
+	}, plan.Modes(columns))
+	assert.Equal(t, []string{"total_amount * 2"}, plan.Unconverted())
+
+	// A star matches currency columns by name
+	plan, err = sim.PlanQuery("SELECT * FROM orders")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"id": ConvertNone, "total_amount": ConvertRow}, plan.Modes([]string{"id", "total_amount"}))
+
+	_, err = sim.PlanQuery("SELECT SUM(total_amount) FROM invoices")
+	assert.ErrorContains(t, err, "table not configured")
+	_, err = sim.PlanQuery("SELECT * FROM orders JOIN customers")
+	assert.Error(t, err)
+	_, err = sim.PlanQuery("UPDATE orders SET total_amount = 1")
+	assert.Error(t, err)
+}
+
+func TestConvertRow_AggregateAfterSum(t *testing.T) {
+	sim := testSimulator()
+	plan, err := sim.PlanQuery("SELECT total_amount FROM orders")
+	require.NoError(t, err)
+
+	// Three orders of 1005 IDR convert to 1.00 IDN each under banker's
+	// rounding, 3.00 in total
+	var perRow float64
+	for i := 0; i < 3; i++ {
+		values := []interface{}{[]byte("1005")}
+		require.NoError(t, sim.convertRow(plan, []string{"total_amount"}, values))
+		perRow += values[0].(float64)
+	}
+	assert.InDelta(t, 3.00, perRow, 1e-9)
+
+	// Their sum of 3015 IDR converts once to 3.02
+	plan, err = sim.PlanQuery("SELECT SUM(total_amount), SUM(shipping_fee), COUNT(*) FROM orders")
+	require.NoError(t, err)
+	values := []interface{}{[]byte("3015"), []byte("150550.500"), int64(3)}
+	require.NoError(t, sim.convertRow(plan, []string{"SUM(total_amount)", "SUM(shipping_fee)", "COUNT(*)"}, values))
+	assert.InDelta(t, 3.02, values[0].(float64), 1e-9)
+	assert.InDelta(t, 150.6, values[1].(float64), 1e-9, "the column's own rounding and precision apply")
+	assert.Equal(t, int64(3), values[2])
+
+	// Aggregates over no rows stay NULL
+	values = []interface{}{nil, nil, int64(0)}
+	require.NoError(t, sim.convertRow(plan, []string{"SUM(total_amount)", "SUM(shipping_fee)", "COUNT(*)"}, values))
+	assert.Nil(t, values[0])
+}
@@ -5,33 +5,41 @@ import (
 	"fmt"
 
 	"github.com/kafitramarna/TransisiDB/internal/config"
-	"github.com/kafitramarna/TransisiDB/internal/rounding"
 )
 
 // Simulator handles simulation mode for testing
 type Simulator struct {
-	config         *config.Config
-	roundingEngine *rounding.Engine
+	config *config.Config
 }
 
 // NewSimulator creates a new simulator
 func NewSimulator(cfg *config.Config) *Simulator {
-	return &Simulator{
-		config: cfg,
-		roundingEngine: rounding.NewEngine(
-			rounding.Strategy(cfg.Conversion.RoundingStrategy),
-			cfg.Conversion.Precision,
-		),
-	}
+	return &Simulator{config: cfg}
 }
 
-// TransformResponse transforms database response to simulation format
+// TransformResponse transforms database response to simulation format.
+// Currency columns are matched by name and converted in each row.
 func (s *Simulator) TransformResponse(rows *sql.Rows, tableName string) (*SimulatedResponse, error) {
-	tableConfig, exists := s.config.Tables[tableName]
+	_, tableConfig, exists := s.config.Tables.Lookup(tableName, s.config.Database.LowerCaseTableNames != 0)
 	if !exists {
 		return nil, fmt.Errorf("table not configured: %s", tableName)
 	}
+	return s.transform(rows, &ResultPlan{tableConfig: tableConfig})
+}
 
+// TransformQueryResponse transforms the response to query to simulation
+// format following PlanQuery: aggregates of currency columns are converted
+// after aggregation rather than summing converted rows
+func (s *Simulator) TransformQueryResponse(rows *sql.Rows, query string) (*SimulatedResponse, error) {
+	plan, err := s.PlanQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.transform(rows, plan)
+}
+
+// transform scans the rows and converts them following plan
+func (s *Simulator) transform(rows *sql.Rows, plan *ResultPlan) (*SimulatedResponse, error) {
 	// Get column names
 	columns, err := rows.Columns()
 	if err != nil {
@@ -51,24 +59,14 @@ func (s *Simulator) TransformResponse(rows *sql.Rows, tableName string) (*Simula
 		if err := rows.Scan(valuePtrs...); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
+		if err := s.convertRow(plan, columns, values); err != nil {
+			return nil, err
+		}
 
-		// Build row map with transformation
-		row := make(map[string]interface{})
+		row := make(map[string]interface{}, len(columns))
 		for i, col := range columns {
-			// Check if this is a currency column
-			if _, isCurrency := tableConfig.Columns[col]; isCurrency {
-				// Transform to IDN
-				if intVal, ok := values[i].(int64); ok {
-					converted := s.roundingEngine.ConvertIDRtoIDN(intVal, s.config.Conversion.Ratio)
-					row[col] = converted
-				} else {
-					row[col] = values[i]
-				}
-			} else {
-				row[col] = values[i]
-			}
+			row[col] = values[i]
 		}
-
 		data = append(data, row)
 	}
 
@@ -79,9 +77,11 @@ func (s *Simulator) TransformResponse(rows *sql.Rows, tableName string) (*Simula
 	return &SimulatedResponse{
 		Data: data,
 		Metadata: ResponseMetadata{
-			Simulated: true,
-			Currency:  "IDN",
-			Ratio:     s.config.Conversion.Ratio,
+			Simulated:   true,
+			Currency:    "IDN",
+			Ratio:       s.config.Conversion.Ratio,
+			Conversions: plan.Modes(columns),
+			Unconverted: plan.Unconverted(),
 		},
 	}, nil
 }
@@ -125,4 +125,8 @@ type ResponseMetadata struct {
 	Simulated bool   `json:"simulated"`
 	Currency  string `json:"currency"`
 	Ratio     int    `json:"conversion_ratio"`
+	// Conversions says how each column was converted: none, row or aggregate
+	Conversions map[string]string `json:"conversions"`
+	// Unconverted lists expressions over currency columns returned in IDR
+	Unconverted []string `json:"unconverted,omitempty"`
 }
@@ -0,0 +1,409 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// BinaryDateTime is a DATE, DATETIME or TIMESTAMP value in the binary protocol.
+// Zero dates ("0000-00-00") are representable, unlike time.Time.
+type BinaryDateTime struct {
+	Year        uint16
+	Month       uint8
+	Day         uint8
+	Hour        uint8
+	Minute      uint8
+	Second      uint8
+	Microsecond uint32
+}
+
+// BinaryTime is a TIME value in the binary protocol
+type BinaryTime struct {
+	Negative    bool
+	Days        uint32
+	Hours       uint8
+	Minutes     uint8
+	Seconds     uint8
+	Microsecond uint32
+}
+
+// BinaryRow is a resultset row in the binary protocol (COM_STMT_EXECUTE).
+// Values are decoded per column type:
+//   - integer types: int64, or uint64 for UNSIGNED columns
+//   - FLOAT: float32, DOUBLE: float64
+//   - DATE/DATETIME/TIMESTAMP: BinaryDateTime, TIME: BinaryTime
+//   - DECIMAL, strings, blobs, JSON, BIT, ENUM, SET: []byte
+//
+// A nil value is SQL NULL.
+type BinaryRow []interface{}
+
+// binaryRowNullOffset is the bit offset of the first column in the NULL bitmap
+const binaryRowNullOffset = 2
+
+// DecodeBinaryRow parses a binary protocol row packet for the given columns
+func DecodeBinaryRow(payload []byte, columns []*ColumnDefinition41) (BinaryRow, error) {
+	r := &payloadReader{buf: payload}
+
+	header, err := r.readByte()
+	if err != nil || header != OK_PACKET {
+		return nil, fmt.Errorf("binary row: invalid packet header")
+	}
+
+	bitmap, err := r.readBytes((len(columns) + 7 + binaryRowNullOffset) / 8)
+	if err != nil {
+		return nil, fmt.Errorf("binary row: NULL bitmap: %w", err)
+	}
+
+	row := make(BinaryRow, len(columns))
+	for i, col := range columns {
+		bit := i + binaryRowNullOffset
+		if bitmap[bit/8]&(1<<(bit%8)) != 0 {
+			continue
+		}
+
+		value, err := decodeBinaryValue(r, col)
+		if err != nil {
+			return nil, fmt.Errorf("binary row: column %d (%s): %w", i, col.Name, err)
+		}
+		row[i] = value
+	}
+
+	if !r.done() {
+		return nil, fmt.Errorf("binary row: %d trailing bytes", len(r.rest()))
+	}
+
+	return row, nil
+}
+
+func decodeBinaryValue(r *payloadReader, col *ColumnDefinition41) (interface{}, error) {
+	unsigned := col.Flags&UNSIGNED_FLAG != 0
+
+	switch col.Type {
+	case MYSQL_TYPE_TINY:
+		b, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if unsigned {
+			return uint64(b), nil
+		}
+		return int64(int8(b)), nil
+
+	case MYSQL_TYPE_SHORT, MYSQL_TYPE_YEAR:
+		v, err := r.readUint16()
+		if err != nil {
+			return nil, err
+		}
+		if unsigned || col.Type == MYSQL_TYPE_YEAR {
+			return uint64(v), nil
+		}
+		return int64(int16(v)), nil
+
+	case MYSQL_TYPE_LONG, MYSQL_TYPE_INT24:
+		v, err := r.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		if unsigned {
+			return uint64(v), nil
+		}
+		return int64(int32(v)), nil
+
+	case MYSQL_TYPE_LONGLONG:
+		b, err := r.readBytes(8)
+		if err != nil {
+			return nil, err
+		}
+		v := binary.LittleEndian.Uint64(b)
+		if unsigned {
+			return v, nil
+		}
+		return int64(v), nil
+
+	case MYSQL_TYPE_FLOAT:
+		v, err := r.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(v), nil
+
+	case MYSQL_TYPE_DOUBLE:
+		b, err := r.readBytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_NEWDATE:
+		return decodeBinaryDateTime(r)
+
+	case MYSQL_TYPE_TIME:
+		return decodeBinaryTime(r)
+
+	case MYSQL_TYPE_NULL:
+		return nil, nil
+
+	default:
+		// DECIMAL, strings, blobs, JSON, BIT, ENUM, SET and GEOMETRY are length-encoded
+		length, n := readLengthEncodedInt(r.rest())
+		if n == 0 {
+			return nil, fmt.Errorf("unexpected end of packet")
+		}
+		r.pos += n
+		b, err := r.readBytes(int(length))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	}
+}
+
+func decodeBinaryDateTime(r *payloadReader) (BinaryDateTime, error) {
+	var dt BinaryDateTime
+
+	length, err := r.readByte()
+	if err != nil {
+		return dt, err
+	}
+	b, err := r.readBytes(int(length))
+	if err != nil {
+		return dt, err
+	}
+
+	switch length {
+	case 0:
+	case 4, 7, 11:
+		dt.Year = binary.LittleEndian.Uint16(b[0:2])
+		dt.Month, dt.Day = b[2], b[3]
+		if length >= 7 {
+			dt.Hour, dt.Minute, dt.Second = b[4], b[5], b[6]
+		}
+		if length == 11 {
+			dt.Microsecond = binary.LittleEndian.Uint32(b[7:11])
+		}
+	default:
+		return dt, fmt.Errorf("invalid datetime length %d", length)
+	}
+	return dt, nil
+}
+
+func decodeBinaryTime(r *payloadReader) (BinaryTime, error) {
+	var t BinaryTime
+
+	length, err := r.readByte()
+	if err != nil {
+		return t, err
+	}
+	b, err := r.readBytes(int(length))
+	if err != nil {
+		return t, err
+	}
+
+	switch length {
+	case 0:
+	case 8, 12:
+		t.Negative = b[0] == 1
+		t.Days = binary.LittleEndian.Uint32(b[1:5])
+		t.Hours, t.Minutes, t.Seconds = b[5], b[6], b[7]
+		if length == 12 {
+			t.Microsecond = binary.LittleEndian.Uint32(b[8:12])
+		}
+	default:
+		return t, fmt.Errorf("invalid time length %d", length)
+	}
+	return t, nil
+}
+
+// Encode serializes the row as a binary protocol row packet payload
+func (row BinaryRow) Encode(columns []*ColumnDefinition41) ([]byte, error) {
+	if len(row) != len(columns) {
+		return nil, fmt.Errorf("binary row: %d values for %d columns", len(row), len(columns))
+	}
+
+	bitmap := make([]byte, (len(columns)+7+binaryRowNullOffset)/8)
+	var values []byte
+
+	for i, col := range columns {
+		if row[i] == nil {
+			bit := i + binaryRowNullOffset
+			bitmap[bit/8] |= 1 << (bit % 8)
+			continue
+		}
+
+		var err error
+		values, err = encodeBinaryValue(values, col, row[i])
+		if err != nil {
+			return nil, fmt.Errorf("binary row: column %d (%s): %w", i, col.Name, err)
+		}
+	}
+
+	buf := []byte{OK_PACKET}
+	buf = append(buf, bitmap...)
+	return append(buf, values...), nil
+}
+
+func encodeBinaryValue(buf []byte, col *ColumnDefinition41, value interface{}) ([]byte, error) {
+	switch col.Type {
+	case MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_YEAR, MYSQL_TYPE_LONG, MYSQL_TYPE_INT24, MYSQL_TYPE_LONGLONG:
+		var v uint64
+		switch n := value.(type) {
+		case int64:
+			v = uint64(n)
+		case uint64:
+			v = n
+		default:
+			return nil, fmt.Errorf("expected integer, got %T", value)
+		}
+		switch col.Type {
+		case MYSQL_TYPE_TINY:
+			return append(buf, byte(v)), nil
+		case MYSQL_TYPE_SHORT, MYSQL_TYPE_YEAR:
+			return WriteUint16(buf, uint16(v)), nil
+		case MYSQL_TYPE_LONG, MYSQL_TYPE_INT24:
+			return WriteUint32(buf, uint32(v)), nil
+		default:
+			return binary.LittleEndian.AppendUint64(buf, v), nil
+		}
+
+	case MYSQL_TYPE_FLOAT:
+		f, ok := value.(float32)
+		if !ok {
+			return nil, fmt.Errorf("expected float32, got %T", value)
+		}
+		return WriteUint32(buf, math.Float32bits(f)), nil
+
+	case MYSQL_TYPE_DOUBLE:
+		f, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected float64, got %T", value)
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), nil
+
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_NEWDATE:
+		dt, ok := value.(BinaryDateTime)
+		if !ok {
+			return nil, fmt.Errorf("expected BinaryDateTime, got %T", value)
+		}
+		switch {
+		case dt.Microsecond != 0:
+			buf = append(buf, 11)
+		case dt.Hour != 0 || dt.Minute != 0 || dt.Second != 0:
+			buf = append(buf, 7)
+		case dt != (BinaryDateTime{}):
+			buf = append(buf, 4)
+		default:
+			return append(buf, 0), nil
+		}
+		length := buf[len(buf)-1]
+		buf = WriteUint16(buf, dt.Year)
+		buf = append(buf, dt.Month, dt.Day)
+		if length >= 7 {
+			buf = append(buf, dt.Hour, dt.Minute, dt.Second)
+		}
+		if length == 11 {
+			buf = WriteUint32(buf, dt.Microsecond)
+		}
+		return buf, nil
+
+	case MYSQL_TYPE_TIME:
+		t, ok := value.(BinaryTime)
+		if !ok {
+			return nil, fmt.Errorf("expected BinaryTime, got %T", value)
+		}
+		if t == (BinaryTime{}) {
+			return append(buf, 0), nil
+		}
+		length := byte(8)
+		if t.Microsecond != 0 {
+			length = 12
+		}
+		negative := byte(0)
+		if t.Negative {
+			negative = 1
+		}
+		buf = append(buf, length, negative)
+		buf = WriteUint32(buf, t.Days)
+		buf = append(buf, t.Hours, t.Minutes, t.Seconds)
+		if length == 12 {
+			buf = WriteUint32(buf, t.Microsecond)
+		}
+		return buf, nil
+
+	default:
+		var b []byte
+		switch v := value.(type) {
+		case []byte:
+			b = v
+		case string:
+			b = []byte(v)
+		default:
+			return nil, fmt.Errorf("expected []byte, got %T", value)
+		}
+		buf = WriteLengthEncodedInt(buf, uint64(len(b)))
+		return append(buf, b...), nil
+	}
+}
+
+// Text converts the row to its text protocol representation, so binary and
+// text resultsets can be compared or rewritten the same way
+func (row BinaryRow) Text(columns []*ColumnDefinition41) TextRow {
+	text := make(TextRow, len(row))
+	for i, value := range row {
+		if value == nil {
+			continue
+		}
+		var col ColumnDefinition41
+		if i < len(columns) {
+			col = *columns[i]
+		}
+		text[i] = []byte(formatBinaryValue(value, col))
+	}
+	return text
+}
+
+func formatBinaryValue(value interface{}, col ColumnDefinition41) string {
+	decimals := col.Decimals
+
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case BinaryDateTime:
+		s := fmt.Sprintf("%04d-%02d-%02d", v.Year, v.Month, v.Day)
+		if col.Type == MYSQL_TYPE_DATE || col.Type == MYSQL_TYPE_NEWDATE {
+			return s
+		}
+		s += fmt.Sprintf(" %02d:%02d:%02d", v.Hour, v.Minute, v.Second)
+		return s + formatMicroseconds(v.Microsecond, decimals)
+	case BinaryTime:
+		sign := ""
+		if v.Negative {
+			sign = "-"
+		}
+		hours := v.Days*24 + uint32(v.Hours)
+		return fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, v.Minutes, v.Seconds) + formatMicroseconds(v.Microsecond, decimals)
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatMicroseconds renders the fractional seconds part for a column with
+// the given number of decimals (fsp); 0x1f means "not fixed"
+func formatMicroseconds(us uint32, decimals byte) string {
+	if decimals == 0 || decimals > 6 {
+		if us == 0 {
+			return ""
+		}
+		decimals = 6
+	}
+	return "." + fmt.Sprintf("%06d", us)[:decimals]
+}
@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func binaryTestColumns() []*ColumnDefinition41 {
+	return []*ColumnDefinition41{
+		{Name: "id", Type: MYSQL_TYPE_LONGLONG, Flags: NOT_NULL_FLAG | PRI_KEY_FLAG},
+		{Name: "total_amount_idn", Type: MYSQL_TYPE_NEWDECIMAL, Decimals: 4},
+		{Name: "discount", Type: MYSQL_TYPE_LONG},
+		{Name: "created_at", Type: MYSQL_TYPE_DATETIME},
+		{Name: "qty", Type: MYSQL_TYPE_TINY, Flags: UNSIGNED_FLAG},
+		{Name: "rate", Type: MYSQL_TYPE_DOUBLE},
+		{Name: "elapsed", Type: MYSQL_TYPE_TIME},
+	}
+}
+
+// Row of a COM_STMT_EXECUTE response in the binary protocol, with discount NULL
+const binaryRowFixture = "001000010000000000000009313530302e3030303007e907010f0a1e00c8000000000000e03f080101000000020304"
+
+func TestDecodeBinaryRow(t *testing.T) {
+	columns := binaryTestColumns()
+	payload := mustHex(t, binaryRowFixture)
+
+	row, err := DecodeBinaryRow(payload, columns)
+	require.NoError(t, err)
+	require.Len(t, row, 7)
+
+	assert.Equal(t, int64(1), row[0])
+	assert.Equal(t, []byte("1500.0000"), row[1])
+	assert.Nil(t, row[2])
+	assert.Equal(t, BinaryDateTime{Year: 2025, Month: 1, Day: 15, Hour: 10, Minute: 30}, row[3])
+	assert.Equal(t, uint64(200), row[4])
+	assert.Equal(t, 0.5, row[5])
+	assert.Equal(t, BinaryTime{Negative: true, Days: 1, Hours: 2, Minutes: 3, Seconds: 4}, row[6])
+
+	encoded, err := row.Encode(columns)
+	require.NoError(t, err)
+	assert.Equal(t, payload, encoded)
+
+	text := row.Text(columns)
+	assert.Equal(t, "1", text.String(0))
+	assert.Equal(t, "1500.0000", text.String(1))
+	assert.True(t, text.IsNull(2))
+	assert.Equal(t, "2025-01-15 10:30:00", text.String(3))
+	assert.Equal(t, "200", text.String(4))
+	assert.Equal(t, "0.5", text.String(5))
+	assert.Equal(t, "-26:03:04", text.String(6))
+}
+
+func TestBinaryRowTemporalValues(t *testing.T) {
+	columns := []*ColumnDefinition41{
+		{Name: "d", Type: MYSQL_TYPE_DATE},
+		{Name: "ts", Type: MYSQL_TYPE_TIMESTAMP, Decimals: 3},
+		{Name: "zero", Type: MYSQL_TYPE_DATETIME},
+		{Name: "t", Type: MYSQL_TYPE_TIME, Decimals: 6},
+	}
+	row := BinaryRow{
+		BinaryDateTime{Year: 2025, Month: 1, Day: 15},
+		BinaryDateTime{Year: 2025, Month: 1, Day: 15, Hour: 8, Microsecond: 123000},
+		BinaryDateTime{},
+		BinaryTime{Hours: 1, Microsecond: 5},
+	}
+
+	payload, err := row.Encode(columns)
+	require.NoError(t, err)
+
+	decoded, err := DecodeBinaryRow(payload, columns)
+	require.NoError(t, err)
+	assert.Equal(t, row, decoded)
+
+	text := decoded.Text(columns)
+	assert.Equal(t, "2025-01-15", text.String(0))
+	assert.Equal(t, "2025-01-15 08:00:00.123", text.String(1))
+	assert.Equal(t, "0000-00-00 00:00:00", text.String(2))
+	assert.Equal(t, "01:00:00.000005", text.String(3))
+}
+
+func TestDecodeBinaryRowErrors(t *testing.T) {
+	columns := binaryTestColumns()
+	payload := mustHex(t, binaryRowFixture)
+
+	_, err := DecodeBinaryRow(payload[:len(payload)-1], columns)
+	assert.Error(t, err)
+
+	_, err = DecodeBinaryRow(append(payload, 0x00), columns)
+	assert.Error(t, err)
+
+	bad := append([]byte{}, payload...)
+	bad[0] = 0xff
+	_, err = DecodeBinaryRow(bad, columns)
+	assert.Error(t, err)
+
+	_, err = BinaryRow{"not an int"}.Encode(columns[:1])
+	assert.Error(t, err)
+}
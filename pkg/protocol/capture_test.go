@@ -0,0 +1,329 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturedPacket is one physical packet of a capture fixture, header included
+type capturedPacket struct {
+	fromServer bool
+	raw        []byte
+}
+
+// loadCapture reads a fixture from testdata. Each packet is a line holding S
+// (server to client) or C (client to server) and the packet's hex; lines
+// starting with # are comments.
+func loadCapture(t *testing.T, name string) []capturedPacket {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var packets []capturedPacket
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		direction, data, ok := strings.Cut(line, " ")
+		require.True(t, ok && (direction == "S" || direction == "C"), "%s: malformed line %q", name, line)
+		packets = append(packets, capturedPacket{fromServer: direction == "S", raw: mustHex(t, data)})
+	}
+	require.NoError(t, scanner.Err())
+	require.NotEmpty(t, packets, "%s has no packets", name)
+	return packets
+}
+
+func TestCapturesFraming(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.capture"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			var server bytes.Buffer
+			for i, captured := range loadCapture(t, name) {
+				r := bytes.NewReader(captured.raw)
+				pkt, err := ReadPacket(r)
+				require.NoError(t, err, "packet %d", i)
+				assert.Zero(t, r.Len(), "packet %d has trailing bytes", i)
+
+				var buf bytes.Buffer
+				require.NoError(t, WritePacket(&buf, pkt.SequenceID, pkt.Payload))
+				assert.Equal(t, captured.raw, buf.Bytes(), "packet %d", i)
+
+				if captured.fromServer {
+					server.Write(captured.raw)
+				}
+			}
+
+			// Back to back, the server's packets are read one at a time
+			for server.Len() > 0 {
+				_, err := ReadPacket(&server)
+				require.NoError(t, err)
+			}
+			assert.Zero(t, server.Len())
+		})
+	}
+}
+
+// roundTrip decodes a captured payload and encodes the result again
+type roundTrip func(payload []byte) (decoded interface{}, encoded []byte, err error)
+
+func handshakeV10RoundTrip(payload []byte) (interface{}, []byte, error) {
+	h, err := DecodeHandshakeV10(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, h.Encode(), nil
+}
+
+func handshakeResponseRoundTrip(payload []byte) (interface{}, []byte, error) {
+	resp, err := DecodeHandshakeResponse41(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, resp.Encode(), nil
+}
+
+func authSwitchRoundTrip(payload []byte) (interface{}, []byte, error) {
+	req, err := DecodeAuthSwitchRequest(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return req, req.Encode(), nil
+}
+
+func okRoundTrip(payload []byte) (interface{}, []byte, error) {
+	ok, err := ParseOKPacket(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ok, EncodeOKPacket(ok.AffectedRows, ok.LastInsertID, ok.StatusFlags, ok.Warnings), nil
+}
+
+func errRoundTrip(payload []byte) (interface{}, []byte, error) {
+	e, err := ParseERRPacket(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, EncodeERRPacket(e.ErrorCode, e.SQLState, e.ErrorMessage), nil
+}
+
+func eofRoundTrip(payload []byte) (interface{}, []byte, error) {
+	eof, err := ParseEOFPacket(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return eof, EncodeEOFPacket(eof.Warnings, eof.StatusFlags), nil
+}
+
+func columnCountRoundTrip(payload []byte) (interface{}, []byte, error) {
+	count, err := DecodeColumnCount(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return count, EncodeColumnCount(count), nil
+}
+
+func columnRoundTrip(payload []byte) (interface{}, []byte, error) {
+	col, err := DecodeColumnDefinition41(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return col, col.Encode(), nil
+}
+
+func textRowRoundTrip(columnCount int) roundTrip {
+	return func(payload []byte) (interface{}, []byte, error) {
+		row, err := DecodeTextRow(payload, columnCount)
+		if err != nil {
+			return nil, nil, err
+		}
+		return row, row.Encode(), nil
+	}
+}
+
+func TestCapturesRoundTrip(t *testing.T) {
+	const (
+		nativePassword = "handshake_native_password.capture"
+		cachingSHA2    = "handshake_caching_sha2.capture"
+		authSwitch     = "auth_switch.capture"
+		commands       = "commands.capture"
+		orders         = "resultset_orders.capture"
+		versionComment = "version_comment.capture"
+	)
+	autocommit := &OKPacket{StatusFlags: SERVER_STATUS_AUTOCOMMIT}
+
+	tests := []struct {
+		name    string
+		capture string
+		packet  int
+		decode  roundTrip
+		want    interface{}
+	}{
+		{"mysql 5.6 handshake", nativePassword, 0, handshakeV10RoundTrip, &HandshakeV10{
+			ProtocolVersion: 10,
+			ServerVersion:   "5.6.4-m7-log",
+			ConnectionID:    2646,
+			AuthPluginData:  []byte("RB3vz&Gr+yD&/ZZ305ZG"),
+			CapabilityFlags: 0xc00fffff,
+			CharacterSet:    8,
+			StatusFlags:     SERVER_STATUS_AUTOCOMMIT,
+			AuthPluginName:  "mysql_native_password",
+		}},
+		{"native password response", nativePassword, 1, handshakeResponseRoundTrip, &HandshakeResponse41{
+			CapabilityFlags: 0x000fa68d,
+			MaxPacketSize:   1 << 24,
+			CharacterSet:    8,
+			Username:        "pam",
+			AuthResponse:    mustHex(t, "ab09eef6bcb1323e61143865c0991d957d75d447"),
+			Database:        "test",
+			AuthPluginName:  "mysql_native_password",
+		}},
+		{"native password ok", nativePassword, 2, okRoundTrip, autocommit},
+
+		{"mysql 8.0 handshake", cachingSHA2, 0, handshakeV10RoundTrip, &HandshakeV10{
+			ProtocolVersion: 10,
+			ServerVersion:   "8.0.36",
+			ConnectionID:    12,
+			AuthPluginData:  mustHex(t, "1b3f6a217c4d0e596b43057a2f1e38614c0b7d12"),
+			CapabilityFlags: 0xdfffffff,
+			CharacterSet:    255,
+			StatusFlags:     SERVER_STATUS_AUTOCOMMIT,
+			AuthPluginName:  "caching_sha2_password",
+		}},
+		{"caching_sha2 response with attributes", cachingSHA2, 1, handshakeResponseRoundTrip, &HandshakeResponse41{
+			CapabilityFlags: CLIENT_LONG_PASSWORD | CLIENT_LONG_FLAG | CLIENT_CONNECT_WITH_DB | CLIENT_LOCAL_FILES |
+				CLIENT_PROTOCOL_41 | CLIENT_TRANSACTIONS | CLIENT_SECURE_CONNECTION | CLIENT_MULTI_RESULTS |
+				CLIENT_PS_MULTI_RESULTS | CLIENT_PLUGIN_AUTH | CLIENT_CONNECT_ATTRS |
+				CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA | CLIENT_SESSION_TRACK,
+			MaxPacketSize:  1 << 24,
+			CharacterSet:   255,
+			Username:       "shop",
+			AuthResponse:   mustHex(t, "9c2e57d1a4f0386b12c5e97d40ab63f88e1d7a25c03b94e6f17a52d8b6093e41"),
+			Database:       "shop",
+			AuthPluginName: "caching_sha2_password",
+			Attributes: map[string]string{
+				"_client_name":    "libmysql",
+				"_client_version": "8.0.36",
+				"_os":             "Linux",
+				"_pid":            "4242",
+				"_platform":       "x86_64",
+				"program_name":    "mysql",
+			},
+		}},
+		{"ok after fast auth", cachingSHA2, 3, okRoundTrip, autocommit},
+
+		{"response without database", authSwitch, 1, handshakeResponseRoundTrip, &HandshakeResponse41{
+			CapabilityFlags: CLIENT_LONG_PASSWORD | CLIENT_LONG_FLAG | CLIENT_PROTOCOL_41 | CLIENT_TRANSACTIONS |
+				CLIENT_SECURE_CONNECTION | CLIENT_MULTI_RESULTS | CLIENT_PLUGIN_AUTH |
+				CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA,
+			MaxPacketSize:  1 << 24,
+			CharacterSet:   255,
+			Username:       "legacy",
+			AuthResponse:   mustHex(t, "3fa1c7e20d9b5864f21e7ac03d58b19e6c04f7a28d53e1b60a9c47f2e15d8b3c"),
+			AuthPluginName: "caching_sha2_password",
+		}},
+		{"auth switch request", authSwitch, 2, authSwitchRoundTrip, &AuthSwitchRequest{
+			PluginName: "mysql_native_password",
+			PluginData: mustHex(t, "4a1f6e0b2c73155d3a68247b0e51193f6c2a7d4000"),
+		}},
+		{"ok after auth switch", authSwitch, 4, okRoundTrip, autocommit},
+
+		{"no tables used", commands, 1, errRoundTrip, &ERRPacket{ErrorCode: 1096, SQLState: "HY000", ErrorMessage: "No tables used"}},
+		{"insert ok", commands, 3, okRoundTrip, &OKPacket{AffectedRows: 1, LastInsertID: 42, StatusFlags: SERVER_STATUS_AUTOCOMMIT}},
+		{"missing table", commands, 5, errRoundTrip, &ERRPacket{ErrorCode: 1146, SQLState: "42S02", ErrorMessage: "Table 'shop.missing' doesn't exist"}},
+		{"ping ok", commands, 7, okRoundTrip, autocommit},
+
+		{"orders column count", orders, 1, columnCountRoundTrip, 4},
+		{"orders bigint column", orders, 2, columnRoundTrip, &ColumnDefinition41{
+			Catalog: "def", Schema: "shop", Table: "orders", OrgTable: "orders", Name: "id", OrgName: "id",
+			CharacterSet: 63, ColumnLength: 20, Type: MYSQL_TYPE_LONGLONG,
+			Flags: NOT_NULL_FLAG | PRI_KEY_FLAG | UNSIGNED_FLAG | AUTO_INCREMENT_FLAG | 0x4000, // PART_KEY
+		}},
+		{"orders decimal column", orders, 3, columnRoundTrip, &ColumnDefinition41{
+			Catalog: "def", Schema: "shop", Table: "orders", OrgTable: "orders", Name: "total_amount", OrgName: "total_amount",
+			CharacterSet: 63, ColumnLength: 17, Type: MYSQL_TYPE_NEWDECIMAL,
+			Flags: NOT_NULL_FLAG | 0x1000, Decimals: 2, // NO_DEFAULT_VALUE
+		}},
+		{"orders shadow column", orders, 4, columnRoundTrip, &ColumnDefinition41{
+			Catalog: "def", Schema: "shop", Table: "orders", OrgTable: "orders", Name: "total_amount_idr", OrgName: "total_amount_idr",
+			CharacterSet: 63, ColumnLength: 21, Type: MYSQL_TYPE_NEWDECIMAL, Decimals: 4,
+		}},
+		{"orders varchar column", orders, 5, columnRoundTrip, &ColumnDefinition41{
+			Catalog: "def", Schema: "shop", Table: "orders", OrgTable: "orders", Name: "customer_note", OrgName: "customer_note",
+			CharacterSet: 255, ColumnLength: 1020, Type: MYSQL_TYPE_VAR_STRING,
+		}},
+		{"orders columns eof", orders, 6, eofRoundTrip, &EOFPacket{StatusFlags: 0x0022}},
+		{"orders row", orders, 7, textRowRoundTrip(4), TextRow{
+			[]byte("1"), []byte("1500000.00"), []byte("1500.0000"), []byte("first order"),
+		}},
+		{"orders row with nulls", orders, 8, textRowRoundTrip(4), TextRow{
+			[]byte("2"), []byte("250000.00"), nil, nil,
+		}},
+		{"orders rows eof", orders, 9, eofRoundTrip, &EOFPacket{StatusFlags: 0x0022}},
+
+		{"version comment column count", versionComment, 1, columnCountRoundTrip, 1},
+		{"version comment column", versionComment, 2, columnRoundTrip, &ColumnDefinition41{
+			Catalog: "def", Name: "@@version_comment",
+			CharacterSet: 8, ColumnLength: 28, Type: MYSQL_TYPE_VAR_STRING, Decimals: 0x1f,
+		}},
+		{"version comment columns eof", versionComment, 3, eofRoundTrip, &EOFPacket{StatusFlags: SERVER_STATUS_AUTOCOMMIT}},
+		{"version comment row", versionComment, 4, textRowRoundTrip(1), TextRow{[]byte("MySQL Community Server (GPL)")}},
+		{"version comment rows eof", versionComment, 5, eofRoundTrip, &EOFPacket{StatusFlags: SERVER_STATUS_AUTOCOMMIT}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packets := loadCapture(t, tt.capture)
+			require.Less(t, tt.packet, len(packets))
+
+			pkt, err := ReadPacket(bytes.NewReader(packets[tt.packet].raw))
+			require.NoError(t, err)
+
+			decoded, encoded, err := tt.decode(pkt.Payload)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, decoded)
+			assert.Equal(t, pkt.Payload, encoded, "payload differs after encoding")
+		})
+	}
+}
+
+func TestCapturesPacketKinds(t *testing.T) {
+	// The checks the proxy uses to relay responses agree with the captures
+	for _, tt := range []struct {
+		capture string
+		packet  int
+		ok      bool
+		eof     bool
+		err     bool
+	}{
+		{"handshake_caching_sha2.capture", 2, false, false, false}, // fast auth success
+		{"handshake_caching_sha2.capture", 3, true, false, false},
+		{"auth_switch.capture", 2, false, false, false}, // auth switch, too long for EOF
+		{"commands.capture", 1, false, false, true},
+		{"commands.capture", 3, true, false, false},
+		{"resultset_orders.capture", 6, false, true, false},
+		{"resultset_orders.capture", 8, false, false, false}, // row, not OK
+	} {
+		packets := loadCapture(t, tt.capture)
+		pkt, err := ReadPacket(bytes.NewReader(packets[tt.packet].raw))
+		require.NoError(t, err)
+
+		assert.Equal(t, tt.ok, IsOKPacket(pkt.Payload), "%s packet %d: IsOKPacket", tt.capture, tt.packet)
+		assert.Equal(t, tt.eof, IsEOFPacket(pkt.Payload), "%s packet %d: IsEOFPacket", tt.capture, tt.packet)
+		assert.Equal(t, tt.err, IsERRPacket(pkt.Payload), "%s packet %d: IsERRPacket", tt.capture, tt.packet)
+	}
+}
@@ -38,9 +38,16 @@ const (
 
 // Packet Type Indicators
 const (
-	OK_PACKET  = 0x00
-	EOF_PACKET = 0xFE
-	ERR_PACKET = 0xFF
+	OK_PACKET           = 0x00
+	LOCAL_INFILE_PACKET = 0xFB
+	EOF_PACKET          = 0xFE
+	ERR_PACKET          = 0xFF
+)
+
+// Server status flags reported in OK and EOF packets
+const (
+	SERVER_STATUS_IN_TRANS   = 0x0001
+	SERVER_STATUS_AUTOCOMMIT = 0x0002
 )
 
 // GetCommandName returns the string name of a command type
@@ -1,9 +1,47 @@
 package protocol
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sort"
 )
 
+// Capability flags
+const (
+	CLIENT_LONG_PASSWORD                  = 0x00000001
+	CLIENT_FOUND_ROWS                     = 0x00000002
+	CLIENT_LONG_FLAG                      = 0x00000004
+	CLIENT_CONNECT_WITH_DB                = 0x00000008
+	CLIENT_NO_SCHEMA                      = 0x00000010
+	CLIENT_COMPRESS                       = 0x00000020
+	CLIENT_ODBC                           = 0x00000040
+	CLIENT_LOCAL_FILES                    = 0x00000080
+	CLIENT_IGNORE_SPACE                   = 0x00000100
+	CLIENT_PROTOCOL_41                    = 0x00000200
+	CLIENT_INTERACTIVE                    = 0x00000400
+	CLIENT_SSL                            = 0x00000800
+	CLIENT_IGNORE_SIGPIPE                 = 0x00001000
+	CLIENT_TRANSACTIONS                   = 0x00002000
+	CLIENT_RESERVED                       = 0x00004000
+	CLIENT_SECURE_CONNECTION              = 0x00008000
+	CLIENT_MULTI_STATEMENTS               = 0x00010000
+	CLIENT_MULTI_RESULTS                  = 0x00020000
+	CLIENT_PS_MULTI_RESULTS               = 0x00040000
+	CLIENT_PLUGIN_AUTH                    = 0x00080000
+	CLIENT_CONNECT_ATTRS                  = 0x00100000
+	CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA = 0x00200000
+	CLIENT_SESSION_TRACK                  = 0x00800000
+	CLIENT_DEPRECATE_EOF                  = 0x01000000
+)
+
+// UnsupportedCapabilities are the capabilities the proxy cannot relay:
+// it does not terminate TLS, decompress packets, relay multiple result sets
+// per command or resultsets that end with an OK packet instead of EOF, so
+// these are masked from the server handshake and client response
+const UnsupportedCapabilities = CLIENT_SSL | CLIENT_COMPRESS | CLIENT_MULTI_STATEMENTS | CLIENT_DEPRECATE_EOF
+
 // HandshakeV10 represents the initial handshake packet from server to client
 type HandshakeV10 struct {
 	ProtocolVersion uint8
@@ -55,14 +93,14 @@ func (h *HandshakeV10) Encode() []byte {
 	// Capability Flags Upper 2 bytes
 	buf = WriteUint16(buf, uint16(h.CapabilityFlags>>16))
 
-	// Auth Plugin Data Length
-	buf = append(buf, 21) // Length of auth plugin data (8 + 12 + 1)
+	// Auth Plugin Data Length (including the trailing NUL)
+	buf = append(buf, byte(len(h.AuthPluginData)+1))
 
 	// Reserved (10 bytes)
 	buf = append(buf, make([]byte, 10)...)
 
-	// Auth Plugin Data Part 2 (12 bytes)
-	buf = append(buf, h.AuthPluginData[8:20]...)
+	// Auth Plugin Data Part 2 (usually 12 bytes)
+	buf = append(buf, h.AuthPluginData[8:]...)
 	buf = append(buf, 0x00) // Null terminator for auth plugin data
 
 	// Auth Plugin Name
@@ -71,6 +109,89 @@ func (h *HandshakeV10) Encode() []byte {
 	return buf
 }
 
+// DecodeHandshakeV10 parses the initial handshake sent by the server
+func DecodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
+	r := &payloadReader{buf: payload}
+	h := &HandshakeV10{}
+
+	var err error
+	if h.ProtocolVersion, err = r.readByte(); err != nil {
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	if h.ProtocolVersion != 10 {
+		return nil, fmt.Errorf("unsupported handshake protocol version: %d", h.ProtocolVersion)
+	}
+	if h.ServerVersion, err = r.readNulString(); err != nil {
+		return nil, fmt.Errorf("handshake server version: %w", err)
+	}
+	if h.ConnectionID, err = r.readUint32(); err != nil {
+		return nil, fmt.Errorf("handshake connection id: %w", err)
+	}
+	authData1, err := r.readBytes(8)
+	if err != nil {
+		return nil, fmt.Errorf("handshake auth data: %w", err)
+	}
+	if _, err := r.readByte(); err != nil { // filler
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	capLower, err := r.readUint16()
+	if err != nil {
+		return nil, fmt.Errorf("handshake capabilities: %w", err)
+	}
+	h.CapabilityFlags = uint32(capLower)
+	h.AuthPluginData = append([]byte(nil), authData1...)
+
+	// Pre-4.1 servers stop here
+	if r.done() {
+		return h, nil
+	}
+
+	if h.CharacterSet, err = r.readByte(); err != nil {
+		return nil, fmt.Errorf("handshake character set: %w", err)
+	}
+	if h.StatusFlags, err = r.readUint16(); err != nil {
+		return nil, fmt.Errorf("handshake status flags: %w", err)
+	}
+	capUpper, err := r.readUint16()
+	if err != nil {
+		return nil, fmt.Errorf("handshake capabilities: %w", err)
+	}
+	h.CapabilityFlags |= uint32(capUpper) << 16
+
+	authDataLen, err := r.readByte()
+	if err != nil {
+		return nil, fmt.Errorf("handshake auth data length: %w", err)
+	}
+	if _, err := r.readBytes(10); err != nil { // reserved
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+
+	if h.CapabilityFlags&CLIENT_SECURE_CONNECTION != 0 {
+		part2Len := int(authDataLen) - 8
+		if part2Len < 13 {
+			part2Len = 13
+		}
+		authData2, err := r.readBytes(part2Len)
+		if err != nil {
+			return nil, fmt.Errorf("handshake auth data: %w", err)
+		}
+		// Drop the trailing NUL
+		if authData2[len(authData2)-1] == 0x00 {
+			authData2 = authData2[:len(authData2)-1]
+		}
+		h.AuthPluginData = append(h.AuthPluginData, authData2...)
+	}
+
+	if h.CapabilityFlags&CLIENT_PLUGIN_AUTH != 0 && !r.done() {
+		if h.AuthPluginName, err = r.readNulString(); err != nil {
+			// Some servers omit the final NUL
+			h.AuthPluginName = string(r.rest())
+		}
+	}
+
+	return h, nil
+}
+
 // HandshakeResponse41 represents the client's response to handshake
 type HandshakeResponse41 struct {
 	CapabilityFlags uint32
@@ -80,11 +201,246 @@ type HandshakeResponse41 struct {
 	AuthResponse    []byte
 	Database        string
 	AuthPluginName  string
+	Attributes      map[string]string
+}
+
+// IsSSLRequest reports whether a client handshake response payload is an
+// SSLRequest (the truncated response sent before switching to TLS)
+func IsSSLRequest(payload []byte) bool {
+	return len(payload) == 32 && binary.LittleEndian.Uint32(payload)&CLIENT_SSL != 0
 }
 
 // DecodeHandshakeResponse41 parses the client handshake response
 func DecodeHandshakeResponse41(payload []byte) (*HandshakeResponse41, error) {
-	// TODO: Implement decoding logic
-	// For MVP we might just proxy this directly to backend
-	return &HandshakeResponse41{}, nil
+	r := &payloadReader{buf: payload}
+	resp := &HandshakeResponse41{}
+
+	var err error
+	if resp.CapabilityFlags, err = r.readUint32(); err != nil {
+		return nil, fmt.Errorf("handshake response capabilities: %w", err)
+	}
+	if resp.CapabilityFlags&CLIENT_PROTOCOL_41 == 0 {
+		return nil, fmt.Errorf("handshake response is not protocol 4.1")
+	}
+	if resp.MaxPacketSize, err = r.readUint32(); err != nil {
+		return nil, fmt.Errorf("handshake response max packet size: %w", err)
+	}
+	if resp.CharacterSet, err = r.readByte(); err != nil {
+		return nil, fmt.Errorf("handshake response character set: %w", err)
+	}
+	if _, err := r.readBytes(23); err != nil { // filler
+		return nil, fmt.Errorf("handshake response: %w", err)
+	}
+	if r.done() && resp.CapabilityFlags&CLIENT_SSL != 0 {
+		return nil, fmt.Errorf("handshake response is an SSL request")
+	}
+	if resp.Username, err = r.readNulString(); err != nil {
+		return nil, fmt.Errorf("handshake response username: %w", err)
+	}
+
+	switch {
+	case resp.CapabilityFlags&CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA != 0:
+		length, n := readLengthEncodedInt(r.rest())
+		if n == 0 {
+			return nil, fmt.Errorf("handshake response auth data: unexpected end of packet")
+		}
+		r.pos += n
+		resp.AuthResponse, err = r.readBytes(int(length))
+	case resp.CapabilityFlags&CLIENT_SECURE_CONNECTION != 0:
+		var length byte
+		if length, err = r.readByte(); err == nil {
+			resp.AuthResponse, err = r.readBytes(int(length))
+		}
+	default:
+		var auth string
+		auth, err = r.readNulString()
+		resp.AuthResponse = []byte(auth)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("handshake response auth data: %w", err)
+	}
+	resp.AuthResponse = append([]byte(nil), resp.AuthResponse...)
+
+	if resp.CapabilityFlags&CLIENT_CONNECT_WITH_DB != 0 && !r.done() {
+		if resp.Database, err = r.readNulString(); err != nil {
+			return nil, fmt.Errorf("handshake response database: %w", err)
+		}
+	}
+	if resp.CapabilityFlags&CLIENT_PLUGIN_AUTH != 0 && !r.done() {
+		if resp.AuthPluginName, err = r.readNulString(); err != nil {
+			return nil, fmt.Errorf("handshake response auth plugin: %w", err)
+		}
+	}
+	if resp.CapabilityFlags&CLIENT_CONNECT_ATTRS != 0 && !r.done() {
+		length, n := readLengthEncodedInt(r.rest())
+		if n == 0 {
+			return nil, fmt.Errorf("handshake response attributes: unexpected end of packet")
+		}
+		r.pos += n
+		attrs, err := r.readBytes(int(length))
+		if err != nil {
+			return nil, fmt.Errorf("handshake response attributes: %w", err)
+		}
+		resp.Attributes = make(map[string]string)
+		for len(attrs) > 0 {
+			key, n, err := readLengthEncodedString(attrs)
+			if err != nil {
+				return nil, fmt.Errorf("handshake response attribute key: %w", err)
+			}
+			attrs = attrs[n:]
+			value, n, err := readLengthEncodedString(attrs)
+			if err != nil {
+				return nil, fmt.Errorf("handshake response attribute value: %w", err)
+			}
+			attrs = attrs[n:]
+			resp.Attributes[key] = value
+		}
+	}
+
+	return resp, nil
+}
+
+// Encode serializes the handshake response, writing only the optional fields
+// enabled by its capability flags
+func (resp *HandshakeResponse41) Encode() []byte {
+	var buf []byte
+
+	buf = WriteUint32(buf, resp.CapabilityFlags)
+	buf = WriteUint32(buf, resp.MaxPacketSize)
+	buf = append(buf, resp.CharacterSet)
+	buf = append(buf, make([]byte, 23)...)
+	buf = WriteString(buf, resp.Username)
+
+	switch {
+	case resp.CapabilityFlags&CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA != 0:
+		buf = WriteLengthEncodedInt(buf, uint64(len(resp.AuthResponse)))
+		buf = append(buf, resp.AuthResponse...)
+	case resp.CapabilityFlags&CLIENT_SECURE_CONNECTION != 0:
+		buf = append(buf, byte(len(resp.AuthResponse)))
+		buf = append(buf, resp.AuthResponse...)
+	default:
+		buf = append(buf, resp.AuthResponse...)
+		buf = append(buf, 0x00)
+	}
+
+	if resp.CapabilityFlags&CLIENT_CONNECT_WITH_DB != 0 {
+		buf = WriteString(buf, resp.Database)
+	}
+	if resp.CapabilityFlags&CLIENT_PLUGIN_AUTH != 0 {
+		buf = WriteString(buf, resp.AuthPluginName)
+	}
+	if resp.CapabilityFlags&CLIENT_CONNECT_ATTRS != 0 {
+		// Sort keys so the encoding is deterministic
+		keys := make([]string, 0, len(resp.Attributes))
+		for k := range resp.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var attrs []byte
+		for _, k := range keys {
+			attrs = WriteLengthEncodedString(attrs, k)
+			attrs = WriteLengthEncodedString(attrs, resp.Attributes[k])
+		}
+		buf = WriteLengthEncodedInt(buf, uint64(len(attrs)))
+		buf = append(buf, attrs...)
+	}
+
+	return buf
+}
+
+// AuthSwitchRequest asks the client to authenticate again with another
+// plugin during the connection phase
+type AuthSwitchRequest struct {
+	PluginName string
+	// PluginData is kept as sent; mysql_native_password and
+	// caching_sha2_password end their scramble with a NUL
+	PluginData []byte
+}
+
+// DecodeAuthSwitchRequest parses an auth switch request sent by the server
+func DecodeAuthSwitchRequest(payload []byte) (*AuthSwitchRequest, error) {
+	r := &payloadReader{buf: payload}
+
+	header, err := r.readByte()
+	if err != nil {
+		return nil, fmt.Errorf("auth switch request: %w", err)
+	}
+	if header != EOF_PACKET {
+		return nil, fmt.Errorf("not an auth switch request: 0x%02X", header)
+	}
+
+	req := &AuthSwitchRequest{}
+	if req.PluginName, err = r.readNulString(); err != nil {
+		return nil, fmt.Errorf("auth switch request plugin: %w", err)
+	}
+	req.PluginData = append([]byte(nil), r.rest()...)
+
+	return req, nil
+}
+
+// Encode serializes the auth switch request
+func (req *AuthSwitchRequest) Encode() []byte {
+	buf := []byte{EOF_PACKET}
+	buf = WriteString(buf, req.PluginName)
+	buf = append(buf, req.PluginData...)
+	return buf
+}
+
+// payloadReader reads fields sequentially from a packet payload
+type payloadReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *payloadReader) done() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *payloadReader) rest() []byte {
+	return r.buf[r.pos:]
+}
+
+func (r *payloadReader) readByte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, fmt.Errorf("unexpected end of packet")
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *payloadReader) readBytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.buf) {
+		return nil, fmt.Errorf("unexpected end of packet")
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *payloadReader) readUint16() (uint16, error) {
+	b, err := r.readBytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (r *payloadReader) readUint32() (uint32, error) {
+	b, err := r.readBytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (r *payloadReader) readNulString() (string, error) {
+	idx := bytes.IndexByte(r.buf[r.pos:], 0x00)
+	if idx < 0 {
+		return "", fmt.Errorf("unterminated string")
+	}
+	s := string(r.buf[r.pos : r.pos+idx])
+	r.pos += idx + 1
+	return s, nil
 }
@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandshakeV10RoundTrip(t *testing.T) {
+	orig := NewHandshakeV10(42)
+	orig.CapabilityFlags = CLIENT_PROTOCOL_41 | CLIENT_SECURE_CONNECTION | CLIENT_PLUGIN_AUTH |
+		CLIENT_SSL | CLIENT_MULTI_STATEMENTS | CLIENT_DEPRECATE_EOF
+
+	decoded, err := DecodeHandshakeV10(orig.Encode())
+	require.NoError(t, err)
+
+	assert.Equal(t, uint8(10), decoded.ProtocolVersion)
+	assert.Equal(t, orig.ServerVersion, decoded.ServerVersion)
+	assert.Equal(t, uint32(42), decoded.ConnectionID)
+	assert.Equal(t, orig.AuthPluginData, decoded.AuthPluginData)
+	assert.Equal(t, orig.CapabilityFlags, decoded.CapabilityFlags)
+	assert.Equal(t, orig.CharacterSet, decoded.CharacterSet)
+	assert.Equal(t, orig.StatusFlags, decoded.StatusFlags)
+	assert.Equal(t, "mysql_native_password", decoded.AuthPluginName)
+
+	decoded.CapabilityFlags &^= UnsupportedCapabilities
+	masked, err := DecodeHandshakeV10(decoded.Encode())
+	require.NoError(t, err)
+	assert.Zero(t, masked.CapabilityFlags&UnsupportedCapabilities)
+	assert.NotZero(t, masked.CapabilityFlags&CLIENT_PLUGIN_AUTH)
+}
+
+func TestDecodeHandshakeV10Invalid(t *testing.T) {
+	_, err := DecodeHandshakeV10([]byte{9, 'x', 0})
+	assert.Error(t, err)
+
+	_, err = DecodeHandshakeV10([]byte{10, 'x'})
+	assert.Error(t, err)
+}
+
+func TestHandshakeResponse41RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags uint32
+	}{
+		{"secure connection", CLIENT_PROTOCOL_41 | CLIENT_SECURE_CONNECTION | CLIENT_CONNECT_WITH_DB | CLIENT_PLUGIN_AUTH},
+		{"lenenc auth data with attributes", CLIENT_PROTOCOL_41 | CLIENT_SECURE_CONNECTION | CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA |
+			CLIENT_CONNECT_WITH_DB | CLIENT_PLUGIN_AUTH | CLIENT_CONNECT_ATTRS | CLIENT_MULTI_STATEMENTS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := &HandshakeResponse41{
+				CapabilityFlags: tt.flags,
+				MaxPacketSize:   16777216,
+				CharacterSet:    45,
+				Username:        "app_user",
+				AuthResponse:    []byte{0x01, 0x00, 0x02, 0x03},
+				Database:        "ecommerce_db",
+				AuthPluginName:  "caching_sha2_password",
+			}
+			if tt.flags&CLIENT_CONNECT_ATTRS != 0 {
+				orig.Attributes = map[string]string{"_client_name": "libmysql", "_pid": "1234"}
+			}
+
+			decoded, err := DecodeHandshakeResponse41(orig.Encode())
+			require.NoError(t, err)
+			assert.Equal(t, orig, decoded)
+		})
+	}
+}
+
+func TestIsSSLRequest(t *testing.T) {
+	resp := &HandshakeResponse41{CapabilityFlags: CLIENT_PROTOCOL_41 | CLIENT_SSL, MaxPacketSize: 1 << 24, CharacterSet: 45}
+	sslRequest := resp.Encode()[:32]
+
+	assert.True(t, IsSSLRequest(sslRequest))
+	_, err := DecodeHandshakeResponse41(sslRequest)
+	assert.Error(t, err)
+
+	resp.CapabilityFlags = CLIENT_PROTOCOL_41
+	assert.False(t, IsSSLRequest(resp.Encode()))
+}
@@ -2,7 +2,6 @@ package protocol
 
 import (
 	"encoding/binary"
-	"fmt"
 	"io"
 )
 
@@ -13,50 +12,69 @@ type Packet struct {
 	Payload    []byte
 }
 
-// ReadPacket reads a packet from the connection
+// MaxPacketSize is the largest payload of one physical packet. Longer
+// payloads are split into packets of this size followed by the remainder,
+// which is empty when the payload is a multiple of it.
+const MaxPacketSize = 1<<24 - 1
+
+// ReadPacket reads a packet from the connection. A payload split across
+// several physical packets is reassembled; the packet carries the sequence ID
+// of the first one.
 func ReadPacket(r io.Reader) (*Packet, error) {
+	var pkt *Packet
 	header := make([]byte, 4)
-	if _, err := io.ReadFull(r, header); err != nil {
-		return nil, err
-	}
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
 
-	length := uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16
-	sequenceID := header[3]
+		length := uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16
+		sequenceID := header[3]
 
-	payload := make([]byte, length)
-	if _, err := io.ReadFull(r, payload); err != nil {
-		return nil, err
-	}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
 
-	return &Packet{
-		Length:     length,
-		SequenceID: sequenceID,
-		Payload:    payload,
-	}, nil
+		if pkt == nil {
+			pkt = &Packet{SequenceID: sequenceID, Payload: payload}
+		} else {
+			pkt.Payload = append(pkt.Payload, payload...)
+		}
+		pkt.Length = uint32(len(pkt.Payload))
+		if length < MaxPacketSize {
+			return pkt, nil
+		}
+	}
 }
 
-// WritePacket writes a packet to the connection
+// WritePacket writes a packet to the connection. Payloads of MaxPacketSize
+// or more are split into physical packets with consecutive sequence IDs.
 func WritePacket(w io.Writer, sequenceID uint8, payload []byte) error {
-	length := len(payload)
-	if length > 16777215 {
-		return fmt.Errorf("packet too large: %d", length)
-	}
-
 	header := make([]byte, 4)
-	header[0] = byte(length)
-	header[1] = byte(length >> 8)
-	header[2] = byte(length >> 16)
-	header[3] = sequenceID
+	for {
+		length := len(payload)
+		if length > MaxPacketSize {
+			length = MaxPacketSize
+		}
+		header[0] = byte(length)
+		header[1] = byte(length >> 8)
+		header[2] = byte(length >> 16)
+		header[3] = sequenceID
 
-	if _, err := w.Write(header); err != nil {
-		return err
-	}
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		if _, err := w.Write(payload[:length]); err != nil {
+			return err
+		}
 
-	if _, err := w.Write(payload); err != nil {
-		return err
+		if length < MaxPacketSize {
+			return nil
+		}
+		payload = payload[length:]
+		sequenceID++
 	}
-
-	return nil
 }
 
 // WriteString writes a length-encoded string
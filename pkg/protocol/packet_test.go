@@ -0,0 +1,101 @@
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WritePacket(&buf, 3, []byte{COM_PING}))
+	assert.Equal(t, []byte{0x01, 0x00, 0x00, 0x03, COM_PING}, buf.Bytes())
+
+	pkt, err := ReadPacket(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(3), pkt.SequenceID)
+	assert.Equal(t, []byte{COM_PING}, pkt.Payload)
+}
+
+func TestPacketSplitsLargePayloads(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		packets []int
+	}{
+		{"one byte over", MaxPacketSize + 1, []int{MaxPacketSize, 1}},
+		// A payload of exactly the maximum ends with an empty packet
+		{"exact multiple", MaxPacketSize, []int{MaxPacketSize, 0}},
+		{"two packets and a remainder", 2*MaxPacketSize + 7, []int{MaxPacketSize, MaxPacketSize, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := bytes.Repeat([]byte{0xab}, tt.size)
+			payload[tt.size-1] = 0xcd
+
+			var buf bytes.Buffer
+			require.NoError(t, WritePacket(&buf, 254, payload))
+			require.NoError(t, WritePacket(&buf, 0, []byte{0x00}))
+
+			// Physical packets carry consecutive sequence IDs, wrapping at 255
+			raw := buf.Bytes()
+			for i, size := range tt.packets {
+				length := int(raw[0]) | int(raw[1])<<8 | int(raw[2])<<16
+				assert.Equal(t, size, length)
+				assert.Equal(t, uint8(254+i), raw[3])
+				raw = raw[4+length:]
+			}
+
+			pkt, err := ReadPacket(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, uint8(254), pkt.SequenceID)
+			assert.Equal(t, uint32(tt.size), pkt.Length)
+			assert.True(t, bytes.Equal(payload, pkt.Payload), "payload differs after reassembly")
+
+			// The next packet is read whole, not mistaken for a continuation
+			next, err := ReadPacket(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{0x00}, next.Payload)
+		})
+	}
+}
+
+func BenchmarkPacket(b *testing.B) {
+	for _, size := range []int{64, 16 << 10, MaxPacketSize + 1} {
+		payload := bytes.Repeat([]byte{0xab}, size)
+
+		b.Run(fmt.Sprintf("write/%d", size), func(b *testing.B) {
+			var buf bytes.Buffer
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := WritePacket(&buf, 0, payload); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("read/%d", size), func(b *testing.B) {
+			var buf bytes.Buffer
+			if err := WritePacket(&buf, 0, payload); err != nil {
+				b.Fatal(err)
+			}
+			raw := buf.Bytes()
+			r := bytes.NewReader(raw)
+
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.Reset(raw)
+				if _, err := ReadPacket(r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
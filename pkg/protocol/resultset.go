@@ -144,6 +144,13 @@ func IsERRPacket(payload []byte) bool {
 	return payload[0] == ERR_PACKET
 }
 
+// IsLocalInfileRequest checks if a payload asks the client to send a file for
+// LOAD DATA LOCAL INFILE
+func IsLocalInfileRequest(payload []byte) bool {
+	// A resultset's column count is never NULL (0xFB)
+	return len(payload) > 1 && payload[0] == LOCAL_INFILE_PACKET
+}
+
 // readLengthEncodedInt reads a MySQL length-encoded integer
 func readLengthEncodedInt(b []byte) (uint64, int) {
 	if len(b) == 0 {
@@ -221,3 +228,36 @@ func WriteLengthEncodedString(buf []byte, s string) []byte {
 	buf = WriteLengthEncodedInt(buf, uint64(len(s)))
 	return append(buf, []byte(s)...)
 }
+
+// EncodeERRPacket builds an ERR packet payload (CLIENT_PROTOCOL_41 format)
+func EncodeERRPacket(errorCode uint16, sqlState string, message string) []byte {
+	if len(sqlState) != 5 {
+		sqlState = "HY000"
+	}
+
+	buf := make([]byte, 0, 9+len(message))
+	buf = append(buf, ERR_PACKET)
+	buf = WriteUint16(buf, errorCode)
+	buf = append(buf, '#')
+	buf = append(buf, sqlState...)
+	buf = append(buf, message...)
+	return buf
+}
+
+// EncodeOKPacket builds an OK packet payload (CLIENT_PROTOCOL_41 format)
+func EncodeOKPacket(affectedRows, lastInsertID uint64, statusFlags, warnings uint16) []byte {
+	buf := []byte{OK_PACKET}
+	buf = WriteLengthEncodedInt(buf, affectedRows)
+	buf = WriteLengthEncodedInt(buf, lastInsertID)
+	buf = WriteUint16(buf, statusFlags)
+	buf = WriteUint16(buf, warnings)
+	return buf
+}
+
+// EncodeEOFPacket builds an EOF packet payload (CLIENT_PROTOCOL_41 format)
+func EncodeEOFPacket(warnings, statusFlags uint16) []byte {
+	buf := []byte{EOF_PACKET}
+	buf = WriteUint16(buf, warnings)
+	buf = WriteUint16(buf, statusFlags)
+	return buf
+}
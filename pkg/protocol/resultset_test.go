@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// Payloads below follow the wire format MySQL 8.0 sends for
+// "SELECT id, total_amount, discount, order_no FROM ecommerce_db.orders"
+// and "SELECT @@version_comment"
+func TestColumnDefinition41(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    ColumnDefinition41
+	}{
+		{
+			name:    "table column",
+			payload: "036465660c65636f6d6d657263655f6462066f7264657273066f72646572730c746f74616c5f616d6f756e740c746f74616c5f616d6f756e740c3f0014000000080110000000",
+			want: ColumnDefinition41{
+				Catalog: "def", Schema: "ecommerce_db", Table: "orders", OrgTable: "orders",
+				Name: "total_amount", OrgName: "total_amount",
+				CharacterSet: 63, ColumnLength: 20, Type: MYSQL_TYPE_LONGLONG,
+				Flags: NOT_NULL_FLAG | 0x1000, Decimals: 0,
+			},
+		},
+		{
+			name:    "system variable",
+			payload: "0364656600000011404076657273696f6e5f636f6d6d656e74000c210054000000fd00001f0000",
+			want: ColumnDefinition41{
+				Catalog: "def", Name: "@@version_comment",
+				CharacterSet: 33, ColumnLength: 84, Type: MYSQL_TYPE_VAR_STRING, Decimals: 0x1f,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := mustHex(t, tt.payload)
+
+			col, err := DecodeColumnDefinition41(payload)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *col)
+			assert.Equal(t, payload, col.Encode())
+		})
+	}
+
+	_, err := DecodeColumnDefinition41(mustHex(t, "03646566"))
+	assert.Error(t, err)
+
+	// The catalog is encoded as given, not defaulted
+	col := ColumnDefinition41{Name: "x"}
+	decoded, err := DecodeColumnDefinition41(col.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, "", decoded.Catalog)
+}
+
+func TestTextRow(t *testing.T) {
+	payload := mustHex(t, "01310731353030303030fb074f52442d303031")
+
+	row, err := DecodeTextRow(payload, 4)
+	require.NoError(t, err)
+	require.Len(t, row, 4)
+	assert.Equal(t, "1", row.String(0))
+	assert.Equal(t, "1500000", row.String(1))
+	assert.True(t, row.IsNull(2))
+	assert.False(t, row.IsNull(3))
+	assert.Equal(t, "ORD-001", row.String(3))
+	assert.Equal(t, payload, row.Encode())
+
+	// Empty strings are not NULL
+	empty := TextRow{[]byte{}, nil}
+	decoded, err := DecodeTextRow(empty.Encode(), 2)
+	require.NoError(t, err)
+	assert.False(t, decoded.IsNull(0))
+	assert.True(t, decoded.IsNull(1))
+
+	_, err = DecodeTextRow(payload, 5)
+	assert.Error(t, err)
+	_, err = DecodeTextRow(payload, 3)
+	assert.Error(t, err)
+}
+
+func TestColumnCount(t *testing.T) {
+	count, err := DecodeColumnCount(EncodeColumnCount(4))
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	_, err = DecodeColumnCount(EncodeOKPacket(0, 0, 2, 0))
+	assert.Error(t, err)
+}
+
+func TestEncodeOKAndEOFPackets(t *testing.T) {
+	ok, err := ParseOKPacket(EncodeOKPacket(3, 42, 2, 1))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), ok.AffectedRows)
+	assert.Equal(t, uint64(42), ok.LastInsertID)
+	assert.Equal(t, uint16(2), ok.StatusFlags)
+	assert.Equal(t, uint16(1), ok.Warnings)
+
+	eof := EncodeEOFPacket(0, 0x22)
+	assert.True(t, IsEOFPacket(eof))
+	parsed, err := ParseEOFPacket(eof)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0x22), parsed.StatusFlags)
+}
+
+func TestIsLocalInfileRequest(t *testing.T) {
+	assert.True(t, IsLocalInfileRequest(append([]byte{LOCAL_INFILE_PACKET}, "rows.csv"...)))
+	assert.False(t, IsLocalInfileRequest([]byte{0x02}))
+	assert.False(t, IsLocalInfileRequest([]byte{LOCAL_INFILE_PACKET}))
+}
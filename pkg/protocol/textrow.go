@@ -0,0 +1,68 @@
+package protocol
+
+import (
+	"fmt"
+)
+
+// TextRow is a resultset row in the text protocol. Each value is the textual
+// representation MySQL sends; a nil value is SQL NULL.
+type TextRow [][]byte
+
+// DecodeTextRow parses a text protocol row packet with the given number of columns
+func DecodeTextRow(payload []byte, columnCount int) (TextRow, error) {
+	row := make(TextRow, 0, columnCount)
+	pos := 0
+
+	for i := 0; i < columnCount; i++ {
+		if pos >= len(payload) {
+			return nil, fmt.Errorf("text row: expected %d columns, got %d", columnCount, i)
+		}
+
+		// 0xfb marks NULL
+		if payload[pos] == 0xfb {
+			row = append(row, nil)
+			pos++
+			continue
+		}
+
+		length, n := readLengthEncodedInt(payload[pos:])
+		if n == 0 || uint64(len(payload)-pos-n) < length {
+			return nil, fmt.Errorf("text row: column %d: unexpected end of packet", i)
+		}
+		pos += n
+		value := make([]byte, length)
+		copy(value, payload[pos:pos+int(length)])
+		row = append(row, value)
+		pos += int(length)
+	}
+
+	if pos != len(payload) {
+		return nil, fmt.Errorf("text row: %d trailing bytes", len(payload)-pos)
+	}
+
+	return row, nil
+}
+
+// Encode serializes the row as a text protocol row packet payload
+func (row TextRow) Encode() []byte {
+	var buf []byte
+	for _, value := range row {
+		if value == nil {
+			buf = append(buf, 0xfb)
+			continue
+		}
+		buf = WriteLengthEncodedInt(buf, uint64(len(value)))
+		buf = append(buf, value...)
+	}
+	return buf
+}
+
+// IsNull reports whether the value at index i is SQL NULL
+func (row TextRow) IsNull(i int) bool {
+	return row[i] == nil
+}
+
+// String returns the value at index i as a string (empty for NULL)
+func (row TextRow) String(i int) string {
+	return string(row[i])
+}
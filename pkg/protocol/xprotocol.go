@@ -0,0 +1,63 @@
+package protocol
+
+import (
+	"encoding/binary"
+)
+
+// MySQL X Protocol (port 33060) frames: a 4-byte little-endian length that
+// counts the type byte, the message type and a protobuf body. The proxy does
+// not speak it; these helpers only recognize such clients and turn them away.
+
+// X Protocol client message types that open a session
+const (
+	xClientCapabilitiesGet = 1
+	xClientCapabilitiesSet = 2
+	xClientClose           = 3
+	xClientAuthStart       = 4
+)
+
+// xServerError is the X Protocol server message type of Mysqlx.Error
+const xServerError = 1
+
+// IsXProtocolMessage reports whether a packet read where the client
+// handshake response belongs is the first X Protocol message of a session.
+// Read as a classic packet, an X frame has the high length byte as sequence
+// 0, where a handshake response has sequence 1, and the message type first.
+func IsXProtocolMessage(sequenceID uint8, payload []byte) bool {
+	if sequenceID != 0 || len(payload) == 0 {
+		return false
+	}
+	switch payload[0] {
+	case xClientCapabilitiesGet, xClientCapabilitiesSet, xClientClose, xClientAuthStart:
+		return true
+	}
+	return false
+}
+
+// EncodeXError returns an X Protocol frame holding a fatal Mysqlx.Error, so
+// an X Protocol client reports why the connection closed
+func EncodeXError(code uint32, sqlState, message string) []byte {
+	var body []byte
+	body = appendProtoVarint(body, 1, 1) // severity: FATAL
+	body = appendProtoVarint(body, 2, uint64(code))
+	body = appendProtoBytes(body, 3, message)
+	body = appendProtoBytes(body, 4, sqlState)
+
+	frame := make([]byte, 5, 5+len(body))
+	binary.LittleEndian.PutUint32(frame, uint32(len(body)+1))
+	frame[4] = xServerError
+	return append(frame, body...)
+}
+
+// appendProtoVarint appends a protobuf varint field
+func appendProtoVarint(buf []byte, field int, value uint64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3)
+	return binary.AppendUvarint(buf, value)
+}
+
+// appendProtoBytes appends a protobuf length-delimited field
+func appendProtoBytes(buf []byte, field int, value string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
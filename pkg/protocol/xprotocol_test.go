@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsXProtocolMessage(t *testing.T) {
+	// Mysqlx.Connection.CapabilitiesGet, the first message of most X clients
+	pkt, err := ReadPacket(bytes.NewReader([]byte{0x01, 0x00, 0x00, 0x00, 0x01}))
+	require.NoError(t, err)
+	assert.True(t, IsXProtocolMessage(pkt.SequenceID, pkt.Payload))
+
+	// CapabilitiesSet asking for TLS
+	pkt, err = ReadPacket(bytes.NewReader([]byte{0x05, 0x00, 0x00, 0x00, 0x02, 0x0a, 0x02, 0x0a, 0x00}))
+	require.NoError(t, err)
+	assert.True(t, IsXProtocolMessage(pkt.SequenceID, pkt.Payload))
+
+	resp := &HandshakeResponse41{CapabilityFlags: CLIENT_PROTOCOL_41, MaxPacketSize: 1 << 24, CharacterSet: 45, Username: "app"}
+	assert.False(t, IsXProtocolMessage(1, resp.Encode()))
+	assert.False(t, IsXProtocolMessage(0, nil))
+	assert.False(t, IsXProtocolMessage(0, []byte{0x0a}))
+}
+
+func TestEncodeXError(t *testing.T) {
+	frame := EncodeXError(1043, "08S01", "no")
+	assert.Equal(t, []byte{
+		0x11, 0x00, 0x00, 0x00, // length: type byte and 16 body bytes
+		0x01,       // Mysqlx.Error
+		0x08, 0x01, // severity FATAL
+		0x10, 0x93, 0x08, // code 1043
+		0x1a, 0x02, 'n', 'o', // msg
+		0x22, 0x05, '0', '8', 'S', '0', '1', // sql_state
+	}, frame)
+}
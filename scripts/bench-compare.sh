@@ -0,0 +1,49 @@
+#!/usr/bin/env bash
+# Runs the hot path benchmarks at a base revision and on the working tree,
+# prints the benchstat comparison and fails when a benchmark got slower or
+# allocates more than BENCH_THRESHOLD percent.
+#
+#   BENCH_BASE       revision to compare against (default: latest tag, else HEAD~1)
+#   BENCH_COUNT      runs per benchmark (default: 10)
+#   BENCH_THRESHOLD  allowed regression in percent (default: 10)
+#   BENCH_PKGS       packages to benchmark
+#   BENCHSTAT        benchstat command
+set -euo pipefail
+
+base="${BENCH_BASE:-$(git describe --tags --abbrev=0 2>/dev/null || echo HEAD~1)}"
+count="${BENCH_COUNT:-10}"
+threshold="${BENCH_THRESHOLD:-10}"
+pkgs="${BENCH_PKGS:-./internal/proxy/ ./pkg/protocol/}"
+benchstat="${BENCHSTAT:-go run golang.org/x/perf/cmd/benchstat@latest}"
+out=".bench"
+
+mkdir -p "$out"
+worktree="$out/base"
+git worktree remove --force "$worktree" 2>/dev/null || true
+git worktree add --detach --quiet "$worktree" "$base"
+trap 'git worktree remove --force "$worktree"' EXIT
+
+echo "Benchmarking $base"
+# Benchmarks the base does not have yet are reported as new
+(cd "$worktree" && go test -run '^$' -bench . -benchmem -count "$count" $pkgs) > "$out/old.txt" || true
+
+echo "Benchmarking working tree"
+go test -run '^$' -bench . -benchmem -count "$count" $pkgs > "$out/new.txt"
+
+$benchstat "$out/old.txt" "$out/new.txt"
+
+# benchstat only prints a delta when the change is significant
+$benchstat -format csv "$out/old.txt" "$out/new.txt" 2>/dev/null | awk -F, -v limit="$threshold" '
+	/^,/ { unit = $2; next }
+	unit != "sec/op" && unit != "allocs/op" { next }
+	$1 == "geomean" || $6 !~ /^\+/ { next }
+	{
+		delta = $6
+		gsub(/[+%]/, "", delta)
+		if (delta + 0 > limit) {
+			printf "regression: %s %s %s\n", $1, unit, $6 > "/dev/stderr"
+			failed = 1
+		}
+	}
+	END { exit failed }
+' || { echo "Benchmarks regressed more than ${threshold}% against $base" >&2; exit 1; }